@@ -5,20 +5,22 @@ go 1.22.0
 require (
 	github.com/eclipse/paho.mqtt.golang v1.4.3
 	github.com/goburrow/modbus v0.1.0
+	github.com/goburrow/serial v0.1.0
 	github.com/gopcua/opcua v0.5.3
 	github.com/prometheus/client_golang v1.19.0
 	github.com/robinson/gos7 v0.0.0-20241205073040-7ea1d6fb9d20
 	github.com/rs/zerolog v1.32.0
 	github.com/sony/gobreaker v0.5.0
 	github.com/spf13/viper v1.18.2
+	google.golang.org/protobuf v1.32.0
 	gopkg.in/yaml.v3 v3.0.1
 )
 
 require (
 	github.com/beorn7/perks v1.0.1 // indirect
 	github.com/cespare/xxhash/v2 v2.2.0 // indirect
+	github.com/davecgh/go-spew v1.1.2-0.20180830191138-d8f796af33cc // indirect
 	github.com/fsnotify/fsnotify v1.7.0 // indirect
-	github.com/goburrow/serial v0.1.0 // indirect
 	github.com/gorilla/websocket v1.5.0 // indirect
 	github.com/hashicorp/hcl v1.0.0 // indirect
 	github.com/magiconair/properties v1.8.7 // indirect
@@ -44,6 +46,9 @@ require (
 	golang.org/x/sync v0.6.0 // indirect
 	golang.org/x/sys v0.16.0 // indirect
 	golang.org/x/text v0.14.0 // indirect
-	google.golang.org/protobuf v1.32.0 // indirect
 	gopkg.in/ini.v1 v1.67.0 // indirect
 )
+
+// Fork of gos7 that adds NewTCPClientHandlerWithTSAP; upstream fixes the
+// local TSAP at 01.00.
+replace github.com/robinson/gos7 => ./third_party/gos7
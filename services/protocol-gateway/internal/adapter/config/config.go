@@ -80,6 +80,8 @@ type MQTTConfig struct {
 	Password       string        `mapstructure:"password"`
 	CleanSession   bool          `mapstructure:"clean_session"`
 	QoS            byte          `mapstructure:"qos"`
+	ControlQoS     byte          `mapstructure:"control_qos"`
+	SafetyQoS      byte          `mapstructure:"safety_qos"`
 	KeepAlive      time.Duration `mapstructure:"keep_alive"`
 	ConnectTimeout time.Duration `mapstructure:"connect_timeout"`
 	ReconnectDelay time.Duration `mapstructure:"reconnect_delay"`
@@ -89,6 +91,17 @@ type MQTTConfig struct {
 	TLSKeyFile     string        `mapstructure:"tls_key_file"`
 	TLSCAFile      string        `mapstructure:"tls_ca_file"`
 	BufferSize     int           `mapstructure:"buffer_size"`
+	// Capacity of each control/safety buffer lane (telemetry uses BufferSize)
+	PriorityBufferSize int `mapstructure:"priority_buffer_size"`
+	// Store-and-forward buffer on disk; disabled when BufferDir is empty
+	BufferDir         string        `mapstructure:"buffer_dir"`
+	BufferMaxBytes    int64         `mapstructure:"buffer_max_bytes"`
+	BufferMaxAge      time.Duration `mapstructure:"buffer_max_age"`
+	BufferSegmentSize int64         `mapstructure:"buffer_segment_size"`
+	// Output format: "json" (per-tag UNS topics) or "sparkplug_b"
+	PayloadFormat       string `mapstructure:"payload_format"`
+	SparkplugGroupID    string `mapstructure:"sparkplug_group_id"`
+	SparkplugEdgeNodeID string `mapstructure:"sparkplug_edge_node_id"` // Defaults to client_id
 }
 
 // ModbusConfig holds Modbus connection pool configuration.
@@ -117,6 +130,10 @@ type OPCUAConfig struct {
 	TrustStorePath    string        `mapstructure:"trust_store_path"`    // Path to PKI directory (default: ./pki)
 	AutoTrust         bool          `mapstructure:"auto_trust"`          // Auto-accept untrusted certs (dev only!)
 	CertCheckInterval time.Duration `mapstructure:"cert_check_interval"` // How often to check cert expiry (default: 1h)
+
+	// History backfill after subscription recovery (devices with opc_history_backfill)
+	BackfillMaxWindow time.Duration `mapstructure:"backfill_max_window"` // Longest gap read from history (default: 1h)
+	BackfillMaxValues int           `mapstructure:"backfill_max_values"` // Max values read per tag and gap (default: 10000)
 }
 
 // S7Config holds S7 connection pool configuration.
@@ -137,10 +154,13 @@ type S7Config struct {
 // PollingConfig holds polling service configuration.
 type PollingConfig struct {
 	WorkerCount     int           `mapstructure:"worker_count"`
+	ControlWorkers  int           `mapstructure:"control_workers"`
+	SafetyWorkers   int           `mapstructure:"safety_workers"`
 	BatchSize       int           `mapstructure:"batch_size"`
 	DefaultInterval time.Duration `mapstructure:"default_interval"`
 	MaxRetries      int           `mapstructure:"max_retries"`
 	ShutdownTimeout time.Duration `mapstructure:"shutdown_timeout"`
+	MaxSilence      time.Duration `mapstructure:"max_silence"` // Heartbeat for deadband-filtered tags
 }
 
 // LoggingConfig holds logging configuration.
@@ -231,11 +251,21 @@ func setDefaults(v *viper.Viper) {
 	v.SetDefault("mqtt.client_id", "protocol-gateway")
 	v.SetDefault("mqtt.clean_session", true)
 	v.SetDefault("mqtt.qos", 1)
+	v.SetDefault("mqtt.control_qos", 1)
+	v.SetDefault("mqtt.safety_qos", 2)
 	v.SetDefault("mqtt.keep_alive", 30*time.Second)
 	v.SetDefault("mqtt.connect_timeout", 10*time.Second)
 	v.SetDefault("mqtt.reconnect_delay", 5*time.Second)
 	v.SetDefault("mqtt.max_reconnect", -1)
 	v.SetDefault("mqtt.buffer_size", 10000)
+	v.SetDefault("mqtt.priority_buffer_size", 1000)
+	v.SetDefault("mqtt.buffer_dir", "")
+	v.SetDefault("mqtt.buffer_max_bytes", int64(1<<30)) // 1GiB
+	v.SetDefault("mqtt.buffer_max_age", 72*time.Hour)
+	v.SetDefault("mqtt.buffer_segment_size", int64(4<<20)) // 4MiB
+	v.SetDefault("mqtt.payload_format", "json")
+	v.SetDefault("mqtt.sparkplug_group_id", "")
+	v.SetDefault("mqtt.sparkplug_edge_node_id", "")
 
 	// Modbus
 	v.SetDefault("modbus.max_connections", 100)
@@ -258,6 +288,8 @@ func setDefaults(v *viper.Viper) {
 	v.SetDefault("opcua.trust_store_path", "./pki")
 	v.SetDefault("opcua.auto_trust", false)
 	v.SetDefault("opcua.cert_check_interval", 1*time.Hour)
+	v.SetDefault("opcua.backfill_max_window", 1*time.Hour)
+	v.SetDefault("opcua.backfill_max_values", 10000)
 
 	// S7
 	v.SetDefault("s7.max_connections", 100)
@@ -274,10 +306,13 @@ func setDefaults(v *viper.Viper) {
 
 	// Polling
 	v.SetDefault("polling.worker_count", 10)
+	v.SetDefault("polling.control_workers", 4)
+	v.SetDefault("polling.safety_workers", 2)
 	v.SetDefault("polling.batch_size", 50)
 	v.SetDefault("polling.default_interval", 1*time.Second)
 	v.SetDefault("polling.max_retries", 3)
 	v.SetDefault("polling.shutdown_timeout", 30*time.Second)
+	v.SetDefault("polling.max_silence", 60*time.Second)
 
 	// Logging
 	v.SetDefault("logging.level", "info")
@@ -323,6 +358,15 @@ func (c *Config) Validate() error {
 	if c.MQTT.BrokerURL == "" {
 		return fmt.Errorf("MQTT broker URL is required")
 	}
+	switch c.MQTT.PayloadFormat {
+	case "", "json":
+	case "sparkplug_b":
+		if c.MQTT.SparkplugGroupID == "" {
+			return fmt.Errorf("mqtt.sparkplug_group_id is required for the sparkplug_b payload format")
+		}
+	default:
+		return fmt.Errorf("invalid MQTT payload format: %s (must be json or sparkplug_b)", c.MQTT.PayloadFormat)
+	}
 	if c.HTTP.Port <= 0 || c.HTTP.Port > 65535 {
 		return fmt.Errorf("invalid HTTP port: %d", c.HTTP.Port)
 	}
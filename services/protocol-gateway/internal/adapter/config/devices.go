@@ -34,29 +34,78 @@ type ConnectionConfig struct {
 	RetryDelay string `yaml:"retry_delay"`
 
 	// Modbus
-	SlaveID int `yaml:"slave_id"`
+	SlaveID         int    `yaml:"slave_id"`
+	Framing         string `yaml:"framing,omitempty"`
+	ShareConnection bool   `yaml:"share_connection,omitempty"`
+
+	ModbusProfile  string                `yaml:"modbus_profile,omitempty"`
+	ModbusBatching *ModbusBatchingConfig `yaml:"modbus_batching,omitempty"`
+
+	// Modbus RTU (serial)
+	SerialPort string `yaml:"serial_port,omitempty"`
+	BaudRate   int    `yaml:"baud_rate,omitempty"`
+	DataBits   int    `yaml:"data_bits,omitempty"`
+	Parity     string `yaml:"parity,omitempty"`
+	StopBits   int    `yaml:"stop_bits,omitempty"`
+
+	TurnaroundDelay string `yaml:"turnaround_delay,omitempty"`
 
 	// OPC UA
-	OPCEndpointURL        string `yaml:"opc_endpoint_url"`
-	OPCSecurityPolicy     string `yaml:"opc_security_policy"`
-	OPCSecurityMode       string `yaml:"opc_security_mode"`
-	OPCAuthMode           string `yaml:"opc_auth_mode"`
-	OPCUsername           string `yaml:"opc_username"`
-	OPCPassword           string `yaml:"opc_password"`
-	OPCCertFile           string `yaml:"opc_cert_file"`
-	OPCKeyFile            string `yaml:"opc_key_file"`
-	OPCServerCertFile     string `yaml:"opc_server_cert_file"`
-	OPCInsecureSkipVerify bool   `yaml:"opc_insecure_skip_verify"`
-	OPCAutoSelectEndpoint bool   `yaml:"opc_auto_select_endpoint"`
-	OPCApplicationName    string `yaml:"opc_application_name"`
-	OPCApplicationURI     string `yaml:"opc_application_uri"`
-	OPCUseSubscriptions   *bool  `yaml:"opc_use_subscriptions"`
-	OPCPublishInterval    string `yaml:"opc_publish_interval"`
-	OPCSamplingInterval   string `yaml:"opc_sampling_interval"`
+	OPCEndpointURL        string   `yaml:"opc_endpoint_url"`
+	OPCSecurityPolicy     string   `yaml:"opc_security_policy"`
+	OPCSecurityMode       string   `yaml:"opc_security_mode"`
+	OPCAuthMode           string   `yaml:"opc_auth_mode"`
+	OPCUsername           string   `yaml:"opc_username"`
+	OPCPassword           string   `yaml:"opc_password"`
+	OPCCertFile           string   `yaml:"opc_cert_file"`
+	OPCKeyFile            string   `yaml:"opc_key_file"`
+	OPCServerCertFile     string   `yaml:"opc_server_cert_file"`
+	OPCInsecureSkipVerify bool     `yaml:"opc_insecure_skip_verify"`
+	OPCAutoSelectEndpoint bool     `yaml:"opc_auto_select_endpoint"`
+	OPCApplicationName    string   `yaml:"opc_application_name"`
+	OPCApplicationURI     string   `yaml:"opc_application_uri"`
+	OPCUseSubscriptions   *bool    `yaml:"opc_use_subscriptions"`
+	OPCPublishInterval    string   `yaml:"opc_publish_interval"`
+	OPCSamplingInterval   string   `yaml:"opc_sampling_interval"`
+	OPCAlarmsEnabled      bool     `yaml:"opc_alarms_enabled,omitempty"`
+	OPCAlarmNotifiers     []string `yaml:"opc_alarm_notifiers,omitempty"`
+	OPCAlarmMinSeverity   uint16   `yaml:"opc_alarm_min_severity,omitempty"`
+	OPCHistoryBackfill    bool     `yaml:"opc_history_backfill,omitempty"`
+	OPCPreserveTypes      bool     `yaml:"opc_preserve_types,omitempty"`
 
 	// S7
-	S7Rack int `yaml:"s7_rack"`
-	S7Slot int `yaml:"s7_slot"`
+	S7Rack           int    `yaml:"s7_rack"`
+	S7Slot           int    `yaml:"s7_slot"`
+	S7Family         string `yaml:"s7_family,omitempty"`
+	S7ConnectionType string `yaml:"s7_connection_type,omitempty"`
+	S7LocalTSAP      string `yaml:"s7_local_tsap,omitempty"`
+	S7RemoteTSAP     string `yaml:"s7_remote_tsap,omitempty"`
+	S7StatusInterval string `yaml:"s7_status_interval,omitempty"`
+
+	// MQTT source
+	MQTTBrokerURL        string `yaml:"mqtt_broker_url,omitempty"`
+	MQTTUsername         string `yaml:"mqtt_username,omitempty"`
+	MQTTPassword         string `yaml:"mqtt_password,omitempty"`
+	MQTTClientIDPrefix   string `yaml:"mqtt_client_id_prefix,omitempty"`
+	MQTTQoS              int    `yaml:"mqtt_qos,omitempty"`
+	MQTTCleanSession     bool   `yaml:"mqtt_clean_session,omitempty"`
+	MQTTStalenessTimeout string `yaml:"mqtt_staleness_timeout,omitempty"`
+	MQTTSourcePrefix     string `yaml:"mqtt_source_prefix,omitempty"`
+	MQTTTLSEnabled       bool   `yaml:"mqtt_tls_enabled,omitempty"`
+	MQTTTLSCAFile        string `yaml:"mqtt_tls_ca_file,omitempty"`
+	MQTTTLSCertFile      string `yaml:"mqtt_tls_cert_file,omitempty"`
+	MQTTTLSKeyFile       string `yaml:"mqtt_tls_key_file,omitempty"`
+}
+
+// ModbusBatchingConfig represents per-device Modbus batching overrides in YAML.
+type ModbusBatchingConfig struct {
+	MaxRegisters       uint16 `yaml:"max_registers,omitempty"`
+	MaxCoils           uint16 `yaml:"max_coils,omitempty"`
+	MaxGap             uint16 `yaml:"max_gap,omitempty"`
+	NoGap              bool   `yaml:"no_gap,omitempty"`
+	Alignment          uint16 `yaml:"alignment,omitempty"`
+	InterRequestDelay  string `yaml:"inter_request_delay,omitempty"`
+	OneBasedAddressing bool   `yaml:"one_based_addressing,omitempty"`
 }
 
 // TagConfig represents a tag configuration in YAML.
@@ -72,8 +121,10 @@ type TagConfig struct {
 	PollInterval  string            `yaml:"poll_interval,omitempty"`
 	DeadbandType  string            `yaml:"deadband_type,omitempty"`
 	DeadbandValue float64           `yaml:"deadband_value,omitempty"`
+	MaxSilence    string            `yaml:"max_silence,omitempty"`
 	Enabled       bool              `yaml:"enabled"`
 	AccessMode    string            `yaml:"access_mode,omitempty"`
+	Priority      uint8             `yaml:"priority,omitempty"`
 	Metadata      map[string]string `yaml:"metadata,omitempty"`
 
 	// Modbus-specific
@@ -83,11 +134,27 @@ type TagConfig struct {
 	RegisterCount int    `yaml:"register_count,omitempty"`
 	BitPosition   *int   `yaml:"bit_position,omitempty"`
 
+	StringEncoding string            `yaml:"string_encoding,omitempty"`
+	StringByteSwap bool              `yaml:"string_byte_swap,omitempty"`
+	StringTrim     string            `yaml:"string_trim,omitempty"`
+	ArrayLength    int               `yaml:"array_length,omitempty"`
+	BitFields      []domain.BitField `yaml:"bit_fields,omitempty"`
+
 	// OPC UA-specific
 	OPCNodeID string `yaml:"opc_node_id,omitempty"`
 
 	// S7-specific
-	S7Address string `yaml:"s7_address,omitempty"`
+	S7Address      string `yaml:"s7_address,omitempty"`
+	S7StringLength int    `yaml:"s7_string_length,omitempty"`
+
+	// Computed tags
+	Expression string `yaml:"expression,omitempty"`
+
+	// MQTT source-specific
+	MQTTTopicMatch    string `yaml:"mqtt_topic_match,omitempty"`
+	MQTTPayloadFormat string `yaml:"mqtt_payload_format,omitempty"`
+	MQTTValuePath     string `yaml:"mqtt_value_path,omitempty"`
+	MQTTTimestampPath string `yaml:"mqtt_timestamp_path,omitempty"`
 }
 
 // DevicesFile represents the top-level devices configuration file.
@@ -139,7 +206,7 @@ func validateConnectionConfig(dc DeviceConfig) error {
 	protocol := domain.Protocol(dc.Protocol)
 
 	switch protocol {
-	case domain.ProtocolModbusTCP, domain.ProtocolModbusRTU:
+	case domain.ProtocolModbusTCP:
 		if dc.Connection.Host == "" {
 			return fmt.Errorf("modbus device requires host")
 		}
@@ -149,6 +216,23 @@ func validateConnectionConfig(dc DeviceConfig) error {
 		if dc.Connection.SlaveID < 1 || dc.Connection.SlaveID > 247 {
 			return fmt.Errorf("modbus slave_id must be between 1 and 247, got %d", dc.Connection.SlaveID)
 		}
+		switch domain.ModbusFraming(dc.Connection.Framing) {
+		case "", domain.ModbusFramingMBAP, domain.ModbusFramingRTU:
+		default:
+			return fmt.Errorf("modbus framing must be %q or %q, got %q",
+				domain.ModbusFramingMBAP, domain.ModbusFramingRTU, dc.Connection.Framing)
+		}
+
+	case domain.ProtocolModbusRTU:
+		if dc.Connection.SerialPort == "" {
+			return fmt.Errorf("modbus RTU device requires serial_port")
+		}
+		if dc.Connection.SlaveID < 1 || dc.Connection.SlaveID > 247 {
+			return fmt.Errorf("modbus slave_id must be between 1 and 247, got %d", dc.Connection.SlaveID)
+		}
+		if err := validateSerialSettings(dc.Connection); err != nil {
+			return err
+		}
 
 	case domain.ProtocolOPCUA:
 		if dc.Connection.OPCEndpointURL == "" {
@@ -165,6 +249,24 @@ func validateConnectionConfig(dc DeviceConfig) error {
 		if dc.Connection.S7Slot < 0 {
 			return fmt.Errorf("S7 slot must be non-negative")
 		}
+		s7Conn, err := s7ConnectionConfig(dc.Connection)
+		if err != nil {
+			return err
+		}
+		if _, err := s7Conn.S7ConnectionSettings(); err != nil {
+			return err
+		}
+
+	case domain.ProtocolMQTT:
+		if dc.Connection.MQTTBrokerURL == "" {
+			return fmt.Errorf("MQTT device requires mqtt_broker_url")
+		}
+		if dc.Connection.MQTTSourcePrefix == "" {
+			return fmt.Errorf("MQTT device requires mqtt_source_prefix")
+		}
+		if dc.Connection.MQTTQoS < 0 || dc.Connection.MQTTQoS > 2 {
+			return fmt.Errorf("mqtt_qos must be 0, 1 or 2, got %d", dc.Connection.MQTTQoS)
+		}
 
 	default:
 		// Unknown protocol - let domain validation handle it
@@ -173,6 +275,26 @@ func validateConnectionConfig(dc DeviceConfig) error {
 	return nil
 }
 
+// validateSerialSettings validates optional serial line parameters.
+// Zero values are allowed and mean "use the RTU default" (19200 8E1).
+func validateSerialSettings(cc ConnectionConfig) error {
+	if cc.BaudRate < 0 {
+		return fmt.Errorf("modbus baud_rate must not be negative, got %d", cc.BaudRate)
+	}
+	if cc.DataBits != 0 && (cc.DataBits < 5 || cc.DataBits > 8) {
+		return fmt.Errorf("modbus data_bits must be between 5 and 8, got %d", cc.DataBits)
+	}
+	switch cc.Parity {
+	case "", "N", "E", "O":
+	default:
+		return fmt.Errorf("modbus parity must be N, E or O, got %q", cc.Parity)
+	}
+	if cc.StopBits != 0 && cc.StopBits != 1 && cc.StopBits != 2 {
+		return fmt.Errorf("modbus stop_bits must be 1 or 2, got %d", cc.StopBits)
+	}
+	return nil
+}
+
 // convertDeviceConfig converts a DeviceConfig to a domain.Device.
 func convertDeviceConfig(dc DeviceConfig) (*domain.Device, error) {
 	// Parse timeout duration
@@ -195,6 +317,51 @@ func convertDeviceConfig(dc DeviceConfig) (*domain.Device, error) {
 		}
 	}
 
+	// Parse RTU bus turnaround delay
+	var turnaroundDelay time.Duration
+	if dc.Connection.TurnaroundDelay != "" {
+		var err error
+		turnaroundDelay, err = time.ParseDuration(dc.Connection.TurnaroundDelay)
+		if err != nil {
+			return nil, fmt.Errorf("invalid turnaround_delay: %w", err)
+		}
+	}
+
+	// Parse S7 connection addressing
+	s7Conn, err := s7ConnectionConfig(dc.Connection)
+	if err != nil {
+		return nil, err
+	}
+
+	// Parse S7 status readout interval
+	var s7StatusInterval time.Duration
+	if dc.Connection.S7StatusInterval != "" {
+		s7StatusInterval, err = time.ParseDuration(dc.Connection.S7StatusInterval)
+		if err != nil {
+			return nil, fmt.Errorf("invalid s7_status_interval: %w", err)
+		}
+	}
+
+	// Parse Modbus batching overrides
+	var modbusBatching *domain.ModbusBatching
+	if mb := dc.Connection.ModbusBatching; mb != nil {
+		modbusBatching = &domain.ModbusBatching{
+			MaxRegisters:       mb.MaxRegisters,
+			MaxCoils:           mb.MaxCoils,
+			MaxGap:             mb.MaxGap,
+			NoGap:              mb.NoGap,
+			Alignment:          mb.Alignment,
+			OneBasedAddressing: mb.OneBasedAddressing,
+		}
+		if mb.InterRequestDelay != "" {
+			var err error
+			modbusBatching.InterRequestDelay, err = time.ParseDuration(mb.InterRequestDelay)
+			if err != nil {
+				return nil, fmt.Errorf("invalid inter_request_delay: %w", err)
+			}
+		}
+	}
+
 	// Parse OPC UA subscription intervals
 	var opcPublishInterval time.Duration
 	if dc.Connection.OPCPublishInterval != "" {
@@ -213,6 +380,16 @@ func convertDeviceConfig(dc DeviceConfig) (*domain.Device, error) {
 		}
 	}
 
+	// Parse MQTT source staleness timeout
+	var mqttStalenessTimeout time.Duration
+	if dc.Connection.MQTTStalenessTimeout != "" {
+		var err error
+		mqttStalenessTimeout, err = time.ParseDuration(dc.Connection.MQTTStalenessTimeout)
+		if err != nil {
+			return nil, fmt.Errorf("invalid mqtt_staleness_timeout: %w", err)
+		}
+	}
+
 	// Convert tags
 	tags := make([]domain.Tag, 0, len(dc.Tags))
 	for _, tc := range dc.Tags {
@@ -252,7 +429,18 @@ func convertDeviceConfig(dc DeviceConfig) (*domain.Device, error) {
 			RetryDelay: retryDelay,
 
 			// Modbus
-			SlaveID: uint8(dc.Connection.SlaveID),
+			SlaveID:         uint8(dc.Connection.SlaveID),
+			Framing:         domain.ModbusFraming(dc.Connection.Framing),
+			ShareConnection: dc.Connection.ShareConnection,
+			ModbusProfile:   dc.Connection.ModbusProfile,
+			ModbusBatching:  modbusBatching,
+			SerialPort:      dc.Connection.SerialPort,
+			BaudRate:        dc.Connection.BaudRate,
+			DataBits:        dc.Connection.DataBits,
+			Parity:          dc.Connection.Parity,
+			StopBits:        dc.Connection.StopBits,
+
+			TurnaroundDelay: turnaroundDelay,
 
 			// OPC UA
 			OPCEndpointURL:        dc.Connection.OPCEndpointURL,
@@ -271,10 +459,34 @@ func convertDeviceConfig(dc DeviceConfig) (*domain.Device, error) {
 			OPCUseSubscriptions:   opcUseSubscriptions(dc),
 			OPCPublishInterval:    opcPublishInterval,
 			OPCSamplingInterval:   opcSamplingInterval,
+			OPCAlarmsEnabled:      dc.Connection.OPCAlarmsEnabled,
+			OPCAlarmNotifiers:     dc.Connection.OPCAlarmNotifiers,
+			OPCAlarmMinSeverity:   dc.Connection.OPCAlarmMinSeverity,
+			OPCHistoryBackfill:    dc.Connection.OPCHistoryBackfill,
+			OPCPreserveTypes:      dc.Connection.OPCPreserveTypes,
 
 			// S7
-			S7Rack: dc.Connection.S7Rack,
-			S7Slot: dc.Connection.S7Slot,
+			S7Rack:           s7Conn.S7Rack,
+			S7Slot:           s7Conn.S7Slot,
+			S7Family:         s7Conn.S7Family,
+			S7ConnectionType: s7Conn.S7ConnectionType,
+			S7LocalTSAP:      s7Conn.S7LocalTSAP,
+			S7RemoteTSAP:     s7Conn.S7RemoteTSAP,
+			S7StatusInterval: s7StatusInterval,
+
+			// MQTT source
+			MQTTBrokerURL:        dc.Connection.MQTTBrokerURL,
+			MQTTUsername:         dc.Connection.MQTTUsername,
+			MQTTPassword:         dc.Connection.MQTTPassword,
+			MQTTClientIDPrefix:   dc.Connection.MQTTClientIDPrefix,
+			MQTTQoS:              byte(dc.Connection.MQTTQoS),
+			MQTTCleanSession:     dc.Connection.MQTTCleanSession,
+			MQTTStalenessTimeout: mqttStalenessTimeout,
+			MQTTSourcePrefix:     dc.Connection.MQTTSourcePrefix,
+			MQTTTLSEnabled:       dc.Connection.MQTTTLSEnabled,
+			MQTTTLSCAFile:        dc.Connection.MQTTTLSCAFile,
+			MQTTTLSCertFile:      dc.Connection.MQTTTLSCertFile,
+			MQTTTLSKeyFile:       dc.Connection.MQTTTLSKeyFile,
 		},
 		CreatedAt: time.Now(),
 		UpdatedAt: time.Now(),
@@ -300,6 +512,15 @@ func convertTagConfig(tc TagConfig) (*domain.Tag, error) {
 		pollInterval = &pi
 	}
 
+	var maxSilence *time.Duration
+	if tc.MaxSilence != "" {
+		ms, err := time.ParseDuration(tc.MaxSilence)
+		if err != nil {
+			return nil, fmt.Errorf("invalid max silence: %w", err)
+		}
+		maxSilence = &ms
+	}
+
 	// Convert bit position
 	var bitPosition *uint8
 	if tc.BitPosition != nil {
@@ -318,8 +539,9 @@ func convertTagConfig(tc TagConfig) (*domain.Tag, error) {
 		byteOrder = domain.ByteOrderBigEndian
 	}
 
+	// Arrays default to the element count times the element size (set by validation)
 	registerCount := uint16(tc.RegisterCount)
-	if registerCount == 0 {
+	if registerCount == 0 && tc.ArrayLength == 0 {
 		registerCount = 1
 	}
 
@@ -341,8 +563,10 @@ func convertTagConfig(tc TagConfig) (*domain.Tag, error) {
 		PollInterval:  pollInterval,
 		DeadbandType:  domain.DeadbandType(tc.DeadbandType),
 		DeadbandValue: tc.DeadbandValue,
+		MaxSilence:    maxSilence,
 		Enabled:       tc.Enabled,
 		AccessMode:    accessMode,
+		Priority:      tc.Priority,
 		Metadata:      tc.Metadata,
 
 		// Modbus-specific
@@ -352,11 +576,27 @@ func convertTagConfig(tc TagConfig) (*domain.Tag, error) {
 		RegisterCount: registerCount,
 		BitPosition:   bitPosition,
 
+		StringEncoding: domain.StringEncoding(tc.StringEncoding),
+		StringByteSwap: tc.StringByteSwap,
+		StringTrim:     domain.StringTrim(tc.StringTrim),
+		ArrayLength:    uint16(tc.ArrayLength),
+		BitFields:      tc.BitFields,
+
 		// OPC UA-specific
 		OPCNodeID: tc.OPCNodeID,
 
 		// S7-specific
-		S7Address: tc.S7Address,
+		S7Address:      tc.S7Address,
+		S7StringLength: uint16(tc.S7StringLength),
+
+		// Computed tags
+		Expression: tc.Expression,
+
+		// MQTT source-specific
+		MQTTTopicMatch:    tc.MQTTTopicMatch,
+		MQTTPayloadFormat: domain.MQTTPayloadFormat(tc.MQTTPayloadFormat),
+		MQTTValuePath:     tc.MQTTValuePath,
+		MQTTTimestampPath: tc.MQTTTimestampPath,
 	}
 
 	return tag, nil
@@ -387,6 +627,16 @@ func SaveDevices(path string, devices []*domain.Device) error {
 	return nil
 }
 
+// TagsToConfig converts tags to their YAML form, e.g. to print generated tags
+// for a devices file.
+func TagsToConfig(tags []domain.Tag) []TagConfig {
+	configs := make([]TagConfig, 0, len(tags))
+	for i := range tags {
+		configs = append(configs, convertToTagConfig(&tags[i]))
+	}
+	return configs
+}
+
 // convertToDeviceConfig converts a domain.Device to a DeviceConfig.
 func convertToDeviceConfig(device *domain.Device) DeviceConfig {
 	tags := make([]TagConfig, 0, len(device.Tags))
@@ -410,6 +660,21 @@ func convertToDeviceConfig(device *domain.Device) DeviceConfig {
 			RetryCount: device.Connection.RetryCount,
 			RetryDelay: device.Connection.RetryDelay.String(),
 
+			// Modbus TCP
+			Framing:         string(device.Connection.Framing),
+			ShareConnection: device.Connection.ShareConnection,
+			ModbusProfile:   device.Connection.ModbusProfile,
+			ModbusBatching:  modbusBatchingToConfig(device.Connection.ModbusBatching),
+
+			// Modbus RTU
+			SerialPort: device.Connection.SerialPort,
+			BaudRate:   device.Connection.BaudRate,
+			DataBits:   device.Connection.DataBits,
+			Parity:     device.Connection.Parity,
+			StopBits:   device.Connection.StopBits,
+
+			TurnaroundDelay: durationToString(device.Connection.TurnaroundDelay),
+
 			// OPC UA
 			OPCEndpointURL:        device.Connection.OPCEndpointURL,
 			OPCSecurityPolicy:     device.Connection.OPCSecurityPolicy,
@@ -427,10 +692,34 @@ func convertToDeviceConfig(device *domain.Device) DeviceConfig {
 			OPCUseSubscriptions:   boolPtr(device.Connection.OPCUseSubscriptions),
 			OPCPublishInterval:    durationToString(device.Connection.OPCPublishInterval),
 			OPCSamplingInterval:   durationToString(device.Connection.OPCSamplingInterval),
+			OPCAlarmsEnabled:      device.Connection.OPCAlarmsEnabled,
+			OPCAlarmNotifiers:     device.Connection.OPCAlarmNotifiers,
+			OPCAlarmMinSeverity:   device.Connection.OPCAlarmMinSeverity,
+			OPCHistoryBackfill:    device.Connection.OPCHistoryBackfill,
+			OPCPreserveTypes:      device.Connection.OPCPreserveTypes,
 
 			// S7
-			S7Rack: device.Connection.S7Rack,
-			S7Slot: device.Connection.S7Slot,
+			S7Rack:           device.Connection.S7Rack,
+			S7Slot:           device.Connection.S7Slot,
+			S7Family:         device.Connection.S7Family,
+			S7ConnectionType: string(device.Connection.S7ConnectionType),
+			S7LocalTSAP:      s7TSAPToString(device.Connection.S7LocalTSAP),
+			S7RemoteTSAP:     s7TSAPToString(device.Connection.S7RemoteTSAP),
+			S7StatusInterval: durationToString(device.Connection.S7StatusInterval),
+
+			// MQTT source
+			MQTTBrokerURL:        device.Connection.MQTTBrokerURL,
+			MQTTUsername:         device.Connection.MQTTUsername,
+			MQTTPassword:         device.Connection.MQTTPassword,
+			MQTTClientIDPrefix:   device.Connection.MQTTClientIDPrefix,
+			MQTTQoS:              int(device.Connection.MQTTQoS),
+			MQTTCleanSession:     device.Connection.MQTTCleanSession,
+			MQTTStalenessTimeout: durationToString(device.Connection.MQTTStalenessTimeout),
+			MQTTSourcePrefix:     device.Connection.MQTTSourcePrefix,
+			MQTTTLSEnabled:       device.Connection.MQTTTLSEnabled,
+			MQTTTLSCAFile:        device.Connection.MQTTTLSCAFile,
+			MQTTTLSCertFile:      device.Connection.MQTTTLSCertFile,
+			MQTTTLSKeyFile:       device.Connection.MQTTTLSKeyFile,
 		},
 		Tags:     tags,
 		Metadata: device.Metadata,
@@ -444,6 +733,11 @@ func convertToTagConfig(tag *domain.Tag) TagConfig {
 		pollInterval = tag.PollInterval.String()
 	}
 
+	var maxSilence string
+	if tag.MaxSilence != nil {
+		maxSilence = tag.MaxSilence.String()
+	}
+
 	var bitPosition *int
 	if tag.BitPosition != nil {
 		bp := int(*tag.BitPosition)
@@ -460,6 +754,13 @@ func convertToTagConfig(tag *domain.Tag) TagConfig {
 		ByteOrder:     string(tag.ByteOrder),
 		RegisterCount: int(tag.RegisterCount),
 		BitPosition:   bitPosition,
+
+		StringEncoding: string(tag.StringEncoding),
+		StringByteSwap: tag.StringByteSwap,
+		StringTrim:     string(tag.StringTrim),
+		ArrayLength:    int(tag.ArrayLength),
+		BitFields:      tag.BitFields,
+
 		ScaleFactor:   tag.ScaleFactor,
 		Offset:        tag.Offset,
 		Unit:          tag.Unit,
@@ -467,19 +768,77 @@ func convertToTagConfig(tag *domain.Tag) TagConfig {
 		PollInterval:  pollInterval,
 		DeadbandType:  string(tag.DeadbandType),
 		DeadbandValue: tag.DeadbandValue,
+		MaxSilence:    maxSilence,
 		Enabled:       tag.Enabled,
 		AccessMode:    string(tag.AccessMode),
+		Priority:      tag.Priority,
 		Metadata:      tag.Metadata,
 
 		// OPC UA
 		OPCNodeID: tag.OPCNodeID,
 
 		// S7
-		S7Address: tag.S7Address,
+		S7Address:      tag.S7Address,
+		S7StringLength: int(tag.S7StringLength),
+
+		// Computed tags
+		Expression: tag.Expression,
+
+		// MQTT source
+		MQTTTopicMatch:    tag.MQTTTopicMatch,
+		MQTTPayloadFormat: string(tag.MQTTPayloadFormat),
+		MQTTValuePath:     tag.MQTTValuePath,
+		MQTTTimestampPath: tag.MQTTTimestampPath,
 	}
 }
 
 // durationToString converts a duration to string, returning empty string for zero.
+// modbusBatchingToConfig converts domain batching overrides to their YAML form.
+func modbusBatchingToConfig(mb *domain.ModbusBatching) *ModbusBatchingConfig {
+	if mb == nil {
+		return nil
+	}
+	return &ModbusBatchingConfig{
+		MaxRegisters:       mb.MaxRegisters,
+		MaxCoils:           mb.MaxCoils,
+		MaxGap:             mb.MaxGap,
+		NoGap:              mb.NoGap,
+		Alignment:          mb.Alignment,
+		InterRequestDelay:  durationToString(mb.InterRequestDelay),
+		OneBasedAddressing: mb.OneBasedAddressing,
+	}
+}
+
+// s7ConnectionConfig parses the S7 connection fields into their domain form.
+func s7ConnectionConfig(cc ConnectionConfig) (*domain.ConnectionConfig, error) {
+	conn := &domain.ConnectionConfig{
+		S7Rack:           cc.S7Rack,
+		S7Slot:           cc.S7Slot,
+		S7Family:         cc.S7Family,
+		S7ConnectionType: domain.S7ConnectionType(cc.S7ConnectionType),
+	}
+	var err error
+	if cc.S7LocalTSAP != "" {
+		if conn.S7LocalTSAP, err = domain.ParseS7TSAP(cc.S7LocalTSAP); err != nil {
+			return nil, fmt.Errorf("invalid s7_local_tsap: %w", err)
+		}
+	}
+	if cc.S7RemoteTSAP != "" {
+		if conn.S7RemoteTSAP, err = domain.ParseS7TSAP(cc.S7RemoteTSAP); err != nil {
+			return nil, fmt.Errorf("invalid s7_remote_tsap: %w", err)
+		}
+	}
+	return conn, nil
+}
+
+// s7TSAPToString formats a TSAP for YAML, empty when unset.
+func s7TSAPToString(tsap uint16) string {
+	if tsap == 0 {
+		return ""
+	}
+	return domain.FormatS7TSAP(tsap)
+}
+
 func durationToString(d time.Duration) string {
 	if d == 0 {
 		return ""
@@ -0,0 +1,397 @@
+// Package modbus provides multi-tag writes and combined read/write for Modbus devices.
+package modbus
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/goburrow/modbus"
+	"github.com/nexus-edge/protocol-gateway/internal/domain"
+	"github.com/sony/gobreaker"
+)
+
+// MaxReadWriteRegisters is the maximum number of registers written by one
+// Read/Write Multiple Registers (FC23) request.
+const MaxReadWriteRegisters = 121
+
+// TagWrite represents a single write operation.
+type TagWrite = domain.TagWrite
+
+// writeFrame is one request of a multi-tag write: consecutive holding
+// registers (FC16, or FC06 for one register), consecutive coils (FC15, or
+// FC05 for one coil), or a bit write sent on its own.
+type writeFrame struct {
+	regType   domain.RegisterType
+	address   uint16
+	registers []byte    // Register values, 2 bytes each, already byte-ordered
+	coils     []bool    // Coil states
+	single    *TagWrite // Bit write, sent through writeRegister
+	items     []int     // Indexes of the writes carried by this frame
+}
+
+// size returns the number of registers or coils in the frame.
+func (f *writeFrame) size() int {
+	if f.regType == domain.RegisterTypeCoil {
+		return len(f.coils)
+	}
+	return len(f.registers) / 2
+}
+
+// planWrites converts writes to request frames: register frames first, then
+// coil frames, then bit writes. Writes at consecutive addresses are merged up
+// to maxRegisters/maxCoils per frame; writes that overlap a frame start a new one (in
+// address order, then batch order). Writes that cannot be encoded get their
+// error in errs and are left out.
+func planWrites(writes []TagWrite, errs []error, maxRegisters, maxCoils int) []*writeFrame {
+	var registers, coils, singles []*writeFrame
+
+	for i, w := range writes {
+		tag := w.Tag
+		frame := &writeFrame{regType: tag.RegisterType, address: tag.Address, items: []int{i}}
+
+		switch {
+		case !tag.IsWritable():
+			errs[i] = fmt.Errorf("%w: tag %s (register type: %s)", domain.ErrTagNotWritable, tag.ID, tag.RegisterType)
+			continue
+
+		case tag.RegisterType == domain.RegisterTypeCoil:
+			states, err := coilStates(tag, w.Value)
+			if err != nil {
+				errs[i] = err
+				continue
+			}
+			frame.coils = states
+			coils = append(coils, frame)
+
+		case isBitWrite(tag, w.Value):
+			frame.single = &writes[i]
+			singles = append(singles, frame)
+
+		default:
+			data, err := valueToBytes(w.Value, tag)
+			if err != nil {
+				errs[i] = err
+				continue
+			}
+			frame.registers = data
+			registers = append(registers, frame)
+		}
+	}
+
+	frames := coalesceFrames(registers, maxRegisters)
+	frames = append(frames, coalesceFrames(coils, maxCoils)...)
+	return append(frames, singles...)
+}
+
+// coalesceFrames merges frames of one register type at consecutive addresses
+// into frames of at most limit registers or coils.
+func coalesceFrames(pending []*writeFrame, limit int) []*writeFrame {
+	sort.SliceStable(pending, func(a, b int) bool { return pending[a].address < pending[b].address })
+
+	var frames []*writeFrame
+	var current *writeFrame
+	for _, f := range pending {
+		if current != nil &&
+			int(f.address) == int(current.address)+current.size() &&
+			current.size()+f.size() <= limit {
+			current.registers = append(current.registers, f.registers...)
+			current.coils = append(current.coils, f.coils...)
+			current.items = append(current.items, f.items...)
+			continue
+		}
+		current = f
+		frames = append(frames, current)
+	}
+	return frames
+}
+
+// isBitWrite reports whether a write changes single bits of a holding
+// register rather than whole registers.
+func isBitWrite(tag *domain.Tag, value interface{}) bool {
+	if tag.BitPosition != nil {
+		return true
+	}
+	_, fields := value.(map[string]interface{})
+	return len(tag.BitFields) > 0 && fields
+}
+
+// coilStates converts a write value to the states of a coil or coil array.
+func coilStates(tag *domain.Tag, value interface{}) ([]bool, error) {
+	if tag.ArrayLength > 0 {
+		return boolArray(value, tag.ArrayLength)
+	}
+	on, ok := toBool(value)
+	if !ok {
+		return nil, fmt.Errorf("%w: cannot convert %T to bool for coil", domain.ErrInvalidWriteValue, value)
+	}
+	return []bool{on}, nil
+}
+
+// packCoils packs coil states LSB first, 8 per byte.
+func packCoils(states []bool) []byte {
+	packed := make([]byte, (len(states)+7)/8)
+	for i, on := range states {
+		if on {
+			packed[i/8] |= 1 << uint(i%8)
+		}
+	}
+	return packed
+}
+
+// writeFrame sends one frame of a multi-tag write.
+func (c *Client) writeFrame(f *writeFrame) error {
+	if f.single != nil {
+		return c.writeRegister(f.single.Tag, f.single.Value)
+	}
+
+	return c.execLocked(func(client modbus.Client) error {
+		var err error
+		switch {
+		case f.regType == domain.RegisterTypeCoil && len(f.coils) == 1:
+			var coilValue uint16
+			if f.coils[0] {
+				coilValue = 0xFF00
+			}
+			_, err = client.WriteSingleCoil(f.address, coilValue)
+		case f.regType == domain.RegisterTypeCoil:
+			_, err = client.WriteMultipleCoils(f.address, uint16(len(f.coils)), packCoils(f.coils))
+		case len(f.registers) == 2:
+			_, err = client.WriteSingleRegister(f.address, binary.BigEndian.Uint16(f.registers))
+		default:
+			_, err = client.WriteMultipleRegisters(f.address, uint16(len(f.registers)/2), f.registers)
+		}
+		if err != nil {
+			return fmt.Errorf("%w: %v", domain.ErrWriteFailed, err)
+		}
+		return nil
+	})
+}
+
+// WriteTags writes several tags, merging holding registers and coils at
+// consecutive addresses into Write Multiple Registers (FC16) and Write
+// Multiple Coils (FC15) requests. Bit writes are sent one by one after them.
+// Returns one error per write; a failed request fails every write it carried.
+func (c *Client) WriteTags(ctx context.Context, writes []TagWrite) []error {
+	startTime := time.Now()
+	defer func() {
+		c.stats.TotalWriteTime.Add(time.Since(startTime).Nanoseconds())
+	}()
+
+	c.mu.Lock()
+	c.lastUsed = time.Now()
+	c.mu.Unlock()
+
+	errs := make([]error, len(writes))
+	if !c.connected.Load() {
+		return writeErrors(len(writes), domain.ErrConnectionClosed)
+	}
+
+	maxRegisters, maxCoils := c.writeLimits()
+	frames := planWrites(writes, errs, maxRegisters, maxCoils)
+	for _, f := range frames {
+		err := ctx.Err()
+		if err == nil {
+			err = c.retryWrite(ctx, func() error { return c.writeFrame(f) })
+		}
+		if err == nil {
+			c.stats.WriteCount.Add(1)
+		}
+		for _, i := range f.items {
+			errs[i] = err
+		}
+	}
+
+	c.logger.Debug().
+		Int("writes", len(writes)).
+		Int("requests", len(frames)).
+		Msg("Wrote Modbus tags")
+
+	return errs
+}
+
+// ReadWriteTags writes tags and then reads tags. When the writes form one
+// holding register frame and the reads are holding registers within one read
+// range, both go in a single Read/Write Multiple Registers (FC23) request,
+// which the device executes write first. Otherwise, or once the device has
+// answered FC23 with "illegal function", it falls back to WriteTags followed
+// by ReadTags. Returns the read data points and one error per write.
+func (c *Client) ReadWriteTags(ctx context.Context, writes []TagWrite, reads []*domain.Tag) ([]*domain.DataPoint, []error) {
+	if c.capabilities.readWrite.Load() != capabilityUnsupported {
+		if points, errs, ok := c.readWriteCombined(ctx, writes, reads); ok {
+			return points, errs
+		}
+	}
+
+	errs := c.WriteTags(ctx, writes)
+	points, err := c.ReadTags(ctx, reads)
+	if err != nil {
+		points = points[:0]
+		for _, tag := range reads {
+			points = append(points, c.createErrorDataPoint(tag, err))
+		}
+	}
+	return points, errs
+}
+
+// readWriteCombined performs ReadWriteTags with FC23. It reports false
+// without side effects on the device when the request does not fit FC23 or
+// the device does not support it.
+func (c *Client) readWriteCombined(ctx context.Context, writes []TagWrite, reads []*domain.Tag) ([]*domain.DataPoint, []error, bool) {
+	if len(writes) == 0 || len(reads) == 0 || !c.connected.Load() {
+		return nil, nil, false
+	}
+
+	rng := RegisterRange{StartAddress: reads[0].Address, Tags: reads}
+	for _, tag := range reads {
+		if tag.RegisterType != domain.RegisterTypeHoldingRegister {
+			return nil, nil, false
+		}
+		if tag.Address < rng.StartAddress {
+			rng.StartAddress = tag.Address
+		}
+		if end := tag.Address + tag.RegisterCount - 1; end > rng.EndAddress {
+			rng.EndAddress = end
+		}
+	}
+	readCount := int(rng.EndAddress) - int(rng.StartAddress) + 1
+	if readCount > MaxHoldingRegisters || c.config.Batching.MaxRegisters > 0 && readCount > int(c.config.Batching.MaxRegisters) {
+		return nil, nil, false
+	}
+
+	errs := make([]error, len(writes))
+	maxRegisters, maxCoils := c.writeLimits()
+	frames := planWrites(writes, errs, maxRegisters, maxCoils)
+	if len(frames) != 1 || frames[0].regType != domain.RegisterTypeHoldingRegister ||
+		frames[0].single != nil || frames[0].size() > MaxReadWriteRegisters {
+		return nil, nil, false
+	}
+	for _, err := range errs {
+		if err != nil {
+			return nil, nil, false
+		}
+	}
+	f := frames[0]
+
+	c.mu.Lock()
+	c.lastUsed = time.Now()
+	c.mu.Unlock()
+
+	var raw []byte
+	err := c.retryWrite(ctx, func() error {
+		return c.execLocked(func(client modbus.Client) error {
+			var err error
+			raw, err = client.ReadWriteMultipleRegisters(rng.StartAddress, uint16(readCount), f.address, uint16(f.size()), f.registers)
+			return err
+		})
+	})
+
+	var mbErr *modbus.ModbusError
+	if errors.As(err, &mbErr) && mbErr.ExceptionCode == modbus.ExceptionCodeIllegalFunction {
+		c.capabilities.readWrite.Store(capabilityUnsupported)
+		c.logger.Info().Msg("Device does not support Read/Write Multiple Registers (FC23), using separate write and read")
+		return nil, nil, false
+	}
+
+	points := make([]*domain.DataPoint, 0, len(reads))
+	if err != nil {
+		for i := range errs {
+			errs[i] = fmt.Errorf("%w: %v", domain.ErrWriteFailed, err)
+		}
+		for _, tag := range reads {
+			points = append(points, c.createErrorDataPoint(tag, c.translateModbusError(err)))
+		}
+		return points, errs, true
+	}
+
+	c.capabilities.readWrite.Store(capabilitySupported)
+	c.stats.WriteCount.Add(1)
+	c.stats.ReadCount.Add(1)
+	points = c.extractRangePoints(rng, raw)
+	return c.appendBitFieldPoints(reads, points), errs, true
+}
+
+// writeErrors returns n copies of err, for failures that affect a whole batch.
+func writeErrors(n int, err error) []error {
+	errs := make([]error, n)
+	for i := range errs {
+		errs[i] = err
+	}
+	return errs
+}
+
+// WriteTags writes several tags on the device, merging consecutive registers
+// and coils into multi-register and multi-coil requests.
+// Uses per-device circuit breaker for fault isolation.
+func (p *ConnectionPool) WriteTags(ctx context.Context, device *domain.Device, writes []domain.TagWrite) []error {
+	client, err := p.GetClient(ctx, device)
+	if err != nil {
+		return writeErrors(len(writes), err)
+	}
+
+	p.mu.RLock()
+	pc := p.clients[device.ID]
+	p.mu.RUnlock()
+
+	if pc == nil {
+		return writeErrors(len(writes), domain.ErrDeviceNotFound)
+	}
+
+	// The breaker sees the first failed write; callers get every result
+	result, err := pc.breaker.Execute(func() (interface{}, error) {
+		errs := client.WriteTags(ctx, writes)
+		return errs, firstError(errs)
+	})
+	if errs, ok := result.([]error); ok {
+		return errs
+	}
+	if err == gobreaker.ErrOpenState {
+		err = domain.ErrCircuitBreakerOpen
+	}
+	return writeErrors(len(writes), err)
+}
+
+// ReadWriteTags writes tags and then reads tags on the device, in a single
+// FC23 request where possible (see Client.ReadWriteTags).
+// Uses per-device circuit breaker for fault isolation.
+func (p *ConnectionPool) ReadWriteTags(ctx context.Context, device *domain.Device, writes []domain.TagWrite, reads []*domain.Tag) ([]*domain.DataPoint, []error, error) {
+	client, err := p.GetClient(ctx, device)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	p.mu.RLock()
+	pc := p.clients[device.ID]
+	p.mu.RUnlock()
+
+	if pc == nil {
+		return nil, nil, domain.ErrDeviceNotFound
+	}
+
+	var points []*domain.DataPoint
+	result, err := pc.breaker.Execute(func() (interface{}, error) {
+		var errs []error
+		points, errs = client.ReadWriteTags(ctx, writes, reads)
+		return errs, firstError(errs)
+	})
+	if errs, ok := result.([]error); ok {
+		return points, errs, nil
+	}
+	if err == gobreaker.ErrOpenState {
+		return nil, nil, domain.ErrCircuitBreakerOpen
+	}
+	return nil, nil, err
+}
+
+// firstError returns the first non-nil error, or nil.
+func firstError(errs []error) error {
+	for _, err := range errs {
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,114 @@
+package modbus
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/nexus-edge/protocol-gateway/internal/domain"
+	"github.com/rs/zerolog"
+)
+
+func writeTag(t *testing.T, id string, regType domain.RegisterType, dataType domain.DataType, address uint16) *domain.Tag {
+	t.Helper()
+	tag := &domain.Tag{
+		ID: id, Name: id, TopicSuffix: id, Address: address,
+		RegisterType: regType, DataType: dataType, AccessMode: domain.AccessModeReadWrite,
+	}
+	if err := tag.ValidateForProtocol(domain.ProtocolModbusTCP); err != nil {
+		t.Fatalf("validate %s: %v", id, err)
+	}
+	return tag
+}
+
+func TestWriteTags_CoalescesFrames(t *testing.T) {
+	registers := &registerStandIn{registers: map[uint16]uint16{20: 0x0001}, maskWrite: true}
+	standIn := startTCPStandIn(t, &tcpStandIn{respond: registers.respond})
+	host, port := standIn.host()
+	device := gatewayDevice("batch", host, port, 1, "", false)
+
+	bit := uint8(3)
+	flag := writeTag(t, "flag", domain.RegisterTypeHoldingRegister, domain.DataTypeBool, 20)
+	flag.BitPosition = &bit
+	input := writeTag(t, "pv", domain.RegisterTypeInputRegister, domain.DataTypeUInt16, 0)
+	input.AccessMode = domain.AccessModeReadOnly
+
+	writes := []domain.TagWrite{
+		{Tag: writeTag(t, "sp2", domain.RegisterTypeHoldingRegister, domain.DataTypeFloat32, 2), Value: 1.5},
+		{Tag: writeTag(t, "sp0", domain.RegisterTypeHoldingRegister, domain.DataTypeUInt16, 0), Value: 7},
+		{Tag: writeTag(t, "run", domain.RegisterTypeCoil, domain.DataTypeBool, 6), Value: true},
+		{Tag: writeTag(t, "sp1", domain.RegisterTypeHoldingRegister, domain.DataTypeInt16, 1), Value: -1},
+		{Tag: flag, Value: true},
+		{Tag: writeTag(t, "mode", domain.RegisterTypeHoldingRegister, domain.DataTypeUInt16, 10), Value: 3},
+		{Tag: writeTag(t, "reset", domain.RegisterTypeCoil, domain.DataTypeBool, 5), Value: 1},
+		{Tag: input, Value: 1},
+		{Tag: writeTag(t, "bad", domain.RegisterTypeHoldingRegister, domain.DataTypeUInt16, 11), Value: "x"},
+	}
+
+	pool := NewConnectionPool(DefaultPoolConfig(), zerolog.Nop(), nil)
+	defer pool.Close()
+
+	errs := pool.WriteTags(context.Background(), device, writes)
+	for i, err := range errs[:7] {
+		if err != nil {
+			t.Errorf("write %s: %v", writes[i].Tag.ID, err)
+		}
+	}
+	if !errors.Is(errs[7], domain.ErrTagNotWritable) || !errors.Is(errs[8], domain.ErrInvalidWriteValue) {
+		t.Errorf("expected not-writable and invalid-value errors, got %v, %v", errs[7], errs[8])
+	}
+
+	registers.mu.Lock()
+	defer registers.mu.Unlock()
+	got := map[uint16]uint16{}
+	for _, a := range []uint16{0, 1, 2, 3, 10, 20} {
+		got[a] = registers.registers[a]
+	}
+	want := map[uint16]uint16{0: 7, 1: 0xFFFF, 2: 0x3FC0, 3: 0x0000, 10: 3, 20: 0x0009}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("registers %v, want %v", got, want)
+	}
+	if !registers.coils[5] || !registers.coils[6] {
+		t.Errorf("expected coils 5 and 6 on, got %v", registers.coils)
+	}
+	wantRequests := map[byte]int{0x10: 1, 0x06: 1, 0x0F: 1, 0x16: 1}
+	if !reflect.DeepEqual(registers.requests, wantRequests) {
+		t.Errorf("requests per function code %v, want %v", registers.requests, wantRequests)
+	}
+}
+
+func TestReadWriteTags_CombinedAndFallback(t *testing.T) {
+	setpoint := writeTag(t, "sp", domain.RegisterTypeHoldingRegister, domain.DataTypeUInt32, 0)
+	status := writeTag(t, "status", domain.RegisterTypeHoldingRegister, domain.DataTypeUInt16, 4)
+
+	for _, readWrite := range []bool{true, false} {
+		registers := &registerStandIn{registers: map[uint16]uint16{4: 42}, readWrite: readWrite}
+		standIn := startTCPStandIn(t, &tcpStandIn{respond: registers.respond})
+		host, port := standIn.host()
+		device := gatewayDevice("rw", host, port, 1, "", false)
+
+		pool := NewConnectionPool(DefaultPoolConfig(), zerolog.Nop(), nil)
+		for _, value := range []uint32{70000, 70001} {
+			points, errs, err := pool.ReadWriteTags(context.Background(), device,
+				[]domain.TagWrite{{Tag: setpoint, Value: value}}, []*domain.Tag{setpoint, status})
+			if err != nil || errs[0] != nil {
+				t.Fatalf("readWrite=%v: ReadWriteTags: %v %v", readWrite, err, errs)
+			}
+			if len(points) != 2 || points[0].Value != value || points[1].Value != uint16(42) {
+				t.Errorf("readWrite=%v: unexpected points %v", readWrite, points)
+			}
+		}
+		pool.Close()
+
+		registers.mu.Lock()
+		want := map[byte]int{0x17: 2}
+		if !readWrite {
+			want = map[byte]int{0x17: 1, 0x10: 2, 0x03: 2}
+		}
+		if !reflect.DeepEqual(registers.requests, want) {
+			t.Errorf("readWrite=%v: requests per function code %v, want %v", readWrite, registers.requests, want)
+		}
+		registers.mu.Unlock()
+	}
+}
@@ -0,0 +1,376 @@
+// Package modbus provides shared-link arbitration for Modbus devices: RS-485
+// multi-drop lines and Modbus gateways with many unit IDs behind one address.
+package modbus
+
+import (
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/goburrow/modbus"
+	"github.com/nexus-edge/protocol-gateway/internal/domain"
+	"github.com/nexus-edge/protocol-gateway/internal/metrics"
+	"github.com/rs/zerolog"
+)
+
+// sharedBus owns the single handle of one physical link and arbitrates access
+// between every slave configured on it. The link is either the serial port of a
+// multi-drop RS-485 line or one TCP connection to a serial-to-Ethernet
+// converter / Modbus gateway that fronts several unit IDs.
+//
+// Only one request may be on the link at a time. When several devices are
+// waiting, the bus is granted round-robin by slave ID, so a device with a long
+// poll cycle (many register ranges) cannot starve the others: their poll cycles
+// interleave request by request.
+type sharedBus struct {
+	key       string // Pool map key (framing + address)
+	port      string // Serial port path or host:port
+	transport transportHandler
+	logger    zerolog.Logger
+	metrics   *metrics.Registry
+
+	mu      sync.Mutex
+	busy    bool
+	waiters map[byte][]chan struct{} // queued requests per slave ID
+	ring    []byte                   // slave IDs with queued requests, in grant order
+	slaves  map[byte]int             // attached clients per slave ID
+	refs    int
+	closed  bool // Link closed after the last device detached
+
+	requests  atomic.Uint64
+	timeouts  atomic.Uint64
+	errors    atomic.Uint64
+	busyNanos atomic.Int64
+
+	// Utilization sampling state, guarded by mu
+	sampledAt   time.Time
+	sampledBusy int64
+	utilization float64
+}
+
+// BusStats contains statistics for a shared bus (serial line or gateway connection).
+type BusStats struct {
+	Port        string
+	Devices     int
+	QueueDepth  int
+	Requests    uint64
+	Timeouts    uint64
+	Errors      uint64
+	BusyTime    time.Duration
+	Utilization float64 // Fraction of wall time the line was busy during the last sample window
+}
+
+// newSharedBus creates a bus for the link described by the first attached
+// device's configuration.
+func newSharedBus(config ClientConfig, logger zerolog.Logger, metricsReg *metrics.Registry) *sharedBus {
+	return &sharedBus{
+		key:       busKey(config),
+		port:      config.Address,
+		transport: newLinkTransport(config),
+		logger:    logger.With().Str("bus", config.Address).Logger(),
+		metrics:   metricsReg,
+		waiters:   make(map[byte][]chan struct{}),
+		slaves:    make(map[byte]int),
+		sampledAt: time.Now(),
+	}
+}
+
+// busKey identifies the physical link a client uses. Devices with the same key
+// share one bus.
+func busKey(config ClientConfig) string {
+	if config.Protocol == domain.ProtocolModbusRTU {
+		return config.Address
+	}
+	framing := config.Framing
+	if framing == "" {
+		framing = domain.ModbusFramingMBAP
+	}
+	return string(framing) + "://" + config.Address
+}
+
+// newLinkTransport creates the transport that owns the shared link.
+func newLinkTransport(config ClientConfig) transportHandler {
+	switch {
+	case config.Protocol == domain.ProtocolModbusRTU:
+		return newRTUHandler(config)
+	case config.Framing == domain.ModbusFramingRTU:
+		return newRTUOverTCPHandler(config)
+	default:
+		return newMBAPLinkHandler(config)
+	}
+}
+
+// attach registers a client on the bus. Caller must hold the pool lock.
+func (b *sharedBus) attach(config ClientConfig) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.refs++
+	b.slaves[config.SlaveID]++
+
+	if serial, ok := b.transport.(*rtuHandler); ok {
+		if config.BaudRate != serial.BaudRate || config.DataBits != serial.DataBits ||
+			config.Parity != serial.Parity || config.StopBits != serial.StopBits {
+			b.logger.Warn().
+				Uint8("slave_id", config.SlaveID).
+				Int("baud_rate", serial.BaudRate).
+				Str("parity", serial.Parity).
+				Msg("Device serial settings differ from the shared bus; using the bus settings")
+		}
+		if config.TurnaroundDelay > serial.frameGap {
+			serial.mu.Lock()
+			serial.frameGap = config.TurnaroundDelay
+			serial.mu.Unlock()
+		}
+	}
+	if b.slaves[config.SlaveID] > 1 {
+		b.logger.Warn().
+			Uint8("slave_id", config.SlaveID).
+			Msg("Multiple devices share the same slave ID on one bus")
+	}
+}
+
+// detach unregisters a client. It returns true when the last client left;
+// the pool then closes the bus. Caller must hold the pool lock.
+func (b *sharedBus) detach(slaveID byte) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.refs--
+	if b.slaves[slaveID]--; b.slaves[slaveID] <= 0 {
+		delete(b.slaves, slaveID)
+	}
+	return b.refs <= 0
+}
+
+// close closes the link once the request on the wire has completed. Requests
+// of devices still holding a handler are refused afterwards, so the link is
+// not reopened. Must not be called with the pool lock held.
+func (b *sharedBus) close() {
+	b.acquire(0)
+	defer b.release()
+
+	b.mu.Lock()
+	b.closed = true
+	b.mu.Unlock()
+	if err := b.transport.Close(); err != nil {
+		b.logger.Warn().Err(err).Msg("Error closing shared link")
+	}
+}
+
+// isClosed reports whether the bus was closed.
+func (b *sharedBus) isClosed() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.closed
+}
+
+// acquire blocks until the bus is granted to the caller.
+func (b *sharedBus) acquire(slaveID byte) {
+	b.mu.Lock()
+	if !b.busy {
+		b.busy = true
+		b.mu.Unlock()
+		return
+	}
+
+	grant := make(chan struct{})
+	if len(b.waiters[slaveID]) == 0 {
+		b.ring = append(b.ring, slaveID)
+	}
+	b.waiters[slaveID] = append(b.waiters[slaveID], grant)
+	b.mu.Unlock()
+
+	<-grant
+}
+
+// release hands the bus to the next slave in round-robin order, or frees it.
+func (b *sharedBus) release() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if len(b.ring) == 0 {
+		b.busy = false
+		return
+	}
+
+	slaveID := b.ring[0]
+	b.ring = b.ring[1:]
+	queue := b.waiters[slaveID]
+	grant := queue[0]
+	if len(queue) > 1 {
+		b.waiters[slaveID] = queue[1:]
+		b.ring = append(b.ring, slaveID) // Back of the line for its next request
+	} else {
+		delete(b.waiters, slaveID)
+	}
+
+	close(grant) // Bus stays busy; ownership passes to the waiter
+}
+
+// connect opens the shared link if it is not already open.
+func (b *sharedBus) connect() error {
+	b.acquire(0)
+	defer b.release()
+	if b.isClosed() {
+		return domain.ErrConnectionClosed
+	}
+	return b.transport.Connect()
+}
+
+// send transmits one request frame for slaveID and waits for its response.
+func (b *sharedBus) send(slaveID byte, aduRequest []byte) ([]byte, error) {
+	b.acquire(slaveID)
+	defer b.release()
+	if b.isClosed() {
+		return nil, domain.ErrConnectionClosed
+	}
+
+	start := time.Now()
+	aduResponse, err := b.transport.Send(aduRequest)
+	b.busyNanos.Add(time.Since(start).Nanoseconds())
+	b.requests.Add(1)
+
+	timeout := err != nil && isTimeout(err)
+	if timeout {
+		b.timeouts.Add(1)
+	} else if err != nil {
+		b.errors.Add(1)
+	}
+	if b.metrics != nil {
+		b.metrics.RecordModbusBusRequest(b.port, err == nil, timeout)
+	}
+
+	return aduResponse, err
+}
+
+// sampleUtilization closes the current sampling window and returns the
+// fraction of it during which a request was on the wire.
+func (b *sharedBus) sampleUtilization() float64 {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	now := time.Now()
+	busy := b.busyNanos.Load()
+	if elapsed := now.Sub(b.sampledAt); elapsed > 0 {
+		b.utilization = float64(busy-b.sampledBusy) / float64(elapsed.Nanoseconds())
+		if b.utilization > 1 {
+			b.utilization = 1
+		}
+	}
+	b.sampledAt = now
+	b.sampledBusy = busy
+	return b.utilization
+}
+
+// queueDepth returns the number of requests waiting for the bus.
+func (b *sharedBus) queueDepth() int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	depth := 0
+	for _, queue := range b.waiters {
+		depth += len(queue)
+	}
+	return depth
+}
+
+// stats returns a snapshot of the bus statistics.
+func (b *sharedBus) stats() BusStats {
+	queueDepth := b.queueDepth()
+
+	b.mu.Lock()
+	devices := b.refs
+	utilization := b.utilization
+	b.mu.Unlock()
+
+	return BusStats{
+		Port:        b.port,
+		Devices:     devices,
+		QueueDepth:  queueDepth,
+		Requests:    b.requests.Load(),
+		Timeouts:    b.timeouts.Load(),
+		Errors:      b.errors.Load(),
+		BusyTime:    time.Duration(b.busyNanos.Load()),
+		Utilization: utilization,
+	}
+}
+
+// busHandler is the per-device transport for a client on a shared bus.
+// Framing uses the device's own slave/unit ID; the link belongs to the bus.
+type busHandler struct {
+	modbus.Packager
+	bus     *sharedBus
+	slaveID byte
+	closed  atomic.Bool
+}
+
+// newBusHandler creates a per-device handler that sends through the bus.
+func newBusHandler(bus *sharedBus, config ClientConfig) *busHandler {
+	var packager modbus.Packager
+	if _, mbap := bus.transport.(*mbapLinkHandler); mbap {
+		tcp := modbus.NewTCPClientHandler(config.Address)
+		tcp.SlaveId = config.SlaveID
+		packager = tcp
+	} else {
+		rtu := modbus.NewRTUClientHandler(config.Address)
+		rtu.SlaveId = config.SlaveID
+		packager = rtu
+	}
+	return &busHandler{Packager: packager, bus: bus, slaveID: config.SlaveID}
+}
+
+// Send transmits the frame through the bus scheduler.
+func (h *busHandler) Send(aduRequest []byte) ([]byte, error) {
+	if h.closed.Load() {
+		return nil, domain.ErrConnectionClosed
+	}
+	return h.bus.send(h.slaveID, aduRequest)
+}
+
+// Connect opens the shared link if needed.
+func (h *busHandler) Connect() error {
+	if h.closed.Load() {
+		return domain.ErrConnectionClosed
+	}
+	return h.bus.connect()
+}
+
+// Close detaches the device's handler: later requests through it fail instead
+// of reaching the bus. The link itself is owned by the bus and closed when the
+// last device detaches from it.
+func (h *busHandler) Close() error {
+	h.closed.Store(true)
+	return nil
+}
+
+// mbapLinkHandler is the transport of a Modbus TCP connection shared by several
+// unit IDs. Requests are strictly sequential, so a response whose transaction
+// ID doesn't match the request can only be a late answer to an earlier,
+// timed-out request; the connection is then dropped rather than letting every
+// following response on it be off by one.
+type mbapLinkHandler struct {
+	*modbus.TCPClientHandler
+}
+
+// newMBAPLinkHandler creates the shared Modbus TCP transport for config.Address.
+func newMBAPLinkHandler(config ClientConfig) *mbapLinkHandler {
+	handler := modbus.NewTCPClientHandler(config.Address)
+	handler.Timeout = config.Timeout
+	handler.IdleTimeout = config.IdleTimeout
+	return &mbapLinkHandler{TCPClientHandler: handler}
+}
+
+// Send transmits one MBAP frame and drops the connection on any failure.
+func (h *mbapLinkHandler) Send(aduRequest []byte) ([]byte, error) {
+	aduResponse, err := h.TCPClientHandler.Send(aduRequest)
+	if err == nil && (len(aduResponse) < 2 || aduResponse[0] != aduRequest[0] || aduResponse[1] != aduRequest[1]) {
+		err = fmt.Errorf("modbus: stale response on shared connection (transaction id mismatch)")
+	}
+	if err != nil {
+		_ = h.TCPClientHandler.Close()
+		return nil, err
+	}
+	return aduResponse, nil
+}
@@ -0,0 +1,102 @@
+//go:build linux
+
+package modbus
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/nexus-edge/protocol-gateway/internal/domain"
+	"github.com/rs/zerolog"
+)
+
+func TestRTUBus_RoundRobinAcrossSlaves(t *testing.T) {
+	bus := newSharedBus(ClientConfig{Address: "/dev/null", Protocol: domain.ProtocolModbusRTU}, zerolog.Nop(), nil)
+
+	// Hold the bus, then queue three requests for slave 1 and one for slave 2
+	bus.acquire(9)
+	order := make(chan byte, 4)
+	enqueue := func(slaveID byte) {
+		depth := bus.queueDepth()
+		go func() {
+			bus.acquire(slaveID)
+			order <- slaveID
+			bus.release()
+		}()
+		for bus.queueDepth() == depth {
+			time.Sleep(time.Millisecond)
+		}
+	}
+	enqueue(1)
+	enqueue(1)
+	enqueue(1)
+	enqueue(2)
+	bus.release()
+
+	want := []byte{1, 2, 1, 1}
+	for i, w := range want {
+		if got := <-order; got != w {
+			t.Fatalf("grant %d: expected slave %d, got %d", i, w, got)
+		}
+	}
+}
+
+func TestRTUBus_PoolSharesOneSerialHandle(t *testing.T) {
+	master, port := openPTY(t)
+	go serveRTUSlaves(master, map[byte]map[uint16]uint16{
+		1: {0: 111},
+		2: {0: 222},
+	}, false)
+
+	pool := NewConnectionPool(DefaultPoolConfig(), zerolog.Nop(), nil)
+	defer pool.Close()
+
+	tag := &domain.Tag{ID: "t", Address: 0, RegisterType: domain.RegisterTypeHoldingRegister, DataType: domain.DataTypeUInt16, ScaleFactor: 1, RegisterCount: 1}
+	newDevice := func(id string, slaveID uint8) *domain.Device {
+		return &domain.Device{
+			ID:       id,
+			Protocol: domain.ProtocolModbusRTU,
+			Connection: domain.ConnectionConfig{
+				SerialPort: port,
+				SlaveID:    slaveID,
+				Timeout:    500 * time.Millisecond,
+				RetryCount: 1,
+			},
+		}
+	}
+
+	for _, tc := range []struct {
+		device *domain.Device
+		want   uint16
+	}{
+		{newDevice("meter-1", 1), 111},
+		{newDevice("meter-2", 2), 222},
+	} {
+		dp, err := pool.ReadTag(context.Background(), tc.device, tag)
+		if err != nil {
+			t.Fatalf("%s: ReadTag: %v", tc.device.ID, err)
+		}
+		if dp.Value != tc.want {
+			t.Errorf("%s: expected %d, got %v", tc.device.ID, tc.want, dp.Value)
+		}
+	}
+
+	stats := pool.GetBusStats()
+	if len(stats) != 1 {
+		t.Fatalf("expected 1 shared bus, got %d", len(stats))
+	}
+	if s := stats[port]; s.Devices != 2 || s.Requests != 2 {
+		t.Errorf("expected 2 devices and 2 requests on the bus, got %+v", s)
+	}
+
+	if err := pool.RemoveClient("meter-1"); err != nil {
+		t.Fatalf("RemoveClient: %v", err)
+	}
+	if err := pool.RemoveClient("meter-2"); err != nil {
+		t.Fatalf("RemoveClient: %v", err)
+	}
+	if n := len(pool.GetBusStats()); n != 0 {
+		t.Errorf("expected bus to be released after last device, got %d buses", n)
+	}
+}
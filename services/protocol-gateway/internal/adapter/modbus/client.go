@@ -13,6 +13,7 @@ import (
 	"time"
 
 	"github.com/goburrow/modbus"
+	"github.com/goburrow/serial"
 	"github.com/nexus-edge/protocol-gateway/internal/domain"
 	"github.com/rs/zerolog"
 )
@@ -37,13 +38,17 @@ func NewClient(deviceID string, config ClientConfig, logger zerolog.Logger) (*Cl
 	if config.RetryDelay == 0 {
 		config.RetryDelay = 100 * time.Millisecond
 	}
+	if config.Protocol == domain.ProtocolModbusRTU {
+		applySerialDefaults(&config)
+	}
 
 	c := &Client{
-		config:   config,
-		logger:   logger.With().Str("device_id", deviceID).Str("address", config.Address).Logger(),
-		stats:    &ClientStats{},
-		deviceID: deviceID,
-		lastUsed: time.Now(),
+		config:       config,
+		logger:       logger.With().Str("device_id", deviceID).Str("address", config.Address).Logger(),
+		stats:        &ClientStats{},
+		deviceID:     deviceID,
+		lastUsed:     time.Now(),
+		capabilities: &deviceCapabilities{},
 	}
 
 	return c, nil
@@ -60,11 +65,7 @@ func (c *Client) Connect(ctx context.Context) error {
 
 	c.logger.Debug().Msg("Connecting to Modbus device")
 
-	// Create TCP handler
-	handler := modbus.NewTCPClientHandler(c.config.Address)
-	handler.Timeout = c.config.Timeout
-	handler.SlaveId = c.config.SlaveID
-	handler.IdleTimeout = c.config.IdleTimeout
+	handler := c.newTransportHandler()
 
 	// Use context for connection timeout
 	connectDone := make(chan error, 1)
@@ -92,7 +93,7 @@ func (c *Client) Connect(ctx context.Context) error {
 	}
 
 	c.handler = handler
-	c.client = modbus.NewClient(handler)
+	c.client = c.newModbusClient(handler)
 	c.connected.Store(true)
 	c.lastError = nil
 	c.lastUsed = time.Now()
@@ -101,6 +102,26 @@ func (c *Client) Connect(ctx context.Context) error {
 	return nil
 }
 
+// newTransportHandler builds the transport for the configured protocol: the
+// shared bus when the pool assigned one, otherwise a dedicated serial port for
+// modbus-rtu, RTU-over-TCP for RTU framing, or standard MBAP over TCP.
+func (c *Client) newTransportHandler() transportHandler {
+	switch {
+	case c.config.bus != nil:
+		return newBusHandler(c.config.bus, c.config)
+	case c.config.Protocol == domain.ProtocolModbusRTU:
+		return newRTUHandler(c.config)
+	case c.config.Framing == domain.ModbusFramingRTU:
+		return newRTUOverTCPHandler(c.config)
+	}
+
+	handler := modbus.NewTCPClientHandler(c.config.Address)
+	handler.Timeout = c.config.Timeout
+	handler.SlaveId = c.config.SlaveID
+	handler.IdleTimeout = c.config.IdleTimeout
+	return handler
+}
+
 // Disconnect closes the connection to the Modbus device.
 func (c *Client) Disconnect() error {
 	c.mu.Lock()
@@ -240,7 +261,49 @@ func (c *Client) ReadTags(ctx context.Context, tags []*domain.Tag) ([]*domain.Da
 		results = append(results, groupResults...)
 	}
 
-	return results, nil
+	return c.appendBitFieldPoints(tags, results), nil
+}
+
+// appendBitFieldPoints adds the sub-points of bit-field tags (see
+// domain.Tag.BitFieldTags), derived from the register value of their parent.
+// A parent read that failed fails its sub-points with the same quality.
+func (c *Client) appendBitFieldPoints(tags []*domain.Tag, results []*domain.DataPoint) []*domain.DataPoint {
+	var parents map[string]*domain.Tag
+	for _, tag := range tags {
+		if len(tag.BitFields) > 0 {
+			if parents == nil {
+				parents = make(map[string]*domain.Tag)
+			}
+			parents[tag.ID] = tag
+		}
+	}
+	if parents == nil {
+		return results
+	}
+
+	for _, dp := range results {
+		tag := parents[dp.TagID]
+		if tag == nil {
+			continue
+		}
+		word, ok := toUint64(dp.RawValue)
+		for _, sub := range tag.BitFieldTags() {
+			if dp.Quality != domain.QualityGood || !ok {
+				results = append(results, domain.AcquireDataPoint(c.deviceID, sub.ID, "", nil, "", dp.Quality))
+				continue
+			}
+			value := word&(1<<*sub.BitPosition) != 0
+			results = append(results, domain.AcquireDataPoint(
+				c.deviceID,
+				sub.ID,
+				"",
+				value,
+				"",
+				domain.QualityGood,
+			).WithRawValue(value).WithPriority(tag.Priority))
+		}
+	}
+	return results
 }
 
 // readRegisters performs the actual Modbus read operation.
@@ -379,11 +442,11 @@ func (c *Client) buildContiguousRanges(tags []*domain.Tag, config BatchConfig) [
 		// Calculate gap between current range end and this tag's start
 		gap := int(tag.Address) - int(currentRange.EndAddress) - 1
 
-		// Calculate new range size if we merge
-		newRangeSize := tagEnd - currentRange.StartAddress + 1
+		// Calculate new range size if we merge, including any alignment padding
+		newRangeSize := alignedSize(currentRange.StartAddress, max(tagEnd, currentRange.EndAddress), config.Alignment, c.addressOffset())
 
 		// Merge if: gap is acceptable AND total size is within limits
-		if gap <= int(config.MaxGapSize) && newRangeSize <= config.MaxRegistersPerRead {
+		if gap <= int(config.MaxGapSize) && newRangeSize <= int(config.MaxRegistersPerRead) {
 			// Extend current range
 			if tagEnd > currentRange.EndAddress {
 				currentRange.EndAddress = tagEnd
@@ -402,7 +465,7 @@ func (c *Client) buildContiguousRanges(tags []*domain.Tag, config BatchConfig) [
 	// Don't forget the last range
 	ranges = append(ranges, currentRange)
 
-	return ranges
+	return alignRanges(ranges, config.Alignment, c.addressOffset())
 }
 
 // sortTagsByAddress sorts tags by address in ascending order (insertion sort for small slices).
@@ -430,8 +493,7 @@ func (c *Client) readTagGroup(ctx context.Context, tags []*domain.Tag) ([]*domai
 	}
 
 	// Build contiguous ranges for holding/input registers
-	config := DefaultBatchConfig()
-	ranges := c.buildContiguousRanges(tags, config)
+	ranges := c.buildContiguousRanges(tags, c.batchConfig())
 
 	c.logger.Debug().
 		Int("tags", len(tags)).
@@ -498,8 +560,14 @@ func (c *Client) readRegisterRange(ctx context.Context, rng RegisterRange, regTy
 	}
 	c.consecutiveFailures.Store(0)
 	c.stats.ReadCount.Add(1)
+	c.recordBatch(rng, regType)
 
-	// Extract individual tag values from the raw data
+	return c.extractRangePoints(rng, rawData), nil
+}
+
+// extractRangePoints extracts the values of a range's tags from the raw data
+// read for the range.
+func (c *Client) extractRangePoints(rng RegisterRange, rawData []byte) []*domain.DataPoint {
 	results := make([]*domain.DataPoint, 0, len(rng.Tags))
 	for _, tag := range rng.Tags {
 		// Calculate offset within the raw data
@@ -542,7 +610,7 @@ func (c *Client) readRegisterRange(ctx context.Context, rng RegisterRange, regTy
 		results = append(results, dp)
 	}
 
-	return results, nil
+	return results
 }
 
 // CoilBatchConfig configures the coil/discrete input batching algorithm.
@@ -551,6 +619,8 @@ type CoilBatchConfig struct {
 	MaxCoilsPerRead uint16
 	// MaxGapSize is the maximum gap between coil addresses to merge into one read
 	MaxGapSize uint16
+	// Alignment makes each read start on a multiple of this many coils
+	Alignment uint16
 }
 
 // DefaultCoilBatchConfig returns sensible defaults for coil batching.
@@ -569,8 +639,7 @@ func (c *Client) readCoilGroupBatched(ctx context.Context, tags []*domain.Tag) (
 		return nil, nil
 	}
 
-	config := DefaultCoilBatchConfig()
-	ranges := c.buildCoilRanges(tags, config)
+	ranges := c.buildCoilRanges(tags, c.coilBatchConfig())
 
 	c.logger.Debug().
 		Int("tags", len(tags)).
@@ -626,9 +695,9 @@ func (c *Client) buildCoilRanges(tags []*domain.Tag, config CoilBatchConfig) []R
 		tagEnd := tag.Address + tag.RegisterCount - 1
 
 		gap := int(tag.Address) - int(currentRange.EndAddress) - 1
-		newRangeSize := tagEnd - currentRange.StartAddress + 1
+		newRangeSize := alignedSize(currentRange.StartAddress, max(tagEnd, currentRange.EndAddress), config.Alignment, c.addressOffset())
 
-		if gap <= int(config.MaxGapSize) && newRangeSize <= config.MaxCoilsPerRead {
+		if gap <= int(config.MaxGapSize) && newRangeSize <= int(config.MaxCoilsPerRead) {
 			if tagEnd > currentRange.EndAddress {
 				currentRange.EndAddress = tagEnd
 			}
@@ -644,7 +713,7 @@ func (c *Client) buildCoilRanges(tags []*domain.Tag, config CoilBatchConfig) []R
 	}
 	ranges = append(ranges, currentRange)
 
-	return ranges
+	return alignRanges(ranges, config.Alignment, c.addressOffset())
 }
 
 // readCoilRange reads a contiguous range of coils/discrete inputs and extracts
@@ -681,21 +750,32 @@ func (c *Client) readCoilRange(ctx context.Context, rng RegisterRange, regType d
 	}
 	c.consecutiveFailures.Store(0)
 	c.stats.ReadCount.Add(1)
+	c.recordBatch(rng, regType)
 
 	// Extract individual tag values from bit-packed data
 	results := make([]*domain.DataPoint, 0, len(rng.Tags))
 	for _, tag := range rng.Tags {
 		bitOffset := int(tag.Address - rng.StartAddress)
-		byteIndex := bitOffset / 8
-		bitIndex := uint(bitOffset % 8)
+		bitCount := 1
+		if tag.ArrayLength > 0 {
+			bitCount = int(tag.ArrayLength)
+		}
 
-		if byteIndex >= len(rawData) {
+		if (bitOffset+bitCount-1)/8 >= len(rawData) {
 			c.recordTagError(tag.ID, domain.ErrInvalidDataLength)
 			results = append(results, c.createErrorDataPoint(tag, domain.ErrInvalidDataLength))
 			continue
 		}
 
-		value := (rawData[byteIndex] & (1 << bitIndex)) != 0
+		var value interface{} = (rawData[bitOffset/8] & (1 << uint(bitOffset%8))) != 0
+		if tag.ArrayLength > 0 {
+			bits := make([]interface{}, bitCount)
+			for i := range bits {
+				bit := bitOffset + i
+				bits[i] = (rawData[bit/8] & (1 << uint(bit%8))) != 0
+			}
+			value = bits
+		}
 
 		scaledValue := applyScaling(value, tag)
 		c.recordTagSuccess(tag.ID)
@@ -813,11 +893,13 @@ func contains(s string, substrs ...string) bool {
 }
 
 // isTimeout checks if the error is a timeout error.
+// Serial ports report response timeouts as serial.ErrTimeout rather than a net.Error.
 func isTimeout(err error) bool {
-	if netErr, ok := err.(net.Error); ok {
+	var netErr net.Error
+	if errors.As(err, &netErr) {
 		return netErr.Timeout()
 	}
-	return false
+	return errors.Is(err, serial.ErrTimeout)
 }
 
 // translateModbusError converts Modbus library errors to domain errors.
@@ -826,8 +908,8 @@ func (c *Client) translateModbusError(err error) error {
 		return nil
 	}
 	// The goburrow/modbus library returns exception codes in error messages
-	// We'll wrap the original error for now
-	return fmt.Errorf("%w: %v", domain.ErrReadFailed, err)
+	// Wrap both so callers can still classify transport errors (timeouts, EOF)
+	return fmt.Errorf("%w: %w", domain.ErrReadFailed, err)
 }
 
 // reconnect attempts to re-establish the connection.
@@ -858,9 +940,24 @@ func (c *Client) WriteTag(ctx context.Context, tag *domain.Tag, value interface{
 		return fmt.Errorf("%w: tag %s (register type: %s)", domain.ErrTagNotWritable, tag.ID, tag.RegisterType)
 	}
 
+	if err := c.retryWrite(ctx, func() error { return c.writeRegister(tag, value) }); err != nil {
+		return err
+	}
+
+	c.stats.WriteCount.Add(1)
+	c.logger.Debug().
+		Str("tag", tag.ID).
+		Interface("value", value).
+		Msg("Successfully wrote to Modbus register")
+
+	return nil
+}
+
+// retryWrite runs a write operation with the client's retry policy,
+// reconnecting on connection errors.
+func (c *Client) retryWrite(ctx context.Context, write func() error) error {
 	var err error
 
-	// Execute write with retry logic
 	for attempt := 0; attempt <= c.config.MaxRetries; attempt++ {
 		if attempt > 0 {
 			c.stats.RetryCount.Add(1)
@@ -877,9 +974,9 @@ func (c *Client) WriteTag(ctx context.Context, tag *domain.Tag, value interface{
 			}
 		}
 
-		err = c.writeRegister(tag, value)
+		err = write()
 		if err == nil {
-			break
+			return nil
 		}
 
 		// Check if error is retryable
@@ -895,23 +992,36 @@ func (c *Client) WriteTag(ctx context.Context, tag *domain.Tag, value interface{
 		}
 	}
 
-	if err != nil {
-		c.stats.ErrorCount.Add(1)
-		return err
-	}
+	c.stats.ErrorCount.Add(1)
+	return err
+}
 
-	c.stats.WriteCount.Add(1)
-	c.logger.Debug().
-		Str("tag", tag.ID).
-		Interface("value", value).
-		Msg("Successfully wrote to Modbus register")
+// writeRegister performs the actual Modbus write operation.
+func (c *Client) writeRegister(tag *domain.Tag, value interface{}) error {
+	switch tag.RegisterType {
+	case domain.RegisterTypeCoil, domain.RegisterTypeHoldingRegister:
+	default:
+		return fmt.Errorf("%w: %s is read-only", domain.ErrTagNotWritable, tag.RegisterType)
+	}
 
-	return nil
+	return c.execLocked(func(client modbus.Client) error {
+		switch {
+		case tag.RegisterType == domain.RegisterTypeCoil && tag.ArrayLength > 0:
+			return c.writeCoilArrayLocked(client, tag, value)
+		case tag.RegisterType == domain.RegisterTypeCoil:
+			return c.writeSingleCoilLocked(client, tag.Address, value)
+		case tag.BitPosition != nil || len(tag.BitFields) > 0:
+			return c.writeRegisterBitsLocked(client, tag, value)
+		default:
+			return c.writeHoldingRegisterLocked(client, tag, value)
+		}
+	})
 }
 
-// writeRegister performs the actual Modbus write operation.
+// execLocked runs an operation on the underlying Modbus client, tracking
+// consecutive failures.
 // Uses opMu to serialize operations - goburrow/modbus Client is NOT thread-safe.
-func (c *Client) writeRegister(tag *domain.Tag, value interface{}) error {
+func (c *Client) execLocked(op func(client modbus.Client) error) error {
 	c.mu.RLock()
 	client := c.client
 	c.mu.RUnlock()
@@ -924,17 +1034,7 @@ func (c *Client) writeRegister(tag *domain.Tag, value interface{}) error {
 	c.opMu.Lock()
 	defer c.opMu.Unlock()
 
-	var err error
-	switch tag.RegisterType {
-	case domain.RegisterTypeCoil:
-		err = c.writeSingleCoilLocked(client, tag.Address, value)
-	case domain.RegisterTypeHoldingRegister:
-		err = c.writeHoldingRegisterLocked(client, tag, value)
-	default:
-		return fmt.Errorf("%w: %s is read-only", domain.ErrTagNotWritable, tag.RegisterType)
-	}
-
-	if err != nil {
+	if err := op(client); err != nil {
 		c.consecutiveFailures.Add(1)
 		return err
 	}
@@ -965,6 +1065,123 @@ func (c *Client) writeSingleCoilLocked(client modbus.Client, address uint16, val
 	return nil
 }
 
+// writeCoilArrayLocked writes the states of a coil array (function code 0x0F).
+// Caller must hold opMu.
+func (c *Client) writeCoilArrayLocked(client modbus.Client, tag *domain.Tag, value interface{}) error {
+	states, err := boolArray(value, tag.ArrayLength)
+	if err != nil {
+		return err
+	}
+
+	if _, err := client.WriteMultipleCoils(tag.Address, uint16(len(states)), packCoils(states)); err != nil {
+		return fmt.Errorf("%w: %v", domain.ErrWriteFailed, err)
+	}
+	return nil
+}
+
+// writeRegisterBitsLocked changes single bits of a holding register without
+// touching its neighbours: a bool tag with BitPosition sets its bit, a
+// bit-field tag given a map of field names to bools sets those fields. Any
+// other value for a bit-field tag replaces the whole register.
+// Uses FC22 Mask Write Register unless the device is known not to support it,
+// falling back to a read-modify-write under opMu.
+// Caller must hold opMu.
+func (c *Client) writeRegisterBitsLocked(client modbus.Client, tag *domain.Tag, value interface{}) error {
+	var mask, bits uint16
+	switch {
+	case tag.BitPosition != nil:
+		on, ok := toBool(value)
+		if !ok {
+			return fmt.Errorf("%w: cannot convert %T to bool for bit %d", domain.ErrInvalidWriteValue, value, *tag.BitPosition)
+		}
+		mask = 1 << *tag.BitPosition
+		if on {
+			bits = mask
+		}
+	default:
+		fields, ok := value.(map[string]interface{})
+		if !ok {
+			return c.writeHoldingRegisterLocked(client, tag, value)
+		}
+		positions := make(map[string]uint8, len(tag.BitFields))
+		for _, field := range tag.BitFields {
+			positions[field.Name] = field.Bit
+		}
+		for name, v := range fields {
+			bit, known := positions[name]
+			if !known {
+				return fmt.Errorf("%w: unknown bit field %q", domain.ErrInvalidWriteValue, name)
+			}
+			on, ok := toBool(v)
+			if !ok {
+				return fmt.Errorf("%w: cannot convert %T to bool for bit field %q", domain.ErrInvalidWriteValue, v, name)
+			}
+			mask |= 1 << bit
+			if on {
+				bits |= 1 << bit
+			}
+		}
+	}
+
+	if c.capabilities.maskWrite.Load() != capabilityUnsupported {
+		err := c.writeBitsWithMask(client, tag, mask, bits)
+		if err == nil {
+			c.capabilities.maskWrite.Store(capabilitySupported)
+			return nil
+		}
+		var mbErr *modbus.ModbusError
+		if !errors.As(err, &mbErr) || mbErr.ExceptionCode != modbus.ExceptionCodeIllegalFunction {
+			return fmt.Errorf("%w: %v", domain.ErrWriteFailed, err)
+		}
+		c.capabilities.maskWrite.Store(capabilityUnsupported)
+		c.logger.Info().Msg("Device does not support Mask Write Register (FC22), using read-modify-write for bit writes")
+	}
+
+	return c.writeBitsWithRMW(client, tag, mask, bits)
+}
+
+// writeBitsWithMask sets the masked bits of a holding register to bits with a
+// single FC22 Mask Write Register request.
+// Caller must hold opMu.
+func (c *Client) writeBitsWithMask(client modbus.Client, tag *domain.Tag, mask, bits uint16) error {
+	// The device applies the masks to the register as transmitted
+	andMask := registerWord(^mask, tag.ByteOrder)
+	orMask := registerWord(bits, tag.ByteOrder)
+	_, err := client.MaskWriteRegister(tag.Address, andMask, orMask)
+	return err
+}
+
+// writeBitsWithRMW performs a read-modify-write to set the masked bits of a
+// holding register while preserving the others. Holding opMu keeps other
+// gateway operations out between the read and the write.
+// Caller must hold opMu.
+func (c *Client) writeBitsWithRMW(client modbus.Client, tag *domain.Tag, mask, bits uint16) error {
+	current, err := client.ReadHoldingRegisters(tag.Address, 1)
+	if err != nil {
+		return fmt.Errorf("%w: read before bit write: %v", domain.ErrWriteFailed, err)
+	}
+	if len(current) < 2 {
+		return fmt.Errorf("%w: read before bit write returned %d bytes", domain.ErrWriteFailed, len(current))
+	}
+
+	// Bits are numbered in the register value after byte ordering
+	word := registerWord(binary.BigEndian.Uint16(current), tag.ByteOrder)
+	word = word&^mask | bits
+
+	if _, err := client.WriteSingleRegister(tag.Address, registerWord(word, tag.ByteOrder)); err != nil {
+		return fmt.Errorf("%w: %v", domain.ErrWriteFailed, err)
+	}
+	return nil
+}
+
+// registerWord converts a single register between its transmitted form and
+// its value under the tag's byte order (the conversion is its own inverse).
+func registerWord(word uint16, order domain.ByteOrder) uint16 {
+	b := make([]byte, 2)
+	binary.BigEndian.PutUint16(b, word)
+	return binary.BigEndian.Uint16(reorderBytes(b, order))
+}
+
 // writeHoldingRegisterLocked writes a value to holding register(s).
 // Caller must hold opMu.
 func (c *Client) writeHoldingRegisterLocked(client modbus.Client, tag *domain.Tag, value interface{}) error {
@@ -5,6 +5,9 @@ import (
 	"encoding/binary"
 	"fmt"
 	"math"
+	"reflect"
+	"strings"
+	"unicode/utf8"
 
 	"github.com/nexus-edge/protocol-gateway/internal/domain"
 )
@@ -15,6 +18,10 @@ func parseValue(data []byte, tag *domain.Tag) (interface{}, error) {
 		return nil, domain.ErrInvalidDataLength
 	}
 
+	if tag.ArrayLength > 0 {
+		return parseArray(data, tag)
+	}
+
 	// Handle coil/discrete input (boolean) values
 	if tag.RegisterType == domain.RegisterTypeCoil ||
 		tag.RegisterType == domain.RegisterTypeDiscreteInput {
@@ -30,6 +37,10 @@ func parseValue(data []byte, tag *domain.Tag) (interface{}, error) {
 		return nil, domain.ErrInvalidDataLength
 	}
 
+	if tag.DataType == domain.DataTypeString {
+		return decodeString(data[:expectedLen], tag), nil
+	}
+
 	// Reorder bytes based on byte order
 	orderedData := reorderBytes(data[:expectedLen], tag.ByteOrder)
 
@@ -72,6 +83,180 @@ func parseValue(data []byte, tag *domain.Tag) (interface{}, error) {
 	}
 }
 
+// parseArray converts the raw bytes of an array tag into a slice of element
+// values: bit-packed coils/discrete inputs (LSB first) or consecutive register
+// elements.
+func parseArray(data []byte, tag *domain.Tag) ([]interface{}, error) {
+	values := make([]interface{}, tag.ArrayLength)
+
+	if tag.RegisterType == domain.RegisterTypeCoil || tag.RegisterType == domain.RegisterTypeDiscreteInput {
+		if len(data)*8 < int(tag.ArrayLength) {
+			return nil, domain.ErrInvalidDataLength
+		}
+		for i := range values {
+			values[i] = data[i/8]&(1<<uint(i%8)) != 0
+		}
+		return values, nil
+	}
+
+	element := arrayElement(tag)
+	size := int(element.RegisterCount) * 2
+	if len(data) < size*len(values) {
+		return nil, domain.ErrInvalidDataLength
+	}
+	for i := range values {
+		value, err := parseValue(data[i*size:(i+1)*size], element)
+		if err != nil {
+			return nil, err
+		}
+		values[i] = value
+	}
+	return values, nil
+}
+
+// arrayElement returns the tag describing one element of an array tag.
+func arrayElement(tag *domain.Tag) *domain.Tag {
+	element := *tag
+	element.ArrayLength = 0
+	element.RegisterCount = element.ExpectedRegisterCount()
+	return &element
+}
+
+// decodeString converts string registers to text: bytes are taken high byte
+// first per register (low byte first with StringByteSwap), padding is removed
+// per StringTrim, and bytes invalid in the encoding become U+FFFD.
+func decodeString(data []byte, tag *domain.Tag) string {
+	raw := make([]byte, len(data))
+	copy(raw, data)
+	if tag.StringByteSwap {
+		swapRegisterBytes(raw)
+	}
+
+	if tag.StringTrim != domain.StringTrimNone {
+		if i := strings.IndexByte(string(raw), 0); i >= 0 {
+			raw = raw[:i]
+		}
+	}
+
+	var text string
+	switch {
+	case tag.StringEncoding == domain.StringEncodingUTF8:
+		text = strings.ToValidUTF8(string(raw), string(utf8.RuneError))
+	case isASCII(raw):
+		text = string(raw)
+	default:
+		runes := make([]rune, len(raw))
+		for i, b := range raw {
+			runes[i] = rune(b)
+			if b >= utf8.RuneSelf {
+				runes[i] = utf8.RuneError
+			}
+		}
+		text = string(runes)
+	}
+
+	if tag.StringTrim == domain.StringTrimSpace {
+		text = strings.TrimSpace(text)
+	}
+	return text
+}
+
+// encodeString converts text to the registers of a string tag, padded to
+// RegisterCount registers with NULs (spaces with StringTrim "space").
+func encodeString(value interface{}, tag *domain.Tag) ([]byte, error) {
+	text, ok := value.(string)
+	if !ok {
+		return nil, fmt.Errorf("%w: cannot convert %T to string", domain.ErrInvalidWriteValue, value)
+	}
+	if tag.StringEncoding == domain.StringEncodingUTF8 {
+		if !utf8.ValidString(text) {
+			return nil, fmt.Errorf("%w: string is not valid UTF-8", domain.ErrInvalidWriteValue)
+		}
+	} else if !isASCII([]byte(text)) {
+		return nil, fmt.Errorf("%w: string contains non-ASCII characters", domain.ErrInvalidWriteValue)
+	}
+
+	size := int(tag.RegisterCount) * 2
+	if len(text) > size {
+		return nil, fmt.Errorf("%w: string of %d bytes exceeds %d registers", domain.ErrInvalidWriteValue, len(text), tag.RegisterCount)
+	}
+
+	pad := byte(0)
+	if tag.StringTrim == domain.StringTrimSpace {
+		pad = ' '
+	}
+	bytes := make([]byte, size)
+	n := copy(bytes, text)
+	for i := n; i < size; i++ {
+		bytes[i] = pad
+	}
+	if tag.StringByteSwap {
+		swapRegisterBytes(bytes)
+	}
+	return bytes, nil
+}
+
+// arrayToBytes converts a slice of element values to the registers of an
+// array tag.
+func arrayToBytes(value interface{}, tag *domain.Tag) ([]byte, error) {
+	values := reflect.ValueOf(value)
+	if values.Kind() != reflect.Slice && values.Kind() != reflect.Array {
+		return nil, fmt.Errorf("%w: cannot convert %T to array", domain.ErrInvalidWriteValue, value)
+	}
+	if values.Len() != int(tag.ArrayLength) {
+		return nil, fmt.Errorf("%w: array of %d elements, tag has %d", domain.ErrInvalidWriteValue, values.Len(), tag.ArrayLength)
+	}
+
+	element := arrayElement(tag)
+	bytes := make([]byte, 0, int(tag.RegisterCount)*2)
+	for i := 0; i < values.Len(); i++ {
+		b, err := valueToBytes(values.Index(i).Interface(), element)
+		if err != nil {
+			return nil, fmt.Errorf("element %d: %w", i, err)
+		}
+		bytes = append(bytes, b...)
+	}
+	return bytes, nil
+}
+
+// boolArray converts a slice of values to the states of a coil array.
+func boolArray(value interface{}, length uint16) ([]bool, error) {
+	values := reflect.ValueOf(value)
+	if values.Kind() != reflect.Slice && values.Kind() != reflect.Array {
+		return nil, fmt.Errorf("%w: cannot convert %T to array", domain.ErrInvalidWriteValue, value)
+	}
+	if values.Len() != int(length) {
+		return nil, fmt.Errorf("%w: array of %d elements, tag has %d", domain.ErrInvalidWriteValue, values.Len(), length)
+	}
+
+	states := make([]bool, values.Len())
+	for i := range states {
+		b, ok := toBool(values.Index(i).Interface())
+		if !ok {
+			return nil, fmt.Errorf("%w: element %d: cannot convert %T to bool", domain.ErrInvalidWriteValue, i, values.Index(i).Interface())
+		}
+		states[i] = b
+	}
+	return states, nil
+}
+
+// swapRegisterBytes swaps the two bytes of each register in place.
+func swapRegisterBytes(data []byte) {
+	for i := 0; i+1 < len(data); i += 2 {
+		data[i], data[i+1] = data[i+1], data[i]
+	}
+}
+
+// isASCII reports whether every byte is 7-bit ASCII.
+func isASCII(data []byte) bool {
+	for _, b := range data {
+		if b >= utf8.RuneSelf {
+			return false
+		}
+	}
+	return true
+}
+
 // reorderBytes reorders bytes in-place according to the specified byte order.
 // Returns the same slice (no allocation) for all byte orders.
 func reorderBytes(data []byte, order domain.ByteOrder) []byte {
@@ -111,6 +296,12 @@ func applyScaling(value interface{}, tag *domain.Tag) interface{} {
 
 	var floatVal float64
 	switch v := value.(type) {
+	case []interface{}:
+		scaled := make([]interface{}, len(v))
+		for i, element := range v {
+			scaled[i] = applyScaling(element, tag)
+		}
+		return scaled
 	case int16:
 		floatVal = float64(v)
 	case uint16:
@@ -152,6 +343,13 @@ func reverseScaling(value interface{}, tag *domain.Tag) interface{} {
 
 // valueToBytes converts a value to bytes based on the tag's data type.
 func valueToBytes(value interface{}, tag *domain.Tag) ([]byte, error) {
+	switch {
+	case tag.ArrayLength > 0:
+		return arrayToBytes(value, tag)
+	case tag.DataType == domain.DataTypeString:
+		return encodeString(value, tag)
+	}
+
 	// Reverse scaling if applied
 	actualValue := reverseScaling(value, tag)
 
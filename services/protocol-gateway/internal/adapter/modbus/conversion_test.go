@@ -0,0 +1,267 @@
+package modbus
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"reflect"
+	"sync"
+	"testing"
+
+	"github.com/nexus-edge/protocol-gateway/internal/domain"
+	"github.com/rs/zerolog"
+)
+
+func TestStringTag_RoundTrip(t *testing.T) {
+	tests := []struct {
+		name  string
+		tag   domain.Tag
+		write string
+		raw   []byte
+		read  string
+	}{
+		{"null padded", domain.Tag{RegisterCount: 3}, "PX-1", []byte("PX-1\x00\x00"), "PX-1"},
+		{"byte swapped", domain.Tag{RegisterCount: 2, StringByteSwap: true}, "ABC", []byte("BA\x00C"), "ABC"},
+		{"space padded", domain.Tag{RegisterCount: 3, StringTrim: domain.StringTrimSpace}, "ok", []byte("ok    "), "ok"},
+		{"keep padding", domain.Tag{RegisterCount: 2, StringTrim: domain.StringTrimNone}, "ab", []byte("ab\x00\x00"), "ab\x00\x00"},
+		{"utf8", domain.Tag{RegisterCount: 2, StringEncoding: domain.StringEncodingUTF8}, "°C", []byte("°C\x00"), "°C"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tag := tt.tag
+			tag.RegisterType = domain.RegisterTypeHoldingRegister
+			tag.DataType = domain.DataTypeString
+
+			raw, err := valueToBytes(tt.write, &tag)
+			if err != nil {
+				t.Fatalf("valueToBytes: %v", err)
+			}
+			if !reflect.DeepEqual(raw, tt.raw) {
+				t.Errorf("encoded %q, want %q", raw, tt.raw)
+			}
+
+			value, err := parseValue(raw, &tag)
+			if err != nil {
+				t.Fatalf("parseValue: %v", err)
+			}
+			if value != tt.read {
+				t.Errorf("decoded %q, want %q", value, tt.read)
+			}
+		})
+	}
+
+	tag := domain.Tag{RegisterType: domain.RegisterTypeHoldingRegister, DataType: domain.DataTypeString, RegisterCount: 1}
+	for _, value := range []interface{}{"abc", "é", 42} {
+		if _, err := valueToBytes(value, &tag); !errors.Is(err, domain.ErrInvalidWriteValue) {
+			t.Errorf("write %v: expected ErrInvalidWriteValue, got %v", value, err)
+		}
+	}
+	if value, _ := parseValue([]byte{'a', 0xE9}, &tag); value != "a�" {
+		t.Errorf("expected non-ASCII byte replaced, got %q", value)
+	}
+}
+
+func TestArrayTag_RoundTrip(t *testing.T) {
+	tag := domain.Tag{ID: "a", Name: "a", TopicSuffix: "a", RegisterType: domain.RegisterTypeHoldingRegister, DataType: domain.DataTypeInt16, ArrayLength: 3}
+	if err := tag.ValidateForProtocol(domain.ProtocolModbusTCP); err != nil {
+		t.Fatalf("validate: %v", err)
+	}
+	if tag.RegisterCount != 3 {
+		t.Fatalf("expected 3 registers, got %d", tag.RegisterCount)
+	}
+
+	raw, err := valueToBytes([]interface{}{1.0, -2.0, 300.0}, &tag)
+	if err != nil {
+		t.Fatalf("valueToBytes: %v", err)
+	}
+	value, err := parseValue(raw, &tag)
+	if err != nil {
+		t.Fatalf("parseValue: %v", err)
+	}
+	if want := []interface{}{int16(1), int16(-2), int16(300)}; !reflect.DeepEqual(value, want) {
+		t.Errorf("got %v, want %v", value, want)
+	}
+
+	tag.ScaleFactor = 0.5
+	if scaled := applyScaling(value, &tag); !reflect.DeepEqual(scaled, []interface{}{0.5, -1.0, 150.0}) {
+		t.Errorf("unexpected scaled array %v", scaled)
+	}
+	if _, err := valueToBytes([]interface{}{1, 2}, &tag); !errors.Is(err, domain.ErrInvalidWriteValue) {
+		t.Errorf("expected length mismatch to fail, got %v", err)
+	}
+
+	coils := domain.Tag{RegisterType: domain.RegisterTypeCoil, DataType: domain.DataTypeBool, ArrayLength: 10, RegisterCount: 10}
+	value, err = parseValue([]byte{0x05, 0x02}, &coils)
+	if err != nil {
+		t.Fatalf("parseValue coils: %v", err)
+	}
+	if want := []interface{}{true, false, true, false, false, false, false, false, false, true}; !reflect.DeepEqual(value, want) {
+		t.Errorf("got %v, want %v", value, want)
+	}
+}
+
+// registerStandIn answers register reads and writes (FC03, FC06, FC16, and
+// FC22/FC23 when enabled) and coil writes (FC05, FC15) from one unit's mutable
+// holding registers and coils, counting requests per function code.
+type registerStandIn struct {
+	mu        sync.Mutex
+	registers map[uint16]uint16
+	coils     map[uint16]bool
+	maskWrite bool
+	readWrite bool
+	requests  map[byte]int
+}
+
+func (s *registerStandIn) respond(unit byte, pdu []byte) []byte {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.requests == nil {
+		s.requests = make(map[byte]int)
+	}
+	if s.coils == nil {
+		s.coils = make(map[uint16]bool)
+	}
+	s.requests[pdu[0]]++
+
+	readRegisters := func(address, count uint16) []byte {
+		resp := []byte{pdu[0], byte(count * 2)}
+		for i := uint16(0); i < count; i++ {
+			resp = binary.BigEndian.AppendUint16(resp, s.registers[address+i])
+		}
+		return resp
+	}
+	writeRegisters := func(address uint16, data []byte) {
+		for i := 0; i+1 < len(data); i += 2 {
+			s.registers[address+uint16(i/2)] = binary.BigEndian.Uint16(data[i:])
+		}
+	}
+
+	address := binary.BigEndian.Uint16(pdu[1:])
+	switch pdu[0] {
+	case 0x03:
+		return readRegisters(address, binary.BigEndian.Uint16(pdu[3:]))
+	case 0x05:
+		s.coils[address] = pdu[3] == 0xFF
+		return pdu
+	case 0x06:
+		s.registers[address] = binary.BigEndian.Uint16(pdu[3:])
+		return pdu
+	case 0x0F:
+		for i := uint16(0); i < binary.BigEndian.Uint16(pdu[3:]); i++ {
+			s.coils[address+i] = pdu[6+i/8]&(1<<(i%8)) != 0
+		}
+		return pdu[:5]
+	case 0x10:
+		writeRegisters(address, pdu[6:])
+		return pdu[:5]
+	case 0x16:
+		if !s.maskWrite {
+			break
+		}
+		and, or := binary.BigEndian.Uint16(pdu[3:]), binary.BigEndian.Uint16(pdu[5:])
+		s.registers[address] = s.registers[address]&and | or&^and
+		return pdu
+	case 0x17:
+		if !s.readWrite {
+			break
+		}
+		writeRegisters(binary.BigEndian.Uint16(pdu[5:]), pdu[10:])
+		return readRegisters(address, binary.BigEndian.Uint16(pdu[3:]))
+	}
+	return []byte{pdu[0] | 0x80, 0x01}
+}
+
+func TestBitFieldTag_ReadAndWrite(t *testing.T) {
+	registers := &registerStandIn{registers: map[uint16]uint16{10: 0x8005}}
+	standIn := startTCPStandIn(t, &tcpStandIn{respond: registers.respond})
+	host, port := standIn.host()
+	device := gatewayDevice("bits", host, port, 1, "", false)
+
+	status := &domain.Tag{
+		ID: "status", Name: "Status", TopicSuffix: "status", Address: 10,
+		RegisterType: domain.RegisterTypeHoldingRegister, DataType: domain.DataTypeUInt16,
+		AccessMode: domain.AccessModeReadWrite,
+		BitFields:  []domain.BitField{{Name: "running", Bit: 0}, {Name: "fault", Bit: 1}, {Name: "remote", Bit: 15}},
+	}
+	if err := status.ValidateForProtocol(domain.ProtocolModbusTCP); err != nil {
+		t.Fatalf("validate: %v", err)
+	}
+
+	pool := NewConnectionPool(DefaultPoolConfig(), zerolog.Nop(), nil)
+	defer pool.Close()
+
+	points, err := pool.ReadTags(context.Background(), device, []*domain.Tag{status})
+	if err != nil {
+		t.Fatalf("ReadTags: %v", err)
+	}
+	got := make(map[string]interface{})
+	for _, dp := range points {
+		got[dp.TagID] = dp.Value
+	}
+	want := map[string]interface{}{"status": uint16(0x8005), "status.running": true, "status.fault": false, "status.remote": true}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+
+	subs := status.BitFieldTags()
+	if subs[1].ID != "status.fault" || subs[1].TopicSuffix != "status.fault" || !subs[1].IsWritable() {
+		t.Fatalf("unexpected sub-point tag %+v", subs[1])
+	}
+	if err := pool.WriteTag(context.Background(), device, subs[1], true); err != nil {
+		t.Fatalf("WriteTag sub-point: %v", err)
+	}
+	if err := pool.WriteTag(context.Background(), device, status, map[string]interface{}{"running": false, "remote": false}); err != nil {
+		t.Fatalf("WriteTag fields: %v", err)
+	}
+	registers.mu.Lock()
+	v := registers.registers[10]
+	registers.mu.Unlock()
+	if v != 0x0006 {
+		t.Errorf("expected register 0x0006 after bit writes, got 0x%04X", v)
+	}
+	if err := pool.WriteTag(context.Background(), device, status, map[string]interface{}{"unknown": true}); !errors.Is(err, domain.ErrInvalidWriteValue) {
+		t.Errorf("expected unknown field to fail, got %v", err)
+	}
+}
+
+func TestRegisterBitWrite_MaskWriteAndFallback(t *testing.T) {
+	bit := uint8(9)
+	tag := &domain.Tag{
+		ID: "remote", Name: "Remote", TopicSuffix: "remote", Address: 4,
+		RegisterType: domain.RegisterTypeHoldingRegister, DataType: domain.DataTypeBool,
+		ByteOrder: domain.ByteOrderLittleEndian, AccessMode: domain.AccessModeReadWrite, BitPosition: &bit,
+	}
+	if err := tag.ValidateForProtocol(domain.ProtocolModbusTCP); err != nil {
+		t.Fatalf("validate: %v", err)
+	}
+
+	for _, maskWrite := range []bool{true, false} {
+		registers := &registerStandIn{registers: map[uint16]uint16{4: 0x00FD}, maskWrite: maskWrite}
+		standIn := startTCPStandIn(t, &tcpStandIn{respond: registers.respond})
+		host, port := standIn.host()
+		device := gatewayDevice("mask", host, port, 1, "", false)
+
+		pool := NewConnectionPool(DefaultPoolConfig(), zerolog.Nop(), nil)
+		for _, on := range []bool{true, false, true} {
+			if err := pool.WriteTag(context.Background(), device, tag, on); err != nil {
+				t.Fatalf("maskWrite=%v: WriteTag %v: %v", maskWrite, on, err)
+			}
+		}
+		pool.Close()
+
+		registers.mu.Lock()
+		// Bit 9 of the little-endian value is bit 1 of the transmitted register
+		if got := registers.registers[4]; got != 0x00FF {
+			t.Errorf("maskWrite=%v: register 0x%04X, want 0x00FF", maskWrite, got)
+		}
+		if got := registers.requests[0x16]; maskWrite && got != 3 || !maskWrite && got != 1 {
+			t.Errorf("maskWrite=%v: %d FC22 requests", maskWrite, got)
+		}
+		if got := registers.requests[0x06]; maskWrite && got != 0 || !maskWrite && got != 3 {
+			t.Errorf("maskWrite=%v: %d FC06 requests", maskWrite, got)
+		}
+		registers.mu.Unlock()
+	}
+}
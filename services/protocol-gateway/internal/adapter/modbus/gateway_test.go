@@ -0,0 +1,318 @@
+package modbus
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/nexus-edge/protocol-gateway/internal/domain"
+	"github.com/rs/zerolog"
+)
+
+// crc16 computes the Modbus RTU CRC (poly 0xA001, init 0xFFFF).
+func crc16(data []byte) uint16 {
+	crc := uint16(0xFFFF)
+	for _, b := range data {
+		crc ^= uint16(b)
+		for i := 0; i < 8; i++ {
+			if crc&1 != 0 {
+				crc = (crc >> 1) ^ 0xA001
+			} else {
+				crc >>= 1
+			}
+		}
+	}
+	return crc
+}
+
+// rtuFrame appends the CRC (low byte first) to a slave address + PDU.
+func rtuFrame(pdu []byte) []byte {
+	crc := crc16(pdu)
+	return append(pdu, byte(crc), byte(crc>>8))
+}
+
+// tcpStandIn is a minimal Modbus TCP server answering FC03 from per-unit register maps.
+// With rtuFraming it speaks RTU frames over TCP like a transparent serial converter.
+type tcpStandIn struct {
+	listener    net.Listener
+	units       map[byte]map[uint16]uint16
+	rtuFraming  bool
+	connections atomic.Int32
+
+	// respond, if set, answers a PDU in place of the FC03 register maps
+	// (nil: no answer). TCP framing only.
+	respond func(unit byte, pdu []byte) []byte
+}
+
+func newTCPStandIn(t *testing.T, units map[byte]map[uint16]uint16, rtuFraming bool) *tcpStandIn {
+	t.Helper()
+	return startTCPStandIn(t, &tcpStandIn{units: units, rtuFraming: rtuFraming})
+}
+
+// startTCPStandIn starts serving a configured stand-in on a local port.
+func startTCPStandIn(t *testing.T, s *tcpStandIn) *tcpStandIn {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	s.listener = listener
+	t.Cleanup(func() { listener.Close() })
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			s.connections.Add(1)
+			go s.serve(conn)
+		}
+	}()
+	return s
+}
+
+func (s *tcpStandIn) host() (string, int) {
+	addr := s.listener.Addr().(*net.TCPAddr)
+	return addr.IP.String(), addr.Port
+}
+
+func (s *tcpStandIn) serve(conn net.Conn) {
+	defer conn.Close()
+	for {
+		var unit byte
+		var pdu []byte
+		var header []byte
+
+		if s.rtuFraming {
+			frame := make([]byte, 8)
+			if _, err := io.ReadFull(conn, frame); err != nil {
+				return
+			}
+			if crc16(frame[:6]) != binary.LittleEndian.Uint16(frame[6:]) {
+				continue
+			}
+			unit, pdu = frame[0], frame[1:6]
+		} else {
+			header = make([]byte, 7)
+			if _, err := io.ReadFull(conn, header); err != nil {
+				return
+			}
+			pdu = make([]byte, binary.BigEndian.Uint16(header[4:])-1)
+			if _, err := io.ReadFull(conn, pdu); err != nil {
+				return
+			}
+			unit = header[6]
+		}
+
+		respond := s.respond
+		if respond == nil {
+			respond = s.readHoldingRegisters
+		}
+		resp := respond(unit, pdu)
+		if resp == nil {
+			continue
+		}
+
+		var out []byte
+		if s.rtuFraming {
+			out = rtuFrame(append([]byte{unit}, resp...))
+		} else {
+			out = append(out, header[:4]...)
+			out = binary.BigEndian.AppendUint16(out, uint16(len(resp)+1))
+			out = append(out, unit)
+			out = append(out, resp...)
+		}
+		if _, err := conn.Write(out); err != nil {
+			return
+		}
+	}
+}
+
+// readHoldingRegisters answers FC03 from the unit's register map.
+func (s *tcpStandIn) readHoldingRegisters(unit byte, pdu []byte) []byte {
+	registers, ok := s.units[unit]
+	if !ok || pdu[0] != 0x03 {
+		return nil // Unknown unit: no answer, like a gateway with a dead slave
+	}
+	start := binary.BigEndian.Uint16(pdu[1:])
+	count := binary.BigEndian.Uint16(pdu[3:])
+	resp := []byte{0x03, byte(count * 2)}
+	for i := uint16(0); i < count; i++ {
+		resp = binary.BigEndian.AppendUint16(resp, registers[start+i])
+	}
+	return resp
+}
+
+func gatewayDevice(id, host string, port int, unit uint8, framing domain.ModbusFraming, shared bool) *domain.Device {
+	return &domain.Device{
+		ID:       id,
+		Protocol: domain.ProtocolModbusTCP,
+		Connection: domain.ConnectionConfig{
+			Host:            host,
+			Port:            port,
+			SlaveID:         unit,
+			Timeout:         500 * time.Millisecond,
+			RetryCount:      1,
+			Framing:         framing,
+			ShareConnection: shared,
+		},
+	}
+}
+
+var gatewayTestTag = &domain.Tag{ID: "t", Address: 0, RegisterType: domain.RegisterTypeHoldingRegister, DataType: domain.DataTypeUInt16, ScaleFactor: 1, RegisterCount: 1}
+
+func TestGateway_SharedConnectionMultiplexesUnitIDs(t *testing.T) {
+	standIn := newTCPStandIn(t, map[byte]map[uint16]uint16{1: {0: 101}, 2: {0: 202}, 3: {0: 303}}, false)
+	host, port := standIn.host()
+
+	pool := NewConnectionPool(DefaultPoolConfig(), zerolog.Nop(), nil)
+	defer pool.Close()
+
+	for unit := uint8(1); unit <= 3; unit++ {
+		device := gatewayDevice("unit-"+string('0'+unit), host, port, unit, "", true)
+		dp, err := pool.ReadTag(context.Background(), device, gatewayTestTag)
+		if err != nil {
+			t.Fatalf("unit %d: ReadTag: %v", unit, err)
+		}
+		if want := uint16(unit) * 101; dp.Value != want {
+			t.Errorf("unit %d: expected %d, got %v", unit, want, dp.Value)
+		}
+	}
+
+	if n := standIn.connections.Load(); n != 1 {
+		t.Errorf("expected 1 shared TCP connection, got %d", n)
+	}
+}
+
+func TestGateway_DedicatedConnectionsWithoutSharing(t *testing.T) {
+	standIn := newTCPStandIn(t, map[byte]map[uint16]uint16{1: {0: 1}, 2: {0: 2}}, false)
+	host, port := standIn.host()
+
+	pool := NewConnectionPool(DefaultPoolConfig(), zerolog.Nop(), nil)
+	defer pool.Close()
+
+	for unit := uint8(1); unit <= 2; unit++ {
+		device := gatewayDevice("unit-"+string('0'+unit), host, port, unit, "", false)
+		if _, err := pool.ReadTag(context.Background(), device, gatewayTestTag); err != nil {
+			t.Fatalf("unit %d: ReadTag: %v", unit, err)
+		}
+	}
+
+	if n := standIn.connections.Load(); n != 2 {
+		t.Errorf("expected 2 dedicated TCP connections, got %d", n)
+	}
+	if n := len(pool.GetBusStats()); n != 0 {
+		t.Errorf("expected no shared buses, got %d", n)
+	}
+}
+
+func TestGateway_RTUOverTCP(t *testing.T) {
+	standIn := newTCPStandIn(t, map[byte]map[uint16]uint16{5: {0: 555}, 6: {0: 666}}, true)
+	host, port := standIn.host()
+
+	pool := NewConnectionPool(DefaultPoolConfig(), zerolog.Nop(), nil)
+	defer pool.Close()
+
+	for _, unit := range []uint8{5, 6} {
+		device := gatewayDevice("rtu-"+string('0'+unit), host, port, unit, domain.ModbusFramingRTU, false)
+		dp, err := pool.ReadTag(context.Background(), device, gatewayTestTag)
+		if err != nil {
+			t.Fatalf("unit %d: ReadTag: %v", unit, err)
+		}
+		if want := uint16(unit) * 111; dp.Value != want {
+			t.Errorf("unit %d: expected %d, got %v", unit, want, dp.Value)
+		}
+	}
+
+	// RTU-over-TCP always shares: there is one serial line behind the converter
+	if n := standIn.connections.Load(); n != 1 {
+		t.Errorf("expected 1 converter connection, got %d", n)
+	}
+}
+
+func TestGateway_SharedConnectionRecoversFromSilentUnit(t *testing.T) {
+	standIn := newTCPStandIn(t, map[byte]map[uint16]uint16{1: {0: 11}}, false)
+	host, port := standIn.host()
+
+	pool := NewConnectionPool(DefaultPoolConfig(), zerolog.Nop(), nil)
+	defer pool.Close()
+
+	dead := gatewayDevice("dead", host, port, 9, "", true)
+	dead.Connection.Timeout = 100 * time.Millisecond
+	if _, err := pool.ReadTag(context.Background(), dead, gatewayTestTag); err == nil {
+		t.Fatal("expected timeout for unit without a slave")
+	}
+
+	dp, err := pool.ReadTag(context.Background(), gatewayDevice("alive", host, port, 1, "", true), gatewayTestTag)
+	if err != nil {
+		t.Fatalf("ReadTag after timeout: %v", err)
+	}
+	if dp.Value != uint16(11) {
+		t.Errorf("expected 11, got %v", dp.Value)
+	}
+}
+
+func TestGateway_BusyBusDoesNotBlockOtherDevices(t *testing.T) {
+	standIn := newTCPStandIn(t, map[byte]map[uint16]uint16{1: {0: 1}, 2: {0: 2}}, false)
+	host, port := standIn.host()
+
+	config := DefaultPoolConfig()
+	config.ConnectionTimeout = 2 * time.Second
+	pool := NewConnectionPool(config, zerolog.Nop(), nil)
+	defer pool.Close()
+
+	// A request hangs on the shared link, so connecting a device on it waits
+	shared := gatewayDevice("shared", host, port, 1, "", true)
+	pool.mu.Lock()
+	bus := pool.attachBus(ClientConfig{Address: fmt.Sprintf("%s:%d", host, port), SlaveID: 9, Protocol: domain.ProtocolModbusTCP})
+	pool.mu.Unlock()
+	bus.acquire(9)
+	connecting := make(chan error, 1)
+	go func() {
+		_, err := pool.GetClient(context.Background(), shared)
+		connecting <- err
+	}()
+	for {
+		pool.mu.RLock()
+		_, pending := pool.creating[shared.ID]
+		pool.mu.RUnlock()
+		if pending {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	start := time.Now()
+	if _, err := pool.GetClient(context.Background(), gatewayDevice("dedicated", host, port, 2, "", false)); err != nil {
+		t.Fatalf("GetClient: %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("unrelated device waited %v for the busy bus", elapsed)
+	}
+
+	bus.release()
+	if err := <-connecting; err != nil {
+		t.Errorf("shared device: %v", err)
+	}
+}
+
+func TestRTUResponseLayout(t *testing.T) {
+	if fixed, counted := rtuResponseLayout(0x03); fixed != 0 || !counted {
+		t.Errorf("FC03: expected counted response, got fixed=%d counted=%v", fixed, counted)
+	}
+	if fixed, _ := rtuResponseLayout(0x10); fixed != 6 {
+		t.Errorf("FC16: expected 6 trailing bytes, got %d", fixed)
+	}
+	if fixed, _ := rtuResponseLayout(0x83); fixed != 3 {
+		t.Errorf("exception: expected 3 trailing bytes, got %d", fixed)
+	}
+	if fixed, counted := rtuResponseLayout(0x2B); fixed != 0 || counted {
+		t.Errorf("FC43: expected unknown length, got fixed=%d counted=%v", fixed, counted)
+	}
+}
@@ -36,6 +36,9 @@ func (c *Client) recordTagError(tagID string, err error) {
 	diag.ErrorCount.Add(1)
 	diag.LastError.Store(err)
 	diag.LastErrorTime.Store(time.Now())
+	if c.metrics != nil {
+		c.metrics.RecordModbusTagError(c.deviceID, tagID)
+	}
 }
 
 // getOrCreateTagDiagnostic gets or creates a diagnostic tracker for a tag.
@@ -141,3 +144,16 @@ func (p *ConnectionPool) IsHealthy() bool {
 	defer p.mu.RUnlock()
 	return !p.closed
 }
+
+// GetBusStats returns statistics for every shared bus, keyed by serial port
+// path or host:port.
+func (p *ConnectionPool) GetBusStats() map[string]BusStats {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	result := make(map[string]BusStats, len(p.buses))
+	for _, bus := range p.buses {
+		result[bus.port] = bus.stats()
+	}
+	return result
+}
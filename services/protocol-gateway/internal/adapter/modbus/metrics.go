@@ -0,0 +1,151 @@
+// Package modbus provides Modbus request and batching metrics.
+package modbus
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/goburrow/modbus"
+	"github.com/nexus-edge/protocol-gateway/internal/domain"
+	"github.com/nexus-edge/protocol-gateway/internal/metrics"
+)
+
+// exceptionNames maps Modbus exception codes to metric label values.
+var exceptionNames = map[byte]string{
+	modbus.ExceptionCodeIllegalFunction:                    "illegal_function",
+	modbus.ExceptionCodeIllegalDataAddress:                 "illegal_data_address",
+	modbus.ExceptionCodeIllegalDataValue:                   "illegal_data_value",
+	modbus.ExceptionCodeServerDeviceFailure:                "slave_device_failure",
+	modbus.ExceptionCodeAcknowledge:                        "acknowledge",
+	modbus.ExceptionCodeServerDeviceBusy:                   "slave_device_busy",
+	modbus.ExceptionCodeMemoryParityError:                  "memory_parity_error",
+	modbus.ExceptionCodeGatewayPathUnavailable:             "gateway_path_unavailable",
+	modbus.ExceptionCodeGatewayTargetDeviceFailedToRespond: "gateway_target_failed",
+}
+
+// requestStatus classifies the result of a request for metrics: its status
+// and, for exception responses, the exception name.
+func requestStatus(err error) (string, string) {
+	if err == nil {
+		return "ok", ""
+	}
+	var mbErr *modbus.ModbusError
+	if errors.As(err, &mbErr) {
+		if name, ok := exceptionNames[mbErr.ExceptionCode]; ok {
+			return "exception", name
+		}
+		return "exception", fmt.Sprintf("code_%d", mbErr.ExceptionCode)
+	}
+	if isTimeout(err) {
+		return "timeout", ""
+	}
+	return "error", ""
+}
+
+// instrumentedClient records the latency and result of every request by
+// function code.
+type instrumentedClient struct {
+	modbus.Client
+
+	deviceID string
+	metrics  *metrics.Registry
+}
+
+func (c instrumentedClient) record(functionCode byte, start time.Time, err error) {
+	status, exception := requestStatus(err)
+	c.metrics.RecordModbusRequest(c.deviceID, fmt.Sprintf("%02d", functionCode), status, exception, time.Since(start).Seconds())
+}
+
+func (c instrumentedClient) ReadCoils(address, quantity uint16) ([]byte, error) {
+	start := time.Now()
+	results, err := c.Client.ReadCoils(address, quantity)
+	c.record(modbus.FuncCodeReadCoils, start, err)
+	return results, err
+}
+
+func (c instrumentedClient) ReadDiscreteInputs(address, quantity uint16) ([]byte, error) {
+	start := time.Now()
+	results, err := c.Client.ReadDiscreteInputs(address, quantity)
+	c.record(modbus.FuncCodeReadDiscreteInputs, start, err)
+	return results, err
+}
+
+func (c instrumentedClient) WriteSingleCoil(address, value uint16) ([]byte, error) {
+	start := time.Now()
+	results, err := c.Client.WriteSingleCoil(address, value)
+	c.record(modbus.FuncCodeWriteSingleCoil, start, err)
+	return results, err
+}
+
+func (c instrumentedClient) WriteMultipleCoils(address, quantity uint16, value []byte) ([]byte, error) {
+	start := time.Now()
+	results, err := c.Client.WriteMultipleCoils(address, quantity, value)
+	c.record(modbus.FuncCodeWriteMultipleCoils, start, err)
+	return results, err
+}
+
+func (c instrumentedClient) ReadInputRegisters(address, quantity uint16) ([]byte, error) {
+	start := time.Now()
+	results, err := c.Client.ReadInputRegisters(address, quantity)
+	c.record(modbus.FuncCodeReadInputRegisters, start, err)
+	return results, err
+}
+
+func (c instrumentedClient) ReadHoldingRegisters(address, quantity uint16) ([]byte, error) {
+	start := time.Now()
+	results, err := c.Client.ReadHoldingRegisters(address, quantity)
+	c.record(modbus.FuncCodeReadHoldingRegisters, start, err)
+	return results, err
+}
+
+func (c instrumentedClient) WriteSingleRegister(address, value uint16) ([]byte, error) {
+	start := time.Now()
+	results, err := c.Client.WriteSingleRegister(address, value)
+	c.record(modbus.FuncCodeWriteSingleRegister, start, err)
+	return results, err
+}
+
+func (c instrumentedClient) WriteMultipleRegisters(address, quantity uint16, value []byte) ([]byte, error) {
+	start := time.Now()
+	results, err := c.Client.WriteMultipleRegisters(address, quantity, value)
+	c.record(modbus.FuncCodeWriteMultipleRegisters, start, err)
+	return results, err
+}
+
+func (c instrumentedClient) ReadWriteMultipleRegisters(readAddress, readQuantity, writeAddress, writeQuantity uint16, value []byte) ([]byte, error) {
+	start := time.Now()
+	results, err := c.Client.ReadWriteMultipleRegisters(readAddress, readQuantity, writeAddress, writeQuantity, value)
+	c.record(modbus.FuncCodeReadWriteMultipleRegisters, start, err)
+	return results, err
+}
+
+func (c instrumentedClient) MaskWriteRegister(address, andMask, orMask uint16) ([]byte, error) {
+	start := time.Now()
+	results, err := c.Client.MaskWriteRegister(address, andMask, orMask)
+	c.record(modbus.FuncCodeMaskWriteRegister, start, err)
+	return results, err
+}
+
+func (c instrumentedClient) ReadFIFOQueue(address uint16) ([]byte, error) {
+	start := time.Now()
+	results, err := c.Client.ReadFIFOQueue(address)
+	c.record(modbus.FuncCodeReadFIFOQueue, start, err)
+	return results, err
+}
+
+// recordBatch records how many of the addresses read for a range are covered
+// by its tags. Tags sharing registers (bit tags) count once.
+func (c *Client) recordBatch(rng RegisterRange, regType domain.RegisterType) {
+	if c.metrics == nil {
+		return
+	}
+	covered := make(map[uint16]struct{})
+	for _, tag := range rng.Tags {
+		for i := uint16(0); i < tag.RegisterCount; i++ {
+			covered[tag.Address+i] = struct{}{}
+		}
+	}
+	read := int(rng.EndAddress) - int(rng.StartAddress) + 1
+	c.metrics.RecordModbusBatch(c.deviceID, string(regType), len(covered), read)
+}
@@ -0,0 +1,82 @@
+package modbus
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/goburrow/modbus"
+	"github.com/nexus-edge/protocol-gateway/internal/domain"
+	"github.com/nexus-edge/protocol-gateway/internal/metrics"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/rs/zerolog"
+)
+
+func TestRequestStatus_Classification(t *testing.T) {
+	tests := []struct {
+		err       error
+		status    string
+		exception string
+	}{
+		{nil, "ok", ""},
+		{&modbus.ModbusError{FunctionCode: 0x83, ExceptionCode: modbus.ExceptionCodeServerDeviceBusy}, "exception", "slave_device_busy"},
+		{&modbus.ModbusError{FunctionCode: 0x83, ExceptionCode: 0x0B}, "exception", "gateway_target_failed"},
+		{&modbus.ModbusError{FunctionCode: 0x83, ExceptionCode: 0x42}, "exception", "code_66"},
+		{errors.New("connection reset"), "error", ""},
+	}
+	for _, tt := range tests {
+		status, exception := requestStatus(tt.err)
+		if status != tt.status || exception != tt.exception {
+			t.Errorf("%v: got %s/%s, want %s/%s", tt.err, status, exception, tt.status, tt.exception)
+		}
+	}
+}
+
+func TestMetrics_RequestsAndBatchEfficiency(t *testing.T) {
+	registers := &registerStandIn{registers: map[uint16]uint16{0: 1, 3: 2}}
+	standIn := startTCPStandIn(t, &tcpStandIn{respond: registers.respond})
+	host, port := standIn.host()
+	device := gatewayDevice("metered", host, port, 1, "", false)
+
+	reg := metrics.NewRegistryWith(prometheus.NewRegistry())
+	pool := NewConnectionPool(DefaultPoolConfig(), zerolog.Nop(), reg)
+	defer pool.Close()
+
+	holding := []*domain.Tag{
+		writeTag(t, "a", domain.RegisterTypeHoldingRegister, domain.DataTypeUInt16, 0),
+		writeTag(t, "b", domain.RegisterTypeHoldingRegister, domain.DataTypeUInt16, 3),
+	}
+	if _, err := pool.ReadTags(context.Background(), device, holding); err != nil {
+		t.Fatalf("ReadTags: %v", err)
+	}
+	// The stand-in has no input registers: FC04 gets "illegal function"
+	input := writeTag(t, "pv", domain.RegisterTypeInputRegister, domain.DataTypeUInt16, 0)
+	if _, err := pool.ReadTags(context.Background(), device, []*domain.Tag{input}); err != nil {
+		t.Fatalf("ReadTags: %v", err)
+	}
+
+	checks := []struct {
+		name string
+		got  float64
+		want float64
+	}{
+		{"FC03 ok", testutil.ToFloat64(reg.ModbusRequestsTotal.WithLabelValues("metered", "03", "ok")), 1},
+		{"FC04 exception", testutil.ToFloat64(reg.ModbusRequestsTotal.WithLabelValues("metered", "04", "exception")), 1},
+		{"illegal function", testutil.ToFloat64(reg.ModbusExceptionsTotal.WithLabelValues("metered", "04", "illegal_function")), 1},
+		{"requested", testutil.ToFloat64(reg.ModbusRegistersRequested.WithLabelValues("metered", "holding_register")), 2},
+		{"read", testutil.ToFloat64(reg.ModbusRegistersRead.WithLabelValues("metered", "holding_register")), 4},
+	}
+	for _, c := range checks {
+		if c.got != c.want {
+			t.Errorf("%s: got %v, want %v", c.name, c.got, c.want)
+		}
+	}
+
+	if err := pool.RemoveClient("metered"); err != nil {
+		t.Fatalf("RemoveClient: %v", err)
+	}
+	if n := testutil.CollectAndCount(reg.ModbusRequestsTotal); n != 0 {
+		t.Errorf("expected device series removed, %d left", n)
+	}
+}
@@ -15,14 +15,17 @@ import (
 
 // ConnectionPool manages a pool of Modbus client connections.
 type ConnectionPool struct {
-	config  PoolConfig
-	clients map[string]*pooledClient
-	mu      sync.RWMutex
-	logger  zerolog.Logger
-	metrics *metrics.Registry
-	closed  bool
-	done    chan struct{} // Closed on shutdown to unblock background loops immediately
-	wg      sync.WaitGroup
+	config       PoolConfig
+	clients      map[string]*pooledClient
+	creating     map[string]*pendingClient      // Clients being connected, by device ID
+	buses        map[string]*sharedBus          // Shared serial lines and gateway connections
+	capabilities map[string]*deviceCapabilities // Per device, kept across idle reaping and MaxTTL recycling
+	mu           sync.RWMutex
+	logger       zerolog.Logger
+	metrics      *metrics.Registry
+	closed       bool
+	done         chan struct{} // Closed on shutdown to unblock background loops immediately
+	wg           sync.WaitGroup
 }
 
 // pooledClient wraps a Client with pool-specific metadata and per-device circuit breaker.
@@ -37,6 +40,16 @@ type pooledClient struct {
 	mu        sync.Mutex
 }
 
+// pendingClient is a client being created and connected. The pool lock is not
+// held while connecting, so a slow device or a busy shared bus cannot stall
+// other devices; concurrent GetClient calls for the same device wait for the
+// pending client instead of connecting a second time.
+type pendingClient struct {
+	done   chan struct{}
+	client *Client
+	err    error
+}
+
 // NewConnectionPool creates a new connection pool.
 func NewConnectionPool(config PoolConfig, logger zerolog.Logger, metricsReg *metrics.Registry) *ConnectionPool {
 	// Apply defaults - 500 to support industrial-scale deployments
@@ -54,11 +67,14 @@ func NewConnectionPool(config PoolConfig, logger zerolog.Logger, metricsReg *met
 	}
 
 	pool := &ConnectionPool{
-		config:  config,
-		clients: make(map[string]*pooledClient),
-		logger:  logger.With().Str("component", "modbus-pool").Logger(),
-		metrics: metricsReg,
-		done:    make(chan struct{}),
+		config:       config,
+		clients:      make(map[string]*pooledClient),
+		creating:     make(map[string]*pendingClient),
+		buses:        make(map[string]*sharedBus),
+		capabilities: make(map[string]*deviceCapabilities),
+		logger:       logger.With().Str("component", "modbus-pool").Logger(),
+		metrics:      metricsReg,
+		done:         make(chan struct{}),
 	}
 
 	// Start background health checker
@@ -124,63 +140,108 @@ func (p *ConnectionPool) createCircuitBreaker(device *domain.Device) *gobreaker.
 // GetClient retrieves or creates a client for the given device.
 func (p *ConnectionPool) GetClient(ctx context.Context, device *domain.Device) (*Client, error) {
 	p.mu.Lock()
-	defer p.mu.Unlock()
-
 	if p.closed {
+		p.mu.Unlock()
 		return nil, domain.ErrServiceStopped
 	}
 
 	// Check if we already have a client for this device
 	if pc, exists := p.clients[device.ID]; exists {
-		pc.mu.Lock()
-		defer pc.mu.Unlock()
-
-		if pc.client.IsConnected() {
-			return pc.client, nil
-		}
+		p.mu.Unlock()
+		return p.reconnectClient(ctx, pc)
+	}
 
-		// Try to reconnect
-		start := time.Now()
-		err := pc.client.Connect(ctx)
-		if p.metrics != nil {
-			p.metrics.RecordConnectionForProtocol(string(device.Protocol), err == nil, time.Since(start).Seconds())
-		}
-		if err != nil {
-			pc.lastError = err
-			return nil, err
+	// Wait for a client another caller is creating
+	if pending, exists := p.creating[device.ID]; exists {
+		p.mu.Unlock()
+		select {
+		case <-pending.done:
+			return pending.client, pending.err
+		case <-ctx.Done():
+			return nil, ctx.Err()
 		}
-		return pc.client, nil
 	}
 
 	// Check pool capacity
-	if len(p.clients) >= p.config.MaxConnections {
+	if len(p.clients)+len(p.creating) >= p.config.MaxConnections {
+		p.mu.Unlock()
 		return nil, domain.ErrPoolExhausted
 	}
 
-	// Create new client
+	pending := &pendingClient{done: make(chan struct{})}
+	p.creating[device.ID] = pending
+	p.mu.Unlock()
+
+	// Create new client without holding the pool lock
 	client, err := p.createClient(ctx, device)
-	if err != nil {
-		return nil, err
+
+	p.mu.Lock()
+	removed := p.creating[device.ID] != pending
+	if !removed {
+		delete(p.creating, device.ID)
+	}
+	if err == nil && (p.closed || removed) {
+		// Pool closed or device removed while connecting
+		bus := p.detachBus(client.config)
+		closed := p.closed
+		p.mu.Unlock()
+		_ = client.Disconnect()
+		p.closeBus(bus)
+		client, err = nil, domain.ErrDeviceNotFound
+		if closed {
+			err = domain.ErrServiceStopped
+		}
+	} else {
+		if err == nil {
+			p.clients[device.ID] = &pooledClient{
+				client:    client,
+				device:    device,
+				breaker:   p.createCircuitBreaker(device),
+				createdAt: time.Now(),
+			}
+			p.logger.Info().
+				Str("device_id", device.ID).
+				Int("pool_size", len(p.clients)).
+				Msg("Created new Modbus client with per-device circuit breaker")
+		}
+		p.mu.Unlock()
 	}
 
-	p.clients[device.ID] = &pooledClient{
-		client:    client,
-		device:    device,
-		breaker:   p.createCircuitBreaker(device),
-		createdAt: time.Now(),
+	pending.client, pending.err = client, err
+	close(pending.done)
+	return client, err
+}
+
+// reconnectClient returns a pooled client, reconnecting it if its connection
+// dropped. Only the client's own lock serializes connection attempts.
+func (p *ConnectionPool) reconnectClient(ctx context.Context, pc *pooledClient) (*Client, error) {
+	if pc.client.IsConnected() {
+		return pc.client, nil
 	}
 
-	p.logger.Info().
-		Str("device_id", device.ID).
-		Int("pool_size", len(p.clients)).
-		Msg("Created new Modbus client with per-device circuit breaker")
+	connectCtx, cancel := context.WithTimeout(ctx, p.config.ConnectionTimeout)
+	defer cancel()
 
-	return client, nil
+	start := time.Now()
+	err := pc.client.Connect(connectCtx)
+	if p.metrics != nil {
+		p.metrics.RecordConnectionForProtocol(string(pc.device.Protocol), err == nil, time.Since(start).Seconds())
+	}
+	if err != nil {
+		pc.mu.Lock()
+		pc.lastError = err
+		pc.mu.Unlock()
+		return nil, err
+	}
+	return pc.client, nil
 }
 
 // createClient creates a new Modbus client for the device.
 func (p *ConnectionPool) createClient(ctx context.Context, device *domain.Device) (*Client, error) {
 	address := fmt.Sprintf("%s:%d", device.Connection.Host, device.Connection.Port)
+	if device.Protocol == domain.ProtocolModbusRTU {
+		address = device.Connection.SerialPort
+	}
 
 	clientConfig := ClientConfig{
 		Address:     address,
@@ -190,7 +251,18 @@ func (p *ConnectionPool) createClient(ctx context.Context, device *domain.Device
 		MaxRetries:  device.Connection.RetryCount,
 		RetryDelay:  device.Connection.RetryDelay,
 		Protocol:    device.Protocol,
+		BaudRate:    device.Connection.BaudRate,
+		DataBits:    device.Connection.DataBits,
+		Parity:      device.Connection.Parity,
+		StopBits:    device.Connection.StopBits,
+		Framing:     device.Connection.Framing,
+	}
+
+	batching, err := device.Connection.ModbusBatchingSettings()
+	if err != nil {
+		return nil, err
 	}
+	clientConfig.Batching = batching
 
 	// Apply defaults
 	if clientConfig.Timeout == 0 {
@@ -203,10 +275,32 @@ func (p *ConnectionPool) createClient(ctx context.Context, device *domain.Device
 		clientConfig.RetryDelay = p.config.RetryDelay
 	}
 
+	// Devices on the same serial port, behind the same RTU-over-TCP converter, or
+	// opted into gateway sharing use one bus (one handle, one request at a time)
+	if device.Protocol == domain.ProtocolModbusRTU {
+		applySerialDefaults(&clientConfig)
+	}
+	if device.Protocol == domain.ProtocolModbusRTU ||
+		device.Connection.Framing == domain.ModbusFramingRTU ||
+		device.Connection.ShareConnection {
+		p.mu.Lock()
+		clientConfig.bus = p.attachBus(clientConfig)
+		p.mu.Unlock()
+	}
+
 	client, err := NewClient(device.ID, clientConfig, p.logger)
 	if err != nil {
+		p.releaseBus(clientConfig)
 		return nil, err
 	}
+	p.mu.Lock()
+	if caps, ok := p.capabilities[device.ID]; ok {
+		client.capabilities = caps
+	} else {
+		p.capabilities[device.ID] = client.capabilities
+	}
+	p.mu.Unlock()
+	client.metrics = p.metrics
 
 	// Connect with timeout
 	connectCtx, cancel := context.WithTimeout(ctx, p.config.ConnectionTimeout)
@@ -218,12 +312,64 @@ func (p *ConnectionPool) createClient(ctx context.Context, device *domain.Device
 		p.metrics.RecordConnectionForProtocol(string(device.Protocol), err == nil, time.Since(start).Seconds())
 	}
 	if err != nil {
+		p.releaseBus(clientConfig)
 		return nil, err
 	}
 
 	return client, nil
 }
 
+// attachBus returns the shared bus for a client's link, creating it on first
+// use. Caller must hold p.mu.
+func (p *ConnectionPool) attachBus(config ClientConfig) *sharedBus {
+	key := busKey(config)
+	bus, exists := p.buses[key]
+	if !exists {
+		bus = newSharedBus(config, p.logger, p.metrics)
+		p.buses[key] = bus
+		p.logger.Info().Str("bus", key).Msg("Opened shared Modbus bus")
+	}
+	bus.attach(config)
+	return bus
+}
+
+// detachBus releases a client's reference on its bus and drops the bus from
+// the pool once its last device is gone. The dropped bus is returned for
+// closeBus, which the caller runs after releasing p.mu: closing waits for the
+// request on the wire. Caller must hold p.mu.
+func (p *ConnectionPool) detachBus(config ClientConfig) *sharedBus {
+	if config.bus == nil || !config.bus.detach(config.SlaveID) {
+		return nil
+	}
+	delete(p.buses, config.bus.key)
+	return config.bus
+}
+
+// releaseBus detaches a client that never joined the pool from its bus.
+func (p *ConnectionPool) releaseBus(config ClientConfig) {
+	p.mu.Lock()
+	bus := p.detachBus(config)
+	p.mu.Unlock()
+	p.closeBus(bus)
+}
+
+// closeBus closes the link of a bus dropped by detachBus and removes its
+// metric series. Must be called without p.mu held.
+func (p *ConnectionPool) closeBus(bus *sharedBus) {
+	if bus == nil {
+		return
+	}
+	bus.close()
+
+	p.mu.RLock()
+	_, reopened := p.buses[bus.key]
+	p.mu.RUnlock()
+	if p.metrics != nil && !reopened {
+		p.metrics.RemoveModbusBus(bus.port)
+	}
+	p.logger.Info().Str("bus", bus.key).Msg("Closed shared Modbus bus")
+}
+
 // ReadTags reads multiple tags from a device using the pooled connection.
 // Uses per-device circuit breaker for fault isolation.
 func (p *ConnectionPool) ReadTags(ctx context.Context, device *domain.Device, tags []*domain.Tag) ([]*domain.DataPoint, error) {
@@ -451,21 +597,31 @@ func (p *ConnectionPool) WriteMultipleCoils(ctx context.Context, device *domain.
 // RemoveClient removes a client from the pool and closes its connection.
 func (p *ConnectionPool) RemoveClient(deviceID string) error {
 	p.mu.Lock()
-	defer p.mu.Unlock()
-
 	pc, exists := p.clients[deviceID]
 	if !exists {
-		return domain.ErrDeviceNotFound
+		// A client still connecting is discarded by its creator
+		_, creating := p.creating[deviceID]
+		delete(p.creating, deviceID)
+		delete(p.capabilities, deviceID)
+		p.mu.Unlock()
+		if !creating {
+			return domain.ErrDeviceNotFound
+		}
+		return nil
 	}
-
-	pc.mu.Lock()
-	defer pc.mu.Unlock()
+	delete(p.clients, deviceID)
+	delete(p.capabilities, deviceID)
+	bus := p.detachBus(pc.client.config)
+	p.mu.Unlock()
 
 	if err := pc.client.Disconnect(); err != nil {
 		p.logger.Warn().Err(err).Str("device_id", deviceID).Msg("Error disconnecting client")
 	}
+	p.closeBus(bus)
 
-	delete(p.clients, deviceID)
+	if p.metrics != nil {
+		p.metrics.RemoveModbusDevice(deviceID)
+	}
 	p.logger.Info().Str("device_id", deviceID).Msg("Removed client from pool")
 
 	return nil
@@ -482,19 +638,27 @@ func (p *ConnectionPool) Close() error {
 	p.wg.Wait()
 
 	p.mu.Lock()
-	defer p.mu.Unlock()
+	clients := p.clients
+	p.clients = make(map[string]*pooledClient)
+	buses := make([]*sharedBus, 0)
+	for _, pc := range clients {
+		if bus := p.detachBus(pc.client.config); bus != nil {
+			buses = append(buses, bus)
+		}
+	}
+	p.mu.Unlock()
 
 	var lastErr error
-	for deviceID, pc := range p.clients {
-		pc.mu.Lock()
+	for deviceID, pc := range clients {
 		if err := pc.client.Disconnect(); err != nil {
 			lastErr = err
 			p.logger.Warn().Err(err).Str("device_id", deviceID).Msg("Error closing client")
 		}
-		pc.mu.Unlock()
+	}
+	for _, bus := range buses {
+		p.closeBus(bus)
 	}
 
-	p.clients = make(map[string]*pooledClient)
 	p.logger.Info().Msg("Connection pool closed")
 
 	return lastErr
@@ -530,6 +694,7 @@ func (p *ConnectionPool) healthCheckLoop() {
 			}
 
 			p.publishActiveConnectionMetrics()
+			p.publishBusMetrics()
 		}
 	}
 }
@@ -542,12 +707,25 @@ func (p *ConnectionPool) publishActiveConnectionMetrics() {
 	counts := make(map[domain.Protocol]int)
 
 	p.mu.RLock()
-	for _, pc := range p.clients {
+	for deviceID, pc := range p.clients {
 		pc.mu.Lock()
 		connected := pc.client.IsConnected()
 		protocol := pc.device.Protocol
 		pc.mu.Unlock()
 
+		// Per-device metrics
+		p.metrics.RecordModbusDeviceConnected(deviceID, connected)
+
+		// Circuit breaker state: 0=closed, 1=half-open, 2=open
+		breakerState := 0
+		switch pc.breaker.State() {
+		case gobreaker.StateHalfOpen:
+			breakerState = 1
+		case gobreaker.StateOpen:
+			breakerState = 2
+		}
+		p.metrics.RecordModbusBreakerState(deviceID, breakerState)
+
 		if !connected {
 			continue
 		}
@@ -567,36 +745,38 @@ func (p *ConnectionPool) publishActiveConnectionMetrics() {
 	}
 }
 
+// publishBusMetrics samples utilization and queue depth for every shared bus.
+func (p *ConnectionPool) publishBusMetrics() {
+	p.mu.RLock()
+	buses := make([]*sharedBus, 0, len(p.buses))
+	for _, bus := range p.buses {
+		buses = append(buses, bus)
+	}
+	p.mu.RUnlock()
+
+	for _, bus := range buses {
+		utilization := bus.sampleUtilization()
+		if p.metrics != nil {
+			p.metrics.UpdateModbusBusStats(bus.port, utilization, bus.queueDepth())
+		}
+	}
+}
+
 // checkClientHealth checks and potentially reconnects a client.
 func (p *ConnectionPool) checkClientHealth(deviceID string) {
 	p.mu.RLock()
 	pc, exists := p.clients[deviceID]
 	p.mu.RUnlock()
 
-	if !exists {
+	if !exists || pc.client.IsConnected() {
 		return
 	}
 
-	pc.mu.Lock()
-	defer pc.mu.Unlock()
-
-	if !pc.client.IsConnected() {
-		p.logger.Debug().Str("device_id", deviceID).Msg("Client disconnected, attempting reconnect")
-
-		ctx, cancel := context.WithTimeout(context.Background(), p.config.ConnectionTimeout)
-		defer cancel()
-
-		start := time.Now()
-		err := pc.client.Connect(ctx)
-		if p.metrics != nil {
-			p.metrics.RecordConnectionForProtocol(string(pc.device.Protocol), err == nil, time.Since(start).Seconds())
-		}
-		if err != nil {
-			pc.lastError = err
-			p.logger.Warn().Err(err).Str("device_id", deviceID).Msg("Failed to reconnect client")
-		} else {
-			p.logger.Info().Str("device_id", deviceID).Msg("Client reconnected")
-		}
+	p.logger.Debug().Str("device_id", deviceID).Msg("Client disconnected, attempting reconnect")
+	if _, err := p.reconnectClient(context.Background(), pc); err != nil {
+		p.logger.Warn().Err(err).Str("device_id", deviceID).Msg("Failed to reconnect client")
+	} else {
+		p.logger.Info().Str("device_id", deviceID).Msg("Client reconnected")
 	}
 }
 
@@ -627,24 +807,39 @@ func (p *ConnectionPool) idleReaperLoop() {
 // reapIdleConnections closes connections that have been idle too long
 // or have exceeded their MaxTTL lifetime.
 func (p *ConnectionPool) reapIdleConnections() {
-	p.mu.Lock()
-	defer p.mu.Unlock()
+	// Check the clients without holding the pool lock: LastUsed waits for a
+	// connection attempt in progress
+	p.mu.RLock()
+	clients := make(map[string]*pooledClient, len(p.clients))
+	for deviceID, pc := range p.clients {
+		clients[deviceID] = pc
+	}
+	p.mu.RUnlock()
 
 	now := time.Now()
-	for deviceID, pc := range p.clients {
-		pc.mu.Lock()
+	for deviceID, pc := range clients {
 		idle := now.Sub(pc.client.LastUsed()) > p.config.IdleTimeout
 		expired := p.config.MaxTTL > 0 && now.Sub(pc.createdAt) > p.config.MaxTTL
-		if idle || expired {
-			reason := "idle"
-			if expired {
-				reason = "max_ttl"
-			}
-			p.logger.Debug().Str("device_id", deviceID).Str("reason", reason).Msg("Closing connection")
-			pc.client.Disconnect()
-			delete(p.clients, deviceID)
+		if !idle && !expired {
+			continue
 		}
-		pc.mu.Unlock()
+
+		p.mu.Lock()
+		if p.clients[deviceID] != pc {
+			p.mu.Unlock()
+			continue // Removed or replaced meanwhile
+		}
+		delete(p.clients, deviceID)
+		bus := p.detachBus(pc.client.config)
+		p.mu.Unlock()
+
+		reason := "idle"
+		if expired {
+			reason = "max_ttl"
+		}
+		p.logger.Debug().Str("device_id", deviceID).Str("reason", reason).Msg("Closing connection")
+		pc.client.Disconnect()
+		p.closeBus(bus)
 	}
 }
 
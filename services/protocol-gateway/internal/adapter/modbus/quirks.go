@@ -0,0 +1,193 @@
+// Package modbus provides per-device batching overrides and quirk handling.
+package modbus
+
+import (
+	"sync"
+	"time"
+
+	"github.com/goburrow/modbus"
+)
+
+// batchConfig returns the register batching settings for this device: the
+// defaults with the device's profile and overrides applied.
+func (c *Client) batchConfig() BatchConfig {
+	config := DefaultBatchConfig()
+	b := c.config.Batching
+	if b.MaxRegisters > 0 {
+		config.MaxRegistersPerRead = b.MaxRegisters
+	}
+	if b.MaxGap > 0 {
+		config.MaxGapSize = b.MaxGap
+	}
+	if b.NoGap {
+		config.MaxGapSize = 0
+	}
+	config.Alignment = b.Alignment
+	return config
+}
+
+// coilBatchConfig returns the coil/discrete input batching settings for this
+// device.
+func (c *Client) coilBatchConfig() CoilBatchConfig {
+	config := DefaultCoilBatchConfig()
+	b := c.config.Batching
+	if b.MaxCoils > 0 {
+		config.MaxCoilsPerRead = b.MaxCoils
+	}
+	if b.MaxGap > 0 {
+		config.MaxGapSize = b.MaxGap
+	}
+	if b.NoGap {
+		config.MaxGapSize = 0
+	}
+	config.Alignment = b.Alignment
+	return config
+}
+
+// writeLimits returns the registers and coils one write frame may carry: the
+// FC16/FC15 limits, lowered to the device's read block size when set.
+func (c *Client) writeLimits() (int, int) {
+	registers, coils := MaxWriteRegisters, MaxWriteCoils
+	if b := c.config.Batching; b.MaxRegisters > 0 && int(b.MaxRegisters) < registers {
+		registers = int(b.MaxRegisters)
+	}
+	if b := c.config.Batching; b.MaxCoils > 0 && int(b.MaxCoils) < coils {
+		coils = int(b.MaxCoils)
+	}
+	return registers, coils
+}
+
+// addressOffset returns how far tag addresses sit above wire addresses: 1
+// with one-based addressing, otherwise 0.
+func (c *Client) addressOffset() uint16 {
+	if c.config.Batching.OneBasedAddressing {
+		return 1
+	}
+	return 0
+}
+
+// alignRange widens [start, end] so it starts on a multiple of alignment and
+// spans a whole number of alignment units. An alignment of 0 or 1 is a no-op.
+// Alignment applies to wire addresses, so start and end are shifted down by
+// offset first and back up after.
+func alignRange(start, end, alignment, offset uint16) (uint16, uint16) {
+	if alignment <= 1 {
+		return start, end
+	}
+	start, end = start-offset, end-offset
+	alignedStart := start - start%alignment
+	alignedEnd := int(end)
+	if rem := (alignedEnd - int(alignedStart) + 1) % int(alignment); rem != 0 {
+		alignedEnd += int(alignment) - rem
+	}
+	if limit := 0xFFFF - int(offset); alignedEnd > limit {
+		alignedEnd = limit
+	}
+	return alignedStart + offset, uint16(alignedEnd) + offset
+}
+
+// alignedSize returns the number of addresses a read of [start, end] covers
+// once aligned.
+func alignedSize(start, end, alignment, offset uint16) int {
+	alignedStart, alignedEnd := alignRange(start, end, alignment, offset)
+	return int(alignedEnd) - int(alignedStart) + 1
+}
+
+// alignRanges widens every range to the alignment.
+func alignRanges(ranges []RegisterRange, alignment, offset uint16) []RegisterRange {
+	for i := range ranges {
+		ranges[i].StartAddress, ranges[i].EndAddress = alignRange(ranges[i].StartAddress, ranges[i].EndAddress, alignment, offset)
+	}
+	return ranges
+}
+
+// pacedHandler enforces a minimum pause between consecutive requests, for
+// devices and gateways that drop or garble back-to-back requests.
+type pacedHandler struct {
+	transportHandler
+
+	delay    time.Duration
+	mu       sync.Mutex
+	lastSend time.Time
+}
+
+// Send transmits a request once the inter-request delay has elapsed.
+func (h *pacedHandler) Send(aduRequest []byte) ([]byte, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if wait := h.delay - time.Since(h.lastSend); wait > 0 {
+		time.Sleep(wait)
+	}
+	aduResponse, err := h.transportHandler.Send(aduRequest)
+	h.lastSend = time.Now()
+	return aduResponse, err
+}
+
+// oneBasedClient translates the 1-based register numbers used in tag
+// configuration to the 0-based protocol addresses sent on the wire.
+// Every addressed function code is shifted by one.
+type oneBasedClient struct {
+	modbus.Client
+}
+
+func (c oneBasedClient) ReadCoils(address, quantity uint16) ([]byte, error) {
+	return c.Client.ReadCoils(address-1, quantity)
+}
+
+func (c oneBasedClient) ReadDiscreteInputs(address, quantity uint16) ([]byte, error) {
+	return c.Client.ReadDiscreteInputs(address-1, quantity)
+}
+
+func (c oneBasedClient) WriteSingleCoil(address, value uint16) ([]byte, error) {
+	return c.Client.WriteSingleCoil(address-1, value)
+}
+
+func (c oneBasedClient) WriteMultipleCoils(address, quantity uint16, value []byte) ([]byte, error) {
+	return c.Client.WriteMultipleCoils(address-1, quantity, value)
+}
+
+func (c oneBasedClient) ReadInputRegisters(address, quantity uint16) ([]byte, error) {
+	return c.Client.ReadInputRegisters(address-1, quantity)
+}
+
+func (c oneBasedClient) ReadHoldingRegisters(address, quantity uint16) ([]byte, error) {
+	return c.Client.ReadHoldingRegisters(address-1, quantity)
+}
+
+func (c oneBasedClient) WriteSingleRegister(address, value uint16) ([]byte, error) {
+	return c.Client.WriteSingleRegister(address-1, value)
+}
+
+func (c oneBasedClient) WriteMultipleRegisters(address, quantity uint16, value []byte) ([]byte, error) {
+	return c.Client.WriteMultipleRegisters(address-1, quantity, value)
+}
+
+func (c oneBasedClient) ReadWriteMultipleRegisters(readAddress, readQuantity, writeAddress, writeQuantity uint16, value []byte) ([]byte, error) {
+	return c.Client.ReadWriteMultipleRegisters(readAddress-1, readQuantity, writeAddress-1, writeQuantity, value)
+}
+
+func (c oneBasedClient) MaskWriteRegister(address, andMask, orMask uint16) ([]byte, error) {
+	return c.Client.MaskWriteRegister(address-1, andMask, orMask)
+}
+
+func (c oneBasedClient) ReadFIFOQueue(address uint16) ([]byte, error) {
+	return c.Client.ReadFIFOQueue(address - 1)
+}
+
+// newModbusClient builds the protocol client over handler, applying the
+// device's inter-request delay and addressing quirks and recording request
+// metrics.
+func (c *Client) newModbusClient(handler transportHandler) modbus.Client {
+	if c.config.Batching.InterRequestDelay > 0 {
+		handler = &pacedHandler{transportHandler: handler, delay: c.config.Batching.InterRequestDelay}
+	}
+	var client modbus.Client = modbus.NewClient(handler)
+	if c.metrics != nil {
+		client = instrumentedClient{Client: client, deviceID: c.deviceID, metrics: c.metrics}
+	}
+	if c.config.Batching.OneBasedAddressing {
+		return oneBasedClient{Client: client}
+	}
+	return client
+}
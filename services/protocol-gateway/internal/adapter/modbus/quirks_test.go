@@ -0,0 +1,180 @@
+package modbus
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/nexus-edge/protocol-gateway/internal/domain"
+	"github.com/rs/zerolog"
+)
+
+func TestBuildRanges_BatchingOverrides(t *testing.T) {
+	tag := func(address, count uint16) *domain.Tag {
+		return &domain.Tag{ID: "t", Address: address, RegisterCount: count}
+	}
+	tags := []*domain.Tag{tag(1, 1), tag(2, 2), tag(7, 1), tag(30, 4)}
+
+	tests := []struct {
+		name     string
+		batching domain.ModbusBatching
+		want     [][2]uint16
+	}{
+		{"defaults", domain.ModbusBatching{}, [][2]uint16{{1, 7}, {30, 33}}},
+		{"no gap", domain.ModbusBatching{NoGap: true}, [][2]uint16{{1, 3}, {7, 7}, {30, 33}}},
+		{"wide gap", domain.ModbusBatching{MaxGap: 30}, [][2]uint16{{1, 33}}},
+		{"small blocks", domain.ModbusBatching{MaxRegisters: 4, MaxGap: 30}, [][2]uint16{{1, 3}, {7, 7}, {30, 33}}},
+		{"aligned", domain.ModbusBatching{Alignment: 4, NoGap: true}, [][2]uint16{{0, 3}, {4, 7}, {28, 35}}},
+		{"aligned blocks", domain.ModbusBatching{Alignment: 4, MaxRegisters: 8}, [][2]uint16{{0, 7}, {28, 35}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := &Client{config: ClientConfig{Batching: tt.batching}}
+			var got [][2]uint16
+			for _, rng := range c.buildContiguousRanges(tags, c.batchConfig()) {
+				got = append(got, [2]uint16{rng.StartAddress, rng.EndAddress})
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ranges %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	c := &Client{config: ClientConfig{Batching: domain.ModbusBatching{MaxCoils: 16, Alignment: 8}}}
+	var got [][2]uint16
+	for _, rng := range c.buildCoilRanges(tags, c.coilBatchConfig()) {
+		got = append(got, [2]uint16{rng.StartAddress, rng.EndAddress})
+	}
+	if want := [][2]uint16{{0, 7}, {24, 39}}; !reflect.DeepEqual(got, want) {
+		t.Errorf("coil ranges %v, want %v", got, want)
+	}
+}
+
+func TestBatchingSettings_ProfileAndOverrides(t *testing.T) {
+	cc := domain.ConnectionConfig{
+		ModbusProfile:  "conservative",
+		ModbusBatching: &domain.ModbusBatching{MaxRegisters: 20, InterRequestDelay: 10 * time.Millisecond},
+	}
+	got, err := cc.ModbusBatchingSettings()
+	if err != nil {
+		t.Fatalf("ModbusBatchingSettings: %v", err)
+	}
+	want := domain.ModbusBatching{MaxRegisters: 20, MaxCoils: 256, NoGap: true, InterRequestDelay: 10 * time.Millisecond}
+	if got != want {
+		t.Errorf("got %+v, want %+v", got, want)
+	}
+
+	for _, bad := range []domain.ConnectionConfig{
+		{ModbusProfile: "unknown"},
+		{ModbusBatching: &domain.ModbusBatching{MaxRegisters: 126}},
+		{ModbusBatching: &domain.ModbusBatching{MaxRegisters: 4, Alignment: 8}},
+	} {
+		if _, err := bad.ModbusBatchingSettings(); !errors.Is(err, domain.ErrInvalidConfig) {
+			t.Errorf("%+v: expected ErrInvalidConfig, got %v", bad, err)
+		}
+	}
+}
+
+func TestBatchingQuirks_OneBasedAndPaced(t *testing.T) {
+	registers := &registerStandIn{registers: map[uint16]uint16{0: 10, 1: 11, 4: 14}}
+	standIn := startTCPStandIn(t, &tcpStandIn{respond: registers.respond})
+	host, port := standIn.host()
+	device := gatewayDevice("quirks", host, port, 1, "", false)
+	device.Connection.ModbusProfile = "one-based"
+	device.Connection.ModbusBatching = &domain.ModbusBatching{NoGap: true, InterRequestDelay: 40 * time.Millisecond}
+
+	tags := []*domain.Tag{
+		writeTag(t, "a", domain.RegisterTypeHoldingRegister, domain.DataTypeUInt16, 1),
+		writeTag(t, "b", domain.RegisterTypeHoldingRegister, domain.DataTypeUInt16, 2),
+		writeTag(t, "c", domain.RegisterTypeHoldingRegister, domain.DataTypeUInt16, 5),
+	}
+	device.Name, device.UNSPrefix, device.PollInterval = "Quirks", "plant/quirks", time.Second
+	device.Tags = []domain.Tag{*tags[0], *tags[1], *tags[2]}
+	if err := device.Validate(); err != nil {
+		t.Fatalf("validate: %v", err)
+	}
+
+	pool := NewConnectionPool(DefaultPoolConfig(), zerolog.Nop(), nil)
+	defer pool.Close()
+
+	start := time.Now()
+	points, err := pool.ReadTags(context.Background(), device, tags)
+	if err != nil {
+		t.Fatalf("ReadTags: %v", err)
+	}
+	if elapsed := time.Since(start); elapsed < 40*time.Millisecond {
+		t.Errorf("two reads took %v, expected the inter-request delay between them", elapsed)
+	}
+	got := map[string]interface{}{}
+	for _, dp := range points {
+		got[dp.TagID] = dp.Value
+	}
+	if want := map[string]interface{}{"a": uint16(10), "b": uint16(11), "c": uint16(14)}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+
+	if err := pool.WriteTag(context.Background(), device, tags[2], 99); err != nil {
+		t.Fatalf("WriteTag: %v", err)
+	}
+	registers.mu.Lock()
+	defer registers.mu.Unlock()
+	if registers.registers[4] != 99 || registers.requests[0x03] != 2 {
+		t.Errorf("expected register 4 written and 2 reads, got %v, %v", registers.registers, registers.requests)
+	}
+
+	device.Tags[0].Address = 0
+	if err := device.Validate(); !errors.Is(err, domain.ErrInvalidConfig) {
+		t.Errorf("expected address 0 to be rejected with one-based addressing, got %v", err)
+	}
+}
+
+func TestBatchingQuirks_AlignedOneBased(t *testing.T) {
+	registers := &registerStandIn{registers: map[uint16]uint16{0: 10, 1: 11, 3: 13}}
+	standIn := startTCPStandIn(t, &tcpStandIn{respond: registers.respond})
+	host, port := standIn.host()
+	device := gatewayDevice("aligned-one-based", host, port, 1, "", false)
+	device.Connection.ModbusProfile = "aligned"
+	device.Connection.ModbusBatching = &domain.ModbusBatching{OneBasedAddressing: true, NoGap: true}
+
+	tags := []*domain.Tag{
+		writeTag(t, "a", domain.RegisterTypeHoldingRegister, domain.DataTypeUInt16, 1),
+		writeTag(t, "b", domain.RegisterTypeHoldingRegister, domain.DataTypeUInt16, 4),
+	}
+	device.Name, device.UNSPrefix, device.PollInterval = "Aligned", "plant/aligned", time.Second
+	device.Tags = []domain.Tag{*tags[0], *tags[1]}
+	if err := device.Validate(); err != nil {
+		t.Fatalf("validate: %v", err)
+	}
+
+	batching, err := device.Connection.ModbusBatchingSettings()
+	if err != nil {
+		t.Fatalf("ModbusBatchingSettings: %v", err)
+	}
+	c := &Client{config: ClientConfig{Batching: batching}}
+	var got [][2]uint16
+	for _, rng := range c.buildContiguousRanges(tags, c.batchConfig()) {
+		got = append(got, [2]uint16{rng.StartAddress, rng.EndAddress})
+	}
+	// Wire addresses 0-1 and 2-3, in 1-based tag numbers.
+	if want := [][2]uint16{{1, 2}, {3, 4}}; !reflect.DeepEqual(got, want) {
+		t.Errorf("ranges %v, want %v", got, want)
+	}
+
+	pool := NewConnectionPool(DefaultPoolConfig(), zerolog.Nop(), nil)
+	defer pool.Close()
+
+	points, err := pool.ReadTags(context.Background(), device, tags)
+	if err != nil {
+		t.Fatalf("ReadTags: %v", err)
+	}
+	values := map[string]interface{}{}
+	for _, dp := range points {
+		values[dp.TagID] = dp.Value
+	}
+	if want := map[string]interface{}{"a": uint16(10), "b": uint16(13)}; !reflect.DeepEqual(values, want) {
+		t.Errorf("got %v, want %v", values, want)
+	}
+}
@@ -0,0 +1,198 @@
+// Package modbus provides the RTU-over-TCP transport for serial-to-Ethernet converters.
+package modbus
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/goburrow/modbus"
+)
+
+// rtuTCPQuietTime is how long the connection must stay silent before a response
+// of unknown length (e.g. FC43 Device Identification) is considered complete.
+// Converters forward a serial frame in one or a few TCP segments, so a short
+// quiet period is enough.
+const rtuTCPQuietTime = 50 * time.Millisecond
+
+// rtuOverTCPHandler sends RTU frames (slave address + PDU + CRC-16) over a raw
+// TCP connection, as expected by transparent serial-to-Ethernet converters
+// (Moxa NPort "TCP server", Lantronix "tunnel" modes, ...).
+// There is no MBAP header, so response boundaries are derived from the
+// function code like on a serial line.
+type rtuOverTCPHandler struct {
+	modbus.Packager // RTU framing and CRC with this device's slave ID
+
+	address string
+	timeout time.Duration
+
+	mu   sync.Mutex
+	conn net.Conn
+}
+
+// newRTUOverTCPHandler creates an RTU-over-TCP handler for config.Address (host:port).
+func newRTUOverTCPHandler(config ClientConfig) *rtuOverTCPHandler {
+	packager := modbus.NewRTUClientHandler(config.Address)
+	packager.SlaveId = config.SlaveID
+	return &rtuOverTCPHandler{
+		Packager: packager,
+		address:  config.Address,
+		timeout:  config.Timeout,
+	}
+}
+
+// Connect dials the converter if not already connected.
+func (h *rtuOverTCPHandler) Connect() error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.connectLocked()
+}
+
+func (h *rtuOverTCPHandler) connectLocked() error {
+	if h.conn != nil {
+		return nil
+	}
+	dialer := net.Dialer{Timeout: h.timeout}
+	conn, err := dialer.Dial("tcp", h.address)
+	if err != nil {
+		return err
+	}
+	h.conn = conn
+	return nil
+}
+
+// Close closes the TCP connection.
+func (h *rtuOverTCPHandler) Close() error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.closeLocked()
+}
+
+func (h *rtuOverTCPHandler) closeLocked() error {
+	if h.conn == nil {
+		return nil
+	}
+	err := h.conn.Close()
+	h.conn = nil
+	return err
+}
+
+// Send writes one RTU request frame and reads the matching response frame.
+// On any error the connection is dropped so a late or partial response can't
+// be mistaken for the answer to the next request; it is redialled lazily.
+func (h *rtuOverTCPHandler) Send(aduRequest []byte) ([]byte, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if err := h.connectLocked(); err != nil {
+		return nil, err
+	}
+
+	aduResponse, err := h.exchange(aduRequest)
+	if err != nil {
+		_ = h.closeLocked()
+		return nil, err
+	}
+	return aduResponse, nil
+}
+
+// exchange performs one request/response round trip. Caller must hold h.mu.
+func (h *rtuOverTCPHandler) exchange(aduRequest []byte) ([]byte, error) {
+	var deadline time.Time
+	if h.timeout > 0 {
+		deadline = time.Now().Add(h.timeout)
+	}
+	if err := h.conn.SetDeadline(deadline); err != nil {
+		return nil, err
+	}
+	if _, err := h.conn.Write(aduRequest); err != nil {
+		return nil, err
+	}
+
+	// Slave address + function code
+	response := make([]byte, 2, rtuMaxFrameSize)
+	if _, err := io.ReadFull(h.conn, response); err != nil {
+		return nil, err
+	}
+	if response[0] != aduRequest[0] {
+		return nil, fmt.Errorf("modbus: response slave id '%v' does not match request '%v'", response[0], aduRequest[0])
+	}
+
+	var remaining int
+	switch fixed, counted := rtuResponseLayout(response[1]); {
+	case counted:
+		var count [1]byte
+		if _, err := io.ReadFull(h.conn, count[:]); err != nil {
+			return nil, err
+		}
+		response = append(response, count[0])
+		remaining = int(count[0]) + 2 // Data + CRC
+	case fixed > 0:
+		remaining = fixed
+	default:
+		return h.readUntilQuiet(response)
+	}
+
+	if len(response)+remaining > rtuMaxFrameSize {
+		return nil, fmt.Errorf("modbus: response length %d exceeds RTU maximum %d", len(response)+remaining, rtuMaxFrameSize)
+	}
+	tail := make([]byte, remaining)
+	if _, err := io.ReadFull(h.conn, tail); err != nil {
+		return nil, err
+	}
+	return append(response, tail...), nil
+}
+
+// rtuResponseLayout describes the response frame that follows the slave address
+// and function code. counted means the next byte is a byte count followed by
+// that many data bytes and the CRC; otherwise fixed is the number of remaining
+// bytes, or 0 when the length can't be derived from the function code.
+func rtuResponseLayout(function byte) (fixed int, counted bool) {
+	if function&0x80 != 0 {
+		return 3, false // Exception code + CRC
+	}
+
+	switch function {
+	case modbus.FuncCodeReadCoils,
+		modbus.FuncCodeReadDiscreteInputs,
+		modbus.FuncCodeReadHoldingRegisters,
+		modbus.FuncCodeReadInputRegisters,
+		modbus.FuncCodeReadWriteMultipleRegisters:
+		return 0, true
+	case modbus.FuncCodeWriteSingleCoil,
+		modbus.FuncCodeWriteSingleRegister,
+		modbus.FuncCodeWriteMultipleCoils,
+		modbus.FuncCodeWriteMultipleRegisters:
+		return 6, false // Address + value/quantity + CRC
+	case modbus.FuncCodeMaskWriteRegister:
+		return 8, false // Address + AND mask + OR mask + CRC
+	default:
+		return 0, false
+	}
+}
+
+// readUntilQuiet reads until the converter stops sending for rtuTCPQuietTime.
+func (h *rtuOverTCPHandler) readUntilQuiet(response []byte) ([]byte, error) {
+	buf := make([]byte, rtuMaxFrameSize)
+	for len(response) < rtuMaxFrameSize {
+		if err := h.conn.SetReadDeadline(time.Now().Add(rtuTCPQuietTime)); err != nil {
+			return nil, err
+		}
+		n, err := h.conn.Read(buf[:rtuMaxFrameSize-len(response)])
+		response = append(response, buf[:n]...)
+		if err != nil {
+			var netErr net.Error
+			if errors.As(err, &netErr) && netErr.Timeout() {
+				break
+			}
+			return nil, err
+		}
+	}
+	if len(response) < 4 {
+		return nil, fmt.Errorf("modbus: response length '%v' does not meet minimum '4'", len(response))
+	}
+	return response, nil
+}
@@ -0,0 +1,177 @@
+//go:build linux
+
+package modbus
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"os"
+	"syscall"
+	"testing"
+	"time"
+	"unsafe"
+
+	"github.com/nexus-edge/protocol-gateway/internal/domain"
+	"github.com/rs/zerolog"
+)
+
+// openPTY opens a pseudo-terminal pair and returns the master and the slave device path.
+// The slave path is handed to the RTU client as its serial port; the test plays the
+// Modbus slave on the master side.
+func openPTY(t *testing.T) (*os.File, string) {
+	t.Helper()
+
+	master, err := os.OpenFile("/dev/ptmx", os.O_RDWR|syscall.O_NOCTTY, 0)
+	if err != nil {
+		t.Skipf("pseudo-terminals unavailable: %v", err)
+	}
+
+	var unlock int32
+	if _, _, errno := syscall.Syscall(syscall.SYS_IOCTL, master.Fd(), syscall.TIOCSPTLCK, uintptr(unsafe.Pointer(&unlock))); errno != 0 {
+		master.Close()
+		t.Skipf("unlockpt failed: %v", errno)
+	}
+	var ptn uint32
+	if _, _, errno := syscall.Syscall(syscall.SYS_IOCTL, master.Fd(), syscall.TIOCGPTN, uintptr(unsafe.Pointer(&ptn))); errno != 0 {
+		master.Close()
+		t.Skipf("ptsname failed: %v", errno)
+	}
+
+	t.Cleanup(func() { master.Close() })
+	return master, fmt.Sprintf("/dev/pts/%d", ptn)
+}
+
+// serveRTUSlave answers FC03 requests on the master side of the pty from a register map.
+// corruptCRC flips the response checksum to exercise CRC verification.
+func serveRTUSlave(master *os.File, slaveID byte, registers map[uint16]uint16, corruptCRC bool) {
+	serveRTUSlaves(master, map[byte]map[uint16]uint16{slaveID: registers}, corruptCRC)
+}
+
+// serveRTUSlaves plays several slaves on one multi-drop line; requests for
+// unknown slave IDs go unanswered, like a real bus.
+func serveRTUSlaves(master *os.File, slaves map[byte]map[uint16]uint16, corruptCRC bool) {
+	req := make([]byte, 8)
+	for {
+		if _, err := io.ReadFull(master, req); err != nil {
+			return
+		}
+		registers, ok := slaves[req[0]]
+		if !ok || crc16(req[:6]) != binary.LittleEndian.Uint16(req[6:]) || req[1] != 0x03 {
+			continue
+		}
+		slaveID := req[0]
+		start := binary.BigEndian.Uint16(req[2:])
+		count := binary.BigEndian.Uint16(req[4:])
+
+		resp := []byte{slaveID, 0x03, byte(count * 2)}
+		for i := uint16(0); i < count; i++ {
+			resp = binary.BigEndian.AppendUint16(resp, registers[start+i])
+		}
+		frame := rtuFrame(resp)
+		if corruptCRC {
+			frame[len(frame)-1] ^= 0xFF
+		}
+		if _, err := master.Write(frame); err != nil {
+			return
+		}
+	}
+}
+
+func newTestRTUClient(t *testing.T, port string) *Client {
+	t.Helper()
+	client, err := NewClient("rtu-test", ClientConfig{
+		Address:    port,
+		SlaveID:    7,
+		Timeout:    500 * time.Millisecond,
+		MaxRetries: 1,
+		RetryDelay: 10 * time.Millisecond,
+		Protocol:   domain.ProtocolModbusRTU,
+		BaudRate:   19200,
+	}, zerolog.Nop())
+	if err != nil {
+		t.Fatalf("NewClient: %v", err)
+	}
+	if err := client.Connect(context.Background()); err != nil {
+		t.Fatalf("Connect: %v", err)
+	}
+	t.Cleanup(func() { client.Disconnect() })
+	return client
+}
+
+func TestRTU_ReadHoldingRegistersOverPTY(t *testing.T) {
+	master, port := openPTY(t)
+	go serveRTUSlave(master, 7, map[uint16]uint16{10: 1234, 11: 0x4248, 12: 0x0000}, false)
+
+	client := newTestRTUClient(t, port)
+	if _, ok := client.handler.(*rtuHandler); !ok {
+		t.Fatalf("expected RTU transport, got %T", client.handler)
+	}
+
+	tags := []*domain.Tag{
+		{ID: "counter", Address: 10, RegisterType: domain.RegisterTypeHoldingRegister, DataType: domain.DataTypeUInt16, ByteOrder: domain.ByteOrderBigEndian, ScaleFactor: 1, RegisterCount: 1},
+		{ID: "power", Address: 11, RegisterType: domain.RegisterTypeHoldingRegister, DataType: domain.DataTypeFloat32, ByteOrder: domain.ByteOrderBigEndian, ScaleFactor: 1, RegisterCount: 2},
+	}
+	points, err := client.ReadTags(context.Background(), tags)
+	if err != nil {
+		t.Fatalf("ReadTags: %v", err)
+	}
+	if len(points) != 2 {
+		t.Fatalf("expected 2 data points, got %d", len(points))
+	}
+
+	values := make(map[string]interface{})
+	for _, dp := range points {
+		if dp.Quality != domain.QualityGood {
+			t.Fatalf("tag %s: expected good quality, got %s", dp.TagID, dp.Quality)
+		}
+		values[dp.TagID] = dp.Value
+	}
+	if values["counter"] != uint16(1234) {
+		t.Errorf("counter: expected 1234, got %v", values["counter"])
+	}
+	if values["power"] != float32(50) {
+		t.Errorf("power: expected 50, got %v", values["power"])
+	}
+}
+
+func TestRTU_BadCRCIsRejected(t *testing.T) {
+	master, port := openPTY(t)
+	go serveRTUSlave(master, 7, map[uint16]uint16{0: 1}, true)
+
+	client := newTestRTUClient(t, port)
+	tag := &domain.Tag{ID: "t", Address: 0, RegisterType: domain.RegisterTypeHoldingRegister, DataType: domain.DataTypeUInt16, ScaleFactor: 1, RegisterCount: 1}
+
+	dp, err := client.ReadTag(context.Background(), tag)
+	if err == nil {
+		t.Fatal("expected CRC error, got nil")
+	}
+	if dp == nil || dp.Quality == domain.QualityGood {
+		t.Errorf("expected bad-quality data point, got %+v", dp)
+	}
+}
+
+func TestRTU_NoResponseTimesOut(t *testing.T) {
+	_, port := openPTY(t)
+
+	client := newTestRTUClient(t, port)
+	tag := &domain.Tag{ID: "t", Address: 0, RegisterType: domain.RegisterTypeHoldingRegister, DataType: domain.DataTypeUInt16, ScaleFactor: 1, RegisterCount: 1}
+
+	_, err := client.ReadTag(context.Background(), tag)
+	if err == nil {
+		t.Fatal("expected timeout error, got nil")
+	}
+	if client.GetStatsStruct().RetryCount.Load() == 0 {
+		t.Error("expected serial timeout to be retried")
+	}
+}
+
+func TestRTUFrameGap(t *testing.T) {
+	if gap := rtuFrameGap(9600); gap < 4*time.Millisecond || gap > 4100*time.Microsecond {
+		t.Errorf("9600 baud: expected ~4.01ms, got %v", gap)
+	}
+	if gap := rtuFrameGap(115200); gap != 1750*time.Microsecond {
+		t.Errorf("115200 baud: expected fixed 1.75ms, got %v", gap)
+	}
+}
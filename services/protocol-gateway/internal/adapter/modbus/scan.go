@@ -0,0 +1,541 @@
+// Package modbus provides the register map probe and device identification
+// (FC 43/14) used to commission devices without a vendor register map.
+package modbus
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/goburrow/modbus"
+	"github.com/nexus-edge/protocol-gateway/internal/domain"
+	"github.com/sony/gobreaker"
+)
+
+// Modbus function code and MEI type of Read Device Identification.
+const (
+	funcCodeEncapsulatedInterface byte = 0x2B
+	meiTypeReadDeviceID           byte = 0x0E
+)
+
+// Read Device ID codes (access levels) of FC 43/14.
+const (
+	readDeviceIDBasic   byte = 0x01 // Objects 0x00-0x02 (mandatory)
+	readDeviceIDRegular byte = 0x02 // Objects 0x00-0x06
+)
+
+// maxDeviceIDRequests bounds the "more follows" continuation of FC 43/14.
+const maxDeviceIDRequests = 16
+
+// ScanConfig configures a register map probe.
+type ScanConfig struct {
+	// RegisterTypes are the tables to probe (default: all four)
+	RegisterTypes []domain.RegisterType
+
+	// Start and End are the inclusive address range probed in every table
+	// (default: 0-999)
+	Start uint16
+	End   uint16
+
+	// RegisterChunk is the number of holding/input registers per probe read
+	// (default and maximum: 125)
+	RegisterChunk uint16
+
+	// BitChunk is the number of coils/discrete inputs per probe read
+	// (default and maximum: 2000)
+	BitChunk uint16
+
+	// MaxRequests bounds the total number of reads of one scan, including
+	// bisection (default: 2000)
+	MaxRequests int
+
+	// Identify reads Device Identification (FC 43/14) before probing
+	Identify bool
+}
+
+// withDefaults returns the config with defaults applied and chunks capped at
+// the protocol limits.
+func (cfg ScanConfig) withDefaults() ScanConfig {
+	if len(cfg.RegisterTypes) == 0 {
+		cfg.RegisterTypes = []domain.RegisterType{
+			domain.RegisterTypeCoil,
+			domain.RegisterTypeDiscreteInput,
+			domain.RegisterTypeHoldingRegister,
+			domain.RegisterTypeInputRegister,
+		}
+	}
+	if cfg.Start == 0 && cfg.End == 0 {
+		cfg.End = 999
+	}
+	if cfg.RegisterChunk == 0 || cfg.RegisterChunk > MaxHoldingRegisters {
+		cfg.RegisterChunk = MaxHoldingRegisters
+	}
+	if cfg.BitChunk == 0 || cfg.BitChunk > MaxCoils {
+		cfg.BitChunk = MaxCoils
+	}
+	if cfg.MaxRequests <= 0 {
+		cfg.MaxRequests = 2000
+	}
+	return cfg
+}
+
+// ScanRange is a contiguous block of addresses that answered a read.
+type ScanRange struct {
+	RegisterType domain.RegisterType `json:"register_type"`
+	Start        uint16              `json:"start"`
+	End          uint16              `json:"end"` // Inclusive
+	Requests     int                 `json:"requests"`
+	AvgResponse  time.Duration       `json:"avg_response"`
+	MaxResponse  time.Duration       `json:"max_response"`
+
+	totalResponse time.Duration
+}
+
+// Count returns the number of addresses in the range.
+func (r ScanRange) Count() int {
+	return int(r.End) - int(r.Start) + 1
+}
+
+// DeviceIdentification holds the objects of Read Device Identification.
+type DeviceIdentification struct {
+	VendorName          string `json:"vendor_name,omitempty"`
+	ProductCode         string `json:"product_code,omitempty"`
+	Revision            string `json:"revision,omitempty"`
+	VendorURL           string `json:"vendor_url,omitempty"`
+	ProductName         string `json:"product_name,omitempty"`
+	ModelName           string `json:"model_name,omitempty"`
+	UserApplicationName string `json:"user_application_name,omitempty"`
+
+	// ConformityLevel is the identification level the device supports
+	ConformityLevel byte `json:"conformity_level"`
+
+	// Objects holds every object read, by object ID (including extended and
+	// private objects 0x80-0xFF)
+	Objects map[byte]string `json:"objects,omitempty"`
+}
+
+// ScanResult is the register map of a device found by a probe.
+type ScanResult struct {
+	Ranges []ScanRange `json:"ranges"`
+
+	// Unsupported are the tables the device rejected with "illegal function"
+	Unsupported []domain.RegisterType `json:"unsupported,omitempty"`
+
+	// Timeouts counts probe reads the device did not answer; their addresses
+	// are not bisected and are missing from Ranges
+	Timeouts int `json:"timeouts"`
+
+	// Truncated is set when MaxRequests stopped the scan early
+	Truncated bool `json:"truncated,omitempty"`
+
+	Identification *DeviceIdentification `json:"identification,omitempty"`
+
+	// IdentificationError is why Identification is missing, if it was requested
+	IdentificationError string `json:"identification_error,omitempty"`
+
+	Requests int           `json:"requests"`
+	Duration time.Duration `json:"duration"`
+}
+
+// DraftTags turns the readable ranges into disabled tags, one per address
+// (uint16 registers, bool coils and inputs), for review and completion before
+// they are enabled.
+func (r *ScanResult) DraftTags() []domain.Tag {
+	var tags []domain.Tag
+	for _, rng := range r.Ranges {
+		prefix, label, dataType := draftTagNaming(rng.RegisterType)
+		for addr := int(rng.Start); addr <= int(rng.End); addr++ {
+			id := fmt.Sprintf("%s_%d", prefix, addr)
+			tags = append(tags, domain.Tag{
+				ID:            id,
+				Name:          fmt.Sprintf("%s %d", label, addr),
+				Address:       uint16(addr),
+				RegisterType:  rng.RegisterType,
+				DataType:      dataType,
+				RegisterCount: 1,
+				ByteOrder:     domain.ByteOrderBigEndian,
+				ScaleFactor:   1.0,
+				TopicSuffix:   id,
+				AccessMode:    domain.AccessModeReadOnly,
+				Enabled:       false,
+			})
+		}
+	}
+	return tags
+}
+
+// draftTagNaming returns the ID prefix, name label and data type of draft tags.
+func draftTagNaming(regType domain.RegisterType) (string, string, domain.DataType) {
+	switch regType {
+	case domain.RegisterTypeCoil:
+		return "co", "Coil", domain.DataTypeBool
+	case domain.RegisterTypeDiscreteInput:
+		return "di", "Discrete input", domain.DataTypeBool
+	case domain.RegisterTypeInputRegister:
+		return "ir", "Input register", domain.DataTypeUInt16
+	default:
+		return "hr", "Holding register", domain.DataTypeUInt16
+	}
+}
+
+// errScanBudget stops a scan that reached ScanConfig.MaxRequests.
+var errScanBudget = errors.New("modbus: scan request budget exhausted")
+
+// probeOutcome classifies the result of one probe read.
+type probeOutcome int
+
+const (
+	probeReadable    probeOutcome = iota // Read succeeded
+	probeRejected                        // Exception for (part of) the addresses: bisect
+	probeUnsupported                     // Illegal function: the table does not exist
+	probeTimeout                         // No answer
+)
+
+// scanner holds the state of one scan.
+type scanner struct {
+	client *Client
+	cfg    ScanConfig
+	result *ScanResult
+}
+
+// Scan probes the register map of the device: every table in cfg is read in
+// chunks, chunks answered with an exception are bisected down to single
+// addresses, and contiguous readable addresses are merged into ranges with
+// their response timings. Reads bypass retries; a device that does not answer
+// an address range is counted in Timeouts rather than bisected, since every
+// unanswered read costs a full timeout.
+func (c *Client) Scan(ctx context.Context, cfg ScanConfig) (*ScanResult, error) {
+	if !c.connected.Load() {
+		return nil, domain.ErrConnectionClosed
+	}
+	cfg = cfg.withDefaults()
+	if cfg.End < cfg.Start {
+		return nil, fmt.Errorf("%w: scan range %d-%d", domain.ErrInvalidAddress, cfg.Start, cfg.End)
+	}
+
+	started := time.Now()
+	s := &scanner{client: c, cfg: cfg, result: &ScanResult{Ranges: []ScanRange{}}}
+
+	if cfg.Identify {
+		ident, err := c.ReadDeviceIdentification(ctx)
+		if err != nil {
+			s.result.IdentificationError = err.Error()
+		}
+		s.result.Identification = ident
+	}
+
+	var err error
+	for _, regType := range cfg.RegisterTypes {
+		if err = s.scanTable(ctx, regType); err != nil {
+			break
+		}
+	}
+
+	s.result.Duration = time.Since(started)
+	if errors.Is(err, errScanBudget) {
+		s.result.Truncated = true
+		err = nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	c.logger.Info().
+		Int("ranges", len(s.result.Ranges)).
+		Int("requests", s.result.Requests).
+		Int("timeouts", s.result.Timeouts).
+		Dur("duration", s.result.Duration).
+		Msg("Modbus register scan completed")
+
+	return s.result, nil
+}
+
+// Scan probes the register map of a device using the pooled connection.
+// Exceptions are part of a probe and do not count as breaker failures; only a
+// lost connection does.
+func (p *ConnectionPool) Scan(ctx context.Context, device *domain.Device, cfg ScanConfig) (*ScanResult, error) {
+	client, err := p.GetClient(ctx, device)
+	if err != nil {
+		return nil, err
+	}
+
+	p.mu.RLock()
+	pc := p.clients[device.ID]
+	p.mu.RUnlock()
+
+	if pc == nil {
+		return nil, domain.ErrDeviceNotFound
+	}
+
+	result, err := pc.breaker.Execute(func() (interface{}, error) {
+		return client.Scan(ctx, cfg)
+	})
+	if err != nil {
+		if err == gobreaker.ErrOpenState {
+			return nil, domain.ErrCircuitBreakerOpen
+		}
+		return nil, err
+	}
+
+	return result.(*ScanResult), nil
+}
+
+// scanTable probes one table chunk by chunk.
+func (s *scanner) scanTable(ctx context.Context, regType domain.RegisterType) error {
+	chunk := s.cfg.RegisterChunk
+	if regType == domain.RegisterTypeCoil || regType == domain.RegisterTypeDiscreteInput {
+		chunk = s.cfg.BitChunk
+	}
+	rangesBefore := len(s.result.Ranges)
+
+	for start := int(s.cfg.Start); start <= int(s.cfg.End); start += int(chunk) {
+		count := int(chunk)
+		if start+count-1 > int(s.cfg.End) {
+			count = int(s.cfg.End) - start + 1
+		}
+
+		unsupported, err := s.probe(ctx, regType, uint16(start), uint16(count))
+		if err != nil {
+			return err
+		}
+		// "Illegal function" before anything in the table answered means the
+		// table does not exist; afterwards it is treated like an address error
+		if unsupported && len(s.result.Ranges) == rangesBefore {
+			s.result.Unsupported = append(s.result.Unsupported, regType)
+			return nil
+		}
+	}
+	return nil
+}
+
+// probe reads one block and bisects it on exceptions. It reports whether the
+// block was rejected with "illegal function".
+func (s *scanner) probe(ctx context.Context, regType domain.RegisterType, start, count uint16) (bool, error) {
+	if err := ctx.Err(); err != nil {
+		return false, err
+	}
+	if s.result.Requests >= s.cfg.MaxRequests {
+		return false, errScanBudget
+	}
+	s.result.Requests++
+
+	elapsed, outcome, err := s.client.probeRead(regType, start, count)
+	if err != nil {
+		return false, err
+	}
+
+	switch outcome {
+	case probeReadable:
+		s.addReadable(regType, start, start+count-1, elapsed)
+		return false, nil
+	case probeTimeout:
+		s.result.Timeouts++
+		return false, nil
+	}
+
+	if count == 1 {
+		return outcome == probeUnsupported, nil
+	}
+	half := count / 2
+	leftUnsupported, err := s.probe(ctx, regType, start, half)
+	if err != nil {
+		return false, err
+	}
+	rightUnsupported, err := s.probe(ctx, regType, start+half, count-half)
+	return leftUnsupported && rightUnsupported, err
+}
+
+// addReadable records a readable block, extending the previous range when
+// the block continues it.
+func (s *scanner) addReadable(regType domain.RegisterType, start, end uint16, elapsed time.Duration) {
+	ranges := s.result.Ranges
+	if n := len(ranges); n > 0 && ranges[n-1].RegisterType == regType && int(ranges[n-1].End)+1 == int(start) {
+		last := &ranges[n-1]
+		last.End = end
+		last.Requests++
+		last.totalResponse += elapsed
+		last.AvgResponse = last.totalResponse / time.Duration(last.Requests)
+		if elapsed > last.MaxResponse {
+			last.MaxResponse = elapsed
+		}
+		return
+	}
+	s.result.Ranges = append(ranges, ScanRange{
+		RegisterType:  regType,
+		Start:         start,
+		End:           end,
+		Requests:      1,
+		AvgResponse:   elapsed,
+		MaxResponse:   elapsed,
+		totalResponse: elapsed,
+	})
+}
+
+// probeRead performs a single read of a block and classifies the result.
+// Errors are returned only for failures that end the scan (connection lost).
+func (c *Client) probeRead(regType domain.RegisterType, start, count uint16) (time.Duration, probeOutcome, error) {
+	c.mu.RLock()
+	client := c.client
+	c.mu.RUnlock()
+
+	if client == nil {
+		return 0, 0, domain.ErrConnectionClosed
+	}
+
+	c.opMu.Lock()
+	started := time.Now()
+	var err error
+	switch regType {
+	case domain.RegisterTypeCoil:
+		_, err = client.ReadCoils(start, count)
+	case domain.RegisterTypeDiscreteInput:
+		_, err = client.ReadDiscreteInputs(start, count)
+	case domain.RegisterTypeHoldingRegister:
+		_, err = client.ReadHoldingRegisters(start, count)
+	case domain.RegisterTypeInputRegister:
+		_, err = client.ReadInputRegisters(start, count)
+	default:
+		c.opMu.Unlock()
+		return 0, 0, domain.ErrInvalidRegisterType
+	}
+	elapsed := time.Since(started)
+	c.opMu.Unlock()
+
+	var mbErr *modbus.ModbusError
+	switch {
+	case err == nil:
+		return elapsed, probeReadable, nil
+	case errors.As(err, &mbErr):
+		if mbErr.ExceptionCode == modbus.ExceptionCodeIllegalFunction {
+			return elapsed, probeUnsupported, nil
+		}
+		return elapsed, probeRejected, nil
+	case isTimeout(err):
+		return elapsed, probeTimeout, nil
+	default:
+		return elapsed, 0, fmt.Errorf("%w: %w", domain.ErrReadFailed, err)
+	}
+}
+
+// ReadDeviceIdentification reads the identification objects of the device
+// with Read Device Identification (FC 43, MEI 14), at the regular level with
+// a fallback to the basic level.
+func (c *Client) ReadDeviceIdentification(ctx context.Context) (*DeviceIdentification, error) {
+	if !c.connected.Load() {
+		return nil, domain.ErrConnectionClosed
+	}
+
+	ident, err := c.readDeviceID(ctx, readDeviceIDRegular)
+	var mbErr *modbus.ModbusError
+	if errors.As(err, &mbErr) && mbErr.ExceptionCode != modbus.ExceptionCodeIllegalFunction {
+		ident, err = c.readDeviceID(ctx, readDeviceIDBasic)
+	}
+	if err != nil {
+		return nil, c.translateModbusError(err)
+	}
+	return ident, nil
+}
+
+// readDeviceID reads all objects of one access level, following "more
+// follows" continuations.
+func (c *Client) readDeviceID(ctx context.Context, code byte) (*DeviceIdentification, error) {
+	ident := &DeviceIdentification{Objects: make(map[byte]string)}
+
+	var objectID byte
+	for i := 0; i < maxDeviceIDRequests; i++ {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+
+		response, err := c.sendPDU(&modbus.ProtocolDataUnit{
+			FunctionCode: funcCodeEncapsulatedInterface,
+			Data:         []byte{meiTypeReadDeviceID, code, objectID},
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		more, next, err := parseDeviceIdentification(response.Data, ident)
+		if err != nil {
+			return nil, err
+		}
+		if !more {
+			break
+		}
+		objectID = next
+	}
+
+	ident.VendorName = ident.Objects[0x00]
+	ident.ProductCode = ident.Objects[0x01]
+	ident.Revision = ident.Objects[0x02]
+	ident.VendorURL = ident.Objects[0x03]
+	ident.ProductName = ident.Objects[0x04]
+	ident.ModelName = ident.Objects[0x05]
+	ident.UserApplicationName = ident.Objects[0x06]
+	return ident, nil
+}
+
+// parseDeviceIdentification adds the objects of one FC 43/14 response (data
+// after the function code) to ident and returns whether more objects follow
+// and the ID of the next one.
+func parseDeviceIdentification(data []byte, ident *DeviceIdentification) (bool, byte, error) {
+	// MEI type, read device ID code, conformity level, more follows, next object ID, number of objects
+	if len(data) < 6 || data[0] != meiTypeReadDeviceID {
+		return false, 0, fmt.Errorf("%w: malformed device identification response", domain.ErrInvalidDataLength)
+	}
+	ident.ConformityLevel = data[2]
+	more, next, count := data[3] == 0xFF, data[4], int(data[5])
+
+	objects := data[6:]
+	for i := 0; i < count; i++ {
+		if len(objects) < 2 || len(objects) < 2+int(objects[1]) {
+			return false, 0, fmt.Errorf("%w: truncated device identification object", domain.ErrInvalidDataLength)
+		}
+		length := int(objects[1])
+		ident.Objects[objects[0]] = strings.TrimRight(string(objects[2:2+length]), "\x00 ")
+		objects = objects[2+length:]
+	}
+	return more, next, nil
+}
+
+// sendPDU sends a request the goburrow client has no method for through the
+// client's transport and returns the response PDU. Exception responses are
+// returned as *modbus.ModbusError like goburrow's own requests.
+func (c *Client) sendPDU(request *modbus.ProtocolDataUnit) (*modbus.ProtocolDataUnit, error) {
+	c.mu.RLock()
+	handler := c.handler
+	c.mu.RUnlock()
+
+	if handler == nil {
+		return nil, domain.ErrConnectionClosed
+	}
+
+	c.opMu.Lock()
+	defer c.opMu.Unlock()
+
+	aduRequest, err := handler.Encode(request)
+	if err != nil {
+		return nil, err
+	}
+	aduResponse, err := handler.Send(aduRequest)
+	if err != nil {
+		return nil, err
+	}
+	if err := handler.Verify(aduRequest, aduResponse); err != nil {
+		return nil, err
+	}
+	response, err := handler.Decode(aduResponse)
+	if err != nil {
+		return nil, err
+	}
+
+	if response.FunctionCode != request.FunctionCode {
+		if response.FunctionCode == request.FunctionCode|0x80 && len(response.Data) > 0 {
+			return nil, &modbus.ModbusError{FunctionCode: response.FunctionCode, ExceptionCode: response.Data[0]}
+		}
+		return nil, fmt.Errorf("modbus: response function code 0x%02X does not match request 0x%02X", response.FunctionCode, request.FunctionCode)
+	}
+	return response, nil
+}
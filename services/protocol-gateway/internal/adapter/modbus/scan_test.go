@@ -0,0 +1,208 @@
+package modbus
+
+import (
+	"context"
+	"encoding/binary"
+	"reflect"
+	"testing"
+
+	"github.com/nexus-edge/protocol-gateway/internal/domain"
+	"github.com/rs/zerolog"
+)
+
+// scanDevice is a sparse device map for the scan stand-in: readable addresses
+// per function code, and identification objects per Read Device ID code.
+type scanDevice struct {
+	readable map[byte]map[uint16]bool
+	objects  map[byte][][]idObject // Read Device ID code -> objects of each response
+}
+
+type idObject struct {
+	id    byte
+	value string
+}
+
+func readable(ranges ...[2]uint16) map[uint16]bool {
+	m := make(map[uint16]bool)
+	for _, r := range ranges {
+		for a := r[0]; a <= r[1]; a++ {
+			m[a] = true
+		}
+	}
+	return m
+}
+
+// respond answers reads of mapped addresses, illegal data address for reads
+// touching unmapped ones, illegal function for tables the device lacks, and
+// FC 43/14 from the identification objects (one response per entry, chained
+// with "more follows").
+func (d *scanDevice) respond(unit byte, pdu []byte) []byte {
+	fc := pdu[0]
+	exception := func(code byte) []byte { return []byte{fc | 0x80, code} }
+
+	if fc == funcCodeEncapsulatedInterface {
+		responses, ok := d.objects[pdu[2]]
+		if !ok {
+			return exception(0x03)
+		}
+		part := int(pdu[3]) // Object ID doubles as response index
+		resp := []byte{fc, meiTypeReadDeviceID, pdu[2], 0x82, 0x00, 0x00, byte(len(responses[part]))}
+		if part+1 < len(responses) {
+			resp[4], resp[5] = 0xFF, byte(part+1)
+		}
+		for _, obj := range responses[part] {
+			resp = append(resp, obj.id, byte(len(obj.value)))
+			resp = append(resp, obj.value...)
+		}
+		return resp
+	}
+
+	table, ok := d.readable[fc]
+	if !ok {
+		return exception(0x01)
+	}
+	start := binary.BigEndian.Uint16(pdu[1:])
+	count := binary.BigEndian.Uint16(pdu[3:])
+	for a := start; a < start+count; a++ {
+		if !table[a] {
+			return exception(0x02)
+		}
+	}
+	if fc == 0x01 || fc == 0x02 {
+		return append([]byte{fc, byte((count + 7) / 8)}, make([]byte, (count+7)/8)...)
+	}
+	return append([]byte{fc, byte(count * 2)}, make([]byte, count*2)...)
+}
+
+func newScanStandIn(t *testing.T, device *scanDevice) *domain.Device {
+	t.Helper()
+	standIn := startTCPStandIn(t, &tcpStandIn{respond: device.respond})
+	host, port := standIn.host()
+	return gatewayDevice("scan", host, port, 1, "", false)
+}
+
+func TestScan_MapsReadableRanges(t *testing.T) {
+	device := newScanStandIn(t, &scanDevice{
+		readable: map[byte]map[uint16]bool{
+			0x02: readable([2]uint16{0, 15}),
+			0x03: readable([2]uint16{0, 9}, [2]uint16{100, 104}),
+			0x04: readable([2]uint16{50, 59}),
+		},
+		objects: map[byte][][]idObject{
+			readDeviceIDRegular: {
+				{{0x00, "Acme"}, {0x01, "PX-100"}, {0x02, "v1.2"}},
+				{{0x04, "Power meter"}, {0x05, "PX"}},
+			},
+		},
+	})
+
+	pool := NewConnectionPool(DefaultPoolConfig(), zerolog.Nop(), nil)
+	defer pool.Close()
+
+	result, err := pool.Scan(context.Background(), device, ScanConfig{End: 199, RegisterChunk: 50, BitChunk: 64, Identify: true})
+	if err != nil {
+		t.Fatalf("Scan: %v", err)
+	}
+
+	type span struct {
+		regType    domain.RegisterType
+		start, end uint16
+	}
+	var got []span
+	for _, r := range result.Ranges {
+		got = append(got, span{r.RegisterType, r.Start, r.End})
+		if r.Requests < 1 || r.MaxResponse < r.AvgResponse {
+			t.Errorf("range %v: inconsistent timings %+v", r.RegisterType, r)
+		}
+	}
+	want := []span{
+		{domain.RegisterTypeDiscreteInput, 0, 15},
+		{domain.RegisterTypeHoldingRegister, 0, 9},
+		{domain.RegisterTypeHoldingRegister, 100, 104},
+		{domain.RegisterTypeInputRegister, 50, 59},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("ranges:\n got %v\nwant %v", got, want)
+	}
+	if !reflect.DeepEqual(result.Unsupported, []domain.RegisterType{domain.RegisterTypeCoil}) {
+		t.Errorf("expected coils unsupported, got %v", result.Unsupported)
+	}
+	if result.Truncated || result.Timeouts != 0 {
+		t.Errorf("unexpected truncated=%v timeouts=%d", result.Truncated, result.Timeouts)
+	}
+
+	ident := result.Identification
+	if ident == nil {
+		t.Fatalf("no identification: %s", result.IdentificationError)
+	}
+	if ident.VendorName != "Acme" || ident.ProductCode != "PX-100" || ident.Revision != "v1.2" || ident.ModelName != "PX" {
+		t.Errorf("unexpected identification %+v", ident)
+	}
+	if ident.ConformityLevel != 0x82 || len(ident.Objects) != 5 {
+		t.Errorf("expected conformity 0x82 and 5 objects, got 0x%02X and %d", ident.ConformityLevel, len(ident.Objects))
+	}
+
+	tags := result.DraftTags()
+	if len(tags) != 16+10+5+10 {
+		t.Fatalf("expected 41 draft tags, got %d", len(tags))
+	}
+	for _, tag := range tags {
+		if err := tag.ValidateForProtocol(domain.ProtocolModbusTCP); err != nil {
+			t.Errorf("draft tag %s invalid: %v", tag.ID, err)
+		}
+	}
+	if tags[0].ID != "di_0" || tags[0].DataType != domain.DataTypeBool || tags[0].Enabled {
+		t.Errorf("unexpected first draft tag %+v", tags[0])
+	}
+	if last := tags[len(tags)-1]; last.ID != "ir_59" || last.DataType != domain.DataTypeUInt16 {
+		t.Errorf("unexpected last draft tag %+v", last)
+	}
+}
+
+func TestScan_StopsAtRequestBudget(t *testing.T) {
+	device := newScanStandIn(t, &scanDevice{
+		readable: map[byte]map[uint16]bool{0x03: readable([2]uint16{7, 7})},
+	})
+
+	pool := NewConnectionPool(DefaultPoolConfig(), zerolog.Nop(), nil)
+	defer pool.Close()
+
+	result, err := pool.Scan(context.Background(), device, ScanConfig{
+		RegisterTypes: []domain.RegisterType{domain.RegisterTypeHoldingRegister},
+		End:           124,
+		MaxRequests:   5,
+	})
+	if err != nil {
+		t.Fatalf("Scan: %v", err)
+	}
+	if !result.Truncated || result.Requests != 5 {
+		t.Errorf("expected truncated scan of 5 requests, got truncated=%v requests=%d", result.Truncated, result.Requests)
+	}
+}
+
+func TestReadDeviceIdentification_FallsBackToBasic(t *testing.T) {
+	device := newScanStandIn(t, &scanDevice{
+		objects: map[byte][][]idObject{
+			readDeviceIDBasic: {{{0x00, "Acme\x00"}, {0x01, "PX-1"}, {0x02, "1.0"}}},
+		},
+	})
+
+	pool := NewConnectionPool(DefaultPoolConfig(), zerolog.Nop(), nil)
+	defer pool.Close()
+
+	client, err := pool.GetClient(context.Background(), device)
+	if err != nil {
+		t.Fatalf("GetClient: %v", err)
+	}
+	ident, err := client.ReadDeviceIdentification(context.Background())
+	if err != nil {
+		t.Fatalf("ReadDeviceIdentification: %v", err)
+	}
+	if ident.VendorName != "Acme" || ident.ProductCode != "PX-1" || ident.Revision != "1.0" {
+		t.Errorf("unexpected identification %+v", ident)
+	}
+
+	if _, _, err := parseDeviceIdentification([]byte{meiTypeReadDeviceID, 1, 1, 0, 0, 1, 0x00, 9, 'A'}, &DeviceIdentification{Objects: map[byte]string{}}); err == nil {
+		t.Error("expected error for truncated object")
+	}
+}
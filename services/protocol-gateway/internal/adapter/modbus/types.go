@@ -8,13 +8,14 @@ import (
 
 	"github.com/goburrow/modbus"
 	"github.com/nexus-edge/protocol-gateway/internal/domain"
+	"github.com/nexus-edge/protocol-gateway/internal/metrics"
 	"github.com/rs/zerolog"
 )
 
 // Client represents a Modbus client connection to a single device.
 type Client struct {
 	config              ClientConfig
-	handler             *modbus.TCPClientHandler
+	handler             transportHandler
 	client              modbus.Client
 	logger              zerolog.Logger
 	mu                  sync.RWMutex
@@ -24,10 +25,26 @@ type Client struct {
 	lastUsed            time.Time
 	stats               *ClientStats
 	deviceID            string
-	consecutiveFailures atomic.Int32 // For backoff reset on success
-	tagDiagnostics      sync.Map     // map[string]*TagDiagnostic - per-tag success/error tracking
+	consecutiveFailures atomic.Int32        // For backoff reset on success
+	tagDiagnostics      sync.Map            // map[string]*TagDiagnostic - per-tag success/error tracking
+	capabilities        *deviceCapabilities // Optional function codes, shared by the pool across reconnects
+	metrics             *metrics.Registry   // Optional, set by the pool
 }
 
+// deviceCapabilities caches whether a device implements optional function
+// codes. Each starts unknown, and the first request using it settles it.
+type deviceCapabilities struct {
+	maskWrite atomic.Int32 // Mask Write Register (FC22)
+	readWrite atomic.Int32 // Read/Write Multiple Registers (FC23)
+}
+
+// Capability states.
+const (
+	capabilityUnknown int32 = iota
+	capabilitySupported
+	capabilityUnsupported
+)
+
 // ClientConfig holds configuration for a Modbus client.
 type ClientConfig struct {
 	// Address is the host:port for TCP or serial port for RTU
@@ -50,6 +67,32 @@ type ClientConfig struct {
 
 	// Protocol specifies TCP or RTU
 	Protocol domain.Protocol
+
+	// BaudRate is the serial baud rate for RTU (default: 19200)
+	BaudRate int
+
+	// DataBits is the number of data bits for RTU (default: 8)
+	DataBits int
+
+	// Parity is the serial parity for RTU: "N", "E" or "O" (default: "E")
+	Parity string
+
+	// StopBits is the number of stop bits for RTU (default: 1)
+	StopBits int
+
+	// TurnaroundDelay is the minimum silence between an RTU response and the next request
+	TurnaroundDelay time.Duration
+
+	// Framing selects MBAP or RTU-over-TCP framing for TCP connections (default: MBAP)
+	Framing domain.ModbusFraming
+
+	// Batching holds the device's resolved batching settings and quirks
+	// (profile plus overrides); zero values use the defaults
+	Batching domain.ModbusBatching
+
+	// bus is the shared link (RS-485 line or gateway connection) this client
+	// sends through; nil means a dedicated connection
+	bus *sharedBus
 }
 
 // ClientStats tracks client performance metrics.
@@ -93,6 +136,9 @@ type BatchConfig struct {
 	// MaxGapSize is the maximum gap between addresses to merge into one read
 	// Higher values = fewer reads but more wasted bandwidth
 	MaxGapSize uint16
+	// Alignment makes each read start on a multiple of this many registers
+	// and span whole multiples of it (0 or 1 = unaligned)
+	Alignment uint16
 }
 
 // DefaultBatchConfig returns sensible defaults for batching.
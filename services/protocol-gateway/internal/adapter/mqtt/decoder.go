@@ -0,0 +1,378 @@
+// Package mqtt provides payload decoding for MQTT-sourced tags.
+package mqtt
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/nexus-edge/protocol-gateway/internal/domain"
+)
+
+// defaultValuePath is used for JSON documents when a tag has no value path.
+const defaultValuePath = "$.value"
+
+// decodePayload decodes a message payload according to the tag's payload
+// format. It returns the value converted to the tag's data type and, for
+// JSON payloads with a timestamp path, the source timestamp.
+func decodePayload(payload []byte, tag *domain.Tag) (interface{}, *time.Time, error) {
+	switch tag.MQTTPayloadFormat {
+	case domain.MQTTPayloadString:
+		value, err := convertValue(strings.TrimSpace(string(payload)), tag.DataType)
+		return value, nil, err
+	case domain.MQTTPayloadRaw:
+		value, err := decodeRaw(payload, tag.DataType)
+		return value, nil, err
+	case "", domain.MQTTPayloadJSON:
+		return decodeJSON(payload, tag)
+	default:
+		return nil, nil, fmt.Errorf("%w: unsupported mqtt payload format %q", domain.ErrInvalidConfig, tag.MQTTPayloadFormat)
+	}
+}
+
+func decodeJSON(payload []byte, tag *domain.Tag) (interface{}, *time.Time, error) {
+	decoder := json.NewDecoder(bytes.NewReader(payload))
+	decoder.UseNumber()
+	var doc interface{}
+	if err := decoder.Decode(&doc); err != nil {
+		return nil, nil, fmt.Errorf("%w: invalid JSON payload: %v", domain.ErrInvalidDataType, err)
+	}
+
+	path := tag.MQTTValuePath
+	if path == "" {
+		path = "$"
+		if isContainer(doc) {
+			path = defaultValuePath
+		}
+	}
+	raw, err := lookupJSONPath(doc, path)
+	if err != nil {
+		return nil, nil, err
+	}
+	value, err := convertValue(raw, tag.DataType)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if tag.MQTTTimestampPath == "" {
+		return value, nil, nil
+	}
+	rawTS, err := lookupJSONPath(doc, tag.MQTTTimestampPath)
+	if err != nil {
+		return nil, nil, err
+	}
+	ts, err := parseTimestamp(rawTS)
+	if err != nil {
+		return nil, nil, err
+	}
+	return value, &ts, nil
+}
+
+func isContainer(v interface{}) bool {
+	switch v.(type) {
+	case map[string]interface{}, []interface{}:
+		return true
+	}
+	return false
+}
+
+// lookupJSONPath evaluates a simple JSONPath: "$" followed by ".name",
+// "['name']" and "[index]" segments, e.g. "$.data.values[0]".
+func lookupJSONPath(doc interface{}, path string) (interface{}, error) {
+	if !strings.HasPrefix(path, "$") {
+		return nil, fmt.Errorf("%w: json path %q must start with '$'", domain.ErrInvalidConfig, path)
+	}
+	current := doc
+	rest := path[1:]
+	for rest != "" {
+		var key string
+		index := -1
+		switch {
+		case rest[0] == '.':
+			rest = rest[1:]
+			end := strings.IndexAny(rest, ".[")
+			if end < 0 {
+				end = len(rest)
+			}
+			key, rest = rest[:end], rest[end:]
+			if key == "" {
+				return nil, fmt.Errorf("%w: empty segment in json path %q", domain.ErrInvalidConfig, path)
+			}
+		case rest[0] == '[':
+			end := strings.IndexByte(rest, ']')
+			if end < 0 {
+				return nil, fmt.Errorf("%w: unterminated '[' in json path %q", domain.ErrInvalidConfig, path)
+			}
+			inner := rest[1:end]
+			rest = rest[end+1:]
+			if unquoted, ok := unquotePathKey(inner); ok {
+				key = unquoted
+			} else {
+				n, err := strconv.Atoi(inner)
+				if err != nil || n < 0 {
+					return nil, fmt.Errorf("%w: invalid index %q in json path %q", domain.ErrInvalidConfig, inner, path)
+				}
+				index = n
+			}
+		default:
+			return nil, fmt.Errorf("%w: unexpected %q in json path %q", domain.ErrInvalidConfig, rest[:1], path)
+		}
+
+		if index >= 0 {
+			list, ok := current.([]interface{})
+			if !ok || index >= len(list) {
+				return nil, fmt.Errorf("%w: json path %q not found in payload", domain.ErrInvalidDataType, path)
+			}
+			current = list[index]
+			continue
+		}
+		object, ok := current.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("%w: json path %q not found in payload", domain.ErrInvalidDataType, path)
+		}
+		if current, ok = object[key]; !ok {
+			return nil, fmt.Errorf("%w: json path %q not found in payload", domain.ErrInvalidDataType, path)
+		}
+	}
+	return current, nil
+}
+
+func unquotePathKey(s string) (string, bool) {
+	if len(s) >= 2 && (s[0] == '\'' || s[0] == '"') && s[len(s)-1] == s[0] {
+		return s[1 : len(s)-1], true
+	}
+	return "", false
+}
+
+// convertValue converts a decoded JSON value or a plain text value to the Go
+// type of dataType.
+func convertValue(raw interface{}, dataType domain.DataType) (interface{}, error) {
+	var text string
+	switch v := raw.(type) {
+	case nil:
+		return nil, fmt.Errorf("%w: value is null", domain.ErrInvalidDataType)
+	case bool:
+		if dataType == domain.DataTypeString {
+			return strconv.FormatBool(v), nil
+		}
+		if dataType == domain.DataTypeBool {
+			return v, nil
+		}
+		text = "0"
+		if v {
+			text = "1"
+		}
+	case json.Number:
+		text = v.String()
+	case string:
+		text = v
+	default:
+		return nil, fmt.Errorf("%w: expected a scalar value, got %T", domain.ErrInvalidDataType, raw)
+	}
+
+	switch dataType {
+	case domain.DataTypeString:
+		return text, nil
+	case domain.DataTypeBool:
+		if b, err := strconv.ParseBool(text); err == nil {
+			return b, nil
+		}
+		f, err := strconv.ParseFloat(text, 64)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %q is not a boolean", domain.ErrInvalidDataType, text)
+		}
+		return f != 0, nil
+	case domain.DataTypeFloat32:
+		f, err := strconv.ParseFloat(text, 32)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %q is not a float32", domain.ErrInvalidDataType, text)
+		}
+		return float32(f), nil
+	case domain.DataTypeFloat64, "":
+		f, err := strconv.ParseFloat(text, 64)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %q is not a number", domain.ErrInvalidDataType, text)
+		}
+		return f, nil
+	case domain.DataTypeInt16, domain.DataTypeInt32, domain.DataTypeInt64:
+		bits := intBits(dataType)
+		n, err := parseInteger(text, bits, true)
+		if err != nil {
+			return nil, err
+		}
+		switch bits {
+		case 16:
+			return int16(n), nil
+		case 32:
+			return int32(n), nil
+		}
+		return n, nil
+	case domain.DataTypeUInt16, domain.DataTypeUInt32, domain.DataTypeUInt64:
+		bits := intBits(dataType)
+		n, err := parseInteger(text, bits, false)
+		if err != nil {
+			return nil, err
+		}
+		switch bits {
+		case 16:
+			return uint16(n), nil
+		case 32:
+			return uint32(n), nil
+		}
+		return uint64(n), nil
+	default:
+		return nil, fmt.Errorf("%w: %s", domain.ErrInvalidDataType, dataType)
+	}
+}
+
+func intBits(dataType domain.DataType) int {
+	switch dataType {
+	case domain.DataTypeInt16, domain.DataTypeUInt16:
+		return 16
+	case domain.DataTypeInt32, domain.DataTypeUInt32:
+		return 32
+	}
+	return 64
+}
+
+// parseInteger parses an integer of the given size. Integral floats such as
+// "42.0" are accepted, as JSON producers often don't distinguish the two.
+// Unsigned results are returned as the bit pattern in an int64.
+func parseInteger(text string, bits int, signed bool) (int64, error) {
+	if signed {
+		if n, err := strconv.ParseInt(text, 10, bits); err == nil {
+			return n, nil
+		}
+	} else if n, err := strconv.ParseUint(text, 10, bits); err == nil {
+		return int64(n), nil
+	}
+
+	f, err := strconv.ParseFloat(text, 64)
+	if err != nil || f != math.Trunc(f) {
+		return 0, fmt.Errorf("%w: %q is not an integer", domain.ErrInvalidDataType, text)
+	}
+	var lo, hi float64
+	if signed {
+		lo, hi = -math.Pow(2, float64(bits-1)), math.Pow(2, float64(bits-1))
+	} else {
+		lo, hi = 0, math.Pow(2, float64(bits))
+	}
+	if f < lo || f >= hi {
+		return 0, fmt.Errorf("%w: %q is out of range for a %d-bit integer", domain.ErrInvalidDataType, text, bits)
+	}
+	if !signed {
+		return int64(uint64(f)), nil
+	}
+	return int64(f), nil
+}
+
+// decodeRaw decodes a binary payload: big-endian numbers of the data type's
+// size, a single byte for booleans and UTF-8 for strings.
+func decodeRaw(payload []byte, dataType domain.DataType) (interface{}, error) {
+	size := map[domain.DataType]int{
+		domain.DataTypeBool: 1, domain.DataTypeInt16: 2, domain.DataTypeUInt16: 2,
+		domain.DataTypeInt32: 4, domain.DataTypeUInt32: 4, domain.DataTypeFloat32: 4,
+		domain.DataTypeInt64: 8, domain.DataTypeUInt64: 8, domain.DataTypeFloat64: 8,
+	}[dataType]
+	if dataType == domain.DataTypeString {
+		return string(payload), nil
+	}
+	if size == 0 {
+		return nil, fmt.Errorf("%w: %s", domain.ErrInvalidDataType, dataType)
+	}
+	if len(payload) != size {
+		return nil, fmt.Errorf("%w: %s needs %d bytes, got %d", domain.ErrInvalidDataLength, dataType, size, len(payload))
+	}
+
+	switch dataType {
+	case domain.DataTypeBool:
+		return payload[0] != 0, nil
+	case domain.DataTypeInt16:
+		return int16(binary.BigEndian.Uint16(payload)), nil
+	case domain.DataTypeUInt16:
+		return binary.BigEndian.Uint16(payload), nil
+	case domain.DataTypeInt32:
+		return int32(binary.BigEndian.Uint32(payload)), nil
+	case domain.DataTypeUInt32:
+		return binary.BigEndian.Uint32(payload), nil
+	case domain.DataTypeFloat32:
+		return math.Float32frombits(binary.BigEndian.Uint32(payload)), nil
+	case domain.DataTypeInt64:
+		return int64(binary.BigEndian.Uint64(payload)), nil
+	case domain.DataTypeUInt64:
+		return binary.BigEndian.Uint64(payload), nil
+	default:
+		return math.Float64frombits(binary.BigEndian.Uint64(payload)), nil
+	}
+}
+
+// parseTimestamp parses an RFC 3339 string or a Unix timestamp. The unit of
+// a Unix timestamp is inferred from its magnitude: s, ms, µs or ns.
+func parseTimestamp(raw interface{}) (time.Time, error) {
+	var text string
+	switch v := raw.(type) {
+	case json.Number:
+		text = v.String()
+	case string:
+		if t, err := time.Parse(time.RFC3339Nano, v); err == nil {
+			return t, nil
+		}
+		text = v
+	default:
+		return time.Time{}, fmt.Errorf("%w: timestamp must be a number or an RFC 3339 string, got %T", domain.ErrInvalidDataType, raw)
+	}
+
+	f, err := strconv.ParseFloat(text, 64)
+	if err != nil || f < 0 {
+		return time.Time{}, fmt.Errorf("%w: invalid timestamp %q", domain.ErrInvalidDataType, text)
+	}
+	switch {
+	case f < 1e11:
+		return time.Unix(0, int64(f*1e9)), nil
+	case f < 1e14:
+		return time.UnixMilli(int64(f)), nil
+	case f < 1e17:
+		return time.UnixMicro(int64(f)), nil
+	default:
+		return time.Unix(0, int64(f)), nil
+	}
+}
+
+// applyScaling converts numeric values to engineering units.
+func applyScaling(value interface{}, tag *domain.Tag) interface{} {
+	if (tag.ScaleFactor == 0 || tag.ScaleFactor == 1) && tag.Offset == 0 {
+		return value
+	}
+	scale := tag.ScaleFactor
+	if scale == 0 {
+		scale = 1
+	}
+
+	var floatVal float64
+	switch v := value.(type) {
+	case int16:
+		floatVal = float64(v)
+	case uint16:
+		floatVal = float64(v)
+	case int32:
+		floatVal = float64(v)
+	case uint32:
+		floatVal = float64(v)
+	case int64:
+		floatVal = float64(v)
+	case uint64:
+		floatVal = float64(v)
+	case float32:
+		floatVal = float64(v)
+	case float64:
+		floatVal = v
+	default:
+		return value // No scaling for booleans and strings
+	}
+	return floatVal*scale + tag.Offset
+}
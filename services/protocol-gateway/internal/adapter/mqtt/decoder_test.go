@@ -0,0 +1,83 @@
+package mqtt
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/nexus-edge/protocol-gateway/internal/domain"
+)
+
+func TestDecodePayload_JSONPaths(t *testing.T) {
+	cases := []struct {
+		name    string
+		payload string
+		tag     domain.Tag
+		want    interface{}
+	}{
+		{"scalar root", `21.5`, domain.Tag{DataType: domain.DataTypeFloat64}, 21.5},
+		{"default value path", `{"value": 7}`, domain.Tag{DataType: domain.DataTypeInt32}, int32(7)},
+		{"nested path", `{"data": {"temp": 18.25}}`, domain.Tag{DataType: domain.DataTypeFloat64, MQTTValuePath: "$.data.temp"}, 18.25},
+		{"index and quoted key", `{"a b": [false, true]}`, domain.Tag{DataType: domain.DataTypeBool, MQTTValuePath: "$['a b'][1]"}, true},
+		{"integral float to int", `{"value": 42.0}`, domain.Tag{DataType: domain.DataTypeUInt16}, uint16(42)},
+		{"number to string", `{"value": 3}`, domain.Tag{DataType: domain.DataTypeString}, "3"},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, ts, err := decodePayload([]byte(tc.payload), &tc.tag)
+			if err != nil {
+				t.Fatalf("decodePayload: %v", err)
+			}
+			if got != tc.want {
+				t.Errorf("got %v (%T), want %v (%T)", got, got, tc.want, tc.want)
+			}
+			if ts != nil {
+				t.Errorf("unexpected timestamp %v", ts)
+			}
+		})
+	}
+
+	tag := &domain.Tag{DataType: domain.DataTypeInt16, MQTTValuePath: "$.value"}
+	if _, _, err := decodePayload([]byte(`{"value": 40000}`), tag); !errors.Is(err, domain.ErrInvalidDataType) {
+		t.Errorf("out of range int16: expected ErrInvalidDataType, got %v", err)
+	}
+	if _, _, err := decodePayload([]byte(`{"other": 1}`), tag); !errors.Is(err, domain.ErrInvalidDataType) {
+		t.Errorf("missing path: expected ErrInvalidDataType, got %v", err)
+	}
+}
+
+func TestDecodePayload_Timestamps(t *testing.T) {
+	want := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	for _, payload := range []string{
+		`{"value": 1, "ts": "2024-05-01T12:00:00Z"}`,
+		`{"value": 1, "ts": 1714564800}`,
+		`{"value": 1, "ts": 1714564800000}`,
+		`{"value": 1, "ts": 1714564800000000}`,
+	} {
+		tag := &domain.Tag{DataType: domain.DataTypeFloat64, MQTTTimestampPath: "$.ts"}
+		_, ts, err := decodePayload([]byte(payload), tag)
+		if err != nil {
+			t.Fatalf("%s: %v", payload, err)
+		}
+		if ts == nil || !ts.Equal(want) {
+			t.Errorf("%s: got timestamp %v, want %v", payload, ts, want)
+		}
+	}
+}
+
+func TestDecodePayload_RawAndString(t *testing.T) {
+	raw := &domain.Tag{DataType: domain.DataTypeFloat32, MQTTPayloadFormat: domain.MQTTPayloadRaw}
+	got, _, err := decodePayload([]byte{0x41, 0x48, 0x00, 0x00}, raw)
+	if err != nil || got != float32(12.5) {
+		t.Errorf("raw float32: got %v, %v", got, err)
+	}
+	if _, _, err := decodePayload([]byte{0x41}, raw); !errors.Is(err, domain.ErrInvalidDataLength) {
+		t.Errorf("short raw payload: expected ErrInvalidDataLength, got %v", err)
+	}
+
+	text := &domain.Tag{DataType: domain.DataTypeInt32, MQTTPayloadFormat: domain.MQTTPayloadString}
+	got, _, err = decodePayload([]byte(" -17\n"), text)
+	if err != nil || got != int32(-17) {
+		t.Errorf("string int32: got %v, %v", got, err)
+	}
+}
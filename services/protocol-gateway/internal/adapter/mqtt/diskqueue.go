@@ -0,0 +1,480 @@
+// Package mqtt provides the disk-backed store-and-forward queue used by the
+// publisher to survive long broker outages and gateway restarts.
+package mqtt
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/rs/zerolog"
+)
+
+const (
+	segmentExt = ".seg"
+
+	// recordHeaderSize is the per-record header: body length + CRC-32C of the body.
+	recordHeaderSize = 8
+
+	// recordFixedSize is the fixed part of a record body:
+	// timestamp (8) + QoS (1) + retained (1) + priority (1) + topic length (2).
+	recordFixedSize = 13
+
+	// maxRecordSize bounds a record body so a corrupt length field can't make
+	// the reader allocate arbitrary amounts of memory.
+	maxRecordSize = 16 << 20
+)
+
+var crcTable = crc32.MakeTable(crc32.Castagnoli)
+
+// diskQueue is a persistent FIFO of buffered messages stored as a series of
+// append-only segment files. Every record carries a CRC-32C so torn writes
+// (power loss, crash mid-append) and bit rot are detected on read; a damaged
+// tail is truncated when the queue is reopened.
+//
+// The queue is bounded by total size and by message age: when a bound is
+// exceeded, whole segments are dropped oldest first. Segments are replayed
+// oldest first and the records of a segment in timestamp order, so consumers
+// see data in the order it was sampled. Delivery is at-least-once: a segment
+// is deleted only after all of its records were published, so a restart
+// during replay may republish part of a segment.
+type diskQueue struct {
+	dir         string
+	maxBytes    int64
+	maxAge      time.Duration
+	segmentSize int64
+	logger      zerolog.Logger
+
+	mu       sync.Mutex
+	segments []*segment // Oldest first; the last one is the active segment when active != nil
+	active   *os.File
+	nextSeq  uint64
+	bytes    int64
+	messages int
+
+	// Replay cursor over segments[0], loaded by peek
+	head     []*BufferedMessage
+	headSeq  uint64
+	headDone int
+
+	dropped atomic.Uint64
+}
+
+// segment describes one segment file.
+type segment struct {
+	seq    uint64
+	path   string
+	size   int64
+	count  int // Records not yet replayed
+	oldest time.Time
+	newest time.Time
+}
+
+// BacklogStats describes the store-and-forward backlog.
+type BacklogStats struct {
+	Messages  int
+	Bytes     int64
+	OldestAge time.Duration
+	Dropped   uint64 // Messages discarded by the size/age bounds
+}
+
+// openDiskQueue opens (or creates) the queue in dir and recovers any segments
+// left by a previous run.
+func openDiskQueue(dir string, maxBytes int64, maxAge time.Duration, segmentSize int64, logger zerolog.Logger) (*diskQueue, error) {
+	if err := os.MkdirAll(dir, 0o750); err != nil {
+		return nil, fmt.Errorf("failed to create buffer directory: %w", err)
+	}
+
+	q := &diskQueue{
+		dir:         dir,
+		maxBytes:    maxBytes,
+		maxAge:      maxAge,
+		segmentSize: segmentSize,
+		logger:      logger.With().Str("buffer_dir", dir).Logger(),
+		nextSeq:     1,
+	}
+	if err := q.recover(); err != nil {
+		return nil, err
+	}
+
+	q.mu.Lock()
+	q.enforceLimitsLocked(time.Now())
+	q.mu.Unlock()
+
+	if q.messages > 0 {
+		q.logger.Info().
+			Int("messages", q.messages).
+			Int("segments", len(q.segments)).
+			Int64("bytes", q.bytes).
+			Msg("Recovered store-and-forward backlog")
+	}
+	return q, nil
+}
+
+// recover scans existing segment files, validating every record.
+func (q *diskQueue) recover() error {
+	entries, err := os.ReadDir(q.dir)
+	if err != nil {
+		return fmt.Errorf("failed to read buffer directory: %w", err)
+	}
+
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !strings.HasSuffix(name, segmentExt) {
+			continue
+		}
+		seq, err := strconv.ParseUint(strings.TrimSuffix(name, segmentExt), 10, 64)
+		if err != nil {
+			continue
+		}
+
+		path := filepath.Join(q.dir, name)
+		records, valid, size, err := readSegment(path)
+		if err != nil {
+			q.logger.Warn().Err(err).Str("segment", name).Msg("Skipping unreadable buffer segment")
+			continue
+		}
+		if valid < size {
+			q.logger.Warn().
+				Str("segment", name).
+				Int64("valid_bytes", valid).
+				Int64("file_bytes", size).
+				Msg("Truncating damaged buffer segment tail")
+			if err := os.Truncate(path, valid); err != nil {
+				return fmt.Errorf("failed to truncate segment %s: %w", name, err)
+			}
+		}
+		if len(records) == 0 {
+			_ = os.Remove(path)
+			continue
+		}
+
+		seg := &segment{seq: seq, path: path, size: valid}
+		for _, msg := range records {
+			seg.add(msg.Timestamp)
+		}
+		q.segments = append(q.segments, seg)
+		q.bytes += seg.size
+		q.messages += seg.count
+		if seq >= q.nextSeq {
+			q.nextSeq = seq + 1
+		}
+	}
+
+	sort.Slice(q.segments, func(i, j int) bool { return q.segments[i].seq < q.segments[j].seq })
+	return nil
+}
+
+// append persists a message at the tail of the queue.
+func (q *diskQueue) append(msg *BufferedMessage) error {
+	record := encodeRecord(msg)
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if q.active == nil || q.segments[len(q.segments)-1].size+int64(len(record)) > q.segmentSize {
+		if err := q.rotateLocked(); err != nil {
+			return err
+		}
+	}
+
+	seg := q.segments[len(q.segments)-1]
+	n, err := q.active.Write(record)
+	seg.size += int64(n)
+	q.bytes += int64(n)
+	if err != nil {
+		// A partial record would misalign every following one: seal the
+		// segment here and let recovery truncate the torn tail.
+		q.sealActiveLocked()
+		return fmt.Errorf("failed to write buffer record: %w", err)
+	}
+
+	seg.add(msg.Timestamp)
+	q.messages++
+	q.enforceLimitsLocked(time.Now())
+	return nil
+}
+
+// peek returns the records of the oldest segment that have not been replayed
+// yet, in timestamp order. Records older than the age bound are skipped. The
+// active segment is replayed without sealing it, so a trickle of new messages
+// during replay doesn't leave a segment file per message behind.
+func (q *diskQueue) peek(now time.Time) ([]*BufferedMessage, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	q.enforceLimitsLocked(now)
+	for len(q.segments) > 0 {
+		first := q.segments[0]
+		loaded := 0
+		if q.head != nil && q.headSeq == first.seq {
+			loaded = len(q.head)
+		}
+		// The active segment is read in place; records appended since it was
+		// loaded are added to the cursor in their own timestamp order.
+		if loaded == 0 || q.headDone+first.count > loaded {
+			if first.count == 0 {
+				if q.active != nil && len(q.segments) == 1 {
+					return nil, nil
+				}
+				q.removeSegmentLocked()
+				continue
+			}
+			records, _, _, err := readSegment(first.path)
+			if err != nil {
+				q.logger.Warn().Err(err).Str("segment", filepath.Base(first.path)).Msg("Dropping unreadable buffer segment")
+				q.dropSegmentLocked()
+				return nil, err
+			}
+			if loaded == 0 {
+				q.head, q.headSeq, q.headDone = nil, first.seq, 0
+			}
+			if len(records) > loaded {
+				added := records[loaded:]
+				sort.SliceStable(added, func(i, j int) bool { return added[i].Timestamp.Before(added[j].Timestamp) })
+				q.head = append(q.head, added...)
+			}
+		}
+
+		for q.maxAge > 0 && q.headDone < len(q.head) && now.Sub(q.head[q.headDone].Timestamp) > q.maxAge {
+			q.headDone++
+			first.count--
+			q.messages--
+			q.dropped.Add(1)
+		}
+		if q.headDone < len(q.head) {
+			return q.head[q.headDone:], nil
+		}
+		q.removeSegmentLocked() // Fully expired or empty
+	}
+	return nil, nil
+}
+
+// commit marks the first n records returned by peek as published. The
+// segment file is deleted once all of its records are published.
+func (q *diskQueue) commit(n int) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if q.head == nil || len(q.segments) == 0 || q.segments[0].seq != q.headSeq {
+		return
+	}
+	first := q.segments[0]
+	q.headDone += n
+	first.count -= n
+	q.messages -= n
+
+	// The active segment stays until its new records are replayed as well
+	if q.headDone >= len(q.head) && first.count == 0 {
+		q.removeSegmentLocked()
+	}
+}
+
+// empty reports whether all records were replayed.
+func (q *diskQueue) empty() bool {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return q.messages == 0
+}
+
+// stats returns a snapshot of the backlog.
+func (q *diskQueue) stats(now time.Time) BacklogStats {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	stats := BacklogStats{
+		Messages: q.messages,
+		Bytes:    q.bytes,
+		Dropped:  q.dropped.Load(),
+	}
+	if len(q.segments) > 0 && q.messages > 0 {
+		oldest := q.segments[0].oldest
+		if q.head != nil && q.headSeq == q.segments[0].seq && q.headDone < len(q.head) {
+			oldest = q.head[q.headDone].Timestamp
+		}
+		stats.OldestAge = now.Sub(oldest)
+	}
+	return stats
+}
+
+// close flushes and closes the active segment.
+func (q *diskQueue) close() error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if q.active == nil {
+		return nil
+	}
+	err := q.active.Sync()
+	if cerr := q.active.Close(); err == nil {
+		err = cerr
+	}
+	q.active = nil
+	return err
+}
+
+// rotateLocked seals the active segment and starts a new one.
+func (q *diskQueue) rotateLocked() error {
+	q.sealActiveLocked()
+
+	path := filepath.Join(q.dir, fmt.Sprintf("%020d%s", q.nextSeq, segmentExt))
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_EXCL|os.O_WRONLY|os.O_APPEND, 0o600)
+	if err != nil {
+		return fmt.Errorf("failed to create buffer segment: %w", err)
+	}
+	q.segments = append(q.segments, &segment{seq: q.nextSeq, path: path})
+	q.nextSeq++
+	q.active = f
+	return nil
+}
+
+// sealActiveLocked flushes and closes the active segment; it stays in the queue.
+func (q *diskQueue) sealActiveLocked() {
+	if q.active == nil {
+		return
+	}
+	if err := q.active.Sync(); err != nil {
+		q.logger.Warn().Err(err).Msg("Failed to sync buffer segment")
+	}
+	_ = q.active.Close()
+	q.active = nil
+}
+
+// enforceLimitsLocked drops the oldest segments while the queue exceeds its
+// size bound or while the oldest segment holds only expired messages. The
+// active segment is never dropped.
+func (q *diskQueue) enforceLimitsLocked(now time.Time) {
+	for len(q.segments) > 0 && !(q.active != nil && len(q.segments) == 1) {
+		first := q.segments[0]
+		overSize := q.maxBytes > 0 && q.bytes > q.maxBytes
+		expired := q.maxAge > 0 && now.Sub(first.newest) > q.maxAge
+		if !overSize && !expired {
+			return
+		}
+		q.logger.Warn().
+			Int("messages", first.count).
+			Bool("size_bound", overSize).
+			Bool("age_bound", expired).
+			Msg("Dropping oldest buffer segment")
+		q.dropSegmentLocked()
+	}
+}
+
+// dropSegmentLocked discards the oldest segment and counts its remaining
+// messages as dropped.
+func (q *diskQueue) dropSegmentLocked() {
+	q.dropped.Add(uint64(q.segments[0].count))
+	q.removeSegmentLocked()
+}
+
+// removeSegmentLocked deletes the oldest segment file.
+func (q *diskQueue) removeSegmentLocked() {
+	first := q.segments[0]
+	if q.active != nil && len(q.segments) == 1 {
+		q.sealActiveLocked()
+	}
+	if err := os.Remove(first.path); err != nil && !errors.Is(err, os.ErrNotExist) {
+		q.logger.Warn().Err(err).Str("segment", filepath.Base(first.path)).Msg("Failed to remove buffer segment")
+	}
+	q.bytes -= first.size
+	q.messages -= first.count
+	q.segments = q.segments[1:]
+	if q.headSeq == first.seq {
+		q.head, q.headDone = nil, 0
+	}
+}
+
+// add accounts for one more record with timestamp ts.
+func (s *segment) add(ts time.Time) {
+	s.count++
+	if s.oldest.IsZero() || ts.Before(s.oldest) {
+		s.oldest = ts
+	}
+	if ts.After(s.newest) {
+		s.newest = ts
+	}
+}
+
+// encodeRecord serializes a message as header (length, CRC-32C) + body.
+func encodeRecord(msg *BufferedMessage) []byte {
+	bodyLen := recordFixedSize + len(msg.Topic) + len(msg.Payload)
+	record := make([]byte, recordHeaderSize, recordHeaderSize+bodyLen)
+
+	record = binary.BigEndian.AppendUint64(record, uint64(msg.Timestamp.UnixNano()))
+	retained := byte(0)
+	if msg.Retained {
+		retained = 1
+	}
+	record = append(record, msg.QoS, retained, msg.Priority)
+	record = binary.BigEndian.AppendUint16(record, uint16(len(msg.Topic)))
+	record = append(record, msg.Topic...)
+	record = append(record, msg.Payload...)
+
+	body := record[recordHeaderSize:]
+	binary.BigEndian.PutUint32(record[0:], uint32(len(body)))
+	binary.BigEndian.PutUint32(record[4:], crc32.Checksum(body, crcTable))
+	return record
+}
+
+// decodeRecord parses a record body (without header).
+func decodeRecord(body []byte) (*BufferedMessage, error) {
+	if len(body) < recordFixedSize {
+		return nil, fmt.Errorf("record too short: %d bytes", len(body))
+	}
+	topicLen := int(binary.BigEndian.Uint16(body[11:]))
+	if recordFixedSize+topicLen > len(body) {
+		return nil, fmt.Errorf("topic length %d exceeds record", topicLen)
+	}
+	topic := body[recordFixedSize : recordFixedSize+topicLen]
+	return &BufferedMessage{
+		Timestamp: time.Unix(0, int64(binary.BigEndian.Uint64(body[0:]))),
+		QoS:       body[8],
+		Retained:  body[9] == 1,
+		Priority:  body[10],
+		Topic:     string(topic),
+		Payload:   append([]byte(nil), body[recordFixedSize+topicLen:]...),
+	}, nil
+}
+
+// readSegment reads all valid records of a segment file. It stops at the
+// first torn or corrupt record and reports the number of valid bytes before
+// it, along with the file size.
+func readSegment(path string) (records []*BufferedMessage, valid int64, size int64, err error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, 0, 0, err
+	}
+	size = int64(len(data))
+
+	for offset := 0; offset < len(data); {
+		if len(data)-offset < recordHeaderSize {
+			break // Torn header
+		}
+		bodyLen := int(binary.BigEndian.Uint32(data[offset:]))
+		checksum := binary.BigEndian.Uint32(data[offset+4:])
+		if bodyLen > maxRecordSize || len(data)-offset-recordHeaderSize < bodyLen {
+			break // Corrupt length or torn body
+		}
+		body := data[offset+recordHeaderSize : offset+recordHeaderSize+bodyLen]
+		if crc32.Checksum(body, crcTable) != checksum {
+			break
+		}
+		msg, err := decodeRecord(body)
+		if err != nil {
+			break
+		}
+		records = append(records, msg)
+		offset += recordHeaderSize + bodyLen
+		valid = int64(offset)
+	}
+	return records, valid, size, nil
+}
@@ -0,0 +1,292 @@
+package mqtt
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/nexus-edge/protocol-gateway/internal/domain"
+	"github.com/rs/zerolog"
+)
+
+func openTestQueue(t *testing.T, dir string, maxBytes int64, maxAge time.Duration, segmentSize int64) *diskQueue {
+	t.Helper()
+	q, err := openDiskQueue(dir, maxBytes, maxAge, segmentSize, zerolog.Nop())
+	if err != nil {
+		t.Fatalf("openDiskQueue: %v", err)
+	}
+	return q
+}
+
+func queuedMessage(topic string, ts time.Time) *BufferedMessage {
+	return &BufferedMessage{Topic: topic, Payload: []byte(`{"v":1}`), QoS: 1, Priority: domain.PriorityControl, Timestamp: ts}
+}
+
+// drain replays the whole queue and returns the topics in replay order.
+func drain(t *testing.T, q *diskQueue, now time.Time) []string {
+	t.Helper()
+	var topics []string
+	for {
+		records, err := q.peek(now)
+		if err != nil {
+			t.Fatalf("peek: %v", err)
+		}
+		if len(records) == 0 {
+			return topics
+		}
+		for _, msg := range records {
+			topics = append(topics, msg.Topic)
+		}
+		q.commit(len(records))
+	}
+}
+
+func TestDiskQueue_SurvivesReopen(t *testing.T) {
+	dir := t.TempDir()
+	base := time.Now()
+
+	q := openTestQueue(t, dir, 1<<20, time.Hour, 1<<10)
+	for i := 0; i < 50; i++ {
+		if err := q.append(queuedMessage(fmt.Sprintf("t-%02d", i), base.Add(time.Duration(i)*time.Millisecond))); err != nil {
+			t.Fatalf("append: %v", err)
+		}
+	}
+	if err := q.close(); err != nil {
+		t.Fatalf("close: %v", err)
+	}
+
+	q = openTestQueue(t, dir, 1<<20, time.Hour, 1<<10)
+	if stats := q.stats(base); stats.Messages != 50 {
+		t.Fatalf("expected 50 recovered messages, got %d", stats.Messages)
+	}
+	records, _ := q.peek(base)
+	if first := records[0]; first.QoS != 1 || first.Priority != domain.PriorityControl || !first.Timestamp.Equal(base) || string(first.Payload) != `{"v":1}` {
+		t.Errorf("record fields not preserved: %+v", first)
+	}
+
+	topics := drain(t, q, base)
+	if len(topics) != 50 || topics[0] != "t-00" || topics[49] != "t-49" {
+		t.Errorf("unexpected replay: %v", topics)
+	}
+	if stats := q.stats(base); stats.Messages != 0 || stats.Bytes != 0 {
+		t.Errorf("expected empty queue after replay, got %+v", stats)
+	}
+	segments, _ := filepath.Glob(filepath.Join(dir, "*"+segmentExt))
+	if len(segments) != 0 {
+		t.Errorf("expected replayed segments to be removed, found %v", segments)
+	}
+}
+
+func TestDiskQueue_TruncatesTornTail(t *testing.T) {
+	dir := t.TempDir()
+	now := time.Now()
+
+	q := openTestQueue(t, dir, 1<<20, time.Hour, 1<<20)
+	for _, topic := range []string{"a", "b", "c"} {
+		_ = q.append(queuedMessage(topic, now))
+	}
+	_ = q.close()
+
+	// Simulate a crash mid-append: half a record at the end of the segment
+	segments, _ := filepath.Glob(filepath.Join(dir, "*"+segmentExt))
+	if len(segments) != 1 {
+		t.Fatalf("expected one segment, got %v", segments)
+	}
+	torn := encodeRecord(queuedMessage("d", now))
+	f, _ := os.OpenFile(segments[0], os.O_APPEND|os.O_WRONLY, 0)
+	_, _ = f.Write(torn[:len(torn)/2])
+	_ = f.Close()
+	before, _ := os.Stat(segments[0])
+
+	q = openTestQueue(t, dir, 1<<20, time.Hour, 1<<20)
+	after, _ := os.Stat(segments[0])
+	if after.Size() != before.Size()-int64(len(torn)/2) {
+		t.Errorf("expected torn tail to be truncated: %d -> %d bytes", before.Size(), after.Size())
+	}
+
+	// Appends after recovery must be readable
+	_ = q.append(queuedMessage("e", now))
+	if topics := drain(t, q, now); fmt.Sprint(topics) != "[a b c e]" {
+		t.Errorf("expected [a b c e], got %v", topics)
+	}
+}
+
+func TestDiskQueue_ReplaysInTimestampOrder(t *testing.T) {
+	q := openTestQueue(t, t.TempDir(), 1<<20, time.Hour, 1<<20)
+	base := time.Now()
+
+	// Out-of-order arrival, e.g. a slow device's batch landing after a fast one
+	_ = q.append(queuedMessage("second", base.Add(2*time.Second)))
+	_ = q.append(queuedMessage("first", base.Add(time.Second)))
+	_ = q.append(queuedMessage("third", base.Add(3*time.Second)))
+
+	records, _ := q.peek(base)
+	if len(records) != 3 {
+		t.Fatalf("expected 3 records, got %d", len(records))
+	}
+	// A partial commit must resume after the published records
+	q.commit(1)
+	_ = q.append(queuedMessage("fourth", base.Add(4*time.Second)))
+
+	if topics := drain(t, q, base); fmt.Sprint(topics) != "[second third fourth]" {
+		t.Errorf("expected [second third fourth], got %v", topics)
+	}
+	if records[0].Topic != "first" {
+		t.Errorf("expected first record to be 'first', got %s", records[0].Topic)
+	}
+}
+
+func TestDiskQueue_EnforcesSizeAndAgeBounds(t *testing.T) {
+	t.Run("size", func(t *testing.T) {
+		record := int64(len(encodeRecord(queuedMessage("t-00", time.Now()))))
+		// Room for about 10 records in segments of 2
+		q := openTestQueue(t, t.TempDir(), 10*record, time.Hour, 2*record)
+		now := time.Now()
+		for i := 0; i < 40; i++ {
+			_ = q.append(queuedMessage(fmt.Sprintf("t-%02d", i), now))
+		}
+
+		stats := q.stats(now)
+		if stats.Bytes > 10*record {
+			t.Errorf("queue exceeds its size bound: %d > %d bytes", stats.Bytes, 10*record)
+		}
+		if stats.Dropped == 0 || uint64(stats.Messages)+stats.Dropped != 40 {
+			t.Errorf("expected dropped + queued = 40, got %+v", stats)
+		}
+		topics := drain(t, q, now)
+		if topics[len(topics)-1] != "t-39" {
+			t.Errorf("expected the newest messages to be kept, got %v", topics)
+		}
+	})
+
+	t.Run("age", func(t *testing.T) {
+		q := openTestQueue(t, t.TempDir(), 1<<20, time.Hour, 1<<20)
+		now := time.Now()
+		_ = q.append(queuedMessage("stale", now.Add(-2*time.Hour)))
+		_ = q.append(queuedMessage("fresh", now.Add(-time.Minute)))
+
+		if stats := q.stats(now); stats.OldestAge < 2*time.Hour {
+			t.Errorf("expected oldest age of at least 2h, got %v", stats.OldestAge)
+		}
+		if topics := drain(t, q, now); fmt.Sprint(topics) != "[fresh]" {
+			t.Errorf("expected only [fresh], got %v", topics)
+		}
+		if dropped := q.stats(now).Dropped; dropped != 1 {
+			t.Errorf("expected 1 dropped message, got %d", dropped)
+		}
+	})
+}
+
+func TestPublisher_OfflineMessagesGoToDisk(t *testing.T) {
+	config := DefaultConfig()
+	config.BufferDir = t.TempDir()
+	p, err := NewPublisher(config, zerolog.Nop(), nil)
+	if err != nil {
+		t.Fatalf("NewPublisher: %v", err)
+	}
+
+	sampled := time.Now().Add(-time.Minute)
+	dp := priorityPoint("alarm", domain.PrioritySafety)
+	dp.Timestamp = sampled
+	if err := p.Publish(context.Background(), dp); err != nil {
+		t.Fatalf("Publish while disconnected: %v", err)
+	}
+
+	if p.BufferSize() != 0 {
+		t.Errorf("expected memory lanes to stay empty, got %d", p.BufferSize())
+	}
+	if backlog := p.Backlog(); backlog.Messages != 1 || backlog.OldestAge < time.Minute {
+		t.Errorf("unexpected backlog: %+v", backlog)
+	}
+	records, _ := p.disk[domain.PrioritySafety].peek(time.Now())
+	if len(records) != 1 || !records[0].Timestamp.Equal(sampled) || records[0].QoS != config.SafetyQoS {
+		t.Errorf("expected safety record with original timestamp, got %+v", records)
+	}
+}
+
+func TestFillWindow_PriorityFirst(t *testing.T) {
+	base := time.Now()
+	at := func(topic string, ms int) *BufferedMessage {
+		return queuedMessage(topic, base.Add(time.Duration(ms)*time.Millisecond))
+	}
+	heads := [][]*BufferedMessage{
+		{at("telemetry-1", 1), at("telemetry-4", 4), at("telemetry-6", 6)},
+		{at("control-2", 2)},
+		{at("safety-3", 3), at("safety-5", 5)},
+	}
+	topics := func(window []*BufferedMessage) string {
+		var names []string
+		for _, msg := range window {
+			names = append(names, msg.Topic)
+		}
+		return fmt.Sprint(names)
+	}
+
+	// Queues that run out leave the window to the lower tiers
+	window, sources := fillWindow(heads, 10)
+	if topics(window) != "[safety-3 safety-5 control-2 telemetry-1 telemetry-4 telemetry-6]" || fmt.Sprint(sources) != "[2 2 1 0 0 0]" {
+		t.Errorf("unexpected window %s from %v", topics(window), sources)
+	}
+
+	window, _ = fillWindow(heads, 4)
+	if topics(window) != "[safety-3 safety-5 control-2 telemetry-1]" {
+		t.Errorf("unexpected window %s", topics(window))
+	}
+}
+
+func TestDiskQueue_ReplaysActiveSegmentInPlace(t *testing.T) {
+	dir := t.TempDir()
+	q := openTestQueue(t, dir, 1<<20, time.Hour, 1<<20)
+	now := time.Now()
+
+	// Messages trickling in during replay go to the same segment
+	for i := 0; i < 5; i++ {
+		_ = q.append(queuedMessage(fmt.Sprintf("t-%d", i), now))
+		records, _ := q.peek(now)
+		if len(records) != 1 || records[0].Topic != fmt.Sprintf("t-%d", i) {
+			t.Fatalf("expected only t-%d, got %d records", i, len(records))
+		}
+		if segments, _ := filepath.Glob(filepath.Join(dir, "*"+segmentExt)); len(segments) != 1 {
+			t.Fatalf("expected one segment file, got %v", segments)
+		}
+		q.commit(len(records))
+	}
+
+	// A fully replayed segment is removed, so a restart doesn't replay it again
+	if segments, _ := filepath.Glob(filepath.Join(dir, "*"+segmentExt)); len(segments) != 0 || !q.empty() {
+		t.Errorf("expected the replayed segment to be removed, found %v", segments)
+	}
+	_ = q.append(queuedMessage("next", now))
+	if topics := drain(t, q, now); fmt.Sprint(topics) != "[next]" {
+		t.Errorf("expected [next], got %v", topics)
+	}
+}
+
+func TestPublisher_LivePointsQueueBehindBacklog(t *testing.T) {
+	config := DefaultConfig()
+	config.BufferDir = t.TempDir()
+	p, err := NewPublisher(config, zerolog.Nop(), nil)
+	if err != nil {
+		t.Fatalf("NewPublisher: %v", err)
+	}
+	if err := p.Publish(context.Background(), priorityPoint("old", domain.PriorityTelemetry)); err != nil {
+		t.Fatalf("Publish while disconnected: %v", err)
+	}
+
+	// Reconnected, backlog not replayed yet: the live point must not overtake it
+	p.connected.Store(true)
+	if err := p.Publish(context.Background(), priorityPoint("new", domain.PriorityTelemetry)); err != nil {
+		t.Fatalf("Publish with pending backlog: %v", err)
+	}
+	if backlog := p.Backlog(); backlog.Messages != 2 {
+		t.Errorf("expected the live point to queue behind the backlog, got %+v", backlog)
+	}
+
+	// Other tiers don't wait for the telemetry backlog
+	if !p.backlogPending(domain.PriorityTelemetry) || p.backlogPending(domain.PrioritySafety) {
+		t.Error("expected only the telemetry tier to have a backlog")
+	}
+}
@@ -9,6 +9,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"os"
+	"path/filepath"
 	"sort"
 	"sync"
 	"sync/atomic"
@@ -22,19 +23,21 @@ import (
 
 // Publisher handles publishing data points to the MQTT broker.
 type Publisher struct {
-	config        Config
-	client        pahomqtt.Client
-	logger        zerolog.Logger
-	metrics       *metrics.Registry
-	mu            sync.RWMutex
-	connected     atomic.Bool
-	reconnecting  atomic.Bool
-	messageBuffer chan *BufferedMessage
-	done          chan struct{}
-	wg            sync.WaitGroup
-	stats         *PublisherStats
-	topicMu       sync.RWMutex
-	topicStats    map[string]*TopicStat
+	config       Config
+	client       pahomqtt.Client
+	logger       zerolog.Logger
+	metrics      *metrics.Registry
+	mu           sync.RWMutex
+	connected    atomic.Bool
+	reconnecting atomic.Bool
+	lanes        [3]chan *BufferedMessage // Offline buffer lanes, indexed by priority tier
+	disk         [3]*diskQueue            // Store-and-forward queues; nil when BufferDir is unset
+	sparkplug    *sparkplugNode           // Set in sparkplug_b payload mode
+	done         chan struct{}
+	wg           sync.WaitGroup
+	stats        *PublisherStats
+	topicMu      sync.RWMutex
+	topicStats   map[string]*TopicStat
 }
 
 // TopicStat tracks publish activity for a given topic.
@@ -46,6 +49,12 @@ type TopicStat struct {
 	LastPayloadBytes int       `json:"last_payload_bytes"`
 }
 
+// Payload formats.
+const (
+	PayloadFormatJSON       = "json"        // Compact JSON per tag on its UNS topic
+	PayloadFormatSparkplugB = "sparkplug_b" // Sparkplug B edge node
+)
+
 // Config holds MQTT publisher configuration.
 type Config struct {
 	BrokerURL      string
@@ -53,7 +62,9 @@ type Config struct {
 	Username       string
 	Password       string
 	CleanSession   bool
-	QoS            byte
+	QoS            byte // Telemetry QoS
+	ControlQoS     byte // QoS for control-priority data points
+	SafetyQoS      byte // QoS for safety-priority data points
 	KeepAlive      time.Duration
 	ConnectTimeout time.Duration
 	ReconnectDelay time.Duration
@@ -62,9 +73,31 @@ type Config struct {
 	TLSCertFile    string
 	TLSKeyFile     string
 	TLSCAFile      string
-	BufferSize     int
-	PublishTimeout time.Duration
-	RetainMessages bool
+	BufferSize     int // Telemetry buffer lane capacity
+	// Capacity of each control/safety buffer lane. Separate from the telemetry
+	// lane so a telemetry flood while disconnected never evicts buffered alarms.
+	PriorityBufferSize int
+	PublishTimeout     time.Duration
+	RetainMessages     bool
+
+	// Store-and-forward: when BufferDir is set, messages that can't be
+	// published are persisted there instead of the in-memory lanes, survive
+	// restarts, and are replayed after reconnect. Control and safety each get
+	// a tenth of BufferMaxBytes on top of the telemetry bound.
+	BufferDir         string
+	BufferMaxBytes    int64
+	BufferMaxAge      time.Duration
+	BufferSegmentSize int64
+
+	// PayloadFormat selects the output format (PayloadFormatJSON by default).
+	// In Sparkplug B mode the gateway is a single edge node
+	// SparkplugGroupID/SparkplugEdgeNodeID (defaulting to ClientID), data is
+	// published with QoS 0 as the specification requires, and values read
+	// while disconnected are not buffered: the births after reconnect carry
+	// the latest values instead.
+	PayloadFormat       string
+	SparkplugGroupID    string
+	SparkplugEdgeNodeID string
 }
 
 // BufferedMessage represents a message waiting to be published.
@@ -73,6 +106,7 @@ type BufferedMessage struct {
 	Payload   []byte
 	QoS       byte
 	Retained  bool
+	Priority  uint8
 	Timestamp time.Time
 }
 
@@ -88,17 +122,24 @@ type PublisherStats struct {
 // DefaultConfig returns a Config with sensible defaults.
 func DefaultConfig() Config {
 	return Config{
-		BrokerURL:      "tcp://localhost:1883",
-		ClientID:       "protocol-gateway",
-		CleanSession:   true,
-		QoS:            1,
-		KeepAlive:      30 * time.Second,
-		ConnectTimeout: 10 * time.Second,
-		ReconnectDelay: 5 * time.Second,
-		MaxReconnect:   -1, // Unlimited
-		BufferSize:     10000,
-		PublishTimeout: 5 * time.Second,
-		RetainMessages: false,
+		BrokerURL:          "tcp://localhost:1883",
+		ClientID:           "protocol-gateway",
+		CleanSession:       true,
+		QoS:                1,
+		ControlQoS:         1,
+		SafetyQoS:          2,
+		KeepAlive:          30 * time.Second,
+		ConnectTimeout:     10 * time.Second,
+		ReconnectDelay:     5 * time.Second,
+		MaxReconnect:       -1, // Unlimited
+		BufferSize:         10000,
+		PriorityBufferSize: 1000,
+		PublishTimeout:     5 * time.Second,
+		RetainMessages:     false,
+		BufferMaxBytes:     1 << 30,
+		BufferMaxAge:       72 * time.Hour,
+		BufferSegmentSize:  4 << 20,
+		PayloadFormat:      PayloadFormatJSON,
 	}
 }
 
@@ -108,6 +149,9 @@ func NewPublisher(config Config, logger zerolog.Logger, metricsReg *metrics.Regi
 	if config.BufferSize == 0 {
 		config.BufferSize = 10000
 	}
+	if config.PriorityBufferSize == 0 {
+		config.PriorityBufferSize = 1000
+	}
 	if config.PublishTimeout == 0 {
 		config.PublishTimeout = 5 * time.Second
 	}
@@ -120,20 +164,92 @@ func NewPublisher(config Config, logger zerolog.Logger, metricsReg *metrics.Regi
 	if config.ReconnectDelay == 0 {
 		config.ReconnectDelay = 5 * time.Second
 	}
+	if config.BufferMaxBytes == 0 {
+		config.BufferMaxBytes = 1 << 30
+	}
+	if config.BufferMaxAge == 0 {
+		config.BufferMaxAge = 72 * time.Hour
+	}
+	if config.BufferSegmentSize == 0 {
+		config.BufferSegmentSize = 4 << 20
+	}
+	// Keep several segments within the bound so the size limit drops data
+	// in reasonably small steps
+	config.BufferSegmentSize = max(min(config.BufferSegmentSize, config.BufferMaxBytes/40), 64<<10)
 
 	p := &Publisher{
-		config:        config,
-		logger:        logger.With().Str("component", "mqtt-publisher").Logger(),
-		metrics:       metricsReg,
-		messageBuffer: make(chan *BufferedMessage, config.BufferSize),
-		done:          make(chan struct{}),
-		stats:         &PublisherStats{},
-		topicStats:    make(map[string]*TopicStat),
+		config:  config,
+		logger:  logger.With().Str("component", "mqtt-publisher").Logger(),
+		metrics: metricsReg,
+		lanes: [3]chan *BufferedMessage{
+			domain.PriorityTelemetry: make(chan *BufferedMessage, config.BufferSize),
+			domain.PriorityControl:   make(chan *BufferedMessage, config.PriorityBufferSize),
+			domain.PrioritySafety:    make(chan *BufferedMessage, config.PriorityBufferSize),
+		},
+		done:       make(chan struct{}),
+		stats:      &PublisherStats{},
+		topicStats: make(map[string]*TopicStat),
+	}
+
+	switch config.PayloadFormat {
+	case "", PayloadFormatJSON:
+	case PayloadFormatSparkplugB:
+		if config.SparkplugGroupID == "" {
+			return nil, fmt.Errorf("%w: sparkplug group ID is required", domain.ErrInvalidConfig)
+		}
+		edgeNodeID := config.SparkplugEdgeNodeID
+		if edgeNodeID == "" {
+			edgeNodeID = config.ClientID
+		}
+		p.sparkplug = newSparkplugNode(sparkplugID(config.SparkplugGroupID), sparkplugID(edgeNodeID), p.sendSparkplug, p.logger)
+	default:
+		return nil, fmt.Errorf("%w: unknown payload format %q", domain.ErrInvalidConfig, config.PayloadFormat)
+	}
+
+	if config.BufferDir != "" {
+		if err := p.openDiskQueues(); err != nil {
+			return nil, err
+		}
 	}
 
 	return p, nil
 }
 
+// openDiskQueues opens one store-and-forward queue per priority tier.
+func (p *Publisher) openDiskQueues() error {
+	names := [3]string{
+		domain.PriorityTelemetry: "telemetry",
+		domain.PriorityControl:   "control",
+		domain.PrioritySafety:    "safety",
+	}
+	for priority, name := range names {
+		maxBytes := p.config.BufferMaxBytes
+		if priority != int(domain.PriorityTelemetry) {
+			maxBytes /= 10
+		}
+		q, err := openDiskQueue(filepath.Join(p.config.BufferDir, name), maxBytes, p.config.BufferMaxAge, p.config.BufferSegmentSize, p.logger)
+		if err != nil {
+			for _, opened := range p.disk[:priority] {
+				_ = opened.close()
+			}
+			return fmt.Errorf("failed to open %s store-and-forward buffer: %w", name, err)
+		}
+		p.disk[priority] = q
+	}
+	return nil
+}
+
+// diskEnabled reports whether the store-and-forward buffer is configured.
+func (p *Publisher) diskEnabled() bool {
+	return p.disk[domain.PriorityTelemetry] != nil
+}
+
+// backlogPending reports whether the store-and-forward buffer of a priority
+// tier holds messages not replayed yet.
+func (p *Publisher) backlogPending(priority uint8) bool {
+	return p.diskEnabled() && !p.disk[min(priority, domain.PrioritySafety)].empty()
+}
+
 // ActiveTopics returns the most recently published topics, sorted by recency.
 // If limit <= 0, a default limit of 200 is used.
 func (p *Publisher) ActiveTopics(limit int) []TopicStat {
@@ -238,6 +354,15 @@ func (p *Publisher) Connect(ctx context.Context) error {
 		opts.SetTLSConfig(tlsConfig)
 	}
 
+	// Sparkplug B: the broker publishes NDEATH if we disappear
+	if p.sparkplug != nil {
+		will, err := p.sparkplug.beginSession()
+		if err != nil {
+			return fmt.Errorf("failed to encode NDEATH: %w", err)
+		}
+		opts.SetBinaryWill(p.sparkplug.nodeTopic("NDEATH"), will, 1, false)
+	}
+
 	// Connection handlers
 	opts.SetOnConnectHandler(p.onConnect)
 	opts.SetConnectionLostHandler(p.onConnectionLost)
@@ -279,6 +404,12 @@ func (p *Publisher) Connect(ctx context.Context) error {
 	p.wg.Add(1)
 	go p.processBuffer()
 
+	// Start store-and-forward replay
+	if p.diskEnabled() {
+		p.wg.Add(1)
+		go p.replayBacklog()
+	}
+
 	p.logger.Info().Msg("Connected to MQTT broker")
 	return nil
 }
@@ -296,6 +427,21 @@ func (p *Publisher) Disconnect() {
 	}
 	p.wg.Wait()
 
+	if p.sparkplug != nil {
+		if err := p.sparkplug.death(); err != nil {
+			p.logger.Warn().Err(err).Msg("Failed to publish Sparkplug B node death")
+		}
+	}
+
+	// Flush the store-and-forward buffer; it is reopened lazily on the next write
+	for _, q := range p.disk {
+		if q != nil {
+			if err := q.close(); err != nil {
+				p.logger.Warn().Err(err).Msg("Failed to close store-and-forward buffer")
+			}
+		}
+	}
+
 	// Disconnect client
 	p.mu.Lock()
 	defer p.mu.Unlock()
@@ -310,8 +456,15 @@ func (p *Publisher) Disconnect() {
 
 // Publish publishes a data point to the MQTT broker.
 func (p *Publisher) Publish(ctx context.Context, dataPoint *domain.DataPoint) error {
-	if !p.connected.Load() {
-		// Buffer the message for later
+	if p.sparkplug != nil {
+		return p.sparkplug.publishData([]*domain.DataPoint{dataPoint})
+	}
+
+	if !p.connected.Load() || p.backlogPending(dataPoint.Priority) {
+		// Buffer the message for later. While a tier's store-and-forward
+		// backlog is replayed, its new points queue behind it so they reach
+		// the broker in timestamp order; other tiers publish live, so a new
+		// alarm doesn't wait for hours of telemetry.
 		return p.bufferMessage(dataPoint)
 	}
 
@@ -320,11 +473,47 @@ func (p *Publisher) Publish(ctx context.Context, dataPoint *domain.DataPoint) er
 		return fmt.Errorf("failed to serialize data point: %w", err)
 	}
 
-	return p.publishRaw(ctx, dataPoint.Topic, payload, p.config.QoS, p.config.RetainMessages)
+	err = p.publishRaw(ctx, dataPoint.Topic, payload, p.qosFor(dataPoint.Priority), p.retainFor(dataPoint))
+	if err != nil && p.diskEnabled() {
+		// Store and forward instead of losing the point
+		return p.enqueue(p.newBufferedMessage(dataPoint, payload))
+	}
+	return err
+}
+
+// qosFor returns the MQTT QoS for a priority tier. A higher tier never gets
+// a lower QoS than telemetry, whatever the per-tier settings say.
+func (p *Publisher) qosFor(priority uint8) byte {
+	var qos byte
+	switch {
+	case priority >= domain.PrioritySafety:
+		qos = p.config.SafetyQoS
+	case priority == domain.PriorityControl:
+		qos = p.config.ControlQoS
+	default:
+		return p.config.QoS
+	}
+	return max(qos, p.config.QoS)
+}
+
+// retainFor reports whether a data point is published retained. Backfilled
+// values never are, or they would replace the current value.
+func (p *Publisher) retainFor(dataPoint *domain.DataPoint) bool {
+	return p.config.RetainMessages && !dataPoint.Backfilled
+}
+
+// lane returns the offline buffer lane for a priority tier.
+func (p *Publisher) lane(priority uint8) chan *BufferedMessage {
+	return p.lanes[min(priority, domain.PrioritySafety)]
 }
 
 // PublishBatch publishes multiple data points efficiently.
 func (p *Publisher) PublishBatch(ctx context.Context, dataPoints []*domain.DataPoint) error {
+	if p.sparkplug != nil {
+		// One DDATA per device instead of one message per tag
+		return p.sparkplug.publishData(dataPoints)
+	}
+
 	var lastErr error
 	for _, dp := range dataPoints {
 		if err := p.Publish(ctx, dp); err != nil {
@@ -397,39 +586,66 @@ func (p *Publisher) bufferMessage(dataPoint *domain.DataPoint) error {
 	if err != nil {
 		return fmt.Errorf("failed to serialize data point: %w", err)
 	}
+	return p.enqueue(p.newBufferedMessage(dataPoint, payload))
+}
 
-	msg := &BufferedMessage{
+// newBufferedMessage creates a buffered message carrying the data point's
+// original sample time, so replay order follows the process, not the outage.
+func (p *Publisher) newBufferedMessage(dataPoint *domain.DataPoint, payload []byte) *BufferedMessage {
+	timestamp := dataPoint.Timestamp
+	if timestamp.IsZero() {
+		timestamp = time.Now()
+	}
+	return &BufferedMessage{
 		Topic:     dataPoint.Topic,
 		Payload:   payload,
-		QoS:       p.config.QoS,
-		Retained:  p.config.RetainMessages,
-		Timestamp: time.Now(),
+		QoS:       p.qosFor(dataPoint.Priority),
+		Retained:  p.retainFor(dataPoint),
+		Priority:  dataPoint.Priority,
+		Timestamp: timestamp,
+	}
+}
+
+// enqueue stores a message in the store-and-forward buffer if configured,
+// otherwise in its in-memory lane.
+func (p *Publisher) enqueue(msg *BufferedMessage) error {
+	if p.diskEnabled() {
+		err := p.disk[min(msg.Priority, domain.PrioritySafety)].append(msg)
+		if err == nil {
+			p.stats.MessagesBuffered.Add(1)
+			return nil
+		}
+		// Disk full or unavailable: keep the message in memory rather than lose it
+		p.logger.Warn().Err(err).Str("topic", msg.Topic).Msg("Failed to persist message, buffering in memory")
 	}
 
+	lane := p.lane(msg.Priority)
+
 	select {
-	case p.messageBuffer <- msg:
+	case lane <- msg:
 		p.stats.MessagesBuffered.Add(1)
 		if p.metrics != nil {
-			p.metrics.UpdateMQTTBufferSize(len(p.messageBuffer))
+			p.metrics.UpdateMQTTBufferSize(p.BufferSize())
 		}
 		return nil
 	default:
-		// Buffer full — drop oldest to make room.
+		// Lane full — drop its oldest message to make room. Other lanes are
+		// never touched, so telemetry can't evict control or safety messages.
 		// Drain and re-send are both non-blocking to avoid a race where
 		// processBuffer() or another goroutine alters the channel between
 		// the drain and the send, which could cause this goroutine to block.
 		select {
-		case <-p.messageBuffer:
+		case <-lane:
 			// Drained one old message
 		default:
 			// processBuffer already drained it — space exists now
 		}
 		select {
-		case p.messageBuffer <- msg:
-			p.logger.Warn().Msg("Buffer full, dropped oldest message")
+		case lane <- msg:
+			p.logger.Warn().Uint8("priority", msg.Priority).Msg("Buffer full, dropped oldest message")
 			p.stats.MessagesBuffered.Add(1)
 			if p.metrics != nil {
-				p.metrics.UpdateMQTTBufferSize(len(p.messageBuffer))
+				p.metrics.UpdateMQTTBufferSize(p.BufferSize())
 			}
 			return nil
 		default:
@@ -447,50 +663,99 @@ func (p *Publisher) processBuffer() {
 	maxBackoff := 5 * time.Second
 
 	for {
-		select {
-		case <-p.done:
+		msg, ok := p.receiveBuffered()
+		if !ok {
 			// Drain remaining messages
 			p.drainBuffer()
 			return
+		}
 
-		case msg := <-p.messageBuffer:
-			if p.connected.Load() {
-				ctx, cancel := context.WithTimeout(context.Background(), p.config.PublishTimeout)
-				if err := p.publishRaw(ctx, msg.Topic, msg.Payload, msg.QoS, msg.Retained); err != nil {
-					p.logger.Warn().Err(err).Str("topic", msg.Topic).Msg("Failed to publish buffered message")
-				}
-				cancel()
-				backoff = 100 * time.Millisecond // Reset backoff on success
-				// Update buffer size metric after draining
-				if p.metrics != nil {
-					p.metrics.UpdateMQTTBufferSize(len(p.messageBuffer))
-				}
-			} else {
-				// Re-buffer if not connected (non-blocking to avoid deadlock)
-				select {
-				case p.messageBuffer <- msg:
-				default:
-					// Buffer still full, drop message
-					p.logger.Debug().Str("topic", msg.Topic).Msg("Dropped message: buffer full while disconnected")
-				}
-				// Exponential backoff to prevent spin-loop when disconnected
-				select {
-				case <-p.done:
-					return
-				case <-time.After(backoff):
-				}
-				if backoff < maxBackoff {
-					backoff *= 2
-				}
+		if p.connected.Load() {
+			ctx, cancel := context.WithTimeout(context.Background(), p.config.PublishTimeout)
+			if err := p.publishRaw(ctx, msg.Topic, msg.Payload, msg.QoS, msg.Retained); err != nil {
+				p.logger.Warn().Err(err).Str("topic", msg.Topic).Msg("Failed to publish buffered message")
+			}
+			cancel()
+			backoff = 100 * time.Millisecond // Reset backoff on success
+			// Update buffer size metric after draining
+			if p.metrics != nil {
+				p.metrics.UpdateMQTTBufferSize(p.BufferSize())
+			}
+		} else {
+			// Re-buffer if not connected (non-blocking to avoid deadlock)
+			select {
+			case p.lane(msg.Priority) <- msg:
+			default:
+				// Buffer still full, drop message
+				p.logger.Debug().Str("topic", msg.Topic).Msg("Dropped message: buffer full while disconnected")
+			}
+			// Exponential backoff to prevent spin-loop when disconnected
+			select {
+			case <-p.done:
+				return
+			case <-time.After(backoff):
+			}
+			if backoff < maxBackoff {
+				backoff *= 2
 			}
 		}
 	}
 }
 
+// receiveBuffered blocks until a buffered message is available and returns the
+// oldest message of the highest-priority non-empty lane: safety > control >
+// telemetry. It returns false when the publisher is shutting down.
+func (p *Publisher) receiveBuffered() (*BufferedMessage, bool) {
+	safety := p.lanes[domain.PrioritySafety]
+	control := p.lanes[domain.PriorityControl]
+	telemetry := p.lanes[domain.PriorityTelemetry]
+
+	// Each select level includes p.done to ensure clean shutdown.
+	select {
+	case msg := <-safety:
+		return msg, true
+	case <-p.done:
+		return nil, false
+	default:
+	}
+	select {
+	case msg := <-safety:
+		return msg, true
+	case msg := <-control:
+		return msg, true
+	case <-p.done:
+		return nil, false
+	default:
+	}
+	select {
+	case msg := <-safety:
+		return msg, true
+	case msg := <-control:
+		return msg, true
+	case msg := <-telemetry:
+		return msg, true
+	case <-p.done:
+		return nil, false
+	}
+}
+
+// nextBuffered returns the oldest message of the highest-priority non-empty
+// lane without blocking, or nil if all lanes are empty.
+func (p *Publisher) nextBuffered() *BufferedMessage {
+	for priority := int(domain.PrioritySafety); priority >= 0; priority-- {
+		select {
+		case msg := <-p.lanes[priority]:
+			return msg
+		default:
+		}
+	}
+	return nil
+}
+
 // drainBuffer attempts to publish all remaining buffered messages.
 // Timeout scales with buffer size: base 10s + 1ms per message, capped at 60s.
 func (p *Publisher) drainBuffer() {
-	bufferLen := len(p.messageBuffer)
+	bufferLen := p.BufferSize()
 	drainTimeout := 10*time.Second + time.Duration(bufferLen)*time.Millisecond
 	if drainTimeout > 60*time.Second {
 		drainTimeout = 60 * time.Second
@@ -498,26 +763,13 @@ func (p *Publisher) drainBuffer() {
 
 	p.logger.Debug().Int("buffered", bufferLen).Dur("timeout", drainTimeout).Msg("Draining message buffer")
 
-	timeout := time.After(drainTimeout)
+	// Safety and control lanes drain first, so they are the last to be
+	// dropped if the timeout hits.
+	deadline := time.Now().Add(drainTimeout)
 	drained := 0
 	for {
-		select {
-		case msg := <-p.messageBuffer:
-			if p.connected.Load() {
-				ctx, cancel := context.WithTimeout(context.Background(), p.config.PublishTimeout)
-				if err := p.publishRaw(ctx, msg.Topic, msg.Payload, msg.QoS, msg.Retained); err != nil {
-					p.logger.Warn().Err(err).Str("topic", msg.Topic).Msg("Failed to drain buffered message")
-				} else {
-					drained++
-				}
-				cancel()
-			}
-			// Update buffer size metric during drain
-			if p.metrics != nil {
-				p.metrics.UpdateMQTTBufferSize(len(p.messageBuffer))
-			}
-		case <-timeout:
-			remaining := len(p.messageBuffer)
+		if time.Now().After(deadline) {
+			remaining := p.BufferSize()
 			if remaining > 0 {
 				p.logger.Warn().Int("drained", drained).Int("dropped", remaining).Msg("Timeout draining buffer, messages dropped")
 			} else if drained > 0 {
@@ -525,31 +777,195 @@ func (p *Publisher) drainBuffer() {
 			}
 			// Final buffer size update
 			if p.metrics != nil {
-				p.metrics.UpdateMQTTBufferSize(len(p.messageBuffer))
+				p.metrics.UpdateMQTTBufferSize(p.BufferSize())
 			}
 			return
-		default:
+		}
+
+		msg := p.nextBuffered()
+		if msg == nil {
 			if drained > 0 {
 				p.logger.Debug().Int("drained", drained).Msg("Buffer drained")
 			}
 			// Final buffer size update
 			if p.metrics != nil {
-				p.metrics.UpdateMQTTBufferSize(len(p.messageBuffer))
+				p.metrics.UpdateMQTTBufferSize(p.BufferSize())
+			}
+			return
+		}
+
+		if p.connected.Load() {
+			ctx, cancel := context.WithTimeout(context.Background(), p.config.PublishTimeout)
+			if err := p.publishRaw(ctx, msg.Topic, msg.Payload, msg.QoS, msg.Retained); err != nil {
+				p.logger.Warn().Err(err).Str("topic", msg.Topic).Msg("Failed to drain buffered message")
+			} else {
+				drained++
+			}
+			cancel()
+		}
+		// Update buffer size metric during drain
+		if p.metrics != nil {
+			p.metrics.UpdateMQTTBufferSize(p.BufferSize())
+		}
+	}
+}
+
+// replayWindow is the number of replayed messages kept in flight at once.
+// Waiting for each acknowledgement in turn would cap replay at one message
+// per broker round trip, too slow to catch up after a long outage.
+const replayWindow = 256
+
+// replayBacklog periodically publishes the store-and-forward backlog while
+// connected and keeps the backlog metrics current.
+func (p *Publisher) replayBacklog() {
+	defer p.wg.Done()
+
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+
+	var reportedDropped uint64
+	for {
+		if p.connected.Load() {
+			p.replayQueues()
+		}
+
+		if p.metrics != nil {
+			backlog := p.Backlog()
+			p.metrics.UpdateMQTTBacklog(backlog.Messages, backlog.Bytes, backlog.OldestAge.Seconds(), backlog.Dropped-reportedDropped)
+			reportedDropped = backlog.Dropped
+		}
+
+		select {
+		case <-p.done:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// replayQueues drains the disk queues, safety first, then control, then
+// telemetry; each queue replays in timestamp order. It stops at the first
+// failed publish; the unpublished records stay queued for the next attempt.
+func (p *Publisher) replayQueues() {
+	replayed := 0
+	defer func() {
+		if replayed > 0 {
+			p.logger.Info().Int("replayed", replayed).Msg("Replayed store-and-forward backlog")
+		}
+	}()
+
+	heads := make([][]*BufferedMessage, len(p.disk))
+	for {
+		select {
+		case <-p.done:
+			return
+		default:
+		}
+		if !p.connected.Load() {
+			return
+		}
+
+		for priority, q := range p.disk {
+			records, err := q.peek(time.Now())
+			if err != nil {
+				records = nil // Unreadable segment was dropped; read on next round
 			}
+			heads[priority] = records
+		}
+
+		window, sources := fillWindow(heads, replayWindow)
+		if len(window) == 0 {
+			return
+		}
+
+		published := p.publishWindow(window)
+		committed := make([]int, len(p.disk))
+		for _, source := range sources[:published] {
+			committed[source]++
+		}
+		for priority, q := range p.disk {
+			if committed[priority] > 0 {
+				q.commit(committed[priority])
+			}
+		}
+		replayed += published
+		if published < len(window) {
 			return
 		}
 	}
 }
 
+// fillWindow takes at most limit of the oldest records of several queues,
+// highest priority (last index) first, and returns each message's queue
+// index. Records of one queue keep their order; a queue that runs out leaves
+// the rest of the window to the next one.
+func fillWindow(heads [][]*BufferedMessage, limit int) ([]*BufferedMessage, []int) {
+	window := make([]*BufferedMessage, 0, limit)
+	sources := make([]int, 0, limit)
+	for i := len(heads) - 1; i >= 0 && len(window) < limit; i-- {
+		records := heads[i][:min(len(heads[i]), limit-len(window))]
+		window = append(window, records...)
+		for range records {
+			sources = append(sources, i)
+		}
+	}
+	return window, sources
+}
+
+// publishWindow publishes msgs without waiting for each acknowledgement in
+// turn and returns how many leading messages were acknowledged.
+func (p *Publisher) publishWindow(msgs []*BufferedMessage) int {
+	p.mu.RLock()
+	client := p.client
+	p.mu.RUnlock()
+	if client == nil {
+		return 0
+	}
+
+	tokens := make([]pahomqtt.Token, len(msgs))
+	for i, msg := range msgs {
+		tokens[i] = client.Publish(msg.Topic, msg.QoS, msg.Retained, msg.Payload)
+	}
+
+	deadline := time.NewTimer(p.config.PublishTimeout)
+	defer deadline.Stop()
+
+	for i, token := range tokens {
+		select {
+		case <-token.Done():
+		case <-deadline.C:
+			p.stats.MessagesFailed.Add(1)
+			return i
+		case <-p.done:
+			return i
+		}
+		if err := token.Error(); err != nil {
+			p.stats.MessagesFailed.Add(1)
+			p.logger.Warn().Err(err).Str("topic", msgs[i].Topic).Msg("Failed to replay buffered message")
+			return i
+		}
+		p.stats.MessagesPublished.Add(1)
+		p.stats.BytesSent.Add(uint64(len(msgs[i].Payload)))
+		p.recordTopicPublish(msgs[i].Topic, len(msgs[i].Payload))
+	}
+	return len(tokens)
+}
+
 // createTLSConfig creates TLS configuration for secure connections.
 func (p *Publisher) createTLSConfig() (*tls.Config, error) {
+	return newTLSConfig(p.config.TLSCAFile, p.config.TLSCertFile, p.config.TLSKeyFile)
+}
+
+// newTLSConfig creates a TLS configuration from an optional CA certificate
+// and an optional client certificate and key.
+func newTLSConfig(caFile, certFile, keyFile string) (*tls.Config, error) {
 	tlsConfig := &tls.Config{
 		MinVersion: tls.VersionTLS12,
 	}
 
 	// Load CA certificate
-	if p.config.TLSCAFile != "" {
-		caCert, err := os.ReadFile(p.config.TLSCAFile)
+	if caFile != "" {
+		caCert, err := os.ReadFile(caFile)
 		if err != nil {
 			return nil, fmt.Errorf("failed to read CA certificate: %w", err)
 		}
@@ -561,8 +977,8 @@ func (p *Publisher) createTLSConfig() (*tls.Config, error) {
 	}
 
 	// Load client certificate and key
-	if p.config.TLSCertFile != "" && p.config.TLSKeyFile != "" {
-		cert, err := tls.LoadX509KeyPair(p.config.TLSCertFile, p.config.TLSKeyFile)
+	if certFile != "" && keyFile != "" {
+		cert, err := tls.LoadX509KeyPair(certFile, keyFile)
 		if err != nil {
 			return nil, fmt.Errorf("failed to load client certificate: %w", err)
 		}
@@ -582,12 +998,20 @@ func (p *Publisher) onConnect(client pahomqtt.Client) {
 	if p.metrics != nil {
 		// Record reconnect if this wasn't the initial connection
 	}
+
+	if p.sparkplug != nil {
+		p.sparkplugOnline(client)
+	}
 }
 
 // onConnectionLost is called when the connection is lost.
 func (p *Publisher) onConnectionLost(client pahomqtt.Client, err error) {
 	p.connected.Store(false)
 	p.logger.Warn().Err(err).Msg("MQTT connection lost")
+
+	if p.sparkplug != nil {
+		p.sparkplug.connectionLost()
+	}
 }
 
 // onReconnecting is called when the client is attempting to reconnect.
@@ -608,9 +1032,41 @@ func (p *Publisher) Stats() *PublisherStats {
 	return p.stats
 }
 
-// BufferSize returns the current number of buffered messages.
+// BufferSize returns the current number of buffered messages across all lanes.
 func (p *Publisher) BufferSize() int {
-	return len(p.messageBuffer)
+	total := 0
+	for _, lane := range p.lanes {
+		total += len(lane)
+	}
+	return total
+}
+
+// LaneSizes returns the number of buffered messages per priority tier.
+func (p *Publisher) LaneSizes() [3]int {
+	var sizes [3]int
+	for i, lane := range p.lanes {
+		sizes[i] = len(lane)
+	}
+	return sizes
+}
+
+// Backlog returns the depth and age of the buffered backlog. With the
+// store-and-forward buffer disabled, only the in-memory depth is known.
+func (p *Publisher) Backlog() BacklogStats {
+	if !p.diskEnabled() {
+		return BacklogStats{Messages: p.BufferSize()}
+	}
+
+	now := time.Now()
+	backlog := BacklogStats{Messages: p.BufferSize()}
+	for _, q := range p.disk {
+		stats := q.stats(now)
+		backlog.Messages += stats.Messages
+		backlog.Bytes += stats.Bytes
+		backlog.Dropped += stats.Dropped
+		backlog.OldestAge = max(backlog.OldestAge, stats.OldestAge)
+	}
+	return backlog
 }
 
 // HealthCheck implements the health.Checker interface.
@@ -648,6 +1104,113 @@ func (p *Publisher) PublishDeviceStatus(ctx context.Context, deviceID string, st
 		return fmt.Errorf("failed to marshal status payload: %w", err)
 	}
 
+	if p.sparkplug != nil {
+		p.sparkplug.setDeviceOnline(deviceID, status == "online")
+	}
+
 	topic := "$nexus/status/devices/" + deviceID
 	return p.publishRaw(ctx, topic, data, 1, true) // QoS 1, retained
 }
+
+// PublishAlarm publishes an alarm event to its alarm topic. Alarm events are
+// retained, so the topic always holds the condition's current state, and use
+// the safety tier's QoS and buffer lane. They are plain JSON in Sparkplug B
+// mode too.
+func (p *Publisher) PublishAlarm(ctx context.Context, event *domain.AlarmEvent) error {
+	payload, err := event.ToJSON()
+	if err != nil {
+		return fmt.Errorf("failed to serialize alarm event: %w", err)
+	}
+	return p.publishEvent(ctx, event.Topic, payload, true, domain.PrioritySafety, event.Time)
+}
+
+// PublishDeviceInfo publishes a device's identification, state and recent
+// diagnostics to its info topic, retained so the topic holds the latest
+// readout. Plain JSON in Sparkplug B mode too.
+func (p *Publisher) PublishDeviceInfo(ctx context.Context, info *domain.DeviceInfo) error {
+	payload, err := info.ToJSON()
+	if err != nil {
+		return fmt.Errorf("failed to serialize device info: %w", err)
+	}
+	return p.publishEvent(ctx, info.Topic, payload, true, domain.PriorityControl, info.Time)
+}
+
+// PublishDiagnosticEvent publishes a new diagnostic buffer entry of a device
+// to its diagnostics topic. Events are not retained; the latest entries are
+// part of the device info.
+func (p *Publisher) PublishDiagnosticEvent(ctx context.Context, event *domain.DiagnosticEvent) error {
+	payload, err := event.ToJSON()
+	if err != nil {
+		return fmt.Errorf("failed to serialize diagnostic event: %w", err)
+	}
+	return p.publishEvent(ctx, event.Topic, payload, false, domain.PriorityControl, event.Time)
+}
+
+// publishEvent publishes a device event with the QoS and buffer lane of its
+// priority. Events must not be lost: they are buffered while disconnected or
+// if publishing fails, for the next reconnect.
+func (p *Publisher) publishEvent(ctx context.Context, topic string, payload []byte, retained bool, priority uint8, ts time.Time) error {
+	msg := &BufferedMessage{
+		Topic:     topic,
+		Payload:   payload,
+		QoS:       p.qosFor(priority),
+		Retained:  retained,
+		Priority:  priority,
+		Timestamp: ts,
+	}
+	if msg.Timestamp.IsZero() {
+		msg.Timestamp = time.Now()
+	}
+
+	if !p.connected.Load() {
+		return p.enqueue(msg)
+	}
+	if err := p.publishRaw(ctx, msg.Topic, msg.Payload, msg.QoS, msg.Retained); err != nil {
+		return p.enqueue(msg)
+	}
+	return nil
+}
+
+// DeviceBirth announces a registered or reconfigured device. In Sparkplug B
+// mode this publishes its DBIRTH with the current metric set.
+func (p *Publisher) DeviceBirth(device *domain.Device) {
+	if p.sparkplug != nil {
+		p.sparkplug.setDevice(device)
+	}
+}
+
+// DeviceDeath announces a removed or disabled device. In Sparkplug B mode
+// this publishes its DDEATH.
+func (p *Publisher) DeviceDeath(deviceID string) {
+	if p.sparkplug != nil {
+		p.sparkplug.removeDevice(deviceID)
+	}
+}
+
+// sparkplugOnline subscribes to node commands and publishes the births for a
+// new connection. With a clean session the NCMD subscription is lost on every
+// disconnect, so it is renewed here.
+func (p *Publisher) sparkplugOnline(client pahomqtt.Client) {
+	ncmd := p.sparkplug.nodeTopic("NCMD")
+	token := client.Subscribe(ncmd, 1, func(_ pahomqtt.Client, msg pahomqtt.Message) {
+		// Don't block the paho router with publishes
+		go func() {
+			if err := p.sparkplug.handleCommand(msg.Payload()); err != nil {
+				p.logger.Warn().Err(err).Msg("Failed to handle Sparkplug B node command")
+			}
+		}()
+	})
+	if token.WaitTimeout(p.config.ConnectTimeout) && token.Error() != nil {
+		p.logger.Warn().Err(token.Error()).Str("topic", ncmd).Msg("Failed to subscribe to Sparkplug B node commands")
+	}
+
+	if err := p.sparkplug.birth(); err != nil {
+		p.logger.Error().Err(err).Msg("Failed to publish Sparkplug B births")
+	}
+}
+
+// sendSparkplug publishes a Sparkplug B message. All Sparkplug messages other
+// than the NDEATH will use QoS 0 and are never retained.
+func (p *Publisher) sendSparkplug(topic string, payload []byte) error {
+	return p.publishRaw(context.Background(), topic, payload, 0, false)
+}
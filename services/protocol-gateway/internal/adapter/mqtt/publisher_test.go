@@ -0,0 +1,90 @@
+package mqtt
+
+import (
+	"context"
+	"testing"
+
+	"github.com/nexus-edge/protocol-gateway/internal/domain"
+	"github.com/rs/zerolog"
+)
+
+func newOfflinePublisher(t *testing.T, bufferSize, priorityBufferSize int) *Publisher {
+	t.Helper()
+	config := DefaultConfig()
+	config.BufferSize = bufferSize
+	config.PriorityBufferSize = priorityBufferSize
+	p, err := NewPublisher(config, zerolog.Nop(), nil)
+	if err != nil {
+		t.Fatalf("NewPublisher: %v", err)
+	}
+	return p
+}
+
+func priorityPoint(topic string, priority uint8) *domain.DataPoint {
+	return domain.NewDataPoint("dev", topic, topic, 1.0, "", domain.QualityGood).WithPriority(priority)
+}
+
+func TestPublisher_BufferedLanesDrainByPriority(t *testing.T) {
+	p := newOfflinePublisher(t, 10, 10)
+	ctx := context.Background()
+
+	for _, dp := range []*domain.DataPoint{
+		priorityPoint("telemetry-1", domain.PriorityTelemetry),
+		priorityPoint("control-1", domain.PriorityControl),
+		priorityPoint("telemetry-2", domain.PriorityTelemetry),
+		priorityPoint("safety-1", domain.PrioritySafety),
+	} {
+		if err := p.Publish(ctx, dp); err != nil {
+			t.Fatalf("Publish while disconnected: %v", err)
+		}
+	}
+
+	want := []string{"safety-1", "control-1", "telemetry-1", "telemetry-2"}
+	for _, topic := range want {
+		msg := p.nextBuffered()
+		if msg == nil || msg.Topic != topic {
+			t.Fatalf("expected %s, got %+v", topic, msg)
+		}
+	}
+	if msg := p.nextBuffered(); msg != nil {
+		t.Errorf("expected empty buffer, got %s", msg.Topic)
+	}
+}
+
+func TestPublisher_TelemetryFloodDoesNotEvictSafety(t *testing.T) {
+	p := newOfflinePublisher(t, 2, 2)
+	ctx := context.Background()
+
+	_ = p.Publish(ctx, priorityPoint("alarm", domain.PrioritySafety))
+	for i := 0; i < 50; i++ {
+		_ = p.Publish(ctx, priorityPoint("telemetry", domain.PriorityTelemetry))
+	}
+
+	sizes := p.LaneSizes()
+	if sizes[domain.PrioritySafety] != 1 {
+		t.Errorf("expected buffered alarm to survive, safety lane has %d", sizes[domain.PrioritySafety])
+	}
+	if sizes[domain.PriorityTelemetry] != 2 {
+		t.Errorf("expected telemetry lane capped at 2, got %d", sizes[domain.PriorityTelemetry])
+	}
+	if msg := p.nextBuffered(); msg == nil || msg.Topic != "alarm" || msg.QoS != 2 {
+		t.Errorf("expected alarm with QoS 2 first, got %+v", msg)
+	}
+}
+
+func TestPublisher_QoSPerPriority(t *testing.T) {
+	p := newOfflinePublisher(t, 1, 1)
+	p.config.QoS, p.config.ControlQoS, p.config.SafetyQoS = 0, 1, 2
+
+	for priority, want := range map[uint8]byte{0: 0, 1: 1, 2: 2} {
+		if got := p.qosFor(priority); got != want {
+			t.Errorf("priority %d: expected QoS %d, got %d", priority, want, got)
+		}
+	}
+
+	// Higher tiers are never downgraded below telemetry
+	p.config.QoS, p.config.ControlQoS = 2, 0
+	if got := p.qosFor(domain.PriorityControl); got != 2 {
+		t.Errorf("expected control QoS raised to 2, got %d", got)
+	}
+}
@@ -0,0 +1,208 @@
+// Package mqtt provides the broker clients of the MQTT source pool.
+package mqtt
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"slices"
+	"sync"
+	"sync/atomic"
+
+	pahomqtt "github.com/eclipse/paho.mqtt.golang"
+	"github.com/nexus-edge/protocol-gateway/internal/domain"
+	"github.com/rs/zerolog"
+)
+
+// brokerKey identifies the broker client a device uses. Devices with the same
+// broker URL, credentials, session and TLS settings share one client.
+func brokerKey(conn *domain.ConnectionConfig) string {
+	h := sha256.New()
+	for _, part := range []string{
+		conn.MQTTBrokerURL,
+		conn.MQTTUsername,
+		conn.MQTTPassword,
+		conn.MQTTClientIDPrefix,
+		fmt.Sprint(conn.MQTTCleanSession, conn.MQTTTLSEnabled),
+		conn.MQTTTLSCAFile,
+		conn.MQTTTLSCertFile,
+		conn.MQTTTLSKeyFile,
+	} {
+		h.Write([]byte(part))
+		h.Write([]byte{0})
+	}
+	return hex.EncodeToString(h.Sum(nil))[:16]
+}
+
+// sourceClient is one connection to a source broker, shared by all devices
+// with the same broker key. Each device has one wildcard subscription; devices
+// with the same source prefix share it.
+type sourceClient struct {
+	key     string
+	broker  string
+	client  pahomqtt.Client
+	config  SourceConfig
+	logger  zerolog.Logger
+	deliver func(d *sourceDevice, topic string, payload []byte)
+
+	connected atomic.Bool
+
+	mu      sync.Mutex
+	devices map[string]*sourceDevice   // By device ID
+	filters map[string][]*sourceDevice // Devices by subscription filter
+}
+
+func newSourceClient(key string, conn *domain.ConnectionConfig, config SourceConfig, logger zerolog.Logger,
+	deliver func(d *sourceDevice, topic string, payload []byte)) (*sourceClient, error) {
+	c := &sourceClient{
+		key:     key,
+		broker:  conn.MQTTBrokerURL,
+		config:  config,
+		logger:  logger.With().Str("broker", conn.MQTTBrokerURL).Str("broker_key", key).Logger(),
+		deliver: deliver,
+		devices: make(map[string]*sourceDevice),
+		filters: make(map[string][]*sourceDevice),
+	}
+
+	prefix := conn.MQTTClientIDPrefix
+	if prefix == "" {
+		prefix = config.ClientIDPrefix
+	}
+	opts := pahomqtt.NewClientOptions()
+	opts.AddBroker(conn.MQTTBrokerURL)
+	opts.SetClientID(prefix + "-" + key)
+	opts.SetCleanSession(conn.MQTTCleanSession)
+	opts.SetKeepAlive(config.KeepAlive)
+	opts.SetConnectTimeout(config.ConnectTimeout)
+	opts.SetAutoReconnect(true)
+	opts.SetMaxReconnectInterval(config.ReconnectDelay)
+	// Keep retrying the initial connection in the background; devices are
+	// subscribed once it is up
+	opts.SetConnectRetry(true)
+	opts.SetConnectRetryInterval(config.ReconnectDelay)
+
+	if conn.MQTTUsername != "" {
+		opts.SetUsername(conn.MQTTUsername)
+		opts.SetPassword(conn.MQTTPassword)
+	}
+	if conn.MQTTTLSEnabled {
+		tlsConfig, err := newTLSConfig(conn.MQTTTLSCAFile, conn.MQTTTLSCertFile, conn.MQTTTLSKeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create TLS config: %w", err)
+		}
+		opts.SetTLSConfig(tlsConfig)
+	}
+
+	opts.SetOnConnectHandler(c.onConnect)
+	opts.SetConnectionLostHandler(func(_ pahomqtt.Client, err error) {
+		c.connected.Store(false)
+		c.logger.Warn().Err(err).Msg("Source broker connection lost")
+	})
+
+	c.client = pahomqtt.NewClient(opts)
+	c.logger.Info().Msg("Connecting to source broker")
+	c.client.Connect()
+	return c, nil
+}
+
+// onConnect (re)subscribes all filters. Subscriptions don't survive a clean
+// session, and a persistent session may have been expired by the broker.
+func (c *sourceClient) onConnect(_ pahomqtt.Client) {
+	c.connected.Store(true)
+	c.logger.Info().Msg("Connected to source broker")
+
+	c.mu.Lock()
+	filters := make(map[string]byte, len(c.filters))
+	for filter, devices := range c.filters {
+		filters[filter] = maxQoS(devices)
+	}
+	c.mu.Unlock()
+
+	for filter, qos := range filters {
+		if err := c.subscribe(filter, qos); err != nil {
+			c.logger.Warn().Err(err).Str("filter", filter).Msg("Failed to resubscribe")
+		}
+	}
+}
+
+// register adds a device and returns the QoS its filter must be subscribed
+// with. The caller subscribes the filter if the client is connected;
+// otherwise onConnect does.
+func (c *sourceClient) register(d *sourceDevice) byte {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.devices[d.device.ID] = d
+	c.filters[d.filter] = append(c.filters[d.filter], d)
+	return maxQoS(c.filters[d.filter])
+}
+
+// unregister removes a device. unused reports whether no other device uses
+// its filter; remaining is the number of devices left on the client.
+func (c *sourceClient) unregister(deviceID string) (filter string, unused bool, remaining int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	d, ok := c.devices[deviceID]
+	if !ok {
+		return "", false, len(c.devices)
+	}
+	delete(c.devices, deviceID)
+	devices := slices.DeleteFunc(slices.Clone(c.filters[d.filter]), func(other *sourceDevice) bool { return other == d })
+	if len(devices) == 0 {
+		delete(c.filters, d.filter)
+	} else {
+		c.filters[d.filter] = devices
+	}
+	return d.filter, len(devices) == 0, len(c.devices)
+}
+
+func (c *sourceClient) subscribe(filter string, qos byte) error {
+	token := c.client.Subscribe(filter, qos, func(_ pahomqtt.Client, msg pahomqtt.Message) {
+		c.dispatch(filter, msg.Topic(), msg.Payload())
+	})
+	if !token.WaitTimeout(c.config.ConnectTimeout) {
+		return fmt.Errorf("%w: %s: timeout", domain.ErrMQTTSubscribeFailed, filter)
+	}
+	if err := token.Error(); err != nil {
+		return fmt.Errorf("%w: %s: %v", domain.ErrMQTTSubscribeFailed, filter, err)
+	}
+	c.logger.Debug().Str("filter", filter).Uint8("qos", qos).Msg("Subscribed")
+	return nil
+}
+
+func (c *sourceClient) unsubscribe(filter string) {
+	token := c.client.Unsubscribe(filter)
+	if token.WaitTimeout(c.config.ConnectTimeout) && token.Error() != nil {
+		c.logger.Warn().Err(token.Error()).Str("filter", filter).Msg("Failed to unsubscribe")
+	}
+}
+
+// dispatch hands a message to every device subscribed with the filter.
+func (c *sourceClient) dispatch(filter, topic string, payload []byte) {
+	c.mu.Lock()
+	devices := c.filters[filter]
+	c.mu.Unlock()
+
+	for _, d := range devices {
+		c.deliver(d, topic, payload)
+	}
+}
+
+func (c *sourceClient) isConnected() bool {
+	return c.connected.Load() && c.client.IsConnectionOpen()
+}
+
+func (c *sourceClient) close() {
+	c.connected.Store(false)
+	c.client.Disconnect(250)
+	c.logger.Info().Msg("Disconnected from source broker")
+}
+
+func maxQoS(devices []*sourceDevice) byte {
+	var qos byte
+	for _, d := range devices {
+		qos = max(qos, d.qos)
+	}
+	return qos
+}
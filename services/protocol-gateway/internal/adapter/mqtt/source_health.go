@@ -0,0 +1,76 @@
+// Package mqtt provides staleness detection for the MQTT source pool.
+package mqtt
+
+import (
+	"time"
+)
+
+// healthLoop periodically checks devices for staleness and updates the
+// broker connection metrics.
+func (p *SourcePool) healthLoop() {
+	defer p.wg.Done()
+
+	ticker := time.NewTicker(p.config.StalenessCheckInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-p.stopChan:
+			return
+		case now := <-ticker.C:
+			p.checkStaleness(now)
+			p.updateBrokerMetrics()
+		}
+	}
+}
+
+// checkStaleness marks devices stale that have received no message for
+// longer than their MQTTStalenessTimeout. A stale device isn't failing, it
+// is silent: its cached values are served with uncertain quality until the
+// next message arrives.
+func (p *SourcePool) checkStaleness(now time.Time) {
+	p.mu.Lock()
+	devices := make([]*sourceDevice, 0, len(p.devices))
+	for _, d := range p.devices {
+		devices = append(devices, d)
+	}
+	p.mu.Unlock()
+
+	for _, d := range devices {
+		timeout := d.device.Connection.MQTTStalenessTimeout
+		if timeout <= 0 {
+			continue
+		}
+
+		d.mu.Lock()
+		silent := now.Sub(d.lastMessage)
+		becameStale := !d.stale && silent > timeout
+		if becameStale {
+			d.stale = true
+		}
+		d.mu.Unlock()
+
+		if !becameStale {
+			continue
+		}
+		p.logger.Warn().
+			Str("device_id", d.device.ID).
+			Dur("silent_for", silent).
+			Dur("staleness_timeout", timeout).
+			Msg("MQTT source device is stale")
+		if p.metrics != nil {
+			p.metrics.RecordMQTTSourceDeviceStale(d.device.ID, true)
+		}
+	}
+}
+
+func (p *SourcePool) updateBrokerMetrics() {
+	if p.metrics == nil {
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for _, client := range p.clients {
+		p.metrics.RecordMQTTSourceBrokerConnected(client.broker, client.isConnected())
+	}
+}
@@ -0,0 +1,312 @@
+// Package mqtt provides the MQTT source pool, which ingests devices that
+// publish to an MQTT broker.
+package mqtt
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/nexus-edge/protocol-gateway/internal/domain"
+	"github.com/nexus-edge/protocol-gateway/internal/metrics"
+	"github.com/rs/zerolog"
+)
+
+// SourcePool implements domain.ProtocolPool and the polling service's
+// SubscriptionHandler for ProtocolMQTT devices.
+//
+// MQTT devices are push-based: the pool subscribes to "{mqtt_source_prefix}/#"
+// for every device, routes each message to the tags whose topic match it
+// satisfies, decodes the payload and hands the data points to the polling
+// service, which publishes them into the UNS. Devices on the same broker
+// share one client. ReadTags serves the last received values.
+type SourcePool struct {
+	config  SourceConfig
+	logger  zerolog.Logger
+	metrics *metrics.Registry
+
+	mu      sync.Mutex
+	clients map[string]*sourceClient // By broker key
+	devices map[string]*sourceDevice // By device ID
+	owners  map[string]*sourceClient // Client of each device, by device ID
+	closed  bool
+
+	stopChan chan struct{}
+	wg       sync.WaitGroup
+}
+
+// NewSourcePool creates a new MQTT source pool.
+func NewSourcePool(config SourceConfig, logger zerolog.Logger, metricsReg *metrics.Registry) *SourcePool {
+	defaults := DefaultSourceConfig()
+	if config.ClientIDPrefix == "" {
+		config.ClientIDPrefix = defaults.ClientIDPrefix
+	}
+	if config.KeepAlive == 0 {
+		config.KeepAlive = defaults.KeepAlive
+	}
+	if config.ConnectTimeout == 0 {
+		config.ConnectTimeout = defaults.ConnectTimeout
+	}
+	if config.ReconnectDelay == 0 {
+		config.ReconnectDelay = defaults.ReconnectDelay
+	}
+	if config.StalenessCheckInterval == 0 {
+		config.StalenessCheckInterval = defaults.StalenessCheckInterval
+	}
+
+	p := &SourcePool{
+		config:   config,
+		logger:   logger.With().Str("component", "mqtt-source-pool").Logger(),
+		metrics:  metricsReg,
+		clients:  make(map[string]*sourceClient),
+		devices:  make(map[string]*sourceDevice),
+		owners:   make(map[string]*sourceClient),
+		stopChan: make(chan struct{}),
+	}
+
+	p.wg.Add(1)
+	go p.healthLoop()
+
+	return p
+}
+
+// Subscribe subscribes a device's wildcard topic on its source broker.
+// onData is called for every decoded tag value. Subscribing a device again
+// replaces its previous subscription.
+func (p *SourcePool) Subscribe(ctx context.Context, device *domain.Device, tags []*domain.Tag, onData func(*domain.DataPoint)) error {
+	if device.Protocol != domain.ProtocolMQTT {
+		return fmt.Errorf("%w: %s is not an MQTT device", domain.ErrProtocolNotSupported, device.ID)
+	}
+	if device.Connection.MQTTBrokerURL == "" || strings.Trim(device.Connection.MQTTSourcePrefix, "/") == "" {
+		return fmt.Errorf("%w: MQTT device %s needs mqtt_broker_url and mqtt_source_prefix", domain.ErrInvalidConfig, device.ID)
+	}
+	d := newSourceDevice(device, tags, onData, time.Now())
+	key := brokerKey(&device.Connection)
+
+	// The previous subscription is replaced under the same lock, so a
+	// concurrent Subscribe or Unsubscribe of the device sees either the old
+	// or the new state.
+	p.mu.Lock()
+	if p.closed {
+		p.mu.Unlock()
+		return domain.ErrServiceStopped
+	}
+	client, ok := p.clients[key]
+	if !ok {
+		var err error
+		client, err = newSourceClient(key, &device.Connection, p.config, p.logger, p.deliver)
+		if err != nil {
+			p.mu.Unlock()
+			return fmt.Errorf("%w: %v", domain.ErrMQTTConnectionFailed, err)
+		}
+		p.clients[key] = client
+	}
+	previous, hadPrevious := p.detachLocked(device.ID)
+	p.devices[device.ID] = d
+	p.owners[device.ID] = client
+	qos := client.register(d)
+	if hadPrevious && previous.client == client {
+		// Still in use by the new subscription
+		previous.remaining++
+		previous.unused = previous.unused && previous.filter != d.filter
+	}
+	if hadPrevious && previous.remaining == 0 {
+		delete(p.clients, previous.client.key)
+	}
+	p.mu.Unlock()
+
+	if hadPrevious {
+		previous.release()
+	}
+	if p.metrics != nil {
+		p.metrics.RecordMQTTSourceDeviceStale(device.ID, false)
+	}
+	if client.isConnected() {
+		if err := client.subscribe(d.filter, qos); err != nil {
+			// Retried on the next reconnect
+			p.logger.Warn().Err(err).Str("device_id", device.ID).Msg("Subscribe failed, will retry on reconnect")
+		}
+	}
+
+	p.logger.Info().
+		Str("device_id", device.ID).
+		Str("filter", d.filter).
+		Str("broker_key", key).
+		Int("tags", len(tags)).
+		Msg("Subscribed MQTT source device")
+	return nil
+}
+
+// Unsubscribe removes a device's subscription. The broker client is closed
+// when its last device is removed.
+func (p *SourcePool) Unsubscribe(deviceID string) error {
+	p.mu.Lock()
+	detached, ok := p.detachLocked(deviceID)
+	if !ok {
+		p.mu.Unlock()
+		return nil
+	}
+	if detached.remaining == 0 {
+		delete(p.clients, detached.client.key)
+	}
+	p.mu.Unlock()
+
+	detached.release()
+
+	if p.metrics != nil {
+		p.metrics.RemoveMQTTSourceDevice(deviceID)
+	}
+	p.logger.Info().Str("device_id", deviceID).Msg("Unsubscribed MQTT source device")
+	return nil
+}
+
+// detachedDevice is the broker-side cleanup left after a device was removed
+// from the pool maps.
+type detachedDevice struct {
+	client    *sourceClient
+	filter    string
+	unused    bool // No other device of the client uses the filter
+	remaining int  // Devices left on the client
+}
+
+// detachLocked removes a device from the pool maps and its client. The
+// caller holds p.mu and calls release after unlocking.
+func (p *SourcePool) detachLocked(deviceID string) (detachedDevice, bool) {
+	client, ok := p.owners[deviceID]
+	if !ok {
+		return detachedDevice{}, false
+	}
+	delete(p.owners, deviceID)
+	delete(p.devices, deviceID)
+	filter, unused, remaining := client.unregister(deviceID)
+	return detachedDevice{client: client, filter: filter, unused: unused, remaining: remaining}, true
+}
+
+// release closes the client of a detached device when it has no devices
+// left, or unsubscribes the device's filter when no other device uses it.
+func (d detachedDevice) release() {
+	switch {
+	case d.remaining == 0:
+		d.client.close()
+	case d.unused && d.client.isConnected():
+		d.client.unsubscribe(d.filter)
+	}
+}
+
+// deliver handles a message for a device and records its outcome.
+func (p *SourcePool) deliver(d *sourceDevice, topic string, payload []byte) {
+	result := d.handleMessage(topic, payload, time.Now())
+
+	if result.recovered {
+		p.logger.Info().Str("device_id", d.device.ID).Msg("MQTT source device is no longer stale")
+		if p.metrics != nil {
+			p.metrics.RecordMQTTSourceDeviceStale(d.device.ID, false)
+		}
+	}
+	if result.lastError != nil {
+		p.logger.Debug().
+			Err(result.lastError).
+			Str("device_id", d.device.ID).
+			Str("topic", topic).
+			Msg("Failed to decode MQTT source message")
+	}
+	if !result.matched {
+		p.logger.Debug().Str("device_id", d.device.ID).Str("topic", topic).Msg("No tag matches MQTT source topic")
+	}
+
+	if p.metrics == nil {
+		return
+	}
+	switch {
+	case !result.matched:
+		p.metrics.RecordMQTTSourceMessage(d.device.ID, "unmatched")
+	case result.errors > 0:
+		p.metrics.RecordMQTTSourceMessage(d.device.ID, "decode_error")
+	default:
+		p.metrics.RecordMQTTSourceMessage(d.device.ID, "decoded")
+	}
+}
+
+// ReadTags returns the last received value of each tag. Tags without a value
+// yet, and values of stale devices, have uncertain quality.
+func (p *SourcePool) ReadTags(ctx context.Context, device *domain.Device, tags []*domain.Tag) ([]*domain.DataPoint, error) {
+	p.mu.Lock()
+	d, ok := p.devices[device.ID]
+	client := p.owners[device.ID]
+	p.mu.Unlock()
+	if !ok {
+		return nil, fmt.Errorf("%w: %s is not subscribed", domain.ErrDeviceNotFound, device.ID)
+	}
+
+	connected := client.isConnected()
+	points := make([]*domain.DataPoint, 0, len(tags))
+	for _, tag := range tags {
+		dp := d.lastValue(tag)
+		if !connected && dp.Quality == domain.QualityGood {
+			dp.Quality = domain.QualityUncertain
+		}
+		points = append(points, dp)
+	}
+	return points, nil
+}
+
+// ReadTag returns the last received value of a tag.
+func (p *SourcePool) ReadTag(ctx context.Context, device *domain.Device, tag *domain.Tag) (*domain.DataPoint, error) {
+	points, err := p.ReadTags(ctx, device, []*domain.Tag{tag})
+	if err != nil {
+		return nil, err
+	}
+	return points[0], nil
+}
+
+// WriteTag is not supported: MQTT source devices are read-only.
+func (p *SourcePool) WriteTag(ctx context.Context, device *domain.Device, tag *domain.Tag, value interface{}) error {
+	return fmt.Errorf("%w: MQTT source device %s is read-only", domain.ErrTagNotWritable, device.ID)
+}
+
+// WriteTags rejects every write: MQTT source devices are read-only.
+func (p *SourcePool) WriteTags(ctx context.Context, device *domain.Device, writes []domain.TagWrite) []error {
+	errs := make([]error, len(writes))
+	for i := range errs {
+		errs[i] = p.WriteTag(ctx, device, writes[i].Tag, writes[i].Value)
+	}
+	return errs
+}
+
+// HealthCheck reports an error if any source broker is unreachable.
+func (p *SourcePool) HealthCheck(ctx context.Context) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	for _, client := range p.clients {
+		if !client.isConnected() {
+			return fmt.Errorf("%w: source broker %s", domain.ErrMQTTNotConnected, client.broker)
+		}
+	}
+	return nil
+}
+
+// Close disconnects from all source brokers.
+func (p *SourcePool) Close() error {
+	p.mu.Lock()
+	if p.closed {
+		p.mu.Unlock()
+		return nil
+	}
+	p.closed = true
+	clients := p.clients
+	p.clients = make(map[string]*sourceClient)
+	p.devices = make(map[string]*sourceDevice)
+	p.owners = make(map[string]*sourceClient)
+	p.mu.Unlock()
+
+	close(p.stopChan)
+	p.wg.Wait()
+
+	for _, client := range clients {
+		client.close()
+	}
+	return nil
+}
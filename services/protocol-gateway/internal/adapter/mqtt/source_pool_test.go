@@ -0,0 +1,377 @@
+package mqtt
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"io"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/nexus-edge/protocol-gateway/internal/domain"
+	"github.com/rs/zerolog"
+)
+
+// testBroker is a minimal MQTT 3.1.1 broker for tests: it accepts any
+// client, tracks subscriptions and forwards publishes at QoS 0.
+type testBroker struct {
+	t        *testing.T
+	listener net.Listener
+
+	mu      sync.Mutex
+	clients map[net.Conn]map[string]bool // Subscribed filters by connection
+	writeMu sync.Mutex                   // Serializes packet writes
+}
+
+func newTestBroker(t *testing.T) *testBroker {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	b := &testBroker{t: t, listener: listener, clients: make(map[net.Conn]map[string]bool)}
+	go b.serve()
+	t.Cleanup(func() { listener.Close() })
+	return b
+}
+
+func (b *testBroker) url() string {
+	return "tcp://" + b.listener.Addr().String()
+}
+
+func (b *testBroker) serve() {
+	for {
+		conn, err := b.listener.Accept()
+		if err != nil {
+			return
+		}
+		go b.handle(conn)
+	}
+}
+
+func (b *testBroker) handle(conn net.Conn) {
+	defer func() {
+		b.mu.Lock()
+		delete(b.clients, conn)
+		b.mu.Unlock()
+		conn.Close()
+	}()
+
+	r := bufio.NewReader(conn)
+	for {
+		header, body, err := readPacket(r)
+		if err != nil {
+			return
+		}
+		switch header >> 4 {
+		case 1: // CONNECT
+			b.mu.Lock()
+			b.clients[conn] = make(map[string]bool)
+			b.mu.Unlock()
+			b.write(conn, 0x20, []byte{0, 0})
+		case 3: // PUBLISH
+			qos := (header >> 1) & 3
+			topicLen := int(binary.BigEndian.Uint16(body))
+			topic := string(body[2 : 2+topicLen])
+			payload := body[2+topicLen:]
+			if qos > 0 {
+				b.write(conn, 0x40, payload[:2])
+				payload = payload[2:]
+			}
+			b.publish(topic, payload)
+		case 8: // SUBSCRIBE
+			ack := append([]byte{}, body[:2]...)
+			b.mu.Lock()
+			for rest := body[2:]; len(rest) > 0; {
+				n := int(binary.BigEndian.Uint16(rest))
+				b.clients[conn][string(rest[2:2+n])] = true
+				ack = append(ack, rest[2+n])
+				rest = rest[3+n:]
+			}
+			b.mu.Unlock()
+			b.write(conn, 0x90, ack)
+		case 10: // UNSUBSCRIBE
+			b.mu.Lock()
+			for rest := body[2:]; len(rest) > 0; {
+				n := int(binary.BigEndian.Uint16(rest))
+				delete(b.clients[conn], string(rest[2:2+n]))
+				rest = rest[2+n:]
+			}
+			b.mu.Unlock()
+			b.write(conn, 0xB0, body[:2])
+		case 12: // PINGREQ
+			b.write(conn, 0xD0, nil)
+		case 14: // DISCONNECT
+			return
+		}
+	}
+}
+
+// publish forwards a message to every client with a matching subscription.
+func (b *testBroker) publish(topic string, payload []byte) {
+	body := binary.BigEndian.AppendUint16(nil, uint16(len(topic)))
+	body = append(append(body, topic...), payload...)
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for conn, filters := range b.clients {
+		for filter := range filters {
+			if topicMatches(filter, topic) {
+				b.write(conn, 0x30, body)
+				break
+			}
+		}
+	}
+}
+
+// subscriptions returns the number of subscribed filters over all clients.
+func (b *testBroker) subscriptions() int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	n := 0
+	for _, filters := range b.clients {
+		n += len(filters)
+	}
+	return n
+}
+
+func (b *testBroker) write(conn net.Conn, header byte, body []byte) {
+	packet := []byte{header}
+	for n := len(body); ; {
+		digit := byte(n % 128)
+		n /= 128
+		if n > 0 {
+			digit |= 0x80
+		}
+		packet = append(packet, digit)
+		if n == 0 {
+			break
+		}
+	}
+	b.writeMu.Lock()
+	defer b.writeMu.Unlock()
+	_, _ = conn.Write(append(packet, body...))
+}
+
+func readPacket(r *bufio.Reader) (byte, []byte, error) {
+	header, err := r.ReadByte()
+	if err != nil {
+		return 0, nil, err
+	}
+	length, multiplier := 0, 1
+	for {
+		digit, err := r.ReadByte()
+		if err != nil {
+			return 0, nil, err
+		}
+		length += int(digit&0x7F) * multiplier
+		multiplier *= 128
+		if digit&0x80 == 0 {
+			break
+		}
+	}
+	body := make([]byte, length)
+	_, err = io.ReadFull(r, body)
+	return header, body, err
+}
+
+func topicMatches(filter, topic string) bool {
+	f, t := strings.Split(filter, "/"), strings.Split(topic, "/")
+	for i, level := range f {
+		if level == "#" {
+			return true
+		}
+		if i >= len(t) || (level != "+" && level != t[i]) {
+			return false
+		}
+	}
+	return len(f) == len(t)
+}
+
+func newTestSourcePool(t *testing.T) *SourcePool {
+	t.Helper()
+	config := DefaultSourceConfig()
+	config.ConnectTimeout = 2 * time.Second
+	config.ReconnectDelay = 100 * time.Millisecond
+	config.StalenessCheckInterval = time.Hour // Tests call checkStaleness directly
+	p := NewSourcePool(config, zerolog.Nop(), nil)
+	t.Cleanup(func() { p.Close() })
+	return p
+}
+
+func mqttSourceDevice(id, brokerURL, prefix string, tags ...domain.Tag) (*domain.Device, []*domain.Tag) {
+	d := &domain.Device{
+		ID:        id,
+		Protocol:  domain.ProtocolMQTT,
+		UNSPrefix: "plant/" + id,
+		Connection: domain.ConnectionConfig{
+			MQTTBrokerURL:        brokerURL,
+			MQTTSourcePrefix:     prefix,
+			MQTTQoS:              1,
+			MQTTStalenessTimeout: 10 * time.Second,
+		},
+		Tags: tags,
+	}
+	ptrs := make([]*domain.Tag, len(d.Tags))
+	for i := range d.Tags {
+		ptrs[i] = &d.Tags[i]
+	}
+	return d, ptrs
+}
+
+// pointSink collects the data points delivered to onData.
+type pointSink struct {
+	points chan *domain.DataPoint
+}
+
+func newPointSink() *pointSink {
+	return &pointSink{points: make(chan *domain.DataPoint, 16)}
+}
+
+func (s *pointSink) onData(dp *domain.DataPoint) {
+	s.points <- dp
+}
+
+func (s *pointSink) next(t *testing.T) *domain.DataPoint {
+	t.Helper()
+	select {
+	case dp := <-s.points:
+		return dp
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for a data point")
+		return nil
+	}
+}
+
+func waitFor(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestSourcePool_SharesClientAndRoutesTopics(t *testing.T) {
+	broker := newTestBroker(t)
+	p := newTestSourcePool(t)
+	ctx := context.Background()
+
+	boiler, boilerTags := mqttSourceDevice("boiler", broker.url(), "site/boiler",
+		domain.Tag{ID: "temp", TopicSuffix: "temperature", DataType: domain.DataTypeFloat64, Enabled: true},
+		domain.Tag{ID: "zone", TopicSuffix: "zone_temp", MQTTTopicMatch: "zones/+/temp", DataType: domain.DataTypeFloat64, Enabled: true},
+	)
+	pump, pumpTags := mqttSourceDevice("pump", broker.url(), "site/pump",
+		domain.Tag{ID: "speed", TopicSuffix: "speed", MQTTPayloadFormat: domain.MQTTPayloadString, DataType: domain.DataTypeInt32, Enabled: true},
+	)
+	boilerSink, pumpSink := newPointSink(), newPointSink()
+	if err := p.Subscribe(ctx, boiler, boilerTags, boilerSink.onData); err != nil {
+		t.Fatalf("Subscribe boiler: %v", err)
+	}
+	if err := p.Subscribe(ctx, pump, pumpTags, pumpSink.onData); err != nil {
+		t.Fatalf("Subscribe pump: %v", err)
+	}
+	if len(p.clients) != 1 {
+		t.Fatalf("expected devices on one broker to share a client, got %d clients", len(p.clients))
+	}
+	waitFor(t, "both subscriptions", func() bool { return broker.subscriptions() == 2 })
+
+	broker.publish("site/boiler/temperature", []byte(`{"value": 71.5}`))
+	if dp := boilerSink.next(t); dp.TagID != "temp" || dp.Value != 71.5 || dp.Quality != domain.QualityGood {
+		t.Errorf("unexpected boiler point %+v", dp)
+	}
+	broker.publish("site/boiler/zones/north/temp", []byte(`19`))
+	if dp := boilerSink.next(t); dp.TagID != "zone" || dp.Value != 19.0 {
+		t.Errorf("unexpected zone point %+v", dp)
+	}
+	broker.publish("site/pump/speed", []byte("1450"))
+	if dp := pumpSink.next(t); dp.TagID != "speed" || dp.Value != int32(1450) {
+		t.Errorf("unexpected pump point %+v", dp)
+	}
+
+	points, err := p.ReadTags(ctx, boiler, boilerTags)
+	if err != nil {
+		t.Fatalf("ReadTags: %v", err)
+	}
+	if points[0].Value != 71.5 || points[0].Quality != domain.QualityGood {
+		t.Errorf("expected cached temperature, got %+v", points[0])
+	}
+	if len(boilerSink.points) != 0 || len(pumpSink.points) != 0 {
+		t.Error("messages were delivered to the wrong device")
+	}
+}
+
+func TestSourcePool_StaleDeviceServesUncertainValues(t *testing.T) {
+	broker := newTestBroker(t)
+	p := newTestSourcePool(t)
+	ctx := context.Background()
+
+	device, tags := mqttSourceDevice("meter", broker.url(), "site/meter",
+		domain.Tag{ID: "kwh", TopicSuffix: "energy", DataType: domain.DataTypeFloat64, Enabled: true},
+		domain.Tag{ID: "kvar", TopicSuffix: "reactive", DataType: domain.DataTypeFloat64, Enabled: true},
+	)
+	sink := newPointSink()
+	if err := p.Subscribe(ctx, device, tags, sink.onData); err != nil {
+		t.Fatalf("Subscribe: %v", err)
+	}
+	waitFor(t, "subscription", func() bool { return broker.subscriptions() == 1 })
+
+	broker.publish("site/meter/energy", []byte(`1200.5`))
+	sink.next(t)
+
+	points, _ := p.ReadTags(ctx, device, tags)
+	if points[0].Quality != domain.QualityGood {
+		t.Errorf("fresh value: expected good quality, got %s", points[0].Quality)
+	}
+	if points[1].Value != nil || points[1].Quality != domain.QualityUncertain {
+		t.Errorf("tag without a value: expected uncertain nil, got %+v", points[1])
+	}
+
+	p.checkStaleness(time.Now().Add(time.Minute))
+	points, _ = p.ReadTags(ctx, device, tags)
+	if points[0].Value != 1200.5 || points[0].Quality != domain.QualityUncertain {
+		t.Errorf("stale value: expected uncertain 1200.5, got %+v", points[0])
+	}
+
+	broker.publish("site/meter/energy", []byte(`1201`))
+	sink.next(t)
+	points, _ = p.ReadTags(ctx, device, tags)
+	if points[0].Value != 1201.0 || points[0].Quality != domain.QualityGood {
+		t.Errorf("after recovery: expected good 1201, got %+v", points[0])
+	}
+}
+
+func TestSourcePool_UnsubscribeReleasesFilterAndClient(t *testing.T) {
+	broker := newTestBroker(t)
+	p := newTestSourcePool(t)
+	ctx := context.Background()
+
+	a, aTags := mqttSourceDevice("a", broker.url(), "site/a", domain.Tag{ID: "x", TopicSuffix: "x", Enabled: true})
+	b, bTags := mqttSourceDevice("b", broker.url(), "site/b", domain.Tag{ID: "x", TopicSuffix: "x", Enabled: true})
+	if err := p.Subscribe(ctx, a, aTags, nil); err != nil {
+		t.Fatalf("Subscribe a: %v", err)
+	}
+	if err := p.Subscribe(ctx, b, bTags, nil); err != nil {
+		t.Fatalf("Subscribe b: %v", err)
+	}
+	waitFor(t, "both subscriptions", func() bool { return broker.subscriptions() == 2 })
+
+	_ = p.Unsubscribe("a")
+	waitFor(t, "filter of a to be unsubscribed", func() bool { return broker.subscriptions() == 1 })
+	if len(p.clients) != 1 {
+		t.Errorf("client must stay open while b uses it")
+	}
+	if _, err := p.ReadTags(ctx, a, aTags); err == nil {
+		t.Error("expected ReadTags of an unsubscribed device to fail")
+	}
+
+	_ = p.Unsubscribe("b")
+	if len(p.clients) != 0 {
+		t.Errorf("expected the client to be closed with its last device, got %d clients", len(p.clients))
+	}
+}
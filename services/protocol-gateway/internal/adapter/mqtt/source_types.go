@@ -0,0 +1,200 @@
+// Package mqtt provides the types of the MQTT source pool: its configuration,
+// per-device topic routing and the last-value cache.
+package mqtt
+
+import (
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/nexus-edge/protocol-gateway/internal/domain"
+)
+
+// SourceConfig holds configuration for the MQTT source pool.
+type SourceConfig struct {
+	// ClientIDPrefix is the default client ID prefix of broker clients; devices
+	// can override it with MQTTClientIDPrefix. The broker key hash is appended.
+	ClientIDPrefix string
+
+	// KeepAlive is the keep-alive interval of broker clients
+	KeepAlive time.Duration
+
+	// ConnectTimeout is the timeout for connecting and for (un)subscribing
+	ConnectTimeout time.Duration
+
+	// ReconnectDelay is the maximum delay between reconnection attempts
+	ReconnectDelay time.Duration
+
+	// StalenessCheckInterval is how often devices are checked for staleness
+	StalenessCheckInterval time.Duration
+}
+
+// DefaultSourceConfig returns a SourceConfig with sensible defaults.
+func DefaultSourceConfig() SourceConfig {
+	return SourceConfig{
+		ClientIDPrefix:         "gw-source",
+		KeepAlive:              30 * time.Second,
+		ConnectTimeout:         10 * time.Second,
+		ReconnectDelay:         30 * time.Second,
+		StalenessCheckInterval: time.Second,
+	}
+}
+
+// tagRoute maps the topics under a device's source prefix to a tag.
+type tagRoute struct {
+	tag    *domain.Tag
+	levels []string // Topic match split on '/'; "+" matches any one level
+}
+
+func (r *tagRoute) matches(levels []string) bool {
+	if len(levels) != len(r.levels) {
+		return false
+	}
+	for i, level := range r.levels {
+		if level != "+" && level != levels[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// sourceDevice is an MQTT device subscribed through the source pool. It
+// routes the messages of its wildcard subscription to tags and keeps the last
+// value of every tag for ReadTags.
+type sourceDevice struct {
+	device *domain.Device
+	prefix string // Source prefix without trailing '/'
+	filter string // "{prefix}/#"
+	qos    byte
+	onData func(*domain.DataPoint)
+
+	exact    map[string][]*tagRoute // Topic matches without wildcards, by topic
+	wildcard []*tagRoute
+
+	mu          sync.RWMutex
+	cache       map[string]*domain.DataPoint // Last value by tag ID
+	lastMessage time.Time
+	stale       bool
+}
+
+func newSourceDevice(device *domain.Device, tags []*domain.Tag, onData func(*domain.DataPoint), now time.Time) *sourceDevice {
+	prefix := strings.Trim(device.Connection.MQTTSourcePrefix, "/")
+	d := &sourceDevice{
+		device:      device,
+		prefix:      prefix,
+		filter:      prefix + "/#",
+		qos:         device.Connection.MQTTQoS,
+		onData:      onData,
+		exact:       make(map[string][]*tagRoute),
+		cache:       make(map[string]*domain.DataPoint, len(tags)),
+		lastMessage: now,
+	}
+	for _, tag := range tags {
+		match := strings.Trim(tag.MQTTTopicMatch, "/")
+		if match == "" {
+			match = strings.Trim(tag.TopicSuffix, "/")
+		}
+		route := &tagRoute{tag: tag, levels: strings.Split(match, "/")}
+		if strings.Contains(match, "+") {
+			d.wildcard = append(d.wildcard, route)
+		} else {
+			d.exact[match] = append(d.exact[match], route)
+		}
+	}
+	return d
+}
+
+// routes returns the tags a message topic is routed to.
+func (d *sourceDevice) routes(topic string) []*tagRoute {
+	suffix, ok := strings.CutPrefix(topic, d.prefix+"/")
+	if !ok {
+		return nil
+	}
+	routes := d.exact[suffix]
+	if len(d.wildcard) == 0 {
+		return routes
+	}
+	levels := strings.Split(suffix, "/")
+	for _, route := range d.wildcard {
+		if route.matches(levels) {
+			routes = append(routes[:len(routes):len(routes)], route)
+		}
+	}
+	return routes
+}
+
+// sourceMessageResult is the outcome of handling one message, for metrics.
+type sourceMessageResult struct {
+	matched   bool // Routed to at least one tag
+	recovered bool // The device was stale before this message
+	decoded   int
+	errors    int
+	lastError error
+}
+
+// handleMessage decodes a message for every tag it is routed to, updates the
+// last-value cache and delivers the data points.
+func (d *sourceDevice) handleMessage(topic string, payload []byte, now time.Time) sourceMessageResult {
+	var result sourceMessageResult
+	routes := d.routes(topic)
+	result.matched = len(routes) > 0
+
+	d.mu.Lock()
+	d.lastMessage = now
+	result.recovered = d.stale
+	d.stale = false
+	d.mu.Unlock()
+
+	for _, route := range routes {
+		tag := route.tag
+		raw, sourceTS, err := decodePayload(payload, tag)
+		if err != nil {
+			result.errors++
+			result.lastError = err
+			continue
+		}
+
+		dp := domain.NewDataPoint(
+			d.device.ID,
+			tag.ID,
+			"",
+			applyScaling(raw, tag),
+			tag.Unit,
+			domain.QualityGood,
+		).WithRawValue(raw).WithPriority(tag.Priority).WithGatewayTimestamp(now)
+		dp.Timestamp = now
+		if sourceTS != nil {
+			dp.WithSourceTimestamp(*sourceTS)
+		}
+
+		// The receiver owns dp; the cache keeps its own copy
+		cached := *dp
+		d.mu.Lock()
+		d.cache[tag.ID] = &cached
+		d.mu.Unlock()
+		result.decoded++
+
+		if d.onData != nil {
+			d.onData(dp)
+		}
+	}
+	return result
+}
+
+// lastValue returns a copy of the cached value of a tag. Stale values are
+// returned with uncertain quality; tags without a value yet are uncertain
+// and carry no value.
+func (d *sourceDevice) lastValue(tag *domain.Tag) *domain.DataPoint {
+	d.mu.RLock()
+	cached, stale := d.cache[tag.ID], d.stale
+	d.mu.RUnlock()
+
+	if cached == nil {
+		return domain.NewDataPoint(d.device.ID, tag.ID, "", nil, tag.Unit, domain.QualityUncertain).WithPriority(tag.Priority)
+	}
+	dp := *cached
+	if stale {
+		dp.Quality = domain.QualityUncertain
+	}
+	return &dp
+}
@@ -0,0 +1,585 @@
+// Package mqtt provides the Sparkplug B edge node used by the publisher's
+// sparkplug_b output mode.
+package mqtt
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/nexus-edge/protocol-gateway/internal/domain"
+	"github.com/rs/zerolog"
+)
+
+const (
+	sparkplugNamespace = "spBv1.0"
+
+	// Node metrics carried in NBIRTH/NDEATH and NCMD
+	sparkplugBdSeqMetric   = "bdSeq"
+	sparkplugRebirthMetric = "Node Control/Rebirth"
+
+	// Quality property values, as used by Ignition
+	sparkplugQualityBad       int32 = 0
+	sparkplugQualityUncertain int32 = 64
+)
+
+// sparkplugNode holds the Sparkplug B edge node session: the birth/death
+// sequence (bdSeq), the message sequence number, metric aliases and the set
+// of devices with their last values.
+//
+// The gateway is one edge node; every domain.Device is a Sparkplug device
+// and every tag a metric. Births carry the full metric set with names and
+// aliases; DDATA carries only aliases. A device is born when the node is
+// born, when it is registered or reconfigured, and when the poller reports it
+// online again; it dies when it is removed or reported offline.
+//
+// All messages are sent under mu, so sequence numbers go out in order.
+type sparkplugNode struct {
+	groupID    string
+	edgeNodeID string
+	send       func(topic string, payload []byte) error
+	logger     zerolog.Logger
+
+	mu        sync.Mutex
+	bdSeq     uint64
+	sessions  int
+	seq       uint64
+	online    bool // NBIRTH published on the current connection
+	nextAlias uint64
+	aliases   map[string]uint64 // Keyed by device ID + NUL + tag ID; stable while the device is registered
+	devices   map[string]*sparkplugDevice
+}
+
+// sparkplugDevice is the Sparkplug view of a domain.Device.
+type sparkplugDevice struct {
+	id      string // Sparkplug device ID (topic-safe)
+	metrics map[string]*sparkplugMetric
+	order   []*sparkplugMetric // Config order, for births
+	born    bool
+	offline bool // Reported offline by the poller
+}
+
+// sparkplugMetric is one tag of a device.
+type sparkplugMetric struct {
+	name     string
+	alias    uint64
+	dataType string
+	last     *sparkplugSample
+}
+
+// sparkplugSample is a metric value copied out of a data point. Data points
+// are pooled and released once published, so births can't refer to them.
+type sparkplugSample struct {
+	value     interface{}
+	quality   domain.Quality
+	timestamp time.Time
+}
+
+func newSparkplugSample(dp *domain.DataPoint) *sparkplugSample {
+	return &sparkplugSample{value: domain.CopyValue(dp.Value), quality: dp.Quality, timestamp: dp.Timestamp}
+}
+
+func newSparkplugNode(groupID, edgeNodeID string, send func(topic string, payload []byte) error, logger zerolog.Logger) *sparkplugNode {
+	return &sparkplugNode{
+		groupID:    groupID,
+		edgeNodeID: edgeNodeID,
+		send:       send,
+		logger:     logger.With().Str("sparkplug_edge_node", groupID+"/"+edgeNodeID).Logger(),
+		nextAlias:  1,
+		aliases:    make(map[string]uint64),
+		devices:    make(map[string]*sparkplugDevice),
+	}
+}
+
+// nodeTopic returns the topic of an edge node message (NBIRTH, NDEATH, NCMD).
+func (n *sparkplugNode) nodeTopic(messageType string) string {
+	return strings.Join([]string{sparkplugNamespace, n.groupID, messageType, n.edgeNodeID}, "/")
+}
+
+// deviceTopic returns the topic of a device message (DBIRTH, DDATA, DDEATH).
+func (n *sparkplugNode) deviceTopic(messageType string, d *sparkplugDevice) string {
+	return n.nodeTopic(messageType) + "/" + d.id
+}
+
+// beginSession starts a new MQTT session and returns the NDEATH payload to
+// register as the Last Will. bdSeq increments with every session so hosts
+// can match an NDEATH to its NBIRTH.
+func (n *sparkplugNode) beginSession() ([]byte, error) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	if n.sessions > 0 {
+		n.bdSeq = (n.bdSeq + 1) % 256
+	}
+	n.sessions++
+	return n.deathPayloadLocked()
+}
+
+func (n *sparkplugNode) deathPayloadLocked() ([]byte, error) {
+	return encodeSparkplugPayload(&domain.SparkplugBPayload{
+		Timestamp: time.Now().UnixMilli(),
+		Metrics:   []domain.SparkplugBMetric{n.bdSeqMetricLocked()},
+	})
+}
+
+func (n *sparkplugNode) bdSeqMetricLocked() domain.SparkplugBMetric {
+	return domain.SparkplugBMetric{
+		Name:      sparkplugBdSeqMetric,
+		Timestamp: time.Now().UnixMilli(),
+		DataType:  domain.SparkplugInt64,
+		Value:     int64(n.bdSeq),
+	}
+}
+
+// nextSeqLocked returns the sequence number for the next message.
+func (n *sparkplugNode) nextSeqLocked() *uint64 {
+	seq := n.seq
+	n.seq = (n.seq + 1) % 256
+	return &seq
+}
+
+// birth publishes NBIRTH followed by a DBIRTH for every online device. It is
+// called on every (re)connect and on a rebirth request.
+func (n *sparkplugNode) birth() error {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	n.online = false
+	n.seq = 0
+	payload, err := encodeSparkplugPayload(&domain.SparkplugBPayload{
+		Timestamp: time.Now().UnixMilli(),
+		Seq:       n.nextSeqLocked(),
+		Metrics: []domain.SparkplugBMetric{
+			n.bdSeqMetricLocked(),
+			{
+				Name:      sparkplugRebirthMetric,
+				Timestamp: time.Now().UnixMilli(),
+				DataType:  domain.SparkplugBoolean,
+				Value:     false,
+			},
+		},
+	})
+	if err != nil {
+		return err
+	}
+	if err := n.send(n.nodeTopic("NBIRTH"), payload); err != nil {
+		return fmt.Errorf("failed to publish NBIRTH: %w", err)
+	}
+	n.online = true
+
+	for _, d := range n.devices {
+		d.born = false
+		if !d.offline {
+			n.deviceBirthLocked(d)
+		}
+	}
+	n.logger.Info().Uint64("bd_seq", n.bdSeq).Int("devices", len(n.devices)).Msg("Published Sparkplug B node birth")
+	return nil
+}
+
+// death publishes NDEATH before a graceful disconnect; the broker only sends
+// the Last Will on an unexpected disconnect.
+func (n *sparkplugNode) death() error {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	if !n.online {
+		return nil
+	}
+	n.connectionLostLocked()
+	payload, err := n.deathPayloadLocked()
+	if err != nil {
+		return err
+	}
+	return n.send(n.nodeTopic("NDEATH"), payload)
+}
+
+// connectionLost marks the node and all devices dead; the broker publishes
+// the NDEATH will on our behalf.
+func (n *sparkplugNode) connectionLost() {
+	n.mu.Lock()
+	n.connectionLostLocked()
+	n.mu.Unlock()
+}
+
+func (n *sparkplugNode) connectionLostLocked() {
+	n.online = false
+	for _, d := range n.devices {
+		d.born = false
+	}
+}
+
+// setDevice adds or reconfigures a device and publishes its DBIRTH. Last
+// values of tags that still exist are kept.
+func (n *sparkplugNode) setDevice(device *domain.Device) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	old := n.devices[device.ID]
+	d := &sparkplugDevice{
+		id:      sparkplugID(device.ID),
+		metrics: make(map[string]*sparkplugMetric, len(device.Tags)),
+	}
+	if old != nil {
+		d.offline = old.offline
+	}
+
+	var tags []*domain.Tag
+	for i := range device.Tags {
+		if device.Tags[i].Enabled {
+			tags = append(tags, &device.Tags[i])
+			tags = append(tags, device.Tags[i].BitFieldTags()...)
+		}
+	}
+
+	for _, tag := range tags {
+		key := device.ID + "\x00" + tag.ID
+		alias, ok := n.aliases[key]
+		if !ok {
+			alias = n.nextAlias
+			n.nextAlias++
+			n.aliases[key] = alias
+		}
+		metric := &sparkplugMetric{
+			name:     sparkplugMetricName(tag),
+			alias:    alias,
+			dataType: sparkplugDataType(tag),
+		}
+		if old != nil && old.metrics[tag.ID] != nil {
+			metric.last = old.metrics[tag.ID].last
+		}
+		d.metrics[tag.ID] = metric
+		d.order = append(d.order, metric)
+	}
+	n.devices[device.ID] = d
+
+	if n.online && !d.offline {
+		n.deviceBirthLocked(d)
+	}
+}
+
+// removeDevice publishes DDEATH for a device and forgets it.
+func (n *sparkplugNode) removeDevice(deviceID string) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	d, ok := n.devices[deviceID]
+	if !ok {
+		return
+	}
+	n.deviceDeathLocked(d)
+	delete(n.devices, deviceID)
+	prefix := deviceID + "\x00"
+	for key := range n.aliases {
+		if strings.HasPrefix(key, prefix) {
+			delete(n.aliases, key)
+		}
+	}
+}
+
+// setDeviceOnline records the poller's view of a device: DDEATH when it goes
+// offline, DBIRTH with the latest values when it comes back.
+func (n *sparkplugNode) setDeviceOnline(deviceID string, online bool) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	d, ok := n.devices[deviceID]
+	if !ok || d.offline == !online {
+		return
+	}
+	d.offline = !online
+	if !online {
+		n.deviceDeathLocked(d)
+	} else if n.online {
+		n.deviceBirthLocked(d)
+	}
+}
+
+// publishData records the data points as the latest metric values and
+// publishes them as DDATA, one message per device. Devices that aren't born
+// yet are born instead, which carries the same values. While the node is
+// offline, values are only recorded and go out with the next birth.
+//
+// Backfilled points are sent as historical metrics (is_historical) in DDATA
+// and don't change the latest values. They are dropped while the node or
+// device is offline.
+func (n *sparkplugNode) publishData(points []*domain.DataPoint) error {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	var lastErr error
+	var order []*sparkplugDevice
+	batches := make(map[*sparkplugDevice][]*sparkplugMetric)
+	historical := make(map[*sparkplugDevice][]domain.SparkplugBMetric)
+	for _, dp := range points {
+		d, ok := n.devices[dp.DeviceID]
+		if !ok {
+			lastErr = fmt.Errorf("%w: %s (not announced to the sparkplug edge node)", domain.ErrDeviceNotFound, dp.DeviceID)
+			continue
+		}
+		metric, ok := d.metrics[dp.TagID]
+		if !ok {
+			lastErr = fmt.Errorf("%w: %s/%s", domain.ErrTagNotFound, dp.DeviceID, dp.TagID)
+			continue
+		}
+		_, seen := batches[d]
+		_, seenHistorical := historical[d]
+		if !seen && !seenHistorical {
+			order = append(order, d)
+		}
+		if dp.Backfilled {
+			encoded := metric.encodeSample(newSparkplugSample(dp), false)
+			encoded.IsHistorical = true
+			historical[d] = append(historical[d], encoded)
+			continue
+		}
+		metric.last = newSparkplugSample(dp)
+		batches[d] = append(batches[d], metric)
+	}
+
+	if !n.online {
+		return lastErr
+	}
+	for _, d := range order {
+		if d.offline {
+			continue
+		}
+		metrics := historical[d]
+		if !d.born {
+			if err := n.deviceBirthLocked(d); err != nil {
+				lastErr = err
+				continue
+			}
+		} else {
+			for _, metric := range batches[d] {
+				metrics = append(metrics, metric.encode(false))
+			}
+		}
+		if len(metrics) == 0 {
+			continue
+		}
+		if err := n.publishLocked(n.deviceTopic("DDATA", d), metrics); err != nil {
+			lastErr = err
+		}
+	}
+	return lastErr
+}
+
+// handleCommand processes an NCMD payload. A "Node Control/Rebirth" request
+// republishes NBIRTH and all DBIRTHs.
+func (n *sparkplugNode) handleCommand(payload []byte) error {
+	cmd, err := decodeSparkplugPayload(payload)
+	if err != nil {
+		return err
+	}
+	for _, metric := range cmd.Metrics {
+		if metric.Name == sparkplugRebirthMetric && metric.Value == true {
+			n.logger.Info().Msg("Rebirth requested by Sparkplug host")
+			return n.birth()
+		}
+	}
+	return nil
+}
+
+func (n *sparkplugNode) deviceBirthLocked(d *sparkplugDevice) error {
+	metrics := make([]domain.SparkplugBMetric, 0, len(d.order))
+	for _, metric := range d.order {
+		metrics = append(metrics, metric.encode(true))
+	}
+	if err := n.publishLocked(n.deviceTopic("DBIRTH", d), metrics); err != nil {
+		n.logger.Warn().Err(err).Str("device", d.id).Msg("Failed to publish DBIRTH")
+		return fmt.Errorf("failed to publish DBIRTH for %s: %w", d.id, err)
+	}
+	d.born = true
+	return nil
+}
+
+func (n *sparkplugNode) deviceDeathLocked(d *sparkplugDevice) {
+	if !d.born || !n.online {
+		d.born = false
+		return
+	}
+	d.born = false
+	if err := n.publishLocked(n.deviceTopic("DDEATH", d), nil); err != nil {
+		n.logger.Warn().Err(err).Str("device", d.id).Msg("Failed to publish DDEATH")
+	}
+}
+
+func (n *sparkplugNode) publishLocked(topic string, metrics []domain.SparkplugBMetric) error {
+	payload, err := encodeSparkplugPayload(&domain.SparkplugBPayload{
+		Timestamp: time.Now().UnixMilli(),
+		Seq:       n.nextSeqLocked(),
+		Metrics:   metrics,
+	})
+	if err != nil {
+		return err
+	}
+	return n.send(topic, payload)
+}
+
+// encode converts the metric's last value. Births include the metric name;
+// data messages only the alias. Values that can't be represented in the
+// metric's data type, and bad-quality reads without a value, are sent as null.
+func (m *sparkplugMetric) encode(withName bool) domain.SparkplugBMetric {
+	return m.encodeSample(m.last, withName)
+}
+
+// encodeSample converts a value of the metric; see encode.
+func (m *sparkplugMetric) encodeSample(sample *sparkplugSample, withName bool) domain.SparkplugBMetric {
+	alias := m.alias
+	metric := domain.SparkplugBMetric{
+		Alias:     &alias,
+		DataType:  m.dataType,
+		Timestamp: time.Now().UnixMilli(),
+	}
+	if withName {
+		metric.Name = m.name
+	}
+	if sample == nil {
+		metric.IsNull = true
+		return metric
+	}
+
+	metric.Timestamp = sample.timestamp.UnixMilli()
+	switch sample.quality {
+	case domain.QualityGood:
+	case domain.QualityUncertain:
+		metric.Properties = map[string]interface{}{"Quality": sparkplugQualityUncertain}
+	default:
+		metric.Properties = map[string]interface{}{"Quality": sparkplugQualityBad}
+	}
+	value, ok := coerceSparkplugValue(sample.value, m.dataType)
+	if !ok {
+		metric.IsNull = true
+		return metric
+	}
+	metric.Value = value
+	return metric
+}
+
+// sparkplugDataType maps a tag to the Sparkplug data type of its published
+// value. Scaled tags are published as Double.
+func sparkplugDataType(tag *domain.Tag) string {
+	if (tag.ScaleFactor != 0 && tag.ScaleFactor != 1) || tag.Offset != 0 {
+		return domain.SparkplugDouble
+	}
+	switch tag.DataType {
+	case domain.DataTypeBool:
+		return domain.SparkplugBoolean
+	case domain.DataTypeInt16:
+		return domain.SparkplugInt16
+	case domain.DataTypeUInt16:
+		return domain.SparkplugUInt16
+	case domain.DataTypeInt32:
+		return domain.SparkplugInt32
+	case domain.DataTypeUInt32:
+		return domain.SparkplugUInt32
+	case domain.DataTypeInt64:
+		return domain.SparkplugInt64
+	case domain.DataTypeUInt64:
+		return domain.SparkplugUInt64
+	case domain.DataTypeFloat32:
+		return domain.SparkplugFloat
+	case domain.DataTypeString:
+		return domain.SparkplugString
+	default:
+		return domain.SparkplugDouble
+	}
+}
+
+// coerceSparkplugValue converts a data point value to the Go type the codec
+// expects for dataType.
+func coerceSparkplugValue(value interface{}, dataType string) (interface{}, bool) {
+	if value == nil {
+		return nil, false
+	}
+	if t, ok := value.(time.Time); ok {
+		value = t.UnixMilli()
+	}
+
+	v := reflect.ValueOf(value)
+	switch dataType {
+	case domain.SparkplugString, domain.SparkplugText:
+		if s, ok := value.(string); ok {
+			return s, true
+		}
+		return fmt.Sprint(value), true
+	case domain.SparkplugBytes:
+		b, ok := value.([]byte)
+		return b, ok
+	case domain.SparkplugBoolean:
+		switch {
+		case v.Kind() == reflect.Bool:
+			return v.Bool(), true
+		case v.CanInt():
+			return v.Int() != 0, true
+		case v.CanUint():
+			return v.Uint() != 0, true
+		}
+	case domain.SparkplugFloat, domain.SparkplugDouble:
+		var f float64
+		switch {
+		case v.CanFloat():
+			f = v.Float()
+		case v.CanInt():
+			f = float64(v.Int())
+		case v.CanUint():
+			f = float64(v.Uint())
+		default:
+			return nil, false
+		}
+		if dataType == domain.SparkplugFloat {
+			return float32(f), true
+		}
+		return f, true
+	case domain.SparkplugInt8, domain.SparkplugInt16, domain.SparkplugInt32, domain.SparkplugInt64:
+		switch {
+		case v.CanInt():
+			return v.Int(), true
+		case v.CanUint():
+			return int64(v.Uint()), true
+		case v.CanFloat():
+			return int64(v.Float()), true
+		case v.Kind() == reflect.Bool:
+			return boolToInt64(v.Bool()), true
+		}
+	case domain.SparkplugUInt8, domain.SparkplugUInt16, domain.SparkplugUInt32, domain.SparkplugUInt64, domain.SparkplugDateTime:
+		switch {
+		case v.CanUint():
+			return v.Uint(), true
+		case v.CanInt():
+			return uint64(v.Int()), true
+		case v.CanFloat():
+			return uint64(v.Float()), true
+		case v.Kind() == reflect.Bool:
+			return uint64(boolToInt64(v.Bool())), true
+		}
+	}
+	return nil, false
+}
+
+func boolToInt64(b bool) int64 {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+// sparkplugMetricName uses the same name as the tag's MQTT topic suffix;
+// "/" is kept, since Sparkplug hosts treat it as a folder separator.
+func sparkplugMetricName(tag *domain.Tag) string {
+	for _, name := range []string{tag.TopicSuffix, tag.Name, tag.ID} {
+		if name = strings.TrimSpace(name); name != "" {
+			return name
+		}
+	}
+	return tag.ID
+}
+
+// sparkplugID makes a device, group or edge node ID safe for use as a
+// Sparkplug topic level.
+func sparkplugID(s string) string {
+	return strings.NewReplacer("/", "_", "+", "_", "#", "_").Replace(strings.TrimSpace(s))
+}
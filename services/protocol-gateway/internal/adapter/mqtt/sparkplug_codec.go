@@ -0,0 +1,455 @@
+// Package mqtt provides the Sparkplug B protobuf codec.
+package mqtt
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+
+	"github.com/nexus-edge/protocol-gateway/internal/domain"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// The Sparkplug B payload is a small, stable protobuf schema
+// (sparkplug_b.proto), so it is encoded by hand with protowire instead of
+// generated code. Only the parts the edge node produces or consumes are
+// supported: scalar metrics and scalar properties. DataSets, templates and
+// metadata are skipped on decode.
+
+// Payload field numbers.
+const (
+	payloadTimestamp = 1
+	payloadMetrics   = 2
+	payloadSeq       = 3
+)
+
+// Metric field numbers.
+const (
+	metricName         = 1
+	metricAlias        = 2
+	metricTimestamp    = 3
+	metricDatatype     = 4
+	metricIsHistorical = 5
+	metricIsNull       = 7
+	metricProperties   = 9
+	metricIntValue     = 10
+	metricLongValue    = 11
+	metricFloatValue   = 12
+	metricDoubleValue  = 13
+	metricBooleanValue = 14
+	metricStringValue  = 15
+	metricBytesValue   = 16
+)
+
+// PropertySet and PropertyValue field numbers.
+const (
+	propertySetKeys      = 1
+	propertySetValues    = 2
+	propertyType         = 1
+	propertyIsNull       = 2
+	propertyIntValue     = 3
+	propertyLongValue    = 4
+	propertyFloatValue   = 5
+	propertyDoubleValue  = 6
+	propertyBooleanValue = 7
+	propertyStringValue  = 8
+)
+
+var sparkplugTypeCodes = map[string]uint64{
+	domain.SparkplugInt8:     1,
+	domain.SparkplugInt16:    2,
+	domain.SparkplugInt32:    3,
+	domain.SparkplugInt64:    4,
+	domain.SparkplugUInt8:    5,
+	domain.SparkplugUInt16:   6,
+	domain.SparkplugUInt32:   7,
+	domain.SparkplugUInt64:   8,
+	domain.SparkplugFloat:    9,
+	domain.SparkplugDouble:   10,
+	domain.SparkplugBoolean:  11,
+	domain.SparkplugString:   12,
+	domain.SparkplugDateTime: 13,
+	domain.SparkplugText:     14,
+	domain.SparkplugBytes:    17,
+}
+
+var sparkplugTypeNames = func() map[uint64]string {
+	names := make(map[uint64]string, len(sparkplugTypeCodes))
+	for name, code := range sparkplugTypeCodes {
+		names[code] = name
+	}
+	return names
+}()
+
+var errMalformedSparkplug = errors.New("malformed sparkplug payload")
+
+// encodeSparkplugPayload serializes a payload to the Sparkplug B protobuf
+// wire format. Metric values must already have the Go type of their data
+// type: int64 for signed integers, uint64 for unsigned integers and
+// DateTime, float32 for Float, float64 for Double, bool, string or []byte.
+func encodeSparkplugPayload(p *domain.SparkplugBPayload) ([]byte, error) {
+	var b []byte
+	b = protowire.AppendTag(b, payloadTimestamp, protowire.VarintType)
+	b = protowire.AppendVarint(b, uint64(p.Timestamp))
+	for i := range p.Metrics {
+		metric, err := encodeSparkplugMetric(&p.Metrics[i])
+		if err != nil {
+			return nil, err
+		}
+		b = protowire.AppendTag(b, payloadMetrics, protowire.BytesType)
+		b = protowire.AppendBytes(b, metric)
+	}
+	if p.Seq != nil {
+		b = protowire.AppendTag(b, payloadSeq, protowire.VarintType)
+		b = protowire.AppendVarint(b, *p.Seq)
+	}
+	return b, nil
+}
+
+func encodeSparkplugMetric(m *domain.SparkplugBMetric) ([]byte, error) {
+	code, ok := sparkplugTypeCodes[m.DataType]
+	if !ok {
+		return nil, fmt.Errorf("metric %q: unsupported sparkplug data type %q", m.Name, m.DataType)
+	}
+
+	var b []byte
+	if m.Name != "" {
+		b = protowire.AppendTag(b, metricName, protowire.BytesType)
+		b = protowire.AppendString(b, m.Name)
+	}
+	if m.Alias != nil {
+		b = protowire.AppendTag(b, metricAlias, protowire.VarintType)
+		b = protowire.AppendVarint(b, *m.Alias)
+	}
+	b = protowire.AppendTag(b, metricTimestamp, protowire.VarintType)
+	b = protowire.AppendVarint(b, uint64(m.Timestamp))
+	b = protowire.AppendTag(b, metricDatatype, protowire.VarintType)
+	b = protowire.AppendVarint(b, code)
+	if m.IsHistorical {
+		b = protowire.AppendTag(b, metricIsHistorical, protowire.VarintType)
+		b = protowire.AppendVarint(b, 1)
+	}
+	if len(m.Properties) > 0 {
+		properties, err := encodeSparkplugProperties(m.Properties)
+		if err != nil {
+			return nil, fmt.Errorf("metric %q: %w", m.Name, err)
+		}
+		b = protowire.AppendTag(b, metricProperties, protowire.BytesType)
+		b = protowire.AppendBytes(b, properties)
+	}
+	if m.IsNull || m.Value == nil {
+		b = protowire.AppendTag(b, metricIsNull, protowire.VarintType)
+		return protowire.AppendVarint(b, 1), nil
+	}
+
+	b, err := appendSparkplugValue(b, m.DataType, m.Value, metricValueFields)
+	if err != nil {
+		return nil, fmt.Errorf("metric %q: %w", m.Name, err)
+	}
+	return b, nil
+}
+
+// valueFields maps value kinds to field numbers, which differ between
+// Metric and PropertyValue.
+type valueFields struct {
+	intValue, longValue, floatValue, doubleValue, booleanValue, stringValue, bytesValue protowire.Number
+}
+
+var (
+	metricValueFields   = valueFields{metricIntValue, metricLongValue, metricFloatValue, metricDoubleValue, metricBooleanValue, metricStringValue, metricBytesValue}
+	propertyValueFields = valueFields{propertyIntValue, propertyLongValue, propertyFloatValue, propertyDoubleValue, propertyBooleanValue, propertyStringValue, 0}
+)
+
+func appendSparkplugValue(b []byte, dataType string, value interface{}, fields valueFields) ([]byte, error) {
+	mismatch := fmt.Errorf("value %v (%T) does not match data type %s", value, value, dataType)
+	switch dataType {
+	case domain.SparkplugInt8, domain.SparkplugInt16, domain.SparkplugInt32:
+		v, ok := value.(int64)
+		if !ok {
+			return nil, mismatch
+		}
+		b = protowire.AppendTag(b, fields.intValue, protowire.VarintType)
+		return protowire.AppendVarint(b, uint64(uint32(int32(v)))), nil
+	case domain.SparkplugUInt8, domain.SparkplugUInt16, domain.SparkplugUInt32:
+		v, ok := value.(uint64)
+		if !ok {
+			return nil, mismatch
+		}
+		b = protowire.AppendTag(b, fields.intValue, protowire.VarintType)
+		return protowire.AppendVarint(b, uint64(uint32(v))), nil
+	case domain.SparkplugInt64:
+		v, ok := value.(int64)
+		if !ok {
+			return nil, mismatch
+		}
+		b = protowire.AppendTag(b, fields.longValue, protowire.VarintType)
+		return protowire.AppendVarint(b, uint64(v)), nil
+	case domain.SparkplugUInt64, domain.SparkplugDateTime:
+		v, ok := value.(uint64)
+		if !ok {
+			return nil, mismatch
+		}
+		b = protowire.AppendTag(b, fields.longValue, protowire.VarintType)
+		return protowire.AppendVarint(b, v), nil
+	case domain.SparkplugFloat:
+		v, ok := value.(float32)
+		if !ok {
+			return nil, mismatch
+		}
+		b = protowire.AppendTag(b, fields.floatValue, protowire.Fixed32Type)
+		return protowire.AppendFixed32(b, math.Float32bits(v)), nil
+	case domain.SparkplugDouble:
+		v, ok := value.(float64)
+		if !ok {
+			return nil, mismatch
+		}
+		b = protowire.AppendTag(b, fields.doubleValue, protowire.Fixed64Type)
+		return protowire.AppendFixed64(b, math.Float64bits(v)), nil
+	case domain.SparkplugBoolean:
+		v, ok := value.(bool)
+		if !ok {
+			return nil, mismatch
+		}
+		b = protowire.AppendTag(b, fields.booleanValue, protowire.VarintType)
+		return protowire.AppendVarint(b, protowire.EncodeBool(v)), nil
+	case domain.SparkplugString, domain.SparkplugText:
+		v, ok := value.(string)
+		if !ok {
+			return nil, mismatch
+		}
+		b = protowire.AppendTag(b, fields.stringValue, protowire.BytesType)
+		return protowire.AppendString(b, v), nil
+	case domain.SparkplugBytes:
+		v, ok := value.([]byte)
+		if !ok || fields.bytesValue == 0 {
+			return nil, mismatch
+		}
+		b = protowire.AppendTag(b, fields.bytesValue, protowire.BytesType)
+		return protowire.AppendBytes(b, v), nil
+	}
+	return nil, fmt.Errorf("unsupported sparkplug data type %q", dataType)
+}
+
+// encodeSparkplugProperties encodes a property set. Property types are
+// derived from the Go type of each value.
+func encodeSparkplugProperties(properties map[string]interface{}) ([]byte, error) {
+	keys := make([]string, 0, len(properties))
+	for key := range properties {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys) // Deterministic output
+
+	var b []byte
+	for _, key := range keys {
+		b = protowire.AppendTag(b, propertySetKeys, protowire.BytesType)
+		b = protowire.AppendString(b, key)
+	}
+	for _, key := range keys {
+		var dataType string
+		value := properties[key]
+		switch v := value.(type) {
+		case int32:
+			dataType, value = domain.SparkplugInt32, int64(v)
+		case int64:
+			dataType = domain.SparkplugInt64
+		case uint64:
+			dataType = domain.SparkplugUInt64
+		case float64:
+			dataType = domain.SparkplugDouble
+		case bool:
+			dataType = domain.SparkplugBoolean
+		case string:
+			dataType = domain.SparkplugString
+		default:
+			return nil, fmt.Errorf("property %q: unsupported type %T", key, value)
+		}
+		pv := protowire.AppendTag(nil, propertyType, protowire.VarintType)
+		pv = protowire.AppendVarint(pv, sparkplugTypeCodes[dataType])
+		pv, err := appendSparkplugValue(pv, dataType, value, propertyValueFields)
+		if err != nil {
+			return nil, fmt.Errorf("property %q: %w", key, err)
+		}
+		b = protowire.AppendTag(b, propertySetValues, protowire.BytesType)
+		b = protowire.AppendBytes(b, pv)
+	}
+	return b, nil
+}
+
+// decodeSparkplugPayload parses a Sparkplug B protobuf payload, e.g. an NCMD.
+// Values are returned with the Go types described at encodeSparkplugPayload;
+// Int32 properties decode as int64.
+func decodeSparkplugPayload(b []byte) (*domain.SparkplugBPayload, error) {
+	p := &domain.SparkplugBPayload{}
+	err := walkFields(b, func(num protowire.Number, typ protowire.Type, varint uint64, raw []byte) error {
+		switch {
+		case num == payloadTimestamp && typ == protowire.VarintType:
+			p.Timestamp = int64(varint)
+		case num == payloadSeq && typ == protowire.VarintType:
+			seq := varint
+			p.Seq = &seq
+		case num == payloadMetrics && typ == protowire.BytesType:
+			metric, err := decodeSparkplugMetric(raw)
+			if err != nil {
+				return err
+			}
+			p.Metrics = append(p.Metrics, *metric)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+func decodeSparkplugMetric(b []byte) (*domain.SparkplugBMetric, error) {
+	m := &domain.SparkplugBMetric{}
+	var value rawValue
+	err := walkFields(b, func(num protowire.Number, typ protowire.Type, varint uint64, raw []byte) error {
+		switch num {
+		case metricName:
+			m.Name = string(raw)
+		case metricAlias:
+			alias := varint
+			m.Alias = &alias
+		case metricTimestamp:
+			m.Timestamp = int64(varint)
+		case metricDatatype:
+			m.DataType = sparkplugTypeNames[varint]
+		case metricIsHistorical:
+			m.IsHistorical = varint != 0
+		case metricIsNull:
+			m.IsNull = varint != 0
+		case metricProperties:
+			properties, err := decodeSparkplugProperties(raw)
+			if err != nil {
+				return err
+			}
+			m.Properties = properties
+		case metricIntValue, metricLongValue, metricFloatValue, metricDoubleValue, metricBooleanValue, metricStringValue, metricBytesValue:
+			value = rawValue{set: true, varint: varint, raw: raw}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if value.set && !m.IsNull {
+		m.Value = value.decode(m.DataType)
+	}
+	return m, nil
+}
+
+func decodeSparkplugProperties(b []byte) (map[string]interface{}, error) {
+	var keys []string
+	var values []interface{}
+	err := walkFields(b, func(num protowire.Number, typ protowire.Type, varint uint64, raw []byte) error {
+		switch num {
+		case propertySetKeys:
+			keys = append(keys, string(raw))
+		case propertySetValues:
+			var dataType string
+			var value rawValue
+			var isNull bool
+			err := walkFields(raw, func(num protowire.Number, typ protowire.Type, varint uint64, raw []byte) error {
+				switch num {
+				case propertyType:
+					dataType = sparkplugTypeNames[varint]
+				case propertyIsNull:
+					isNull = varint != 0
+				case propertyIntValue, propertyLongValue, propertyFloatValue, propertyDoubleValue, propertyBooleanValue, propertyStringValue:
+					value = rawValue{set: true, varint: varint, raw: raw}
+				}
+				return nil
+			})
+			if err != nil {
+				return err
+			}
+			if isNull || !value.set {
+				values = append(values, nil)
+			} else {
+				values = append(values, value.decode(dataType))
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(keys) != len(values) {
+		return nil, fmt.Errorf("%w: %d property keys but %d values", errMalformedSparkplug, len(keys), len(values))
+	}
+	properties := make(map[string]interface{}, len(keys))
+	for i, key := range keys {
+		properties[key] = values[i]
+	}
+	return properties, nil
+}
+
+// rawValue holds an undecoded value field until the data type is known;
+// the datatype field may follow the value on the wire.
+type rawValue struct {
+	set    bool
+	varint uint64 // Varint and fixed-width fields
+	raw    []byte // Length-delimited fields
+}
+
+func (v rawValue) decode(dataType string) interface{} {
+	switch dataType {
+	case domain.SparkplugInt8, domain.SparkplugInt16, domain.SparkplugInt32:
+		return int64(int32(uint32(v.varint)))
+	case domain.SparkplugInt64:
+		return int64(v.varint)
+	case domain.SparkplugUInt8, domain.SparkplugUInt16, domain.SparkplugUInt32, domain.SparkplugUInt64, domain.SparkplugDateTime:
+		return v.varint
+	case domain.SparkplugFloat:
+		return math.Float32frombits(uint32(v.varint))
+	case domain.SparkplugDouble:
+		return math.Float64frombits(v.varint)
+	case domain.SparkplugBoolean:
+		return v.varint != 0
+	case domain.SparkplugString, domain.SparkplugText:
+		return string(v.raw)
+	default:
+		return v.raw
+	}
+}
+
+// walkFields calls fn for each field of a protobuf message. Varint and
+// fixed-width values are passed in varint, length-delimited ones in raw.
+func walkFields(b []byte, fn func(num protowire.Number, typ protowire.Type, varint uint64, raw []byte) error) error {
+	for len(b) > 0 {
+		num, typ, n := protowire.ConsumeTag(b)
+		if n < 0 {
+			return fmt.Errorf("%w: %v", errMalformedSparkplug, protowire.ParseError(n))
+		}
+		b = b[n:]
+
+		var varint uint64
+		var raw []byte
+		switch typ {
+		case protowire.VarintType:
+			varint, n = protowire.ConsumeVarint(b)
+		case protowire.Fixed32Type:
+			var v uint32
+			v, n = protowire.ConsumeFixed32(b)
+			varint = uint64(v)
+		case protowire.Fixed64Type:
+			varint, n = protowire.ConsumeFixed64(b)
+		case protowire.BytesType:
+			raw, n = protowire.ConsumeBytes(b)
+		default:
+			n = protowire.ConsumeFieldValue(num, typ, b)
+		}
+		if n < 0 {
+			return fmt.Errorf("%w: field %d: %v", errMalformedSparkplug, num, protowire.ParseError(n))
+		}
+		b = b[n:]
+
+		if err := fn(num, typ, varint, raw); err != nil {
+			return err
+		}
+	}
+	return nil
+}
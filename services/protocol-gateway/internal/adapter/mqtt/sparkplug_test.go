@@ -0,0 +1,227 @@
+package mqtt
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/nexus-edge/protocol-gateway/internal/domain"
+	"github.com/rs/zerolog"
+)
+
+// sentMessage is a Sparkplug message captured by a test node.
+type sentMessage struct {
+	topic   string
+	payload *domain.SparkplugBPayload
+}
+
+func newTestSparkplugNode(t *testing.T) (*sparkplugNode, *[]sentMessage) {
+	t.Helper()
+	var sent []sentMessage
+	node := newSparkplugNode("plant", "gw1", func(topic string, payload []byte) error {
+		decoded, err := decodeSparkplugPayload(payload)
+		if err != nil {
+			t.Fatalf("decode %s: %v", topic, err)
+		}
+		sent = append(sent, sentMessage{topic: topic, payload: decoded})
+		return nil
+	}, zerolog.Nop())
+	return node, &sent
+}
+
+func sparkplugDevice1() *domain.Device {
+	return &domain.Device{
+		ID: "plc/1",
+		Tags: []domain.Tag{
+			{ID: "temp", Name: "Temperature", TopicSuffix: "temperature", DataType: domain.DataTypeFloat32, ScaleFactor: 1, Enabled: true},
+			{ID: "count", Name: "Count", TopicSuffix: "count", DataType: domain.DataTypeInt16, ScaleFactor: 1, Enabled: true},
+			{ID: "spare", Name: "Spare", DataType: domain.DataTypeBool, Enabled: false},
+		},
+	}
+}
+
+func TestSparkplugCodec_RoundTrip(t *testing.T) {
+	alias := uint64(7)
+	seq := uint64(42)
+	in := &domain.SparkplugBPayload{
+		Timestamp: 1700000000000,
+		Seq:       &seq,
+		Metrics: []domain.SparkplugBMetric{
+			{Name: "i16", Alias: &alias, Timestamp: 1, DataType: domain.SparkplugInt16, Value: int64(-2)},
+			{Name: "u32", Timestamp: 2, DataType: domain.SparkplugUInt32, Value: uint64(4000000000)},
+			{Name: "i64", Timestamp: 3, DataType: domain.SparkplugInt64, Value: int64(-1 << 40)},
+			{Name: "f", Timestamp: 4, DataType: domain.SparkplugFloat, Value: float32(1.5)},
+			{Name: "d", Timestamp: 5, DataType: domain.SparkplugDouble, Value: 2.25},
+			{Name: "b", Timestamp: 6, DataType: domain.SparkplugBoolean, Value: true},
+			{Name: "s", Timestamp: 7, DataType: domain.SparkplugString, Value: "hello"},
+			{Name: "null", Timestamp: 8, DataType: domain.SparkplugDouble, IsNull: true, Properties: map[string]interface{}{"Quality": int32(0)}},
+		},
+	}
+
+	encoded, err := encodeSparkplugPayload(in)
+	if err != nil {
+		t.Fatalf("encode: %v", err)
+	}
+	out, err := decodeSparkplugPayload(encoded)
+	if err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+
+	if out.Timestamp != in.Timestamp || out.Seq == nil || *out.Seq != seq || len(out.Metrics) != len(in.Metrics) {
+		t.Fatalf("payload mismatch: %+v", out)
+	}
+	for i, want := range in.Metrics {
+		got := out.Metrics[i]
+		if got.Name != want.Name || got.DataType != want.DataType || got.Timestamp != want.Timestamp || got.IsNull != want.IsNull || got.Value != want.Value {
+			t.Errorf("metric %d: expected %+v, got %+v", i, want, got)
+		}
+	}
+	if out.Metrics[0].Alias == nil || *out.Metrics[0].Alias != alias {
+		t.Errorf("alias not preserved: %v", out.Metrics[0].Alias)
+	}
+	if q := out.Metrics[7].Properties["Quality"]; q != int64(0) {
+		t.Errorf("expected Quality property 0, got %v (%T)", q, q)
+	}
+
+	if _, err := encodeSparkplugPayload(&domain.SparkplugBPayload{Metrics: []domain.SparkplugBMetric{{Name: "x", DataType: domain.SparkplugInt32, Value: "nope"}}}); err == nil {
+		t.Error("expected an error for a value that doesn't match its data type")
+	}
+}
+
+func TestSparkplugNode_BirthDataAndSequence(t *testing.T) {
+	node, sent := newTestSparkplugNode(t)
+	node.setDevice(sparkplugDevice1()) // Before the node is born: nothing is sent
+
+	if len(*sent) != 0 {
+		t.Fatalf("expected no messages before NBIRTH, got %d", len(*sent))
+	}
+	if err := node.birth(); err != nil {
+		t.Fatalf("birth: %v", err)
+	}
+
+	if len(*sent) != 2 {
+		t.Fatalf("expected NBIRTH and DBIRTH, got %d messages", len(*sent))
+	}
+	nbirth, dbirth := (*sent)[0], (*sent)[1]
+	if nbirth.topic != "spBv1.0/plant/NBIRTH/gw1" || *nbirth.payload.Seq != 0 {
+		t.Errorf("unexpected NBIRTH: %s seq %d", nbirth.topic, *nbirth.payload.Seq)
+	}
+	if dbirth.topic != "spBv1.0/plant/DBIRTH/gw1/plc_1" || *dbirth.payload.Seq != 1 {
+		t.Errorf("unexpected DBIRTH: %s seq %d", dbirth.topic, *dbirth.payload.Seq)
+	}
+	if len(dbirth.payload.Metrics) != 2 {
+		t.Fatalf("expected 2 enabled metrics in DBIRTH, got %d", len(dbirth.payload.Metrics))
+	}
+	temp := dbirth.payload.Metrics[0]
+	if temp.Name != "temperature" || temp.DataType != domain.SparkplugFloat || !temp.IsNull || temp.Alias == nil {
+		t.Errorf("unexpected DBIRTH metric: %+v", temp)
+	}
+
+	now := time.Now()
+	points := []*domain.DataPoint{
+		{DeviceID: "plc/1", TagID: "temp", Value: 21.5, Quality: domain.QualityGood, Timestamp: now},
+		{DeviceID: "plc/1", TagID: "count", Value: int16(-3), Quality: domain.QualityGood, Timestamp: now},
+	}
+	if err := node.publishData(points); err != nil {
+		t.Fatalf("publishData: %v", err)
+	}
+
+	ddata := (*sent)[2]
+	if ddata.topic != "spBv1.0/plant/DDATA/gw1/plc_1" || *ddata.payload.Seq != 2 || len(ddata.payload.Metrics) != 2 {
+		t.Fatalf("expected one DDATA with both metrics, got %s %+v", ddata.topic, ddata.payload)
+	}
+	for i, metric := range ddata.payload.Metrics {
+		if metric.Name != "" || metric.Alias == nil || *metric.Alias != *dbirth.payload.Metrics[i].Alias {
+			t.Errorf("DDATA metric %d must use the DBIRTH alias only: %+v", i, metric)
+		}
+	}
+	if v := ddata.payload.Metrics[0].Value; v != float32(21.5) {
+		t.Errorf("expected Float 21.5, got %v (%T)", v, v)
+	}
+	if v := ddata.payload.Metrics[1].Value; v != int64(-3) {
+		t.Errorf("expected Int16 -3, got %v (%T)", v, v)
+	}
+
+	// seq wraps at 256
+	for i := 0; i < 300; i++ {
+		_ = node.publishData(points[:1])
+	}
+	if last := (*sent)[len(*sent)-1]; *last.payload.Seq != (2+300)%256 {
+		t.Errorf("expected seq to wrap to %d, got %d", (2+300)%256, *last.payload.Seq)
+	}
+}
+
+func TestSparkplugNode_RebirthAndDeviceDeath(t *testing.T) {
+	node, sent := newTestSparkplugNode(t)
+	node.setDevice(sparkplugDevice1())
+	_ = node.birth()
+	// The poller releases published points to the pool: the birth cache must not keep them
+	dp := domain.NewDataPoint("plc/1", "temp", "", 20.0, "", domain.QualityGood)
+	_ = node.publishData([]*domain.DataPoint{dp})
+	domain.ReleaseDataPoint(dp)
+	*sent = nil
+
+	// Host requests a rebirth: NBIRTH resets seq, DBIRTH carries the last value
+	alias := uint64(0)
+	ncmd, _ := encodeSparkplugPayload(&domain.SparkplugBPayload{Metrics: []domain.SparkplugBMetric{
+		{Name: sparkplugRebirthMetric, Alias: &alias, DataType: domain.SparkplugBoolean, Value: true},
+	}})
+	if err := node.handleCommand(ncmd); err != nil {
+		t.Fatalf("handleCommand: %v", err)
+	}
+	if len(*sent) != 2 || !strings.Contains((*sent)[0].topic, "/NBIRTH/") || *(*sent)[0].payload.Seq != 0 {
+		t.Fatalf("expected NBIRTH + DBIRTH on rebirth, got %+v", *sent)
+	}
+	if v := (*sent)[1].payload.Metrics[0].Value; v != float32(20) {
+		t.Errorf("expected DBIRTH to carry the last value, got %v", v)
+	}
+
+	// Device goes offline: DDEATH, and data is held until it is back
+	*sent = nil
+	node.setDeviceOnline("plc/1", false)
+	_ = node.publishData([]*domain.DataPoint{{DeviceID: "plc/1", TagID: "temp", Value: 25.0, Quality: domain.QualityGood, Timestamp: time.Now()}})
+	if len(*sent) != 1 || !strings.Contains((*sent)[0].topic, "/DDEATH/gw1/plc_1") {
+		t.Fatalf("expected a single DDEATH, got %+v", *sent)
+	}
+	node.setDeviceOnline("plc/1", true)
+	if len(*sent) != 2 || !strings.Contains((*sent)[1].topic, "/DBIRTH/") || (*sent)[1].payload.Metrics[0].Value != float32(25) {
+		t.Fatalf("expected DBIRTH with the latest value, got %+v", *sent)
+	}
+
+	// The NDEATH will carries the session's bdSeq; a new session increments it
+	_, _ = node.beginSession()
+	will, _ := node.beginSession()
+	death, _ := decodeSparkplugPayload(will)
+	if death.Seq != nil || death.Metrics[0].Name != sparkplugBdSeqMetric || death.Metrics[0].Value != int64(1) {
+		t.Errorf("unexpected NDEATH payload: %+v", death)
+	}
+}
+
+func TestSparkplugNode_BackfillIsHistorical(t *testing.T) {
+	node, sent := newTestSparkplugNode(t)
+	node.setDevice(sparkplugDevice1())
+	_ = node.birth()
+	now := time.Now()
+	_ = node.publishData([]*domain.DataPoint{{DeviceID: "plc/1", TagID: "temp", Value: 30.0, Quality: domain.QualityGood, Timestamp: now}})
+	*sent = nil
+
+	old := now.Add(-10 * time.Minute)
+	backfilled := (&domain.DataPoint{DeviceID: "plc/1", TagID: "temp", Value: 18.0, Quality: domain.QualityGood}).AsBackfill(old)
+	if err := node.publishData([]*domain.DataPoint{backfilled}); err != nil {
+		t.Fatalf("publishData: %v", err)
+	}
+	if len(*sent) != 1 || !strings.Contains((*sent)[0].topic, "/DDATA/") {
+		t.Fatalf("expected one DDATA, got %+v", *sent)
+	}
+	metric := (*sent)[0].payload.Metrics[0]
+	if !metric.IsHistorical || metric.Value != float32(18) || metric.Timestamp != old.UnixMilli() {
+		t.Errorf("expected historical metric with the source timestamp, got %+v", metric)
+	}
+
+	// The latest value is unchanged, so a rebirth still carries 30
+	*sent = nil
+	_ = node.birth()
+	if v := (*sent)[1].payload.Metrics[0].Value; v != float32(30) {
+		t.Errorf("expected DBIRTH to carry the live value, got %v", v)
+	}
+}
@@ -0,0 +1,313 @@
+// Package opcua provides Alarms & Conditions event monitoring and acknowledgement.
+package opcua
+
+import (
+	"context"
+	"encoding/hex"
+	"fmt"
+	"time"
+
+	"github.com/gopcua/opcua/id"
+	"github.com/gopcua/opcua/ua"
+	"github.com/nexus-edge/protocol-gateway/internal/domain"
+)
+
+// AlarmHandler is called for every alarm event received from subscriptions.
+type AlarmHandler func(event *domain.AlarmEvent)
+
+// alarmClientHandleBase is the first client handle of event monitored items.
+// Data monitored items use the tag index as client handle, so the two ranges
+// never overlap.
+const alarmClientHandleBase uint32 = 1 << 31
+
+// alarmQueueSize is the server-side queue size of event monitored items.
+// Alarm floods (e.g., a tripped line) must not drop transitions.
+const alarmQueueSize = 1000
+
+// defaultAlarmNotifier is the Server object, which reports all events of the server.
+var defaultAlarmNotifier = ua.NewNumericNodeID(0, id.Server)
+
+// Indices of the event fields selected by alarmEventFilter.
+const (
+	alarmFieldEventID = iota
+	alarmFieldEventType
+	alarmFieldSourceNode
+	alarmFieldSourceName
+	alarmFieldTime
+	alarmFieldReceiveTime
+	alarmFieldMessage
+	alarmFieldSeverity
+	alarmFieldConditionID
+	alarmFieldConditionName
+	alarmFieldRetain
+	alarmFieldActive
+	alarmFieldAcked
+	alarmFieldConfirmed
+	alarmFieldCount
+)
+
+// alarmEventFilter returns the event filter of alarm monitored items: the
+// BaseEventType, ConditionType and AlarmConditionType fields of the alarm
+// payload, for events of AlarmConditionType and its subtypes only.
+func alarmEventFilter() *ua.EventFilter {
+	baseEvent := ua.NewNumericNodeID(0, id.BaseEventType)
+	condition := ua.NewNumericNodeID(0, id.ConditionType)
+	acknowledgeable := ua.NewNumericNodeID(0, id.AcknowledgeableConditionType)
+	alarm := ua.NewNumericNodeID(0, id.AlarmConditionType)
+
+	field := func(typeID *ua.NodeID, path ...string) *ua.SimpleAttributeOperand {
+		browsePath := make([]*ua.QualifiedName, len(path))
+		for i, name := range path {
+			browsePath[i] = &ua.QualifiedName{Name: name}
+		}
+		return &ua.SimpleAttributeOperand{
+			TypeDefinitionID: typeID,
+			BrowsePath:       browsePath,
+			AttributeID:      ua.AttributeIDValue,
+		}
+	}
+
+	selects := make([]*ua.SimpleAttributeOperand, alarmFieldCount)
+	selects[alarmFieldEventID] = field(baseEvent, "EventId")
+	selects[alarmFieldEventType] = field(baseEvent, "EventType")
+	selects[alarmFieldSourceNode] = field(baseEvent, "SourceNode")
+	selects[alarmFieldSourceName] = field(baseEvent, "SourceName")
+	selects[alarmFieldTime] = field(baseEvent, "Time")
+	selects[alarmFieldReceiveTime] = field(baseEvent, "ReceiveTime")
+	selects[alarmFieldMessage] = field(baseEvent, "Message")
+	selects[alarmFieldSeverity] = field(baseEvent, "Severity")
+	// The ConditionId is the NodeId of the condition itself (empty browse path)
+	selects[alarmFieldConditionID] = &ua.SimpleAttributeOperand{
+		TypeDefinitionID: condition,
+		AttributeID:      ua.AttributeIDNodeID,
+	}
+	selects[alarmFieldConditionName] = field(condition, "ConditionName")
+	selects[alarmFieldRetain] = field(condition, "Retain")
+	selects[alarmFieldActive] = field(alarm, "ActiveState", "Id")
+	selects[alarmFieldAcked] = field(acknowledgeable, "AckedState", "Id")
+	selects[alarmFieldConfirmed] = field(acknowledgeable, "ConfirmedState", "Id")
+
+	return &ua.EventFilter{
+		SelectClauses: selects,
+		WhereClause: &ua.ContentFilter{
+			Elements: []*ua.ContentFilterElement{{
+				FilterOperator: ua.FilterOperatorOfType,
+				FilterOperands: []*ua.ExtensionObject{
+					ua.NewExtensionObject(&ua.LiteralOperand{Value: ua.MustVariant(alarm)}),
+				},
+			}},
+		},
+	}
+}
+
+// alarmItemRequests builds the event monitored items of a device's alarm
+// notifiers. Client handles start at alarmClientHandleBase.
+func (sm *SubscriptionManager) alarmItemRequests(device *domain.Device) []*ua.MonitoredItemCreateRequest {
+	notifiers := []*ua.NodeID{defaultAlarmNotifier}
+	if configured := device.Connection.OPCAlarmNotifiers; len(configured) > 0 {
+		notifiers = notifiers[:0]
+		for _, nodeIDStr := range configured {
+			nodeID, err := sm.client.getNodeID(nodeIDStr)
+			if err != nil {
+				sm.logger.Warn().
+					Err(err).
+					Str("device_id", device.ID).
+					Str("node_id", nodeIDStr).
+					Msg("Failed to parse alarm notifier node ID, skipping")
+				continue
+			}
+			notifiers = append(notifiers, nodeID)
+		}
+	}
+
+	filter := ua.NewExtensionObject(alarmEventFilter())
+	requests := make([]*ua.MonitoredItemCreateRequest, 0, len(notifiers))
+	for i, nodeID := range notifiers {
+		requests = append(requests, &ua.MonitoredItemCreateRequest{
+			ItemToMonitor: &ua.ReadValueID{
+				NodeID:       nodeID,
+				AttributeID:  ua.AttributeIDEventNotifier,
+				DataEncoding: &ua.QualifiedName{},
+			},
+			MonitoringMode: ua.MonitoringModeReporting,
+			RequestedParameters: &ua.MonitoringParameters{
+				ClientHandle:  alarmClientHandleBase + uint32(i),
+				QueueSize:     alarmQueueSize,
+				DiscardOldest: true,
+				Filter:        filter,
+			},
+		})
+	}
+	return requests
+}
+
+// refreshConditions asks the server to resend the current state of all
+// retained conditions (ConditionRefresh), so alarms that were already active
+// before the subscription existed are published too.
+func (sm *SubscriptionManager) refreshConditions(ctx context.Context, subscriptionID uint32) error {
+	_, err := sm.client.callMethod(ctx, &ua.CallMethodRequest{
+		ObjectID:       ua.NewNumericNodeID(0, id.ConditionType),
+		MethodID:       ua.NewNumericNodeID(0, id.ConditionType_ConditionRefresh),
+		InputArguments: []*ua.Variant{ua.MustVariant(subscriptionID)},
+	})
+	return err
+}
+
+// processEvent converts an event notification into an alarm event, caches it
+// as the condition's latest state while the condition is retained and hands it
+// to the alarm handler.
+func (sm *SubscriptionManager) processEvent(sub *Subscription, fields *ua.EventFieldList) {
+	if fields == nil || fields.ClientHandle < alarmClientHandleBase {
+		return
+	}
+
+	event, ok := alarmEventFromFields(sub.Device.ID, fields.EventFields)
+	if !ok {
+		sm.logger.Debug().
+			Str("device_id", sub.Device.ID).
+			Msg("Ignoring event without condition ID")
+		return
+	}
+	if event.Severity < sub.Device.Connection.OPCAlarmMinSeverity {
+		return
+	}
+	event.Topic = alarmTopic(sub.Device.UNSPrefix, event)
+
+	// Conditions that are no longer retained are forgotten, so the cache
+	// only holds the ones still needing operator attention
+	sub.mu.Lock()
+	if event.Retain {
+		sub.Alarms[event.ConditionID] = event
+	} else {
+		delete(sub.Alarms, event.ConditionID)
+	}
+	sub.mu.Unlock()
+
+	if sm.client.metricsReg != nil {
+		sm.client.metricsReg.RecordOPCUAAlarmEvent(sub.Device.ID, event.Active)
+	}
+
+	sm.logger.Debug().
+		Str("device_id", sub.Device.ID).
+		Str("condition_id", event.ConditionID).
+		Uint16("severity", event.Severity).
+		Bool("active", event.Active).
+		Bool("acked", event.Acked).
+		Msg("Processed alarm event")
+
+	if sm.alarmHandler != nil {
+		eventCopy := *event
+		sm.alarmHandler(&eventCopy)
+	}
+}
+
+// alarmEventFromFields maps the fields selected by alarmEventFilter to an
+// alarm event. Events without a condition ID can't be acknowledged and are
+// rejected.
+func alarmEventFromFields(deviceID string, fields []*ua.Variant) (*domain.AlarmEvent, bool) {
+	value := func(i int) interface{} {
+		if i >= len(fields) || fields[i] == nil {
+			return nil
+		}
+		return fields[i].Value()
+	}
+	nodeIDString := func(i int) string {
+		if nodeID, ok := value(i).(*ua.NodeID); ok && nodeID != nil {
+			return nodeID.String()
+		}
+		return ""
+	}
+	boolean := func(i int) bool {
+		b, _ := value(i).(bool)
+		return b
+	}
+
+	event := &domain.AlarmEvent{
+		DeviceID:    deviceID,
+		ConditionID: nodeIDString(alarmFieldConditionID),
+		EventType:   nodeIDString(alarmFieldEventType),
+		SourceNode:  nodeIDString(alarmFieldSourceNode),
+		Active:      boolean(alarmFieldActive),
+		Acked:       boolean(alarmFieldAcked),
+		Confirmed:   boolean(alarmFieldConfirmed),
+		Retain:      boolean(alarmFieldRetain),
+	}
+	if event.ConditionID == "" {
+		return nil, false
+	}
+	if eventID, ok := value(alarmFieldEventID).([]byte); ok {
+		event.EventID = hex.EncodeToString(eventID)
+	}
+	event.SourceName, _ = value(alarmFieldSourceName).(string)
+	event.ConditionName, _ = value(alarmFieldConditionName).(string)
+	if message, ok := value(alarmFieldMessage).(*ua.LocalizedText); ok && message != nil {
+		event.Message = message.Text
+	}
+	event.Severity, _ = value(alarmFieldSeverity).(uint16)
+	if t, ok := value(alarmFieldTime).(time.Time); ok && !t.IsZero() {
+		event.Time = t
+	} else {
+		event.Time = time.Now()
+	}
+	if t, ok := value(alarmFieldReceiveTime).(time.Time); ok && !t.IsZero() {
+		event.ReceiveTime = &t
+	}
+	return event, true
+}
+
+// alarmTopic returns the UNS topic of an alarm: {uns_prefix}/alarms/{source}/{condition}.
+func alarmTopic(unsPrefix string, event *domain.AlarmEvent) string {
+	source := topicSegment(event.SourceName)
+	if source == "" {
+		source = topicSegment(event.SourceNode)
+	}
+	if source == "" {
+		source = "server"
+	}
+	condition := topicSegment(event.ConditionName)
+	if condition == "" {
+		condition = topicSegment(event.ConditionID)
+	}
+	return unsPrefix + "/alarms/" + source + "/" + condition
+}
+
+// lastAlarmEvent returns the latest event received for a condition of a device.
+func (sm *SubscriptionManager) lastAlarmEvent(deviceID, conditionID string) (*domain.AlarmEvent, bool) {
+	sm.mu.RLock()
+	sub, exists := sm.subscriptions[deviceID]
+	sm.mu.RUnlock()
+	if !exists {
+		return nil, false
+	}
+
+	sub.mu.RLock()
+	defer sub.mu.RUnlock()
+	event, exists := sub.Alarms[conditionID]
+	return event, exists
+}
+
+// acknowledgeCondition calls Acknowledge or Confirm on a condition for the
+// given event.
+func (c *Client) acknowledgeCondition(ctx context.Context, conditionID string, eventID []byte, action domain.AlarmAction, comment string) error {
+	nodeID, err := c.getNodeID(conditionID)
+	if err != nil {
+		return err
+	}
+
+	methodID := uint32(id.AcknowledgeableConditionType_Acknowledge)
+	if action == domain.AlarmActionConfirm {
+		methodID = id.AcknowledgeableConditionType_Confirm
+	}
+	_, err = c.callMethod(ctx, &ua.CallMethodRequest{
+		ObjectID: nodeID,
+		MethodID: ua.NewNumericNodeID(0, methodID),
+		InputArguments: []*ua.Variant{
+			ua.MustVariant(eventID),
+			ua.MustVariant(ua.NewLocalizedText(comment)),
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("%s of %s failed: %w", action, conditionID, err)
+	}
+	return nil
+}
@@ -0,0 +1,137 @@
+package opcua
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/gopcua/opcua/ua"
+	"github.com/nexus-edge/protocol-gateway/internal/domain"
+	"github.com/rs/zerolog"
+	"github.com/sony/gobreaker"
+)
+
+func alarmFields(conditionID *ua.NodeID, sourceName, conditionName string) []*ua.Variant {
+	fields := make([]*ua.Variant, alarmFieldCount)
+	fields[alarmFieldEventID] = ua.MustVariant([]byte{0xde, 0xad, 0xbe, 0xef})
+	fields[alarmFieldEventType] = ua.MustVariant(ua.NewNumericNodeID(0, 9482))
+	fields[alarmFieldSourceNode] = ua.MustVariant(ua.NewStringNodeID(2, "Boiler1"))
+	fields[alarmFieldSourceName] = ua.MustVariant(sourceName)
+	fields[alarmFieldTime] = ua.MustVariant(time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC))
+	fields[alarmFieldMessage] = ua.MustVariant(ua.NewLocalizedText("Temperature high"))
+	fields[alarmFieldSeverity] = ua.MustVariant(uint16(700))
+	if conditionID != nil {
+		fields[alarmFieldConditionID] = ua.MustVariant(conditionID)
+	}
+	fields[alarmFieldConditionName] = ua.MustVariant(conditionName)
+	fields[alarmFieldRetain] = ua.MustVariant(true)
+	fields[alarmFieldActive] = ua.MustVariant(true)
+	fields[alarmFieldAcked] = ua.MustVariant(false)
+	fields[alarmFieldConfirmed] = ua.MustVariant(false)
+	return fields
+}
+
+func TestAlarmEventFromFields(t *testing.T) {
+	fields := alarmFields(ua.NewStringNodeID(2, "Boiler1.HighTemp"), "Boiler 1", "HighTemp")
+	event, ok := alarmEventFromFields("plc-1", fields)
+	if !ok {
+		t.Fatal("expected event to be accepted")
+	}
+
+	if event.DeviceID != "plc-1" || event.ConditionID != "ns=2;s=Boiler1.HighTemp" {
+		t.Errorf("unexpected identity: %+v", event)
+	}
+	if event.EventID != "deadbeef" {
+		t.Errorf("event ID: got %q, want deadbeef", event.EventID)
+	}
+	if event.Severity != 700 || event.Message != "Temperature high" || event.ConditionName != "HighTemp" {
+		t.Errorf("unexpected payload: %+v", event)
+	}
+	if !event.Active || event.Acked || event.Confirmed || !event.Retain {
+		t.Errorf("unexpected states: %+v", event)
+	}
+	if !event.Time.Equal(time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)) {
+		t.Errorf("time: got %v", event.Time)
+	}
+	if event.ReceiveTime != nil {
+		t.Errorf("receive time: expected nil, got %v", event.ReceiveTime)
+	}
+
+	if _, ok := alarmEventFromFields("plc-1", alarmFields(nil, "Boiler 1", "HighTemp")); ok {
+		t.Error("expected event without condition ID to be rejected")
+	}
+	if _, ok := alarmEventFromFields("plc-1", fields[:3]); ok {
+		t.Error("expected truncated field list to be rejected")
+	}
+}
+
+func TestAlarmTopic(t *testing.T) {
+	cases := []struct {
+		name  string
+		event domain.AlarmEvent
+		want  string
+	}{
+		{"names", domain.AlarmEvent{SourceName: "Boiler 1", ConditionName: "HighTemp"}, "plant/line1/alarms/Boiler_1/HighTemp"},
+		{"source node fallback", domain.AlarmEvent{SourceNode: "ns=2;s=Boiler1", ConditionName: "HighTemp"}, "plant/line1/alarms/ns=2;s=Boiler1/HighTemp"},
+		{"server fallback", domain.AlarmEvent{ConditionID: "ns=2;i=42"}, "plant/line1/alarms/server/ns=2;i=42"},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := alarmTopic("plant/line1", &tc.event); got != tc.want {
+				t.Errorf("got %q, want %q", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestProcessEvent_ForgetsConditionsNoLongerRetained(t *testing.T) {
+	device := &domain.Device{ID: "plc-1", UNSPrefix: "plant/line1"}
+	sub := &Subscription{Device: device, Alarms: make(map[string]*domain.AlarmEvent)}
+	sm := &SubscriptionManager{
+		client:        &Client{},
+		subscriptions: map[string]*Subscription{device.ID: sub},
+		logger:        zerolog.Nop(),
+	}
+	var published []*domain.AlarmEvent
+	sm.SetAlarmHandler(func(event *domain.AlarmEvent) { published = append(published, event) })
+
+	conditionID := ua.NewStringNodeID(2, "Boiler1.HighTemp")
+	fields := alarmFields(conditionID, "Boiler 1", "HighTemp")
+	sm.processEvent(sub, &ua.EventFieldList{ClientHandle: alarmClientHandleBase, EventFields: fields})
+	if _, ok := sm.lastAlarmEvent(device.ID, conditionID.String()); !ok {
+		t.Fatal("expected retained condition to be cached")
+	}
+
+	fields[alarmFieldRetain] = ua.MustVariant(false)
+	sm.processEvent(sub, &ua.EventFieldList{ClientHandle: alarmClientHandleBase, EventFields: fields})
+	if _, ok := sm.lastAlarmEvent(device.ID, conditionID.String()); ok {
+		t.Error("expected condition to be forgotten once it is no longer retained")
+	}
+	if len(published) != 2 || published[1].Retain {
+		t.Errorf("expected both events to be published, got %+v", published)
+	}
+}
+
+func TestExecuteCommand_RejectionsDoNotTripBreakers(t *testing.T) {
+	tripAfterOne := gobreaker.Settings{ReadyToTrip: func(counts gobreaker.Counts) bool { return counts.ConsecutiveFailures >= 1 }}
+	session := &pooledSession{breaker: gobreaker.NewCircuitBreaker(tripAfterOne)}
+	binding := &DeviceBinding{DeviceID: "plc-1", breaker: gobreaker.NewCircuitBreaker(tripAfterOne)}
+	p := &ConnectionPool{}
+
+	rejected := fmt.Errorf("%w: %w: BadConditionBranchAlreadyAcked", domain.ErrOPCUAMethodCallFailed, domain.ErrOPCUABadStatus)
+	for i := 0; i < 3; i++ {
+		_, err := p.executeCommand(session, binding, func() (interface{}, error) { return nil, rejected })
+		if !errors.Is(err, domain.ErrOPCUABadStatus) {
+			t.Fatalf("expected the rejection to be returned, got %v", err)
+		}
+	}
+	if session.breaker.State() != gobreaker.StateClosed || binding.breaker.State() != gobreaker.StateClosed {
+		t.Fatal("rejected commands must not open the breakers")
+	}
+
+	_, _ = p.executeCommand(session, binding, func() (interface{}, error) { return nil, domain.ErrConnectionClosed })
+	if session.breaker.State() != gobreaker.StateOpen {
+		t.Error("connection failures must count against the endpoint breaker")
+	}
+}
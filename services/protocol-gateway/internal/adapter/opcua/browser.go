@@ -0,0 +1,168 @@
+// Package opcua provides the domain.ProtocolBrowser implementation for OPC UA.
+package opcua
+
+import (
+	"context"
+	"strconv"
+	"strings"
+
+	"github.com/gopcua/opcua/id"
+	"github.com/gopcua/opcua/ua"
+	"github.com/nexus-edge/protocol-gateway/internal/domain"
+)
+
+// maxBrowseDepth caps recursion of a single browse request.
+const maxBrowseDepth = 5
+
+// Browse walks the address space of a device below rootPath (a NodeID, default
+// the Objects folder) and returns every node found up to depth levels deep.
+// Server-internal nodes (namespace 0) below the Objects folder are skipped.
+func (p *ConnectionPool) Browse(ctx context.Context, device *domain.Device, rootPath string, depth int) ([]domain.BrowseResult, error) {
+	if depth < 1 {
+		depth = 1
+	}
+	if depth > maxBrowseDepth {
+		depth = maxBrowseDepth
+	}
+
+	if _, err := p.GetClient(ctx, device); err != nil {
+		return nil, err
+	}
+
+	tree, err := p.BrowseNodes(ctx, device.ID, rootPath, depth)
+	if err != nil {
+		return nil, err
+	}
+
+	skipSystem := tree.NodeID == ua.NewNumericNodeID(0, id.ObjectsFolder).String()
+	return browseResults(tree, skipSystem), nil
+}
+
+// browseResults flattens a browse tree into domain results, children after
+// their parent, with paths relative to the root.
+func browseResults(root *BrowseResult, skipSystem bool) []domain.BrowseResult {
+	results := make([]domain.BrowseResult, 0, len(root.Children))
+
+	var walk func(node *BrowseResult, path []string)
+	walk = func(node *BrowseResult, path []string) {
+		for _, child := range node.Children {
+			if skipSystem && node == root && nodeNamespace(child.NodeID) == 0 {
+				continue
+			}
+
+			name := child.DisplayName
+			if name == "" {
+				name = child.BrowseName
+			}
+			if name == "" {
+				name = child.NodeID
+			}
+			childPath := append(path[:len(path):len(path)], name)
+
+			result := domain.BrowseResult{
+				ID:       browseTagID(childPath),
+				Name:     name,
+				Address:  child.NodeID,
+				Path:     childPath,
+				Children: len(child.Children),
+				Metadata: map[string]string{
+					"node_class": child.NodeClassName,
+					"namespace":  strconv.Itoa(int(nodeNamespace(child.NodeID))),
+				},
+			}
+			if child.HasChildren && len(child.Children) == 0 {
+				result.Children = -1
+			}
+			if child.BrowseName != "" {
+				result.Metadata["browse_name"] = child.BrowseName
+			}
+			if child.NodeClass == ua.NodeClassVariable {
+				result.DataType = browseDataType(child.DataType)
+				result.AccessMode = browseAccessMode(child.AccessLevel)
+				if child.DataType != "" {
+					result.Metadata["opc_data_type"] = child.DataType
+				}
+			}
+
+			results = append(results, result)
+			walk(child, childPath)
+		}
+	}
+	walk(root, nil)
+
+	return results
+}
+
+// nodeNamespace returns the namespace index of a NodeID string (0 if invalid).
+func nodeNamespace(nodeID string) uint16 {
+	parsed, err := ua.ParseNodeID(nodeID)
+	if err != nil {
+		return 0
+	}
+	return parsed.Namespace()
+}
+
+// browseTagID suggests a tag ID from a browse path ("Line 1", "Motor.Speed"
+// becomes "Line_1_Motor_Speed").
+func browseTagID(path []string) string {
+	tagID := strings.Map(func(r rune) rune {
+		if r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '-' || r == '_' {
+			return r
+		}
+		return '_'
+	}, strings.Join(path, "_"))
+	return strings.Trim(tagID, "_")
+}
+
+// browseDataType maps an OPC UA built-in type name (see dataTypeNodeIDToString)
+// to the closest domain data type. Types without one return "".
+func browseDataType(opcType string) domain.DataType {
+	switch opcType {
+	case "Boolean":
+		return domain.DataTypeBool
+	case "SByte", "Int16":
+		return domain.DataTypeInt16
+	case "Byte", "UInt16":
+		return domain.DataTypeUInt16
+	case "Int32":
+		return domain.DataTypeInt32
+	case "UInt32":
+		return domain.DataTypeUInt32
+	case "Int64":
+		return domain.DataTypeInt64
+	case "UInt64":
+		return domain.DataTypeUInt64
+	case "Float":
+		return domain.DataTypeFloat32
+	case "Double":
+		return domain.DataTypeFloat64
+	case "String", "LocalizedText", "DateTime", "Guid", "NodeId", "XmlElement":
+		return domain.DataTypeString
+	default:
+		return ""
+	}
+}
+
+// browseAccessMode maps an access level string (see accessLevelToString) to
+// a domain access mode.
+func browseAccessMode(accessLevel string) domain.AccessMode {
+	var read, write bool
+	for _, part := range strings.Split(accessLevel, ", ") {
+		switch part {
+		case "Read":
+			read = true
+		case "Write":
+			write = true
+		}
+	}
+	switch {
+	case read && write:
+		return domain.AccessModeReadWrite
+	case write:
+		return domain.AccessModeWriteOnly
+	case read:
+		return domain.AccessModeReadOnly
+	default:
+		return ""
+	}
+}
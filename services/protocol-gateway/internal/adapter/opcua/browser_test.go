@@ -0,0 +1,79 @@
+package opcua
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/gopcua/opcua/ua"
+	"github.com/nexus-edge/protocol-gateway/internal/domain"
+)
+
+func TestBrowseResultsFlattenTree(t *testing.T) {
+	root := &BrowseResult{
+		NodeID: "i=85",
+		Children: []*BrowseResult{
+			{NodeID: "i=2253", BrowseName: "Server", NodeClass: ua.NodeClassObject, NodeClassName: "Object", HasChildren: true},
+			{
+				NodeID: "ns=2;s=Line 1", DisplayName: "Line 1", BrowseName: "Line1",
+				NodeClass: ua.NodeClassObject, NodeClassName: "Object", HasChildren: true,
+				Children: []*BrowseResult{
+					{
+						NodeID: "ns=2;s=Line 1.Speed", DisplayName: "Motor.Speed", NodeClass: ua.NodeClassVariable,
+						NodeClassName: "Variable", DataType: "Double", AccessLevel: "Read, Write, HistoryRead",
+					},
+					{NodeID: "ns=2;s=Line 1.Recipe", DisplayName: "Recipe", NodeClass: ua.NodeClassObject, NodeClassName: "Object", HasChildren: true},
+				},
+			},
+		},
+	}
+
+	got := browseResults(root, true)
+	if len(got) != 3 {
+		t.Fatalf("expected 3 results (Server skipped), got %d: %#v", len(got), got)
+	}
+
+	line := got[0]
+	if line.ID != "Line_1" || line.Address != "ns=2;s=Line 1" || line.Children != 2 || line.DataType != "" {
+		t.Errorf("unexpected folder result: %#v", line)
+	}
+
+	speed := got[1]
+	want := domain.BrowseResult{
+		ID:         "Line_1_Motor_Speed",
+		Name:       "Motor.Speed",
+		Address:    "ns=2;s=Line 1.Speed",
+		DataType:   domain.DataTypeFloat64,
+		AccessMode: domain.AccessModeReadWrite,
+		Path:       []string{"Line 1", "Motor.Speed"},
+		Metadata:   map[string]string{"node_class": "Variable", "namespace": "2", "opc_data_type": "Double"},
+	}
+	if !reflect.DeepEqual(speed, want) {
+		t.Errorf("got %#v, want %#v", speed, want)
+	}
+
+	// Children below the requested depth are reported as unknown
+	if recipe := got[2]; recipe.Children != -1 || !reflect.DeepEqual(recipe.Path, []string{"Line 1", "Recipe"}) {
+		t.Errorf("unexpected unexpanded result: %#v", recipe)
+	}
+
+	// Browsing from another root keeps namespace 0 nodes
+	if got := browseResults(root, false); len(got) != 4 || got[0].Name != "Server" || got[0].Children != -1 {
+		t.Errorf("expected Server node without system filter, got %#v", got)
+	}
+}
+
+func TestBrowseAccessMode(t *testing.T) {
+	tests := map[string]domain.AccessMode{
+		"Read":               domain.AccessModeReadOnly,
+		"Read, HistoryWrite": domain.AccessModeReadOnly,
+		"Write":              domain.AccessModeWriteOnly,
+		"Read, Write":        domain.AccessModeReadWrite,
+		"HistoryRead":        "",
+		"None":               "",
+	}
+	for level, want := range tests {
+		if got := browseAccessMode(level); got != want {
+			t.Errorf("%q: got %q, want %q", level, got, want)
+		}
+	}
+}
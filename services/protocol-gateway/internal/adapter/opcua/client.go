@@ -14,6 +14,7 @@ import (
 	"time"
 
 	"github.com/gopcua/opcua"
+	"github.com/gopcua/opcua/id"
 	"github.com/gopcua/opcua/ua"
 	"github.com/nexus-edge/protocol-gateway/internal/domain"
 	"github.com/nexus-edge/protocol-gateway/internal/metrics"
@@ -72,6 +73,7 @@ func NewClient(deviceID string, config ClientConfig, logger zerolog.Logger) (*Cl
 		deviceID:     deviceID,
 		lastUsed:     time.Now(),
 		nodeCache:    make(map[string]*ua.NodeID),
+		types:        newTypeSystem(),
 		namespaceMap: make(map[string]uint16),
 		sessionState: SessionStateDisconnected,
 	}
@@ -200,6 +202,9 @@ func (c *Client) Disconnect() error {
 	c.nodeCache = make(map[string]*ua.NodeID)
 	c.nodeCacheMu.Unlock()
 
+	// Clear type cache (namespace indexes may change)
+	c.types = newTypeSystem()
+
 	// Clear namespace cache (will be repopulated on reconnect)
 	c.namespaceMu.Lock()
 	c.namespaceMap = make(map[string]uint16)
@@ -223,7 +228,7 @@ func (c *Client) IsConnected() bool {
 }
 
 // ReadTag reads a single tag from the OPC UA server.
-func (c *Client) ReadTag(ctx context.Context, tag *domain.Tag) (*domain.DataPoint, error) {
+func (c *Client) ReadTag(ctx context.Context, tag *domain.Tag, decoding ValueDecoding) (*domain.DataPoint, error) {
 	startTime := time.Now()
 	defer func() {
 		c.stats.TotalReadTime.Add(time.Since(startTime).Nanoseconds())
@@ -263,7 +268,7 @@ func (c *Client) ReadTag(ctx context.Context, tag *domain.Tag) (*domain.DataPoin
 			}
 		}
 
-		dp, err = c.readNode(ctx, nodeID, tag)
+		dp, err = c.readNode(ctx, nodeID, tag, decoding)
 		if err == nil {
 			break
 		}
@@ -292,7 +297,7 @@ func (c *Client) ReadTag(ctx context.Context, tag *domain.Tag) (*domain.DataPoin
 
 // ReadTags reads multiple tags efficiently using batch reads.
 // Uses opMu to serialize batch operations for thread safety.
-func (c *Client) ReadTags(ctx context.Context, tags []*domain.Tag) ([]*domain.DataPoint, error) {
+func (c *Client) ReadTags(ctx context.Context, tags []*domain.Tag, decoding ValueDecoding) ([]*domain.DataPoint, error) {
 	if len(tags) == 0 {
 		return nil, nil
 	}
@@ -360,7 +365,7 @@ func (c *Client) ReadTags(ctx context.Context, tags []*domain.Tag) ([]*domain.Da
 			break
 		}
 		tag := validTags[i]
-		dp := c.processReadResult(result, tag)
+		dp := c.processReadResult(result, tag, decoding)
 		results = append(results, dp)
 	}
 
@@ -556,14 +561,11 @@ func (c *Client) WriteTags(ctx context.Context, writes []TagWrite) []error {
 }
 
 // TagWrite represents a single write operation.
-type TagWrite struct {
-	Tag   *domain.Tag
-	Value interface{}
-}
+type TagWrite = domain.TagWrite
 
 // readNode performs a single node read operation.
 // Uses opMu to serialize operations for thread safety.
-func (c *Client) readNode(ctx context.Context, nodeID *ua.NodeID, tag *domain.Tag) (*domain.DataPoint, error) {
+func (c *Client) readNode(ctx context.Context, nodeID *ua.NodeID, tag *domain.Tag, decoding ValueDecoding) (*domain.DataPoint, error) {
 	c.mu.RLock()
 	client := c.client
 	c.mu.RUnlock()
@@ -599,7 +601,7 @@ func (c *Client) readNode(ctx context.Context, nodeID *ua.NodeID, tag *domain.Ta
 	}
 
 	c.consecutiveFailures.Store(0)
-	return c.processReadResult(resp.Results[0], tag), nil
+	return c.processReadResult(resp.Results[0], tag, decoding), nil
 }
 
 // writeNode performs a single node write operation.
@@ -649,8 +651,105 @@ func (c *Client) writeNode(ctx context.Context, nodeID *ua.NodeID, variant *ua.V
 	return nil
 }
 
+// callMethod calls an OPC UA method and checks its result status.
+// Uses opMu to serialize operations for thread safety.
+func (c *Client) callMethod(ctx context.Context, req *ua.CallMethodRequest) (*ua.CallMethodResult, error) {
+	c.mu.RLock()
+	client := c.client
+	c.mu.RUnlock()
+
+	if client == nil {
+		return nil, domain.ErrConnectionClosed
+	}
+
+	// Serialize OPC UA operations
+	c.opMu.Lock()
+	defer c.opMu.Unlock()
+
+	result, err := client.Call(ctx, req)
+	if err != nil {
+		c.consecutiveFailures.Add(1)
+		return nil, fmt.Errorf("%w: %v", domain.ErrOPCUAMethodCallFailed, err)
+	}
+
+	// The server answered: a bad result status is a rejection of the call
+	// (ErrOPCUABadStatus), not a connection failure
+	c.consecutiveFailures.Store(0)
+	if result.StatusCode != ua.StatusOK {
+		// Report which input arguments the server rejected
+		for i, status := range result.InputArgumentResults {
+			if status != ua.StatusOK {
+				return nil, fmt.Errorf("%w: %w: %s (input argument %d: %s)", domain.ErrOPCUAMethodCallFailed, domain.ErrOPCUABadStatus, result.StatusCode, i, status)
+			}
+		}
+		return nil, fmt.Errorf("%w: %w: %s", domain.ErrOPCUAMethodCallFailed, domain.ErrOPCUABadStatus, result.StatusCode)
+	}
+
+	return result, nil
+}
+
+// historyReadRaw reads raw historical values of nodes (HistoryReadRawModified).
+// Per-node status codes and continuation points are left to the caller.
+// Uses opMu to serialize operations for thread safety.
+func (c *Client) historyReadRaw(ctx context.Context, nodes []*ua.HistoryReadValueID, details *ua.ReadRawModifiedDetails) ([]*ua.HistoryReadResult, error) {
+	c.mu.RLock()
+	client := c.client
+	c.mu.RUnlock()
+
+	if client == nil {
+		return nil, domain.ErrConnectionClosed
+	}
+
+	// Serialize OPC UA operations
+	c.opMu.Lock()
+	defer c.opMu.Unlock()
+
+	resp, err := client.HistoryReadRawModified(ctx, nodes, details)
+	if err != nil {
+		c.consecutiveFailures.Add(1)
+		return nil, fmt.Errorf("%w: %v", domain.ErrOPCUAHistoryReadFailed, err)
+	}
+
+	if len(resp.Results) != len(nodes) {
+		return nil, fmt.Errorf("%w: expected %d results, got %d", domain.ErrOPCUAHistoryReadFailed, len(nodes), len(resp.Results))
+	}
+
+	c.consecutiveFailures.Store(0)
+	return resp.Results, nil
+}
+
+// releaseHistoryReads releases the continuation points of history reads that
+// are abandoned before their last value.
+func (c *Client) releaseHistoryReads(ctx context.Context, nodes []*ua.HistoryReadValueID, details *ua.ReadRawModifiedDetails) error {
+	c.mu.RLock()
+	client := c.client
+	c.mu.RUnlock()
+
+	if client == nil {
+		return domain.ErrConnectionClosed
+	}
+
+	c.opMu.Lock()
+	defer c.opMu.Unlock()
+
+	req := &ua.HistoryReadRequest{
+		TimestampsToReturn: ua.TimestampsToReturnBoth,
+		NodesToRead:        nodes,
+		HistoryReadDetails: &ua.ExtensionObject{
+			TypeID:       ua.NewFourByteExpandedNodeID(0, id.ReadRawModifiedDetails_Encoding_DefaultBinary),
+			EncodingMask: ua.ExtensionObjectBinary,
+			Value:        details,
+		},
+		ReleaseContinuationPoints: true,
+	}
+	if err := client.Send(ctx, req, func(interface{}) error { return nil }); err != nil {
+		return fmt.Errorf("%w: %v", domain.ErrOPCUAHistoryReadFailed, err)
+	}
+	return nil
+}
+
 // processReadResult converts an OPC UA read result to a DataPoint.
-func (c *Client) processReadResult(result *ua.DataValue, tag *domain.Tag) *domain.DataPoint {
+func (c *Client) processReadResult(result *ua.DataValue, tag *domain.Tag, decoding ValueDecoding) *domain.DataPoint {
 	quality := c.statusCodeToQuality(result.Status)
 
 	if quality != domain.QualityGood {
@@ -665,7 +764,7 @@ func (c *Client) processReadResult(result *ua.DataValue, tag *domain.Tag) *domai
 	}
 
 	// Extract value from variant
-	value := c.variantToValue(result.Value, tag)
+	value := c.variantToValue(result.Value, tag, decoding)
 
 	// Apply scaling and offset
 	scaledValue := applyScaling(value, tag)
@@ -691,12 +790,21 @@ func (c *Client) processReadResult(result *ua.DataValue, tag *domain.Tag) *domai
 	return dp
 }
 
-// variantToValue converts an OPC UA variant to a Go value.
-func (c *Client) variantToValue(v *ua.Variant, tag *domain.Tag) interface{} {
+// variantToValue converts an OPC UA variant to a Go value. With DecodeTyped,
+// arrays, structures, enums and LocalizedText become JSON arrays and objects.
+func (c *Client) variantToValue(v *ua.Variant, tag *domain.Tag, decoding ValueDecoding) interface{} {
 	if v == nil {
 		return nil
 	}
 
+	if decoding == DecodeTyped {
+		var nodeID string
+		if parsed, err := c.getNodeIDForTag(tag); err == nil {
+			nodeID = parsed.String()
+		}
+		return c.typeCache().value(nodeID, v.Value())
+	}
+
 	// Return the value directly - the OPC UA library handles type conversion
 	return v.Value()
 }
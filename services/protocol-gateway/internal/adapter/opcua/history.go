@@ -0,0 +1,332 @@
+// Package opcua provides subscription recovery with history backfill of data gaps.
+package opcua
+
+import (
+	"context"
+	"time"
+
+	"github.com/gopcua/opcua/ua"
+	"github.com/nexus-edge/protocol-gateway/internal/domain"
+)
+
+// Backfill results, as recorded in metrics.
+const (
+	backfillResultOK          = "ok"
+	backfillResultUnsupported = "unsupported"
+	backfillResultFailed      = "failed"
+)
+
+// backfillTimeout bounds the history reads of one device gap.
+const backfillTimeout = 2 * time.Minute
+
+// releaseTimeout bounds the release of abandoned continuation points. It runs
+// on its own deadline, as the backfill may have stopped on a cancelled context.
+const releaseTimeout = 10 * time.Second
+
+// historyReader reads raw history and releases the continuation points of
+// reads that are not continued; implemented by *Client.
+type historyReader interface {
+	historyReadRaw(ctx context.Context, nodes []*ua.HistoryReadValueID, details *ua.ReadRawModifiedDetails) ([]*ua.HistoryReadResult, error)
+	releaseHistoryReads(ctx context.Context, nodes []*ua.HistoryReadValueID, details *ua.ReadRawModifiedDetails) error
+}
+
+// tagGap is the period a subscribed tag went without data.
+type tagGap struct {
+	tag   *domain.Tag
+	start time.Time // Source time of the last value before the gap (exclusive)
+}
+
+// deviceGap holds the tag gaps of one device subscription. All gaps end when
+// the subscription was recreated; later values arrive through it.
+type deviceGap struct {
+	sub  *Subscription
+	end  time.Time
+	tags []tagGap
+}
+
+// subscriptionGaps returns the gap of every tag of a subscription that had a
+// value before the outage. With report-by-exception, a tag's last value may
+// predate the outage; the history read then simply returns nothing for the
+// quiet period. Gaps are limited to maxWindow before end.
+func subscriptionGaps(sub *Subscription, end time.Time, maxWindow time.Duration) *deviceGap {
+	gap := &deviceGap{sub: sub, end: end}
+	earliest := end.Add(-maxWindow)
+
+	sub.mu.RLock()
+	defer sub.mu.RUnlock()
+	for _, tag := range sub.TagList {
+		last, ok := sub.LastValues[tag.ID]
+		if !ok || last == nil {
+			continue
+		}
+		start := last.Timestamp
+		if last.SourceTimestamp != nil {
+			start = *last.SourceTimestamp
+		}
+		if start.Before(earliest) {
+			start = earliest
+		}
+		if !start.Before(end) {
+			continue
+		}
+		gap.tags = append(gap.tags, tagGap{tag: tag, start: start})
+	}
+	return gap
+}
+
+// recoverSubscriptions recreates all subscriptions after the client
+// reconnected and returns the gaps of devices with OPCHistoryBackfill.
+func (sm *SubscriptionManager) recoverSubscriptions(maxWindow time.Duration) []*deviceGap {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+
+	var gaps []*deviceGap
+	for deviceID, sub := range sm.subscriptions {
+		var gap *deviceGap
+		if sub.Device.Connection.OPCHistoryBackfill {
+			// Gap ends are approximate: the server's initial notifications
+			// of the new subscription carry the current values.
+			gap = subscriptionGaps(sub, time.Now(), maxWindow)
+		}
+
+		if err := sm.resubscribeLocked(sub); err != nil {
+			sm.logger.Error().
+				Err(err).
+				Str("device_id", deviceID).
+				Msg("Failed to recreate subscription after reconnect")
+			continue
+		}
+		sm.logger.Info().
+			Str("device_id", deviceID).
+			Uint32("subscription_id", sub.ID).
+			Msg("Subscription recreated after reconnect")
+
+		if gap != nil && len(gap.tags) > 0 {
+			gaps = append(gaps, gap)
+		}
+	}
+	return gaps
+}
+
+// backfill reads the history of a device's gaps (HistoryReadRawModified) and
+// hands the values to the data handler, marked as backfilled. Returns the
+// number of values published and whether the server had history for any tag.
+func (sm *SubscriptionManager) backfill(ctx context.Context, gap *deviceGap, maxNodes, maxValues int) (int, bool, error) {
+	return sm.readHistory(ctx, sm.client, gap, maxNodes, maxValues)
+}
+
+// readHistory implements backfill. Servers keep a few continuation points per
+// session, so those of nodes truncated at maxValues, or left when the reads
+// stop on an error, are released before returning; otherwise later history
+// reads fail with BadNoContinuationPoints.
+func (sm *SubscriptionManager) readHistory(ctx context.Context, reader historyReader, gap *deviceGap, maxNodes, maxValues int) (int, bool, error) {
+	type pendingNode struct {
+		gap   tagGap
+		read  *ua.HistoryReadValueID
+		count int
+	}
+
+	nodes := make([]*pendingNode, 0, len(gap.tags))
+	earliest := gap.end
+	for _, tg := range gap.tags {
+		nodeID, err := sm.client.getNodeIDForTag(tg.tag)
+		if err != nil {
+			continue
+		}
+		nodes = append(nodes, &pendingNode{
+			gap:  tg,
+			read: &ua.HistoryReadValueID{NodeID: nodeID, DataEncoding: &ua.QualifiedName{}},
+		})
+		if tg.start.Before(earliest) {
+			earliest = tg.start
+		}
+	}
+
+	details := &ua.ReadRawModifiedDetails{
+		StartTime:        earliest,
+		EndTime:          gap.end,
+		NumValuesPerNode: uint32(maxValues),
+	}
+
+	var abandoned []*ua.HistoryReadValueID
+	defer func() {
+		if len(abandoned) > 0 {
+			sm.releaseContinuationPoints(reader, gap.sub.Device.ID, abandoned, details)
+		}
+	}()
+
+	published := 0
+	supported := false
+	for len(nodes) > 0 {
+		batch := nodes[:min(len(nodes), maxNodes)]
+		nodes = nodes[len(batch):]
+
+		for len(batch) > 0 {
+			reads := make([]*ua.HistoryReadValueID, len(batch))
+			for i, node := range batch {
+				reads[i] = node.read
+			}
+			results, err := reader.historyReadRaw(ctx, reads, details)
+			if err != nil {
+				for _, read := range reads {
+					if len(read.ContinuationPoint) > 0 {
+						abandoned = append(abandoned, read)
+					}
+				}
+				return published, supported, err
+			}
+
+			var next []*pendingNode
+			for i, result := range results {
+				node := batch[i]
+				if isBadStatus(result.StatusCode) {
+					sm.logger.Debug().
+						Str("device_id", gap.sub.Device.ID).
+						Str("tag_id", node.gap.tag.ID).
+						Str("status", result.StatusCode.Error()).
+						Msg("No history for tag")
+					continue
+				}
+				supported = true
+
+				var values []*ua.DataValue
+				if result.HistoryData != nil {
+					if data, ok := result.HistoryData.Value.(*ua.HistoryData); ok {
+						values = data.DataValues
+					}
+				}
+				for _, value := range values {
+					if node.count >= maxValues {
+						break
+					}
+					ts := value.SourceTimestamp
+					if ts.IsZero() {
+						ts = value.ServerTimestamp
+					}
+					if !ts.After(node.gap.start) || !ts.Before(gap.end) {
+						continue
+					}
+					sm.publishBackfill(gap.sub, node.gap.tag, value, ts)
+					node.count++
+					published++
+				}
+
+				if len(result.ContinuationPoint) == 0 {
+					continue
+				}
+				continued := &ua.HistoryReadValueID{
+					NodeID:            node.read.NodeID,
+					DataEncoding:      node.read.DataEncoding,
+					ContinuationPoint: result.ContinuationPoint,
+				}
+				if node.count >= maxValues {
+					sm.logger.Warn().
+						Str("device_id", gap.sub.Device.ID).
+						Str("tag_id", node.gap.tag.ID).
+						Int("max_values", maxValues).
+						Msg("History backfill truncated at the value limit")
+					abandoned = append(abandoned, continued)
+					continue
+				}
+				node.read = continued
+				next = append(next, node)
+			}
+			batch = next
+		}
+	}
+	return published, supported, nil
+}
+
+// releaseContinuationPoints releases the continuation points of abandoned
+// history reads.
+func (sm *SubscriptionManager) releaseContinuationPoints(reader historyReader, deviceID string, reads []*ua.HistoryReadValueID, details *ua.ReadRawModifiedDetails) {
+	ctx, cancel := context.WithTimeout(context.Background(), releaseTimeout)
+	defer cancel()
+	if err := reader.releaseHistoryReads(ctx, reads, details); err != nil {
+		sm.logger.Warn().
+			Err(err).
+			Str("device_id", deviceID).
+			Int("nodes", len(reads)).
+			Msg("Failed to release history continuation points")
+	}
+}
+
+// publishBackfill converts a historical value and hands it to the data handler.
+func (sm *SubscriptionManager) publishBackfill(sub *Subscription, tag *domain.Tag, value *ua.DataValue, ts time.Time) {
+	quality := sm.client.statusCodeToQuality(value.Status)
+	var raw, scaled interface{}
+	if quality == domain.QualityGood {
+		raw = sm.client.variantToValue(value.Value, tag, valueDecodingFor(sub.Device))
+		scaled = applyScaling(raw, tag)
+	}
+
+	dp := domain.AcquireDataPoint(sub.Device.ID, tag.ID, tagTopic(sub.Device.UNSPrefix, tag), scaled, tag.Unit, quality).
+		WithRawValue(raw).
+		WithPriority(tag.Priority).
+		AsBackfill(ts)
+
+	if sm.dataHandler != nil {
+		sm.dataHandler(dp)
+	}
+}
+
+// isBadStatus reports whether a status code has bad severity.
+func isBadStatus(status ua.StatusCode) bool {
+	return uint32(status)&0x80000000 != 0
+}
+
+// backfillSession recovers the subscriptions of a reconnected session and
+// backfills the gaps of its devices from the server's history.
+func (p *ConnectionPool) backfillSession(session *pooledSession) {
+	session.mu.Lock()
+	mgr := session.subscriptionMgr
+	session.mu.Unlock()
+	if mgr == nil {
+		return
+	}
+
+	gaps := mgr.recoverSubscriptions(p.config.BackfillMaxWindow)
+	for _, gap := range gaps {
+		deviceID := gap.sub.Device.ID
+		if _, exists := p.getDeviceBinding(deviceID); !exists {
+			continue
+		}
+		// History reads bypass the breakers: a server that rejects them
+		// must not open the device breaker and stop its live reads.
+		ctx, cancel := context.WithTimeout(context.Background(), backfillTimeout)
+		var published int
+		var supported bool
+		err := p.checkGlobalLoadAndQueueWithSession(ctx, PriorityTelemetry, session, func() error {
+			var err error
+			published, supported, err = mgr.backfill(ctx, gap, p.config.MaxNodesPerRead, p.config.BackfillMaxValues)
+			return err
+		})
+		cancel()
+
+		result := backfillResultOK
+		switch {
+		case err != nil:
+			result = backfillResultFailed
+			p.logger.Warn().
+				Err(err).
+				Str("device_id", deviceID).
+				Int("published", published).
+				Msg("History backfill failed")
+		case !supported:
+			result = backfillResultUnsupported
+			p.logger.Info().
+				Str("device_id", deviceID).
+				Msg("Server has no history for the device's tags, gap not backfilled")
+		default:
+			p.logger.Info().
+				Str("device_id", deviceID).
+				Int("tags", len(gap.tags)).
+				Int("published", published).
+				Time("gap_end", gap.end).
+				Msg("Backfilled data gap from history")
+		}
+		if p.metrics != nil {
+			p.metrics.RecordOPCUABackfill(deviceID, result, published)
+		}
+	}
+}
@@ -0,0 +1,113 @@
+package opcua
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/gopcua/opcua/ua"
+	"github.com/nexus-edge/protocol-gateway/internal/domain"
+	"github.com/rs/zerolog"
+)
+
+func TestSubscriptionGaps(t *testing.T) {
+	end := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	tags := []*domain.Tag{{ID: "recent"}, {ID: "stale"}, {ID: "never"}, {ID: "server-time"}}
+	sub := newSubscription(&domain.Device{ID: "plc-1"}, tags, DefaultSubscriptionConfig())
+
+	recent := end.Add(-5 * time.Minute)
+	sub.LastValues["recent"] = (&domain.DataPoint{Timestamp: end}).WithSourceTimestamp(recent)
+	sub.LastValues["stale"] = (&domain.DataPoint{Timestamp: end}).WithSourceTimestamp(end.Add(-3 * time.Hour))
+	sub.LastValues["server-time"] = &domain.DataPoint{Timestamp: end.Add(-time.Minute)}
+
+	gap := subscriptionGaps(sub, end, time.Hour)
+	if gap.end != end || len(gap.tags) != 3 {
+		t.Fatalf("expected gaps for 3 tags ending at %v, got %+v", end, gap)
+	}
+	want := map[string]time.Time{
+		"recent":      recent,
+		"stale":       end.Add(-time.Hour), // Limited to the window
+		"server-time": end.Add(-time.Minute),
+	}
+	for _, tg := range gap.tags {
+		if !tg.start.Equal(want[tg.tag.ID]) {
+			t.Errorf("%s: gap start %v, want %v", tg.tag.ID, tg.start, want[tg.tag.ID])
+		}
+	}
+
+	// Values at or after the end leave no gap
+	sub.LastValues["recent"] = (&domain.DataPoint{}).WithSourceTimestamp(end)
+	for _, tg := range subscriptionGaps(sub, end, time.Hour).tags {
+		if tg.tag.ID == "recent" {
+			t.Error("expected no gap for a tag with a value at the gap end")
+		}
+	}
+}
+
+// fakeHistory returns one value per read with a continuation point, so every
+// node has more history than it is allowed to read.
+type fakeHistory struct {
+	start    time.Time
+	calls    int
+	failNext bool // Fail reads that continue a previous one
+	released [][]byte
+}
+
+func (f *fakeHistory) historyReadRaw(_ context.Context, nodes []*ua.HistoryReadValueID, _ *ua.ReadRawModifiedDetails) ([]*ua.HistoryReadResult, error) {
+	f.calls++
+	results := make([]*ua.HistoryReadResult, len(nodes))
+	for i, node := range nodes {
+		if f.failNext && len(node.ContinuationPoint) > 0 {
+			return nil, errors.New("connection lost")
+		}
+		value := &ua.DataValue{Value: ua.MustVariant(float64(f.calls)), SourceTimestamp: f.start.Add(time.Duration(f.calls) * time.Second)}
+		results[i] = &ua.HistoryReadResult{
+			ContinuationPoint: []byte(fmt.Sprintf("%s/%d", node.NodeID, f.calls)),
+			HistoryData:       ua.NewExtensionObject(&ua.HistoryData{DataValues: []*ua.DataValue{value}}),
+		}
+	}
+	return results, nil
+}
+
+func (f *fakeHistory) releaseHistoryReads(_ context.Context, nodes []*ua.HistoryReadValueID, _ *ua.ReadRawModifiedDetails) error {
+	for _, node := range nodes {
+		f.released = append(f.released, node.ContinuationPoint)
+	}
+	return nil
+}
+
+func TestReadHistory_ReleasesAbandonedContinuationPoints(t *testing.T) {
+	end := time.Now()
+	start := end.Add(-time.Hour)
+	tags := []*domain.Tag{{ID: "a", OPCNodeID: "ns=2;s=a"}, {ID: "b", OPCNodeID: "ns=2;s=b"}}
+	sub := newSubscription(&domain.Device{ID: "plc-1"}, tags, DefaultSubscriptionConfig())
+	gap := &deviceGap{sub: sub, end: end, tags: []tagGap{{tag: tags[0], start: start}, {tag: tags[1], start: start}}}
+
+	var published int
+	sm := &SubscriptionManager{
+		client:      &Client{nodeCache: make(map[string]*ua.NodeID)},
+		logger:      zerolog.Nop(),
+		dataHandler: func(*domain.DataPoint) { published++ },
+	}
+
+	// Truncated at the value limit
+	reader := &fakeHistory{start: start}
+	n, supported, err := sm.readHistory(context.Background(), reader, gap, 10, 2)
+	if err != nil || !supported || n != 4 || published != 4 {
+		t.Fatalf("expected 4 values, got %d (%d handled), supported=%v, err=%v", n, published, supported, err)
+	}
+	if fmt.Sprint(reader.released) != fmt.Sprint([][]byte{[]byte("ns=2;s=a/2"), []byte("ns=2;s=b/2")}) {
+		t.Errorf("expected the last continuation points to be released, got %q", reader.released)
+	}
+
+	// Stopped by an error
+	reader = &fakeHistory{start: start, failNext: true}
+	if _, _, err := sm.readHistory(context.Background(), reader, gap, 10, 5); err == nil {
+		t.Fatal("expected the read error")
+	}
+	if fmt.Sprint(reader.released) != fmt.Sprint([][]byte{[]byte("ns=2;s=a/1"), []byte("ns=2;s=b/1")}) {
+		t.Errorf("expected the pending continuation points to be released, got %q", reader.released)
+	}
+}
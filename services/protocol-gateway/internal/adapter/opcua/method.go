@@ -0,0 +1,349 @@
+// Package opcua provides OPC UA method calls with argument validation.
+package opcua
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"math"
+	"reflect"
+	"time"
+
+	"github.com/gopcua/opcua/id"
+	"github.com/gopcua/opcua/ua"
+	"github.com/nexus-edge/protocol-gateway/internal/domain"
+)
+
+// methodSignature holds the InputArguments and OutputArguments properties of a method.
+type methodSignature struct {
+	inputs  []*ua.Argument
+	outputs []*ua.Argument
+}
+
+// Browse names of the method argument properties.
+const (
+	inputArgumentsName  = "InputArguments"
+	outputArgumentsName = "OutputArguments"
+)
+
+// methodSignature returns the argument definitions of a method, reading its
+// InputArguments and OutputArguments properties on first use. Data types of
+// arguments that are not builtin (e.g., enums) are resolved as well.
+func (c *Client) methodSignature(ctx context.Context, methodID *ua.NodeID) (*methodSignature, error) {
+	ts := c.typeCache()
+	ts.mu.RLock()
+	sig, ok := ts.methods[methodID.String()]
+	ts.mu.RUnlock()
+	if ok {
+		return sig, nil
+	}
+
+	refs, err := c.browseProperties(ctx, methodID)
+	if err != nil {
+		return nil, fmt.Errorf("%w: method %s: %w", domain.ErrOPCUABrowseFailed, methodID, err)
+	}
+
+	var propertyIDs []*ua.NodeID
+	var names []string
+	for _, ref := range refs {
+		if ref.BrowseName == nil || ref.NodeID == nil {
+			continue
+		}
+		if name := ref.BrowseName.Name; name == inputArgumentsName || name == outputArgumentsName {
+			propertyIDs = append(propertyIDs, ref.NodeID.NodeID)
+			names = append(names, name)
+		}
+	}
+
+	sig = &methodSignature{}
+	if len(propertyIDs) > 0 {
+		results, err := c.readAttribute(ctx, propertyIDs, ua.AttributeIDValue)
+		if err != nil {
+			return nil, err
+		}
+		for i, result := range results {
+			if isBadStatus(result.Status) || result.Value == nil {
+				return nil, fmt.Errorf("%w: %w: %s of method %s: %s", domain.ErrReadFailed, domain.ErrOPCUABadStatus, names[i], methodID, result.Status)
+			}
+			args := argumentsFromValue(result.Value.Value())
+			if names[i] == inputArgumentsName {
+				sig.inputs = args
+			} else {
+				sig.outputs = args
+			}
+		}
+	}
+
+	pending := make(map[string]*ua.NodeID)
+	ts.mu.RLock()
+	for _, arg := range append(append([]*ua.Argument(nil), sig.inputs...), sig.outputs...) {
+		if _, known := ts.dataTypes[arg.DataType.String()]; !known {
+			pending[arg.DataType.String()] = arg.DataType
+		}
+	}
+	ts.mu.RUnlock()
+	if err := c.resolveDefinitions(ctx, pending); err != nil {
+		return nil, err
+	}
+
+	ts.mu.Lock()
+	ts.methods[methodID.String()] = sig
+	ts.mu.Unlock()
+	return sig, nil
+}
+
+// argumentsFromValue converts an InputArguments or OutputArguments value.
+func argumentsFromValue(v interface{}) []*ua.Argument {
+	objects, _ := v.([]*ua.ExtensionObject)
+	args := make([]*ua.Argument, 0, len(objects))
+	for _, eo := range objects {
+		if eo == nil {
+			continue
+		}
+		if arg, ok := eo.Value.(*ua.Argument); ok && arg.DataType != nil {
+			args = append(args, arg)
+		}
+	}
+	return args
+}
+
+// browseProperties returns the HasProperty references of a node.
+// Uses opMu to serialize operations for thread safety.
+func (c *Client) browseProperties(ctx context.Context, nodeID *ua.NodeID) ([]*ua.ReferenceDescription, error) {
+	c.mu.RLock()
+	client := c.client
+	c.mu.RUnlock()
+
+	if client == nil {
+		return nil, domain.ErrConnectionClosed
+	}
+
+	c.opMu.Lock()
+	defer c.opMu.Unlock()
+
+	resp, err := client.Browse(ctx, &ua.BrowseRequest{
+		NodesToBrowse: []*ua.BrowseDescription{{
+			NodeID:          nodeID,
+			BrowseDirection: ua.BrowseDirectionForward,
+			ReferenceTypeID: ua.NewNumericNodeID(0, id.HasProperty),
+			IncludeSubtypes: true,
+			NodeClassMask:   uint32(ua.NodeClassVariable),
+			ResultMask:      uint32(ua.BrowseResultMaskBrowseName),
+		}},
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(resp.Results) == 0 {
+		return nil, nil
+	}
+	if status := resp.Results[0].StatusCode; status != ua.StatusOK {
+		return nil, fmt.Errorf("%w: %s", domain.ErrOPCUABadStatus, status)
+	}
+	return resp.Results[0].References, nil
+}
+
+// inputVariants validates positional input values against the method's
+// InputArguments and converts them to variants of the declared types.
+func (sig *methodSignature) inputVariants(inputs []interface{}, ts *typeSystem) ([]*ua.Variant, error) {
+	if len(inputs) != len(sig.inputs) {
+		return nil, fmt.Errorf("%w: method expects %d input arguments, got %d", domain.ErrOPCUAInvalidArgument, len(sig.inputs), len(inputs))
+	}
+
+	ts.mu.RLock()
+	defer ts.mu.RUnlock()
+
+	variants := make([]*ua.Variant, len(inputs))
+	for i, arg := range sig.inputs {
+		variant, err := ts.argumentVariant(arg, inputs[i])
+		if err != nil {
+			name := arg.Name
+			if name == "" {
+				name = fmt.Sprintf("#%d", i)
+			}
+			return nil, fmt.Errorf("%w: %s: %v", domain.ErrOPCUAInvalidArgument, name, err)
+		}
+		variants[i] = variant
+	}
+	return variants, nil
+}
+
+// outputArguments converts output variants to named method arguments.
+func (sig *methodSignature) outputArguments(outputs []*ua.Variant, ts *typeSystem) []domain.MethodArgument {
+	args := make([]domain.MethodArgument, len(outputs))
+	for i, output := range outputs {
+		if i < len(sig.outputs) {
+			args[i].Name = sig.outputs[i].Name
+		}
+		if output != nil {
+			args[i].Value = ts.value("", output.Value())
+		}
+	}
+	return args
+}
+
+// argumentVariant converts a JSON value to a variant of the argument's data
+// type and value rank. Callers hold ts.mu.
+func (ts *typeSystem) argumentVariant(arg *ua.Argument, value interface{}) (*ua.Variant, error) {
+	convert, elemType, err := ts.argumentConverter(arg.DataType)
+	if err != nil {
+		return nil, err
+	}
+
+	values, isArray := value.([]interface{})
+	switch {
+	case arg.ValueRank == -1 && isArray:
+		return nil, fmt.Errorf("expected a scalar, got an array")
+	case arg.ValueRank >= 0 && !isArray:
+		return nil, fmt.Errorf("expected an array, got %T", value)
+	case arg.ValueRank > 1:
+		return nil, fmt.Errorf("multi-dimensional arguments are not supported")
+	}
+
+	if !isArray {
+		v, err := convert(value)
+		if err != nil {
+			return nil, err
+		}
+		return ua.NewVariant(v)
+	}
+
+	if len(arg.ArrayDimensions) == 1 && arg.ArrayDimensions[0] > 0 && uint32(len(values)) > arg.ArrayDimensions[0] {
+		return nil, fmt.Errorf("array has %d elements, at most %d allowed", len(values), arg.ArrayDimensions[0])
+	}
+	slice := reflect.MakeSlice(reflect.SliceOf(elemType), len(values), len(values))
+	for i, element := range values {
+		v, err := convert(element)
+		if err != nil {
+			return nil, fmt.Errorf("element %d: %v", i, err)
+		}
+		slice.Index(i).Set(reflect.ValueOf(v))
+	}
+	return ua.NewVariant(slice.Interface())
+}
+
+// argumentConverter returns the conversion of JSON values to a data type and
+// the Go type of its values. Builtin types, their standard subtypes and enums
+// are supported. Callers hold ts.mu.
+func (ts *typeSystem) argumentConverter(dataType *ua.NodeID) (func(interface{}) (interface{}, error), reflect.Type, error) {
+	builtin := uint32(0)
+	if _, ok := builtinReader(dataType); ok {
+		builtin = dataType.IntID()
+		if base, ok := builtinSubtypes[builtin]; ok {
+			builtin = base
+		}
+	} else if def := ts.dataTypes[dataType.String()]; def != nil && def.enum != nil {
+		builtin = id.Enumeration
+	}
+
+	integer := func(min, max float64, typed func(float64) interface{}) func(interface{}) (interface{}, error) {
+		return func(v interface{}) (interface{}, error) {
+			f, ok := toFloat64(v)
+			if !ok || f != math.Trunc(f) {
+				return nil, fmt.Errorf("expected an integer, got %v", v)
+			}
+			if f < min || f > max {
+				return nil, fmt.Errorf("%v out of range [%v, %v]", v, min, max)
+			}
+			return typed(f), nil
+		}
+	}
+
+	switch builtin {
+	case id.Boolean:
+		return func(v interface{}) (interface{}, error) {
+			b, ok := v.(bool)
+			if !ok {
+				return nil, fmt.Errorf("expected a boolean, got %T", v)
+			}
+			return b, nil
+		}, reflect.TypeOf(false), nil
+	case id.SByte:
+		return integer(math.MinInt8, math.MaxInt8, func(f float64) interface{} { return int8(f) }), reflect.TypeOf(int8(0)), nil
+	case id.Byte:
+		return integer(0, math.MaxUint8, func(f float64) interface{} { return uint8(f) }), reflect.TypeOf(uint8(0)), nil
+	case id.Int16:
+		return integer(math.MinInt16, math.MaxInt16, func(f float64) interface{} { return int16(f) }), reflect.TypeOf(int16(0)), nil
+	case id.UInt16:
+		return integer(0, math.MaxUint16, func(f float64) interface{} { return uint16(f) }), reflect.TypeOf(uint16(0)), nil
+	case id.Int32, id.Enumeration:
+		return integer(math.MinInt32, math.MaxInt32, func(f float64) interface{} { return int32(f) }), reflect.TypeOf(int32(0)), nil
+	case id.UInt32:
+		return integer(0, math.MaxUint32, func(f float64) interface{} { return uint32(f) }), reflect.TypeOf(uint32(0)), nil
+	case id.Int64:
+		return integer(math.MinInt64, math.Nextafter(math.MaxInt64, 0), func(f float64) interface{} { return int64(f) }), reflect.TypeOf(int64(0)), nil
+	case id.UInt64:
+		return integer(0, math.Nextafter(math.MaxUint64, 0), func(f float64) interface{} { return uint64(f) }), reflect.TypeOf(uint64(0)), nil
+	case id.Float:
+		return func(v interface{}) (interface{}, error) {
+			f, ok := toFloat64(v)
+			if !ok {
+				return nil, fmt.Errorf("expected a number, got %T", v)
+			}
+			return float32(f), nil
+		}, reflect.TypeOf(float32(0)), nil
+	case id.Double:
+		return func(v interface{}) (interface{}, error) {
+			f, ok := toFloat64(v)
+			if !ok {
+				return nil, fmt.Errorf("expected a number, got %T", v)
+			}
+			return f, nil
+		}, reflect.TypeOf(float64(0)), nil
+	case id.String:
+		return func(v interface{}) (interface{}, error) {
+			s, ok := v.(string)
+			if !ok {
+				return nil, fmt.Errorf("expected a string, got %T", v)
+			}
+			return s, nil
+		}, reflect.TypeOf(""), nil
+	case id.DateTime:
+		return func(v interface{}) (interface{}, error) {
+			s, ok := v.(string)
+			if !ok {
+				return nil, fmt.Errorf("expected an RFC 3339 time, got %T", v)
+			}
+			return time.Parse(time.RFC3339Nano, s)
+		}, reflect.TypeOf(time.Time{}), nil
+	case id.ByteString:
+		return func(v interface{}) (interface{}, error) {
+			s, ok := v.(string)
+			if !ok {
+				return nil, fmt.Errorf("expected base64 bytes, got %T", v)
+			}
+			return base64.StdEncoding.DecodeString(s)
+		}, reflect.TypeOf([]byte(nil)), nil
+	case id.NodeID:
+		return func(v interface{}) (interface{}, error) {
+			s, ok := v.(string)
+			if !ok {
+				return nil, fmt.Errorf("expected a node ID, got %T", v)
+			}
+			return ua.ParseNodeID(s)
+		}, reflect.TypeOf((*ua.NodeID)(nil)), nil
+	case id.LocalizedText:
+		return func(v interface{}) (interface{}, error) {
+			s, ok := v.(string)
+			if !ok {
+				return nil, fmt.Errorf("expected a string, got %T", v)
+			}
+			return ua.NewLocalizedText(s), nil
+		}, reflect.TypeOf((*ua.LocalizedText)(nil)), nil
+	}
+	return nil, nil, fmt.Errorf("unsupported argument data type %s", dataType)
+}
+
+// callWithSignature calls a method with validated inputs and returns its
+// named output arguments.
+func (c *Client) callWithSignature(ctx context.Context, objectID, methodID *ua.NodeID, sig *methodSignature, inputs []*ua.Variant) ([]domain.MethodArgument, error) {
+	result, err := c.callMethod(ctx, &ua.CallMethodRequest{
+		ObjectID:       objectID,
+		MethodID:       methodID,
+		InputArguments: inputs,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return sig.outputArguments(result.OutputArguments, c.typeCache()), nil
+}
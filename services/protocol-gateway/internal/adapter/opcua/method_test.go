@@ -0,0 +1,72 @@
+package opcua
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/gopcua/opcua/id"
+	"github.com/gopcua/opcua/ua"
+	"github.com/nexus-edge/protocol-gateway/internal/domain"
+)
+
+func TestMethodSignatureInputVariants(t *testing.T) {
+	ts := newTypeSystem()
+	modeType := ua.NewNumericNodeID(2, 3001)
+	ts.dataTypes[modeType.String()] = &dataTypeDefinition{enum: map[int64]string{0: "Manual", 1: "Auto"}}
+
+	sig := &methodSignature{inputs: []*ua.Argument{
+		{Name: "BatchID", DataType: ua.NewNumericNodeID(0, id.String), ValueRank: -1},
+		{Name: "Quantity", DataType: ua.NewNumericNodeID(0, id.UInt16), ValueRank: -1},
+		{Name: "Setpoints", DataType: ua.NewNumericNodeID(0, id.Double), ValueRank: 1},
+		{Name: "Mode", DataType: modeType, ValueRank: -1},
+	}}
+
+	variants, err := sig.inputVariants([]interface{}{"B-1042", 500.0, []interface{}{1.5, 2.0}, 1.0}, ts)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := []interface{}{"B-1042", uint16(500), []float64{1.5, 2.0}, int32(1)}
+	for i, v := range variants {
+		if !reflect.DeepEqual(v.Value(), want[i]) {
+			t.Errorf("input %d: got %#v (%T), want %#v", i, v.Value(), v.Value(), want[i])
+		}
+	}
+
+	invalid := []struct {
+		name   string
+		inputs []interface{}
+	}{
+		{"count", []interface{}{"B-1042"}},
+		{"out of range", []interface{}{"B-1042", 70000.0, []interface{}{}, 0.0}},
+		{"fraction", []interface{}{"B-1042", 1.5, []interface{}{}, 0.0}},
+		{"scalar for array", []interface{}{"B-1042", 1.0, 2.0, 0.0}},
+		{"wrong type", []interface{}{42.0, 1.0, []interface{}{}, 0.0}},
+	}
+	for _, tc := range invalid {
+		t.Run(tc.name, func(t *testing.T) {
+			if _, err := sig.inputVariants(tc.inputs, ts); !errors.Is(err, domain.ErrOPCUAInvalidArgument) {
+				t.Errorf("expected ErrOPCUAInvalidArgument, got %v", err)
+			}
+		})
+	}
+}
+
+func TestMethodSignatureOutputArguments(t *testing.T) {
+	sig := &methodSignature{outputs: []*ua.Argument{
+		{Name: "Status", DataType: ua.NewNumericNodeID(0, id.LocalizedText), ValueRank: -1},
+	}}
+	outputs := []*ua.Variant{
+		ua.MustVariant(ua.NewLocalizedText("Started")),
+		ua.MustVariant(int32(7)),
+	}
+
+	got := sig.outputArguments(outputs, newTypeSystem())
+	want := []domain.MethodArgument{
+		{Name: "Status", Value: map[string]interface{}{"text": "Started"}},
+		{Value: int32(7)},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %#v, want %#v", got, want)
+	}
+}
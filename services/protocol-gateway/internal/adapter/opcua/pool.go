@@ -22,6 +22,8 @@ package opcua
 
 import (
 	"context"
+	"encoding/hex"
+	"errors"
 	"fmt"
 	"math/rand/v2"
 	"runtime"
@@ -73,6 +75,9 @@ type ConnectionPool struct {
 	// Trust store for server certificate validation
 	trustStore *TrustStore
 	autoTrust  bool
+
+	// Alarm handler installed on every session's subscription manager
+	alarmHandler AlarmHandler
 }
 
 // PoolConfig holds configuration for the connection pool.
@@ -136,6 +141,10 @@ type PoolConfig struct {
 	// MaxTTL is the maximum lifetime for a session, even if active.
 	// Zero means no limit. Forces periodic reconnection for long-running sessions.
 	MaxTTL time.Duration
+
+	// History Backfill (devices with OPCHistoryBackfill)
+	BackfillMaxWindow time.Duration // Longest gap read from history; older values are lost
+	BackfillMaxValues int           // Max values read per tag and gap
 }
 
 // DefaultPoolConfig returns sensible defaults for industrial-scale deployments.
@@ -163,6 +172,8 @@ func DefaultPoolConfig() PoolConfig {
 		MaxQueuedPerEndpoint:      200,              // Per-endpoint queue limit
 		StartupJitterMax:          5 * time.Second,  // Spread reconnections over 5s
 		WarmupRampDuration:        30 * time.Second, // Ramp to full capacity over 30s
+		BackfillMaxWindow:         1 * time.Hour,
+		BackfillMaxValues:         10000,
 	}
 }
 
@@ -202,6 +213,12 @@ func NewConnectionPool(config PoolConfig, logger zerolog.Logger, metricsReg *met
 	if config.MaxInFlightPerEndpoint == 0 {
 		config.MaxInFlightPerEndpoint = config.MaxGlobalInFlight / 10
 	}
+	if config.BackfillMaxWindow == 0 {
+		config.BackfillMaxWindow = 1 * time.Hour
+	}
+	if config.BackfillMaxValues == 0 {
+		config.BackfillMaxValues = 10000
+	}
 
 	// === COLD-START STORM PROTECTION ===
 	// When Kubernetes restarts all pods, they reconnect simultaneously → PLC denial of service
@@ -261,6 +278,12 @@ func (p *ConnectionPool) SetTrustStore(ts *TrustStore, autoTrust bool) {
 		Msg("Trust store configured for OPC UA connection pool")
 }
 
+// SetAlarmHandler sets the callback for alarm events of devices with
+// opc_alarms_enabled. Must be called before devices are subscribed.
+func (p *ConnectionPool) SetAlarmHandler(handler AlarmHandler) {
+	p.alarmHandler = handler
+}
+
 // =============================================================================
 // Client Management
 // =============================================================================
@@ -607,6 +630,33 @@ func (p *ConnectionPool) executeWithTwoTierBreaker(
 	return result, nil
 }
 
+// executeCommand executes an operator command (alarm acknowledgement, method
+// call) through both breakers. A command the server rejects, e.g. acknowledging
+// an acknowledged condition, is returned to the caller but counts as a
+// success: only failures to reach the server count against the breakers.
+func (p *ConnectionPool) executeCommand(
+	session *pooledSession,
+	binding *DeviceBinding,
+	fn func() (interface{}, error),
+) (interface{}, error) {
+	var rejected error
+	result, err := p.executeWithTwoTierBreaker(session, binding, func() (interface{}, error) {
+		result, err := fn()
+		if errors.Is(err, domain.ErrOPCUABadStatus) {
+			rejected = err
+			return nil, nil
+		}
+		return result, err
+	})
+	if err != nil {
+		return nil, err
+	}
+	if rejected != nil {
+		return nil, rejected
+	}
+	return result, nil
+}
+
 func (p *ConnectionPool) recoverSubscriptions(session *pooledSession) {
 	session.subscriptionState.mu.Lock()
 	defer session.subscriptionState.mu.Unlock()
@@ -623,6 +673,10 @@ func (p *ConnectionPool) recoverSubscriptions(session *pooledSession) {
 			Int("monitored_items", len(session.subscriptionState.MonitoredItems)).
 			Msg("Rebinding monitored items after reconnect")
 	}
+
+	// Recreate the device subscriptions on the new connection and backfill
+	// the values missed in between from the server's history
+	p.backfillSession(session)
 }
 
 // =============================================================================
@@ -653,6 +707,7 @@ func (p *ConnectionPool) SubscribeDevice(ctx context.Context, device *domain.Dev
 		if err != nil {
 			return fmt.Errorf("failed to create subscription manager: %w", err)
 		}
+		mgr.SetAlarmHandler(p.alarmHandler)
 		if err := mgr.Start(); err != nil {
 			return fmt.Errorf("failed to start subscription manager: %w", err)
 		}
@@ -741,11 +796,17 @@ func (p *ConnectionPool) ReadTags(ctx context.Context, device *domain.Device, ta
 		}
 	}
 
+	decoding := valueDecodingFor(device)
 	var result []*domain.DataPoint
 	err = p.checkGlobalLoadAndQueueWithSession(ctx, priority, session, func() error {
 		res, err := p.executeWithTwoTierBreaker(session, binding, func() (interface{}, error) {
+			if decoding == DecodeTyped {
+				for i := 0; i < len(tags); i += p.config.MaxNodesPerRead {
+					client.resolveTagTypes(ctx, tags[i:min(i+p.config.MaxNodesPerRead, len(tags))])
+				}
+			}
 			if len(tags) <= p.config.MaxNodesPerRead {
-				return client.ReadTags(ctx, tags)
+				return client.ReadTags(ctx, tags, decoding)
 			}
 
 			// Batch to respect server limits
@@ -755,7 +816,7 @@ func (p *ConnectionPool) ReadTags(ctx context.Context, device *domain.Device, ta
 				if end > len(tags) {
 					end = len(tags)
 				}
-				batchResults, err := client.ReadTags(ctx, tags[i:end])
+				batchResults, err := client.ReadTags(ctx, tags[i:end], decoding)
 				if err != nil {
 					return nil, err
 				}
@@ -791,10 +852,14 @@ func (p *ConnectionPool) ReadTag(ctx context.Context, device *domain.Device, tag
 		return nil, domain.ErrDeviceNotFound
 	}
 
+	decoding := valueDecodingFor(device)
 	var result *domain.DataPoint
 	err = p.checkGlobalLoadAndQueueWithSession(ctx, int(tag.Priority), session, func() error {
 		res, err := p.executeWithTwoTierBreaker(session, binding, func() (interface{}, error) {
-			return client.ReadTag(ctx, tag)
+			if decoding == DecodeTyped {
+				client.resolveTagTypes(ctx, []*domain.Tag{tag})
+			}
+			return client.ReadTag(ctx, tag, decoding)
 		})
 		if err != nil {
 			return err
@@ -837,6 +902,122 @@ func (p *ConnectionPool) WriteTag(ctx context.Context, device *domain.Device, ta
 	})
 }
 
+// AcknowledgeAlarm acknowledges or confirms an alarm condition of a device.
+// An empty eventID refers to the latest event received for the condition.
+// Implements domain.AlarmAcknowledger.
+func (p *ConnectionPool) AcknowledgeAlarm(ctx context.Context, device *domain.Device, conditionID, eventID string, action domain.AlarmAction, comment string) error {
+	if !action.IsValid() {
+		return fmt.Errorf("%w: unsupported alarm action %q", domain.ErrInvalidWriteValue, action)
+	}
+	if conditionID == "" {
+		return fmt.Errorf("%w: condition_id is required", domain.ErrInvalidWriteValue)
+	}
+
+	client, err := p.GetClient(ctx, device)
+	if err != nil {
+		return err
+	}
+
+	session, exists := p.getSessionForDevice(device.ID)
+	if !exists {
+		return domain.ErrDeviceNotFound
+	}
+
+	binding, exists := p.getDeviceBinding(device.ID)
+	if !exists {
+		return domain.ErrDeviceNotFound
+	}
+
+	if eventID == "" {
+		session.mu.Lock()
+		mgr := session.subscriptionMgr
+		session.mu.Unlock()
+		if mgr == nil {
+			return fmt.Errorf("%w: %s", domain.ErrOPCUAConditionNotFound, conditionID)
+		}
+		event, found := mgr.lastAlarmEvent(device.ID, conditionID)
+		if !found {
+			return fmt.Errorf("%w: %s", domain.ErrOPCUAConditionNotFound, conditionID)
+		}
+		eventID = event.EventID
+	}
+
+	eventIDBytes, err := hex.DecodeString(eventID)
+	if err != nil {
+		return fmt.Errorf("%w: event_id must be hex: %v", domain.ErrInvalidWriteValue, err)
+	}
+
+	return p.checkGlobalLoadAndQueueWithSession(ctx, PriorityControl, session, func() error {
+		_, err := p.executeCommand(session, binding, func() (interface{}, error) {
+			return nil, client.acknowledgeCondition(ctx, conditionID, eventIDBytes, action, comment)
+		})
+		return err
+	})
+}
+
+// CallMethod calls an OPC UA method of an object on the device. The method's
+// InputArguments are read from the server (once per session) and the inputs
+// are validated and converted to the declared types before the call.
+// Uses two-tier circuit breakers: endpoint breaker → device breaker; calls the
+// server rejects don't count against them.
+func (p *ConnectionPool) CallMethod(ctx context.Context, device *domain.Device, objectID, methodID string, inputs []interface{}) ([]domain.MethodArgument, error) {
+	if objectID == "" || methodID == "" {
+		return nil, fmt.Errorf("%w: object_id and method_id are required", domain.ErrOPCUAInvalidArgument)
+	}
+
+	client, err := p.GetClient(ctx, device)
+	if err != nil {
+		return nil, err
+	}
+
+	session, exists := p.getSessionForDevice(device.ID)
+	if !exists {
+		return nil, domain.ErrDeviceNotFound
+	}
+
+	binding, exists := p.getDeviceBinding(device.ID)
+	if !exists {
+		return nil, domain.ErrDeviceNotFound
+	}
+
+	objectNodeID, err := client.getNodeID(objectID)
+	if err != nil {
+		return nil, err
+	}
+	methodNodeID, err := client.getNodeID(methodID)
+	if err != nil {
+		return nil, err
+	}
+
+	var outputs []domain.MethodArgument
+	err = p.checkGlobalLoadAndQueueWithSession(ctx, PriorityControl, session, func() error {
+		res, err := p.executeCommand(session, binding, func() (interface{}, error) {
+			return client.methodSignature(ctx, methodNodeID)
+		})
+		if err != nil {
+			return err
+		}
+		sig := res.(*methodSignature)
+
+		// Invalid inputs are rejected before the call and don't count
+		// against the circuit breakers.
+		variants, err := sig.inputVariants(inputs, client.typeCache())
+		if err != nil {
+			return err
+		}
+
+		res, err = p.executeCommand(session, binding, func() (interface{}, error) {
+			return client.callWithSignature(ctx, objectNodeID, methodNodeID, sig, variants)
+		})
+		if err != nil {
+			return err
+		}
+		outputs = res.([]domain.MethodArgument)
+		return nil
+	})
+	return outputs, err
+}
+
 // WriteTags writes multiple values to tags on the device.
 // Uses two-tier circuit breakers: endpoint breaker → device breaker.
 func (p *ConnectionPool) WriteTags(ctx context.Context, device *domain.Device, writes []TagWrite) []error {
@@ -24,6 +24,7 @@ type SubscriptionManager struct {
 	mu              sync.RWMutex
 	logger          zerolog.Logger
 	dataHandler     DataHandler
+	alarmHandler    AlarmHandler
 	publishInterval time.Duration
 	queueSize       uint32
 	running         atomic.Bool
@@ -39,13 +40,16 @@ type Subscription struct {
 	Tags            map[string]*domain.Tag
 	TagList         []*domain.Tag     // Ordered list for client handle lookup
 	MonitoredItems  map[string]uint32 // tag ID -> monitored item ID
+	AlarmItems      int               // Event monitored items (OPCAlarmsEnabled)
 	LastValues      map[string]*domain.DataPoint
+	Alarms          map[string]*domain.AlarmEvent // condition ID -> latest alarm event
 	opcuaSub        *opcua.Subscription
 	notifyCh        chan *opcua.PublishNotificationData
 	doneCh          chan struct{}  // Signals notification handler to stop
 	wg              sync.WaitGroup // Waits for notification handler to exit
 	mu              sync.RWMutex
 	publishInterval time.Duration
+	config          SubscriptionConfig // For re-creation after a reconnect
 	active          atomic.Bool
 }
 
@@ -103,6 +107,12 @@ func NewSubscriptionManager(client *Client, handler DataHandler, logger zerolog.
 	return sm, nil
 }
 
+// SetAlarmHandler sets the handler for alarm events of devices with
+// OPCAlarmsEnabled. Must be called before devices are subscribed.
+func (sm *SubscriptionManager) SetAlarmHandler(handler AlarmHandler) {
+	sm.alarmHandler = handler
+}
+
 // Start starts the subscription manager.
 func (sm *SubscriptionManager) Start() error {
 	if sm.running.Load() {
@@ -158,21 +168,7 @@ func (sm *SubscriptionManager) Subscribe(device *domain.Device, tags []*domain.T
 	}
 
 	// Create new subscription
-	sub := &Subscription{
-		Device:          device,
-		Tags:            make(map[string]*domain.Tag),
-		TagList:         make([]*domain.Tag, 0, len(tags)),
-		MonitoredItems:  make(map[string]uint32),
-		LastValues:      make(map[string]*domain.DataPoint),
-		notifyCh:        make(chan *opcua.PublishNotificationData, 100),
-		doneCh:          make(chan struct{}),
-		publishInterval: config.PublishInterval,
-	}
-
-	for _, tag := range tags {
-		sub.Tags[tag.ID] = tag
-		sub.TagList = append(sub.TagList, tag)
-	}
+	sub := newSubscription(device, tags, config)
 
 	sm.subscriptions[device.ID] = sub
 
@@ -192,6 +188,28 @@ func (sm *SubscriptionManager) Subscribe(device *domain.Device, tags []*domain.T
 	return nil
 }
 
+// newSubscription creates the bookkeeping of a device subscription.
+func newSubscription(device *domain.Device, tags []*domain.Tag, config SubscriptionConfig) *Subscription {
+	sub := &Subscription{
+		Device:          device,
+		Tags:            make(map[string]*domain.Tag),
+		TagList:         make([]*domain.Tag, 0, len(tags)),
+		MonitoredItems:  make(map[string]uint32),
+		LastValues:      make(map[string]*domain.DataPoint),
+		Alarms:          make(map[string]*domain.AlarmEvent),
+		notifyCh:        make(chan *opcua.PublishNotificationData, 100),
+		doneCh:          make(chan struct{}),
+		publishInterval: config.PublishInterval,
+		config:          config,
+	}
+
+	for _, tag := range tags {
+		sub.Tags[tag.ID] = tag
+		sub.TagList = append(sub.TagList, tag)
+	}
+	return sub
+}
+
 // Unsubscribe removes a subscription for a device.
 func (sm *SubscriptionManager) Unsubscribe(deviceID string) error {
 	sm.mu.Lock()
@@ -253,6 +271,11 @@ func (sm *SubscriptionManager) createOPCSubscription(sub *Subscription, config S
 		return domain.ErrConnectionClosed
 	}
 
+	// Resolve data types before the initial notifications arrive
+	if valueDecodingFor(sub.Device) == DecodeTyped {
+		sm.client.resolveTagTypes(sm.ctx, sub.TagList)
+	}
+
 	// Create subscription parameters
 	params := &opcua.SubscriptionParameters{
 		Interval: config.PublishInterval,
@@ -267,11 +290,11 @@ func (sm *SubscriptionManager) createOPCSubscription(sub *Subscription, config S
 	sub.opcuaSub = opcuaSub
 	sub.ID = opcuaSub.SubscriptionID
 
-	// Build monitored item requests using ua.MonitoredItemCreateRequest
+	// Build monitored item requests using ua.MonitoredItemCreateRequest.
+	// The client handle of a data item is its tag's index in TagList.
 	monitoredItemRequests := make([]*ua.MonitoredItemCreateRequest, 0, len(sub.Tags))
 
-	clientHandle := uint32(0)
-	for _, tag := range sub.TagList {
+	for i, tag := range sub.TagList {
 		nodeID, err := sm.client.getNodeID(tag.OPCNodeID)
 		if err != nil {
 			sm.logger.Warn().
@@ -291,7 +314,7 @@ func (sm *SubscriptionManager) createOPCSubscription(sub *Subscription, config S
 			},
 			MonitoringMode: ua.MonitoringModeReporting,
 			RequestedParameters: &ua.MonitoringParameters{
-				ClientHandle:     clientHandle,
+				ClientHandle:     uint32(i),
 				SamplingInterval: float64(config.SamplingInterval.Milliseconds()),
 				QueueSize:        config.QueueSize,
 				DiscardOldest:    config.DiscardOldest,
@@ -304,7 +327,11 @@ func (sm *SubscriptionManager) createOPCSubscription(sub *Subscription, config S
 		}
 
 		monitoredItemRequests = append(monitoredItemRequests, req)
-		clientHandle++
+	}
+
+	// Alarms & Conditions: event items on the device's notifier nodes
+	if sub.Device.Connection.OPCAlarmsEnabled {
+		monitoredItemRequests = append(monitoredItemRequests, sm.alarmItemRequests(sub.Device)...)
 	}
 
 	if len(monitoredItemRequests) == 0 {
@@ -320,24 +347,38 @@ func (sm *SubscriptionManager) createOPCSubscription(sub *Subscription, config S
 		return fmt.Errorf("%w: failed to create monitored items: %v", domain.ErrOPCUASubscriptionFailed, err)
 	}
 
-	// Map monitored items to tags
+	// Map monitored items to tags (results are in request order)
 	// res is *ua.CreateMonitoredItemsResponse, Results is the slice
 	if res != nil && res.Results != nil {
 		for i, result := range res.Results {
-			if i >= len(sub.TagList) {
+			if i >= len(monitoredItemRequests) {
 				break
 			}
+			request := monitoredItemRequests[i]
+			if handle := request.RequestedParameters.ClientHandle; handle >= alarmClientHandleBase {
+				if result.StatusCode == ua.StatusOK {
+					sub.AlarmItems++
+				} else {
+					sm.logger.Warn().
+						Str("device_id", sub.Device.ID).
+						Str("notifier", request.ItemToMonitor.NodeID.String()).
+						Uint32("status", uint32(result.StatusCode)).
+						Msg("Failed to create alarm event monitored item")
+				}
+				continue
+			}
+			tag := sub.TagList[request.RequestedParameters.ClientHandle]
 			if result.StatusCode == ua.StatusOK {
 				sub.mu.Lock()
-				sub.MonitoredItems[sub.TagList[i].ID] = result.MonitoredItemID
+				sub.MonitoredItems[tag.ID] = result.MonitoredItemID
 				sub.mu.Unlock()
 				sm.logger.Debug().
-					Str("tag_id", sub.TagList[i].ID).
+					Str("tag_id", tag.ID).
 					Uint32("monitored_item_id", result.MonitoredItemID).
 					Msg("Created monitored item")
 			} else {
 				sm.logger.Warn().
-					Str("tag_id", sub.TagList[i].ID).
+					Str("tag_id", tag.ID).
 					Uint32("status", uint32(result.StatusCode)).
 					Msg("Failed to create monitored item")
 			}
@@ -350,10 +391,22 @@ func (sm *SubscriptionManager) createOPCSubscription(sub *Subscription, config S
 	sub.wg.Add(1)
 	go sm.handleNotifications(sub)
 
+	// Active alarms raised before the subscription existed are only reported
+	// after a ConditionRefresh
+	if sub.AlarmItems > 0 {
+		if err := sm.refreshConditions(sm.ctx, sub.ID); err != nil {
+			sm.logger.Warn().
+				Err(err).
+				Str("device_id", sub.Device.ID).
+				Msg("ConditionRefresh failed, only new alarm transitions will be reported")
+		}
+	}
+
 	sm.logger.Info().
 		Str("device_id", sub.Device.ID).
 		Uint32("subscription_id", sub.ID).
 		Int("monitored_items", len(sub.MonitoredItems)).
+		Int("alarm_items", sub.AlarmItems).
 		Msg("OPC UA subscription created")
 
 	return nil
@@ -410,8 +463,9 @@ func (sm *SubscriptionManager) processNotification(sub *Subscription, notif *opc
 			sm.processDataChange(sub, item)
 		}
 	case *ua.EventNotificationList:
-		// Handle events if needed in the future
-		sm.logger.Debug().Msg("Received event notification (not processed)")
+		for _, event := range n.Events {
+			sm.processEvent(sub, event)
+		}
 	}
 }
 
@@ -434,26 +488,11 @@ func (sm *SubscriptionManager) processDataChange(sub *Subscription, item *ua.Mon
 		return
 	}
 
-	// Convert to data point
-	dp := sm.client.processReadResult(item.Value, tag)
-	suffix := strings.TrimSpace(tag.TopicSuffix)
-	if suffix == "" {
-		suffix = tag.Name
-	}
-	if strings.TrimSpace(suffix) == "" {
-		suffix = tag.ID
-	}
-	suffix = strings.TrimSpace(suffix)
-	suffix = strings.ReplaceAll(suffix, "/", "_")
-	suffix = strings.ReplaceAll(suffix, "#", "_")
-	suffix = strings.ReplaceAll(suffix, "+", "_")
-	suffix = strings.ReplaceAll(suffix, " ", "_")
-	suffix = strings.Trim(suffix, "_")
-	if suffix == "" {
-		dp.Topic = sub.Device.UNSPrefix
-	} else {
-		dp.Topic = sub.Device.UNSPrefix + "/" + suffix
-	}
+	// Convert to data point. The session's client may belong to another
+	// device on the same endpoint, so the device ID is set from the subscription.
+	dp := sm.client.processReadResult(item.Value, tag, valueDecodingFor(sub.Device))
+	dp.DeviceID = sub.Device.ID
+	dp.Topic = tagTopic(sub.Device.UNSPrefix, tag)
 
 	// Update last value
 	sub.mu.Lock()
@@ -473,6 +512,58 @@ func (sm *SubscriptionManager) processDataChange(sub *Subscription, item *ua.Mon
 		Msg("Processed data change notification")
 }
 
+// tagTopic returns the UNS topic of a tag: {uns_prefix}/{topic_suffix, name or ID}.
+func tagTopic(unsPrefix string, tag *domain.Tag) string {
+	suffix := strings.TrimSpace(tag.TopicSuffix)
+	if suffix == "" {
+		suffix = tag.Name
+	}
+	if strings.TrimSpace(suffix) == "" {
+		suffix = tag.ID
+	}
+	suffix = topicSegment(suffix)
+	if suffix == "" {
+		return unsPrefix
+	}
+	return unsPrefix + "/" + suffix
+}
+
+// topicSegment makes a string safe for use as a single MQTT topic level.
+func topicSegment(s string) string {
+	s = strings.TrimSpace(s)
+	s = strings.ReplaceAll(s, "/", "_")
+	s = strings.ReplaceAll(s, "#", "_")
+	s = strings.ReplaceAll(s, "+", "_")
+	s = strings.ReplaceAll(s, " ", "_")
+	return strings.Trim(s, "_")
+}
+
+// resubscribeLocked recreates a subscription on the client's current
+// connection after a reconnect replaced the one it was created on (must hold
+// lock). The server-side subscription died with the old session; cached last
+// values and alarms are kept.
+func (sm *SubscriptionManager) resubscribeLocked(sub *Subscription) error {
+	sub.active.Store(false)
+	close(sub.doneCh)
+	sub.wg.Wait()
+
+	// The old notification channel is abandoned rather than closed: the old
+	// connection's publish loop may still hold it.
+	sub.notifyCh = make(chan *opcua.PublishNotificationData, 100)
+	sub.doneCh = make(chan struct{})
+	sub.opcuaSub = nil
+	sub.AlarmItems = 0
+	sub.mu.Lock()
+	sub.MonitoredItems = make(map[string]uint32)
+	sub.mu.Unlock()
+
+	if err := sm.createOPCSubscription(sub, sub.config); err != nil {
+		return err
+	}
+	sub.active.Store(true)
+	return nil
+}
+
 // updateSubscriptionLocked updates an existing subscription with new tags (must hold lock).
 func (sm *SubscriptionManager) updateSubscriptionLocked(device *domain.Device, tags []*domain.Tag, config SubscriptionConfig) error {
 	// For simplicity, recreate the subscription
@@ -482,21 +573,7 @@ func (sm *SubscriptionManager) updateSubscriptionLocked(device *domain.Device, t
 	}
 
 	// Re-add to subscriptions map and create new subscription
-	sub := &Subscription{
-		Device:          device,
-		Tags:            make(map[string]*domain.Tag),
-		TagList:         make([]*domain.Tag, 0, len(tags)),
-		MonitoredItems:  make(map[string]uint32),
-		LastValues:      make(map[string]*domain.DataPoint),
-		notifyCh:        make(chan *opcua.PublishNotificationData, 100),
-		doneCh:          make(chan struct{}),
-		publishInterval: config.PublishInterval,
-	}
-
-	for _, tag := range tags {
-		sub.Tags[tag.ID] = tag
-		sub.TagList = append(sub.TagList, tag)
-	}
+	sub := newSubscription(device, tags, config)
 
 	sm.subscriptions[device.ID] = sub
 
@@ -585,7 +662,7 @@ func (sm *SubscriptionManager) Stats() SubscriptionStats {
 
 	for _, sub := range sm.subscriptions {
 		sub.mu.RLock()
-		stats.TotalMonitoredItems += len(sub.MonitoredItems)
+		stats.TotalMonitoredItems += len(sub.MonitoredItems) + sub.AlarmItems
 		if sub.active.Load() {
 			stats.ActiveSubscriptions++
 		}
@@ -45,6 +45,7 @@ type Client struct {
 	deviceID     string
 	nodeCache    map[string]*ua.NodeID // Cache parsed node IDs
 	nodeCacheMu  sync.RWMutex
+	types        *typeSystem // Data types for typed value decoding (OPCPreserveTypes)
 
 	// Namespace URI to index mapping (fetched from server on connect)
 	// Key: namespace URI, Value: namespace index
@@ -221,8 +222,8 @@ type OPCUAClient interface {
 	Connect(ctx context.Context) error
 	Disconnect() error
 	IsConnected() bool
-	ReadTag(ctx context.Context, tag *domain.Tag) (*domain.DataPoint, error)
-	ReadTags(ctx context.Context, tags []*domain.Tag) ([]*domain.DataPoint, error)
+	ReadTag(ctx context.Context, tag *domain.Tag, decoding ValueDecoding) (*domain.DataPoint, error)
+	ReadTags(ctx context.Context, tags []*domain.Tag, decoding ValueDecoding) ([]*domain.DataPoint, error)
 	WriteTag(ctx context.Context, tag *domain.Tag, value interface{}) error
 	WriteTags(ctx context.Context, writes []TagWrite) []error
 	Browse(ctx context.Context, nodeID string, maxDepth int) (*BrowseResult, error)
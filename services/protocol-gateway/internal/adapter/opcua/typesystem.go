@@ -0,0 +1,683 @@
+// Package opcua provides type-aware decoding of OPC UA values: arrays,
+// structures (driven by the server's DataTypeDefinition), enums and LocalizedText.
+package opcua
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"sync"
+	"time"
+
+	"github.com/gopcua/opcua/id"
+	"github.com/gopcua/opcua/ua"
+	"github.com/nexus-edge/protocol-gateway/internal/domain"
+)
+
+// ValueDecoding selects how variant values are converted to data point values.
+type ValueDecoding int
+
+const (
+	// DecodeScalar returns values as decoded by the OPC UA stack (legacy output).
+	DecodeScalar ValueDecoding = iota
+
+	// DecodeTyped converts arrays, structures, enums and LocalizedText to JSON
+	// arrays and objects (OPCPreserveTypes).
+	DecodeTyped
+)
+
+// valueDecodingFor returns the value decoding configured for a device.
+func valueDecodingFor(device *domain.Device) ValueDecoding {
+	if device.Connection.OPCPreserveTypes {
+		return DecodeTyped
+	}
+	return DecodeScalar
+}
+
+// maxTypeDepth limits the nesting of structure definitions, both when
+// resolving them and when decoding values.
+const maxTypeDepth = 16
+
+// dataTypeDefinition is the resolved definition of a server data type.
+type dataTypeDefinition struct {
+	structure *ua.StructureDefinition
+	enum      map[int64]string
+}
+
+// typeSystem caches the data types of variable nodes, the definitions of the
+// server's custom data types and the signatures of called methods. It is cleared when the client disconnects,
+// as namespace indexes may change across sessions.
+type typeSystem struct {
+	mu        sync.RWMutex
+	nodes     map[string]*ua.NodeID              // Variable node -> DataType
+	dataTypes map[string]*dataTypeDefinition     // DataType -> definition (nil: builtin or undefined)
+	encodings map[string]*ua.StructureDefinition // Binary encoding -> structure
+	methods   map[string]*methodSignature        // Method -> argument definitions
+}
+
+func newTypeSystem() *typeSystem {
+	return &typeSystem{
+		nodes:     make(map[string]*ua.NodeID),
+		dataTypes: make(map[string]*dataTypeDefinition),
+		encodings: make(map[string]*ua.StructureDefinition),
+		methods:   make(map[string]*methodSignature),
+	}
+}
+
+// addDefinition stores the definition of a data type, and of its binary
+// encoding for structures.
+func (ts *typeSystem) addDefinition(dataType *ua.NodeID, def *dataTypeDefinition) {
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+	ts.dataTypes[dataType.String()] = def
+	if def != nil && def.structure != nil && def.structure.DefaultEncodingID != nil {
+		ts.encodings[def.structure.DefaultEncodingID.String()] = def.structure
+	}
+}
+
+// definitionFromValue converts a DataTypeDefinition attribute value.
+func definitionFromValue(v interface{}) *dataTypeDefinition {
+	eo, ok := v.(*ua.ExtensionObject)
+	if !ok || eo == nil {
+		return nil
+	}
+	switch def := eo.Value.(type) {
+	case *ua.StructureDefinition:
+		return &dataTypeDefinition{structure: def}
+	case *ua.EnumDefinition:
+		names := make(map[int64]string, len(def.Fields))
+		for _, field := range def.Fields {
+			if field == nil {
+				continue
+			}
+			name := field.Name
+			if name == "" && field.DisplayName != nil {
+				name = field.DisplayName.Text
+			}
+			names[field.Value] = name
+		}
+		return &dataTypeDefinition{enum: names}
+	}
+	return nil
+}
+
+// value converts a variant value of the given variable node.
+func (ts *typeSystem) value(nodeID string, v interface{}) interface{} {
+	ts.mu.RLock()
+	defer ts.mu.RUnlock()
+
+	if dataType, ok := ts.nodes[nodeID]; ok {
+		if def := ts.dataTypes[dataType.String()]; def != nil && def.enum != nil {
+			return enumValue(v, def.enum)
+		}
+	}
+	return ts.convert(v, 0)
+}
+
+// convert returns v as JSON-friendly values: slices as arrays, structures as
+// objects and identifiers as strings. Callers hold ts.mu.
+func (ts *typeSystem) convert(v interface{}, depth int) interface{} {
+	switch x := v.(type) {
+	case nil:
+		return nil
+	case []byte, time.Time, string, bool:
+		return x
+	case *ua.Variant:
+		if x == nil {
+			return nil
+		}
+		return ts.convert(x.Value(), depth)
+	case *ua.DataValue:
+		if x == nil || x.Value == nil {
+			return nil
+		}
+		return ts.convert(x.Value.Value(), depth)
+	case *ua.LocalizedText:
+		if x == nil {
+			return nil
+		}
+		text := map[string]interface{}{"text": x.Text}
+		if x.Locale != "" {
+			text["locale"] = x.Locale
+		}
+		return text
+	case *ua.QualifiedName:
+		if x == nil {
+			return nil
+		}
+		return map[string]interface{}{"namespace": x.NamespaceIndex, "name": x.Name}
+	case *ua.NodeID:
+		if x == nil {
+			return nil
+		}
+		return x.String()
+	case *ua.ExpandedNodeID:
+		if x == nil || x.NodeID == nil {
+			return nil
+		}
+		return x.NodeID.String()
+	case *ua.GUID:
+		if x == nil {
+			return nil
+		}
+		return x.String()
+	case ua.StatusCode:
+		return uint32(x)
+	case ua.XMLElement:
+		return string(x)
+	case *ua.ExtensionObject:
+		return ts.extensionObject(x, depth)
+	}
+
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Slice, reflect.Array:
+		out := make([]interface{}, rv.Len())
+		for i := range out {
+			out[i] = ts.convert(rv.Index(i).Interface(), depth)
+		}
+		return out
+	case reflect.Ptr:
+		if rv.IsNil() {
+			return nil
+		}
+		if rv.Elem().Kind() == reflect.Struct && depth < maxTypeDepth {
+			return ts.structFields(rv.Elem(), depth+1)
+		}
+	}
+	return v
+}
+
+// structFields converts a structure known to the OPC UA stack to an object
+// keyed by field name.
+func (ts *typeSystem) structFields(rv reflect.Value, depth int) map[string]interface{} {
+	out := make(map[string]interface{}, rv.NumField())
+	for i := 0; i < rv.NumField(); i++ {
+		field := rv.Type().Field(i)
+		if !field.IsExported() || field.Name == "EncodingMask" {
+			continue
+		}
+		out[field.Name] = ts.convert(rv.Field(i).Interface(), depth)
+	}
+	return out
+}
+
+// extensionObject converts a structure value. Custom structures are decoded
+// with their DataTypeDefinition; structures without one are returned as
+// {"type_id", "body"} so no data is lost.
+func (ts *typeSystem) extensionObject(eo *ua.ExtensionObject, depth int) interface{} {
+	if eo == nil {
+		return nil
+	}
+	typeID := ""
+	if eo.TypeID != nil && eo.TypeID.NodeID != nil {
+		typeID = eo.TypeID.NodeID.String()
+	}
+
+	switch body := eo.Value.(type) {
+	case nil:
+		if typeID == "" {
+			return nil
+		}
+		return map[string]interface{}{"type_id": typeID}
+	case *rawStructure:
+		if def, ok := ts.encodings[typeID]; ok {
+			buf := ua.NewBuffer(body.body)
+			if fields, err := ts.decodeStructure(buf, def, depth); err == nil {
+				return fields
+			}
+		}
+		return map[string]interface{}{"type_id": typeID, "body": body.body}
+	default:
+		return ts.convert(body, depth)
+	}
+}
+
+// decodeStructure decodes a structure body (Part 6, 5.2.7) field by field.
+func (ts *typeSystem) decodeStructure(buf *ua.Buffer, def *ua.StructureDefinition, depth int) (map[string]interface{}, error) {
+	if depth >= maxTypeDepth {
+		return nil, fmt.Errorf("structure nesting exceeds %d levels", maxTypeDepth)
+	}
+
+	var out map[string]interface{}
+	switch def.StructureType {
+	case ua.StructureTypeStructure, ua.StructureTypeStructureWithOptionalFields:
+		optional := def.StructureType == ua.StructureTypeStructureWithOptionalFields
+		var mask uint32
+		if optional {
+			mask = buf.ReadUint32()
+		}
+		out = make(map[string]interface{}, len(def.Fields))
+		bit := 0
+		for _, field := range def.Fields {
+			if optional && field.IsOptional {
+				present := mask&(1<<bit) != 0
+				bit++
+				if !present {
+					continue
+				}
+			}
+			value, err := ts.decodeField(buf, field, depth)
+			if err != nil {
+				return nil, err
+			}
+			out[field.Name] = value
+		}
+
+	case ua.StructureTypeUnion:
+		// The switch field selects the one field present (1-based, 0 = null)
+		selected := buf.ReadUint32()
+		if selected == 0 {
+			return nil, buf.Error()
+		}
+		if int(selected) > len(def.Fields) {
+			return nil, fmt.Errorf("union switch field %d out of range", selected)
+		}
+		field := def.Fields[selected-1]
+		value, err := ts.decodeField(buf, field, depth)
+		if err != nil {
+			return nil, err
+		}
+		out = map[string]interface{}{field.Name: value}
+
+	default:
+		return nil, fmt.Errorf("unsupported structure type %d", def.StructureType)
+	}
+	return out, buf.Error()
+}
+
+// decodeField decodes a scalar or one-dimensional array structure field.
+func (ts *typeSystem) decodeField(buf *ua.Buffer, field *ua.StructureField, depth int) (interface{}, error) {
+	switch field.ValueRank {
+	case -1:
+		return ts.decodeType(buf, field.DataType, depth)
+	case 1:
+		n := buf.ReadInt32()
+		if n < 0 {
+			return nil, buf.Error()
+		}
+		if int(n) > buf.Len() {
+			return nil, fmt.Errorf("array length %d of field %s exceeds the structure body", n, field.Name)
+		}
+		values := make([]interface{}, n)
+		for i := range values {
+			value, err := ts.decodeType(buf, field.DataType, depth)
+			if err != nil {
+				return nil, err
+			}
+			values[i] = value
+		}
+		return values, buf.Error()
+	default:
+		return nil, fmt.Errorf("unsupported value rank %d of field %s", field.ValueRank, field.Name)
+	}
+}
+
+// decodeType decodes one value of a data type.
+func (ts *typeSystem) decodeType(buf *ua.Buffer, dataType *ua.NodeID, depth int) (interface{}, error) {
+	if dataType == nil {
+		return nil, fmt.Errorf("structure field without data type")
+	}
+	if read, ok := builtinReader(dataType); ok {
+		value := read(buf)
+		return ts.convert(value, depth), buf.Error()
+	}
+
+	def := ts.dataTypes[dataType.String()]
+	switch {
+	case def == nil:
+		return nil, fmt.Errorf("no definition for data type %s", dataType)
+	case def.enum != nil:
+		return enumValue(buf.ReadInt32(), def.enum), buf.Error()
+	default:
+		return ts.decodeStructure(buf, def.structure, depth+1)
+	}
+}
+
+// builtinReader returns the decoder of a builtin data type or of a standard
+// subtype encoded as its builtin base type (e.g., Duration as Double).
+func builtinReader(dataType *ua.NodeID) (func(*ua.Buffer) interface{}, bool) {
+	if dataType.Namespace() != 0 || dataType.Type() == ua.NodeIDTypeString ||
+		dataType.Type() == ua.NodeIDTypeGUID || dataType.Type() == ua.NodeIDTypeByteString {
+		return nil, false
+	}
+
+	builtin := dataType.IntID()
+	if base, ok := builtinSubtypes[builtin]; ok {
+		builtin = base
+	}
+
+	structValue := func(v interface{}) func(*ua.Buffer) interface{} {
+		return func(buf *ua.Buffer) interface{} {
+			value := reflect.New(reflect.TypeOf(v).Elem()).Interface()
+			buf.ReadStruct(value)
+			return value
+		}
+	}
+
+	switch builtin {
+	case id.Boolean:
+		return func(buf *ua.Buffer) interface{} { return buf.ReadBool() }, true
+	case id.SByte:
+		return func(buf *ua.Buffer) interface{} { return buf.ReadInt8() }, true
+	case id.Byte:
+		return func(buf *ua.Buffer) interface{} { return buf.ReadByte() }, true
+	case id.Int16:
+		return func(buf *ua.Buffer) interface{} { return buf.ReadInt16() }, true
+	case id.UInt16:
+		return func(buf *ua.Buffer) interface{} { return buf.ReadUint16() }, true
+	case id.Int32, id.Enumeration:
+		return func(buf *ua.Buffer) interface{} { return buf.ReadInt32() }, true
+	case id.UInt32:
+		return func(buf *ua.Buffer) interface{} { return buf.ReadUint32() }, true
+	case id.Int64:
+		return func(buf *ua.Buffer) interface{} { return buf.ReadInt64() }, true
+	case id.UInt64:
+		return func(buf *ua.Buffer) interface{} { return buf.ReadUint64() }, true
+	case id.Float:
+		return func(buf *ua.Buffer) interface{} { return buf.ReadFloat32() }, true
+	case id.Double:
+		return func(buf *ua.Buffer) interface{} { return buf.ReadFloat64() }, true
+	case id.String, id.XMLElement:
+		return func(buf *ua.Buffer) interface{} { return buf.ReadString() }, true
+	case id.DateTime:
+		return func(buf *ua.Buffer) interface{} { return buf.ReadTime() }, true
+	case id.ByteString:
+		return func(buf *ua.Buffer) interface{} { return buf.ReadBytes() }, true
+	case id.StatusCode:
+		return func(buf *ua.Buffer) interface{} { return ua.StatusCode(buf.ReadUint32()) }, true
+	case id.GUID:
+		return structValue(new(ua.GUID)), true
+	case id.NodeID:
+		return structValue(new(ua.NodeID)), true
+	case id.ExpandedNodeID:
+		return structValue(new(ua.ExpandedNodeID)), true
+	case id.QualifiedName:
+		return structValue(new(ua.QualifiedName)), true
+	case id.LocalizedText:
+		return structValue(new(ua.LocalizedText)), true
+	case id.Structure:
+		return structValue(new(ua.ExtensionObject)), true
+	case id.DataValue:
+		return structValue(new(ua.DataValue)), true
+	case id.BaseDataType, id.Number, id.Integer, id.UInteger:
+		// Abstract types are encoded as Variant
+		return structValue(new(ua.Variant)), true
+	case id.DiagnosticInfo:
+		return structValue(new(ua.DiagnosticInfo)), true
+	}
+	return nil, false
+}
+
+// builtinSubtypes maps standard data types to the builtin type they are
+// encoded as.
+var builtinSubtypes = map[uint32]uint32{
+	id.Duration:                       id.Double,
+	id.UtcTime:                        id.DateTime,
+	id.LocaleID:                       id.String,
+	id.NumericRange:                   id.String,
+	id.NormalizedString:               id.String,
+	id.DecimalString:                  id.String,
+	id.DurationString:                 id.String,
+	id.TimeString:                     id.String,
+	id.DateString:                     id.String,
+	id.IntegerID:                      id.UInt32,
+	id.Counter:                        id.UInt32,
+	id.Index:                          id.UInt32,
+	id.VersionTime:                    id.UInt32,
+	id.BitFieldMaskDataType:           id.UInt64,
+	id.Image:                          id.ByteString,
+	id.ImageBMP:                       id.ByteString,
+	id.ImageGIF:                       id.ByteString,
+	id.ImageJPG:                       id.ByteString,
+	id.ImagePNG:                       id.ByteString,
+	id.ApplicationInstanceCertificate: id.ByteString,
+	id.SessionAuthenticationToken:     id.NodeID,
+}
+
+// enumValue converts an enum value (or array of values) to {"value", "name"}.
+func enumValue(v interface{}, names map[int64]string) interface{} {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() == reflect.Slice {
+		out := make([]interface{}, rv.Len())
+		for i := range out {
+			out[i] = enumValue(rv.Index(i).Interface(), names)
+		}
+		return out
+	}
+
+	n, ok := toInt64(v)
+	if !ok {
+		return v
+	}
+	value := map[string]interface{}{"value": n}
+	if name, ok := names[n]; ok {
+		value["name"] = name
+	}
+	return value
+}
+
+// =============================================================================
+// Custom Structure Encodings
+// =============================================================================
+
+// rawStructure keeps the binary body of a custom structure, which the OPC UA
+// stack would otherwise discard as an unknown extension object.
+type rawStructure struct {
+	body []byte
+}
+
+// Decode implements ua.BinaryDecoder. The buffer holds exactly the body.
+func (r *rawStructure) Decode(b []byte) (int, error) {
+	r.body = append([]byte(nil), b...)
+	return len(b), nil
+}
+
+// Encode implements ua.BinaryEncoder.
+func (r *rawStructure) Encode() ([]byte, error) {
+	return r.body, nil
+}
+
+// MarshalJSON keeps the legacy output of unknown structures (a nil value).
+func (r *rawStructure) MarshalJSON() ([]byte, error) {
+	return []byte("null"), nil
+}
+
+// structureBodies serializes keepStructureBodies.
+var structureBodies sync.Mutex
+
+// keepStructureBodies makes the OPC UA stack keep the binary body of a custom
+// structure encoding, which it otherwise discards as an unknown extension
+// object. The stack's type registry is process-wide, so all it gets is
+// rawStructure, the same body holder for every server; the body is decoded
+// with the definitions of each connection's type system. Encodings the stack
+// already decodes to a type of its own are left to it and reported.
+func keepStructureBodies(encodingID *ua.NodeID) error {
+	if encodingID == nil {
+		return nil
+	}
+	structureBodies.Lock()
+	defer structureBodies.Unlock()
+
+	known, err := stackType(encodingID)
+	if err != nil {
+		return err
+	}
+	switch known.(type) {
+	case nil:
+		ua.RegisterExtensionObject(encodingID, new(rawStructure))
+		return nil
+	case *rawStructure:
+		return nil
+	default:
+		return fmt.Errorf("encoding %s is decoded by the OPC UA stack as %T", encodingID, known)
+	}
+}
+
+// stackType returns the value the stack decodes an extension object with the
+// given encoding to, or nil if the encoding is unknown to it.
+func stackType(encodingID *ua.NodeID) (interface{}, error) {
+	probe, err := (&ua.ExtensionObject{
+		EncodingMask: ua.ExtensionObjectBinary,
+		TypeID:       &ua.ExpandedNodeID{NodeID: encodingID},
+		Value:        &rawStructure{body: []byte{0}},
+	}).Encode()
+	if err != nil {
+		return nil, fmt.Errorf("encoding %s: %w", encodingID, err)
+	}
+	// The one-byte body is too short for most types; only the type matters
+	var eo ua.ExtensionObject
+	_, _ = eo.Decode(probe)
+	return eo.Value, nil
+}
+
+// =============================================================================
+// Type Resolution
+// =============================================================================
+
+// resolveTypes reads the DataType of variable nodes whose type is not cached
+// yet, and the DataTypeDefinition of their data types and of the data types
+// of nested structure fields. Types without a definition (servers before
+// OPC UA 1.04) are cached as undefined and decoded generically.
+func (c *Client) resolveTypes(ctx context.Context, nodeIDs []*ua.NodeID) error {
+	ts := c.typeCache()
+
+	ts.mu.RLock()
+	var unknown []*ua.NodeID
+	for _, nodeID := range nodeIDs {
+		if _, ok := ts.nodes[nodeID.String()]; !ok {
+			unknown = append(unknown, nodeID)
+		}
+	}
+	ts.mu.RUnlock()
+	if len(unknown) == 0 {
+		return nil
+	}
+
+	results, err := c.readAttribute(ctx, unknown, ua.AttributeIDDataType)
+	if err != nil {
+		return err
+	}
+
+	pending := make(map[string]*ua.NodeID)
+	ts.mu.Lock()
+	for i, result := range results {
+		if isBadStatus(result.Status) || result.Value == nil {
+			continue
+		}
+		dataType, ok := result.Value.Value().(*ua.NodeID)
+		if !ok || dataType == nil {
+			continue
+		}
+		ts.nodes[unknown[i].String()] = dataType
+		if _, known := ts.dataTypes[dataType.String()]; !known {
+			pending[dataType.String()] = dataType
+		}
+	}
+	ts.mu.Unlock()
+
+	return c.resolveDefinitions(ctx, pending)
+}
+
+// resolveDefinitions reads the DataTypeDefinition of data types, and of the
+// data types of their structure fields, that are not cached yet.
+func (c *Client) resolveDefinitions(ctx context.Context, pending map[string]*ua.NodeID) error {
+	ts := c.typeCache()
+	for depth := 0; len(pending) > 0 && depth < maxTypeDepth; depth++ {
+		dataTypes := make([]*ua.NodeID, 0, len(pending))
+		for _, dataType := range pending {
+			if _, builtin := builtinReader(dataType); !builtin {
+				dataTypes = append(dataTypes, dataType)
+			}
+		}
+		pending = make(map[string]*ua.NodeID)
+		if len(dataTypes) == 0 {
+			break
+		}
+
+		results, err := c.readAttribute(ctx, dataTypes, ua.AttributeIDDataTypeDefinition)
+		if err != nil {
+			return err
+		}
+		for i, result := range results {
+			var def *dataTypeDefinition
+			if !isBadStatus(result.Status) && result.Value != nil {
+				def = definitionFromValue(result.Value.Value())
+			}
+			ts.addDefinition(dataTypes[i], def)
+			if def == nil || def.structure == nil {
+				continue
+			}
+			if err := keepStructureBodies(def.structure.DefaultEncodingID); err != nil {
+				c.logger.Warn().Err(err).
+					Str("data_type", dataTypes[i].String()).
+					Msg("Custom structure is decoded by the OPC UA stack")
+			}
+
+			ts.mu.RLock()
+			for _, field := range def.structure.Fields {
+				if field == nil || field.DataType == nil {
+					continue
+				}
+				if _, known := ts.dataTypes[field.DataType.String()]; !known {
+					pending[field.DataType.String()] = field.DataType
+				}
+			}
+			ts.mu.RUnlock()
+		}
+	}
+	return nil
+}
+
+// typeCache returns the type system of the current session.
+func (c *Client) typeCache() *typeSystem {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.types
+}
+
+// resolveTagTypes resolves the data types of tags, logging failures: values
+// of unresolved tags are still decoded generically.
+func (c *Client) resolveTagTypes(ctx context.Context, tags []*domain.Tag) {
+	nodeIDs := make([]*ua.NodeID, 0, len(tags))
+	for _, tag := range tags {
+		if nodeID, err := c.getNodeIDForTag(tag); err == nil {
+			nodeIDs = append(nodeIDs, nodeID)
+		}
+	}
+	if err := c.resolveTypes(ctx, nodeIDs); err != nil {
+		c.logger.Warn().Err(err).Msg("Failed to resolve OPC UA data types")
+	}
+}
+
+// readAttribute reads one attribute of multiple nodes.
+// Uses opMu to serialize operations for thread safety.
+func (c *Client) readAttribute(ctx context.Context, nodeIDs []*ua.NodeID, attributeID ua.AttributeID) ([]*ua.DataValue, error) {
+	c.mu.RLock()
+	client := c.client
+	c.mu.RUnlock()
+
+	if client == nil {
+		return nil, domain.ErrConnectionClosed
+	}
+
+	reads := make([]*ua.ReadValueID, len(nodeIDs))
+	for i, nodeID := range nodeIDs {
+		reads[i] = &ua.ReadValueID{NodeID: nodeID, AttributeID: attributeID}
+	}
+
+	c.opMu.Lock()
+	defer c.opMu.Unlock()
+
+	resp, err := client.Read(ctx, &ua.ReadRequest{
+		TimestampsToReturn: ua.TimestampsToReturnNeither,
+		NodesToRead:        reads,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", domain.ErrReadFailed, err)
+	}
+	if len(resp.Results) != len(nodeIDs) {
+		return nil, fmt.Errorf("%w: expected %d results, got %d", domain.ErrReadFailed, len(nodeIDs), len(resp.Results))
+	}
+	return resp.Results, nil
+}
@@ -0,0 +1,177 @@
+package opcua
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/gopcua/opcua/id"
+	"github.com/gopcua/opcua/ua"
+)
+
+func TestTypeSystemConvert(t *testing.T) {
+	ts := newTypeSystem()
+
+	texts := []*ua.LocalizedText{
+		{Locale: "en", Text: "Running"},
+		{Text: "Läuft"},
+	}
+	got := ts.convert(texts, 0)
+	want := []interface{}{
+		map[string]interface{}{"locale": "en", "text": "Running"},
+		map[string]interface{}{"text": "Läuft"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("localized texts: got %#v, want %#v", got, want)
+	}
+
+	matrix := [][]int32{{1, 2}, {3, 4}}
+	if got := ts.convert(matrix, 0); !reflect.DeepEqual(got, []interface{}{
+		[]interface{}{int32(1), int32(2)},
+		[]interface{}{int32(3), int32(4)},
+	}) {
+		t.Errorf("matrix: got %#v", got)
+	}
+
+	if got := ts.convert(&ua.QualifiedName{NamespaceIndex: 2, Name: "Motor"}, 0); !reflect.DeepEqual(got,
+		map[string]interface{}{"namespace": uint16(2), "name": "Motor"}) {
+		t.Errorf("qualified name: got %#v", got)
+	}
+
+	// Structures known to the stack are converted field by field
+	rng := ua.NewExtensionObject(&ua.Range{Low: 0, High: 100})
+	if got := ts.convert(rng, 0); !reflect.DeepEqual(got, map[string]interface{}{"Low": 0.0, "High": 100.0}) {
+		t.Errorf("range: got %#v", got)
+	}
+}
+
+func TestTypeSystemDecodeStructure(t *testing.T) {
+	ts := newTypeSystem()
+	modeType := ua.NewNumericNodeID(2, 3001)
+	pointType := ua.NewNumericNodeID(2, 3002)
+	ts.dataTypes[modeType.String()] = &dataTypeDefinition{enum: map[int64]string{0: "Manual", 1: "Auto"}}
+	ts.dataTypes[pointType.String()] = &dataTypeDefinition{structure: &ua.StructureDefinition{
+		StructureType: ua.StructureTypeStructure,
+		Fields: []*ua.StructureField{
+			{Name: "X", DataType: ua.NewNumericNodeID(0, id.Double), ValueRank: -1},
+			{Name: "Y", DataType: ua.NewNumericNodeID(0, id.Double), ValueRank: -1},
+		},
+	}}
+
+	def := &ua.StructureDefinition{
+		StructureType: ua.StructureTypeStructureWithOptionalFields,
+		Fields: []*ua.StructureField{
+			{Name: "Name", DataType: ua.NewNumericNodeID(0, id.String), ValueRank: -1},
+			{Name: "Comment", DataType: ua.NewNumericNodeID(0, id.LocalizedText), ValueRank: -1, IsOptional: true},
+			{Name: "Mode", DataType: modeType, ValueRank: -1},
+			{Name: "Setpoints", DataType: ua.NewNumericNodeID(0, id.Float), ValueRank: 1},
+			{Name: "Origin", DataType: pointType, ValueRank: -1, IsOptional: true},
+			{Name: "Timeout", DataType: ua.NewNumericNodeID(0, id.Duration), ValueRank: -1},
+		},
+	}
+
+	buf := ua.NewBuffer(nil)
+	buf.WriteUint32(0x2) // Comment absent, Origin present
+	buf.WriteString("Pump 1")
+	buf.WriteInt32(1)
+	buf.WriteInt32(2)
+	buf.WriteFloat32(1.5)
+	buf.WriteFloat32(2.5)
+	buf.WriteFloat64(3)
+	buf.WriteFloat64(4)
+	buf.WriteFloat64(250)
+
+	got, err := ts.decodeStructure(ua.NewBuffer(buf.Bytes()), def, 0)
+	if err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	want := map[string]interface{}{
+		"Name":      "Pump 1",
+		"Mode":      map[string]interface{}{"value": int64(1), "name": "Auto"},
+		"Setpoints": []interface{}{float32(1.5), float32(2.5)},
+		"Origin":    map[string]interface{}{"X": 3.0, "Y": 4.0},
+		"Timeout":   250.0,
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %#v, want %#v", got, want)
+	}
+
+	// A truncated body fails instead of returning partial fields
+	if _, err := ts.decodeStructure(ua.NewBuffer(buf.Bytes()[:10]), def, 0); err == nil {
+		t.Error("expected error for truncated body")
+	}
+}
+
+func TestTypeSystemUnionAndRawFallback(t *testing.T) {
+	ts := newTypeSystem()
+	union := &ua.StructureDefinition{
+		StructureType: ua.StructureTypeUnion,
+		Fields: []*ua.StructureField{
+			{Name: "Count", DataType: ua.NewNumericNodeID(0, id.UInt32), ValueRank: -1},
+			{Name: "Label", DataType: ua.NewNumericNodeID(0, id.String), ValueRank: -1},
+		},
+	}
+	encoding := ua.NewNumericNodeID(2, 5001)
+	ts.encodings[encoding.String()] = union
+
+	buf := ua.NewBuffer(nil)
+	buf.WriteUint32(2)
+	buf.WriteString("Batch 7")
+
+	eo := &ua.ExtensionObject{
+		TypeID: ua.NewNumericExpandedNodeID(2, 5001),
+		Value:  &rawStructure{body: buf.Bytes()},
+	}
+	if got := ts.convert(eo, 0); !reflect.DeepEqual(got, map[string]interface{}{"Label": "Batch 7"}) {
+		t.Errorf("union: got %#v", got)
+	}
+
+	// Without a definition the body is kept
+	eo.TypeID = ua.NewNumericExpandedNodeID(2, 5002)
+	got, ok := ts.convert(eo, 0).(map[string]interface{})
+	if !ok || got["type_id"] != "ns=2;i=5002" || !reflect.DeepEqual(got["body"], buf.Bytes()) {
+		t.Errorf("raw fallback: got %#v", got)
+	}
+}
+
+func TestKeepStructureBodies_PerConnectionDecoding(t *testing.T) {
+	encoding := ua.NewNumericNodeID(7, 5101)
+	if err := keepStructureBodies(encoding); err != nil {
+		t.Fatalf("keep bodies: %v", err)
+	}
+	if err := keepStructureBodies(encoding); err != nil {
+		t.Fatalf("second server with the same encoding: %v", err)
+	}
+	if err := keepStructureBodies(ua.NewNumericNodeID(0, id.Range_Encoding_DefaultBinary)); err == nil {
+		t.Error("expected an encoding the stack decodes itself to be reported")
+	}
+
+	body := ua.NewBuffer(nil)
+	body.WriteUint32(42)
+	raw, err := (&ua.ExtensionObject{
+		EncodingMask: ua.ExtensionObjectBinary,
+		TypeID:       &ua.ExpandedNodeID{NodeID: encoding},
+		Value:        &rawStructure{body: body.Bytes()},
+	}).Encode()
+	if err != nil {
+		t.Fatal(err)
+	}
+	eo := new(ua.ExtensionObject)
+	if _, err := eo.Decode(raw); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+
+	// Two servers define the same encoding differently; each decodes with its own
+	count, code := newTypeSystem(), newTypeSystem()
+	count.encodings[encoding.String()] = &ua.StructureDefinition{Fields: []*ua.StructureField{
+		{Name: "Count", DataType: ua.NewNumericNodeID(0, id.UInt32), ValueRank: -1},
+	}}
+	code.encodings[encoding.String()] = &ua.StructureDefinition{Fields: []*ua.StructureField{
+		{Name: "Code", DataType: ua.NewNumericNodeID(0, id.Int32), ValueRank: -1},
+	}}
+	if got := count.convert(eo, 0); !reflect.DeepEqual(got, map[string]interface{}{"Count": uint32(42)}) {
+		t.Errorf("first server: got %#v", got)
+	}
+	if got := code.convert(eo, 0); !reflect.DeepEqual(got, map[string]interface{}{"Code": int32(42)}) {
+		t.Errorf("second server: got %#v", got)
+	}
+}
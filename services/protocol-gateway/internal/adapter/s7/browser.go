@@ -0,0 +1,223 @@
+// Package s7 provides the domain.ProtocolBrowser implementation for S7 PLCs.
+package s7
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/nexus-edge/protocol-gateway/internal/domain"
+	"github.com/sony/gobreaker"
+)
+
+// S7 userdata function groups and block functions used for browsing.
+const (
+	userDataGroupBlock       byte = 0x03 // Block functions
+	userDataListBlocksOfType byte = 0x02 // Block function: list blocks of a type
+	blockTypeDB              byte = 0x41 // 'A': data block
+	userDataReturnOK         byte = 0xFF // Data section return code: success
+	userDataReturnNotExist   byte = 0x0A // Data section return code: object does not exist
+	userDataMaxFragments          = 256  // Upper bound on response fragments of one request
+)
+
+// Browse enumerates the data blocks of a PLC with their sizes. rootPath is ""
+// for all data blocks or "DB<n>" for a single one. depth is ignored: without a
+// symbol table the PLC does not describe what a data block contains, so
+// offsets and types inside a block are entered when importing.
+func (p *Pool) Browse(ctx context.Context, device *domain.Device, rootPath string, depth int) ([]domain.BrowseResult, error) {
+	dbNumber := 0
+	if rootPath != "" {
+		upper := strings.ToUpper(strings.TrimSpace(rootPath))
+		n, err := strconv.Atoi(strings.TrimPrefix(upper, "DB"))
+		if !strings.HasPrefix(upper, "DB") || err != nil || n < 1 || n > 65535 {
+			return nil, fmt.Errorf("%w: %q (expected DB<n>)", domain.ErrS7InvalidDBNumber, rootPath)
+		}
+		dbNumber = n
+	}
+
+	p.mu.RLock()
+	entry, exists := p.clients[device.ID]
+	p.mu.RUnlock()
+
+	if !exists {
+		if _, err := p.GetOrCreate(ctx, device); err != nil {
+			return nil, err
+		}
+		p.mu.RLock()
+		entry = p.clients[device.ID]
+		p.mu.RUnlock()
+	}
+
+	result, err := entry.breaker.Execute(func() (interface{}, error) {
+		return entry.client.browseDataBlocks(dbNumber)
+	})
+	if err != nil {
+		if err == gobreaker.ErrOpenState {
+			return nil, domain.ErrCircuitBreakerOpen
+		}
+		return nil, err
+	}
+
+	return result.([]domain.BrowseResult), nil
+}
+
+// browseDataBlocks lists the data blocks of the PLC (or only dbNumber if
+// non-zero) with the attributes reported by their block info.
+func (c *Client) browseDataBlocks(dbNumber int) ([]domain.BrowseResult, error) {
+	c.mu.RLock()
+	client := c.client
+	c.mu.RUnlock()
+
+	if client == nil {
+		return nil, domain.ErrConnectionClosed
+	}
+
+	numbers := []int{dbNumber}
+	if dbNumber == 0 {
+		data, err := c.userData(userDataGroupBlock, userDataListBlocksOfType, []byte{0x30, blockTypeDB})
+		if err != nil {
+			if err == domain.ErrS7ObjectNotExist {
+				return []domain.BrowseResult{}, nil
+			}
+			return nil, fmt.Errorf("%w: list data blocks: %v", domain.ErrS7ReadFailed, err)
+		}
+		numbers = blockNumbers(data)
+	}
+
+	results := make([]domain.BrowseResult, 0, len(numbers))
+	for _, n := range numbers {
+		address := fmt.Sprintf("DB%d", n)
+		result := domain.BrowseResult{
+			ID:       fmt.Sprintf("db%d", n),
+			Name:     address,
+			Address:  address,
+			Path:     []string{address},
+			Metadata: map[string]string{"db_number": strconv.Itoa(n)},
+		}
+
+		c.opMu.Lock()
+		info, err := client.GetAgBlockInfo(int(blockTypeDB), n)
+		c.opMu.Unlock()
+
+		if err != nil {
+			if dbNumber != 0 {
+				return nil, fmt.Errorf("%w: block info of DB%d: %v", domain.ErrS7ReadFailed, n, err)
+			}
+			c.logger.Debug().Err(err).Int("db_number", n).Msg("Failed to read data block info")
+		} else {
+			result.Metadata["size_bytes"] = strconv.Itoa(info.MC7Size)
+			result.Metadata["load_size"] = strconv.Itoa(info.LoadSize)
+			for key, value := range map[string]string{"name": info.Header, "family": info.Family, "author": info.Author} {
+				if value = strings.Trim(value, "\x00 "); value != "" {
+					result.Metadata[key] = value
+				}
+			}
+		}
+
+		results = append(results, result)
+	}
+
+	return results, nil
+}
+
+// blockNumbers decodes the entries of a "list blocks of type" response:
+// block number (2 bytes), flags and language (1 byte each).
+func blockNumbers(data []byte) []int {
+	numbers := make([]int, 0, len(data)/4)
+	for i := 0; i+4 <= len(data); i += 4 {
+		numbers = append(numbers, int(binary.BigEndian.Uint16(data[i:])))
+	}
+	return numbers
+}
+
+// userData sends an S7 userdata request (the PG function telegrams also used
+// for SZL reads) and returns the data of all response fragments. gos7 only
+// exposes fixed requests of this kind, so the telegrams are built here and
+// sent through its transport.
+func (c *Client) userData(group, subfunction byte, payload []byte) ([]byte, error) {
+	c.mu.RLock()
+	handler := c.handler
+	c.mu.RUnlock()
+
+	if handler == nil {
+		return nil, domain.ErrConnectionClosed
+	}
+
+	c.opMu.Lock()
+	defer c.opMu.Unlock()
+
+	var data []byte
+	request := userDataRequest(group, subfunction, payload)
+	for i := 0; i < userDataMaxFragments; i++ {
+		response, err := handler.Send(request)
+		if err != nil {
+			return nil, err
+		}
+
+		fragment, more, sequence, err := parseUserDataResponse(response)
+		if err != nil {
+			return nil, err
+		}
+		data = append(data, fragment...)
+		if !more {
+			return data, nil
+		}
+		request = userDataNextRequest(group, subfunction, sequence)
+	}
+
+	return nil, fmt.Errorf("%w: userdata response exceeds %d fragments", domain.ErrS7PDUSizeMismatch, userDataMaxFragments)
+}
+
+// userDataRequest builds the first telegram of a userdata request.
+func userDataRequest(group, subfunction byte, payload []byte) []byte {
+	params := []byte{0x00, 0x01, 0x12, 0x04, 0x11, 0x40 | group, subfunction, 0x00}
+	data := append([]byte{userDataReturnOK, 0x09, 0x00, 0x00}, payload...)
+	binary.BigEndian.PutUint16(data[2:], uint16(len(payload)))
+	return userDataTelegram(params, data)
+}
+
+// userDataNextRequest builds the telegram requesting the fragment following
+// sequence.
+func userDataNextRequest(group, subfunction, sequence byte) []byte {
+	params := []byte{0x00, 0x01, 0x12, 0x08, 0x12, 0x40 | group, subfunction, sequence, 0x00, 0x00, 0x00, 0x00}
+	return userDataTelegram(params, []byte{userDataReturnNotExist, 0x00, 0x00, 0x00})
+}
+
+// userDataTelegram frames userdata parameters and data in TPKT, COTP and S7
+// headers.
+func userDataTelegram(params, data []byte) []byte {
+	telegram := make([]byte, 17, 17+len(params)+len(data))
+	copy(telegram, []byte{0x03, 0x00, 0x00, 0x00, 0x02, 0xF0, 0x80, 0x32, 0x07, 0x00, 0x00, 0x00, 0x01})
+	binary.BigEndian.PutUint16(telegram[13:], uint16(len(params)))
+	binary.BigEndian.PutUint16(telegram[15:], uint16(len(data)))
+	telegram = append(append(telegram, params...), data...)
+	binary.BigEndian.PutUint16(telegram[2:], uint16(len(telegram)))
+	return telegram
+}
+
+// parseUserDataResponse returns the data of a userdata response fragment,
+// whether more fragments follow and the fragment's sequence number.
+func parseUserDataResponse(response []byte) ([]byte, bool, byte, error) {
+	// TPKT (4) + COTP (3) + S7 header (10) + userdata parameters (12) + data header (4)
+	if len(response) < 33 {
+		return nil, false, 0, fmt.Errorf("%w: userdata response of %d bytes", domain.ErrS7PDUSizeMismatch, len(response))
+	}
+	if code := binary.BigEndian.Uint16(response[27:]); code != 0 {
+		return nil, false, 0, fmt.Errorf("%w: userdata error 0x%04X", domain.ErrS7CPUError, code)
+	}
+	switch response[29] {
+	case userDataReturnOK:
+	case userDataReturnNotExist:
+		return nil, false, 0, domain.ErrS7ObjectNotExist
+	default:
+		return nil, false, 0, fmt.Errorf("%w: userdata return code 0x%02X", domain.ErrS7CPUError, response[29])
+	}
+
+	length := int(binary.BigEndian.Uint16(response[31:]))
+	if 33+length > len(response) {
+		return nil, false, 0, fmt.Errorf("%w: userdata data length %d exceeds response", domain.ErrS7PDUSizeMismatch, length)
+	}
+	return response[33 : 33+length], response[26] != 0, response[24], nil
+}
@@ -0,0 +1,50 @@
+package s7
+
+import (
+	"bytes"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/nexus-edge/protocol-gateway/internal/domain"
+)
+
+func TestUserDataRequestTelegrams(t *testing.T) {
+	// Same telegram gos7 sends for its DB block list
+	want := []byte{3, 0, 0, 31, 2, 240, 128, 50, 7, 0, 0, 0, 1, 0, 8, 0, 6, 0, 1, 18, 4, 17, 67, 2, 0, 255, 9, 0, 2, 48, 65}
+	if got := userDataRequest(userDataGroupBlock, userDataListBlocksOfType, []byte{0x30, blockTypeDB}); !bytes.Equal(got, want) {
+		t.Errorf("first request:\n got % x\nwant % x", got, want)
+	}
+
+	want = []byte{3, 0, 0, 33, 2, 240, 128, 50, 7, 0, 0, 0, 1, 0, 12, 0, 4, 0, 1, 18, 8, 18, 67, 2, 5, 0, 0, 0, 0, 10, 0, 0, 0}
+	if got := userDataNextRequest(userDataGroupBlock, userDataListBlocksOfType, 5); !bytes.Equal(got, want) {
+		t.Errorf("next request:\n got % x\nwant % x", got, want)
+	}
+}
+
+func TestParseUserDataResponse(t *testing.T) {
+	header := []byte{3, 0, 0, 0, 2, 240, 128, 50, 7, 0, 0, 0, 1, 0, 12, 0, 12,
+		0, 1, 18, 8, 18, 131, 2, 7, 0, 1, 0, 0} // sequence 7, more fragments follow
+	response := append(append([]byte{}, header...), 255, 9, 0, 8, 0, 1, 0x22, 0x41, 0x01, 0x2C, 0x22, 0x41)
+
+	data, more, sequence, err := parseUserDataResponse(response)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !more || sequence != 7 {
+		t.Errorf("got more=%v sequence=%d, want true and 7", more, sequence)
+	}
+	if got := blockNumbers(data); !reflect.DeepEqual(got, []int{1, 300}) {
+		t.Errorf("got block numbers %v, want [1 300]", got)
+	}
+
+	notExist := append(append([]byte{}, header...), 10, 0, 0, 0)
+	if _, _, _, err := parseUserDataResponse(notExist); !errors.Is(err, domain.ErrS7ObjectNotExist) {
+		t.Errorf("expected ErrS7ObjectNotExist, got %v", err)
+	}
+
+	truncated := append(append([]byte{}, header...), 255, 9, 0, 8, 0, 1)
+	if _, _, _, err := parseUserDataResponse(truncated); !errors.Is(err, domain.ErrS7PDUSizeMismatch) {
+		t.Errorf("expected ErrS7PDUSizeMismatch, got %v", err)
+	}
+}
@@ -40,6 +40,12 @@ func NewClient(deviceID string, config ClientConfig, logger zerolog.Logger) (*Cl
 	if config.PDUSize == 0 {
 		config.PDUSize = 480
 	}
+	if config.LocalTSAP == 0 {
+		config.LocalTSAP = domain.S7DefaultLocalTSAP
+	}
+	if config.RemoteTSAP == 0 {
+		config.RemoteTSAP = 0x0100 | uint16(config.Rack*0x20+config.Slot)
+	}
 
 	c := &Client{
 		config:         config,
@@ -66,11 +72,15 @@ func (c *Client) Connect(ctx context.Context) error {
 	c.logger.Debug().
 		Int("rack", c.config.Rack).
 		Int("slot", c.config.Slot).
+		Str("local_tsap", domain.FormatS7TSAP(c.config.LocalTSAP)).
+		Str("remote_tsap", domain.FormatS7TSAP(c.config.RemoteTSAP)).
 		Msg("Connecting to S7 PLC")
 
-	// Create TCP handler
+	// Create TCP handler. LOGO! and S7-200 CPUs only accept the TSAPs
+	// configured for the connection; upstream gos7 fixes the local TSAP at
+	// 01.00, so the module uses the fork in third_party/gos7.
 	address := fmt.Sprintf("%s:%d", c.config.Address, c.config.Port)
-	handler := gos7.NewTCPClientHandler(address, c.config.Rack, c.config.Slot)
+	handler := gos7.NewTCPClientHandlerWithTSAP(address, c.config.LocalTSAP, c.config.RemoteTSAP)
 	handler.Timeout = c.config.Timeout
 	handler.IdleTimeout = c.config.IdleTimeout
 
@@ -253,7 +263,7 @@ func (c *Client) readTagsOptimized(ctx context.Context, tags []*domain.Tag) ([]*
 		if err != nil {
 			return nil, fmt.Errorf("failed to parse address for tag %s: %w", tag.ID, err)
 		}
-		byteCount := c.getByteCount(tag.DataType)
+		byteCount := c.getByteCount(tag)
 		parsed = append(parsed, s7ParsedTag{
 			tag:       tag,
 			area:      area,
@@ -469,7 +479,7 @@ func (c *Client) readTagBatch(ctx context.Context, tags []*domain.Tag) ([]*domai
 		}
 
 		// Calculate bytes to read and word length
-		byteCount := c.getByteCount(tag.DataType)
+		byteCount := c.getByteCount(tag)
 		wordLen := c.getWordLength(tag.DataType)
 
 		// Allocate buffer for this item
@@ -777,7 +787,7 @@ func (c *Client) writeTagBatch(ctx context.Context, writes []indexedWrite) []err
 			Area:     areaCode,
 			WordLen:  wordLen,
 			DBNumber: dbNumber,
-			Start:    offset,
+			Start:    writeOffset(iw.write.Tag, offset),
 			Bit:      bitOffset,
 			Amount:   len(buffer),
 			Data:     buffer,
@@ -851,7 +861,7 @@ func (c *Client) readData(tag *domain.Tag, area domain.S7Area, dbNumber, offset,
 	defer c.opMu.Unlock()
 
 	// Calculate bytes to read based on data type
-	byteCount := c.getByteCount(tag.DataType)
+	byteCount := c.getByteCount(tag)
 	buffer := BufferPool.Get(byteCount)
 	defer BufferPool.Put(buffer)
 
@@ -926,11 +936,12 @@ func (c *Client) writeData(tag *domain.Tag, area domain.S7Area, dbNumber, offset
 	defer BufferPool.Put(buffer) // Return buffer to pool after use
 
 	// Write to PLC
+	start := writeOffset(tag, offset)
 	switch area {
 	case domain.S7AreaDB:
-		err = client.AGWriteDB(dbNumber, offset, len(buffer), buffer)
+		err = client.AGWriteDB(dbNumber, start, len(buffer), buffer)
 	default:
-		err = client.AGWriteEB(offset, len(buffer), buffer)
+		err = client.AGWriteEB(start, len(buffer), buffer)
 	}
 
 	if err != nil {
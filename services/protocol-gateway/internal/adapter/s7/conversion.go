@@ -76,6 +76,27 @@ func (c *Client) parseValue(data []byte, tag *domain.Tag, bitOffset int) (interf
 		bits := binary.BigEndian.Uint64(data)
 		return math.Float64frombits(bits), nil
 
+	case domain.DataTypeString:
+		return parseS7String(data)
+
+	case domain.DataTypeWString:
+		return parseS7WString(data)
+
+	case domain.DataTypeDTL:
+		return parseDTL(data)
+
+	case domain.DataTypeDateAndTime:
+		return parseDateAndTime(data)
+
+	case domain.DataTypeTime:
+		if len(data) < 4 {
+			return nil, domain.ErrInvalidDataLength
+		}
+		return int64(int32(binary.BigEndian.Uint32(data))), nil
+
+	case domain.DataTypeS5Time:
+		return parseS5Time(data)
+
 	default:
 		return nil, domain.ErrInvalidDataType
 	}
@@ -187,6 +208,30 @@ func (c *Client) valueToBytes(value interface{}, tag *domain.Tag, bitOffset int)
 		binary.BigEndian.PutUint64(data, math.Float64bits(f))
 		return data, nil
 
+	case domain.DataTypeString:
+		return encodeS7String(actualValue, s7StringLength(tag))
+
+	case domain.DataTypeWString:
+		return encodeS7WString(actualValue, s7StringLength(tag))
+
+	case domain.DataTypeDTL:
+		return encodeDTL(actualValue)
+
+	case domain.DataTypeDateAndTime:
+		return encodeDateAndTime(actualValue)
+
+	case domain.DataTypeTime:
+		ms, ok := toMilliseconds(actualValue)
+		if !ok || ms < math.MinInt32 || ms > math.MaxInt32 {
+			return nil, fmt.Errorf("%w: cannot convert %v to TIME", domain.ErrInvalidWriteValue, value)
+		}
+		data := BufferPool.Get(4)
+		binary.BigEndian.PutUint32(data, uint32(int32(ms)))
+		return data, nil
+
+	case domain.DataTypeS5Time:
+		return encodeS5Time(actualValue)
+
 	default:
 		return nil, fmt.Errorf("%w: unsupported data type %s", domain.ErrInvalidDataType, tag.DataType)
 	}
@@ -233,9 +278,9 @@ func (c *Client) reverseScaling(value interface{}, tag *domain.Tag) interface{}
 // Byte Count Calculation
 // =============================================================================
 
-// getByteCount returns the number of bytes needed for a data type.
-func (c *Client) getByteCount(dataType domain.DataType) int {
-	switch dataType {
+// getByteCount returns the number of bytes needed for a tag's data type.
+func (c *Client) getByteCount(tag *domain.Tag) int {
+	switch tag.DataType {
 	case domain.DataTypeBool:
 		return 1
 	case domain.DataTypeInt16, domain.DataTypeUInt16:
@@ -244,11 +289,31 @@ func (c *Client) getByteCount(dataType domain.DataType) int {
 		return 4
 	case domain.DataTypeInt64, domain.DataTypeUInt64, domain.DataTypeFloat64:
 		return 8
+	case domain.DataTypeString:
+		return 2 + s7StringLength(tag)
+	case domain.DataTypeWString:
+		return 4 + 2*s7StringLength(tag)
+	case domain.DataTypeDTL:
+		return 12
+	case domain.DataTypeDateAndTime:
+		return 8
+	case domain.DataTypeTime:
+		return 4
+	case domain.DataTypeS5Time:
+		return 2
 	default:
 		return 1
 	}
 }
 
+// s7StringLength returns the declared character length of a STRING or WSTRING tag.
+func s7StringLength(tag *domain.Tag) int {
+	if tag.S7StringLength == 0 {
+		return domain.S7StringDefaultLength
+	}
+	return int(tag.S7StringLength)
+}
+
 // =============================================================================
 // Type Conversion Helpers
 // =============================================================================
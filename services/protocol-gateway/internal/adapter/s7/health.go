@@ -153,6 +153,7 @@ type DeviceHealth struct {
 	LastError           error
 	LastUsed            time.Time
 	ConsecutiveFailures int32
+	CPUState            domain.CPUState // empty until the first status readout
 }
 
 // =============================================================================
@@ -252,6 +253,7 @@ func (p *Pool) GetDeviceHealth(deviceID string) (DeviceHealth, bool) {
 		LastError:           entry.client.LastError(),
 		LastUsed:            entry.lastUse,
 		ConsecutiveFailures: entry.client.ConsecutiveFailures(),
+		CPUState:            entry.cpuState(),
 	}, true
 }
 
@@ -270,6 +272,7 @@ func (p *Pool) GetAllDeviceHealth() []DeviceHealth {
 			LastError:           entry.client.LastError(),
 			LastUsed:            entry.lastUse,
 			ConsecutiveFailures: entry.client.ConsecutiveFailures(),
+			CPUState:            entry.cpuState(),
 		})
 		entry.mu.Unlock()
 	}
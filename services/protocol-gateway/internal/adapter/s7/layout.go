@@ -0,0 +1,428 @@
+// Package s7 provides tag generation from data block layouts.
+package s7
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/nexus-edge/protocol-gateway/internal/domain"
+)
+
+// maxSourceTags bounds the tags generated from one block, so a large array
+// cannot produce an unbounded tag list.
+const maxSourceTags = 10000
+
+// maxSourceNesting bounds UDT nesting (and catches recursive UDTs).
+const maxSourceNesting = 16
+
+// elementaryType describes an elementary S7 type: its size in bytes (0 for
+// BOOL) and the tag data type it is published as ("" if it has none).
+type elementaryType struct {
+	size     int
+	dataType domain.DataType
+}
+
+// sourceElementaryTypes are the elementary types of standard (non-optimized)
+// blocks. DATE (days since 1990-01-01), TIME_OF_DAY (ms since midnight) and
+// LTIME/LDT/LTOD (ns) are published as their raw counts.
+var sourceElementaryTypes = map[string]elementaryType{
+	"BOOL":          {0, domain.DataTypeBool},
+	"BYTE":          {1, ""},
+	"CHAR":          {1, ""},
+	"SINT":          {1, ""},
+	"USINT":         {1, ""},
+	"WORD":          {2, domain.DataTypeUInt16},
+	"INT":           {2, domain.DataTypeInt16},
+	"UINT":          {2, domain.DataTypeUInt16},
+	"WCHAR":         {2, ""},
+	"DATE":          {2, domain.DataTypeUInt16},
+	"S5TIME":        {2, domain.DataTypeS5Time},
+	"DWORD":         {4, domain.DataTypeUInt32},
+	"DINT":          {4, domain.DataTypeInt32},
+	"UDINT":         {4, domain.DataTypeUInt32},
+	"REAL":          {4, domain.DataTypeFloat32},
+	"TIME":          {4, domain.DataTypeTime},
+	"TIME_OF_DAY":   {4, domain.DataTypeUInt32},
+	"TOD":           {4, domain.DataTypeUInt32},
+	"LWORD":         {8, domain.DataTypeUInt64},
+	"LINT":          {8, domain.DataTypeInt64},
+	"ULINT":         {8, domain.DataTypeUInt64},
+	"LREAL":         {8, domain.DataTypeFloat64},
+	"LTIME":         {8, domain.DataTypeInt64},
+	"LDT":           {8, domain.DataTypeUInt64},
+	"LTOD":          {8, domain.DataTypeUInt64},
+	"LTIME_OF_DAY":  {8, domain.DataTypeUInt64},
+	"DATE_AND_TIME": {8, domain.DataTypeDateAndTime},
+	"DT":            {8, domain.DataTypeDateAndTime},
+	"DTL":           {12, domain.DataTypeDTL},
+	"POINTER":       {6, ""},
+	"ANY":           {10, ""},
+}
+
+// SourceImportOptions selects the block to import from a source and how its
+// tags are named.
+type SourceImportOptions struct {
+	// Block is the data block or UDT to import. Optional if the source holds
+	// a single data block.
+	Block string
+
+	// DBNumber is the data block number. Required unless the block has an
+	// absolute name (DATA_BLOCK DB 10). A UDT is laid out from offset 0 of
+	// this DB.
+	DBNumber int
+
+	// TopicPrefix is prepended to each tag's topic suffix (e.g., "motor1")
+	TopicPrefix string
+
+	// AccessMode is applied to every generated tag
+	AccessMode domain.AccessMode
+}
+
+// SkippedMember is a block member without a matching tag data type (BYTE,
+// CHAR, SINT, USINT, WCHAR, POINTER, ANY). It still occupies its bytes.
+type SkippedMember struct {
+	Path   string `json:"path"`
+	Type   string `json:"type"`
+	Offset int    `json:"offset"`
+}
+
+// SourceImport is the result of laying out a block.
+type SourceImport struct {
+	Block    string          `json:"block"`
+	DBNumber int             `json:"db_number"`
+	Size     int             `json:"size"` // Block size in bytes
+	Tags     []domain.Tag    `json:"tags"`
+	Skipped  []SkippedMember `json:"skipped,omitempty"`
+}
+
+// ImportSource parses a block source and generates the tags of one of its
+// blocks. Offsets follow the standard-access S7 layout rules: BOOLs share a
+// byte (8 per byte, also in arrays), other 1-byte types are byte-aligned,
+// everything else as well as every STRUCT, UDT and ARRAY starts on an even
+// byte, and STRUCTs and ARRAYs are padded to an even size.
+//
+// Each elementary member becomes a tag named by its path ("Motor.Speed",
+// "Temps[3]") with the path as topic suffix ("Motor/Speed", "Temps/3").
+// Paths that map to the same tag ID ("A.B" and "A_B") get a numeric suffix
+// from the second one on ("A_B_2").
+func ImportSource(src string, opts SourceImportOptions) (*SourceImport, error) {
+	source, err := ParseSource(src)
+	if err != nil {
+		return nil, err
+	}
+	return source.Import(opts)
+}
+
+// Import generates the tags of one block of the source.
+func (s *Source) Import(opts SourceImportOptions) (*SourceImport, error) {
+	block, err := s.selectBlock(opts.Block)
+	if err != nil {
+		return nil, err
+	}
+	if block.Optimized {
+		return nil, fmt.Errorf("%w: %s uses optimized block access, which has no fixed offsets (disable it in the block properties)",
+			domain.ErrS7InvalidSource, block.Name)
+	}
+
+	dbNumber := opts.DBNumber
+	if dbNumber == 0 && !s.isType(block) {
+		dbNumber = block.Number
+	}
+	if dbNumber <= 0 {
+		return nil, fmt.Errorf("%w: a DB number is required for %s", domain.ErrS7InvalidDBNumber, block.Name)
+	}
+
+	l := &sourceLayout{source: s}
+	if err := l.place(nil, block.typ, ""); err != nil {
+		return nil, err
+	}
+	l.alignWord()
+
+	result := &SourceImport{
+		Block:    block.Name,
+		DBNumber: dbNumber,
+		Size:     l.byteOffset,
+		Skipped:  l.skipped,
+		Tags:     make([]domain.Tag, 0, len(l.leaves)),
+	}
+	ids := make(map[string]bool, len(l.leaves))
+	for _, leaf := range l.leaves {
+		tag := leaf.tag(dbNumber, opts)
+		tag.ID = uniqueSourceTagID(tag.ID, ids)
+		if err := tag.ValidateForProtocol(domain.ProtocolS7); err != nil {
+			return nil, fmt.Errorf("%w: %s: %v", domain.ErrS7InvalidSource, joinSourcePath(leaf.path), err)
+		}
+		result.Tags = append(result.Tags, tag)
+	}
+	return result, nil
+}
+
+// selectBlock returns the named block, or the only data block of the source.
+func (s *Source) selectBlock(name string) (*SourceBlock, error) {
+	if name != "" {
+		block := s.block(name)
+		if block == nil {
+			return nil, fmt.Errorf("%w: block %q not found", domain.ErrS7InvalidSource, name)
+		}
+		return block, nil
+	}
+
+	switch {
+	case len(s.Blocks) == 1:
+		return s.Blocks[0], nil
+	case len(s.Blocks) == 0 && len(s.Types) == 1:
+		return s.Types[0], nil
+	}
+	names := make([]string, 0, len(s.Blocks)+len(s.Types))
+	for _, b := range append(append([]*SourceBlock{}, s.Blocks...), s.Types...) {
+		names = append(names, b.Name)
+	}
+	return nil, fmt.Errorf("%w: the source declares several blocks, select one of %s",
+		domain.ErrS7InvalidSource, strings.Join(names, ", "))
+}
+
+// isType reports whether a block is a UDT of the source.
+func (s *Source) isType(block *SourceBlock) bool {
+	for _, t := range s.Types {
+		if t == block {
+			return true
+		}
+	}
+	return false
+}
+
+// sourceLayout assigns byte and bit offsets to the members of a block.
+type sourceLayout struct {
+	source     *Source
+	byteOffset int
+	bitOffset  int
+	depth      int
+	leaves     []sourceLeaf
+	skipped    []SkippedMember
+}
+
+// sourceLeaf is an elementary member at its offset.
+type sourceLeaf struct {
+	path       []string // Member names and array indices
+	typeName   string
+	dataType   domain.DataType
+	byteOffset int
+	bitOffset  int
+	length     int // STRING/WSTRING length
+	comment    string
+}
+
+// alignByte moves to the start of the next byte if inside a byte of BOOLs.
+func (l *sourceLayout) alignByte() {
+	if l.bitOffset > 0 {
+		l.byteOffset++
+		l.bitOffset = 0
+	}
+}
+
+// alignWord moves to the next even byte.
+func (l *sourceLayout) alignWord() {
+	l.alignByte()
+	if l.byteOffset%2 == 1 {
+		l.byteOffset++
+	}
+}
+
+// place lays out a member of a type at the current offset.
+func (l *sourceLayout) place(path []string, typ *sourceType, comment string) error {
+	switch typ.kind {
+	case sourceElementary:
+		elem := sourceElementaryTypes[typ.name]
+		switch elem.size {
+		case 0:
+			// BOOL
+		case 1:
+			l.alignByte()
+		default:
+			l.alignWord()
+		}
+		if elem.dataType == "" {
+			l.skipped = append(l.skipped, SkippedMember{Path: joinSourcePath(path), Type: typ.name, Offset: l.byteOffset})
+		} else if err := l.addLeaf(sourceLeaf{path: path, typeName: typ.name, dataType: elem.dataType,
+			byteOffset: l.byteOffset, bitOffset: l.bitOffset, comment: comment}); err != nil {
+			return err
+		}
+		if elem.size == 0 {
+			l.bitOffset++
+			if l.bitOffset == 8 {
+				l.alignByte()
+			}
+		}
+		l.byteOffset += elem.size
+
+	case sourceString, sourceWString:
+		leaf := sourceLeaf{path: path, typeName: "STRING", dataType: domain.DataTypeString, length: typ.length, comment: comment}
+		size := 2 + typ.length
+		if typ.kind == sourceWString {
+			leaf.typeName, leaf.dataType, size = "WSTRING", domain.DataTypeWString, 4+2*typ.length
+		}
+		leaf.typeName += "[" + strconv.Itoa(typ.length) + "]"
+		l.alignWord()
+		leaf.byteOffset = l.byteOffset
+		if err := l.addLeaf(leaf); err != nil {
+			return err
+		}
+		l.byteOffset += size
+
+	case sourceStruct:
+		l.alignWord()
+		for _, m := range typ.members {
+			if err := l.place(append(path[:len(path):len(path)], m.name), m.typ, m.comment); err != nil {
+				return err
+			}
+		}
+		l.alignWord()
+
+	case sourceArray:
+		l.alignWord()
+		index := make([]int, len(typ.dims))
+		for i, dim := range typ.dims {
+			index[i] = dim[0]
+		}
+		for {
+			parts := make([]string, len(index))
+			for i, n := range index {
+				parts[i] = strconv.Itoa(n)
+			}
+			element := append(path[:len(path):len(path)], "["+strings.Join(parts, ",")+"]")
+			if err := l.place(element, typ.elem, comment); err != nil {
+				return err
+			}
+			// Row-major: the last index varies fastest
+			i := len(index) - 1
+			for ; i >= 0; i-- {
+				if index[i] < typ.dims[i][1] {
+					index[i]++
+					break
+				}
+				index[i] = typ.dims[i][0]
+			}
+			if i < 0 {
+				break
+			}
+		}
+		l.alignWord()
+
+	case sourceRef:
+		ref := l.source.block(typ.name)
+		if ref == nil || !l.source.isType(ref) {
+			return fmt.Errorf("%w: %s: UDT %q is not declared in the source", domain.ErrS7InvalidSource, joinSourcePath(path), typ.name)
+		}
+		if l.depth >= maxSourceNesting {
+			return fmt.Errorf("%w: %s: UDTs nested deeper than %d levels", domain.ErrS7InvalidSource, joinSourcePath(path), maxSourceNesting)
+		}
+		l.depth++
+		err := l.place(path, ref.typ, comment)
+		l.depth--
+		return err
+	}
+	return nil
+}
+
+// addLeaf records an elementary member, bounded by maxSourceTags.
+func (l *sourceLayout) addLeaf(leaf sourceLeaf) error {
+	if len(l.leaves) >= maxSourceTags {
+		return fmt.Errorf("%w: more than %d tags", domain.ErrS7InvalidSource, maxSourceTags)
+	}
+	l.leaves = append(l.leaves, leaf)
+	return nil
+}
+
+// tag builds the tag of a leaf in a DB.
+func (leaf sourceLeaf) tag(dbNumber int, opts SourceImportOptions) domain.Tag {
+	name := joinSourcePath(leaf.path)
+
+	var topic []string
+	if opts.TopicPrefix != "" {
+		topic = append(topic, strings.Trim(opts.TopicPrefix, "/"))
+	}
+	for _, part := range leaf.path {
+		topic = append(topic, strings.Split(strings.Trim(part, "[]"), ",")...)
+	}
+
+	description := leaf.typeName
+	if leaf.comment != "" {
+		description = leaf.comment + " (" + leaf.typeName + ")"
+	}
+
+	tag := domain.Tag{
+		ID:          sourceTagID(name),
+		Name:        name,
+		Description: description,
+		DataType:    leaf.dataType,
+		TopicSuffix: sanitizeSourceTopic(strings.Join(topic, "/")),
+		AccessMode:  opts.AccessMode,
+		Enabled:     true,
+		S7Address:   sourceAddress(dbNumber, leaf),
+	}
+	if leaf.length > 0 {
+		tag.S7StringLength = uint16(leaf.length)
+	}
+	return tag
+}
+
+// sourceAddress returns the DB address of a leaf.
+func sourceAddress(dbNumber int, leaf sourceLeaf) string {
+	switch {
+	case leaf.dataType == domain.DataTypeBool:
+		return fmt.Sprintf("DB%d.DBX%d.%d", dbNumber, leaf.byteOffset, leaf.bitOffset)
+	case leaf.dataType == domain.DataTypeString || leaf.dataType == domain.DataTypeWString:
+		return fmt.Sprintf("DB%d.DBB%d", dbNumber, leaf.byteOffset)
+	case sourceElementaryTypes[leaf.typeName].size == 2:
+		return fmt.Sprintf("DB%d.DBW%d", dbNumber, leaf.byteOffset)
+	default:
+		return fmt.Sprintf("DB%d.DBD%d", dbNumber, leaf.byteOffset)
+	}
+}
+
+// joinSourcePath joins member names with '.' and appends array indices.
+func joinSourcePath(path []string) string {
+	var b strings.Builder
+	for i, part := range path {
+		if i > 0 && !strings.HasPrefix(part, "[") {
+			b.WriteByte('.')
+		}
+		b.WriteString(part)
+	}
+	return b.String()
+}
+
+// sourceTagID derives a tag ID from a member path: characters other than
+// letters, digits, '-' and '_' become '_'.
+func sourceTagID(path string) string {
+	id := strings.Map(func(r rune) rune {
+		if r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '-' || r == '_' {
+			return r
+		}
+		return '_'
+	}, path)
+	return strings.Trim(id, "_")
+}
+
+// uniqueSourceTagID returns id, or id with the first free numeric suffix if
+// it is already taken, and marks the result as taken.
+func uniqueSourceTagID(id string, taken map[string]bool) string {
+	unique := id
+	for n := 2; taken[unique]; n++ {
+		unique = fmt.Sprintf("%s_%d", id, n)
+	}
+	taken[unique] = true
+	return unique
+}
+
+// sanitizeSourceTopic replaces MQTT wildcards and whitespace in a topic suffix.
+func sanitizeSourceTopic(topic string) string {
+	return strings.Map(func(r rune) rune {
+		switch r {
+		case '+', '#', ' ', '\t':
+			return '_'
+		}
+		return r
+	}, topic)
+}
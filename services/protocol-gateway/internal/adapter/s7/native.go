@@ -0,0 +1,303 @@
+// Package s7 provides encoding of the S7-native string, date and time types.
+package s7
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+	"time"
+	"unicode/utf16"
+
+	"github.com/nexus-edge/protocol-gateway/internal/domain"
+)
+
+// S7 date and time values carry no time zone; they are read and written as UTC.
+//
+// Published values:
+//   - STRING, WSTRING:      string (actual length from the header)
+//   - DTL, DATE_AND_TIME:   time.Time (RFC 3339 in JSON payloads)
+//   - TIME, S5TIME:         int64 milliseconds
+
+// s5TimeBases maps the S5TIME time-base bits (13-12) to milliseconds.
+var s5TimeBases = [4]int64{10, 100, 1000, 10000}
+
+// =============================================================================
+// Strings
+// =============================================================================
+
+// parseS7String decodes a STRING: max length byte, actual length byte, then
+// single-byte (Latin-1) characters.
+func parseS7String(data []byte) (interface{}, error) {
+	if len(data) < 2 {
+		return nil, domain.ErrInvalidDataLength
+	}
+	n := int(data[1])
+	if n > int(data[0]) || 2+n > len(data) {
+		return nil, fmt.Errorf("%w: STRING length %d exceeds max %d or buffer %d",
+			domain.ErrInvalidDataLength, n, data[0], len(data)-2)
+	}
+	runes := make([]rune, n)
+	for i, b := range data[2 : 2+n] {
+		runes[i] = rune(b)
+	}
+	return string(runes), nil
+}
+
+// parseS7WString decodes a WSTRING: big-endian max and actual length words,
+// then UTF-16BE characters.
+func parseS7WString(data []byte) (interface{}, error) {
+	if len(data) < 4 {
+		return nil, domain.ErrInvalidDataLength
+	}
+	maxLen := int(binary.BigEndian.Uint16(data))
+	n := int(binary.BigEndian.Uint16(data[2:]))
+	if n > maxLen || 4+2*n > len(data) {
+		return nil, fmt.Errorf("%w: WSTRING length %d exceeds max %d or buffer %d",
+			domain.ErrInvalidDataLength, n, maxLen, (len(data)-4)/2)
+	}
+	units := make([]uint16, n)
+	for i := range units {
+		units[i] = binary.BigEndian.Uint16(data[4+2*i:])
+	}
+	return string(utf16.Decode(units)), nil
+}
+
+// encodeS7String encodes the current length and characters of a STRING. The
+// max-length byte belongs to the declaration in the PLC and is not written:
+// the data goes to the tag's offset+1 (see writeOffset). Characters past the
+// current length are left untouched.
+func encodeS7String(value interface{}, maxLen int) ([]byte, error) {
+	s, ok := value.(string)
+	if !ok {
+		return nil, fmt.Errorf("%w: cannot convert %T to STRING", domain.ErrInvalidWriteValue, value)
+	}
+	runes := []rune(s)
+	if len(runes) > maxLen {
+		return nil, fmt.Errorf("%w: %d characters exceed STRING[%d]", domain.ErrInvalidWriteValue, len(runes), maxLen)
+	}
+	data := BufferPool.Get(1 + len(runes))
+	data[0] = byte(len(runes))
+	for i, r := range runes {
+		if r > 0xFF {
+			BufferPool.Put(data)
+			return nil, fmt.Errorf("%w: character %q is not representable in a STRING", domain.ErrInvalidWriteValue, r)
+		}
+		data[1+i] = byte(r)
+	}
+	return data, nil
+}
+
+// encodeS7WString encodes the current length and characters of a WSTRING,
+// written to the tag's offset+2 like encodeS7String.
+func encodeS7WString(value interface{}, maxLen int) ([]byte, error) {
+	s, ok := value.(string)
+	if !ok {
+		return nil, fmt.Errorf("%w: cannot convert %T to WSTRING", domain.ErrInvalidWriteValue, value)
+	}
+	units := utf16.Encode([]rune(s))
+	if len(units) > maxLen {
+		return nil, fmt.Errorf("%w: %d characters exceed WSTRING[%d]", domain.ErrInvalidWriteValue, len(units), maxLen)
+	}
+	data := BufferPool.Get(2 + 2*len(units))
+	binary.BigEndian.PutUint16(data, uint16(len(units)))
+	for i, u := range units {
+		binary.BigEndian.PutUint16(data[2+2*i:], u)
+	}
+	return data, nil
+}
+
+// writeOffset returns the byte offset the encoded value of a tag is written
+// to: past the max-length header for STRING and WSTRING, the tag's offset
+// otherwise.
+func writeOffset(tag *domain.Tag, offset int) int {
+	switch tag.DataType {
+	case domain.DataTypeString:
+		return offset + 1
+	case domain.DataTypeWString:
+		return offset + 2
+	default:
+		return offset
+	}
+}
+
+// =============================================================================
+// Date and Time
+// =============================================================================
+
+// parseDTL decodes a DTL: year (uint16), month, day, weekday, hour, minute,
+// second (one byte each) and nanoseconds (uint32).
+func parseDTL(data []byte) (interface{}, error) {
+	if len(data) < 12 {
+		return nil, domain.ErrInvalidDataLength
+	}
+	year := int(binary.BigEndian.Uint16(data))
+	month, day, hour, minute, second := int(data[2]), int(data[3]), int(data[5]), int(data[6]), int(data[7])
+	nanos := int(binary.BigEndian.Uint32(data[8:]))
+	if !validDateTime(month, day, hour, minute, second) || nanos > 999999999 {
+		return nil, fmt.Errorf("%w: invalid DTL % x", domain.ErrInvalidDataType, data[:12])
+	}
+	return time.Date(year, time.Month(month), day, hour, minute, second, nanos, time.UTC), nil
+}
+
+// parseDateAndTime decodes a DATE_AND_TIME: BCD year (90-99 = 1990s, 00-89 =
+// 2000s), month, day, hour, minute, second, then three BCD millisecond digits
+// and the weekday nibble.
+func parseDateAndTime(data []byte) (interface{}, error) {
+	if len(data) < 8 {
+		return nil, domain.ErrInvalidDataLength
+	}
+	var fields [6]int
+	for i := range fields {
+		v, ok := fromBCD(data[i])
+		if !ok {
+			return nil, fmt.Errorf("%w: invalid DATE_AND_TIME % x", domain.ErrInvalidDataType, data[:8])
+		}
+		fields[i] = v
+	}
+	msHigh, ok1 := fromBCD(data[6])
+	msLow := int(data[7] >> 4)
+	if !ok1 || msLow > 9 || !validDateTime(fields[1], fields[2], fields[3], fields[4], fields[5]) {
+		return nil, fmt.Errorf("%w: invalid DATE_AND_TIME % x", domain.ErrInvalidDataType, data[:8])
+	}
+	year := 2000 + fields[0]
+	if fields[0] >= 90 {
+		year = 1900 + fields[0]
+	}
+	ms := msHigh*10 + msLow
+	return time.Date(year, time.Month(fields[1]), fields[2], fields[3], fields[4], fields[5],
+		ms*int(time.Millisecond), time.UTC), nil
+}
+
+// encodeDTL encodes a timestamp as a DTL.
+func encodeDTL(value interface{}) ([]byte, error) {
+	t, ok := toTime(value)
+	if !ok || t.Year() < 1970 || t.Year() > 2262 {
+		return nil, fmt.Errorf("%w: cannot convert %v to DTL (1970-2262)", domain.ErrInvalidWriteValue, value)
+	}
+	data := BufferPool.Get(12)
+	binary.BigEndian.PutUint16(data, uint16(t.Year()))
+	data[2], data[3] = byte(t.Month()), byte(t.Day())
+	data[4] = byte(t.Weekday()) + 1 // 1 = Sunday
+	data[5], data[6], data[7] = byte(t.Hour()), byte(t.Minute()), byte(t.Second())
+	binary.BigEndian.PutUint32(data[8:], uint32(t.Nanosecond()))
+	return data, nil
+}
+
+// encodeDateAndTime encodes a timestamp as a DATE_AND_TIME, truncated to the
+// millisecond.
+func encodeDateAndTime(value interface{}) ([]byte, error) {
+	t, ok := toTime(value)
+	if !ok || t.Year() < 1990 || t.Year() > 2089 {
+		return nil, fmt.Errorf("%w: cannot convert %v to DATE_AND_TIME (1990-2089)", domain.ErrInvalidWriteValue, value)
+	}
+	ms := t.Nanosecond() / int(time.Millisecond)
+	data := BufferPool.Get(8)
+	data[0] = toBCD(t.Year() % 100)
+	data[1], data[2] = toBCD(int(t.Month())), toBCD(t.Day())
+	data[3], data[4], data[5] = toBCD(t.Hour()), toBCD(t.Minute()), toBCD(t.Second())
+	data[6] = toBCD(ms / 10)
+	data[7] = byte(ms%10)<<4 | byte(t.Weekday()+1)
+	return data, nil
+}
+
+// validDateTime reports whether the date and time fields are in range.
+func validDateTime(month, day, hour, minute, second int) bool {
+	return month >= 1 && month <= 12 && day >= 1 && day <= 31 &&
+		hour <= 23 && minute <= 59 && second <= 59
+}
+
+// =============================================================================
+// Durations
+// =============================================================================
+
+// parseS5Time decodes an S5TIME: time base in bits 13-12 and three BCD digits
+// in bits 11-0.
+func parseS5Time(data []byte) (interface{}, error) {
+	if len(data) < 2 {
+		return nil, domain.ErrInvalidDataLength
+	}
+	hundreds := int(data[0] & 0x0F)
+	tensUnits, ok := fromBCD(data[1])
+	if !ok || hundreds > 9 {
+		return nil, fmt.Errorf("%w: invalid S5TIME % x", domain.ErrInvalidDataType, data[:2])
+	}
+	base := s5TimeBases[(data[0]>>4)&0x03]
+	return int64(hundreds*100+tensUnits) * base, nil
+}
+
+// encodeS5Time encodes a duration as an S5TIME using the finest time base
+// that holds it, truncating to that base's resolution.
+func encodeS5Time(value interface{}) ([]byte, error) {
+	ms, ok := toMilliseconds(value)
+	if !ok || ms < 0 || ms > 999*s5TimeBases[3] {
+		return nil, fmt.Errorf("%w: cannot convert %v to S5TIME (0-9990s)", domain.ErrInvalidWriteValue, value)
+	}
+	baseIndex := 0
+	for ms/s5TimeBases[baseIndex] > 999 {
+		baseIndex++
+	}
+	count := int(ms / s5TimeBases[baseIndex])
+	data := BufferPool.Get(2)
+	data[0] = byte(baseIndex)<<4 | byte(count/100)
+	data[1] = toBCD(count % 100)
+	return data, nil
+}
+
+// =============================================================================
+// Helpers
+// =============================================================================
+
+// fromBCD decodes a two-digit BCD byte.
+func fromBCD(b byte) (int, bool) {
+	hi, lo := int(b>>4), int(b&0x0F)
+	if hi > 9 || lo > 9 {
+		return 0, false
+	}
+	return hi*10 + lo, true
+}
+
+// toBCD encodes 0-99 as a two-digit BCD byte.
+func toBCD(v int) byte {
+	return byte(v/10)<<4 | byte(v%10)
+}
+
+// toTime converts a write value to a UTC timestamp. Accepts time.Time and
+// RFC 3339 strings.
+func toTime(v interface{}) (time.Time, bool) {
+	switch val := v.(type) {
+	case time.Time:
+		return val.UTC(), true
+	case string:
+		t, err := time.Parse(time.RFC3339Nano, val)
+		if err != nil {
+			return time.Time{}, false
+		}
+		return t.UTC(), true
+	default:
+		return time.Time{}, false
+	}
+}
+
+// toMilliseconds converts a write value to milliseconds. Accepts
+// time.Duration, Go duration strings (e.g., "1m30s") and numbers of
+// milliseconds.
+func toMilliseconds(v interface{}) (int64, bool) {
+	switch val := v.(type) {
+	case time.Duration:
+		return val.Milliseconds(), true
+	case string:
+		d, err := time.ParseDuration(val)
+		if err != nil {
+			return 0, false
+		}
+		return d.Milliseconds(), true
+	case float32, float64:
+		f, _ := toFloat64(val)
+		if math.IsNaN(f) || math.IsInf(f, 0) {
+			return 0, false
+		}
+		return int64(math.Round(f)), true
+	default:
+		return toInt64(val)
+	}
+}
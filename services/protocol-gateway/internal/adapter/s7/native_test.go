@@ -0,0 +1,167 @@
+package s7
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/nexus-edge/protocol-gateway/internal/domain"
+)
+
+func TestNativeTypes_Parse(t *testing.T) {
+	tests := []struct {
+		name     string
+		dataType domain.DataType
+		data     []byte
+		want     interface{}
+	}{
+		{"string", domain.DataTypeString, []byte{10, 3, 'A', 'B', 0xE9, 'x', 'x'}, "ABé"},
+		{"wstring", domain.DataTypeWString, []byte{0, 4, 0, 2, 0x00, 'H', 0x20, 0xAC, 0, 0}, "H€"},
+		{"dtl", domain.DataTypeDTL,
+			[]byte{0x07, 0xE8, 3, 15, 6, 13, 45, 30, 0x1D, 0xCD, 0x65, 0x00},
+			time.Date(2024, 3, 15, 13, 45, 30, 500000000, time.UTC)},
+		{"date_and_time", domain.DataTypeDateAndTime,
+			[]byte{0x24, 0x03, 0x15, 0x13, 0x45, 0x30, 0x12, 0x36},
+			time.Date(2024, 3, 15, 13, 45, 30, 123000000, time.UTC)},
+		{"date_and_time 1990s", domain.DataTypeDateAndTime,
+			[]byte{0x95, 0x12, 0x31, 0x23, 0x59, 0x59, 0x00, 0x01},
+			time.Date(1995, 12, 31, 23, 59, 59, 0, time.UTC)},
+		{"time", domain.DataTypeTime, []byte{0xFF, 0xFF, 0xFC, 0x18}, int64(-1000)},
+		{"s5time 10ms base", domain.DataTypeS5Time, []byte{0x01, 0x23}, int64(1230)},
+		{"s5time 10s base", domain.DataTypeS5Time, []byte{0x39, 0x99}, int64(9990000)},
+	}
+
+	c := &Client{}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tag := &domain.Tag{ID: "t", DataType: tt.dataType}
+			got, err := c.parseValue(tt.data, tag, 0)
+			if err != nil {
+				t.Fatalf("parseValue: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("got %v (%T), want %v (%T)", got, got, tt.want, tt.want)
+			}
+		})
+	}
+
+	for _, bad := range []struct {
+		dataType domain.DataType
+		data     []byte
+	}{
+		{domain.DataTypeString, []byte{2, 3, 'a', 'b', 'c'}},
+		{domain.DataTypeDateAndTime, []byte{0x24, 0x13, 0x15, 0x13, 0x45, 0x30, 0x12, 0x36}},
+		{domain.DataTypeS5Time, []byte{0x01, 0x2A}},
+	} {
+		if _, err := c.parseValue(bad.data, &domain.Tag{DataType: bad.dataType}, 0); err == nil {
+			t.Errorf("%s % x: expected an error", bad.dataType, bad.data)
+		}
+	}
+}
+
+func TestNativeTypes_Encode(t *testing.T) {
+	ts := time.Date(2024, 3, 15, 13, 45, 30, 123000000, time.UTC)
+	tests := []struct {
+		name  string
+		tag   domain.Tag
+		value interface{}
+		want  []byte
+	}{
+		{"string", domain.Tag{DataType: domain.DataTypeString, S7StringLength: 10}, "ABé", []byte{3, 'A', 'B', 0xE9}},
+		{"wstring", domain.Tag{DataType: domain.DataTypeWString, S7StringLength: 4}, "H€", []byte{0, 2, 0x00, 'H', 0x20, 0xAC}},
+		{"dtl", domain.Tag{DataType: domain.DataTypeDTL}, ts, []byte{0x07, 0xE8, 3, 15, 6, 13, 45, 30, 0x07, 0x54, 0xD4, 0xC0}},
+		{"date_and_time string", domain.Tag{DataType: domain.DataTypeDateAndTime}, "2024-03-15T14:45:30.123+01:00",
+			[]byte{0x24, 0x03, 0x15, 0x13, 0x45, 0x30, 0x12, 0x36}},
+		{"time duration", domain.Tag{DataType: domain.DataTypeTime}, -time.Second, []byte{0xFF, 0xFF, 0xFC, 0x18}},
+		{"s5time ms", domain.Tag{DataType: domain.DataTypeS5Time}, 1230, []byte{0x01, 0x23}},
+		{"s5time coarse base", domain.Tag{DataType: domain.DataTypeS5Time}, "2h", []byte{0x37, 0x20}},
+	}
+
+	c := &Client{}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tag := tt.tag
+			tag.ScaleFactor = 1
+			got, err := c.valueToBytes(tt.value, &tag, 0)
+			if err != nil {
+				t.Fatalf("valueToBytes: %v", err)
+			}
+			defer BufferPool.Put(got)
+			if !bytes.Equal(got, tt.want) {
+				t.Errorf("got % x, want % x", got, tt.want)
+			}
+		})
+	}
+
+	// The max-length header of strings is not overwritten
+	for dataType, want := range map[domain.DataType]int{domain.DataTypeString: 11, domain.DataTypeWString: 12, domain.DataTypeDTL: 10} {
+		if got := writeOffset(&domain.Tag{DataType: dataType}, 10); got != want {
+			t.Errorf("writeOffset(%s, 10) = %d, want %d", dataType, got, want)
+		}
+	}
+
+	for _, bad := range []struct {
+		tag   domain.Tag
+		value interface{}
+	}{
+		{domain.Tag{DataType: domain.DataTypeString, S7StringLength: 2}, "abc"},
+		{domain.Tag{DataType: domain.DataTypeString}, "€"},
+		{domain.Tag{DataType: domain.DataTypeDateAndTime}, time.Date(2100, 1, 1, 0, 0, 0, 0, time.UTC)},
+		{domain.Tag{DataType: domain.DataTypeS5Time}, 10000 * time.Second},
+	} {
+		tag := bad.tag
+		tag.ScaleFactor = 1
+		if _, err := c.valueToBytes(bad.value, &tag, 0); !errors.Is(err, domain.ErrInvalidWriteValue) {
+			t.Errorf("%s %v: expected ErrInvalidWriteValue, got %v", tag.DataType, bad.value, err)
+		}
+	}
+}
+
+func TestNativeTypes_ByteCountAndBatching(t *testing.T) {
+	c := &Client{}
+	tags := []*domain.Tag{
+		{ID: "name", DataType: domain.DataTypeString, S7StringLength: 20},
+		{ID: "label", DataType: domain.DataTypeWString, S7StringLength: 8},
+		{ID: "stamp", DataType: domain.DataTypeDTL},
+		{ID: "timer", DataType: domain.DataTypeS5Time},
+	}
+	wantCounts := []int{22, 20, 12, 2}
+	offsets := []int{0, 22, 42, 54}
+
+	parsed := make([]s7ParsedTag, len(tags))
+	for i, tag := range tags {
+		if got := c.getByteCount(tag); got != wantCounts[i] {
+			t.Errorf("%s: byte count %d, want %d", tag.ID, got, wantCounts[i])
+		}
+		parsed[i] = s7ParsedTag{tag: tag, area: domain.S7AreaDB, dbNumber: 1, offset: offsets[i], byteCount: c.getByteCount(tag)}
+	}
+	if got := c.getByteCount(&domain.Tag{DataType: domain.DataTypeString}); got != 256 {
+		t.Errorf("default STRING byte count %d, want 256", got)
+	}
+
+	ranges := buildS7ContiguousRanges(parsed, DefaultS7BatchConfig())
+	if len(ranges) != 1 || ranges[0].totalBytes != 56 {
+		t.Fatalf("expected one 56-byte range, got %+v", ranges)
+	}
+}
+
+func TestNativeTypes_Validation(t *testing.T) {
+	tag := domain.Tag{ID: "t", Name: "t", TopicSuffix: "t", DataType: domain.DataTypeString, S7Address: "DB1.DBB0"}
+	if err := tag.ValidateForProtocol(domain.ProtocolS7); err != nil {
+		t.Fatalf("validate: %v", err)
+	}
+	if tag.S7StringLength != domain.S7StringDefaultLength {
+		t.Errorf("expected default string length, got %d", tag.S7StringLength)
+	}
+
+	tag.S7StringLength = 255
+	if err := tag.ValidateForProtocol(domain.ProtocolS7); err == nil {
+		t.Error("expected STRING[255] to be rejected")
+	}
+
+	dtl := domain.Tag{ID: "t", Name: "t", TopicSuffix: "t", DataType: domain.DataTypeDTL, RegisterType: domain.RegisterTypeHoldingRegister}
+	if err := dtl.ValidateForProtocol(domain.ProtocolModbusTCP); err == nil {
+		t.Error("expected DTL to be rejected for Modbus")
+	}
+}
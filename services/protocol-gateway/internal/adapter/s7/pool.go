@@ -26,6 +26,9 @@ type Pool struct {
 	stopChan       chan struct{}
 	wg             sync.WaitGroup
 	closed         bool
+
+	// Status handler for devices with s7_status_interval
+	statusHandler StatusHandler
 }
 
 // clientEntry represents a pooled client with its circuit breaker.
@@ -38,6 +41,9 @@ type clientEntry struct {
 	lastError       error
 	connectFailures int
 	nextReconnectAt time.Time
+	info            *domain.DeviceInfo // latest status readout
+	nextStatusAt    time.Time
+	diagnostics     diagnosticCursor
 	mu              sync.Mutex
 }
 
@@ -115,6 +121,10 @@ func NewPool(config PoolConfig, logger zerolog.Logger, metricsReg *metrics.Regis
 	pool.wg.Add(1)
 	go pool.idleReaperLoop()
 
+	// Start status readout of devices with s7_status_interval
+	pool.wg.Add(1)
+	go pool.statusLoop()
+
 	return pool
 }
 
@@ -200,11 +210,18 @@ func (p *Pool) GetOrCreate(ctx context.Context, device *domain.Device) (*Client,
 
 // createClient creates and connects a new S7 client.
 func (p *Pool) createClient(ctx context.Context, device *domain.Device) (*Client, error) {
+	conn, err := device.Connection.S7ConnectionSettings()
+	if err != nil {
+		return nil, err
+	}
+
 	config := ClientConfig{
 		Address:     device.Connection.Host,
 		Port:        device.Connection.Port,
-		Rack:        device.Connection.S7Rack,
-		Slot:        device.Connection.S7Slot,
+		Rack:        conn.Rack,
+		Slot:        conn.Slot,
+		LocalTSAP:   conn.LocalTSAP,
+		RemoteTSAP:  conn.RemoteTSAP,
 		Timeout:     device.Connection.Timeout,
 		IdleTimeout: p.idleTimeout,
 		MaxRetries:  device.Connection.RetryCount,
@@ -434,10 +451,7 @@ func (p *Pool) WriteTags(ctx context.Context, device *domain.Device, writes []Ta
 }
 
 // TagWrite represents a single write operation.
-type TagWrite struct {
-	Tag   *domain.Tag
-	Value interface{}
-}
+type TagWrite = domain.TagWrite
 
 // indexedWrite pairs a TagWrite with its original index for batch error tracking.
 type indexedWrite struct {
@@ -676,6 +690,14 @@ func (p *Pool) reapIdleConnections() {
 // Client Entry Methods
 // =============================================================================
 
+// cpuState returns the CPU state of the latest status readout. Callers hold ce.mu.
+func (ce *clientEntry) cpuState() domain.CPUState {
+	if ce.info == nil {
+		return ""
+	}
+	return ce.info.CPUState
+}
+
 func (ce *clientEntry) canAttemptReconnect(now time.Time) bool {
 	return ce.nextReconnectAt.IsZero() || !now.Before(ce.nextReconnectAt)
 }
@@ -0,0 +1,507 @@
+// Package s7 provides parsing of TIA Portal and STEP 7 data block sources.
+package s7
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/nexus-edge/protocol-gateway/internal/domain"
+)
+
+// Source is a parsed block source file: the UDTs (TYPE) and data blocks
+// (DATA_BLOCK) it declares. Both TIA Portal exports (.udt, .db) and STEP 7
+// AWL sources are accepted; other blocks (FB, FC, OB) are skipped.
+type Source struct {
+	Types  []*SourceBlock
+	Blocks []*SourceBlock
+}
+
+// SourceBlock is a UDT or data block declaration.
+type SourceBlock struct {
+	// Name is the block name: the symbol ("Motor_DB") or absolute name
+	// ("DB10", "UDT5")
+	Name string
+
+	// Number is the block number of an absolute name, else 0
+	Number int
+
+	// Optimized is set for blocks with optimized access, which have no
+	// fixed offsets
+	Optimized bool
+
+	typ *sourceType
+}
+
+// sourceTypeKind is the kind of a declared type.
+type sourceTypeKind int
+
+const (
+	sourceElementary sourceTypeKind = iota
+	sourceString
+	sourceWString
+	sourceArray
+	sourceStruct
+	sourceRef
+)
+
+// sourceType is a declared type in a block source.
+type sourceType struct {
+	kind    sourceTypeKind
+	name    string      // Elementary type (upper case) or referenced UDT
+	length  int         // STRING/WSTRING length
+	dims    [][2]int    // Array bounds (inclusive)
+	elem    *sourceType // Array element type
+	members []sourceMember
+}
+
+// sourceMember is a member of a STRUCT.
+type sourceMember struct {
+	name    string
+	comment string
+	typ     *sourceType
+}
+
+// ParseSource parses a TIA Portal or STEP 7 block source.
+func ParseSource(src string) (*Source, error) {
+	p := &sourceParser{}
+	p.tokenize(src)
+
+	source := &Source{}
+	for !p.done() {
+		tok := p.next()
+		switch {
+		case tok.is("TYPE"):
+			block, err := p.parseBlock("END_TYPE")
+			if err != nil {
+				return nil, err
+			}
+			source.Types = append(source.Types, block)
+		case tok.is("DATA_BLOCK"):
+			block, err := p.parseBlock("END_DATA_BLOCK")
+			if err != nil {
+				return nil, err
+			}
+			source.Blocks = append(source.Blocks, block)
+		case tok.is("FUNCTION_BLOCK"), tok.is("FUNCTION"), tok.is("ORGANIZATION_BLOCK"):
+			p.skipTo("END_" + strings.ToUpper(tok.text))
+		default:
+			return nil, p.errorf(tok, "unexpected %q, expected TYPE or DATA_BLOCK", tok.text)
+		}
+	}
+
+	if len(source.Types) == 0 && len(source.Blocks) == 0 {
+		return nil, fmt.Errorf("%w: no TYPE or DATA_BLOCK found", domain.ErrS7InvalidSource)
+	}
+	return source, nil
+}
+
+// block returns the UDT or data block with a name (case-insensitive).
+func (s *Source) block(name string) *SourceBlock {
+	for _, list := range [][]*SourceBlock{s.Blocks, s.Types} {
+		for _, b := range list {
+			if strings.EqualFold(b.Name, name) {
+				return b
+			}
+		}
+	}
+	return nil
+}
+
+// =============================================================================
+// Tokenizer
+// =============================================================================
+
+// sourceTokenKind is the kind of a source token.
+type sourceTokenKind int
+
+const (
+	tokIdent  sourceTokenKind = iota // Keyword or identifier
+	tokQuoted                        // "Symbol"
+	tokNumber                        // Integer or real literal
+	tokString                        // 'literal'
+	tokSymbol                        // Punctuation (":=", "..", ":", ...)
+)
+
+// sourceToken is a token with its source line.
+type sourceToken struct {
+	kind sourceTokenKind
+	text string
+	line int
+}
+
+// is reports whether the token is the keyword kw (case-insensitive).
+func (t sourceToken) is(kw string) bool {
+	return t.kind == tokIdent && strings.EqualFold(t.text, kw)
+}
+
+// isSymbol reports whether the token is the punctuation sym.
+func (t sourceToken) isSymbol(sym string) bool {
+	return t.kind == tokSymbol && t.text == sym
+}
+
+// sourceParser is a recursive-descent parser over the tokens of a source.
+type sourceParser struct {
+	toks     []sourceToken
+	pos      int
+	comments map[int]string // Line comments by line
+}
+
+// tokenize splits a source into tokens, dropping comments. Line comments are
+// kept by line to describe members.
+func (p *sourceParser) tokenize(src string) {
+	p.comments = make(map[int]string)
+	runes := []rune(src)
+	line := 1
+	for i := 0; i < len(runes); {
+		r := runes[i]
+		switch {
+		case r == '\n':
+			line++
+			i++
+		case unicode.IsSpace(r):
+			i++
+		case r == '/' && i+1 < len(runes) && runes[i+1] == '/':
+			j := i + 2
+			for j < len(runes) && runes[j] != '\n' {
+				j++
+			}
+			p.comments[line] = strings.TrimSpace(string(runes[i+2 : j]))
+			i = j
+		case r == '(' && i+1 < len(runes) && runes[i+1] == '*':
+			j := i + 2
+			for j+1 < len(runes) && !(runes[j] == '*' && runes[j+1] == ')') {
+				if runes[j] == '\n' {
+					line++
+				}
+				j++
+			}
+			i = j + 2
+		case r == '"' || r == '\'':
+			j := i + 1
+			for j < len(runes) && runes[j] != r && runes[j] != '\n' {
+				j++
+			}
+			kind := tokQuoted
+			if r == '\'' {
+				kind = tokString
+			}
+			p.toks = append(p.toks, sourceToken{kind: kind, text: string(runes[i+1 : j]), line: line})
+			i = j
+			if j < len(runes) && runes[j] == r {
+				i++
+			}
+		case unicode.IsLetter(r) || r == '_' || r == '#':
+			j := i + 1
+			for j < len(runes) && (unicode.IsLetter(runes[j]) || unicode.IsDigit(runes[j]) || runes[j] == '_' || runes[j] == '#') {
+				j++
+			}
+			p.toks = append(p.toks, sourceToken{kind: tokIdent, text: string(runes[i:j]), line: line})
+			i = j
+		case unicode.IsDigit(r):
+			j := i + 1
+			for j < len(runes) && (unicode.IsDigit(runes[j]) ||
+				runes[j] == '.' && j+1 < len(runes) && unicode.IsDigit(runes[j+1])) {
+				j++
+			}
+			p.toks = append(p.toks, sourceToken{kind: tokNumber, text: string(runes[i:j]), line: line})
+			i = j
+		default:
+			text := string(r)
+			if i+1 < len(runes) {
+				if pair := string(runes[i : i+2]); pair == ":=" || pair == ".." {
+					text = pair
+				}
+			}
+			p.toks = append(p.toks, sourceToken{kind: tokSymbol, text: text, line: line})
+			i += len([]rune(text))
+		}
+	}
+}
+
+func (p *sourceParser) done() bool {
+	return p.pos >= len(p.toks)
+}
+
+func (p *sourceParser) peek() sourceToken {
+	if p.done() {
+		return sourceToken{kind: tokSymbol, text: "<end of file>"}
+	}
+	return p.toks[p.pos]
+}
+
+func (p *sourceParser) next() sourceToken {
+	tok := p.peek()
+	p.pos++
+	return tok
+}
+
+// skipTo skips past the keyword kw.
+func (p *sourceParser) skipTo(kw string) {
+	for !p.done() {
+		if p.next().is(kw) {
+			return
+		}
+	}
+}
+
+// skipLine skips the remaining tokens on a line.
+func (p *sourceParser) skipLine(line int) {
+	for !p.done() && p.peek().line == line {
+		p.pos++
+	}
+}
+
+// expectSymbol consumes the punctuation sym.
+func (p *sourceParser) expectSymbol(sym string) error {
+	if tok := p.next(); !tok.isSymbol(sym) {
+		return p.errorf(tok, "expected %q, found %q", sym, tok.text)
+	}
+	return nil
+}
+
+// expectNumber consumes an optionally signed integer.
+func (p *sourceParser) expectNumber() (int, error) {
+	sign := 1
+	if p.peek().isSymbol("-") {
+		sign = -1
+		p.pos++
+	}
+	tok := p.next()
+	n, err := strconv.Atoi(tok.text)
+	if tok.kind != tokNumber || err != nil {
+		return 0, p.errorf(tok, "expected a number, found %q", tok.text)
+	}
+	return sign * n, nil
+}
+
+func (p *sourceParser) errorf(tok sourceToken, format string, args ...interface{}) error {
+	return fmt.Errorf("%w: line %d: %s", domain.ErrS7InvalidSource, tok.line, fmt.Sprintf(format, args...))
+}
+
+// =============================================================================
+// Blocks and Declarations
+// =============================================================================
+
+// parseBlock parses a TYPE or DATA_BLOCK after its keyword, up to and
+// including the end keyword.
+func (p *sourceParser) parseBlock(end string) (*SourceBlock, error) {
+	block := &SourceBlock{}
+	tok := p.next()
+	switch {
+	case tok.kind == tokQuoted:
+		block.Name = tok.text
+	case tok.kind == tokIdent && p.peek().kind == tokNumber:
+		// Absolute name: "DB 10", "UDT 5"
+		number, err := p.expectNumber()
+		if err != nil {
+			return nil, err
+		}
+		block.Name = strings.ToUpper(tok.text) + strconv.Itoa(number)
+		block.Number = number
+	case tok.kind == tokIdent:
+		block.Name = tok.text
+		if strings.HasPrefix(strings.ToUpper(tok.text), "DB") {
+			block.Number, _ = strconv.Atoi(tok.text[2:])
+		}
+	default:
+		return nil, p.errorf(tok, "expected a block name, found %q", tok.text)
+	}
+
+	for {
+		tok := p.peek()
+		switch {
+		case p.done():
+			return nil, p.errorf(tok, "missing %s for %s", end, block.Name)
+		case tok.is(end):
+			p.pos++
+			return p.checkBlock(block, tok)
+		case tok.is("BEGIN"):
+			// Initial values of a data block
+			p.skipTo(end)
+			return p.checkBlock(block, tok)
+		case tok.isSymbol("{"):
+			attrs := p.parseAttributes()
+			if strings.EqualFold(attrs["S7_Optimized_Access"], "TRUE") {
+				block.Optimized = true
+			}
+		case tok.is("TITLE"), tok.is("VERSION"), tok.is("AUTHOR"), tok.is("FAMILY"), tok.is("NAME"):
+			p.skipLine(tok.line)
+		case tok.is("STRUCT"), tok.kind == tokQuoted, tok.is("UDT"):
+			if block.typ != nil {
+				return nil, p.errorf(tok, "%s declares more than one structure", block.Name)
+			}
+			typ, err := p.parseType()
+			if err != nil {
+				return nil, err
+			}
+			block.typ = typ
+		case tok.is("FB"), tok.is("SFB"):
+			return nil, p.errorf(tok, "instance data block %s is not supported, export the FB interface as a UDT", block.Name)
+		default:
+			// NON_RETAIN, KNOW_HOW_PROTECT, ';' and other header keywords
+			p.pos++
+		}
+	}
+}
+
+// checkBlock verifies that a block declared a structure.
+func (p *sourceParser) checkBlock(block *SourceBlock, tok sourceToken) (*SourceBlock, error) {
+	if block.typ == nil {
+		return nil, p.errorf(tok, "%s has no STRUCT or UDT declaration", block.Name)
+	}
+	return block, nil
+}
+
+// parseAttributes parses a "{ name := 'value'; ... }" attribute list.
+func (p *sourceParser) parseAttributes() map[string]string {
+	attrs := make(map[string]string)
+	p.pos++ // '{'
+	for !p.done() {
+		tok := p.next()
+		if tok.isSymbol("}") {
+			break
+		}
+		if tok.kind == tokIdent && p.peek().isSymbol(":=") {
+			p.pos++
+			attrs[tok.text] = p.next().text
+		}
+	}
+	return attrs
+}
+
+// parseType parses a type: elementary, STRING[n], WSTRING[n], ARRAY[..] OF,
+// STRUCT ... END_STRUCT, "UDT name" or UDT n.
+func (p *sourceParser) parseType() (*sourceType, error) {
+	tok := p.next()
+	switch {
+	case tok.kind == tokQuoted:
+		return &sourceType{kind: sourceRef, name: tok.text}, nil
+
+	case tok.is("STRUCT"):
+		return p.parseStruct()
+
+	case tok.is("ARRAY"):
+		typ := &sourceType{kind: sourceArray}
+		if err := p.expectSymbol("["); err != nil {
+			return nil, err
+		}
+		for {
+			lo, err := p.expectNumber()
+			if err != nil {
+				return nil, err
+			}
+			if err := p.expectSymbol(".."); err != nil {
+				return nil, err
+			}
+			hi, err := p.expectNumber()
+			if err != nil {
+				return nil, err
+			}
+			if hi < lo {
+				return nil, p.errorf(tok, "array bounds [%d..%d] are reversed", lo, hi)
+			}
+			typ.dims = append(typ.dims, [2]int{lo, hi})
+			if sep := p.next(); sep.isSymbol("]") {
+				break
+			} else if !sep.isSymbol(",") {
+				return nil, p.errorf(sep, "expected \",\" or \"]\", found %q", sep.text)
+			}
+		}
+		if of := p.next(); !of.is("OF") {
+			return nil, p.errorf(of, "expected OF, found %q", of.text)
+		}
+		elem, err := p.parseType()
+		if err != nil {
+			return nil, err
+		}
+		typ.elem = elem
+		return typ, nil
+
+	case tok.is("STRING"), tok.is("WSTRING"):
+		typ := &sourceType{kind: sourceString, length: domain.S7StringDefaultLength}
+		if tok.is("WSTRING") {
+			typ.kind = sourceWString
+		}
+		if p.peek().isSymbol("[") {
+			p.pos++
+			n, err := p.expectNumber()
+			if err != nil {
+				return nil, err
+			}
+			if err := p.expectSymbol("]"); err != nil {
+				return nil, err
+			}
+			typ.length = n
+		}
+		return typ, nil
+
+	case tok.is("UDT") && p.peek().kind == tokNumber:
+		number, _ := p.expectNumber()
+		return &sourceType{kind: sourceRef, name: "UDT" + strconv.Itoa(number)}, nil
+
+	case tok.kind == tokIdent:
+		name := strings.ToUpper(tok.text)
+		if _, ok := sourceElementaryTypes[name]; !ok {
+			// Unquoted UDT symbol
+			return &sourceType{kind: sourceRef, name: tok.text}, nil
+		}
+		return &sourceType{kind: sourceElementary, name: name}, nil
+
+	default:
+		return nil, p.errorf(tok, "expected a type, found %q", tok.text)
+	}
+}
+
+// parseStruct parses STRUCT members after the STRUCT keyword, up to and
+// including END_STRUCT.
+func (p *sourceParser) parseStruct() (*sourceType, error) {
+	typ := &sourceType{kind: sourceStruct}
+	for {
+		tok := p.next()
+		switch {
+		case tok.is("END_STRUCT"):
+			return typ, nil
+		case tok.isSymbol(";"):
+			continue
+		case tok.kind != tokIdent && tok.kind != tokQuoted:
+			return nil, p.errorf(tok, "expected a member name or END_STRUCT, found %q", tok.text)
+		}
+
+		member := sourceMember{name: tok.text, comment: p.comments[tok.line]}
+		if p.peek().isSymbol("{") {
+			p.parseAttributes()
+		}
+		if err := p.expectSymbol(":"); err != nil {
+			return nil, err
+		}
+		memberType, err := p.parseType()
+		if err != nil {
+			return nil, err
+		}
+		member.typ = memberType
+		typ.members = append(typ.members, member)
+
+		if p.peek().isSymbol(":=") {
+			p.skipInitialValue()
+		}
+	}
+}
+
+// skipInitialValue skips a ":= value" up to the terminating ';'.
+func (p *sourceParser) skipInitialValue() {
+	depth := 0
+	for !p.done() {
+		tok := p.peek()
+		switch {
+		case tok.isSymbol("(") || tok.isSymbol("["):
+			depth++
+		case tok.isSymbol(")") || tok.isSymbol("]"):
+			depth--
+		case tok.isSymbol(";") && depth <= 0, tok.is("END_STRUCT"):
+			return
+		}
+		p.pos++
+	}
+}
@@ -0,0 +1,211 @@
+package s7
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/nexus-edge/protocol-gateway/internal/domain"
+)
+
+const tiaSource = `TYPE "UDT_Motor"
+VERSION : 0.1
+   STRUCT
+      "On" : Bool;   // Contactor closed
+      Current : Real;
+   END_STRUCT;
+
+END_TYPE
+
+DATA_BLOCK "Line_DB"
+{ S7_Optimized_Access := 'FALSE' }
+VERSION : 0.1
+NON_RETAIN
+   STRUCT
+      Run : Bool;
+      Fault : Bool;
+      Speed { ExternalAccessible := 'False'} : Real := 1.5;   // Setpoint
+      Count : Int;
+      Mode : Byte;
+      Name : String[10] := 'Line 1';
+      Flags : Array[0..9] of Bool;
+      Temps : Array[1..3] of Real;
+      Motor : "UDT_Motor";
+      Stamp : DTL;
+      Last : Bool;
+   END_STRUCT;
+
+
+BEGIN
+   Count := 5;
+
+END_DATA_BLOCK
+`
+
+func TestImportSource_TIAPortal(t *testing.T) {
+	result, err := ImportSource(tiaSource, SourceImportOptions{DBNumber: 7, TopicPrefix: "line"})
+	if err != nil {
+		t.Fatalf("ImportSource: %v", err)
+	}
+	if result.Block != "Line_DB" || result.DBNumber != 7 || result.Size != 56 {
+		t.Errorf("got block %s DB%d size %d, want Line_DB DB7 size 56", result.Block, result.DBNumber, result.Size)
+	}
+
+	want := []struct {
+		id, address, topic string
+		dataType           domain.DataType
+	}{
+		{"Run", "DB7.DBX0.0", "line/Run", domain.DataTypeBool},
+		{"Fault", "DB7.DBX0.1", "line/Fault", domain.DataTypeBool},
+		{"Speed", "DB7.DBD2", "line/Speed", domain.DataTypeFloat32},
+		{"Count", "DB7.DBW6", "line/Count", domain.DataTypeInt16},
+		{"Name", "DB7.DBB10", "line/Name", domain.DataTypeString},
+		{"Flags_0", "DB7.DBX22.0", "line/Flags/0", domain.DataTypeBool},
+	}
+	tags := make(map[string]domain.Tag, len(result.Tags))
+	for _, tag := range result.Tags {
+		tags[tag.ID] = tag
+	}
+	for _, w := range want {
+		tag, ok := tags[w.id]
+		if !ok {
+			t.Errorf("missing tag %s", w.id)
+			continue
+		}
+		if tag.S7Address != w.address || tag.TopicSuffix != w.topic || tag.DataType != w.dataType {
+			t.Errorf("%s: got %s %s %s, want %s %s %s", w.id, tag.S7Address, tag.TopicSuffix, tag.DataType, w.address, w.topic, w.dataType)
+		}
+	}
+
+	addresses := map[string]string{
+		"Flags_9": "DB7.DBX23.1", "Temps_1": "DB7.DBD24", "Temps_3": "DB7.DBD32",
+		"Motor_On": "DB7.DBX36.0", "Motor_Current": "DB7.DBD38", "Stamp": "DB7.DBD42", "Last": "DB7.DBX54.0",
+	}
+	for id, address := range addresses {
+		if got := tags[id].S7Address; got != address {
+			t.Errorf("%s: address %q, want %q", id, got, address)
+		}
+	}
+	if tags["Name"].S7StringLength != 10 || tags["Speed"].Description != "Setpoint (REAL)" || tags["Motor_On"].Name != "Motor.On" {
+		t.Errorf("unexpected tag details: %+v / %+v / %+v", tags["Name"], tags["Speed"], tags["Motor_On"])
+	}
+	if len(result.Skipped) != 1 || result.Skipped[0].Path != "Mode" || result.Skipped[0].Offset != 8 {
+		t.Errorf("expected Mode (BYTE at 8) to be skipped, got %+v", result.Skipped)
+	}
+}
+
+const awlSource = `TYPE UDT 5
+  STRUCT
+   On : BOOL ;
+   Current : REAL ;
+  END_STRUCT ;
+END_TYPE
+
+DATA_BLOCK DB 10
+TITLE =
+VERSION : 0.1
+
+  STRUCT
+   Speed : REAL ;
+   Flags : ARRAY  [1 .. 3 ] OF BOOL ;
+   Motor : UDT 5;
+   Grid : ARRAY [0..1, 0..1] OF INT ;
+  END_STRUCT ;
+BEGIN
+   Speed := 0.000000e+000;
+END_DATA_BLOCK
+`
+
+func TestImportSource_STEP7(t *testing.T) {
+	result, err := ImportSource(awlSource, SourceImportOptions{})
+	if err != nil {
+		t.Fatalf("ImportSource: %v", err)
+	}
+	if result.Block != "DB10" || result.DBNumber != 10 || result.Size != 20 {
+		t.Errorf("got block %s DB%d size %d, want DB10 DB10 size 20", result.Block, result.DBNumber, result.Size)
+	}
+
+	var got []string
+	for _, tag := range result.Tags {
+		got = append(got, tag.Name+"@"+tag.S7Address)
+	}
+	want := []string{
+		"Speed@DB10.DBD0", "Flags[1]@DB10.DBX4.0", "Flags[2]@DB10.DBX4.1", "Flags[3]@DB10.DBX4.2",
+		"Motor.On@DB10.DBX6.0", "Motor.Current@DB10.DBD8",
+		"Grid[0,0]@DB10.DBW12", "Grid[0,1]@DB10.DBW14", "Grid[1,0]@DB10.DBW16", "Grid[1,1]@DB10.DBW18",
+	}
+	if len(got) != len(want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("tag %d: got %s, want %s", i, got[i], want[i])
+		}
+	}
+	if result.Tags[6].TopicSuffix != "Grid/0/0" || result.Tags[6].ID != "Grid_0_0" {
+		t.Errorf("unexpected array element naming: %s / %s", result.Tags[6].ID, result.Tags[6].TopicSuffix)
+	}
+}
+
+func TestImportSource_DuplicateTagIDs(t *testing.T) {
+	src := `DATA_BLOCK DB 3
+   STRUCT
+      A : STRUCT
+         B : Int;
+      END_STRUCT;
+      A_B : Int;
+      "A B" : Int;
+      A_B_2 : Int;
+      M : Array[1..2, 1..2] of Int;
+      M_1_2 : Int;
+   END_STRUCT;
+BEGIN
+END_DATA_BLOCK
+`
+	result, err := ImportSource(src, SourceImportOptions{})
+	if err != nil {
+		t.Fatalf("ImportSource: %v", err)
+	}
+
+	seen := make(map[string]string)
+	for _, tag := range result.Tags {
+		if other, dup := seen[tag.ID]; dup {
+			t.Errorf("%s and %s share tag ID %s", other, tag.Name, tag.ID)
+		}
+		seen[tag.ID] = tag.Name
+	}
+	want := map[string]string{"A.B": "A_B", "A_B": "A_B_2", "A B": "A_B_3", "A_B_2": "A_B_2_2", "M[1,2]": "M_1_2", "M_1_2": "M_1_2_2"}
+	for _, tag := range result.Tags {
+		if id, ok := want[tag.Name]; ok && tag.ID != id {
+			t.Errorf("%s: got ID %s, want %s", tag.Name, tag.ID, id)
+		}
+	}
+}
+
+func TestImportSource_Errors(t *testing.T) {
+	tests := []struct {
+		name string
+		src  string
+		opts SourceImportOptions
+		err  error
+	}{
+		{"optimized", `DATA_BLOCK "Opt" { S7_Optimized_Access := 'TRUE' } STRUCT a : Int; END_STRUCT; BEGIN END_DATA_BLOCK`,
+			SourceImportOptions{DBNumber: 1}, domain.ErrS7InvalidSource},
+		{"no db number", `DATA_BLOCK "Sym" STRUCT a : Int; END_STRUCT; BEGIN END_DATA_BLOCK`,
+			SourceImportOptions{}, domain.ErrS7InvalidDBNumber},
+		{"unknown udt", `DATA_BLOCK DB 1 STRUCT m : "Missing"; END_STRUCT; BEGIN END_DATA_BLOCK`,
+			SourceImportOptions{}, domain.ErrS7InvalidSource},
+		{"recursive udt", `TYPE "A" STRUCT a : "A"; END_STRUCT; END_TYPE DATA_BLOCK DB 1 "A" BEGIN END_DATA_BLOCK`,
+			SourceImportOptions{}, domain.ErrS7InvalidSource},
+		{"syntax", `DATA_BLOCK DB 1 STRUCT a Int; END_STRUCT; END_DATA_BLOCK`,
+			SourceImportOptions{}, domain.ErrS7InvalidSource},
+		{"huge array", `DATA_BLOCK DB 1 STRUCT a : Array[0..20000] of Int; END_STRUCT; END_DATA_BLOCK`,
+			SourceImportOptions{}, domain.ErrS7InvalidSource},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := ImportSource(tt.src, tt.opts); !errors.Is(err, tt.err) {
+				t.Errorf("expected %v, got %v", tt.err, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,333 @@
+// Package s7 provides PLC identification, state and diagnostics readout via SZL.
+package s7
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/nexus-edge/protocol-gateway/internal/domain"
+	"github.com/sony/gobreaker"
+)
+
+// SZL (system status list) requests and the lists read for the device info.
+const (
+	userDataGroupCPU byte = 0x04 // CPU functions
+	userDataReadSZL  byte = 0x01 // CPU function: read SZL
+
+	szlModuleIdentification    uint16 = 0x0011 // order number, hardware and firmware version
+	szlComponentIdentification uint16 = 0x001C // station, module and type names, serial number
+	szlCPUState                uint16 = 0x0424 // current operating state
+	szlDiagnosticBuffer        uint16 = 0x01A0 // most recent diagnostic entries (index = count)
+
+	diagnosticBufferEntries = 10 // diagnostic entries read and kept in the device info
+)
+
+// StatusHandler receives the device info read from a PLC and the diagnostic
+// buffer entries that appeared since the previous readout.
+type StatusHandler func(info *domain.DeviceInfo, events []*domain.DiagnosticEvent)
+
+// SetStatusHandler sets the callback for status readouts of devices with
+// s7_status_interval. Must be called before devices are polled.
+func (p *Pool) SetStatusHandler(handler StatusHandler) {
+	p.statusHandler = handler
+}
+
+// DeviceInfo returns the latest status readout of a device.
+// Implements domain.DeviceInfoProvider.
+func (p *Pool) DeviceInfo(deviceID string) (*domain.DeviceInfo, bool) {
+	p.mu.RLock()
+	entry, exists := p.clients[deviceID]
+	p.mu.RUnlock()
+
+	if !exists {
+		return nil, false
+	}
+
+	entry.mu.Lock()
+	defer entry.mu.Unlock()
+	if entry.info == nil {
+		return nil, false
+	}
+	info := *entry.info
+	return &info, true
+}
+
+// statusLoop reads the status of devices whose s7_status_interval is due.
+func (p *Pool) statusLoop() {
+	defer p.wg.Done()
+
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-p.stopChan:
+			return
+		case <-ticker.C:
+			p.readDueStatus(time.Now())
+		}
+	}
+}
+
+// readDueStatus reads the status of connected devices whose next readout is
+// due. Readouts bypass the circuit breaker, so CPUs that do not support an
+// SZL list do not affect data reads; an open breaker skips the readout.
+func (p *Pool) readDueStatus(now time.Time) {
+	p.mu.RLock()
+	due := make([]*clientEntry, 0)
+	for _, entry := range p.clients {
+		interval := entry.device.Connection.S7StatusInterval
+		if interval <= 0 || !entry.client.IsConnected() || entry.breaker.State() == gobreaker.StateOpen {
+			continue
+		}
+		entry.mu.Lock()
+		if !now.Before(entry.nextStatusAt) {
+			entry.nextStatusAt = now.Add(interval)
+			due = append(due, entry)
+		}
+		entry.mu.Unlock()
+	}
+	p.mu.RUnlock()
+
+	for _, entry := range due {
+		p.readStatus(entry)
+	}
+}
+
+// readStatus reads and stores the device info of one device and hands it to
+// the status handler with the new diagnostic buffer entries.
+func (p *Pool) readStatus(entry *clientEntry) {
+	device := entry.device
+	info, err := entry.client.readDeviceInfo()
+	if err != nil {
+		p.logger.Warn().Err(err).Str("device", device.ID).Msg("Failed to read S7 status")
+		return
+	}
+	info.DeviceID = device.ID
+	info.Topic = strings.TrimSuffix(device.UNSPrefix, "/") + "/info"
+
+	entry.mu.Lock()
+	previous := entry.info
+	newEntries := entry.diagnostics.update(info.Diagnostics)
+	entry.info = info
+	entry.mu.Unlock()
+
+	if previous != nil && previous.CPUState != info.CPUState {
+		p.logger.Info().
+			Str("device", device.ID).
+			Str("from", string(previous.CPUState)).
+			Str("to", string(info.CPUState)).
+			Msg("S7 CPU state changed")
+	}
+
+	if p.statusHandler == nil {
+		return
+	}
+	topic := strings.TrimSuffix(device.UNSPrefix, "/") + "/diagnostics"
+	events := make([]*domain.DiagnosticEvent, 0, len(newEntries))
+	// Oldest first, so events arrive in the order they occurred
+	for i := len(newEntries) - 1; i >= 0; i-- {
+		events = append(events, &domain.DiagnosticEvent{DeviceID: device.ID, Topic: topic, DiagnosticEntry: newEntries[i]})
+	}
+	p.statusHandler(info, events)
+}
+
+// diagnosticCursor tracks the newest diagnostic buffer entry seen for a device.
+type diagnosticCursor struct {
+	seen   bool
+	newest domain.DiagnosticEntry
+}
+
+// update returns the entries (newest first) that precede the newest entry of
+// the previous readout and remembers the newest one. The first readout only
+// sets the cursor: the buffer's history is in the device info, not replayed
+// as events. If the previous newest entry is no longer among those read, all
+// entries are new.
+func (d *diagnosticCursor) update(entries []domain.DiagnosticEntry) []domain.DiagnosticEntry {
+	if len(entries) == 0 {
+		return nil
+	}
+	seen, previous := d.seen, d.newest
+	d.seen, d.newest = true, entries[0]
+	if !seen {
+		return nil
+	}
+	for i, e := range entries {
+		if e == previous {
+			return entries[:i]
+		}
+	}
+	return entries
+}
+
+// readDeviceInfo reads identification, operating state and recent diagnostic
+// buffer entries. Lists the CPU does not support (LOGO!, S7-200) are left
+// empty; it fails only if none could be read.
+func (c *Client) readDeviceInfo() (*domain.DeviceInfo, error) {
+	info := &domain.DeviceInfo{CPUState: domain.CPUStateUnknown, Time: time.Now()}
+
+	var errs []error
+	read := func(id, index uint16, decode func(*domain.DeviceInfo, [][]byte)) {
+		records, err := c.readSZL(id, index)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("SZL %04X: %w", id, err))
+			return
+		}
+		decode(info, records)
+	}
+	read(szlModuleIdentification, 0, decodeModuleIdentification)
+	read(szlComponentIdentification, 0, decodeComponentIdentification)
+	read(szlCPUState, 0, decodeCPUState)
+	read(szlDiagnosticBuffer, diagnosticBufferEntries, decodeDiagnosticBuffer)
+
+	if len(errs) == 4 {
+		return nil, errors.Join(errs...)
+	}
+	for _, err := range errs {
+		c.logger.Debug().Err(err).Msg("S7 status list not available")
+	}
+	return info, nil
+}
+
+// readSZL reads an SZL list and returns its data records.
+func (c *Client) readSZL(id, index uint16) ([][]byte, error) {
+	payload := make([]byte, 4)
+	binary.BigEndian.PutUint16(payload, id)
+	binary.BigEndian.PutUint16(payload[2:], index)
+	data, err := c.userData(userDataGroupCPU, userDataReadSZL, payload)
+	if err != nil {
+		return nil, err
+	}
+	return szlRecords(data)
+}
+
+// szlRecords splits SZL data into its records. The data starts with the SZL
+// ID, index, record length and record count (2 bytes each).
+func szlRecords(data []byte) ([][]byte, error) {
+	if len(data) < 8 {
+		return nil, fmt.Errorf("%w: SZL response of %d bytes", domain.ErrS7PDUSizeMismatch, len(data))
+	}
+	size := int(binary.BigEndian.Uint16(data[4:]))
+	count := int(binary.BigEndian.Uint16(data[6:]))
+	if size == 0 {
+		return nil, nil
+	}
+	records := make([][]byte, 0, count)
+	for offset := 8; len(records) < count && offset+size <= len(data); offset += size {
+		records = append(records, data[offset:offset+size])
+	}
+	return records, nil
+}
+
+// decodeModuleIdentification reads SZL 0x0011 records: index (2), order
+// number (20), module type (2), version (2+2). Index 1 is the module, 6 the
+// hardware and 7 the firmware.
+func decodeModuleIdentification(info *domain.DeviceInfo, records [][]byte) {
+	for _, r := range records {
+		if len(r) < 28 {
+			continue
+		}
+		version := szlVersion(binary.BigEndian.Uint16(r[24:]), binary.BigEndian.Uint16(r[26:]))
+		switch binary.BigEndian.Uint16(r) {
+		case 0x0001:
+			info.OrderNumber = szlText(r[2:22])
+		case 0x0006:
+			info.HardwareVersion = version
+		case 0x0007:
+			info.FirmwareVersion = version
+		}
+	}
+}
+
+// szlVersion formats a module version: "V" and three numbers when the first
+// byte is 'V', otherwise the plain version number.
+func szlVersion(high, low uint16) string {
+	if high>>8 == 'V' {
+		return fmt.Sprintf("V%d.%d.%d", high&0xFF, low>>8, low&0xFF)
+	}
+	return fmt.Sprintf("%d", low)
+}
+
+// decodeComponentIdentification reads SZL 0x001C records: index (2) and a
+// name of up to 32 characters.
+func decodeComponentIdentification(info *domain.DeviceInfo, records [][]byte) {
+	for _, r := range records {
+		if len(r) < 4 {
+			continue
+		}
+		text := szlText(r[2:])
+		switch binary.BigEndian.Uint16(r) {
+		case 0x0001:
+			info.StationName = text
+		case 0x0002:
+			info.ModuleName = text
+		case 0x0005:
+			info.SerialNumber = text
+		case 0x0007:
+			info.ModuleTypeName = text
+		}
+	}
+}
+
+// decodeCPUState reads the SZL 0x0424 record; the low nibble of its fourth
+// byte is the current operating state.
+func decodeCPUState(info *domain.DeviceInfo, records [][]byte) {
+	if len(records) == 0 || len(records[0]) < 4 {
+		return
+	}
+	info.CPUState = cpuState(records[0][3] & 0x0F)
+}
+
+// cpuState maps an SZL operating state to a CPUState.
+func cpuState(mode byte) domain.CPUState {
+	switch {
+	case mode == 0x08:
+		return domain.CPUStateRun
+	case mode >= 0x01 && mode <= 0x04:
+		return domain.CPUStateStop
+	case mode >= 0x05 && mode <= 0x07:
+		return domain.CPUStateStartup
+	case mode == 0x0A:
+		return domain.CPUStateHold
+	case mode == 0x0D:
+		return domain.CPUStateDefect
+	default:
+		return domain.CPUStateUnknown
+	}
+}
+
+// decodeDiagnosticBuffer reads SZL 0x01A0 records, newest first: event ID
+// (2), event information (10) and a DATE_AND_TIME timestamp (8). Bits 12-15
+// of the event ID are the event class, bit 8 is set for incoming events.
+func decodeDiagnosticBuffer(info *domain.DeviceInfo, records [][]byte) {
+	for _, r := range records {
+		if len(r) < 20 {
+			continue
+		}
+		id := binary.BigEndian.Uint16(r)
+		entry := domain.DiagnosticEntry{
+			EventID:    fmt.Sprintf("16#%04X", id),
+			EventClass: uint8(id >> 12),
+			Incoming:   id&0x0100 != 0,
+			Info:       hex.EncodeToString(r[2:12]),
+		}
+		if ts, err := parseDateAndTime(r[12:20]); err == nil {
+			entry.Time = ts.(time.Time)
+		}
+		info.Diagnostics = append(info.Diagnostics, entry)
+	}
+}
+
+// szlText returns the text of an SZL field, up to the first NUL and without
+// padding.
+func szlText(b []byte) string {
+	if i := bytes.IndexByte(b, 0); i >= 0 {
+		b = b[:i]
+	}
+	return strings.TrimSpace(string(b))
+}
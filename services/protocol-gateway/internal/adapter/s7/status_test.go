@@ -0,0 +1,106 @@
+package s7
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/nexus-edge/protocol-gateway/internal/domain"
+)
+
+// szlData builds SZL response data: header and fixed-size records.
+func szlData(id uint16, records ...[]byte) []byte {
+	data := []byte{byte(id >> 8), byte(id), 0, 0, 0, byte(len(records[0])), 0, byte(len(records))}
+	for _, r := range records {
+		data = append(data, r...)
+	}
+	return data
+}
+
+func TestReadSZLRequest(t *testing.T) {
+	// Same telegram gos7 sends for SZL 0x0424 (PLCGetStatus)
+	want := []byte{3, 0, 0, 33, 2, 240, 128, 50, 7, 0, 0, 0, 1, 0, 8, 0, 8, 0, 1, 18, 4, 17, 68, 1, 0, 255, 9, 0, 4, 4, 36, 0, 0}
+	if got := userDataRequest(userDataGroupCPU, userDataReadSZL, []byte{0x04, 0x24, 0, 0}); !bytes.Equal(got, want) {
+		t.Errorf("SZL request:\n got % x\nwant % x", got, want)
+	}
+}
+
+func TestDecodeDeviceInfo(t *testing.T) {
+	info := &domain.DeviceInfo{}
+
+	module := func(index uint16, order string, high, low uint16) []byte {
+		r := append([]byte{byte(index >> 8), byte(index)}, []byte(order + "                    ")[:20]...)
+		return append(r, 0, 0, byte(high>>8), byte(high), byte(low>>8), byte(low))
+	}
+	records, err := szlRecords(szlData(szlModuleIdentification,
+		module(1, "6ES7 315-2EH14-0AB0", 0, 0), module(6, "", 0, 4), module(7, "", 'V'<<8|3, 0x0205)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	decodeModuleIdentification(info, records)
+
+	component := func(index uint16, text string) []byte {
+		r := make([]byte, 34)
+		r[1] = byte(index)
+		copy(r[2:], text)
+		return r
+	}
+	records, _ = szlRecords(szlData(szlComponentIdentification,
+		component(1, "Line 1"), component(5, "S C-X4U421302009"), component(7, "CPU 315-2 PN/DP")))
+	decodeComponentIdentification(info, records)
+
+	records, _ = szlRecords(szlData(szlCPUState, []byte{0x43, 0x02, 0xFF, 0x08, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0}))
+	decodeCPUState(info, records)
+
+	entry := []byte{0x43, 0x02, 0, 0, 0, 0, 0, 0, 0, 0, 0xFF, 0x84, 0x24, 0x10, 0x16, 0x12, 0x30, 0x45, 0x12, 0x34}
+	records, _ = szlRecords(szlData(szlDiagnosticBuffer, entry))
+	decodeDiagnosticBuffer(info, records)
+
+	if info.OrderNumber != "6ES7 315-2EH14-0AB0" || info.HardwareVersion != "4" || info.FirmwareVersion != "V3.2.5" {
+		t.Errorf("unexpected module identification: %+v", info)
+	}
+	if info.StationName != "Line 1" || info.SerialNumber != "S C-X4U421302009" || info.ModuleTypeName != "CPU 315-2 PN/DP" {
+		t.Errorf("unexpected component identification: %+v", info)
+	}
+	if info.CPUState != domain.CPUStateRun {
+		t.Errorf("CPU state %q, want run", info.CPUState)
+	}
+	if len(info.Diagnostics) != 1 {
+		t.Fatalf("got %d diagnostic entries, want 1", len(info.Diagnostics))
+	}
+	d := info.Diagnostics[0]
+	wantTime := time.Date(2024, 10, 16, 12, 30, 45, 123*int(time.Millisecond), time.UTC)
+	if d.EventID != "16#4302" || d.EventClass != 4 || !d.Incoming || d.Info != "0000000000000000ff84" || !d.Time.Equal(wantTime) {
+		t.Errorf("unexpected diagnostic entry: %+v", d)
+	}
+}
+
+func TestCPUState(t *testing.T) {
+	for mode, want := range map[byte]domain.CPUState{
+		0x08: domain.CPUStateRun, 0x04: domain.CPUStateStop, 0x03: domain.CPUStateStop,
+		0x06: domain.CPUStateStartup, 0x0A: domain.CPUStateHold, 0x00: domain.CPUStateUnknown,
+	} {
+		if got := cpuState(mode); got != want {
+			t.Errorf("cpuState(%#x) = %s, want %s", mode, got, want)
+		}
+	}
+}
+
+func TestDiagnosticCursor(t *testing.T) {
+	e := func(id string) domain.DiagnosticEntry { return domain.DiagnosticEntry{EventID: id} }
+	var cursor diagnosticCursor
+
+	if got := cursor.update([]domain.DiagnosticEntry{e("b"), e("a")}); len(got) != 0 {
+		t.Errorf("first readout should not report events, got %v", got)
+	}
+	if got := cursor.update([]domain.DiagnosticEntry{e("b"), e("a")}); len(got) != 0 {
+		t.Errorf("unchanged buffer should not report events, got %v", got)
+	}
+	got := cursor.update([]domain.DiagnosticEntry{e("d"), e("c"), e("b")})
+	if len(got) != 2 || got[0].EventID != "d" || got[1].EventID != "c" {
+		t.Errorf("expected d and c as new entries, got %v", got)
+	}
+	if got := cursor.update([]domain.DiagnosticEntry{e("g"), e("f"), e("e")}); len(got) != 3 {
+		t.Errorf("expected all entries once the previous newest is gone, got %v", got)
+	}
+}
@@ -0,0 +1,110 @@
+package s7
+
+import (
+	"errors"
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/nexus-edge/protocol-gateway/internal/domain"
+	"github.com/robinson/gos7"
+)
+
+func TestS7ConnectionSettings(t *testing.T) {
+	tests := []struct {
+		name              string
+		conn              domain.ConnectionConfig
+		local, remote     uint16
+		wantInvalidConfig bool
+	}{
+		{"rack and slot", domain.ConnectionConfig{S7Slot: 2}, 0x0100, 0x0102, false},
+		{"op connection", domain.ConnectionConfig{S7Rack: 1, S7Slot: 3, S7ConnectionType: domain.S7ConnectionTypeOP}, 0x0100, 0x0223, false},
+		{"s7-1500", domain.ConnectionConfig{S7Family: "s7-1500"}, 0x0100, 0x0101, false},
+		{"s7-400 other slot", domain.ConnectionConfig{S7Family: "s7-400", S7Slot: 4}, 0x0100, 0x0104, false},
+		{"logo", domain.ConnectionConfig{S7Family: "logo"}, 0x0100, 0x0200, false},
+		{"logo own tsap", domain.ConnectionConfig{S7Family: "logo", S7LocalTSAP: 0x2000}, 0x2000, 0x0200, false},
+		{"s7-200-smart", domain.ConnectionConfig{S7Family: "s7-200-smart"}, 0x1000, 0x0300, false},
+		{"raw tsap", domain.ConnectionConfig{S7LocalTSAP: 0x1000, S7RemoteTSAP: 0x2000}, 0x1000, 0x2000, false},
+		{"unknown family", domain.ConnectionConfig{S7Family: "s5"}, 0, 0, true},
+		{"unknown type", domain.ConnectionConfig{S7ConnectionType: "hmi"}, 0, 0, true},
+		{"slot out of range", domain.ConnectionConfig{S7Slot: 32}, 0, 0, true},
+		{"tsap and slot", domain.ConnectionConfig{S7Slot: 1, S7RemoteTSAP: 0x0201}, 0, 0, true},
+		{"logo with slot", domain.ConnectionConfig{S7Family: "logo", S7Slot: 1}, 0, 0, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			settings, err := tt.conn.S7ConnectionSettings()
+			if tt.wantInvalidConfig {
+				if !errors.Is(err, domain.ErrInvalidConfig) {
+					t.Errorf("expected ErrInvalidConfig, got %v", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("S7ConnectionSettings: %v", err)
+			}
+			if settings.LocalTSAP != tt.local || settings.RemoteTSAP != tt.remote {
+				t.Errorf("got TSAPs %04X/%04X, want %04X/%04X", settings.LocalTSAP, settings.RemoteTSAP, tt.local, tt.remote)
+			}
+		})
+	}
+}
+
+func TestParseS7TSAP(t *testing.T) {
+	for in, want := range map[string]uint16{"10.00": 0x1000, "4D.57": 0x4D57, "0x0201": 0x0201, "256": 0x0100} {
+		if got, err := domain.ParseS7TSAP(in); err != nil || got != want {
+			t.Errorf("ParseS7TSAP(%q) = %04X, %v; want %04X", in, got, err, want)
+		}
+	}
+	for _, in := range []string{"", "100.00", "0x10000", "TSAP"} {
+		if _, err := domain.ParseS7TSAP(in); err == nil {
+			t.Errorf("ParseS7TSAP(%q): expected error", in)
+		}
+	}
+	if got := domain.FormatS7TSAP(0x4D57); got != "4D.57" {
+		t.Errorf("FormatS7TSAP = %q, want 4D.57", got)
+	}
+}
+
+// TestNewTCPClientHandlerWithTSAP checks the TSAPs gos7 sends in the ISO
+// connection request.
+func TestNewTCPClientHandlerWithTSAP(t *testing.T) {
+	for _, tsaps := range [][2]uint16{{0x0100, 0x0102}, {0x1000, 0x0300}, {0x4D57, 0x4D57}} {
+		ln, err := net.Listen("tcp", "127.0.0.1:0")
+		if err != nil {
+			t.Fatal(err)
+		}
+		received := make(chan []byte, 1)
+		go func() {
+			conn, err := ln.Accept()
+			if err != nil {
+				received <- nil
+				return
+			}
+			defer conn.Close()
+			buf := make([]byte, 22)
+			_ = conn.SetDeadline(time.Now().Add(5 * time.Second))
+			if _, err := io.ReadFull(conn, buf); err != nil {
+				buf = nil
+			}
+			received <- buf
+		}()
+
+		handler := gos7.NewTCPClientHandlerWithTSAP(ln.Addr().String(), tsaps[0], tsaps[1])
+		handler.Timeout = 5 * time.Second
+		_ = handler.Connect() // the stand-in closes without confirming
+		handler.Close()
+		ln.Close()
+
+		cr := <-received
+		if cr == nil {
+			t.Fatal("no connection request received")
+		}
+		local := uint16(cr[16])<<8 | uint16(cr[17])
+		remote := uint16(cr[20])<<8 | uint16(cr[21])
+		if local != tsaps[0] || remote != tsaps[1] {
+			t.Errorf("connection request TSAPs %04X/%04X, want %04X/%04X", local, remote, tsaps[0], tsaps[1])
+		}
+	}
+}
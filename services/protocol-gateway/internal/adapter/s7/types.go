@@ -50,6 +50,13 @@ type ClientConfig struct {
 	// S7-300/400: usually 2, S7-1200/1500: usually 0 or 1
 	Slot int
 
+	// LocalTSAP is the gateway's TSAP (default: 0x0100)
+	LocalTSAP uint16
+
+	// RemoteTSAP is the PLC's TSAP. When zero it is derived from Rack and
+	// Slot for a PG connection.
+	RemoteTSAP uint16
+
 	// Timeout is the connection and response timeout
 	Timeout time.Duration
 
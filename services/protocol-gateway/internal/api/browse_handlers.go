@@ -0,0 +1,459 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/nexus-edge/protocol-gateway/internal/adapter/s7"
+	"github.com/nexus-edge/protocol-gateway/internal/domain"
+)
+
+// browseTimeout bounds a single browse request (connect + address space walk).
+const browseTimeout = 30 * time.Second
+
+// maxImportTags caps the number of tags in one import request.
+const maxImportTags = 5000
+
+// PoolProvider resolves the protocol pool of a device.
+// Implemented by domain.ProtocolManager.
+type PoolProvider interface {
+	GetPool(protocol domain.Protocol) (domain.ProtocolPool, bool)
+}
+
+// DeviceUpdater applies a changed device configuration to the running gateway.
+// Implemented by service.MQTTDeviceManager.
+type DeviceUpdater interface {
+	UpdateDeviceFromConfig(device *domain.Device) error
+}
+
+// BrowseRequest is the body of POST /api/browse.
+type BrowseRequest struct {
+	DeviceID string `json:"device_id"`
+	RootPath string `json:"root_path"`
+	Depth    int    `json:"depth"`
+}
+
+// BrowseResponse is the response of POST /api/browse.
+type BrowseResponse struct {
+	DeviceID string                `json:"device_id"`
+	Results  []domain.BrowseResult `json:"results"`
+}
+
+// ImportTag is a selected browse result to import as a tag. Address and
+// DataType are required; the other fields default from Name and Address.
+type ImportTag struct {
+	Address     string            `json:"address"`
+	Name        string            `json:"name"`
+	ID          string            `json:"id,omitempty"`
+	DataType    domain.DataType   `json:"data_type"`
+	TopicSuffix string            `json:"topic_suffix,omitempty"`
+	Unit        string            `json:"unit,omitempty"`
+	AccessMode  domain.AccessMode `json:"access_mode,omitempty"`
+}
+
+// ImportRequest is the body of POST /api/browse/import.
+type ImportRequest struct {
+	DeviceID string      `json:"device_id"`
+	Tags     []ImportTag `json:"tags"`
+	// DryRun validates and returns the tags without adding them to the device
+	DryRun bool `json:"dry_run,omitempty"`
+}
+
+// ImportError reports why a selected tag could not be imported.
+type ImportError struct {
+	Index   int    `json:"index"`
+	Address string `json:"address"`
+	Error   string `json:"error"`
+}
+
+// ImportResponse is the response of POST /api/browse/import.
+type ImportResponse struct {
+	DeviceID string        `json:"device_id"`
+	Imported int           `json:"imported"`
+	DryRun   bool          `json:"dry_run,omitempty"`
+	Tags     []domain.Tag  `json:"tags,omitempty"`
+	Errors   []ImportError `json:"errors,omitempty"`
+}
+
+// SetProtocolPools wires in the protocol pools used for browsing (optional).
+// Pools implementing domain.ProtocolBrowser can be browsed.
+func (h *APIHandler) SetProtocolPools(pools PoolProvider) {
+	h.pools = pools
+}
+
+// SetDeviceUpdater wires in the device updater used for tag import (optional).
+func (h *APIHandler) SetDeviceUpdater(updater DeviceUpdater) {
+	h.deviceUpdater = updater
+}
+
+// BrowseHandler discovers the tags of a device through its protocol browser.
+// POST /api/browse {"device_id": "plc-001", "root_path": "", "depth": 1}
+func (h *APIHandler) BrowseHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req BrowseRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if req.DeviceID == "" {
+		http.Error(w, "Device ID is required", http.StatusBadRequest)
+		return
+	}
+
+	device, ok := h.deviceManager.GetDevice(req.DeviceID)
+	if !ok {
+		http.Error(w, "Device not found", http.StatusNotFound)
+		return
+	}
+
+	browser, ok := h.browserFor(device.Protocol)
+	if !ok {
+		http.Error(w, fmt.Sprintf("Browse is not supported for protocol %s", device.Protocol), http.StatusBadRequest)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), browseTimeout)
+	defer cancel()
+
+	results, err := browser.Browse(ctx, device, req.RootPath, req.Depth)
+	if err != nil {
+		h.logger.Error().Err(err).
+			Str("device_id", device.ID).
+			Str("root_path", req.RootPath).
+			Msg("Browse failed")
+		status := http.StatusInternalServerError
+		if errors.Is(err, domain.ErrS7InvalidDBNumber) || errors.Is(err, domain.ErrOPCUAInvalidNodeID) {
+			status = http.StatusBadRequest
+		}
+		http.Error(w, fmt.Sprintf("Browse failed: %v", err), status)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(BrowseResponse{DeviceID: device.ID, Results: results}); err != nil {
+		h.logger.Error().Err(err).Msg("Failed to encode browse results")
+	}
+}
+
+// ImportTagsHandler turns selected browse results into tags and adds them to
+// a device. Nothing is imported unless every tag is valid.
+// POST /api/browse/import {"device_id": "plc-001", "tags": [{"address": "DB1.DBW0", "name": "Temperature", "data_type": "int16"}]}
+func (h *APIHandler) ImportTagsHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req ImportRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if req.DeviceID == "" {
+		http.Error(w, "Device ID is required", http.StatusBadRequest)
+		return
+	}
+	if len(req.Tags) == 0 || len(req.Tags) > maxImportTags {
+		http.Error(w, fmt.Sprintf("Between 1 and %d tags are required", maxImportTags), http.StatusBadRequest)
+		return
+	}
+	if !req.DryRun && h.deviceUpdater == nil {
+		http.Error(w, "Tag import is not available", http.StatusNotImplemented)
+		return
+	}
+
+	device, ok := h.deviceManager.GetDevice(req.DeviceID)
+	if !ok {
+		http.Error(w, "Device not found", http.StatusNotFound)
+		return
+	}
+
+	tags, importErrs := importTags(device, req.Tags)
+
+	w.Header().Set("Content-Type", "application/json")
+	resp := ImportResponse{DeviceID: device.ID, DryRun: req.DryRun}
+	if len(importErrs) > 0 {
+		resp.Errors = importErrs
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(resp)
+		return
+	}
+
+	if !req.DryRun {
+		if err := h.applyImportedTags(device, tags); err != nil {
+			http.Error(w, fmt.Sprintf("Failed to apply imported tags: %v", err), http.StatusInternalServerError)
+			return
+		}
+		h.logger.Info().
+			Str("device_id", device.ID).
+			Int("tags", len(tags)).
+			Msg("Imported tags from browse results")
+	}
+
+	resp.Imported = len(tags)
+	resp.Tags = tags
+	json.NewEncoder(w).Encode(resp)
+}
+
+// S7SourceImportRequest is the body of POST /api/browse/import-s7-source.
+type S7SourceImportRequest struct {
+	DeviceID string `json:"device_id"`
+	// Source is the text of a TIA Portal (.db/.udt) or STEP 7 AWL block source
+	Source string `json:"source"`
+	// Block selects the data block or UDT (optional for a single data block)
+	Block string `json:"block,omitempty"`
+	// DBNumber is required for symbolic data blocks and UDTs
+	DBNumber    int               `json:"db_number,omitempty"`
+	TopicPrefix string            `json:"topic_prefix,omitempty"`
+	AccessMode  domain.AccessMode `json:"access_mode,omitempty"`
+	DryRun      bool              `json:"dry_run,omitempty"`
+}
+
+// S7SourceImportResponse is the response of POST /api/browse/import-s7-source.
+type S7SourceImportResponse struct {
+	ImportResponse
+	Block    string             `json:"block,omitempty"`
+	DBNumber int                `json:"db_number,omitempty"`
+	Size     int                `json:"size,omitempty"`
+	Skipped  []s7.SkippedMember `json:"skipped,omitempty"`
+}
+
+// S7SourceImportHandler lays out a data block from its TIA Portal or STEP 7
+// source and adds a tag per elementary member to an S7 device. Nothing is
+// imported unless every generated tag is new to the device.
+// POST /api/browse/import-s7-source {"device_id": "plc-001", "source": "DATA_BLOCK DB 10 ...", "dry_run": true}
+func (h *APIHandler) S7SourceImportHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req S7SourceImportRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if req.DeviceID == "" || strings.TrimSpace(req.Source) == "" {
+		http.Error(w, "Device ID and source are required", http.StatusBadRequest)
+		return
+	}
+	switch req.AccessMode {
+	case "", domain.AccessModeReadOnly, domain.AccessModeWriteOnly, domain.AccessModeReadWrite:
+	default:
+		http.Error(w, fmt.Sprintf("Invalid access mode %q (must be read, write or readwrite)", req.AccessMode), http.StatusBadRequest)
+		return
+	}
+	if !req.DryRun && h.deviceUpdater == nil {
+		http.Error(w, "Tag import is not available", http.StatusNotImplemented)
+		return
+	}
+
+	device, ok := h.deviceManager.GetDevice(req.DeviceID)
+	if !ok {
+		http.Error(w, "Device not found", http.StatusNotFound)
+		return
+	}
+	if device.Protocol != domain.ProtocolS7 {
+		http.Error(w, fmt.Sprintf("Source import is not supported for protocol %s", device.Protocol), http.StatusBadRequest)
+		return
+	}
+
+	result, err := s7.ImportSource(req.Source, s7.SourceImportOptions{
+		Block:       req.Block,
+		DBNumber:    req.DBNumber,
+		TopicPrefix: req.TopicPrefix,
+		AccessMode:  req.AccessMode,
+	})
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Source import failed: %v", err), http.StatusBadRequest)
+		return
+	}
+	if len(result.Tags) == 0 || len(result.Tags) > maxImportTags {
+		http.Error(w, fmt.Sprintf("Block %s yields %d tags, between 1 and %d are supported", result.Block, len(result.Tags), maxImportTags), http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	resp := S7SourceImportResponse{
+		ImportResponse: ImportResponse{DeviceID: device.ID, DryRun: req.DryRun},
+		Block:          result.Block,
+		DBNumber:       result.DBNumber,
+		Size:           result.Size,
+		Skipped:        result.Skipped,
+	}
+	if importErrs := tagConflicts(device, result.Tags); len(importErrs) > 0 {
+		resp.Errors = importErrs
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(resp)
+		return
+	}
+
+	if !req.DryRun {
+		if err := h.applyImportedTags(device, result.Tags); err != nil {
+			http.Error(w, fmt.Sprintf("Failed to apply imported tags: %v", err), http.StatusInternalServerError)
+			return
+		}
+		h.logger.Info().
+			Str("device_id", device.ID).
+			Str("block", result.Block).
+			Int("tags", len(result.Tags)).
+			Msg("Imported tags from S7 block source")
+	}
+
+	resp.Imported = len(result.Tags)
+	resp.Tags = result.Tags
+	json.NewEncoder(w).Encode(resp)
+}
+
+// applyImportedTags adds imported tags to a device through the device updater.
+func (h *APIHandler) applyImportedTags(device *domain.Device, tags []domain.Tag) error {
+	updated := *device
+	updated.Tags = append(append(make([]domain.Tag, 0, len(device.Tags)+len(tags)), device.Tags...), tags...)
+	updated.UpdatedAt = time.Now()
+	if err := h.deviceUpdater.UpdateDeviceFromConfig(&updated); err != nil {
+		h.logger.Error().Err(err).Str("device_id", device.ID).Msg("Failed to apply imported tags")
+		return err
+	}
+	return nil
+}
+
+// browserFor returns the protocol browser of a protocol, if its pool has one.
+func (h *APIHandler) browserFor(protocol domain.Protocol) (domain.ProtocolBrowser, bool) {
+	if h.pools == nil {
+		return nil, false
+	}
+	pool, ok := h.pools.GetPool(protocol)
+	if !ok {
+		return nil, false
+	}
+	browser, ok := pool.(domain.ProtocolBrowser)
+	return browser, ok
+}
+
+// importTags builds and validates the tags of an import request for a device.
+// Tag IDs and topic suffixes must be unique across the device's existing tags
+// and the imported ones.
+func importTags(device *domain.Device, selected []ImportTag) ([]domain.Tag, []ImportError) {
+	ids := make(map[string]bool, len(device.Tags)+len(selected))
+	suffixes := make(map[string]bool, len(device.Tags)+len(selected))
+	for _, t := range device.Tags {
+		ids[t.ID] = true
+		suffixes[t.TopicSuffix] = true
+	}
+
+	tags := make([]domain.Tag, 0, len(selected))
+	var importErrs []ImportError
+	for i, sel := range selected {
+		tag, err := importTag(device.Protocol, sel)
+		if err == nil {
+			switch {
+			case ids[tag.ID]:
+				err = fmt.Errorf("tag ID %q already exists on device", tag.ID)
+			case suffixes[tag.TopicSuffix]:
+				err = fmt.Errorf("topic suffix %q already exists on device", tag.TopicSuffix)
+			}
+		}
+		if err != nil {
+			importErrs = append(importErrs, ImportError{Index: i, Address: sel.Address, Error: err.Error()})
+			continue
+		}
+		ids[tag.ID] = true
+		suffixes[tag.TopicSuffix] = true
+		tags = append(tags, tag)
+	}
+
+	return tags, importErrs
+}
+
+// tagConflicts reports generated tags whose ID or topic suffix is already used
+// on the device or by an earlier generated tag.
+func tagConflicts(device *domain.Device, tags []domain.Tag) []ImportError {
+	ids := make(map[string]bool, len(device.Tags)+len(tags))
+	suffixes := make(map[string]bool, len(device.Tags)+len(tags))
+	for _, t := range device.Tags {
+		ids[t.ID] = true
+		suffixes[t.TopicSuffix] = true
+	}
+
+	var importErrs []ImportError
+	for i, tag := range tags {
+		switch {
+		case ids[tag.ID]:
+			importErrs = append(importErrs, ImportError{Index: i, Address: tag.S7Address, Error: fmt.Sprintf("tag ID %q already exists on device", tag.ID)})
+		case suffixes[tag.TopicSuffix]:
+			importErrs = append(importErrs, ImportError{Index: i, Address: tag.S7Address, Error: fmt.Sprintf("topic suffix %q already exists on device", tag.TopicSuffix)})
+		}
+		ids[tag.ID] = true
+		suffixes[tag.TopicSuffix] = true
+	}
+	return importErrs
+}
+
+// importTag builds a validated tag of a protocol from a selected browse result.
+func importTag(protocol domain.Protocol, sel ImportTag) (domain.Tag, error) {
+	address := strings.TrimSpace(sel.Address)
+	if address == "" {
+		return domain.Tag{}, fmt.Errorf("address is required")
+	}
+
+	name := strings.TrimSpace(sel.Name)
+	if name == "" {
+		name = address
+	}
+	tag := domain.Tag{
+		ID:          sel.ID,
+		Name:        name,
+		DataType:    sel.DataType,
+		TopicSuffix: sel.TopicSuffix,
+		Unit:        sel.Unit,
+		AccessMode:  sel.AccessMode,
+		Enabled:     true,
+	}
+	if tag.ID == "" {
+		tag.ID = importTagID(name)
+	}
+	if tag.TopicSuffix == "" {
+		tag.TopicSuffix = tag.ID
+	}
+
+	switch protocol {
+	case domain.ProtocolOPCUA:
+		tag.OPCNodeID = address
+	case domain.ProtocolS7:
+		tag.S7Address = strings.ToUpper(address)
+	default:
+		return domain.Tag{}, fmt.Errorf("%w: tag import for %s", domain.ErrProtocolNotSupported, protocol)
+	}
+
+	switch tag.AccessMode {
+	case "", domain.AccessModeReadOnly, domain.AccessModeWriteOnly, domain.AccessModeReadWrite:
+	default:
+		return domain.Tag{}, fmt.Errorf("invalid access mode %q (must be read, write or readwrite)", tag.AccessMode)
+	}
+
+	if err := tag.ValidateForProtocol(protocol); err != nil {
+		return domain.Tag{}, err
+	}
+	return tag, nil
+}
+
+// importTagID derives a tag ID from a name: characters other than letters,
+// digits, '-' and '_' become '_'.
+func importTagID(name string) string {
+	id := strings.Map(func(r rune) rune {
+		if r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '-' || r == '_' {
+			return r
+		}
+		return '_'
+	}, name)
+	return strings.Trim(id, "_")
+}
@@ -6,6 +6,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"net/http"
+	"slices"
 	"strings"
 	"sync"
 	"time"
@@ -424,6 +425,8 @@ type APIHandler struct {
 	subscriptions    SubscriptionProvider
 	logProvider      LogProvider
 	connectionTester ConnectionTester
+	pools            PoolProvider
+	deviceUpdater    DeviceUpdater
 }
 
 // NewAPIHandler creates a new API handler.
@@ -518,6 +521,55 @@ func (h *APIHandler) GetDeviceHandler(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+// DeviceStatusResponse is the runtime status of a device.
+type DeviceStatusResponse struct {
+	DeviceID string          `json:"device_id"`
+	Protocol domain.Protocol `json:"protocol"`
+
+	// Info is the latest identification, state and diagnostics readout,
+	// for pools implementing domain.DeviceInfoProvider (S7 devices with
+	// s7_status_interval)
+	Info *domain.DeviceInfo `json:"info,omitempty"`
+}
+
+// DeviceStatusHandler returns the runtime status of a device.
+func (h *APIHandler) DeviceStatusHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	id := r.URL.Query().Get("id")
+	if id == "" {
+		http.Error(w, "Device ID is required", http.StatusBadRequest)
+		return
+	}
+
+	device, ok := h.deviceManager.GetDevice(id)
+	if !ok {
+		http.Error(w, "Device not found", http.StatusNotFound)
+		return
+	}
+
+	response := DeviceStatusResponse{DeviceID: device.ID, Protocol: device.Protocol}
+	if h.pools != nil {
+		if pool, exists := h.pools.GetPool(device.Protocol); exists {
+			if provider, ok := pool.(domain.DeviceInfoProvider); ok {
+				if info, ok := provider.DeviceInfo(device.ID); ok {
+					response.Info = info
+				}
+			}
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		h.logger.Error().Err(err).Msg("Failed to encode device status")
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+}
+
 // NOTE: CreateDeviceHandler, UpdateDeviceHandler, DeleteDeviceHandler removed.
 // Device config is now managed by gateway-core and synced via MQTT config subscriber.
 // See internal/service/config_subscriber.go.
@@ -557,8 +609,9 @@ func (h *APIHandler) TestConnectionHandler(w http.ResponseWriter, r *http.Reques
 		return
 	}
 
-	// Attempt a real connection by reading the first tag
-	if len(device.Tags) == 0 {
+	// Attempt a real connection by reading the first tag read from the device
+	tagIndex := slices.IndexFunc(device.Tags, func(t domain.Tag) bool { return !t.IsComputed() })
+	if tagIndex < 0 {
 		http.Error(w, "Device has no tags to test", http.StatusBadRequest)
 		return
 	}
@@ -571,7 +624,7 @@ func (h *APIHandler) TestConnectionHandler(w http.ResponseWriter, r *http.Reques
 	ctx, cancel := context.WithTimeout(r.Context(), timeout)
 	defer cancel()
 
-	tag := device.Tags[0]
+	tag := device.Tags[tagIndex]
 	start := time.Now()
 	_, err := h.connectionTester.ReadTag(ctx, device, &tag)
 	elapsed := time.Since(start)
@@ -9,6 +9,7 @@ import (
 	"time"
 
 	"github.com/nexus-edge/protocol-gateway/internal/adapter/mqtt"
+	"github.com/nexus-edge/protocol-gateway/internal/domain"
 )
 
 type TopicRoute struct {
@@ -62,23 +63,27 @@ func (h *APIHandler) TopicsOverviewHandler(w http.ResponseWriter, r *http.Reques
 	routes := make([]TopicRoute, 0)
 	devices := h.deviceManager.GetDevices()
 	for _, device := range devices {
-		for _, tag := range device.Tags {
-			full := device.UNSPrefix
-			if tag.TopicSuffix != "" {
-				full = full + "/" + tag.TopicSuffix
+		for i := range device.Tags {
+			// Bit-field sub-points have their own topics
+			tags := append([]*domain.Tag{&device.Tags[i]}, device.Tags[i].BitFieldTags()...)
+			for _, tag := range tags {
+				full := device.UNSPrefix
+				if tag.TopicSuffix != "" {
+					full = full + "/" + tag.TopicSuffix
+				}
+				routes = append(routes, TopicRoute{
+					DeviceID:    device.ID,
+					DeviceName:  device.Name,
+					Protocol:    string(device.Protocol),
+					Enabled:     device.Enabled && tag.Enabled,
+					UNSPrefix:   device.UNSPrefix,
+					TagID:       tag.ID,
+					TagName:     tag.Name,
+					TopicSuffix: tag.TopicSuffix,
+					FullTopic:   full,
+					AccessMode:  string(tag.AccessMode),
+				})
 			}
-			routes = append(routes, TopicRoute{
-				DeviceID:    device.ID,
-				DeviceName:  device.Name,
-				Protocol:    string(device.Protocol),
-				Enabled:     device.Enabled && tag.Enabled,
-				UNSPrefix:   device.UNSPrefix,
-				TagID:       tag.ID,
-				TagName:     tag.Name,
-				TopicSuffix: tag.TopicSuffix,
-				FullTopic:   full,
-				AccessMode:  string(tag.AccessMode),
-			})
 		}
 	}
 
@@ -0,0 +1,93 @@
+// Package cli provides the gateway's command-line subcommands.
+package cli
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/nexus-edge/protocol-gateway/internal/adapter/config"
+	"github.com/nexus-edge/protocol-gateway/internal/adapter/s7"
+	"github.com/nexus-edge/protocol-gateway/internal/domain"
+	"gopkg.in/yaml.v3"
+)
+
+// S7ImportUsage is the one-line usage of the s7-import subcommand.
+const S7ImportUsage = "s7-import -source FILE [-block NAME] [-db N] [-topic-prefix PREFIX] [-access-mode MODE] [-format yaml|json]"
+
+// S7Import runs "gateway s7-import": it lays out a data block or UDT from a
+// TIA Portal (.db/.udt) or STEP 7 AWL source and prints a tag per elementary
+// member, as a devices.yaml "tags:" list or as JSON. Members without a tag
+// data type are reported on stderr. Returns the process exit code.
+func S7Import(args []string, stdout, stderr io.Writer) int {
+	fs := flag.NewFlagSet("s7-import", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	sourcePath := fs.String("source", "", "block source file (\"-\" for stdin)")
+	block := fs.String("block", "", "data block or UDT to import (default: the only data block)")
+	dbNumber := fs.Int("db", 0, "data block number (required for symbolic blocks and UDTs)")
+	topicPrefix := fs.String("topic-prefix", "", "prefix for the generated topic suffixes")
+	accessMode := fs.String("access-mode", "", "access mode of the generated tags (read, write, readwrite)")
+	format := fs.String("format", "yaml", "output format: yaml or json")
+	fs.Usage = func() {
+		fmt.Fprintf(stderr, "usage: gateway %s\n", S7ImportUsage)
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if *sourcePath == "" || (*format != "yaml" && *format != "json") {
+		fs.Usage()
+		return 2
+	}
+	switch domain.AccessMode(*accessMode) {
+	case "", domain.AccessModeReadOnly, domain.AccessModeWriteOnly, domain.AccessModeReadWrite:
+	default:
+		fmt.Fprintf(stderr, "invalid access mode %q (must be read, write or readwrite)\n", *accessMode)
+		return 2
+	}
+
+	var src []byte
+	var err error
+	if *sourcePath == "-" {
+		src, err = io.ReadAll(os.Stdin)
+	} else {
+		src, err = os.ReadFile(*sourcePath)
+	}
+	if err != nil {
+		fmt.Fprintf(stderr, "failed to read source: %v\n", err)
+		return 1
+	}
+
+	result, err := s7.ImportSource(string(src), s7.SourceImportOptions{
+		Block:       *block,
+		DBNumber:    *dbNumber,
+		TopicPrefix: *topicPrefix,
+		AccessMode:  domain.AccessMode(*accessMode),
+	})
+	if err != nil {
+		fmt.Fprintf(stderr, "import failed: %v\n", err)
+		return 1
+	}
+
+	for _, skipped := range result.Skipped {
+		fmt.Fprintf(stderr, "skipped %s (%s at byte %d): no matching data type\n", skipped.Path, skipped.Type, skipped.Offset)
+	}
+	fmt.Fprintf(stderr, "%s: %d tags in DB%d (%d bytes)\n", result.Block, len(result.Tags), result.DBNumber, result.Size)
+
+	if *format == "json" {
+		enc := json.NewEncoder(stdout)
+		enc.SetIndent("", "  ")
+		err = enc.Encode(result)
+	} else {
+		err = yaml.NewEncoder(stdout).Encode(struct {
+			Tags []config.TagConfig `yaml:"tags"`
+		}{config.TagsToConfig(result.Tags)})
+	}
+	if err != nil {
+		fmt.Fprintf(stderr, "failed to write tags: %v\n", err)
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,42 @@
+package cli
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestS7Import_PrintsDeviceTags(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "line.db")
+	src := `DATA_BLOCK "Line_DB"
+   STRUCT
+      Run : Bool;
+      Mode : Byte;
+      Speed : Real;   // Setpoint
+   END_STRUCT;
+BEGIN
+END_DATA_BLOCK
+`
+	if err := os.WriteFile(path, []byte(src), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	var stdout, stderr bytes.Buffer
+	if code := S7Import([]string{"-source", path, "-db", "3", "-topic-prefix", "line"}, &stdout, &stderr); code != 0 {
+		t.Fatalf("exit code %d: %s", code, stderr.String())
+	}
+	for _, want := range []string{"tags:", "s7_address: DB3.DBX0.0", "s7_address: DB3.DBD2", "topic_suffix: line/Speed", "description: Setpoint (REAL)"} {
+		if !strings.Contains(stdout.String(), want) {
+			t.Errorf("output missing %q:\n%s", want, stdout.String())
+		}
+	}
+	if !strings.Contains(stderr.String(), "skipped Mode (BYTE at byte 1)") {
+		t.Errorf("expected skipped member on stderr, got %q", stderr.String())
+	}
+
+	if code := S7Import([]string{"-source", path}, &stdout, &stderr); code != 1 {
+		t.Errorf("expected exit code 1 without a DB number, got %d", code)
+	}
+}
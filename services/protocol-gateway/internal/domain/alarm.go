@@ -0,0 +1,81 @@
+// Package domain contains core business entities.
+package domain
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+)
+
+// AlarmEvent is a state change of an alarm condition reported by a device
+// (e.g., an OPC UA AlarmConditionType event). Every event carries the full
+// condition state, so the latest event on an alarm topic is its current state.
+type AlarmEvent struct {
+	// DeviceID identifies the source device
+	DeviceID string `json:"device_id"`
+
+	// Topic is the full MQTT topic for this alarm: {uns_prefix}/alarms/{condition}
+	Topic string `json:"-"`
+
+	// ConditionID identifies the condition instance (the OPC UA ConditionId node)
+	ConditionID string `json:"condition_id"`
+
+	// ConditionName is the configured name of the condition (e.g., "HighTemperature")
+	ConditionName string `json:"condition_name,omitempty"`
+
+	// EventID identifies this event; acknowledge and confirm refer to it (hex)
+	EventID string `json:"event_id"`
+
+	// EventType is the event type node (e.g., the ID of ExclusiveLevelAlarmType)
+	EventType string `json:"event_type,omitempty"`
+
+	// SourceNode and SourceName identify the object the alarm is about
+	SourceNode string `json:"source_node,omitempty"`
+	SourceName string `json:"source_name,omitempty"`
+
+	// Message is the alarm text
+	Message string `json:"message,omitempty"`
+
+	// Severity is the urgency, 1 (lowest) to 1000 (highest)
+	Severity uint16 `json:"severity"`
+
+	// Active, Acked and Confirmed are the condition's state machine states
+	Active    bool `json:"active"`
+	Acked     bool `json:"acked"`
+	Confirmed bool `json:"confirmed"`
+
+	// Retain is false once the condition no longer needs operator attention
+	Retain bool `json:"retain"`
+
+	// Time is when the event occurred at the source
+	Time time.Time `json:"ts"`
+
+	// ReceiveTime is when the server received the event
+	ReceiveTime *time.Time `json:"receive_ts,omitempty"`
+}
+
+// ToJSON serializes the alarm event to JSON bytes.
+func (a *AlarmEvent) ToJSON() ([]byte, error) {
+	return json.Marshal(a)
+}
+
+// AlarmAction is an operator action on an alarm condition.
+type AlarmAction string
+
+const (
+	AlarmActionAcknowledge AlarmAction = "acknowledge"
+	AlarmActionConfirm     AlarmAction = "confirm"
+)
+
+// IsValid checks if the alarm action is supported.
+func (a AlarmAction) IsValid() bool {
+	return a == AlarmActionAcknowledge || a == AlarmActionConfirm
+}
+
+// AlarmAcknowledger is implemented by protocol pools that support operator
+// actions on alarm conditions.
+type AlarmAcknowledger interface {
+	// AcknowledgeAlarm acknowledges or confirms a condition. An empty eventID
+	// refers to the latest event received for the condition.
+	AcknowledgeAlarm(ctx context.Context, device *Device, conditionID, eventID string, action AlarmAction, comment string) error
+}
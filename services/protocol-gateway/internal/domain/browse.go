@@ -0,0 +1,49 @@
+// Package domain contains core business entities.
+package domain
+
+import "context"
+
+// BrowseResult is a node, data block or other addressable item discovered on a
+// device, in a protocol-independent shape for tree display and tag import.
+type BrowseResult struct {
+	// ID is a suggested tag ID (sanitized node or block name)
+	ID string `json:"id"`
+
+	// Name is a human-readable name
+	Name string `json:"name"`
+
+	// Address is the protocol-specific address (OPC UA NodeID, S7 "DB1", ...)
+	// and the value to pass back as root_path to browse below this item
+	Address string `json:"address"`
+
+	// DataType is the detected data type, empty if the item is not a tag or its
+	// type has no DataType equivalent
+	DataType DataType `json:"data_type,omitempty"`
+
+	// AccessMode is the detected access mode, if the protocol reports it
+	AccessMode AccessMode `json:"access_mode,omitempty"`
+
+	// Unit is the engineering unit, if the protocol reports it
+	Unit string `json:"unit,omitempty"`
+
+	// Path is the hierarchical path of names from the browse root to this item
+	Path []string `json:"path"`
+
+	// Metadata contains protocol-specific extras (OPC UA: node class,
+	// namespace; S7: DB number, size)
+	Metadata map[string]string `json:"metadata,omitempty"`
+
+	// Children is the number of child items, or -1 if the item has children
+	// that were not enumerated because they lie below the requested depth
+	Children int `json:"children"`
+}
+
+// ProtocolBrowser is implemented by protocol pools that can discover the
+// addressable items of a device.
+type ProtocolBrowser interface {
+	// Browse returns the items below rootPath, up to depth levels deep
+	// (depth < 1 means immediate children only). rootPath is protocol-specific:
+	//   OPC UA: NodeID (e.g., "ns=2;s=MyFolder"); "" for the Objects folder
+	//   S7:     "DB1" for a single data block; "" for all data blocks
+	Browse(ctx context.Context, device *Device, rootPath string, depth int) ([]BrowseResult, error)
+}
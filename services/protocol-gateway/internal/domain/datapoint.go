@@ -3,6 +3,7 @@ package domain
 
 import (
 	"encoding/json"
+	"reflect"
 	"sync"
 	"time"
 )
@@ -81,6 +82,10 @@ type DataPoint struct {
 	// Priority indicates QoS tier (0=telemetry/default, 1=control, 2=safety/alarm)
 	Priority uint8 `json:"priority,omitempty"`
 
+	// Backfilled marks a value recovered from the device's history after a
+	// connection gap; Timestamp is then the original source timestamp
+	Backfilled bool `json:"backfilled,omitempty"`
+
 	// Metadata contains additional context
 	Metadata map[string]string `json:"meta,omitempty"`
 }
@@ -88,10 +93,11 @@ type DataPoint struct {
 // MQTTPayload represents the compact payload format for MQTT publishing.
 // Uses short field names to minimize bandwidth.
 type MQTTPayload struct {
-	Value     interface{} `json:"v"`           // Value
-	Unit      string      `json:"u,omitempty"` // Unit
-	Quality   Quality     `json:"q"`           // Quality
-	Timestamp int64       `json:"ts"`          // Unix timestamp (milliseconds)
+	Value     interface{} `json:"v"`            // Value
+	Unit      string      `json:"u,omitempty"`  // Unit
+	Quality   Quality     `json:"q"`            // Quality
+	Timestamp int64       `json:"ts"`           // Unix timestamp (milliseconds)
+	Backfill  bool        `json:"bf,omitempty"` // Recovered from history after a gap
 }
 
 // ToMQTTPayload converts the DataPoint to a compact MQTT payload.
@@ -101,6 +107,7 @@ func (dp *DataPoint) ToMQTTPayload() MQTTPayload {
 		Unit:      dp.Unit,
 		Quality:   dp.Quality,
 		Timestamp: dp.Timestamp.UnixMilli(),
+		Backfill:  dp.Backfilled,
 	}
 }
 
@@ -115,6 +122,7 @@ func (dp *DataPoint) ToJSON() ([]byte, error) {
 type SparkplugBPayload struct {
 	Timestamp int64              `json:"timestamp"`
 	Metrics   []SparkplugBMetric `json:"metrics"`
+	Seq       *uint64            `json:"seq,omitempty"` // Absent on NDEATH
 }
 
 // SparkplugBMetric represents a single metric in Sparkplug B format.
@@ -124,8 +132,34 @@ type SparkplugBMetric struct {
 	Timestamp int64       `json:"timestamp"`
 	DataType  string      `json:"dataType"`
 	Value     interface{} `json:"value"`
+	IsNull    bool        `json:"is_null,omitempty"`
+
+	// IsHistorical marks backfilled values that must not update the current value
+	IsHistorical bool `json:"is_historical,omitempty"`
+
+	// Properties carries metric properties such as "Quality"
+	Properties map[string]interface{} `json:"properties,omitempty"`
 }
 
+// Sparkplug B metric data types (Sparkplug specification 3.0, section 6.4.16).
+const (
+	SparkplugInt8     = "Int8"
+	SparkplugInt16    = "Int16"
+	SparkplugInt32    = "Int32"
+	SparkplugInt64    = "Int64"
+	SparkplugUInt8    = "UInt8"
+	SparkplugUInt16   = "UInt16"
+	SparkplugUInt32   = "UInt32"
+	SparkplugUInt64   = "UInt64"
+	SparkplugFloat    = "Float"
+	SparkplugDouble   = "Double"
+	SparkplugBoolean  = "Boolean"
+	SparkplugString   = "String"
+	SparkplugDateTime = "DateTime"
+	SparkplugText     = "Text"
+	SparkplugBytes    = "Bytes"
+)
+
 // DataPointBatch represents a batch of data points for efficient processing.
 type DataPointBatch struct {
 	DeviceID  string       `json:"device_id"`
@@ -166,6 +200,7 @@ func AcquireDataPoint(deviceID, tagID, topic string, value interface{}, unit str
 	dp.LatencyMs = nil
 	dp.StalenessMs = nil
 	dp.Priority = 0
+	dp.Backfilled = false
 	dp.Metadata = nil
 	return dp
 }
@@ -187,6 +222,19 @@ func ReleaseDataPoint(dp *DataPoint) {
 	dataPointPool.Put(dp)
 }
 
+// CopyValue returns a copy of a data point value that stays valid after the
+// DataPoint is released. Array values are slices whose backing array may be
+// reused, so they are copied; other values are returned as is.
+func CopyValue(value interface{}) interface{} {
+	v := reflect.ValueOf(value)
+	if v.Kind() != reflect.Slice || v.IsNil() {
+		return value
+	}
+	dup := reflect.MakeSlice(v.Type(), v.Len(), v.Len())
+	reflect.Copy(dup, v)
+	return dup.Interface()
+}
+
 // WithRawValue sets the raw value and returns the DataPoint for chaining.
 func (dp *DataPoint) WithRawValue(raw interface{}) *DataPoint {
 	dp.RawValue = raw
@@ -219,6 +267,15 @@ func (dp *DataPoint) WithPublishTimestamp(ts time.Time) *DataPoint {
 	return dp
 }
 
+// AsBackfill marks the DataPoint as recovered from history and sets its
+// timestamp to the original source timestamp.
+func (dp *DataPoint) AsBackfill(sourceTS time.Time) *DataPoint {
+	dp.Backfilled = true
+	dp.Timestamp = sourceTS
+	dp.SourceTimestamp = &sourceTS
+	return dp
+}
+
 // WithPriority sets the QoS priority tier.
 func (dp *DataPoint) WithPriority(priority uint8) *DataPoint {
 	dp.Priority = priority
@@ -4,7 +4,11 @@ package domain
 
 import (
 	"fmt"
+	"strconv"
+	"strings"
 	"time"
+
+	"github.com/nexus-edge/protocol-gateway/internal/expr"
 )
 
 // DeviceStatus represents the current operational status of a device.
@@ -120,6 +124,31 @@ type ConnectionConfig struct {
 	// StopBits is the number of stop bits for RTU connections (1 or 2)
 	StopBits int `json:"stop_bits,omitempty" yaml:"stop_bits,omitempty"`
 
+	// TurnaroundDelay is the minimum bus silence between an RTU response and the
+	// next request on the same serial line. Needed by slow slaves and by RS-485
+	// adapters with slow direction switching. Zero means the 3.5 character gap only.
+	// All devices sharing a serial port share one bus; the largest delay wins.
+	TurnaroundDelay time.Duration `json:"turnaround_delay,omitempty" yaml:"turnaround_delay,omitempty"`
+
+	// Framing selects the frame format for modbus-tcp devices: "mbap" (default,
+	// standard Modbus TCP) or "rtu" (RTU frames with CRC tunnelled over TCP, as
+	// spoken by transparent serial-to-Ethernet converters). RTU-over-TCP devices
+	// with the same host:port always share one connection.
+	Framing ModbusFraming `json:"framing,omitempty" yaml:"framing,omitempty"`
+
+	// ShareConnection multiplexes all modbus-tcp devices with the same host:port
+	// over one TCP connection, addressing each by its unit ID. Use it for Modbus
+	// gateways that only accept a few concurrent connections.
+	ShareConnection bool `json:"share_connection,omitempty" yaml:"share_connection,omitempty"`
+
+	// ModbusProfile names a quirk profile (see ModbusProfiles) used as the
+	// base of the device's request batching settings.
+	ModbusProfile string `json:"modbus_profile,omitempty" yaml:"modbus_profile,omitempty"`
+
+	// ModbusBatching overrides how reads are merged into requests for this
+	// device; set fields take precedence over the profile.
+	ModbusBatching *ModbusBatching `json:"modbus_batching,omitempty" yaml:"modbus_batching,omitempty"`
+
 	// === OPC UA Settings ===
 
 	// OPCEndpointURL is the full OPC UA endpoint URL (e.g., "opc.tcp://localhost:4840")
@@ -177,6 +206,29 @@ type ConnectionConfig struct {
 	// Falls back to polling if subscription setup fails.
 	OPCUseSubscriptions bool `json:"opc_use_subscriptions" yaml:"opc_use_subscriptions"`
 
+	// OPCAlarmsEnabled monitors Alarms & Conditions events (AlarmConditionType and
+	// subtypes) and publishes them to {uns_prefix}/alarms/{source}/{condition}.
+	// Events are delivered through the device's subscription, so this requires
+	// OPCUseSubscriptions.
+	OPCAlarmsEnabled bool `json:"opc_alarms_enabled,omitempty" yaml:"opc_alarms_enabled,omitempty"`
+
+	// OPCAlarmNotifiers are the event notifier nodes monitored for alarms
+	// (default: the Server object, i=2253, which reports all events of the server)
+	OPCAlarmNotifiers []string `json:"opc_alarm_notifiers,omitempty" yaml:"opc_alarm_notifiers,omitempty"`
+
+	// OPCAlarmMinSeverity drops alarm events below this severity (1-1000; 0 = all)
+	OPCAlarmMinSeverity uint16 `json:"opc_alarm_min_severity,omitempty" yaml:"opc_alarm_min_severity,omitempty"`
+
+	// OPCHistoryBackfill reads the server's history (HistoryReadRawModified)
+	// for the gap after a subscription is recovered and publishes the missed
+	// values as backfilled. Requires OPCUseSubscriptions.
+	OPCHistoryBackfill bool `json:"opc_history_backfill,omitempty" yaml:"opc_history_backfill,omitempty"`
+
+	// OPCPreserveTypes publishes arrays, structures (decoded with the server's
+	// DataTypeDefinition), enums and LocalizedText as JSON arrays and objects.
+	// Off by default: values are published as returned by the OPC UA stack.
+	OPCPreserveTypes bool `json:"opc_preserve_types,omitempty" yaml:"opc_preserve_types,omitempty"`
+
 	// === S7 (Siemens) Settings ===
 
 	// S7Rack is the rack number of the PLC (usually 0)
@@ -191,6 +243,67 @@ type ConnectionConfig struct {
 	// S7Timeout is the connection timeout for S7 (default: 10s)
 	S7Timeout time.Duration `json:"s7_timeout,omitempty" yaml:"s7_timeout,omitempty"`
 
+	// S7Family names a connection preset (see S7Families) for the CPU family:
+	// rack/slot, connection type or the TSAPs LOGO! and S7-200 CPUs expect.
+	// The other S7 connection fields override the preset.
+	S7Family string `json:"s7_family,omitempty" yaml:"s7_family,omitempty"`
+
+	// S7ConnectionType is the connection resource requested from the CPU:
+	// "pg" (default), "op" or "basic"
+	S7ConnectionType S7ConnectionType `json:"s7_connection_type,omitempty" yaml:"s7_connection_type,omitempty"`
+
+	// S7LocalTSAP is the gateway's TSAP (default 0x0100)
+	S7LocalTSAP uint16 `json:"s7_local_tsap,omitempty" yaml:"s7_local_tsap,omitempty"`
+
+	// S7RemoteTSAP is the PLC's TSAP. It replaces the TSAP derived from
+	// connection type, rack and slot, for CPUs addressed by TSAP only.
+	S7RemoteTSAP uint16 `json:"s7_remote_tsap,omitempty" yaml:"s7_remote_tsap,omitempty"`
+
+	// S7StatusInterval is how often the CPU's identification, operating state
+	// and diagnostic buffer are read (0 = never, minimum 1s)
+	S7StatusInterval time.Duration `json:"s7_status_interval,omitempty" yaml:"s7_status_interval,omitempty"`
+
+	// === MQTT Source Settings ===
+
+	// MQTTBrokerURL is the source broker URL (e.g., "tcp://edge-broker:1883" or "ssl://...").
+	// Devices with the same broker URL, credentials and TLS files share one client.
+	MQTTBrokerURL string `json:"mqtt_broker_url,omitempty" yaml:"mqtt_broker_url,omitempty"`
+
+	// MQTTUsername for broker authentication
+	MQTTUsername string `json:"mqtt_username,omitempty" yaml:"mqtt_username,omitempty"`
+
+	// MQTTPassword for broker authentication
+	MQTTPassword string `json:"mqtt_password,omitempty" yaml:"mqtt_password,omitempty"`
+
+	// MQTTClientIDPrefix is the client ID prefix of the shared broker client (default: "gw-source")
+	MQTTClientIDPrefix string `json:"mqtt_client_id_prefix,omitempty" yaml:"mqtt_client_id_prefix,omitempty"`
+
+	// MQTTQoS is the QoS of the device's wildcard subscription (0, 1 or 2)
+	MQTTQoS byte `json:"mqtt_qos,omitempty" yaml:"mqtt_qos,omitempty"`
+
+	// MQTTCleanSession starts the shared broker client with a clean session
+	MQTTCleanSession bool `json:"mqtt_clean_session,omitempty" yaml:"mqtt_clean_session,omitempty"`
+
+	// MQTTStalenessTimeout marks the device stale when no message has arrived
+	// for this long: cached values turn uncertain. Zero disables the check.
+	MQTTStalenessTimeout time.Duration `json:"mqtt_staleness_timeout,omitempty" yaml:"mqtt_staleness_timeout,omitempty"`
+
+	// MQTTSourcePrefix is the topic prefix of the device; the gateway subscribes
+	// to "{prefix}/#" and routes messages to tags by their MQTTTopicMatch.
+	MQTTSourcePrefix string `json:"mqtt_source_prefix,omitempty" yaml:"mqtt_source_prefix,omitempty"`
+
+	// MQTTTLSEnabled enables TLS for the source broker
+	MQTTTLSEnabled bool `json:"mqtt_tls_enabled,omitempty" yaml:"mqtt_tls_enabled,omitempty"`
+
+	// MQTTTLSCAFile path for the CA certificate of the source broker
+	MQTTTLSCAFile string `json:"mqtt_tls_ca_file,omitempty" yaml:"mqtt_tls_ca_file,omitempty"`
+
+	// MQTTTLSCertFile path for the client certificate
+	MQTTTLSCertFile string `json:"mqtt_tls_cert_file,omitempty" yaml:"mqtt_tls_cert_file,omitempty"`
+
+	// MQTTTLSKeyFile path for the client private key
+	MQTTTLSKeyFile string `json:"mqtt_tls_key_file,omitempty" yaml:"mqtt_tls_key_file,omitempty"`
+
 	// === Circuit Breaker Override ===
 
 	// CircuitBreaker provides per-device circuit breaker overrides.
@@ -199,6 +312,240 @@ type ConnectionConfig struct {
 	CircuitBreaker *CircuitBreakerConfig `json:"circuit_breaker,omitempty" yaml:"circuit_breaker,omitempty"`
 }
 
+// ModbusFraming is the frame format used on a Modbus TCP connection.
+type ModbusFraming string
+
+const (
+	ModbusFramingMBAP ModbusFraming = "mbap" // Modbus TCP application protocol header
+	ModbusFramingRTU  ModbusFraming = "rtu"  // RTU frames (address + PDU + CRC) over TCP
+)
+
+// ModbusBatching holds per-device request batching settings and quirks of
+// Modbus devices. All fields are optional — zero values mean "use the
+// profile or pool default".
+type ModbusBatching struct {
+	// MaxRegisters caps the registers per read request (1-125, default 100)
+	MaxRegisters uint16 `json:"max_registers,omitempty" yaml:"max_registers,omitempty"`
+
+	// MaxCoils caps the coils or discrete inputs per read request (1-2000, default 1000)
+	MaxCoils uint16 `json:"max_coils,omitempty" yaml:"max_coils,omitempty"`
+
+	// MaxGap is the largest run of unconfigured addresses a read may span
+	// to merge two tags (default 10 registers, 32 coils)
+	MaxGap uint16 `json:"max_gap,omitempty" yaml:"max_gap,omitempty"`
+
+	// NoGap only merges tags at adjacent addresses, for devices that reject
+	// reads touching undefined addresses
+	NoGap bool `json:"no_gap,omitempty" yaml:"no_gap,omitempty"`
+
+	// Alignment makes reads start at a multiple of this many addresses and
+	// span a whole number of them (e.g. 2 for devices that reject reads
+	// splitting 32-bit values)
+	Alignment uint16 `json:"alignment,omitempty" yaml:"alignment,omitempty"`
+
+	// InterRequestDelay is the minimum pause between the end of one request
+	// and the start of the next, for devices that drop back-to-back requests
+	InterRequestDelay time.Duration `json:"inter_request_delay,omitempty" yaml:"inter_request_delay,omitempty"`
+
+	// OneBasedAddressing treats tag addresses as 1-based register numbers (as
+	// printed in many device manuals): address 1 is sent as 0 on the wire
+	OneBasedAddressing bool `json:"one_based_addressing,omitempty" yaml:"one_based_addressing,omitempty"`
+}
+
+// ModbusProfiles are the named quirk profiles for ModbusProfile.
+var ModbusProfiles = map[string]ModbusBatching{
+	// standard is the pool default batching
+	"standard": {},
+	// conservative suits devices that reject large or sparse reads
+	"conservative": {MaxRegisters: 60, MaxCoils: 256, NoGap: true},
+	// no-gap suits devices that fail reads crossing undefined addresses
+	"no-gap": {NoGap: true},
+	// aligned suits devices that only accept reads on even register boundaries
+	"aligned": {Alignment: 2},
+	// slow suits slow serial slaves and overloaded gateways
+	"slow": {MaxRegisters: 32, InterRequestDelay: 50 * time.Millisecond},
+	// one-based suits register maps documented as 1-based register numbers
+	"one-based": {OneBasedAddressing: true},
+}
+
+// ModbusBatchingSettings returns the device's batching settings: the named
+// profile with the ModbusBatching overrides applied.
+func (c *ConnectionConfig) ModbusBatchingSettings() (ModbusBatching, error) {
+	var settings ModbusBatching
+	if c.ModbusProfile != "" {
+		profile, ok := ModbusProfiles[c.ModbusProfile]
+		if !ok {
+			return settings, fmt.Errorf("%w: unknown modbus_profile %q", ErrInvalidConfig, c.ModbusProfile)
+		}
+		settings = profile
+	}
+
+	if o := c.ModbusBatching; o != nil {
+		if o.MaxRegisters > 0 {
+			settings.MaxRegisters = o.MaxRegisters
+		}
+		if o.MaxCoils > 0 {
+			settings.MaxCoils = o.MaxCoils
+		}
+		if o.MaxGap > 0 {
+			settings.MaxGap = o.MaxGap
+		}
+		if o.Alignment > 0 {
+			settings.Alignment = o.Alignment
+		}
+		if o.InterRequestDelay > 0 {
+			settings.InterRequestDelay = o.InterRequestDelay
+		}
+		settings.NoGap = settings.NoGap || o.NoGap
+		settings.OneBasedAddressing = settings.OneBasedAddressing || o.OneBasedAddressing
+	}
+
+	switch {
+	case settings.MaxRegisters > 125:
+		return settings, fmt.Errorf("%w: max_registers %d is out of range (must be 1-125)", ErrInvalidConfig, settings.MaxRegisters)
+	case settings.MaxCoils > 2000:
+		return settings, fmt.Errorf("%w: max_coils %d is out of range (must be 1-2000)", ErrInvalidConfig, settings.MaxCoils)
+	case settings.Alignment > 125 || settings.MaxRegisters > 0 && settings.Alignment > settings.MaxRegisters:
+		return settings, fmt.Errorf("%w: alignment %d exceeds the registers per read", ErrInvalidConfig, settings.Alignment)
+	case settings.InterRequestDelay < 0:
+		return settings, fmt.Errorf("%w: inter_request_delay must not be negative", ErrInvalidConfig)
+	}
+	return settings, nil
+}
+
+// S7ConnectionType is the connection resource an S7 client occupies on the CPU.
+type S7ConnectionType string
+
+const (
+	S7ConnectionTypePG    S7ConnectionType = "pg"    // programming device
+	S7ConnectionTypeOP    S7ConnectionType = "op"    // operator panel
+	S7ConnectionTypeBasic S7ConnectionType = "basic" // basic (S7 basic communication)
+)
+
+// s7ConnectionTypeCodes are the high bytes of the remote TSAP per connection type.
+var s7ConnectionTypeCodes = map[S7ConnectionType]uint16{
+	S7ConnectionTypePG:    0x01,
+	S7ConnectionTypeOP:    0x02,
+	S7ConnectionTypeBasic: 0x03,
+}
+
+// S7DefaultLocalTSAP is the local TSAP sent when none is configured.
+const S7DefaultLocalTSAP uint16 = 0x0100
+
+// S7Connection holds the connection addressing of an S7 device.
+type S7Connection struct {
+	// ConnectionType is the connection resource requested from the CPU
+	ConnectionType S7ConnectionType
+
+	// Rack and Slot locate the CPU (rack 0-7, slot 0-31)
+	Rack int
+	Slot int
+
+	// LocalTSAP is the gateway's TSAP
+	LocalTSAP uint16
+
+	// RemoteTSAP is the PLC's TSAP: connection type in the high byte,
+	// rack*32+slot in the low byte unless set explicitly
+	RemoteTSAP uint16
+}
+
+// S7Families are the connection presets for S7Family. They are the factory
+// defaults of each family; LOGO! and S7-200 TSAPs must match the connection
+// configured in the PLC and can be overridden per device.
+var S7Families = map[string]S7Connection{
+	// s7-300 CPUs sit in slot 2 of rack 0
+	"s7-300": {Slot: 2},
+	// s7-400 CPUs usually sit in slot 3 (after the power supply)
+	"s7-400": {Slot: 3},
+	// s7-1200 and s7-1500 need PUT/GET access enabled and a non-optimized DB
+	"s7-1200": {Slot: 1},
+	"s7-1500": {Slot: 1},
+	// s7-200 is reached through the CP 243-1 wizard's default TSAPs
+	"s7-200": {LocalTSAP: 0x4D57, RemoteTSAP: 0x4D57},
+	// s7-200-smart uses the TSAPs of its built-in Ethernet port
+	"s7-200-smart": {LocalTSAP: 0x1000, RemoteTSAP: 0x0300},
+	// logo matches the default server connection of LOGO! 0BA7/0BA8
+	"logo": {LocalTSAP: 0x0100, RemoteTSAP: 0x0200},
+}
+
+// S7ConnectionSettings returns the device's connection addressing: the named
+// family preset with the explicit S7 connection fields applied, and the
+// TSAPs filled in.
+func (c *ConnectionConfig) S7ConnectionSettings() (S7Connection, error) {
+	var settings S7Connection
+	if c.S7Family != "" {
+		preset, ok := S7Families[c.S7Family]
+		if !ok {
+			return settings, fmt.Errorf("%w: unknown s7_family %q", ErrInvalidConfig, c.S7Family)
+		}
+		settings = preset
+	}
+
+	addressed := c.S7Rack != 0 || c.S7Slot != 0 || c.S7ConnectionType != ""
+	if addressed && c.S7RemoteTSAP != 0 {
+		return settings, fmt.Errorf("%w: s7_remote_tsap replaces s7_rack, s7_slot and s7_connection_type; set one or the other", ErrInvalidConfig)
+	}
+	if addressed && settings.RemoteTSAP != 0 {
+		return settings, fmt.Errorf("%w: s7_family %q is addressed by TSAP; use s7_remote_tsap instead of s7_rack, s7_slot and s7_connection_type", ErrInvalidConfig, c.S7Family)
+	}
+	if c.S7Rack != 0 || c.S7Slot != 0 {
+		settings.Rack, settings.Slot = c.S7Rack, c.S7Slot
+	}
+	if c.S7ConnectionType != "" {
+		settings.ConnectionType = c.S7ConnectionType
+	}
+	if c.S7LocalTSAP != 0 {
+		settings.LocalTSAP = c.S7LocalTSAP
+	}
+	if c.S7RemoteTSAP != 0 {
+		settings.RemoteTSAP = c.S7RemoteTSAP
+	}
+	if settings.ConnectionType == "" {
+		settings.ConnectionType = S7ConnectionTypePG
+	}
+
+	code, ok := s7ConnectionTypeCodes[settings.ConnectionType]
+	switch {
+	case !ok:
+		return settings, fmt.Errorf("%w: unknown s7_connection_type %q (must be pg, op or basic)", ErrInvalidConfig, settings.ConnectionType)
+	case settings.Rack < 0 || settings.Rack > 7:
+		return settings, fmt.Errorf("%w: s7_rack %d is out of range (must be 0-7)", ErrInvalidConfig, settings.Rack)
+	case settings.Slot < 0 || settings.Slot > 31:
+		return settings, fmt.Errorf("%w: s7_slot %d is out of range (must be 0-31)", ErrInvalidConfig, settings.Slot)
+	}
+	if settings.LocalTSAP == 0 {
+		settings.LocalTSAP = S7DefaultLocalTSAP
+	}
+	if settings.RemoteTSAP == 0 {
+		settings.RemoteTSAP = code<<8 | uint16(settings.Rack*0x20+settings.Slot)
+	}
+	return settings, nil
+}
+
+// ParseS7TSAP parses a TSAP written in Siemens byte notation ("10.00", as in
+// TIA Portal and LOGO!Soft Comfort), as hex ("0x1000") or as a decimal number.
+func ParseS7TSAP(s string) (uint16, error) {
+	s = strings.TrimSpace(s)
+	if high, low, ok := strings.Cut(s, "."); ok {
+		h, errH := strconv.ParseUint(high, 16, 8)
+		l, errL := strconv.ParseUint(low, 16, 8)
+		if errH != nil || errL != nil {
+			return 0, fmt.Errorf("%w: invalid TSAP %q", ErrInvalidConfig, s)
+		}
+		return uint16(h<<8 | l), nil
+	}
+	v, err := strconv.ParseUint(s, 0, 16)
+	if err != nil {
+		return 0, fmt.Errorf("%w: invalid TSAP %q", ErrInvalidConfig, s)
+	}
+	return uint16(v), nil
+}
+
+// FormatS7TSAP formats a TSAP in Siemens byte notation (e.g. "10.00").
+func FormatS7TSAP(tsap uint16) string {
+	return fmt.Sprintf("%02X.%02X", tsap>>8, tsap&0xFF)
+}
+
 // CircuitBreakerConfig holds per-device circuit breaker settings.
 // All fields are optional — zero values mean "use pool default".
 type CircuitBreakerConfig struct {
@@ -248,6 +595,125 @@ func (d *Device) Validate() error {
 			return fmt.Errorf("invalid tag %q for device %q: %w", d.Tags[i].ID, d.ID, err)
 		}
 	}
+	if err := d.validateComputedTags(); err != nil {
+		return err
+	}
+	switch d.Protocol {
+	case ProtocolModbusTCP, ProtocolModbusRTU:
+		return d.validateModbusBatching()
+	case ProtocolMQTT:
+		return d.validateMQTTSource()
+	case ProtocolS7:
+		if _, err := d.Connection.S7ConnectionSettings(); err != nil {
+			return fmt.Errorf("%w (device %q)", err, d.ID)
+		}
+		if d.Connection.S7StatusInterval != 0 && d.Connection.S7StatusInterval < time.Second {
+			return fmt.Errorf("%w: s7_status_interval must be at least 1s for device %q", ErrInvalidConfig, d.ID)
+		}
+	case ProtocolOPCUA:
+		if d.Connection.OPCHistoryBackfill && !d.Connection.OPCUseSubscriptions {
+			return fmt.Errorf("%w: opc_history_backfill requires opc_use_subscriptions for device %q", ErrInvalidConfig, d.ID)
+		}
+		return d.validateOPCAlarms()
+	}
+	return nil
+}
+
+// validateComputedTags checks that the references of computed tags to tags
+// of the same device name existing tags other than themselves. References to
+// other devices are resolved at runtime, as those may be configured later.
+func (d *Device) validateComputedTags() error {
+	ids := make(map[string]bool, len(d.Tags))
+	for i := range d.Tags {
+		ids[d.Tags[i].ID] = true
+		for _, sub := range d.Tags[i].BitFieldTags() {
+			ids[sub.ID] = true
+		}
+	}
+	for i := range d.Tags {
+		tag := &d.Tags[i]
+		if !tag.IsComputed() {
+			continue
+		}
+		e, err := expr.Parse(tag.Expression)
+		if err != nil {
+			return fmt.Errorf("invalid tag %q for device %q: %w", tag.ID, d.ID, err)
+		}
+		for _, ref := range e.Refs() {
+			if ref.Device != "" && ref.Device != d.ID {
+				continue
+			}
+			if ref.Tag == tag.ID {
+				return fmt.Errorf("%w: computed tag %q of device %q references itself", ErrInvalidConfig, tag.ID, d.ID)
+			}
+			if !ids[ref.Tag] {
+				return fmt.Errorf("%w: computed tag %q of device %q references unknown tag %q", ErrInvalidConfig, tag.ID, d.ID, ref.Tag)
+			}
+		}
+	}
+	return nil
+}
+
+// validateModbusBatching checks the batching settings of a Modbus device.
+// With one-based addressing, address 0 does not exist.
+func (d *Device) validateModbusBatching() error {
+	settings, err := d.Connection.ModbusBatchingSettings()
+	if err != nil {
+		return fmt.Errorf("%w (device %q)", err, d.ID)
+	}
+	if settings.OneBasedAddressing {
+		for i := range d.Tags {
+			if d.Tags[i].Address == 0 && !d.Tags[i].IsComputed() {
+				return fmt.Errorf("%w: tag %q has address 0 but device %q uses one-based addressing", ErrInvalidConfig, d.Tags[i].ID, d.ID)
+			}
+		}
+	}
+	return nil
+}
+
+// validateOPCAlarms checks the Alarms & Conditions settings of an OPC UA device.
+func (d *Device) validateOPCAlarms() error {
+	conn := &d.Connection
+	if !conn.OPCAlarmsEnabled {
+		return nil
+	}
+	if !conn.OPCUseSubscriptions {
+		return fmt.Errorf("%w: opc_alarms_enabled requires opc_use_subscriptions for device %q", ErrInvalidConfig, d.ID)
+	}
+	if conn.OPCAlarmMinSeverity > 1000 {
+		return fmt.Errorf("%w: opc_alarm_min_severity %d is out of range (must be 0-1000) for device %q", ErrInvalidConfig, conn.OPCAlarmMinSeverity, d.ID)
+	}
+	return nil
+}
+
+// validateMQTTSource checks the source settings of an MQTT device. The
+// device's wildcard subscription must not match its own UNS output topics,
+// or the gateway would re-ingest what it publishes when the source broker is
+// also the output broker.
+func (d *Device) validateMQTTSource() error {
+	conn := &d.Connection
+	if conn.MQTTBrokerURL == "" {
+		return fmt.Errorf("%w: mqtt_broker_url is required for MQTT device %q", ErrInvalidConfig, d.ID)
+	}
+	if conn.MQTTQoS > 2 {
+		return fmt.Errorf("%w: mqtt_qos %d is out of range (must be 0-2) for MQTT device %q", ErrInvalidConfig, conn.MQTTQoS, d.ID)
+	}
+	prefix := strings.Trim(conn.MQTTSourcePrefix, "/")
+	if prefix == "" {
+		return fmt.Errorf("%w: mqtt_source_prefix is required for MQTT device %q", ErrInvalidConfig, d.ID)
+	}
+	if strings.ContainsAny(prefix, "+#") {
+		return fmt.Errorf("%w: mqtt_source_prefix %q must not contain wildcards", ErrInvalidConfig, prefix)
+	}
+
+	uns := strings.Trim(d.UNSPrefix, "/")
+	for i := range d.Tags {
+		topic := uns + "/" + strings.Trim(d.Tags[i].TopicSuffix, "/")
+		if strings.HasPrefix(topic, prefix+"/") {
+			return fmt.Errorf("%w: mqtt_source_prefix %q overlaps output topic %q of device %q (the gateway would re-ingest its own output)",
+				ErrInvalidConfig, prefix, topic, d.ID)
+		}
+	}
 	return nil
 }
 
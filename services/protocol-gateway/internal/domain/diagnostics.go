@@ -0,0 +1,104 @@
+// Package domain contains core business entities.
+package domain
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// CPUState is the operating state of a PLC CPU.
+type CPUState string
+
+const (
+	CPUStateRun     CPUState = "run"
+	CPUStateStop    CPUState = "stop"
+	CPUStateStartup CPUState = "startup"
+	CPUStateHold    CPUState = "hold"
+	CPUStateDefect  CPUState = "defect"
+	CPUStateUnknown CPUState = "unknown"
+)
+
+// DeviceInfo is the identification, operating state and recent diagnostics
+// read from a device. It is published retained, so the topic always holds
+// the latest readout.
+type DeviceInfo struct {
+	// DeviceID identifies the device
+	DeviceID string `json:"device_id"`
+
+	// Topic is the full MQTT topic: {uns_prefix}/info
+	Topic string `json:"-"`
+
+	// OrderNumber is the article number of the CPU module (e.g. "6ES7 315-2EH14-0AB0")
+	OrderNumber string `json:"order_number,omitempty"`
+
+	// HardwareVersion and FirmwareVersion of the CPU module
+	HardwareVersion string `json:"hardware_version,omitempty"`
+	FirmwareVersion string `json:"firmware_version,omitempty"`
+
+	// ModuleTypeName is the CPU type (e.g. "CPU 315-2 PN/DP")
+	ModuleTypeName string `json:"module_type_name,omitempty"`
+
+	// StationName and ModuleName are the names configured for the station and module
+	StationName string `json:"station_name,omitempty"`
+	ModuleName  string `json:"module_name,omitempty"`
+
+	// SerialNumber of the CPU module
+	SerialNumber string `json:"serial_number,omitempty"`
+
+	// CPUState is the operating state of the CPU
+	CPUState CPUState `json:"cpu_state"`
+
+	// Diagnostics are the most recent diagnostic buffer entries, newest first
+	Diagnostics []DiagnosticEntry `json:"diagnostics,omitempty"`
+
+	// Time is when the information was read
+	Time time.Time `json:"ts"`
+}
+
+// ToJSON serializes the device information to JSON bytes.
+func (i *DeviceInfo) ToJSON() ([]byte, error) {
+	return json.Marshal(i)
+}
+
+// DiagnosticEntry is an entry of a device's diagnostic buffer.
+type DiagnosticEntry struct {
+	// EventID identifies the event, in the device's notation (S7: "16#4302")
+	EventID string `json:"event_id"`
+
+	// EventClass groups related events (S7: the high nibble of the event ID)
+	EventClass uint8 `json:"event_class"`
+
+	// Incoming is true for an entering event, false for a leaving one
+	Incoming bool `json:"incoming"`
+
+	// Info is the event-specific information (hex)
+	Info string `json:"info,omitempty"`
+
+	// Time is when the event occurred, as recorded by the device
+	Time time.Time `json:"ts"`
+}
+
+// DiagnosticEvent is a diagnostic buffer entry that appeared since the
+// previous readout of a device.
+type DiagnosticEvent struct {
+	// DeviceID identifies the source device
+	DeviceID string `json:"device_id"`
+
+	// Topic is the full MQTT topic: {uns_prefix}/diagnostics
+	Topic string `json:"-"`
+
+	DiagnosticEntry
+}
+
+// ToJSON serializes the diagnostic event to JSON bytes.
+func (e *DiagnosticEvent) ToJSON() ([]byte, error) {
+	return json.Marshal(e)
+}
+
+// DeviceInfoProvider is implemented by protocol pools that read device
+// identification and state.
+type DeviceInfoProvider interface {
+	// DeviceInfo returns the latest information read from a device, or false
+	// if none has been read.
+	DeviceInfo(deviceID string) (*DeviceInfo, bool)
+}
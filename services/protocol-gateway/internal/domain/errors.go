@@ -70,6 +70,10 @@ var (
 	ErrOPCUANodeNotFound       = errors.New("opcua: node not found")
 	ErrOPCUAAccessDenied       = errors.New("opcua: access denied")
 	ErrOPCUAWriteNotPermitted  = errors.New("opcua: write not permitted")
+	ErrOPCUAMethodCallFailed   = errors.New("opcua: method call failed")
+	ErrOPCUAConditionNotFound  = errors.New("opcua: alarm condition not found")
+	ErrOPCUAHistoryReadFailed  = errors.New("opcua: history read failed")
+	ErrOPCUAInvalidArgument    = errors.New("opcua: invalid method argument")
 )
 
 // S7 (Siemens) specific errors.
@@ -89,6 +93,7 @@ var (
 	ErrS7ObjectNotExist        = errors.New("s7: object does not exist")
 	ErrS7HardwareFault         = errors.New("s7: hardware fault")
 	ErrS7AccessingNotAllowed   = errors.New("s7: accessing not allowed")
+	ErrS7InvalidSource         = errors.New("s7: invalid block source")
 )
 
 // Write operation errors.
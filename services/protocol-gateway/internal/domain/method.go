@@ -0,0 +1,21 @@
+// Package domain contains core business entities.
+package domain
+
+import "context"
+
+// MethodArgument is a named input or output argument of a device method.
+type MethodArgument struct {
+	// Name is the argument name declared by the method (may be empty)
+	Name string `json:"name,omitempty"`
+
+	// Value is the argument value (arrays and structures as JSON arrays and objects)
+	Value interface{} `json:"value"`
+}
+
+// MethodCaller is implemented by protocol pools that can call methods on
+// devices (e.g., OPC UA Methods such as "start batch" or "reset counter").
+type MethodCaller interface {
+	// CallMethod calls a method of an object with positional input arguments
+	// and returns the method's output arguments.
+	CallMethod(ctx context.Context, device *Device, objectID, methodID string, inputs []interface{}) ([]MethodArgument, error)
+}
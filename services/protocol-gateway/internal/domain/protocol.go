@@ -20,6 +20,10 @@ type ProtocolPool interface {
 	// WriteTag writes a value to a tag on a device.
 	WriteTag(ctx context.Context, device *Device, tag *Tag, value interface{}) error
 
+	// WriteTags writes several values to tags on a device as one request
+	// where the protocol allows, returning one error per write.
+	WriteTags(ctx context.Context, device *Device, writes []TagWrite) []error
+
 	// Close closes all connections in the pool.
 	Close() error
 
@@ -27,6 +31,12 @@ type ProtocolPool interface {
 	HealthCheck(ctx context.Context) error
 }
 
+// TagWrite is a single write in a multi-tag write.
+type TagWrite struct {
+	Tag   *Tag
+	Value interface{}
+}
+
 // ProtocolManager manages multiple protocol pools and routes operations
 // to the appropriate pool based on device protocol.
 // Thread-safe for concurrent access.
@@ -84,6 +94,19 @@ func (pm *ProtocolManager) WriteTag(ctx context.Context, device *Device, tag *Ta
 	return pool.WriteTag(ctx, device, tag, value)
 }
 
+// WriteTags routes a multi-tag write to the appropriate pool. Thread-safe.
+func (pm *ProtocolManager) WriteTags(ctx context.Context, device *Device, writes []TagWrite) []error {
+	pool, exists := pm.GetPool(device.Protocol)
+	if !exists {
+		errs := make([]error, len(writes))
+		for i := range errs {
+			errs[i] = ErrProtocolNotSupported
+		}
+		return errs
+	}
+	return pool.WriteTags(ctx, device, writes)
+}
+
 // Close closes all protocol pools. Thread-safe.
 func (pm *ProtocolManager) Close() error {
 	pm.mu.Lock()
@@ -3,7 +3,10 @@ package domain
 
 import (
 	"fmt"
+	"strings"
 	"time"
+
+	"github.com/nexus-edge/protocol-gateway/internal/expr"
 )
 
 // DataType represents the data type of a tag value.
@@ -20,6 +23,19 @@ const (
 	DataTypeFloat32 DataType = "float32"
 	DataTypeFloat64 DataType = "float64"
 	DataTypeString  DataType = "string"
+
+	// S7-native types (Siemens S7 tags only; DataTypeString is an S7 STRING there)
+	DataTypeWString     DataType = "wstring"       // WSTRING: 4-byte header + UTF-16 characters
+	DataTypeDTL         DataType = "dtl"           // DTL: 12-byte date and time, published as a timestamp
+	DataTypeDateAndTime DataType = "date_and_time" // DATE_AND_TIME: 8-byte BCD date and time, published as a timestamp
+	DataTypeTime        DataType = "time"          // TIME: signed 32-bit milliseconds, published as milliseconds
+	DataTypeS5Time      DataType = "s5time"        // S5TIME: 16-bit BCD timer value, published as milliseconds
+)
+
+// S7 string length limits.
+const (
+	S7StringDefaultLength = 254   // Default and maximum declared length of an S7 STRING
+	S7WStringMaxLength    = 16382 // Maximum declared length of an S7 WSTRING
 )
 
 // RegisterType represents the Modbus register type.
@@ -42,6 +58,32 @@ const (
 	ByteOrderMidLitEndian ByteOrder = "mid_little"    // CDAB (byte swap)
 )
 
+// StringEncoding is the character encoding of a Modbus string tag.
+type StringEncoding string
+
+const (
+	StringEncodingASCII StringEncoding = "ascii" // 7-bit ASCII (default)
+	StringEncodingUTF8  StringEncoding = "utf8"  // UTF-8
+)
+
+// StringTrim specifies how padding is removed from a Modbus string tag.
+type StringTrim string
+
+const (
+	StringTrimNull  StringTrim = "null"  // Cut at the first NUL (default)
+	StringTrimSpace StringTrim = "space" // Cut at the first NUL, then trim spaces; writes pad with spaces
+	StringTrimNone  StringTrim = "none"  // Keep every byte, including padding
+)
+
+// BitField names one bit of a bit-field tag's register.
+type BitField struct {
+	// Name identifies the sub-point (letters, digits, '-' and '_')
+	Name string `json:"name" yaml:"name"`
+
+	// Bit is the bit position in the register (0-15, 0 = least significant)
+	Bit uint8 `json:"bit" yaml:"bit"`
+}
+
 // AccessMode defines read/write access for a tag.
 type AccessMode string
 
@@ -51,6 +93,13 @@ const (
 	AccessModeReadWrite AccessMode = "readwrite"
 )
 
+// Priority tiers for Tag.Priority and DataPoint.Priority.
+const (
+	PriorityTelemetry uint8 = 0 // Regular data; polls may be skipped under back-pressure
+	PriorityControl   uint8 = 1 // Operational data; reserved polling capacity
+	PrioritySafety    uint8 = 2 // Alarms and trips; never skipped, highest MQTT QoS
+)
+
 // Tag represents a single data point to be read from/written to a device.
 type Tag struct {
 	// ID is the unique identifier for this tag within the device
@@ -80,6 +129,27 @@ type Tag struct {
 	// BitPosition is the bit position for boolean values within a register (0-15)
 	BitPosition *uint8 `json:"bit_position,omitempty" yaml:"bit_position,omitempty"`
 
+	// StringEncoding is the encoding of a string tag: "ascii" (default) or
+	// "utf8". A string tag holds two bytes per register over RegisterCount
+	// registers, first character in the high byte.
+	StringEncoding StringEncoding `json:"string_encoding,omitempty" yaml:"string_encoding,omitempty"`
+
+	// StringByteSwap puts the first character of each register in the low byte
+	StringByteSwap bool `json:"string_byte_swap,omitempty" yaml:"string_byte_swap,omitempty"`
+
+	// StringTrim specifies how padding is removed: "null" (default), "space"
+	// or "none"
+	StringTrim StringTrim `json:"string_trim,omitempty" yaml:"string_trim,omitempty"`
+
+	// ArrayLength reads the tag as a fixed-length array of DataType elements in
+	// consecutive registers (or consecutive coils/discrete inputs for bool)
+	ArrayLength uint16 `json:"array_length,omitempty" yaml:"array_length,omitempty"`
+
+	// BitFields expands a 16-bit register tag into named boolean sub-points
+	// with tag ID and topic suffix "{id}.{name}" / "{topic_suffix}.{name}"
+	// (see BitFieldTags)
+	BitFields []BitField `json:"bit_fields,omitempty" yaml:"bit_fields,omitempty"`
+
 	// ScaleFactor is multiplied with the raw value to get the engineering value
 	ScaleFactor float64 `json:"scale_factor,omitempty" yaml:"scale_factor,omitempty"`
 
@@ -103,6 +173,10 @@ type Tag struct {
 	// DeadbandValue is the threshold for deadband filtering
 	DeadbandValue float64 `json:"deadband_value,omitempty" yaml:"deadband_value,omitempty"`
 
+	// MaxSilence overrides the polling service's heartbeat for deadband-filtered
+	// tags: an unchanged value is republished once it has been silent this long
+	MaxSilence *time.Duration `json:"max_silence,omitempty" yaml:"max_silence,omitempty"`
+
 	// Enabled indicates whether this tag should be actively polled
 	Enabled bool `json:"enabled" yaml:"enabled"`
 
@@ -153,6 +227,38 @@ type Tag struct {
 	// If provided, this will be parsed to extract Area, DBNumber, Offset, and BitOffset
 	S7Address string `json:"s7_address,omitempty" yaml:"s7_address,omitempty"`
 
+	// S7StringLength is the declared length of a STRING or WSTRING tag in
+	// characters (e.g., 20 for STRING[20]). Defaults to 254.
+	S7StringLength uint16 `json:"s7_string_length,omitempty" yaml:"s7_string_length,omitempty"`
+
+	// === Computed Tag Fields ===
+
+	// Expression makes this a computed (virtual) tag: instead of being read
+	// from the device, its value is evaluated from other tags whenever one of
+	// them is updated (see package expr for the syntax). {tag_id} references a
+	// tag of this device, {device_id/tag_id} a tag of another device. The
+	// result is converted to DataType; scaling and protocol fields don't apply.
+	Expression string `json:"expression,omitempty" yaml:"expression,omitempty"`
+
+	// === MQTT Source Specific Fields ===
+
+	// MQTTTopicMatch is the topic under the device's MQTTSourcePrefix that carries
+	// this tag (e.g., "Energy" or "Drive/Speed"). "+" matches one topic level.
+	// Defaults to TopicSuffix.
+	MQTTTopicMatch string `json:"mqtt_topic_match,omitempty" yaml:"mqtt_topic_match,omitempty"`
+
+	// MQTTPayloadFormat is how the message payload is decoded: "json" (default),
+	// "string" (plain text value) or "raw" (big-endian binary of DataType)
+	MQTTPayloadFormat MQTTPayloadFormat `json:"mqtt_payload_format,omitempty" yaml:"mqtt_payload_format,omitempty"`
+
+	// MQTTValuePath locates the value in a JSON payload (e.g., "$.value" or
+	// "$.data.temp"). Defaults to the whole payload if it is a scalar, else "$.value".
+	MQTTValuePath string `json:"mqtt_value_path,omitempty" yaml:"mqtt_value_path,omitempty"`
+
+	// MQTTTimestampPath optionally locates the source timestamp in a JSON payload:
+	// RFC 3339 or Unix time in s, ms, µs or ns
+	MQTTTimestampPath string `json:"mqtt_timestamp_path,omitempty" yaml:"mqtt_timestamp_path,omitempty"`
+
 	// Metadata contains additional key-value pairs for this tag
 	Metadata map[string]string `json:"metadata,omitempty" yaml:"metadata,omitempty"`
 }
@@ -169,6 +275,15 @@ const (
 	S7AreaC  S7Area = "C"  // Counters
 )
 
+// MQTTPayloadFormat is the payload encoding of an MQTT-sourced tag.
+type MQTTPayloadFormat string
+
+const (
+	MQTTPayloadJSON   MQTTPayloadFormat = "json"   // JSON document, value located by MQTTValuePath
+	MQTTPayloadString MQTTPayloadFormat = "string" // Plain text, e.g. "21.5" or "true"
+	MQTTPayloadRaw    MQTTPayloadFormat = "raw"    // Big-endian binary of the tag's data type
+)
+
 // DeadbandType specifies how deadband filtering is applied.
 type DeadbandType string
 
@@ -207,6 +322,32 @@ func (t *Tag) ValidateForProtocol(protocol Protocol) error {
 	if t.DataType == "" {
 		return fmt.Errorf("data type is required for tag %s", t.ID)
 	}
+	if t.PollInterval != nil && *t.PollInterval < 100*time.Millisecond {
+		return fmt.Errorf("%w: tag %s", ErrPollIntervalTooShort, t.ID)
+	}
+	switch t.DeadbandType {
+	case "", DeadbandTypeNone, DeadbandTypeAbsolute, DeadbandTypePercent:
+	default:
+		return fmt.Errorf("invalid deadband type %q for tag %s (must be none, absolute or percent)", t.DeadbandType, t.ID)
+	}
+	if t.DeadbandValue < 0 {
+		return fmt.Errorf("deadband value must not be negative for tag %s", t.ID)
+	}
+	if t.MaxSilence != nil && *t.MaxSilence < 0 {
+		return fmt.Errorf("max silence must not be negative for tag %s", t.ID)
+	}
+	if t.Priority > PrioritySafety {
+		return fmt.Errorf("priority %d is out of range (must be 0-2) for tag %s", t.Priority, t.ID)
+	}
+	if t.IsComputed() {
+		return t.validateComputed()
+	}
+	if (t.ArrayLength > 0 || len(t.BitFields) > 0) && protocol != ProtocolModbusTCP && protocol != ProtocolModbusRTU {
+		return fmt.Errorf("array length and bit fields are only supported for Modbus tags (tag %s)", t.ID)
+	}
+	if t.IsS7NativeType() && protocol != ProtocolS7 {
+		return fmt.Errorf("data type %s is only supported for S7 tags (tag %s)", t.DataType, t.ID)
+	}
 
 	switch protocol {
 	case ProtocolModbusTCP, ProtocolModbusRTU:
@@ -214,9 +355,19 @@ func (t *Tag) ValidateForProtocol(protocol Protocol) error {
 			return fmt.Errorf("register type is required for Modbus tag %s", t.ID)
 		}
 
-		// Validate bit position if specified (must be 0-7)
-		if t.BitPosition != nil && *t.BitPosition > 7 {
-			return fmt.Errorf("bit position %d is out of range (must be 0-7) for Modbus tag %s", *t.BitPosition, t.ID)
+		// Validate bit position if specified (0-15 within a register, 0-7 otherwise)
+		if t.BitPosition != nil {
+			maxBit := uint8(7)
+			if t.RegisterType == RegisterTypeHoldingRegister || t.RegisterType == RegisterTypeInputRegister {
+				maxBit = 15
+			}
+			if *t.BitPosition > maxBit {
+				return fmt.Errorf("bit position %d is out of range (must be 0-%d) for Modbus tag %s", *t.BitPosition, maxBit, t.ID)
+			}
+		}
+
+		if err := t.validateModbusLayout(); err != nil {
+			return err
 		}
 
 		expectedCount := t.ExpectedRegisterCount()
@@ -238,8 +389,23 @@ func (t *Tag) ValidateForProtocol(protocol Protocol) error {
 		if t.S7Address == "" {
 			return fmt.Errorf("s7 address is required for S7 tag %s", t.ID)
 		}
+		if err := t.validateS7String(); err != nil {
+			return err
+		}
 	case ProtocolMQTT:
-		// No extra required fields beyond the common ones.
+		switch t.MQTTPayloadFormat {
+		case "", MQTTPayloadJSON, MQTTPayloadString, MQTTPayloadRaw:
+		default:
+			return fmt.Errorf("invalid mqtt payload format %q for tag %s (must be json, string or raw)", t.MQTTPayloadFormat, t.ID)
+		}
+		if strings.Contains(t.MQTTTopicMatch, "#") {
+			return fmt.Errorf("mqtt topic match %q must not contain '#' for tag %s", t.MQTTTopicMatch, t.ID)
+		}
+		for _, path := range []string{t.MQTTValuePath, t.MQTTTimestampPath} {
+			if path != "" && !strings.HasPrefix(path, "$") {
+				return fmt.Errorf("mqtt path %q must start with '$' for tag %s", path, t.ID)
+			}
+		}
 	default:
 		return fmt.Errorf("unsupported protocol %q for tag %s", protocol, t.ID)
 	}
@@ -252,18 +418,184 @@ func (t *Tag) ValidateForProtocol(protocol Protocol) error {
 	return nil
 }
 
-// ExpectedRegisterCount returns the number of 16-bit registers needed for the data type.
+// validateModbusLayout validates the string, array and bit-field settings of
+// a Modbus tag.
+func (t *Tag) validateModbusLayout() error {
+	isRegister := t.RegisterType == RegisterTypeHoldingRegister || t.RegisterType == RegisterTypeInputRegister
+
+	if t.DataType == DataTypeString {
+		if !isRegister {
+			return fmt.Errorf("string tag %s must use holding or input registers", t.ID)
+		}
+		if t.RegisterCount == 0 {
+			return fmt.Errorf("register count is required for string tag %s", t.ID)
+		}
+		switch t.StringEncoding {
+		case "", StringEncodingASCII, StringEncodingUTF8:
+		default:
+			return fmt.Errorf("invalid string encoding %q for tag %s (must be ascii or utf8)", t.StringEncoding, t.ID)
+		}
+		switch t.StringTrim {
+		case "", StringTrimNull, StringTrimSpace, StringTrimNone:
+		default:
+			return fmt.Errorf("invalid string trim %q for tag %s (must be null, space or none)", t.StringTrim, t.ID)
+		}
+	}
+
+	if t.ArrayLength > 0 {
+		switch {
+		case t.DataType == DataTypeString || len(t.BitFields) > 0 || t.BitPosition != nil:
+			return fmt.Errorf("array tag %s cannot be a string, bit field or bit position", t.ID)
+		case !isRegister && t.DataType != DataTypeBool:
+			return fmt.Errorf("coil and discrete input array tag %s must be bool", t.ID)
+		case isRegister && t.DataType == DataTypeBool:
+			return fmt.Errorf("bool array tag %s must use coils or discrete inputs", t.ID)
+		}
+	}
+
+	if len(t.BitFields) > 0 {
+		if !isRegister || (t.DataType != DataTypeInt16 && t.DataType != DataTypeUInt16) {
+			return fmt.Errorf("bit-field tag %s must be an int16 or uint16 register", t.ID)
+		}
+		seen := make(map[string]bool, len(t.BitFields))
+		for _, field := range t.BitFields {
+			if !validBitFieldName(field.Name) {
+				return fmt.Errorf("invalid bit field name %q for tag %s (letters, digits, '-' and '_')", field.Name, t.ID)
+			}
+			if seen[field.Name] {
+				return fmt.Errorf("duplicate bit field %q for tag %s", field.Name, t.ID)
+			}
+			seen[field.Name] = true
+			if field.Bit > 15 {
+				return fmt.Errorf("bit %d of field %q is out of range (must be 0-15) for tag %s", field.Bit, field.Name, t.ID)
+			}
+		}
+	}
+
+	return nil
+}
+
+// IsComputed reports whether the tag is a computed tag, evaluated from other
+// tags instead of being read from the device.
+func (t *Tag) IsComputed() bool {
+	return t.Expression != ""
+}
+
+// validateComputed validates a computed tag: its result type, access mode
+// and expression. References are checked by the device (see
+// Device.validateComputedTags).
+func (t *Tag) validateComputed() error {
+	switch t.DataType {
+	case DataTypeBool, DataTypeString, DataTypeFloat32, DataTypeFloat64,
+		DataTypeInt16, DataTypeUInt16, DataTypeInt32, DataTypeUInt32, DataTypeInt64, DataTypeUInt64:
+	default:
+		return fmt.Errorf("data type %s is not supported for computed tag %s", t.DataType, t.ID)
+	}
+	if t.ArrayLength > 0 || len(t.BitFields) > 0 {
+		return fmt.Errorf("computed tag %s cannot be an array or have bit fields", t.ID)
+	}
+	if t.AccessMode == AccessModeWriteOnly || t.AccessMode == AccessModeReadWrite {
+		return fmt.Errorf("computed tag %s is read-only", t.ID)
+	}
+	if _, err := expr.Parse(t.Expression); err != nil {
+		return fmt.Errorf("invalid expression for computed tag %s: %w", t.ID, err)
+	}
+	return nil
+}
+
+// IsS7NativeType reports whether the tag's data type exists only in the S7 adapter.
+func (t *Tag) IsS7NativeType() bool {
+	switch t.DataType {
+	case DataTypeWString, DataTypeDTL, DataTypeDateAndTime, DataTypeTime, DataTypeS5Time:
+		return true
+	}
+	return false
+}
+
+// validateS7String validates and defaults the declared length of an S7
+// STRING or WSTRING tag.
+func (t *Tag) validateS7String() error {
+	maxLength := uint16(S7StringDefaultLength)
+	switch t.DataType {
+	case DataTypeString:
+	case DataTypeWString:
+		maxLength = S7WStringMaxLength
+	default:
+		if t.S7StringLength != 0 {
+			return fmt.Errorf("s7 string length is only valid for string and wstring tags (tag %s)", t.ID)
+		}
+		return nil
+	}
+	if t.S7StringLength == 0 {
+		t.S7StringLength = S7StringDefaultLength
+	}
+	if t.S7StringLength > maxLength {
+		return fmt.Errorf("s7 string length %d is out of range (must be 1-%d) for tag %s", t.S7StringLength, maxLength, t.ID)
+	}
+	return nil
+}
+
+// validBitFieldName reports whether a bit field name is usable in tag IDs and topics.
+func validBitFieldName(name string) bool {
+	if name == "" {
+		return false
+	}
+	for _, r := range name {
+		if !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '-' || r == '_') {
+			return false
+		}
+	}
+	return true
+}
+
+// BitFieldTags returns the sub-point tags of a bit-field tag: one bool tag
+// per field reading that bit of the parent's register, with ID "{id}.{name}"
+// and topic suffix "{topic_suffix}.{name}". Sub-points inherit the parent's
+// address, byte order, access mode, priority and polling, and are written by
+// read-modify-write of the register.
+func (t *Tag) BitFieldTags() []*Tag {
+	if len(t.BitFields) == 0 {
+		return nil
+	}
+
+	subs := make([]*Tag, 0, len(t.BitFields))
+	for _, field := range t.BitFields {
+		bit := field.Bit
+		sub := *t
+		sub.ID = t.ID + "." + field.Name
+		sub.Name = t.Name + " " + field.Name
+		sub.TopicSuffix = t.TopicSuffix + "." + field.Name
+		sub.DataType = DataTypeBool
+		sub.BitPosition = &bit
+		sub.BitFields = nil
+		sub.ScaleFactor = 1.0
+		sub.Offset = 0
+		sub.Unit = ""
+		sub.DeadbandType = DeadbandTypeNone
+		sub.DeadbandValue = 0
+		subs = append(subs, &sub)
+	}
+	return subs
+}
+
+// ExpectedRegisterCount returns the number of 16-bit registers needed for the
+// data type (coils or discrete inputs for bool arrays).
 func (t *Tag) ExpectedRegisterCount() uint16 {
+	var count uint16
 	switch t.DataType {
 	case DataTypeBool, DataTypeInt16, DataTypeUInt16:
-		return 1
+		count = 1
 	case DataTypeInt32, DataTypeUInt32, DataTypeFloat32:
-		return 2
+		count = 2
 	case DataTypeInt64, DataTypeUInt64, DataTypeFloat64:
-		return 4
+		count = 4
 	default:
-		return 1
+		count = 1
+	}
+	if t.ArrayLength > 0 {
+		count *= t.ArrayLength
 	}
+	return count
 }
 
 // GetEffectivePollInterval returns the effective poll interval for this tag.
@@ -278,6 +610,10 @@ func (t *Tag) GetEffectivePollInterval(deviceDefault time.Duration) time.Duratio
 // For Modbus, coils and holding registers are writable.
 // For OPC UA, it depends on the node's access level (use AccessMode).
 func (t *Tag) IsWritable() bool {
+	if t.IsComputed() {
+		return false
+	}
+
 	// Check explicit access mode first
 	if t.AccessMode != "" {
 		return t.AccessMode == AccessModeWriteOnly || t.AccessMode == AccessModeReadWrite
@@ -0,0 +1,261 @@
+// Package expr provides the evaluation of parsed expressions.
+package expr
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+)
+
+// node is an element of a parsed expression. values holds the current value
+// of each referenced tag, indexed like Expr.refs.
+type node interface {
+	eval(values []interface{}) (interface{}, error)
+}
+
+type literal struct {
+	value interface{}
+}
+
+func (n *literal) eval([]interface{}) (interface{}, error) {
+	return n.value, nil
+}
+
+type reference struct {
+	index int
+}
+
+func (n *reference) eval(values []interface{}) (interface{}, error) {
+	return values[n.index], nil
+}
+
+type unary struct {
+	op      string
+	operand node
+}
+
+func (n *unary) eval(values []interface{}) (interface{}, error) {
+	v, err := n.operand.eval(values)
+	if err != nil {
+		return nil, err
+	}
+	if n.op == "!" {
+		b, err := truth(v)
+		return !b, err
+	}
+	f, err := number(v)
+	return -f, err
+}
+
+type binary struct {
+	op          string
+	left, right node
+}
+
+func (n *binary) eval(values []interface{}) (interface{}, error) {
+	left, err := n.left.eval(values)
+	if err != nil {
+		return nil, err
+	}
+
+	// Logical operators short-circuit
+	if n.op == "&&" || n.op == "||" {
+		l, err := truth(left)
+		if err != nil || l == (n.op == "||") {
+			return l, err
+		}
+		right, err := n.right.eval(values)
+		if err != nil {
+			return nil, err
+		}
+		return truth(right)
+	}
+
+	right, err := n.right.eval(values)
+	if err != nil {
+		return nil, err
+	}
+	if n.op == "==" || n.op == "!=" {
+		eq, err := equal(left, right)
+		return eq == (n.op == "=="), err
+	}
+
+	l, err := number(left)
+	if err != nil {
+		return nil, err
+	}
+	r, err := number(right)
+	if err != nil {
+		return nil, err
+	}
+	switch n.op {
+	case "+":
+		return l + r, nil
+	case "-":
+		return l - r, nil
+	case "*":
+		return l * r, nil
+	case "/", "%":
+		if r == 0 {
+			return nil, fmt.Errorf("%w: division by zero", ErrEval)
+		}
+		if n.op == "%" {
+			return math.Mod(l, r), nil
+		}
+		return l / r, nil
+	case "<":
+		return l < r, nil
+	case "<=":
+		return l <= r, nil
+	case ">":
+		return l > r, nil
+	default: // ">="
+		return l >= r, nil
+	}
+}
+
+type conditional struct {
+	cond, then, otherwise node
+}
+
+func (n *conditional) eval(values []interface{}) (interface{}, error) {
+	v, err := n.cond.eval(values)
+	if err != nil {
+		return nil, err
+	}
+	cond, err := truth(v)
+	if err != nil {
+		return nil, err
+	}
+	if cond {
+		return n.then.eval(values)
+	}
+	return n.otherwise.eval(values)
+}
+
+type call struct {
+	name string
+	fn   function
+	args []node
+}
+
+func (n *call) eval(values []interface{}) (interface{}, error) {
+	args := make([]float64, len(n.args))
+	for i, arg := range n.args {
+		v, err := arg.eval(values)
+		if err != nil {
+			return nil, err
+		}
+		if args[i], err = number(v); err != nil {
+			return nil, fmt.Errorf("%w (argument %d of %s)", err, i+1, n.name)
+		}
+	}
+	return n.fn.apply(args), nil
+}
+
+// function is an entry of the function table. maxArgs is -1 for variadic
+// functions.
+type function struct {
+	minArgs, maxArgs int
+	apply            func(args []float64) float64
+}
+
+func (f function) arity() string {
+	switch {
+	case f.maxArgs < 0:
+		return fmt.Sprintf("at least %d arguments", f.minArgs)
+	case f.minArgs == 1 && f.maxArgs == 1:
+		return "1 argument"
+	default:
+		return fmt.Sprintf("%d arguments", f.minArgs)
+	}
+}
+
+// functions is the function table; expressions cannot call anything else.
+var functions = map[string]function{
+	"abs":   {1, 1, func(a []float64) float64 { return math.Abs(a[0]) }},
+	"ceil":  {1, 1, func(a []float64) float64 { return math.Ceil(a[0]) }},
+	"floor": {1, 1, func(a []float64) float64 { return math.Floor(a[0]) }},
+	"round": {1, 1, func(a []float64) float64 { return math.Round(a[0]) }},
+	"sqrt":  {1, 1, func(a []float64) float64 { return math.Sqrt(a[0]) }},
+	"exp":   {1, 1, func(a []float64) float64 { return math.Exp(a[0]) }},
+	"ln":    {1, 1, func(a []float64) float64 { return math.Log(a[0]) }},
+	"log10": {1, 1, func(a []float64) float64 { return math.Log10(a[0]) }},
+	"pow":   {2, 2, func(a []float64) float64 { return math.Pow(a[0], a[1]) }},
+	"clamp": {3, 3, func(a []float64) float64 { return math.Min(math.Max(a[0], a[1]), a[2]) }},
+	"min": {1, -1, func(a []float64) float64 {
+		m := a[0]
+		for _, v := range a[1:] {
+			m = math.Min(m, v)
+		}
+		return m
+	}},
+	"max": {1, -1, func(a []float64) float64 {
+		m := a[0]
+		for _, v := range a[1:] {
+			m = math.Max(m, v)
+		}
+		return m
+	}},
+	"avg": {1, -1, func(a []float64) float64 {
+		sum := 0.0
+		for _, v := range a {
+			sum += v
+		}
+		return sum / float64(len(a))
+	}},
+}
+
+// number returns the numeric value of v; booleans count as 1 and 0, so
+// running flags can be summed.
+func number(v interface{}) (float64, error) {
+	switch val := v.(type) {
+	case float64:
+		return val, nil
+	case bool:
+		if val {
+			return 1, nil
+		}
+		return 0, nil
+	default:
+		return 0, fmt.Errorf("%w: %s is not a number", ErrEval, describe(v))
+	}
+}
+
+// truth returns the truth value of v; numbers are true when not zero.
+func truth(v interface{}) (bool, error) {
+	switch val := v.(type) {
+	case bool:
+		return val, nil
+	case float64:
+		return val != 0, nil
+	default:
+		return false, fmt.Errorf("%w: %s is not a boolean", ErrEval, describe(v))
+	}
+}
+
+// equal compares two values. Strings only equal strings; numbers and
+// booleans compare by numeric value.
+func equal(a, b interface{}) (bool, error) {
+	as, aString := a.(string)
+	bs, bString := b.(string)
+	if aString || bString {
+		if !aString || !bString {
+			return false, fmt.Errorf("%w: cannot compare %s with %s", ErrEval, describe(a), describe(b))
+		}
+		return as == bs, nil
+	}
+	l, err := number(a)
+	if err != nil {
+		return false, err
+	}
+	r, err := number(b)
+	return l == r, err
+}
+
+func describe(v interface{}) string {
+	if s, ok := v.(string); ok {
+		return "string " + strconv.Quote(s)
+	}
+	return fmt.Sprintf("%v", v)
+}
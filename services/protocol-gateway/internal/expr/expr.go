@@ -0,0 +1,148 @@
+// Package expr provides the sandboxed expression language of computed tags.
+//
+// An expression combines tag references, numbers, strings and booleans with
+// arithmetic (+ - * / %), comparison (< <= > >= == !=), logical (&& || !)
+// and conditional (cond ? a : b) operators and a fixed set of numeric
+// functions. Tag references are written in braces: {tag_id} for a tag of the
+// same device, {device_id/tag_id} for a tag of another device.
+//
+//	{voltage} * {current} / 1000
+//	{line1-drive/speed} > 5 && !{line1-drive/fault}
+//	clamp({flow_out} / max({flow_in}, 0.001) * 100, 0, 100)
+//
+// Expressions cannot assign, loop or call anything outside the function
+// table, and their length and nesting depth are bounded, so evaluation always
+// terminates in time proportional to the expression's size.
+package expr
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+)
+
+// Limits on the expressions accepted by Parse.
+const (
+	MaxLength = 1024 // Maximum expression length in bytes
+	MaxDepth  = 32   // Maximum nesting depth of operators, calls and parentheses
+)
+
+// Expression errors.
+var (
+	ErrSyntax = errors.New("expression syntax error")
+	ErrEval   = errors.New("expression evaluation error")
+)
+
+// Ref is a tag referenced by an expression. Device is empty for a tag of the
+// expression's own device.
+type Ref struct {
+	Device string
+	Tag    string
+}
+
+// String returns the reference in expression notation.
+func (r Ref) String() string {
+	if r.Device == "" {
+		return "{" + r.Tag + "}"
+	}
+	return "{" + r.Device + "/" + r.Tag + "}"
+}
+
+// Expr is a parsed expression. It is immutable and safe for concurrent use.
+type Expr struct {
+	src  string
+	root node
+	refs []Ref
+}
+
+// Parse parses an expression.
+func Parse(src string) (*Expr, error) {
+	if strings.TrimSpace(src) == "" {
+		return nil, fmt.Errorf("%w: empty expression", ErrSyntax)
+	}
+	if len(src) > MaxLength {
+		return nil, fmt.Errorf("%w: expression is longer than %d bytes", ErrSyntax, MaxLength)
+	}
+	tokens, err := tokenize(src)
+	if err != nil {
+		return nil, err
+	}
+	p := &parser{tokens: tokens, refIndex: make(map[Ref]int)}
+	root, err := p.parseExpression()
+	if err != nil {
+		return nil, err
+	}
+	if tok := p.peek(); tok.kind != tokenEOF {
+		return nil, fmt.Errorf("%w: unexpected %s at offset %d", ErrSyntax, tok, tok.pos)
+	}
+	return &Expr{src: src, root: root, refs: p.refs}, nil
+}
+
+// String returns the source of the expression.
+func (e *Expr) String() string {
+	return e.src
+}
+
+// Refs returns the distinct tags referenced by the expression, in order of
+// first appearance.
+func (e *Expr) Refs() []Ref {
+	return append([]Ref(nil), e.refs...)
+}
+
+// Eval evaluates the expression. lookup returns the current value of a
+// referenced tag, or nil if there is none. Integer and float values of any
+// width are numbers; bool and string values are used as they are. The result
+// is a float64, bool or string.
+func (e *Expr) Eval(lookup func(Ref) interface{}) (interface{}, error) {
+	values := make([]interface{}, len(e.refs))
+	for i, ref := range e.refs {
+		v, err := inputValue(lookup(ref))
+		if err != nil {
+			return nil, fmt.Errorf("%w: %s: %v", ErrEval, ref, err)
+		}
+		values[i] = v
+	}
+	result, err := e.root.eval(values)
+	if err != nil {
+		return nil, err
+	}
+	if f, ok := result.(float64); ok && (math.IsNaN(f) || math.IsInf(f, 0)) {
+		return nil, fmt.Errorf("%w: result is not a finite number", ErrEval)
+	}
+	return result, nil
+}
+
+// inputValue converts a tag value to an expression value.
+func inputValue(v interface{}) (interface{}, error) {
+	switch val := v.(type) {
+	case nil:
+		return nil, errors.New("no value")
+	case bool, string, float64:
+		return val, nil
+	case float32:
+		return float64(val), nil
+	case int:
+		return float64(val), nil
+	case int8:
+		return float64(val), nil
+	case int16:
+		return float64(val), nil
+	case int32:
+		return float64(val), nil
+	case int64:
+		return float64(val), nil
+	case uint:
+		return float64(val), nil
+	case uint8:
+		return float64(val), nil
+	case uint16:
+		return float64(val), nil
+	case uint32:
+		return float64(val), nil
+	case uint64:
+		return float64(val), nil
+	default:
+		return nil, fmt.Errorf("unsupported value type %T", v)
+	}
+}
@@ -0,0 +1,138 @@
+package expr
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestEval(t *testing.T) {
+	values := map[Ref]interface{}{
+		{Tag: "voltage"}:                  float32(230),
+		{Tag: "current"}:                  int16(12),
+		{Tag: "mode"}:                     "AUTO",
+		{Device: "drive-1", Tag: "speed"}: uint32(7),
+		{Device: "drive-1", Tag: "fault"}: false,
+		{Device: "drive-2", Tag: "speed"}: 0.0,
+		{Tag: "alarm.high"}:               true,
+	}
+	lookup := func(ref Ref) interface{} { return values[ref] }
+
+	tests := []struct {
+		src  string
+		want interface{}
+	}{
+		{"{voltage} * {current} / 1000", 2.76},
+		{"1 + 2 * 3 - 4 / 2", 5.0},
+		{"(1 + 2) * 3", 9.0},
+		{"-{current} % 5", -2.0},
+		{"2e3 + .5", 2000.5},
+		{"{drive-1/speed} > 5 && !{drive-1/fault}", true},
+		{"{drive-1/speed} > 5 && {drive-2/speed} > 5", false},
+		{"{drive-2/speed} > 5 || {alarm.high}", true},
+		{"{mode} == \"AUTO\"", true},
+		{"{mode} != \"MAN\" ? 1 : 0", 1.0},
+		{"{alarm.high} + {drive-1/fault}", 1.0},
+		{"false ? 1 : true ? 2 : 3", 2.0},
+		{"1 < 2 == true", true},
+		{"min(3, {current}, 7) + max(1, 2) + abs(-1)", 6.0},
+		{"avg(1, 2, 3) + round(2.5) + floor(1.9) + ceil(1.1)", 8.0},
+		{"clamp(150, 0, 100) + sqrt(16) + pow(2, 3)", 112.0},
+		{"{ drive-1 / speed } == 7", true},
+	}
+	for _, tt := range tests {
+		e, err := Parse(tt.src)
+		if err != nil {
+			t.Errorf("Parse(%q): %v", tt.src, err)
+			continue
+		}
+		got, err := e.Eval(lookup)
+		if err != nil {
+			t.Errorf("Eval(%q): %v", tt.src, err)
+			continue
+		}
+		if f, ok := got.(float64); ok {
+			if want, ok := tt.want.(float64); ok && f-want < 1e-9 && want-f < 1e-9 {
+				continue
+			}
+		}
+		if got != tt.want {
+			t.Errorf("Eval(%q) = %v (%T), want %v", tt.src, got, got, tt.want)
+		}
+	}
+}
+
+func TestRefs(t *testing.T) {
+	e, err := Parse("{a} + {plc/b} * {a} - {plc/a}")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Ref{{Tag: "a"}, {Device: "plc", Tag: "b"}, {Device: "plc", Tag: "a"}}
+	got := e.Refs()
+	if len(got) != len(want) {
+		t.Fatalf("Refs() = %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("Refs()[%d] = %v, want %v", i, got[i], want[i])
+		}
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	for _, src := range []string{
+		"",
+		"1 +",
+		"(1 + 2",
+		"{a",
+		"{}",
+		"{/a}",
+		"{a/}",
+		"\"open",
+		"1 ? 2",
+		"system(1)",
+		"sqrt(1, 2)",
+		"max()",
+		"1 2",
+		"a = 1",
+		"1.2.3",
+		strings.Repeat("(", MaxDepth) + "1" + strings.Repeat(")", MaxDepth),
+		strings.Repeat("-", MaxDepth+1) + "1",
+		strings.Repeat("1+", MaxLength/2) + "1",
+	} {
+		if _, err := Parse(src); !errors.Is(err, ErrSyntax) {
+			t.Errorf("Parse(%q): expected ErrSyntax, got %v", src, err)
+		}
+	}
+}
+
+func TestEvalErrors(t *testing.T) {
+	values := map[Ref]interface{}{{Tag: "zero"}: 0, {Tag: "text"}: "x", {Tag: "list"}: []int{1}}
+	lookup := func(ref Ref) interface{} { return values[ref] }
+
+	for _, src := range []string{
+		"1 / {zero}",
+		"1 % {zero}",
+		"sqrt(-1)",
+		"{text} + 1",
+		"{text} == 1",
+		"!{text}",
+		"{missing} + 1",
+		"{list} + 1",
+	} {
+		e, err := Parse(src)
+		if err != nil {
+			t.Errorf("Parse(%q): %v", src, err)
+			continue
+		}
+		if _, err := e.Eval(lookup); !errors.Is(err, ErrEval) {
+			t.Errorf("Eval(%q): expected ErrEval, got %v", src, err)
+		}
+	}
+
+	// Short-circuit: the right-hand side is not evaluated
+	e, _ := Parse("false && 1 / {zero} > 0")
+	if got, err := e.Eval(lookup); err != nil || got != false {
+		t.Errorf("short-circuit && = %v, %v", got, err)
+	}
+}
@@ -0,0 +1,346 @@
+// Package expr provides the tokenizer and parser of the expression language.
+package expr
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+type tokenKind int
+
+const (
+	tokenEOF tokenKind = iota
+	tokenNumber
+	tokenString
+	tokenIdent
+	tokenRef
+	tokenOp
+)
+
+type token struct {
+	kind tokenKind
+	text string // Operator, identifier, number, unquoted string or reference
+	pos  int
+}
+
+func (t token) String() string {
+	switch t.kind {
+	case tokenEOF:
+		return "end of expression"
+	case tokenString:
+		return strconv.Quote(t.text)
+	case tokenRef:
+		return "{" + t.text + "}"
+	default:
+		return fmt.Sprintf("%q", t.text)
+	}
+}
+
+// operators are the operator tokens, two-character ones first.
+var operators = []string{"<=", ">=", "==", "!=", "&&", "||", "+", "-", "*", "/", "%", "<", ">", "!", "?", ":", "(", ")", ","}
+
+// tokenize splits an expression into tokens.
+func tokenize(src string) ([]token, error) {
+	var tokens []token
+	for i := 0; i < len(src); {
+		c := src[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			i++
+		case c >= '0' && c <= '9' || c == '.':
+			start := i
+			for i < len(src) && (isDigit(src[i]) || src[i] == '.') {
+				i++
+			}
+			if i < len(src) && (src[i] == 'e' || src[i] == 'E') {
+				i++
+				if i < len(src) && (src[i] == '+' || src[i] == '-') {
+					i++
+				}
+				for i < len(src) && isDigit(src[i]) {
+					i++
+				}
+			}
+			tokens = append(tokens, token{kind: tokenNumber, text: src[start:i], pos: start})
+		case isLetter(c):
+			start := i
+			for i < len(src) && (isLetter(src[i]) || isDigit(src[i])) {
+				i++
+			}
+			tokens = append(tokens, token{kind: tokenIdent, text: src[start:i], pos: start})
+		case c == '{':
+			end := strings.IndexByte(src[i:], '}')
+			if end < 0 {
+				return nil, fmt.Errorf("%w: unterminated tag reference at offset %d", ErrSyntax, i)
+			}
+			tokens = append(tokens, token{kind: tokenRef, text: src[i+1 : i+end], pos: i})
+			i += end + 1
+		case c == '"':
+			end := i + 1
+			for end < len(src) && src[end] != '"' {
+				if src[end] == '\\' {
+					end++
+				}
+				end++
+			}
+			if end >= len(src) {
+				return nil, fmt.Errorf("%w: unterminated string at offset %d", ErrSyntax, i)
+			}
+			text, err := strconv.Unquote(src[i : end+1])
+			if err != nil {
+				return nil, fmt.Errorf("%w: invalid string at offset %d", ErrSyntax, i)
+			}
+			tokens = append(tokens, token{kind: tokenString, text: text, pos: i})
+			i = end + 1
+		default:
+			op := ""
+			for _, candidate := range operators {
+				if strings.HasPrefix(src[i:], candidate) {
+					op = candidate
+					break
+				}
+			}
+			if op == "" {
+				return nil, fmt.Errorf("%w: unexpected character %q at offset %d", ErrSyntax, c, i)
+			}
+			tokens = append(tokens, token{kind: tokenOp, text: op, pos: i})
+			i += len(op)
+		}
+	}
+	return append(tokens, token{kind: tokenEOF, pos: len(src)}), nil
+}
+
+func isDigit(c byte) bool  { return c >= '0' && c <= '9' }
+func isLetter(c byte) bool { return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c == '_' }
+
+// parser is a recursive-descent parser with one function per precedence
+// level, lowest first: conditional, ||, &&, equality, comparison, additive,
+// multiplicative, unary.
+type parser struct {
+	tokens   []token
+	pos      int
+	depth    int
+	refs     []Ref
+	refIndex map[Ref]int
+}
+
+func (p *parser) peek() token {
+	return p.tokens[p.pos]
+}
+
+func (p *parser) next() token {
+	tok := p.tokens[p.pos]
+	if tok.kind != tokenEOF {
+		p.pos++
+	}
+	return tok
+}
+
+// acceptOp consumes the next token if it is one of the operators.
+func (p *parser) acceptOp(ops ...string) (string, bool) {
+	tok := p.peek()
+	if tok.kind != tokenOp {
+		return "", false
+	}
+	for _, op := range ops {
+		if tok.text == op {
+			p.pos++
+			return op, true
+		}
+	}
+	return "", false
+}
+
+func (p *parser) expectOp(op string) error {
+	if _, ok := p.acceptOp(op); !ok {
+		tok := p.peek()
+		return fmt.Errorf("%w: expected %q, got %s at offset %d", ErrSyntax, op, tok, tok.pos)
+	}
+	return nil
+}
+
+// enter tracks the nesting depth: subexpressions (parentheses, conditional
+// branches, call arguments) and unary operators each add a level.
+func (p *parser) enter() error {
+	p.depth++
+	if p.depth > MaxDepth {
+		return fmt.Errorf("%w: expression is nested deeper than %d levels", ErrSyntax, MaxDepth)
+	}
+	return nil
+}
+
+func (p *parser) leave() {
+	p.depth--
+}
+
+// parseExpression parses cond ? a : b, right-associative.
+func (p *parser) parseExpression() (node, error) {
+	if err := p.enter(); err != nil {
+		return nil, err
+	}
+	defer p.leave()
+
+	cond, err := p.parseBinary(0)
+	if err != nil {
+		return nil, err
+	}
+	if _, ok := p.acceptOp("?"); !ok {
+		return cond, nil
+	}
+	then, err := p.parseExpression()
+	if err != nil {
+		return nil, err
+	}
+	if err := p.expectOp(":"); err != nil {
+		return nil, err
+	}
+	otherwise, err := p.parseExpression()
+	if err != nil {
+		return nil, err
+	}
+	return &conditional{cond: cond, then: then, otherwise: otherwise}, nil
+}
+
+// binaryLevels are the left-associative binary operators by precedence,
+// lowest first.
+var binaryLevels = [][]string{
+	{"||"},
+	{"&&"},
+	{"==", "!="},
+	{"<", "<=", ">", ">="},
+	{"+", "-"},
+	{"*", "/", "%"},
+}
+
+// parseBinary parses the binary operators of a precedence level and above.
+func (p *parser) parseBinary(level int) (node, error) {
+	if level == len(binaryLevels) {
+		return p.parseUnary()
+	}
+	left, err := p.parseBinary(level + 1)
+	if err != nil {
+		return nil, err
+	}
+	for {
+		op, ok := p.acceptOp(binaryLevels[level]...)
+		if !ok {
+			return left, nil
+		}
+		right, err := p.parseBinary(level + 1)
+		if err != nil {
+			return nil, err
+		}
+		left = &binary{op: op, left: left, right: right}
+	}
+}
+
+// parseUnary parses -x, !x and primary expressions.
+func (p *parser) parseUnary() (node, error) {
+	if op, ok := p.acceptOp("-", "!"); ok {
+		if err := p.enter(); err != nil {
+			return nil, err
+		}
+		defer p.leave()
+		operand, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return &unary{op: op, operand: operand}, nil
+	}
+	return p.parsePrimary()
+}
+
+// parsePrimary parses literals, tag references, function calls and
+// parenthesized expressions.
+func (p *parser) parsePrimary() (node, error) {
+	tok := p.next()
+	switch tok.kind {
+	case tokenNumber:
+		f, err := strconv.ParseFloat(tok.text, 64)
+		if err != nil {
+			return nil, fmt.Errorf("%w: invalid number %q at offset %d", ErrSyntax, tok.text, tok.pos)
+		}
+		return &literal{value: f}, nil
+	case tokenString:
+		return &literal{value: tok.text}, nil
+	case tokenRef:
+		return p.ref(tok)
+	case tokenIdent:
+		switch tok.text {
+		case "true":
+			return &literal{value: true}, nil
+		case "false":
+			return &literal{value: false}, nil
+		}
+		return p.parseCall(tok)
+	case tokenOp:
+		if tok.text == "(" {
+			inner, err := p.parseExpression()
+			if err != nil {
+				return nil, err
+			}
+			if err := p.expectOp(")"); err != nil {
+				return nil, err
+			}
+			return inner, nil
+		}
+	}
+	return nil, fmt.Errorf("%w: unexpected %s at offset %d", ErrSyntax, tok, tok.pos)
+}
+
+// parseCall parses the arguments of a call to a function of the function table.
+func (p *parser) parseCall(name token) (node, error) {
+	fn, ok := functions[name.text]
+	if !ok {
+		return nil, fmt.Errorf("%w: unknown function %q at offset %d", ErrSyntax, name.text, name.pos)
+	}
+	if err := p.expectOp("("); err != nil {
+		return nil, err
+	}
+	var args []node
+	if _, ok := p.acceptOp(")"); !ok {
+		for {
+			arg, err := p.parseExpression()
+			if err != nil {
+				return nil, err
+			}
+			args = append(args, arg)
+			if _, ok := p.acceptOp(","); !ok {
+				break
+			}
+		}
+		if err := p.expectOp(")"); err != nil {
+			return nil, err
+		}
+	}
+	if len(args) < fn.minArgs || (fn.maxArgs >= 0 && len(args) > fn.maxArgs) {
+		return nil, fmt.Errorf("%w: %s takes %s, got %d at offset %d", ErrSyntax, name.text, fn.arity(), len(args), name.pos)
+	}
+	return &call{name: name.text, fn: fn, args: args}, nil
+}
+
+// ref parses a tag reference: {tag_id} or {device_id/tag_id}.
+func (p *parser) ref(tok token) (node, error) {
+	text := strings.TrimSpace(tok.text)
+	var ref Ref
+	if device, tag, ok := strings.Cut(text, "/"); ok {
+		ref = Ref{Device: strings.TrimSpace(device), Tag: strings.TrimSpace(tag)}
+		if ref.Device == "" {
+			return nil, fmt.Errorf("%w: empty device ID in tag reference at offset %d", ErrSyntax, tok.pos)
+		}
+	} else {
+		ref = Ref{Tag: text}
+	}
+	if ref.Tag == "" {
+		return nil, fmt.Errorf("%w: empty tag ID in tag reference at offset %d", ErrSyntax, tok.pos)
+	}
+
+	index, seen := p.refIndex[ref]
+	if !seen {
+		index = len(p.refs)
+		p.refIndex[ref] = index
+		p.refs = append(p.refs, ref)
+	}
+	return &reference{index: index}, nil
+}
@@ -25,7 +25,8 @@ type Registry struct {
 	PollErrors            *prometheus.CounterVec
 	PointsRead            prometheus.Counter
 	PointsPublished       prometheus.Counter
-	WorkerPoolUtilization prometheus.Gauge // Current workers in use / max workers
+	PointsFiltered        prometheus.Counter // Suppressed by deadband (report by exception)
+	WorkerPoolUtilization prometheus.Gauge   // Current workers in use / max workers
 
 	// MQTT metrics
 	MQTTMessagesPublished prometheus.Counter
@@ -34,6 +35,12 @@ type Registry struct {
 	MQTTPublishLatency    prometheus.Histogram
 	MQTTReconnects        prometheus.Counter
 
+	// MQTT store-and-forward backlog metrics
+	MQTTBacklogMessages  prometheus.Gauge
+	MQTTBacklogBytes     prometheus.Gauge
+	MQTTBacklogOldestAge prometheus.Gauge
+	MQTTBacklogDropped   prometheus.Counter
+
 	// Device metrics
 	DevicesRegistered prometheus.Gauge
 	DevicesOnline     prometheus.Gauge
@@ -46,6 +53,27 @@ type Registry struct {
 	S7WriteDuration   *prometheus.HistogramVec
 	S7BreakerState    *prometheus.GaugeVec
 
+	// Modbus shared bus metrics (RS-485 lines and gateway connections)
+	ModbusBusRequestsTotal *prometheus.CounterVec
+	ModbusBusTimeoutsTotal *prometheus.CounterVec
+	ModbusBusUtilization   *prometheus.GaugeVec
+	ModbusBusQueueDepth    *prometheus.GaugeVec
+
+	// Modbus-specific metrics
+	ModbusDeviceConnected    *prometheus.GaugeVec
+	ModbusRequestsTotal      *prometheus.CounterVec
+	ModbusRequestDuration    *prometheus.HistogramVec
+	ModbusExceptionsTotal    *prometheus.CounterVec
+	ModbusRegistersRequested *prometheus.CounterVec // Addresses covered by configured tags
+	ModbusRegistersRead      *prometheus.CounterVec // Addresses read on the wire, including batching gaps
+	ModbusTagErrorsTotal     *prometheus.CounterVec
+	ModbusBreakerState       *prometheus.GaugeVec
+
+	// MQTT source metrics (devices ingested from a source broker)
+	MQTTSourceMessagesTotal   *prometheus.CounterVec
+	MQTTSourceDeviceStale     *prometheus.GaugeVec
+	MQTTSourceBrokerConnected *prometheus.GaugeVec
+
 	// Clock drift metrics
 	ClockDriftSeconds prometheus.Gauge       // Current NTP offset in seconds
 	ClockDriftChecks  *prometheus.CounterVec // NTP check results by status (success/error)
@@ -55,6 +83,13 @@ type Registry struct {
 	OPCUACertsTotal *prometheus.GaugeVec // Certificate count by store (trusted/rejected)
 	OPCUACertExpiry *prometheus.GaugeVec // Days until cert expiry, by fingerprint
 
+	// OPC UA Alarms & Conditions metrics
+	OPCUAAlarmEvents *prometheus.CounterVec // Alarm events received, by device and state
+
+	// OPC UA history backfill metrics
+	OPCUABackfillGaps   *prometheus.CounterVec // Gaps recovered from history, by device and result
+	OPCUABackfillPoints *prometheus.CounterVec // Values published from history, by device
+
 	// System metrics
 	GoroutineCount prometheus.Gauge
 	MemoryUsage    prometheus.Gauge
@@ -62,27 +97,34 @@ type Registry struct {
 
 // NewRegistry creates a new metrics registry with all metrics registered.
 func NewRegistry() *Registry {
+	return NewRegistryWith(prometheus.DefaultRegisterer)
+}
+
+// NewRegistryWith creates a new metrics registry with all metrics registered
+// with reg, e.g. a private prometheus.Registry in tests.
+func NewRegistryWith(reg prometheus.Registerer) *Registry {
+	factory := promauto.With(reg)
 	r := &Registry{
 		// Connection metrics
-		ActiveConnectionsByProtocol: promauto.NewGaugeVec(prometheus.GaugeOpts{
+		ActiveConnectionsByProtocol: factory.NewGaugeVec(prometheus.GaugeOpts{
 			Namespace: "gateway",
 			Subsystem: "connections",
 			Name:      "active",
 			Help:      "Number of active connections (all protocols)",
 		}, []string{"protocol"}),
-		ConnectionsTotalByProtocol: promauto.NewCounterVec(prometheus.CounterOpts{
+		ConnectionsTotalByProtocol: factory.NewCounterVec(prometheus.CounterOpts{
 			Namespace: "gateway",
 			Subsystem: "connections",
 			Name:      "attempts_total",
 			Help:      "Total number of connection attempts by protocol",
 		}, []string{"protocol"}),
-		ConnectionErrorsByProtocol: promauto.NewCounterVec(prometheus.CounterOpts{
+		ConnectionErrorsByProtocol: factory.NewCounterVec(prometheus.CounterOpts{
 			Namespace: "gateway",
 			Subsystem: "connections",
 			Name:      "errors_total",
 			Help:      "Total number of connection errors by protocol",
 		}, []string{"protocol"}),
-		ConnectionLatencyByProtocol: promauto.NewHistogramVec(prometheus.HistogramOpts{
+		ConnectionLatencyByProtocol: factory.NewHistogramVec(prometheus.HistogramOpts{
 			Namespace: "gateway",
 			Subsystem: "connections",
 			Name:      "latency_seconds",
@@ -91,44 +133,50 @@ func NewRegistry() *Registry {
 		}, []string{"protocol"}),
 
 		// Polling metrics
-		PollsTotal: promauto.NewCounterVec(prometheus.CounterOpts{
+		PollsTotal: factory.NewCounterVec(prometheus.CounterOpts{
 			Namespace: "gateway",
 			Subsystem: "polling",
 			Name:      "polls_total",
 			Help:      "Total number of poll operations",
 		}, []string{"device_id", "status"}),
-		PollsSkipped: promauto.NewCounter(prometheus.CounterOpts{
+		PollsSkipped: factory.NewCounter(prometheus.CounterOpts{
 			Namespace: "gateway",
 			Subsystem: "polling",
 			Name:      "polls_skipped_total",
 			Help:      "Total polls skipped due to worker pool back-pressure",
 		}),
-		PollDuration: promauto.NewHistogramVec(prometheus.HistogramOpts{
+		PollDuration: factory.NewHistogramVec(prometheus.HistogramOpts{
 			Namespace: "gateway",
 			Subsystem: "polling",
 			Name:      "duration_seconds",
 			Help:      "Poll cycle duration in seconds (per-device for p95/p99 analysis)",
 			Buckets:   []float64{0.001, 0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5},
 		}, []string{"device_id", "protocol"}),
-		PollErrors: promauto.NewCounterVec(prometheus.CounterOpts{
+		PollErrors: factory.NewCounterVec(prometheus.CounterOpts{
 			Namespace: "gateway",
 			Subsystem: "polling",
 			Name:      "errors_total",
 			Help:      "Total number of poll errors",
 		}, []string{"device_id", "error_type"}),
-		PointsRead: promauto.NewCounter(prometheus.CounterOpts{
+		PointsRead: factory.NewCounter(prometheus.CounterOpts{
 			Namespace: "gateway",
 			Subsystem: "polling",
 			Name:      "points_read_total",
 			Help:      "Total number of data points read",
 		}),
-		PointsPublished: promauto.NewCounter(prometheus.CounterOpts{
+		PointsPublished: factory.NewCounter(prometheus.CounterOpts{
 			Namespace: "gateway",
 			Subsystem: "polling",
 			Name:      "points_published_total",
 			Help:      "Total number of data points published",
 		}),
-		WorkerPoolUtilization: promauto.NewGauge(prometheus.GaugeOpts{
+		PointsFiltered: factory.NewCounter(prometheus.CounterOpts{
+			Namespace: "gateway",
+			Subsystem: "polling",
+			Name:      "points_filtered_total",
+			Help:      "Total number of good data points not published because they stayed within their deadband",
+		}),
+		WorkerPoolUtilization: factory.NewGauge(prometheus.GaugeOpts{
 			Namespace: "gateway",
 			Subsystem: "polling",
 			Name:      "worker_pool_utilization",
@@ -136,52 +184,78 @@ func NewRegistry() *Registry {
 		}),
 
 		// MQTT metrics
-		MQTTMessagesPublished: promauto.NewCounter(prometheus.CounterOpts{
+		MQTTMessagesPublished: factory.NewCounter(prometheus.CounterOpts{
 			Namespace: "gateway",
 			Subsystem: "mqtt",
 			Name:      "messages_published_total",
 			Help:      "Total number of MQTT messages published",
 		}),
-		MQTTMessagesFailed: promauto.NewCounter(prometheus.CounterOpts{
+		MQTTMessagesFailed: factory.NewCounter(prometheus.CounterOpts{
 			Namespace: "gateway",
 			Subsystem: "mqtt",
 			Name:      "messages_failed_total",
 			Help:      "Total number of failed MQTT publishes",
 		}),
-		MQTTBufferSize: promauto.NewGauge(prometheus.GaugeOpts{
+		MQTTBufferSize: factory.NewGauge(prometheus.GaugeOpts{
 			Namespace: "gateway",
 			Subsystem: "mqtt",
 			Name:      "buffer_size",
 			Help:      "Current MQTT message buffer size",
 		}),
-		MQTTPublishLatency: promauto.NewHistogram(prometheus.HistogramOpts{
+		MQTTPublishLatency: factory.NewHistogram(prometheus.HistogramOpts{
 			Namespace: "gateway",
 			Subsystem: "mqtt",
 			Name:      "publish_latency_seconds",
 			Help:      "MQTT publish latency in seconds",
 			Buckets:   []float64{0.001, 0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5},
 		}),
-		MQTTReconnects: promauto.NewCounter(prometheus.CounterOpts{
+		MQTTReconnects: factory.NewCounter(prometheus.CounterOpts{
 			Namespace: "gateway",
 			Subsystem: "mqtt",
 			Name:      "reconnects_total",
 			Help:      "Total number of MQTT reconnection attempts",
 		}),
 
+		// MQTT store-and-forward backlog metrics
+		MQTTBacklogMessages: factory.NewGauge(prometheus.GaugeOpts{
+			Namespace: "gateway",
+			Subsystem: "mqtt",
+			Name:      "backlog_messages",
+			Help:      "Messages waiting in the store-and-forward buffer",
+		}),
+		MQTTBacklogBytes: factory.NewGauge(prometheus.GaugeOpts{
+			Namespace: "gateway",
+			Subsystem: "mqtt",
+			Name:      "backlog_bytes",
+			Help:      "Size of the on-disk store-and-forward buffer in bytes",
+		}),
+		MQTTBacklogOldestAge: factory.NewGauge(prometheus.GaugeOpts{
+			Namespace: "gateway",
+			Subsystem: "mqtt",
+			Name:      "backlog_oldest_age_seconds",
+			Help:      "Age of the oldest message in the store-and-forward buffer",
+		}),
+		MQTTBacklogDropped: factory.NewCounter(prometheus.CounterOpts{
+			Namespace: "gateway",
+			Subsystem: "mqtt",
+			Name:      "backlog_dropped_total",
+			Help:      "Total number of buffered messages discarded by the size or age bound",
+		}),
+
 		// Device metrics
-		DevicesRegistered: promauto.NewGauge(prometheus.GaugeOpts{
+		DevicesRegistered: factory.NewGauge(prometheus.GaugeOpts{
 			Namespace: "gateway",
 			Subsystem: "devices",
 			Name:      "registered",
 			Help:      "Number of registered devices",
 		}),
-		DevicesOnline: promauto.NewGauge(prometheus.GaugeOpts{
+		DevicesOnline: factory.NewGauge(prometheus.GaugeOpts{
 			Namespace: "gateway",
 			Subsystem: "devices",
 			Name:      "online",
 			Help:      "Number of online devices",
 		}),
-		DeviceErrors: promauto.NewCounterVec(prometheus.CounterOpts{
+		DeviceErrors: factory.NewCounterVec(prometheus.CounterOpts{
 			Namespace: "gateway",
 			Subsystem: "devices",
 			Name:      "errors_total",
@@ -189,53 +263,149 @@ func NewRegistry() *Registry {
 		}, []string{"device_id", "error_type"}),
 
 		// S7-specific metrics
-		S7DeviceConnected: promauto.NewGaugeVec(prometheus.GaugeOpts{
+		S7DeviceConnected: factory.NewGaugeVec(prometheus.GaugeOpts{
 			Namespace: "gateway",
 			Subsystem: "s7",
 			Name:      "device_connected",
 			Help:      "Whether the S7 device is currently connected (1=connected, 0=disconnected)",
 		}, []string{"device_id"}),
-		S7TagErrorsTotal: promauto.NewCounterVec(prometheus.CounterOpts{
+		S7TagErrorsTotal: factory.NewCounterVec(prometheus.CounterOpts{
 			Namespace: "gateway",
 			Subsystem: "s7",
 			Name:      "tag_errors_total",
 			Help:      "Total S7 tag read/write errors by device and tag",
 		}, []string{"device_id", "tag_id"}),
-		S7ReadDuration: promauto.NewHistogramVec(prometheus.HistogramOpts{
+		S7ReadDuration: factory.NewHistogramVec(prometheus.HistogramOpts{
 			Namespace: "gateway",
 			Subsystem: "s7",
 			Name:      "read_duration_seconds",
 			Help:      "S7 read operation duration per device",
 			Buckets:   []float64{0.001, 0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1},
 		}, []string{"device_id"}),
-		S7WriteDuration: promauto.NewHistogramVec(prometheus.HistogramOpts{
+		S7WriteDuration: factory.NewHistogramVec(prometheus.HistogramOpts{
 			Namespace: "gateway",
 			Subsystem: "s7",
 			Name:      "write_duration_seconds",
 			Help:      "S7 write operation duration per device",
 			Buckets:   []float64{0.001, 0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1},
 		}, []string{"device_id"}),
-		S7BreakerState: promauto.NewGaugeVec(prometheus.GaugeOpts{
+		S7BreakerState: factory.NewGaugeVec(prometheus.GaugeOpts{
 			Namespace: "gateway",
 			Subsystem: "s7",
 			Name:      "breaker_state",
 			Help:      "S7 circuit breaker state per device (0=closed, 1=half-open, 2=open)",
 		}, []string{"device_id"}),
 
+		// Modbus shared bus metrics (RS-485 lines and gateway connections)
+		ModbusBusRequestsTotal: factory.NewCounterVec(prometheus.CounterOpts{
+			Namespace: "gateway",
+			Subsystem: "modbus",
+			Name:      "bus_requests_total",
+			Help:      "Total requests sent on a shared Modbus bus (serial line or gateway connection) by result",
+		}, []string{"port", "status"}),
+		ModbusBusTimeoutsTotal: factory.NewCounterVec(prometheus.CounterOpts{
+			Namespace: "gateway",
+			Subsystem: "modbus",
+			Name:      "bus_timeouts_total",
+			Help:      "Total response timeouts on a shared Modbus bus",
+		}, []string{"port"}),
+		ModbusBusUtilization: factory.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: "gateway",
+			Subsystem: "modbus",
+			Name:      "bus_utilization_ratio",
+			Help:      "Fraction of time a shared Modbus bus was busy over the last health check period",
+		}, []string{"port"}),
+		ModbusBusQueueDepth: factory.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: "gateway",
+			Subsystem: "modbus",
+			Name:      "bus_queue_depth",
+			Help:      "Requests waiting for a shared Modbus bus",
+		}, []string{"port"}),
+
+		// Modbus-specific metrics
+		ModbusDeviceConnected: factory.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: "gateway",
+			Subsystem: "modbus",
+			Name:      "device_connected",
+			Help:      "Whether the Modbus device is currently connected (1=connected, 0=disconnected)",
+		}, []string{"device_id"}),
+		ModbusRequestsTotal: factory.NewCounterVec(prometheus.CounterOpts{
+			Namespace: "gateway",
+			Subsystem: "modbus",
+			Name:      "requests_total",
+			Help:      "Total Modbus requests by device, function code and result (ok/exception/timeout/error)",
+		}, []string{"device_id", "function_code", "status"}),
+		ModbusRequestDuration: factory.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: "gateway",
+			Subsystem: "modbus",
+			Name:      "request_duration_seconds",
+			Help:      "Modbus request round-trip time per device and function code",
+			Buckets:   []float64{0.001, 0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5},
+		}, []string{"device_id", "function_code"}),
+		ModbusExceptionsTotal: factory.NewCounterVec(prometheus.CounterOpts{
+			Namespace: "gateway",
+			Subsystem: "modbus",
+			Name:      "exceptions_total",
+			Help:      "Total Modbus exception responses by device, function code and exception",
+		}, []string{"device_id", "function_code", "exception"}),
+		ModbusRegistersRequested: factory.NewCounterVec(prometheus.CounterOpts{
+			Namespace: "gateway",
+			Subsystem: "modbus",
+			Name:      "registers_requested_total",
+			Help:      "Total registers or bits covered by the tags of batched reads",
+		}, []string{"device_id", "register_type"}),
+		ModbusRegistersRead: factory.NewCounterVec(prometheus.CounterOpts{
+			Namespace: "gateway",
+			Subsystem: "modbus",
+			Name:      "registers_read_total",
+			Help:      "Total registers or bits read by batched reads, including gaps between tags",
+		}, []string{"device_id", "register_type"}),
+		ModbusTagErrorsTotal: factory.NewCounterVec(prometheus.CounterOpts{
+			Namespace: "gateway",
+			Subsystem: "modbus",
+			Name:      "tag_errors_total",
+			Help:      "Total Modbus tag read errors by device and tag",
+		}, []string{"device_id", "tag_id"}),
+		ModbusBreakerState: factory.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: "gateway",
+			Subsystem: "modbus",
+			Name:      "breaker_state",
+			Help:      "Modbus circuit breaker state per device (0=closed, 1=half-open, 2=open)",
+		}, []string{"device_id"}),
+
+		// MQTT source metrics
+		MQTTSourceMessagesTotal: factory.NewCounterVec(prometheus.CounterOpts{
+			Namespace: "gateway",
+			Subsystem: "mqtt_source",
+			Name:      "messages_total",
+			Help:      "Messages received from source brokers by device and result (decoded, unmatched, decode_error)",
+		}, []string{"device_id", "result"}),
+		MQTTSourceDeviceStale: factory.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: "gateway",
+			Name:      "mqtt_source_device_stale",
+			Help:      "Whether an MQTT source device has been silent for longer than its staleness timeout (1=stale)",
+		}, []string{"device_id"}),
+		MQTTSourceBrokerConnected: factory.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: "gateway",
+			Subsystem: "mqtt_source",
+			Name:      "broker_connected",
+			Help:      "Whether the shared client of a source broker is connected (1=connected)",
+		}, []string{"broker"}),
+
 		// Clock drift metrics
-		ClockDriftSeconds: promauto.NewGauge(prometheus.GaugeOpts{
+		ClockDriftSeconds: factory.NewGauge(prometheus.GaugeOpts{
 			Namespace: "gateway",
 			Subsystem: "system",
 			Name:      "clock_drift_seconds",
 			Help:      "Current NTP clock offset in seconds (positive = gateway ahead, negative = behind)",
 		}),
-		ClockDriftChecks: promauto.NewCounterVec(prometheus.CounterOpts{
+		ClockDriftChecks: factory.NewCounterVec(prometheus.CounterOpts{
 			Namespace: "gateway",
 			Subsystem: "system",
 			Name:      "clock_drift_checks_total",
 			Help:      "Total NTP clock drift checks by result",
 		}, []string{"status"}),
-		OPCUAClockDrift: promauto.NewGaugeVec(prometheus.GaugeOpts{
+		OPCUAClockDrift: factory.NewGaugeVec(prometheus.GaugeOpts{
 			Namespace: "gateway",
 			Subsystem: "opcua",
 			Name:      "clock_drift_seconds",
@@ -243,27 +413,49 @@ func NewRegistry() *Registry {
 		}, []string{"device_id"}),
 
 		// Certificate metrics
-		OPCUACertsTotal: promauto.NewGaugeVec(prometheus.GaugeOpts{
+		OPCUACertsTotal: factory.NewGaugeVec(prometheus.GaugeOpts{
 			Namespace: "gateway",
 			Subsystem: "opcua",
 			Name:      "certs_total",
 			Help:      "Number of certificates in the trust store by store type",
 		}, []string{"store"}),
-		OPCUACertExpiry: promauto.NewGaugeVec(prometheus.GaugeOpts{
+		OPCUACertExpiry: factory.NewGaugeVec(prometheus.GaugeOpts{
 			Namespace: "gateway",
 			Subsystem: "opcua",
 			Name:      "cert_expiry_days",
 			Help:      "Days until certificate expiry (negative = already expired)",
 		}, []string{"fingerprint", "subject"}),
 
+		// OPC UA Alarms & Conditions metrics
+		OPCUAAlarmEvents: factory.NewCounterVec(prometheus.CounterOpts{
+			Namespace: "gateway",
+			Subsystem: "opcua",
+			Name:      "alarm_events_total",
+			Help:      "Total OPC UA alarm events received by device and state (active/inactive)",
+		}, []string{"device_id", "state"}),
+
+		// OPC UA history backfill metrics
+		OPCUABackfillGaps: factory.NewCounterVec(prometheus.CounterOpts{
+			Namespace: "gateway",
+			Subsystem: "opcua",
+			Name:      "backfill_gaps_total",
+			Help:      "Total per-device data gaps handled by history backfill, by result (ok/unsupported/failed)",
+		}, []string{"device_id", "result"}),
+		OPCUABackfillPoints: factory.NewCounterVec(prometheus.CounterOpts{
+			Namespace: "gateway",
+			Subsystem: "opcua",
+			Name:      "backfill_points_total",
+			Help:      "Total values recovered from OPC UA history and published as backfilled",
+		}, []string{"device_id"}),
+
 		// System metrics
-		GoroutineCount: promauto.NewGauge(prometheus.GaugeOpts{
+		GoroutineCount: factory.NewGauge(prometheus.GaugeOpts{
 			Namespace: "gateway",
 			Subsystem: "system",
 			Name:      "goroutines",
 			Help:      "Number of running goroutines",
 		}),
-		MemoryUsage: promauto.NewGauge(prometheus.GaugeOpts{
+		MemoryUsage: factory.NewGauge(prometheus.GaugeOpts{
 			Namespace: "gateway",
 			Subsystem: "system",
 			Name:      "memory_bytes",
@@ -314,6 +506,17 @@ func (r *Registry) UpdateMQTTBufferSize(size int) {
 	r.MQTTBufferSize.Set(float64(size))
 }
 
+// UpdateMQTTBacklog updates the store-and-forward backlog gauges and adds
+// newly dropped messages to the dropped counter.
+func (r *Registry) UpdateMQTTBacklog(messages int, bytes int64, oldestAge float64, dropped uint64) {
+	r.MQTTBacklogMessages.Set(float64(messages))
+	r.MQTTBacklogBytes.Set(float64(bytes))
+	r.MQTTBacklogOldestAge.Set(oldestAge)
+	if dropped > 0 {
+		r.MQTTBacklogDropped.Add(float64(dropped))
+	}
+}
+
 // RecordConnectionForProtocol records a connection attempt for a specific protocol.
 func (r *Registry) RecordConnectionForProtocol(protocol string, success bool, latency float64) {
 	r.ConnectionsTotalByProtocol.WithLabelValues(protocol).Inc()
@@ -364,6 +567,111 @@ func (r *Registry) RecordS7BreakerState(deviceID string, state int) {
 	r.S7BreakerState.WithLabelValues(deviceID).Set(float64(state))
 }
 
+// RecordModbusBusRequest records a request on a shared Modbus bus.
+func (r *Registry) RecordModbusBusRequest(port string, success, timeout bool) {
+	status := "success"
+	if !success {
+		status = "error"
+	}
+	r.ModbusBusRequestsTotal.WithLabelValues(port, status).Inc()
+	if timeout {
+		r.ModbusBusTimeoutsTotal.WithLabelValues(port).Inc()
+	}
+}
+
+// UpdateModbusBusStats updates the utilization and queue depth gauges for a shared Modbus bus.
+func (r *Registry) UpdateModbusBusStats(port string, utilization float64, queueDepth int) {
+	r.ModbusBusUtilization.WithLabelValues(port).Set(utilization)
+	r.ModbusBusQueueDepth.WithLabelValues(port).Set(float64(queueDepth))
+}
+
+// RemoveModbusBus drops the series of a closed shared Modbus bus.
+func (r *Registry) RemoveModbusBus(port string) {
+	r.ModbusBusRequestsTotal.DeletePartialMatch(prometheus.Labels{"port": port})
+	r.ModbusBusTimeoutsTotal.DeleteLabelValues(port)
+	r.ModbusBusUtilization.DeleteLabelValues(port)
+	r.ModbusBusQueueDepth.DeleteLabelValues(port)
+}
+
+// RecordModbusDeviceConnected updates the Modbus device connection state gauge.
+func (r *Registry) RecordModbusDeviceConnected(deviceID string, connected bool) {
+	val := 0.0
+	if connected {
+		val = 1.0
+	}
+	r.ModbusDeviceConnected.WithLabelValues(deviceID).Set(val)
+}
+
+// RecordModbusRequest records one Modbus request. Status is ok, exception,
+// timeout or error; exception names the exception code for status exception.
+func (r *Registry) RecordModbusRequest(deviceID, functionCode, status, exception string, duration float64) {
+	r.ModbusRequestsTotal.WithLabelValues(deviceID, functionCode, status).Inc()
+	r.ModbusRequestDuration.WithLabelValues(deviceID, functionCode).Observe(duration)
+	if exception != "" {
+		r.ModbusExceptionsTotal.WithLabelValues(deviceID, functionCode, exception).Inc()
+	}
+}
+
+// RecordModbusBatch records a batched read: the registers covered by its tags
+// and the registers actually read.
+func (r *Registry) RecordModbusBatch(deviceID, registerType string, requested, read int) {
+	r.ModbusRegistersRequested.WithLabelValues(deviceID, registerType).Add(float64(requested))
+	r.ModbusRegistersRead.WithLabelValues(deviceID, registerType).Add(float64(read))
+}
+
+// RecordModbusTagError increments the Modbus tag error counter.
+func (r *Registry) RecordModbusTagError(deviceID, tagID string) {
+	r.ModbusTagErrorsTotal.WithLabelValues(deviceID, tagID).Inc()
+}
+
+// RecordModbusBreakerState updates the Modbus circuit breaker state gauge.
+// 0=closed (normal), 1=half-open (probing), 2=open (blocking).
+func (r *Registry) RecordModbusBreakerState(deviceID string, state int) {
+	r.ModbusBreakerState.WithLabelValues(deviceID).Set(float64(state))
+}
+
+// RemoveModbusDevice drops the per-device series of a removed Modbus device.
+func (r *Registry) RemoveModbusDevice(deviceID string) {
+	labels := prometheus.Labels{"device_id": deviceID}
+	r.ModbusDeviceConnected.DeleteLabelValues(deviceID)
+	r.ModbusBreakerState.DeleteLabelValues(deviceID)
+	r.ModbusRequestsTotal.DeletePartialMatch(labels)
+	r.ModbusRequestDuration.DeletePartialMatch(labels)
+	r.ModbusExceptionsTotal.DeletePartialMatch(labels)
+	r.ModbusRegistersRequested.DeletePartialMatch(labels)
+	r.ModbusRegistersRead.DeletePartialMatch(labels)
+	r.ModbusTagErrorsTotal.DeletePartialMatch(labels)
+}
+
+// RecordMQTTSourceMessage counts a message received for an MQTT source device.
+func (r *Registry) RecordMQTTSourceMessage(deviceID, result string) {
+	r.MQTTSourceMessagesTotal.WithLabelValues(deviceID, result).Inc()
+}
+
+// RecordMQTTSourceDeviceStale updates the staleness gauge of an MQTT source device.
+func (r *Registry) RecordMQTTSourceDeviceStale(deviceID string, stale bool) {
+	val := 0.0
+	if stale {
+		val = 1.0
+	}
+	r.MQTTSourceDeviceStale.WithLabelValues(deviceID).Set(val)
+}
+
+// RemoveMQTTSourceDevice drops the per-device series of an unsubscribed MQTT source device.
+func (r *Registry) RemoveMQTTSourceDevice(deviceID string) {
+	r.MQTTSourceDeviceStale.DeleteLabelValues(deviceID)
+	r.MQTTSourceMessagesTotal.DeletePartialMatch(prometheus.Labels{"device_id": deviceID})
+}
+
+// RecordMQTTSourceBrokerConnected updates the connection gauge of a source broker.
+func (r *Registry) RecordMQTTSourceBrokerConnected(broker string, connected bool) {
+	val := 0.0
+	if connected {
+		val = 1.0
+	}
+	r.MQTTSourceBrokerConnected.WithLabelValues(broker).Set(val)
+}
+
 // RecordClockDrift records the current NTP clock offset.
 func (r *Registry) RecordClockDrift(offsetSeconds float64, success bool) {
 	if success {
@@ -390,6 +698,23 @@ func (r *Registry) RecordOPCUACertExpiry(fingerprint, subject string, daysUntilE
 	r.OPCUACertExpiry.WithLabelValues(fingerprint, subject).Set(float64(daysUntilExpiry))
 }
 
+// RecordOPCUAAlarmEvent records an alarm event received from an OPC UA device.
+func (r *Registry) RecordOPCUAAlarmEvent(deviceID string, active bool) {
+	state := "inactive"
+	if active {
+		state = "active"
+	}
+	r.OPCUAAlarmEvents.WithLabelValues(deviceID, state).Inc()
+}
+
+// RecordOPCUABackfill records the history backfill of a device's gap.
+func (r *Registry) RecordOPCUABackfill(deviceID, result string, points int) {
+	r.OPCUABackfillGaps.WithLabelValues(deviceID, result).Inc()
+	if points > 0 {
+		r.OPCUABackfillPoints.WithLabelValues(deviceID).Add(float64(points))
+	}
+}
+
 // UpdateSystemMetrics updates the system resource metrics (goroutines, memory).
 func (r *Registry) UpdateSystemMetrics() {
 	r.GoroutineCount.Set(float64(runtime.NumGoroutine()))
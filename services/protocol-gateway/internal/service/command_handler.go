@@ -40,7 +40,9 @@ type CommandHandler struct {
 func (h *CommandHandler) SubscribedTopics() []string {
 	writeTopic := fmt.Sprintf("%s/+/write", h.config.CommandTopicPrefix)
 	tagWriteTopic := fmt.Sprintf("%s/+/+/set", h.config.CommandTopicPrefix)
-	return []string{writeTopic, tagWriteTopic}
+	alarmTopic := fmt.Sprintf("%s/+/alarm", h.config.CommandTopicPrefix)
+	callTopic := fmt.Sprintf("%s/+/call", h.config.CommandTopicPrefix)
+	return []string{writeTopic, tagWriteTopic, alarmTopic, callTopic}
 }
 
 // CommandConfig holds configuration for the command handler.
@@ -105,6 +107,9 @@ type WriteCommand struct {
 	// Value is the value to write
 	Value interface{} `json:"value"`
 
+	// Writes is a set of tag writes applied as one request, instead of TagID and Value
+	Writes []TagValue `json:"writes,omitempty"`
+
 	// Timestamp is when the command was issued
 	Timestamp time.Time `json:"timestamp,omitempty"`
 
@@ -112,6 +117,27 @@ type WriteCommand struct {
 	Priority int `json:"priority,omitempty"`
 }
 
+// TagValue is one tag write of a multi-tag write command.
+type TagValue struct {
+	// TagID is the target tag ID
+	TagID string `json:"tag_id"`
+
+	// Value is the value to write
+	Value interface{} `json:"value"`
+}
+
+// WriteResult is the outcome of one tag write of a multi-tag write command.
+type WriteResult struct {
+	// TagID is the target tag ID
+	TagID string `json:"tag_id"`
+
+	// Success indicates whether the write succeeded
+	Success bool `json:"success"`
+
+	// Error contains the error message if the write failed
+	Error string `json:"error,omitempty"`
+}
+
 // WriteResponse represents the response to a write command.
 type WriteResponse struct {
 	// RequestID correlates with the original command
@@ -129,6 +155,9 @@ type WriteResponse struct {
 	// Error contains the error message if the write failed
 	Error string `json:"error,omitempty"`
 
+	// Results holds the outcome of each write of a multi-tag write command
+	Results []WriteResult `json:"results,omitempty"`
+
 	// Timestamp is when the response was generated
 	Timestamp time.Time `json:"timestamp"`
 
@@ -136,6 +165,101 @@ type WriteResponse struct {
 	Duration time.Duration `json:"duration_ms"`
 }
 
+// AlarmCommand represents an acknowledge or confirm request for an alarm condition.
+type AlarmCommand struct {
+	// RequestID is a unique identifier for the command (for correlation)
+	RequestID string `json:"request_id,omitempty"`
+
+	// DeviceID is the target device ID
+	DeviceID string `json:"device_id"`
+
+	// ConditionID identifies the condition (from the alarm event)
+	ConditionID string `json:"condition_id"`
+
+	// EventID is the event being acknowledged; empty means the latest event
+	EventID string `json:"event_id,omitempty"`
+
+	// Action is "acknowledge" (default) or "confirm"
+	Action domain.AlarmAction `json:"action,omitempty"`
+
+	// Comment is the operator comment recorded with the action
+	Comment string `json:"comment,omitempty"`
+}
+
+// AlarmResponse represents the response to an alarm command.
+type AlarmResponse struct {
+	// RequestID correlates with the original command
+	RequestID string `json:"request_id,omitempty"`
+
+	// DeviceID is the target device ID
+	DeviceID string `json:"device_id"`
+
+	// ConditionID is the target condition
+	ConditionID string `json:"condition_id"`
+
+	// Action is the executed action
+	Action domain.AlarmAction `json:"action"`
+
+	// Success indicates whether the action succeeded
+	Success bool `json:"success"`
+
+	// Error contains the error message if the action failed
+	Error string `json:"error,omitempty"`
+
+	// Timestamp is when the response was generated
+	Timestamp time.Time `json:"timestamp"`
+}
+
+// MethodCommand represents a method call on a device (e.g., an OPC UA Method).
+type MethodCommand struct {
+	// RequestID is a unique identifier for the command (for correlation)
+	RequestID string `json:"request_id,omitempty"`
+
+	// DeviceID is the target device ID
+	DeviceID string `json:"device_id"`
+
+	// ObjectID is the node ID of the object the method is called on
+	ObjectID string `json:"object_id"`
+
+	// MethodID is the node ID of the method
+	MethodID string `json:"method_id"`
+
+	// Inputs are the positional input arguments
+	Inputs []interface{} `json:"inputs,omitempty"`
+
+	outputs []domain.MethodArgument // Set by a successful call
+}
+
+// MethodResponse represents the response to a method call.
+type MethodResponse struct {
+	// RequestID correlates with the original command
+	RequestID string `json:"request_id,omitempty"`
+
+	// DeviceID is the target device ID
+	DeviceID string `json:"device_id"`
+
+	// ObjectID is the object the method was called on
+	ObjectID string `json:"object_id"`
+
+	// MethodID is the called method
+	MethodID string `json:"method_id"`
+
+	// Success indicates whether the call succeeded
+	Success bool `json:"success"`
+
+	// Outputs are the method's output arguments
+	Outputs []domain.MethodArgument `json:"outputs,omitempty"`
+
+	// Error contains the error message if the call failed
+	Error string `json:"error,omitempty"`
+
+	// Timestamp is when the response was generated
+	Timestamp time.Time `json:"timestamp"`
+
+	// Duration is how long the call took
+	Duration time.Duration `json:"duration_ms"`
+}
+
 // NewCommandHandler creates a new command handler.
 func NewCommandHandler(
 	mqttClient mqtt.Client,
@@ -212,6 +336,20 @@ func (h *CommandHandler) Start() error {
 		return fmt.Errorf("%w: %v", domain.ErrMQTTSubscribeFailed, token.Error())
 	}
 
+	// Alarm acknowledge/confirm commands: $nexus/cmd/{device_id}/alarm
+	alarmTopic := fmt.Sprintf("%s/+/alarm", h.config.CommandTopicPrefix)
+	token = h.mqttClient.Subscribe(alarmTopic, h.config.QoS, h.handleAlarmCommand)
+	if token.Wait() && token.Error() != nil {
+		return fmt.Errorf("%w: %v", domain.ErrMQTTSubscribeFailed, token.Error())
+	}
+
+	// Method calls: $nexus/cmd/{device_id}/call
+	callTopic := fmt.Sprintf("%s/+/call", h.config.CommandTopicPrefix)
+	token = h.mqttClient.Subscribe(callTopic, h.config.QoS, h.handleMethodCommand)
+	if token.Wait() && token.Error() != nil {
+		return fmt.Errorf("%w: %v", domain.ErrMQTTSubscribeFailed, token.Error())
+	}
+
 	h.running.Store(true)
 	h.logger.Info().Msg("Command handler started")
 
@@ -233,6 +371,12 @@ func (h *CommandHandler) Stop() error {
 	tagWriteTopic := fmt.Sprintf("%s/+/+/set", h.config.CommandTopicPrefix)
 	h.mqttClient.Unsubscribe(tagWriteTopic)
 
+	alarmTopic := fmt.Sprintf("%s/+/alarm", h.config.CommandTopicPrefix)
+	h.mqttClient.Unsubscribe(alarmTopic)
+
+	callTopic := fmt.Sprintf("%s/+/call", h.config.CommandTopicPrefix)
+	h.mqttClient.Unsubscribe(callTopic)
+
 	h.wg.Wait()
 	h.running.Store(false)
 
@@ -278,7 +422,7 @@ func (h *CommandHandler) drainCommandQueue() {
 
 // handleWriteCommand handles JSON write commands.
 // Topic: $nexus/cmd/{device_id}/write
-// Payload: {"tag_id": "...", "value": ...}
+// Payload: {"tag_id": "...", "value": ...} or {"writes": [{"tag_id": "...", "value": ...}, ...]}
 func (h *CommandHandler) handleWriteCommand(client mqtt.Client, msg mqtt.Message) {
 	h.stats.CommandsReceived.Add(1)
 
@@ -398,6 +542,11 @@ func (h *CommandHandler) processWriteCommand(cmd WriteCommand) {
 		return
 	}
 
+	if len(cmd.Writes) > 0 {
+		h.processMultiWrite(cmd, startTime)
+		return
+	}
+
 	// Get device and tag (O(1) lookups)
 	h.devicesMu.RLock()
 	device, exists := h.devices[cmd.DeviceID]
@@ -457,21 +606,103 @@ func (h *CommandHandler) processWriteCommand(cmd WriteCommand) {
 	h.stats.CommandsSucceeded.Add(1)
 }
 
+// processMultiWrite applies the writes of a multi-tag write command with one
+// WriteTags call, so protocols can merge them into as few requests as possible.
+// Nothing is written unless every tag exists, is writable and is named once.
+func (h *CommandHandler) processMultiWrite(cmd WriteCommand, startTime time.Time) {
+	h.devicesMu.RLock()
+	device, exists := h.devices[cmd.DeviceID]
+	tagMap := h.tagByID[cmd.DeviceID]
+	h.devicesMu.RUnlock()
+
+	if !exists {
+		h.sendResponse(cmd, false, "device not found", time.Since(startTime))
+		h.stats.CommandsFailed.Add(1)
+		return
+	}
+
+	writes := make([]domain.TagWrite, len(cmd.Writes))
+	seen := make(map[string]bool, len(cmd.Writes))
+	for i, w := range cmd.Writes {
+		if seen[w.TagID] {
+			h.sendResponse(cmd, false, fmt.Sprintf("tag %s is written more than once", w.TagID), time.Since(startTime))
+			h.stats.CommandsFailed.Add(1)
+			return
+		}
+		seen[w.TagID] = true
+		tag := tagMap[w.TagID]
+		if tag == nil {
+			h.sendResponse(cmd, false, fmt.Sprintf("tag %s not found", w.TagID), time.Since(startTime))
+			h.stats.CommandsFailed.Add(1)
+			return
+		}
+		if !tag.IsWritable() {
+			h.sendResponse(cmd, false, fmt.Sprintf("tag %s is not writable", w.TagID), time.Since(startTime))
+			h.stats.CommandsFailed.Add(1)
+			return
+		}
+		writes[i] = domain.TagWrite{Tag: tag, Value: w.Value}
+	}
+
+	ctx, cancel := context.WithTimeout(h.ctx, h.config.WriteTimeout)
+	defer cancel()
+
+	errs := h.protocolManager.WriteTags(ctx, device, writes)
+
+	results := make([]WriteResult, len(cmd.Writes))
+	failed := 0
+	for i, w := range cmd.Writes {
+		results[i] = WriteResult{TagID: w.TagID, Success: errs[i] == nil}
+		if errs[i] != nil {
+			results[i].Error = errs[i].Error()
+			failed++
+		}
+	}
+
+	duration := time.Since(startTime)
+	if failed > 0 {
+		h.logger.Error().
+			Str("device_id", cmd.DeviceID).
+			Int("writes", len(writes)).
+			Int("failed", failed).
+			Msg("Multi-tag write command failed")
+		h.publishResponse(cmd, WriteResponse{
+			Success: false,
+			Error:   fmt.Sprintf("%d of %d writes failed", failed, len(writes)),
+			Results: results,
+		}, duration)
+		h.stats.CommandsFailed.Add(1)
+		return
+	}
+
+	h.logger.Debug().
+		Str("device_id", cmd.DeviceID).
+		Int("writes", len(writes)).
+		Dur("duration", duration).
+		Msg("Multi-tag write command succeeded")
+
+	h.publishResponse(cmd, WriteResponse{Success: true, Results: results}, duration)
+	h.stats.CommandsSucceeded.Add(1)
+}
+
 // sendResponse publishes a response to the command.
 func (h *CommandHandler) sendResponse(cmd WriteCommand, success bool, errMsg string, duration time.Duration) {
+	h.publishResponse(cmd, WriteResponse{Success: success, Error: errMsg}, duration)
+}
+
+// publishResponse completes a write response from the command and publishes it.
+// Topic: $nexus/cmd/response/{device_id}/{tag_id}, or
+// $nexus/cmd/response/{device_id}/write for multi-tag writes
+func (h *CommandHandler) publishResponse(cmd WriteCommand, response WriteResponse, duration time.Duration) {
 	if !h.config.EnableAcknowledgement {
 		return
 	}
 
-	response := WriteResponse{
-		RequestID: cmd.RequestID,
-		DeviceID:  cmd.DeviceID,
-		TagID:     cmd.TagID,
-		Success:   success,
-		Error:     errMsg,
-		Timestamp: time.Now(),
-		Duration:  duration,
-	}
+	response.RequestID = cmd.RequestID
+	response.DeviceID = cmd.DeviceID
+	response.TagID = cmd.TagID
+	response.Timestamp = time.Now()
+	response.Duration = duration
 
 	payload, err := json.Marshal(response)
 	if err != nil {
@@ -479,15 +710,235 @@ func (h *CommandHandler) sendResponse(cmd WriteCommand, success bool, errMsg str
 		return
 	}
 
-	// Publish response
-	// Topic: $nexus/cmd/response/{device_id}/{tag_id}
-	topic := fmt.Sprintf("%s/%s/%s", h.config.ResponseTopicPrefix, cmd.DeviceID, cmd.TagID)
+	target := cmd.TagID
+	if len(cmd.Writes) > 0 {
+		target = "write"
+	}
+	topic := fmt.Sprintf("%s/%s/%s", h.config.ResponseTopicPrefix, cmd.DeviceID, target)
 	token := h.mqttClient.Publish(topic, h.config.QoS, false, payload)
 	if token.Wait() && token.Error() != nil {
 		h.logger.Error().Err(token.Error()).Msg("Failed to publish response")
 	}
 }
 
+// handleAlarmCommand handles alarm acknowledge/confirm commands.
+// Topic: $nexus/cmd/{device_id}/alarm
+// Payload: {"condition_id": "...", "event_id": "...", "action": "acknowledge", "comment": "..."}
+func (h *CommandHandler) handleAlarmCommand(client mqtt.Client, msg mqtt.Message) {
+	h.dispatchCommand(msg, &AlarmCommand{})
+}
+
+// handleMethodCommand handles method call commands.
+// Topic: $nexus/cmd/{device_id}/call
+// Payload: {"object_id": "ns=2;s=Line1", "method_id": "ns=2;s=Line1.StartBatch", "inputs": ["B-1042", 500]}
+func (h *CommandHandler) handleMethodCommand(client mqtt.Client, msg mqtt.Message) {
+	h.dispatchCommand(msg, &MethodCommand{})
+}
+
+// deviceCommand is an operator command on one device that bypasses the write
+// queue: alarm actions and method calls.
+type deviceCommand interface {
+	// kind names the command in logs and is the last segment of its command
+	// and response topics.
+	kind() string
+
+	// target sets the device ID taken from the topic and fills in defaults.
+	target(deviceID string)
+
+	// deviceID returns the target device ID.
+	deviceID() string
+
+	// execute runs the command on the device's protocol pool.
+	execute(ctx context.Context, pool domain.ProtocolPool, device *domain.Device) error
+
+	// response builds the response payload; an empty errMsg means success.
+	response(errMsg string, duration time.Duration) interface{}
+
+	// logFields adds the command's identifying fields to a log event.
+	logFields(event *zerolog.Event) *zerolog.Event
+}
+
+// dispatchCommand parses a device command and executes it in its own
+// goroutine. These commands are rare operator actions; they bypass the write
+// queue but share the write semaphore.
+func (h *CommandHandler) dispatchCommand(msg mqtt.Message, cmd deviceCommand) {
+	h.stats.CommandsReceived.Add(1)
+
+	parts := strings.Split(msg.Topic(), "/")
+	if len(parts) < 3 {
+		h.logger.Warn().
+			Str("topic", msg.Topic()).
+			Str("command", cmd.kind()).
+			Msg("Invalid command topic format")
+		h.stats.CommandsRejected.Add(1)
+		return
+	}
+
+	if err := json.Unmarshal(msg.Payload(), cmd); err != nil {
+		h.logger.Warn().
+			Err(err).
+			Str("topic", msg.Topic()).
+			Str("command", cmd.kind()).
+			Msg("Failed to parse command")
+		h.stats.CommandsRejected.Add(1)
+		return
+	}
+	cmd.target(parts[len(parts)-2])
+
+	h.wg.Add(1)
+	go func() {
+		defer h.wg.Done()
+		h.processDeviceCommand(cmd)
+	}()
+}
+
+// processDeviceCommand executes a device command on the device's protocol
+// pool and publishes its response.
+func (h *CommandHandler) processDeviceCommand(cmd deviceCommand) {
+	startTime := time.Now()
+
+	select {
+	case h.writeSemaphore <- struct{}{}:
+		defer func() { <-h.writeSemaphore }()
+	case <-h.ctx.Done():
+		h.sendCommandResponse(cmd, "service shutting down", time.Since(startTime))
+		h.stats.CommandsRejected.Add(1)
+		return
+	default:
+		h.sendCommandResponse(cmd, "rate limit exceeded, too many concurrent writes", time.Since(startTime))
+		h.stats.CommandsRejected.Add(1)
+		return
+	}
+
+	h.devicesMu.RLock()
+	device, exists := h.devices[cmd.deviceID()]
+	h.devicesMu.RUnlock()
+	if !exists {
+		h.sendCommandResponse(cmd, "device not found", time.Since(startTime))
+		h.stats.CommandsFailed.Add(1)
+		return
+	}
+
+	// A missing pool is reported by execute like one without the capability
+	pool, _ := h.protocolManager.GetPool(device.Protocol)
+
+	ctx, cancel := context.WithTimeout(h.ctx, h.config.WriteTimeout)
+	defer cancel()
+
+	err := cmd.execute(ctx, pool, device)
+	duration := time.Since(startTime)
+	if err != nil {
+		cmd.logFields(h.logger.Error().Err(err).Str("command", cmd.kind())).
+			Dur("duration", duration).
+			Msg("Device command failed")
+		h.sendCommandResponse(cmd, err.Error(), duration)
+		h.stats.CommandsFailed.Add(1)
+		return
+	}
+
+	cmd.logFields(h.logger.Info().Str("command", cmd.kind())).
+		Dur("duration", duration).
+		Msg("Device command succeeded")
+
+	h.sendCommandResponse(cmd, "", duration)
+	h.stats.CommandsSucceeded.Add(1)
+}
+
+// sendCommandResponse publishes the response to a device command.
+// Topic: $nexus/cmd/response/{device_id}/{kind}
+func (h *CommandHandler) sendCommandResponse(cmd deviceCommand, errMsg string, duration time.Duration) {
+	if !h.config.EnableAcknowledgement {
+		return
+	}
+
+	payload, err := json.Marshal(cmd.response(errMsg, duration))
+	if err != nil {
+		h.logger.Error().Err(err).Str("command", cmd.kind()).Msg("Failed to marshal command response")
+		return
+	}
+
+	topic := fmt.Sprintf("%s/%s/%s", h.config.ResponseTopicPrefix, cmd.deviceID(), cmd.kind())
+	token := h.mqttClient.Publish(topic, h.config.QoS, false, payload)
+	if token.Wait() && token.Error() != nil {
+		h.logger.Error().Err(token.Error()).Str("command", cmd.kind()).Msg("Failed to publish command response")
+	}
+}
+
+func (c *AlarmCommand) kind() string     { return "alarm" }
+func (c *AlarmCommand) deviceID() string { return c.DeviceID }
+
+func (c *AlarmCommand) target(deviceID string) {
+	c.DeviceID = deviceID
+	if c.Action == "" {
+		c.Action = domain.AlarmActionAcknowledge
+	}
+}
+
+func (c *AlarmCommand) execute(ctx context.Context, pool domain.ProtocolPool, device *domain.Device) error {
+	acknowledger, ok := pool.(domain.AlarmAcknowledger)
+	if !ok {
+		return fmt.Errorf("alarms not supported for protocol %s", device.Protocol)
+	}
+	return acknowledger.AcknowledgeAlarm(ctx, device, c.ConditionID, c.EventID, c.Action, c.Comment)
+}
+
+func (c *AlarmCommand) response(errMsg string, _ time.Duration) interface{} {
+	return AlarmResponse{
+		RequestID:   c.RequestID,
+		DeviceID:    c.DeviceID,
+		ConditionID: c.ConditionID,
+		Action:      c.Action,
+		Success:     errMsg == "",
+		Error:       errMsg,
+		Timestamp:   time.Now(),
+	}
+}
+
+func (c *AlarmCommand) logFields(event *zerolog.Event) *zerolog.Event {
+	return event.
+		Str("device_id", c.DeviceID).
+		Str("condition_id", c.ConditionID).
+		Str("action", string(c.Action))
+}
+
+func (c *MethodCommand) kind() string           { return "call" }
+func (c *MethodCommand) deviceID() string       { return c.DeviceID }
+func (c *MethodCommand) target(deviceID string) { c.DeviceID = deviceID }
+
+func (c *MethodCommand) execute(ctx context.Context, pool domain.ProtocolPool, device *domain.Device) error {
+	caller, ok := pool.(domain.MethodCaller)
+	if !ok {
+		return fmt.Errorf("method calls not supported for protocol %s", device.Protocol)
+	}
+	outputs, err := caller.CallMethod(ctx, device, c.ObjectID, c.MethodID, c.Inputs)
+	c.outputs = outputs
+	return err
+}
+
+func (c *MethodCommand) response(errMsg string, duration time.Duration) interface{} {
+	response := MethodResponse{
+		RequestID: c.RequestID,
+		DeviceID:  c.DeviceID,
+		ObjectID:  c.ObjectID,
+		MethodID:  c.MethodID,
+		Success:   errMsg == "",
+		Error:     errMsg,
+		Timestamp: time.Now(),
+		Duration:  duration,
+	}
+	if response.Success {
+		response.Outputs = c.outputs
+	}
+	return response
+}
+
+func (c *MethodCommand) logFields(event *zerolog.Event) *zerolog.Event {
+	return event.
+		Str("device_id", c.DeviceID).
+		Str("object_id", c.ObjectID).
+		Str("method_id", c.MethodID)
+}
+
 // UpdateDevices updates the device list.
 func (h *CommandHandler) UpdateDevices(devices []*domain.Device) {
 	h.devicesMu.Lock()
@@ -523,11 +974,15 @@ func (h *CommandHandler) RemoveDevice(deviceID string) {
 	h.logger.Debug().Str("device_id", deviceID).Msg("Removed device")
 }
 
-// buildTagIndex creates a map of tagID -> *Tag for O(1) lookup.
+// buildTagIndex creates a map of tagID -> *Tag for O(1) lookup, including the
+// sub-points of bit-field tags.
 func buildTagIndex(device *domain.Device) map[string]*domain.Tag {
 	tagMap := make(map[string]*domain.Tag, len(device.Tags))
 	for i := range device.Tags {
 		tagMap[device.Tags[i].ID] = &device.Tags[i]
+		for _, sub := range device.Tags[i].BitFieldTags() {
+			tagMap[sub.ID] = sub
+		}
 	}
 	return tagMap
 }
@@ -0,0 +1,42 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/nexus-edge/protocol-gateway/internal/domain"
+	"github.com/rs/zerolog"
+)
+
+func TestProcessMultiWrite_RejectsDuplicateTags(t *testing.T) {
+	pool := &recordingPool{}
+	manager := domain.NewProtocolManager()
+	manager.RegisterPool(domain.ProtocolModbusTCP, pool)
+
+	setpoint := &domain.Tag{ID: "setpoint", AccessMode: domain.AccessModeReadWrite}
+	speed := &domain.Tag{ID: "speed", AccessMode: domain.AccessModeReadWrite}
+	h := &CommandHandler{
+		protocolManager: manager,
+		devices:         map[string]*domain.Device{"plc": {ID: "plc", Protocol: domain.ProtocolModbusTCP}},
+		tagByID:         map[string]map[string]*domain.Tag{"plc": {"setpoint": setpoint, "speed": speed}},
+		logger:          zerolog.Nop(),
+		config:          CommandConfig{WriteTimeout: time.Second},
+		stats:           &CommandStats{},
+		ctx:             context.Background(),
+	}
+
+	h.processMultiWrite(WriteCommand{DeviceID: "plc", Writes: []TagValue{
+		{TagID: "setpoint", Value: 1}, {TagID: "speed", Value: 2}, {TagID: "setpoint", Value: 3},
+	}}, time.Now())
+	if len(pool.writes) != 0 || h.stats.CommandsFailed.Load() != 1 {
+		t.Fatalf("expected the command to be rejected before writing, got %d writes", len(pool.writes))
+	}
+
+	h.processMultiWrite(WriteCommand{DeviceID: "plc", Writes: []TagValue{
+		{TagID: "setpoint", Value: 1}, {TagID: "speed", Value: 2},
+	}}, time.Now())
+	if len(pool.writes) != 1 || len(pool.writes[0]) != 2 || h.stats.CommandsSucceeded.Load() != 1 {
+		t.Errorf("expected one write of two tags, got %v", pool.writes)
+	}
+}
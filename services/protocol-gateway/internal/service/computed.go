@@ -0,0 +1,422 @@
+// Package service provides the evaluation of computed (virtual) tags.
+package service
+
+import (
+	"cmp"
+	"fmt"
+	"math"
+	"slices"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/nexus-edge/protocol-gateway/internal/domain"
+	"github.com/nexus-edge/protocol-gateway/internal/expr"
+	"github.com/rs/zerolog"
+)
+
+// tagKey identifies a tag across devices.
+type tagKey struct {
+	deviceID string
+	tagID    string
+}
+
+// computedTag is an enabled computed tag of a registered device.
+type computedTag struct {
+	key     tagKey
+	device  *domain.Device
+	tag     *domain.Tag
+	expr    *expr.Expr
+	inputs  []tagKey
+	rank    int            // Position in evaluation order: inputs rank lower
+	quality domain.Quality // Quality last reported
+	lastErr string         // Last evaluation error, logged once
+}
+
+// inputValue is the latest value and quality of a tag used as an input.
+type inputValue struct {
+	value   interface{}
+	quality domain.Quality
+}
+
+// computedTags evaluates computed tags. The polling service feeds it every
+// data point read or received; each computed tag is re-evaluated when one of
+// its inputs is updated and returns a data point to publish.
+//
+// Quality propagates: while any input is not good, the computed value is bad
+// (uncertain if no input is worse than uncertain), and a failing evaluation
+// (division by zero, a string where a number is expected) is bad as well.
+// Like read values, good values are published subject to the tag's deadband;
+// a non-good quality is published once when it is entered, so consumers see
+// the value go bad. Tags are not evaluated before each input has been seen
+// once, and computed tags may use each other as long as they form no cycle.
+type computedTags struct {
+	maxSilence time.Duration
+	logger     zerolog.Logger
+
+	mu         sync.Mutex
+	devices    map[string][]*computedTag // Computed tags by owning device
+	filters    map[string]*exceptionFilter
+	dependents map[tagKey][]*computedTag // Computed tags by input
+	values     map[tagKey]inputValue     // Latest value of each input
+}
+
+// newComputedTags creates an evaluator; maxSilence is the deadband heartbeat
+// as for read values.
+func newComputedTags(maxSilence time.Duration, logger zerolog.Logger) *computedTags {
+	return &computedTags{
+		maxSilence: maxSilence,
+		logger:     logger,
+		devices:    make(map[string][]*computedTag),
+		filters:    make(map[string]*exceptionFilter),
+		dependents: make(map[tagKey][]*computedTag),
+		values:     make(map[tagKey]inputValue),
+	}
+}
+
+// setDevice registers the computed tags of a device, replacing those of a
+// previous configuration.
+func (c *computedTags) setDevice(device *domain.Device) {
+	tags := make([]*computedTag, 0)
+	for i := range device.Tags {
+		tag := &device.Tags[i]
+		if !tag.Enabled || !tag.IsComputed() {
+			continue
+		}
+		e, err := expr.Parse(tag.Expression)
+		if err != nil {
+			c.logger.Error().Err(err).Str("device_id", device.ID).Str("tag_id", tag.ID).Msg("Invalid computed tag expression")
+			continue
+		}
+		ct := &computedTag{key: tagKey{device.ID, tag.ID}, device: device, tag: tag, expr: e}
+		for _, ref := range e.Refs() {
+			input := tagKey{ref.Device, ref.Tag}
+			if input.deviceID == "" {
+				input.deviceID = device.ID
+			}
+			ct.inputs = append(ct.inputs, input)
+		}
+		tags = append(tags, ct)
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.devices, device.ID)
+	delete(c.filters, device.ID)
+	if len(tags) > 0 {
+		c.devices[device.ID] = tags
+		c.filters[device.ID] = newExceptionFilter(c.maxSilence)
+	}
+	c.rebuild()
+}
+
+// removeDevice drops the computed tags of a device. Its tags count as bad
+// inputs from now on.
+func (c *computedTags) removeDevice(deviceID string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for key := range c.values {
+		if key.deviceID == deviceID {
+			c.values[key] = inputValue{quality: domain.QualityBad}
+		}
+	}
+	if _, ok := c.devices[deviceID]; !ok {
+		return
+	}
+	delete(c.devices, deviceID)
+	delete(c.filters, deviceID)
+	c.rebuild()
+}
+
+// rebuild indexes the computed tags by input and ranks them so that a tag is
+// evaluated after the computed tags it uses. Tags in or depending on a
+// reference cycle are left out. Must be called with c.mu held.
+func (c *computedTags) rebuild() {
+	byKey := make(map[tagKey]*computedTag)
+	for _, tags := range c.devices {
+		for _, ct := range tags {
+			byKey[ct.key] = ct
+		}
+	}
+
+	const (
+		unvisited = iota
+		visiting
+		done
+	)
+	state := make(map[*computedTag]int, len(byKey))
+	cyclic := make(map[*computedTag]bool)
+	rank := 0
+	var visit func(ct *computedTag) bool
+	visit = func(ct *computedTag) bool {
+		switch state[ct] {
+		case visiting:
+			return false
+		case done:
+			return !cyclic[ct]
+		}
+		state[ct] = visiting
+		ok := true
+		for _, input := range ct.inputs {
+			if dep, isComputed := byKey[input]; isComputed && !visit(dep) {
+				ok = false
+			}
+		}
+		state[ct] = done
+		if !ok {
+			cyclic[ct] = true
+			return false
+		}
+		ct.rank = rank
+		rank++
+		return true
+	}
+
+	c.dependents = make(map[tagKey][]*computedTag)
+	used := make(map[tagKey]bool)
+	for _, ct := range byKey {
+		if !visit(ct) {
+			continue
+		}
+		for _, input := range ct.inputs {
+			c.dependents[input] = append(c.dependents[input], ct)
+			used[input] = true
+		}
+	}
+	for ct := range cyclic {
+		c.logger.Error().
+			Str("device_id", ct.key.deviceID).
+			Str("tag_id", ct.key.tagID).
+			Msg("Computed tag depends on a reference cycle, not evaluated")
+	}
+
+	// Forget values no computed tag uses any more
+	for key := range c.values {
+		if !used[key] {
+			delete(c.values, key)
+		}
+	}
+}
+
+// update records the data points of a device and returns the data points of
+// the computed tags to publish. Backfilled points are history, not the
+// current value, so they are ignored.
+func (c *computedTags) update(deviceID string, points []*domain.DataPoint, now time.Time) []*domain.DataPoint {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if len(c.dependents) == 0 {
+		return nil
+	}
+
+	changed := make([]tagKey, 0)
+	for _, point := range points {
+		if point == nil || point.Backfilled {
+			continue
+		}
+		key := tagKey{deviceID, point.TagID}
+		if _, used := c.dependents[key]; used {
+			c.values[key] = inputValue{value: point.Value, quality: point.Quality}
+			changed = append(changed, key)
+		}
+	}
+	return c.evaluate(changed, now)
+}
+
+// markBad records the tags of a failed read as bad and returns the data
+// points of the computed tags to publish.
+func (c *computedTags) markBad(deviceID string, tags []*domain.Tag, now time.Time) []*domain.DataPoint {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if len(c.dependents) == 0 {
+		return nil
+	}
+
+	changed := make([]tagKey, 0)
+	for _, tag := range tags {
+		keys := []tagKey{{deviceID, tag.ID}}
+		for _, sub := range tag.BitFieldTags() {
+			keys = append(keys, tagKey{deviceID, sub.ID})
+		}
+		for _, key := range keys {
+			if _, used := c.dependents[key]; used {
+				c.values[key] = inputValue{quality: domain.QualityBad}
+				changed = append(changed, key)
+			}
+		}
+	}
+	return c.evaluate(changed, now)
+}
+
+// evaluate re-evaluates the computed tags that depend on the changed inputs,
+// directly or through other computed tags, in rank order. Must be called with
+// c.mu held.
+func (c *computedTags) evaluate(changed []tagKey, now time.Time) []*domain.DataPoint {
+	affected := make([]*computedTag, 0)
+	seen := make(map[*computedTag]bool)
+	for _, key := range changed {
+		for _, ct := range c.dependents[key] {
+			if !seen[ct] {
+				seen[ct] = true
+				affected = append(affected, ct)
+			}
+		}
+	}
+	for i := 0; i < len(affected); i++ {
+		for _, ct := range c.dependents[affected[i].key] {
+			if !seen[ct] {
+				seen[ct] = true
+				affected = append(affected, ct)
+			}
+		}
+	}
+	slices.SortFunc(affected, func(a, b *computedTag) int { return cmp.Compare(a.rank, b.rank) })
+
+	var points []*domain.DataPoint
+	for _, ct := range affected {
+		point := c.evaluateTag(ct)
+		if point == nil {
+			continue
+		}
+		if _, used := c.dependents[ct.key]; used {
+			c.values[ct.key] = inputValue{value: point.Value, quality: point.Quality}
+		}
+		if c.report(ct, point, now) {
+			points = append(points, point)
+		}
+	}
+	return points
+}
+
+// evaluateTag evaluates a computed tag, or returns nil if an input has not
+// been seen yet.
+func (c *computedTags) evaluateTag(ct *computedTag) *domain.DataPoint {
+	quality := domain.QualityGood
+	for _, input := range ct.inputs {
+		v, ok := c.values[input]
+		switch {
+		case !ok:
+			return nil
+		case v.quality == domain.QualityUncertain && quality == domain.QualityGood:
+			quality = domain.QualityUncertain
+		case v.quality != domain.QualityGood && v.quality != domain.QualityUncertain:
+			quality = domain.QualityBad
+		}
+	}
+
+	topic := topicForTag(ct.device.UNSPrefix, ct.tag)
+	var value interface{}
+	if quality == domain.QualityGood {
+		result, err := ct.expr.Eval(func(ref expr.Ref) interface{} {
+			key := tagKey{ref.Device, ref.Tag}
+			if key.deviceID == "" {
+				key.deviceID = ct.key.deviceID
+			}
+			return c.values[key].value
+		})
+		if err == nil {
+			value, err = computedValue(result, ct.tag.DataType)
+		}
+		if err != nil {
+			value = nil
+			if err.Error() != ct.lastErr {
+				c.logger.Warn().Err(err).Str("device_id", ct.key.deviceID).Str("tag_id", ct.key.tagID).Msg("Computed tag evaluation failed")
+			}
+			ct.lastErr = err.Error()
+			quality = domain.QualityBad
+		} else {
+			ct.lastErr = ""
+		}
+	}
+
+	return domain.NewDataPoint(ct.key.deviceID, ct.key.tagID, topic, value, ct.tag.Unit, quality).
+		WithPriority(ct.tag.Priority)
+}
+
+// report reports whether a computed data point should be published: good
+// values through the device's deadband filter, other qualities once when
+// entered.
+func (c *computedTags) report(ct *computedTag, point *domain.DataPoint, now time.Time) bool {
+	filter := c.filters[ct.key.deviceID]
+	previous := ct.quality
+	ct.quality = point.Quality
+	if point.Quality == domain.QualityGood {
+		return filter.allow(ct.tag, point, now)
+	}
+	filter.forget(ct.key.tagID)
+	return previous != point.Quality
+}
+
+// computedValue converts an expression result (float64, bool or string) to
+// the data type of a computed tag. Integers are rounded to the nearest value.
+func computedValue(result interface{}, dataType domain.DataType) (interface{}, error) {
+	switch dataType {
+	case domain.DataTypeString:
+		switch v := result.(type) {
+		case string:
+			return v, nil
+		case bool:
+			return strconv.FormatBool(v), nil
+		default:
+			return strconv.FormatFloat(v.(float64), 'g', -1, 64), nil
+		}
+	case domain.DataTypeBool:
+		switch v := result.(type) {
+		case bool:
+			return v, nil
+		case float64:
+			return v != 0, nil
+		}
+		return nil, fmt.Errorf("%w: result %q is not a bool", domain.ErrInvalidDataType, result)
+	}
+
+	var f float64
+	switch v := result.(type) {
+	case float64:
+		f = v
+	case bool:
+		if v {
+			f = 1
+		}
+	default:
+		return nil, fmt.Errorf("%w: result %q is not a number", domain.ErrInvalidDataType, result)
+	}
+
+	inRange := func(min, max float64) (float64, error) {
+		r := math.Round(f)
+		if r < min || r > max {
+			return 0, fmt.Errorf("%w: result %v is out of range for %s", domain.ErrInvalidDataType, f, dataType)
+		}
+		return r, nil
+	}
+	switch dataType {
+	case domain.DataTypeFloat32:
+		return float32(f), nil
+	case domain.DataTypeInt16:
+		r, err := inRange(math.MinInt16, math.MaxInt16)
+		return int16(r), err
+	case domain.DataTypeUInt16:
+		r, err := inRange(0, math.MaxUint16)
+		return uint16(r), err
+	case domain.DataTypeInt32:
+		r, err := inRange(math.MinInt32, math.MaxInt32)
+		return int32(r), err
+	case domain.DataTypeUInt32:
+		r, err := inRange(0, math.MaxUint32)
+		return uint32(r), err
+	case domain.DataTypeInt64:
+		// 2^63 is the first float64 above MaxInt64
+		r, err := inRange(math.MinInt64, math.MaxInt64)
+		if err == nil && r >= math.MaxInt64 {
+			err = fmt.Errorf("%w: result %v is out of range for %s", domain.ErrInvalidDataType, f, dataType)
+		}
+		return int64(r), err
+	case domain.DataTypeUInt64:
+		r, err := inRange(0, math.MaxUint64)
+		if err == nil && r >= math.MaxUint64 {
+			err = fmt.Errorf("%w: result %v is out of range for %s", domain.ErrInvalidDataType, f, dataType)
+		}
+		return uint64(r), err
+	default:
+		return f, nil
+	}
+}
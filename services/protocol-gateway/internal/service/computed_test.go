@@ -0,0 +1,188 @@
+package service
+
+import (
+	"testing"
+	"time"
+
+	"github.com/nexus-edge/protocol-gateway/internal/domain"
+	"github.com/rs/zerolog"
+)
+
+func computedDevice(id string, tags ...domain.Tag) *domain.Device {
+	return &domain.Device{ID: id, UNSPrefix: "plant/" + id, Tags: tags}
+}
+
+func virtualTag(id, expression string, dataType domain.DataType) domain.Tag {
+	return domain.Tag{ID: id, Name: id, TopicSuffix: id, DataType: dataType, Expression: expression, Enabled: true}
+}
+
+func readTag(id string) domain.Tag {
+	return domain.Tag{ID: id, Name: id, TopicSuffix: id, DataType: domain.DataTypeFloat32, Enabled: true}
+}
+
+func pointWithQuality(tagID string, value interface{}, q domain.Quality) *domain.DataPoint {
+	return domain.NewDataPoint("", tagID, "", value, "", q)
+}
+
+func TestComputedTags_CrossDeviceAndQuality(t *testing.T) {
+	c := newComputedTags(0, zerolog.Nop())
+	c.setDevice(computedDevice("meter", readTag("voltage")))
+	c.setDevice(computedDevice("line", readTag("current"),
+		virtualTag("power", "{meter/voltage} * {current}", domain.DataTypeFloat64)))
+	now := time.Now()
+
+	// Not evaluated until every input has been seen
+	if got := c.update("meter", []*domain.DataPoint{point("voltage", float32(230))}, now); len(got) != 0 {
+		t.Fatalf("expected no points before all inputs are known, got %d", len(got))
+	}
+
+	got := c.update("line", []*domain.DataPoint{point("current", int16(2))}, now)
+	if len(got) != 1 || got[0].Value != 460.0 || got[0].Quality != domain.QualityGood {
+		t.Fatalf("expected power 460 (good), got %+v", got)
+	}
+	if got[0].DeviceID != "line" || got[0].TagID != "power" || got[0].Topic != "plant/line/power" {
+		t.Errorf("unexpected identity %s/%s on %s", got[0].DeviceID, got[0].TagID, got[0].Topic)
+	}
+
+	// A bad input makes the result bad, reported once
+	got = c.update("meter", []*domain.DataPoint{pointWithQuality("voltage", nil, domain.QualityTimeout)}, now)
+	if len(got) != 1 || got[0].Quality != domain.QualityBad || got[0].Value != nil {
+		t.Fatalf("expected one bad point, got %+v", got)
+	}
+	if got := c.markBad("meter", []*domain.Tag{{ID: "voltage"}}, now); len(got) != 0 {
+		t.Errorf("bad quality should be reported once, got %d points", len(got))
+	}
+
+	// Uncertain inputs give an uncertain result
+	got = c.update("meter", []*domain.DataPoint{pointWithQuality("voltage", float32(231), domain.QualityUncertain)}, now)
+	if len(got) != 1 || got[0].Quality != domain.QualityUncertain {
+		t.Fatalf("expected one uncertain point, got %+v", got)
+	}
+
+	got = c.update("meter", []*domain.DataPoint{point("voltage", float32(230))}, now)
+	if len(got) != 1 || got[0].Quality != domain.QualityGood {
+		t.Fatalf("expected the good value again, got %+v", got)
+	}
+
+	// Tags of a removed device are bad inputs
+	c.removeDevice("meter")
+	got = c.update("line", []*domain.DataPoint{point("current", int16(3))}, now)
+	if len(got) != 1 || got[0].Quality != domain.QualityBad {
+		t.Fatalf("expected a bad point after the input device was removed, got %+v", got)
+	}
+}
+
+func TestComputedTags_ChainsAndCycles(t *testing.T) {
+	c := newComputedTags(0, zerolog.Nop())
+	c.setDevice(computedDevice("drive",
+		readTag("speed"),
+		readTag("load"),
+		virtualTag("running", "{speed} > 5", domain.DataTypeBool),
+		virtualTag("label", "{running} ? \"run\" : \"idle\"", domain.DataTypeString),
+		virtualTag("a", "{b} + {load}", domain.DataTypeFloat64),
+		virtualTag("b", "{a} + 1", domain.DataTypeFloat64),
+	))
+
+	got := c.update("drive", []*domain.DataPoint{point("speed", 7.5), point("load", 1)}, time.Now())
+	values := make(map[string]interface{})
+	for _, p := range got {
+		values[p.TagID] = p.Value
+	}
+	if len(got) != 2 || values["running"] != true || values["label"] != "run" {
+		t.Errorf("expected running=true and label=run only, got %v", values)
+	}
+}
+
+func TestComputedTags_EvaluationErrorAndDeadband(t *testing.T) {
+	c := newComputedTags(0, zerolog.Nop())
+	ratio := virtualTag("ratio", "{out} / {in} * 100", domain.DataTypeUInt16)
+	ratio.DeadbandType = domain.DeadbandTypeAbsolute
+	ratio.DeadbandValue = 2
+	c.setDevice(computedDevice("pump", readTag("in"), readTag("out"), ratio))
+	now := time.Now()
+
+	got := c.update("pump", []*domain.DataPoint{point("in", 10), point("out", 5)}, now)
+	if len(got) != 1 || got[0].Value != uint16(50) {
+		t.Fatalf("expected ratio 50, got %+v", got)
+	}
+	if got := c.update("pump", []*domain.DataPoint{point("out", 5.1)}, now); len(got) != 0 {
+		t.Errorf("change within the deadband should be filtered, got %+v", got)
+	}
+	got = c.update("pump", []*domain.DataPoint{point("in", 0)}, now)
+	if len(got) != 1 || got[0].Quality != domain.QualityBad {
+		t.Fatalf("division by zero should give a bad point, got %+v", got)
+	}
+	got = c.update("pump", []*domain.DataPoint{point("in", 10)}, now)
+	if len(got) != 1 || got[0].Value != uint16(51) {
+		t.Fatalf("first good value after bad should bypass the deadband, got %+v", got)
+	}
+}
+
+func TestComputedTags_IgnoreBackfill(t *testing.T) {
+	c := newComputedTags(0, zerolog.Nop())
+	c.setDevice(computedDevice("tank", readTag("level"), readTag("limit"),
+		virtualTag("full", "{level} > {limit}", domain.DataTypeBool)))
+	now := time.Now()
+
+	got := c.update("tank", []*domain.DataPoint{point("level", 95.0), point("limit", 90.0)}, now)
+	if len(got) != 1 || got[0].Value != true {
+		t.Fatalf("expected full=true, got %+v", got)
+	}
+	backfilled := point("level", 10.0).AsBackfill(now.Add(-time.Hour))
+	if got := c.update("tank", []*domain.DataPoint{backfilled}, now); len(got) != 0 {
+		t.Errorf("backfilled points must not be evaluated, got %+v", got)
+	}
+
+	// The backfilled value did not replace the current one
+	got = c.update("tank", []*domain.DataPoint{point("limit", 50.0)}, now)
+	if len(got) != 1 || got[0].Value != true {
+		t.Errorf("expected full=true from the current level, got %+v", got)
+	}
+}
+
+func TestComputedValue(t *testing.T) {
+	tests := []struct {
+		result   interface{}
+		dataType domain.DataType
+		want     interface{}
+		wantErr  bool
+	}{
+		{2.5, domain.DataTypeFloat64, 2.5, false},
+		{2.5, domain.DataTypeFloat32, float32(2.5), false},
+		{2.5, domain.DataTypeInt16, int16(3), false},
+		{true, domain.DataTypeUInt32, uint32(1), false},
+		{-1.0, domain.DataTypeUInt16, nil, true},
+		{70000.0, domain.DataTypeInt16, nil, true},
+		{0.0, domain.DataTypeBool, false, false},
+		{"x", domain.DataTypeBool, nil, true},
+		{"x", domain.DataTypeFloat64, nil, true},
+		{1.5, domain.DataTypeString, "1.5", false},
+		{false, domain.DataTypeString, "false", false},
+	}
+	for _, tt := range tests {
+		got, err := computedValue(tt.result, tt.dataType)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("computedValue(%v, %s): expected error", tt.result, tt.dataType)
+			}
+			continue
+		}
+		if err != nil || got != tt.want {
+			t.Errorf("computedValue(%v, %s) = %v (%T), %v; want %v", tt.result, tt.dataType, got, got, err, tt.want)
+		}
+	}
+}
+
+func TestScanClasses_SkipComputedTags(t *testing.T) {
+	device := computedDevice("plc", readTag("speed"), virtualTag("running", "{speed} > 5", domain.DataTypeBool))
+	device.PollInterval = time.Second
+	device.Tags[1].PollInterval = durationPtr(100 * time.Millisecond)
+
+	if classes := scanClasses(device); len(classes) != 1 || classes[0].interval != time.Second {
+		t.Errorf("computed tags must not form scan classes, got %v", classes)
+	}
+	s := &PollingService{}
+	if tags := s.getEnabledTags(device); len(tags) != 1 || tags[0].ID != "speed" {
+		t.Errorf("computed tags must not be read, got %v", tags)
+	}
+}
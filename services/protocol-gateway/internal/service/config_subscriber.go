@@ -234,19 +234,60 @@ type WireConnection struct {
 	RetryCount *int   `json:"retry_count,omitempty"`
 	RetryDelay string `json:"retry_delay,omitempty"`
 	// Modbus
-	SlaveID *int `json:"slave_id,omitempty"`
+	SlaveID    *int   `json:"slave_id,omitempty"`
+	SerialPort string `json:"serial_port,omitempty"`
+	BaudRate   int    `json:"baud_rate,omitempty"`
+	DataBits   int    `json:"data_bits,omitempty"`
+	Parity     string `json:"parity,omitempty"`
+	StopBits   int    `json:"stop_bits,omitempty"`
+	Turnaround string `json:"turnaround_delay,omitempty"`
+	Framing    string `json:"framing,omitempty"`
+	Shared     bool   `json:"share_connection,omitempty"`
+	Profile    string `json:"modbus_profile,omitempty"`
+	Batching   *WireModbusBatching `json:"modbus_batching,omitempty"`
 	// OPC UA
-	SecurityPolicy   string `json:"security_policy,omitempty"`
-	SecurityMode     string `json:"security_mode,omitempty"`
-	AuthMode         string `json:"auth_mode,omitempty"`
-	Username         string `json:"username,omitempty"`
-	Password         string `json:"password,omitempty"`
-	EndpointURL      string `json:"endpoint_url,omitempty"`
-	UseSubscriptions *bool  `json:"use_subscriptions,omitempty"`
+	SecurityPolicy   string   `json:"security_policy,omitempty"`
+	SecurityMode     string   `json:"security_mode,omitempty"`
+	AuthMode         string   `json:"auth_mode,omitempty"`
+	Username         string   `json:"username,omitempty"`
+	Password         string   `json:"password,omitempty"`
+	EndpointURL      string   `json:"endpoint_url,omitempty"`
+	UseSubscriptions *bool    `json:"use_subscriptions,omitempty"`
+	AlarmsEnabled    bool     `json:"alarms_enabled,omitempty"`
+	AlarmNotifiers   []string `json:"alarm_notifiers,omitempty"`
+	AlarmMinSeverity uint16   `json:"alarm_min_severity,omitempty"`
+	HistoryBackfill  bool     `json:"history_backfill,omitempty"`
+	PreserveTypes    bool     `json:"preserve_types,omitempty"`
 	// S7
-	Rack    *int `json:"rack,omitempty"`
-	Slot    *int `json:"slot,omitempty"`
-	PDUSize *int `json:"pdu_size,omitempty"`
+	Rack           *int   `json:"rack,omitempty"`
+	Slot           *int   `json:"slot,omitempty"`
+	PDUSize        *int   `json:"pdu_size,omitempty"`
+	Family         string `json:"family,omitempty"`
+	ConnectionType string `json:"connection_type,omitempty"`
+	LocalTSAP      uint16 `json:"local_tsap,omitempty"`
+	RemoteTSAP     uint16 `json:"remote_tsap,omitempty"`
+	StatusInterval string `json:"status_interval,omitempty"`
+	// MQTT source (Username/Password are shared with OPC UA)
+	BrokerURL        string `json:"broker_url,omitempty"`
+	ClientIDPrefix   string `json:"client_id_prefix,omitempty"`
+	QoS              *int   `json:"qos,omitempty"`
+	CleanSession     bool   `json:"clean_session,omitempty"`
+	StalenessTimeout string `json:"staleness_timeout,omitempty"`
+	SourcePrefix     string `json:"source_prefix,omitempty"`
+	TLSEnabled       bool   `json:"tls_enabled,omitempty"`
+	TLSCAFile        string `json:"tls_ca_file,omitempty"`
+	TLSCertFile      string `json:"tls_cert_file,omitempty"`
+	TLSKeyFile       string `json:"tls_key_file,omitempty"`
+}
+
+type WireModbusBatching struct {
+	MaxRegisters      uint16 `json:"max_registers,omitempty"`
+	MaxCoils          uint16 `json:"max_coils,omitempty"`
+	MaxGap            uint16 `json:"max_gap,omitempty"`
+	NoGap             bool   `json:"no_gap,omitempty"`
+	Alignment         uint16 `json:"alignment,omitempty"`
+	InterRequestDelay string `json:"inter_request_delay,omitempty"`
+	OneBased          bool   `json:"one_based_addressing,omitempty"`
 }
 
 type WireTag struct {
@@ -263,6 +304,7 @@ type WireTag struct {
 	Unit            string  `json:"unit"`
 	DeadbandType    string  `json:"deadband_type"`
 	DeadbandValue   float64 `json:"deadband_value"`
+	MaxSilence      string  `json:"max_silence,omitempty"`
 	AccessMode      string  `json:"access_mode"`
 	Priority        uint8   `json:"priority"`
 	ByteOrder       string  `json:"byte_order"`
@@ -271,6 +313,17 @@ type WireTag struct {
 	OPCNodeID       string  `json:"opc_node_id"`
 	OPCNamespaceURI string  `json:"opc_namespace_uri"`
 	S7Address       string  `json:"s7_address"`
+	S7StringLength  uint16  `json:"s7_string_length,omitempty"`
+	MQTTTopicMatch  string  `json:"mqtt_topic_match,omitempty"`
+	MQTTPayloadFormat string `json:"mqtt_payload_format,omitempty"`
+	MQTTValuePath   string  `json:"mqtt_value_path,omitempty"`
+	MQTTTimestampPath string `json:"mqtt_timestamp_path,omitempty"`
+	StringEncoding  string  `json:"string_encoding,omitempty"`
+	StringByteSwap  bool    `json:"string_byte_swap,omitempty"`
+	StringTrim      string  `json:"string_trim,omitempty"`
+	ArrayLength     uint16  `json:"array_length,omitempty"`
+	BitFields       []domain.BitField `json:"bit_fields,omitempty"`
+	Expression      string  `json:"expression,omitempty"`
 	TopicSuffix     string  `json:"topic_suffix"`
 }
 
@@ -363,6 +416,17 @@ func (cs *ConfigSubscriber) handleTagChange(topic string, payload []byte) {
 		return
 	}
 
+	if notification.Action != "delete" {
+		if err := validateWireTag(notification.Data); err != nil {
+			cs.stats.errorsTotal.Add(1)
+			cs.logger.Error().Err(err).
+				Str("device_id", deviceID).
+				Str("action", notification.Action).
+				Msg("Rejected tag config change")
+			return
+		}
+	}
+
 	// Clone device to avoid mutating the live pointer
 	updated := cloneDevice(device)
 	tag := WireTagToDomain(notification.Data)
@@ -426,6 +490,11 @@ func (cs *ConfigSubscriber) handleTagChange(topic string, payload []byte) {
 // =========================================================================
 
 func (cs *ConfigSubscriber) applyDeviceCreate(wd WireDevice) {
+	if err := validateWireDevice(wd); err != nil {
+		cs.stats.errorsTotal.Add(1)
+		cs.logger.Error().Err(err).Str("device_id", wd.ID).Msg("Failed to add device from config")
+		return
+	}
 	device := WireDeviceToDomain(wd)
 
 	if err := cs.dm.AddDeviceFromConfig(device); err != nil {
@@ -446,6 +515,11 @@ func (cs *ConfigSubscriber) applyDeviceCreate(wd WireDevice) {
 }
 
 func (cs *ConfigSubscriber) applyDeviceUpdate(wd WireDevice) {
+	if err := validateWireDevice(wd); err != nil {
+		cs.stats.errorsTotal.Add(1)
+		cs.logger.Error().Err(err).Str("device_id", wd.ID).Msg("Failed to update device from config")
+		return
+	}
 	device := WireDeviceToDomain(wd)
 
 	if err := cs.dm.UpdateDeviceFromConfig(device); err != nil {
@@ -481,6 +555,29 @@ func (cs *ConfigSubscriber) applyDeviceDelete(deviceID string) {
 // Wire → Domain conversion
 // =========================================================================
 
+// validateWireDevice rejects wire fields the conversion cannot represent, as
+// the YAML loader does.
+func validateWireDevice(wd WireDevice) error {
+	for _, wt := range wd.Tags {
+		if err := validateWireTag(wt); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// validateWireTag rejects an unparseable max_silence: converted to zero, it
+// would silently disable the tag's heartbeat.
+func validateWireTag(wt WireTag) error {
+	if wt.MaxSilence == "" {
+		return nil
+	}
+	if _, err := time.ParseDuration(wt.MaxSilence); err != nil {
+		return fmt.Errorf("invalid max silence for tag %s: %w", wt.ID, err)
+	}
+	return nil
+}
+
 // WireDeviceToDomain converts the gateway-core wire format to a domain.Device.
 func WireDeviceToDomain(wd WireDevice) *domain.Device {
 	d := &domain.Device{
@@ -527,6 +624,28 @@ func WireConnectionToDomain(wc WireConnection, protocol domain.Protocol) domain.
 		if wc.SlaveID != nil {
 			cc.SlaveID = uint8(*wc.SlaveID)
 		}
+		cc.Framing = domain.ModbusFraming(wc.Framing)
+		cc.ShareConnection = wc.Shared
+		cc.ModbusProfile = wc.Profile
+		if b := wc.Batching; b != nil {
+			cc.ModbusBatching = &domain.ModbusBatching{
+				MaxRegisters:       b.MaxRegisters,
+				MaxCoils:           b.MaxCoils,
+				MaxGap:             b.MaxGap,
+				NoGap:              b.NoGap,
+				Alignment:          b.Alignment,
+				InterRequestDelay:  parseDuration(b.InterRequestDelay, 0),
+				OneBasedAddressing: b.OneBased,
+			}
+		}
+		if protocol == domain.ProtocolModbusRTU {
+			cc.SerialPort = wc.SerialPort
+			cc.BaudRate = wc.BaudRate
+			cc.DataBits = wc.DataBits
+			cc.Parity = wc.Parity
+			cc.StopBits = wc.StopBits
+			cc.TurnaroundDelay = parseDuration(wc.Turnaround, 0)
+		}
 	case domain.ProtocolOPCUA:
 		cc.OPCSecurityPolicy = wc.SecurityPolicy
 		cc.OPCSecurityMode = wc.SecurityMode
@@ -537,6 +656,11 @@ func WireConnectionToDomain(wc WireConnection, protocol domain.Protocol) domain.
 		if wc.UseSubscriptions != nil {
 			cc.OPCUseSubscriptions = *wc.UseSubscriptions
 		}
+		cc.OPCAlarmsEnabled = wc.AlarmsEnabled
+		cc.OPCAlarmNotifiers = wc.AlarmNotifiers
+		cc.OPCAlarmMinSeverity = wc.AlarmMinSeverity
+		cc.OPCHistoryBackfill = wc.HistoryBackfill
+		cc.OPCPreserveTypes = wc.PreserveTypes
 	case domain.ProtocolS7:
 		if wc.Rack != nil {
 			cc.S7Rack = *wc.Rack
@@ -547,6 +671,26 @@ func WireConnectionToDomain(wc WireConnection, protocol domain.Protocol) domain.
 		if wc.PDUSize != nil {
 			cc.S7PDUSize = *wc.PDUSize
 		}
+		cc.S7Family = wc.Family
+		cc.S7ConnectionType = domain.S7ConnectionType(wc.ConnectionType)
+		cc.S7LocalTSAP = wc.LocalTSAP
+		cc.S7RemoteTSAP = wc.RemoteTSAP
+		cc.S7StatusInterval = parseDuration(wc.StatusInterval, 0)
+	case domain.ProtocolMQTT:
+		cc.MQTTBrokerURL = wc.BrokerURL
+		cc.MQTTUsername = wc.Username
+		cc.MQTTPassword = wc.Password
+		cc.MQTTClientIDPrefix = wc.ClientIDPrefix
+		if wc.QoS != nil {
+			cc.MQTTQoS = byte(*wc.QoS)
+		}
+		cc.MQTTCleanSession = wc.CleanSession
+		cc.MQTTStalenessTimeout = parseDuration(wc.StalenessTimeout, 0)
+		cc.MQTTSourcePrefix = wc.SourcePrefix
+		cc.MQTTTLSEnabled = wc.TLSEnabled
+		cc.MQTTTLSCAFile = wc.TLSCAFile
+		cc.MQTTTLSCertFile = wc.TLSCertFile
+		cc.MQTTTLSKeyFile = wc.TLSKeyFile
 	}
 
 	return cc
@@ -571,7 +715,26 @@ func WireTagToDomain(wt WireTag) domain.Tag {
 		OPCNodeID:       wt.OPCNodeID,
 		OPCNamespaceURI: wt.OPCNamespaceURI,
 		S7Address:       wt.S7Address,
+		S7StringLength:  wt.S7StringLength,
 		TopicSuffix:     wt.TopicSuffix,
+
+		MQTTTopicMatch:    wt.MQTTTopicMatch,
+		MQTTPayloadFormat: domain.MQTTPayloadFormat(wt.MQTTPayloadFormat),
+		MQTTValuePath:     wt.MQTTValuePath,
+		MQTTTimestampPath: wt.MQTTTimestampPath,
+
+		StringEncoding: domain.StringEncoding(wt.StringEncoding),
+		StringByteSwap: wt.StringByteSwap,
+		StringTrim:     domain.StringTrim(wt.StringTrim),
+		ArrayLength:    wt.ArrayLength,
+		BitFields:      wt.BitFields,
+
+		Expression: wt.Expression,
+	}
+
+	if wt.MaxSilence != "" {
+		maxSilence := parseDuration(wt.MaxSilence, 0)
+		t.MaxSilence = &maxSilence
 	}
 
 	// Parse address from string to uint16
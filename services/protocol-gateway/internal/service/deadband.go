@@ -0,0 +1,139 @@
+// Package service provides report-by-exception filtering for polled data points.
+package service
+
+import (
+	"math"
+	"reflect"
+	"sync"
+	"time"
+
+	"github.com/nexus-edge/protocol-gateway/internal/domain"
+)
+
+// exceptionFilter implements report-by-exception for polled protocols.
+// Tags without a deadband are reported on every poll. For tags with an absolute
+// or percent deadband, a good value is reported only when:
+//   - it is the first good value (at startup or after a bad-quality read),
+//   - it moved past the deadband relative to the last reported value, or
+//   - the tag has been silent for longer than the max-silence heartbeat, so
+//     downstream consumers can tell a steady value from a dead device.
+//
+// OPC UA subscriptions apply their deadband server-side and bypass this filter.
+type exceptionFilter struct {
+	maxSilence time.Duration // Default heartbeat; a tag's MaxSilence overrides it
+
+	mu   sync.Mutex
+	last map[string]reportedValue // Keyed by tag ID
+}
+
+// reportedValue is the last value published for a tag.
+type reportedValue struct {
+	value interface{}
+	at    time.Time
+}
+
+// newExceptionFilter creates a filter with the given default max-silence
+// heartbeat (0 disables the heartbeat).
+func newExceptionFilter(maxSilence time.Duration) *exceptionFilter {
+	return &exceptionFilter{
+		maxSilence: maxSilence,
+		last:       make(map[string]reportedValue),
+	}
+}
+
+// allow reports whether a good-quality point should be published and, if so,
+// records it as the tag's last reported value.
+func (f *exceptionFilter) allow(tag *domain.Tag, point *domain.DataPoint, now time.Time) bool {
+	if tag == nil || tag.DeadbandType == "" || tag.DeadbandType == domain.DeadbandTypeNone {
+		return true
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	last, seen := f.last[point.TagID]
+	if seen && !f.heartbeatDue(tag, last, now) && !exceedsDeadband(tag, last.value, point.Value) {
+		return false
+	}
+
+	f.last[point.TagID] = reportedValue{value: domain.CopyValue(point.Value), at: now}
+	return true
+}
+
+// heartbeatDue reports whether the tag has been silent for its max-silence period.
+func (f *exceptionFilter) heartbeatDue(tag *domain.Tag, last reportedValue, now time.Time) bool {
+	maxSilence := f.maxSilence
+	if tag.MaxSilence != nil {
+		maxSilence = *tag.MaxSilence
+	}
+	return maxSilence > 0 && now.Sub(last.at) >= maxSilence
+}
+
+// forget drops the last reported value of a tag, so its next good value is
+// reported regardless of the deadband. Called on bad-quality reads.
+func (f *exceptionFilter) forget(tagID string) {
+	f.mu.Lock()
+	delete(f.last, tagID)
+	f.mu.Unlock()
+}
+
+// reset drops all last reported values, e.g. after a configuration change.
+func (f *exceptionFilter) reset() {
+	f.mu.Lock()
+	f.last = make(map[string]reportedValue)
+	f.mu.Unlock()
+}
+
+// exceedsDeadband reports whether current differs from last by more than the
+// tag's deadband. Percent deadbands are relative to the magnitude of the last
+// reported value, since tags carry no engineering range. Non-numeric values
+// (bool, string) are reported on any change.
+func exceedsDeadband(tag *domain.Tag, last, current interface{}) bool {
+	lastNum, ok1 := toFloat64(last)
+	currentNum, ok2 := toFloat64(current)
+	if !ok1 || !ok2 {
+		return !reflect.DeepEqual(last, current)
+	}
+	if math.IsNaN(lastNum) || math.IsNaN(currentNum) {
+		return math.IsNaN(lastNum) != math.IsNaN(currentNum)
+	}
+
+	delta := math.Abs(currentNum - lastNum)
+	switch tag.DeadbandType {
+	case domain.DeadbandTypePercent:
+		return delta > math.Abs(lastNum)*tag.DeadbandValue/100
+	default:
+		return delta > tag.DeadbandValue
+	}
+}
+
+func toFloat64(v interface{}) (float64, bool) {
+	switch val := v.(type) {
+	case int:
+		return float64(val), true
+	case int8:
+		return float64(val), true
+	case int16:
+		return float64(val), true
+	case int32:
+		return float64(val), true
+	case int64:
+		return float64(val), true
+	case uint:
+		return float64(val), true
+	case uint8:
+		return float64(val), true
+	case uint16:
+		return float64(val), true
+	case uint32:
+		return float64(val), true
+	case uint64:
+		return float64(val), true
+	case float32:
+		return float64(val), true
+	case float64:
+		return val, true
+	default:
+		return 0, false
+	}
+}
@@ -0,0 +1,112 @@
+package service
+
+import (
+	"testing"
+	"time"
+
+	"github.com/nexus-edge/protocol-gateway/internal/domain"
+)
+
+func point(tagID string, value interface{}) *domain.DataPoint {
+	return domain.NewDataPoint("dev", tagID, "", value, "", domain.QualityGood)
+}
+
+func TestExceptionFilter_AbsoluteDeadband(t *testing.T) {
+	f := newExceptionFilter(0)
+	tag := &domain.Tag{ID: "temp", DeadbandType: domain.DeadbandTypeAbsolute, DeadbandValue: 0.5}
+	now := time.Now()
+
+	steps := []struct {
+		value float64
+		want  bool
+	}{
+		{20.0, true},  // First value is always reported
+		{20.3, false}, // Within deadband of 20.0
+		{20.5, false}, // Exactly on the deadband is not a change
+		{20.6, true},  // Past the deadband
+		{20.2, false}, // Compared with the last reported value (20.6)
+		{20.0, true},
+	}
+	for i, step := range steps {
+		if got := f.allow(tag, point("temp", step.value), now); got != step.want {
+			t.Errorf("step %d (%v): expected %v, got %v", i, step.value, step.want, got)
+		}
+	}
+}
+
+func TestExceptionFilter_PercentDeadband(t *testing.T) {
+	f := newExceptionFilter(0)
+	tag := &domain.Tag{ID: "flow", DeadbandType: domain.DeadbandTypePercent, DeadbandValue: 10}
+	now := time.Now()
+
+	if !f.allow(tag, point("flow", int32(200)), now) {
+		t.Fatal("expected first value to be reported")
+	}
+	if f.allow(tag, point("flow", int32(215)), now) {
+		t.Error("7.5% change should be filtered by a 10% deadband")
+	}
+	if !f.allow(tag, point("flow", int32(225)), now) {
+		t.Error("12.5% change should be reported")
+	}
+}
+
+func TestExceptionFilter_MaxSilenceHeartbeat(t *testing.T) {
+	f := newExceptionFilter(time.Minute)
+	tag := &domain.Tag{ID: "level", DeadbandType: domain.DeadbandTypeAbsolute, DeadbandValue: 1}
+	start := time.Now()
+
+	f.allow(tag, point("level", 5.0), start)
+	if f.allow(tag, point("level", 5.0), start.Add(59*time.Second)) {
+		t.Error("unchanged value reported before max silence elapsed")
+	}
+	if !f.allow(tag, point("level", 5.0), start.Add(time.Minute)) {
+		t.Error("expected heartbeat after max silence")
+	}
+
+	// A per-tag override wins over the service default
+	override := 10 * time.Second
+	tag.MaxSilence = &override
+	if !f.allow(tag, point("level", 5.0), start.Add(70*time.Second)) {
+		t.Error("expected heartbeat after the tag's own max silence")
+	}
+}
+
+func TestExceptionFilter_ReportsAfterBadQualityAndNonNumericChanges(t *testing.T) {
+	f := newExceptionFilter(0)
+	tag := &domain.Tag{ID: "mode", DeadbandType: domain.DeadbandTypeAbsolute, DeadbandValue: 100}
+	now := time.Now()
+
+	f.allow(tag, point("mode", "auto"), now)
+	if f.allow(tag, point("mode", "auto"), now) {
+		t.Error("unchanged string should be filtered")
+	}
+	if !f.allow(tag, point("mode", "manual"), now) {
+		t.Error("changed string should be reported regardless of deadband value")
+	}
+
+	f.forget("mode")
+	if !f.allow(tag, point("mode", "manual"), now) {
+		t.Error("expected first good value after a bad read to be reported")
+	}
+
+	plain := &domain.Tag{ID: "raw"}
+	for i := 0; i < 3; i++ {
+		if !f.allow(plain, point("raw", 1), now) {
+			t.Fatal("tags without a deadband must be reported every poll")
+		}
+	}
+}
+
+func TestExceptionFilter_KeepsCopyOfArrayValues(t *testing.T) {
+	f := newExceptionFilter(0)
+	tag := &domain.Tag{ID: "levels", DeadbandType: domain.DeadbandTypeAbsolute, DeadbandValue: 1}
+	now := time.Now()
+
+	values := []uint16{1, 2, 3}
+	f.allow(tag, point("levels", values), now)
+	// The buffer of a released point is reused for the next read
+	values[0] = 9
+	if !f.allow(tag, point("levels", values), now) {
+		t.Error("changed array should be reported")
+	}
+}
@@ -3,9 +3,12 @@
 package service
 
 import (
+	"cmp"
 	"context"
 	"errors"
+	"fmt"
 	"math/rand"
+	"slices"
 	"strings"
 	"sync"
 	"sync/atomic"
@@ -61,8 +64,17 @@ type StatusPublisher interface {
 	PublishDeviceStatus(ctx context.Context, deviceID string, status string, lastError string, stats map[string]interface{}) error
 }
 
+// DeviceAnnouncer is notified when devices are registered, reconfigured or
+// removed, for output formats that track the device set (e.g., Sparkplug B
+// device births and deaths).
+type DeviceAnnouncer interface {
+	DeviceBirth(device *domain.Device)
+	DeviceDeath(deviceID string)
+}
+
 // SubscriptionHandler handles push-based data delivery for protocols that
-// support server-side subscriptions (e.g., OPC UA Report-by-Exception).
+// support server-side subscriptions (e.g., OPC UA Report-by-Exception) and for
+// push-only protocols (MQTT sources).
 // When a device is configured for subscriptions, the polling service delegates
 // to this handler instead of running a polling loop.
 type SubscriptionHandler interface {
@@ -76,33 +88,40 @@ type SubscriptionHandler interface {
 
 // PollingService orchestrates reading data from devices and publishing to MQTT.
 // It supports multiple protocols through the ProtocolManager.
-// For OPC UA devices with OPCUseSubscriptions=true, it delegates to a
-// SubscriptionHandler for push-based data delivery instead of polling.
+// For OPC UA devices with OPCUseSubscriptions=true, and for all devices of a
+// push-only protocol, it delegates to a SubscriptionHandler for push-based
+// data delivery instead of polling.
 type PollingService struct {
 	config              PollingConfig
 	protocolManager     *domain.ProtocolManager
 	publisher           Publisher
-	statusPublisher     StatusPublisher     // Optional: publishes device status to MQTT
-	subscriptionHandler SubscriptionHandler // Optional: handles OPC UA subscriptions
+	statusPublisher     StatusPublisher                         // Optional: publishes device status to MQTT
+	deviceAnnouncer     DeviceAnnouncer                         // Optional: tracks the device set
+	subscriptionHandler SubscriptionHandler                     // Optional: handles OPC UA subscriptions
+	pushHandlers        map[domain.Protocol]SubscriptionHandler // Push-only protocols (e.g., MQTT sources)
 	logger              zerolog.Logger
 	metrics             *metrics.Registry
 	devices             map[string]*devicePoller
+	computed            *computedTags
 	mu                  sync.RWMutex
 	started             atomic.Bool
 	ctx                 context.Context
 	cancel              context.CancelFunc
 	wg                  sync.WaitGroup
-	workerPool          chan struct{}
+	workerPools         [3]chan struct{} // Indexed by priority tier
 	stats               *PollingStats
 }
 
 // PollingConfig holds configuration for the polling service.
 type PollingConfig struct {
-	WorkerCount     int
+	WorkerCount     int // Telemetry workers
+	ControlWorkers  int // Reserved for control-priority scan classes
+	SafetyWorkers   int // Reserved for safety-priority scan classes
 	BatchSize       int
 	DefaultInterval time.Duration
 	MaxRetries      int
 	ShutdownTimeout time.Duration
+	MaxSilence      time.Duration // Heartbeat for deadband-filtered tags (0 = disabled)
 }
 
 // PollingStats tracks polling statistics.
@@ -113,21 +132,25 @@ type PollingStats struct {
 	SkippedPolls    atomic.Uint64 // Polls skipped due to back-pressure
 	PointsRead      atomic.Uint64
 	PointsPublished atomic.Uint64
+	PointsFiltered  atomic.Uint64 // Good points suppressed by a deadband
+	PointsComputed  atomic.Uint64 // Computed tag points published
 }
 
 // devicePoller manages polling for a single device.
 type devicePoller struct {
-	device     *domain.Device
-	stopChan   chan struct{}
-	stopOnce   sync.Once
-	running    atomic.Bool
-	subscribed     bool // true if using push-based subscriptions instead of polling
-	lastPoll       time.Time
-	lastError      error
-	lastStatus     string    // last published status (for change detection)
-	lastStatusAt   time.Time // when status was last published
-	stats          deviceStats
-	mu             sync.RWMutex
+	device       *domain.Device
+	stopChan     chan struct{}
+	stopOnce     sync.Once
+	running      atomic.Bool
+	subscribed   bool                // true if using push-based subscriptions instead of polling
+	handler      SubscriptionHandler // Handler of the active subscription
+	filter       *exceptionFilter
+	lastPoll     time.Time
+	lastError    error
+	lastStatus   string    // last published status (for change detection)
+	lastStatusAt time.Time // when status was last published
+	stats        deviceStats
+	mu           sync.RWMutex
 }
 
 // deviceStats tracks per-device statistics.
@@ -150,6 +173,12 @@ func NewPollingService(
 	if config.WorkerCount <= 0 {
 		config.WorkerCount = 10
 	}
+	if config.ControlWorkers <= 0 {
+		config.ControlWorkers = 4
+	}
+	if config.SafetyWorkers <= 0 {
+		config.SafetyWorkers = 2
+	}
 	if config.BatchSize <= 0 {
 		config.BatchSize = 50
 	}
@@ -160,15 +189,22 @@ func NewPollingService(
 		config.ShutdownTimeout = 30 * time.Second
 	}
 
+	serviceLogger := logger.With().Str("component", "polling-service").Logger()
 	return &PollingService{
 		config:          config,
 		protocolManager: protocolManager,
 		publisher:       publisher,
-		logger:          logger.With().Str("component", "polling-service").Logger(),
+		logger:          serviceLogger,
 		metrics:         metricsReg,
 		devices:         make(map[string]*devicePoller),
-		workerPool:      make(chan struct{}, config.WorkerCount),
-		stats:           &PollingStats{},
+		computed:        newComputedTags(config.MaxSilence, serviceLogger),
+		pushHandlers:    make(map[domain.Protocol]SubscriptionHandler),
+		workerPools: [3]chan struct{}{
+			domain.PriorityTelemetry: make(chan struct{}, config.WorkerCount),
+			domain.PriorityControl:   make(chan struct{}, config.ControlWorkers),
+			domain.PrioritySafety:    make(chan struct{}, config.SafetyWorkers),
+		},
+		stats: &PollingStats{},
 	}
 }
 
@@ -177,6 +213,11 @@ func (s *PollingService) SetStatusPublisher(sp StatusPublisher) {
 	s.statusPublisher = sp
 }
 
+// SetDeviceAnnouncer sets the receiver of device lifecycle announcements.
+func (s *PollingService) SetDeviceAnnouncer(announcer DeviceAnnouncer) {
+	s.deviceAnnouncer = announcer
+}
+
 // SetSubscriptionHandler sets the handler for push-based subscriptions.
 // Must be called before Start(). Devices with OPCUseSubscriptions=true
 // will use this handler instead of polling.
@@ -184,6 +225,36 @@ func (s *PollingService) SetSubscriptionHandler(handler SubscriptionHandler) {
 	s.subscriptionHandler = handler
 }
 
+// SetPushHandler sets the handler for a push-only protocol. Must be called
+// before Start(). Devices of this protocol are never polled; if their
+// subscription fails they stay in error until reconfigured.
+func (s *PollingService) SetPushHandler(protocol domain.Protocol, handler SubscriptionHandler) {
+	s.pushHandlers[protocol] = handler
+}
+
+// subscriptionHandlerFor returns the handler that delivers a device's data by
+// push, or nil if the device is polled.
+func (s *PollingService) subscriptionHandlerFor(device *domain.Device) SubscriptionHandler {
+	if handler, ok := s.pushHandlers[device.Protocol]; ok {
+		return handler
+	}
+	if device.Connection.OPCUseSubscriptions && device.Protocol == domain.ProtocolOPCUA && s.subscriptionHandler != nil {
+		return s.subscriptionHandler
+	}
+	return nil
+}
+
+// unsubscribe removes the active subscription of a device, if any.
+func (s *PollingService) unsubscribe(dp *devicePoller) {
+	if dp.subscribed && dp.handler != nil {
+		if err := dp.handler.Unsubscribe(dp.device.ID); err != nil {
+			s.logger.Warn().Err(err).Str("device_id", dp.device.ID).Msg("Failed to unsubscribe device")
+		}
+	}
+	dp.subscribed = false
+	dp.handler = nil
+}
+
 // Start begins the polling service.
 func (s *PollingService) Start(ctx context.Context) error {
 	if s.started.Load() {
@@ -200,6 +271,8 @@ func (s *PollingService) Start(ctx context.Context) error {
 	s.logger.Info().
 		Int("devices", deviceCount).
 		Int("workers", s.config.WorkerCount).
+		Int("control_workers", s.config.ControlWorkers).
+		Int("safety_workers", s.config.SafetyWorkers).
 		Msg("Starting polling service")
 
 	// Start polling for all registered devices
@@ -258,9 +331,14 @@ func (s *PollingService) RegisterDevice(ctx context.Context, device *domain.Devi
 	dp := &devicePoller{
 		device:   device,
 		stopChan: make(chan struct{}),
+		filter:   newExceptionFilter(s.config.MaxSilence),
 	}
 
 	s.devices[device.ID] = dp
+	s.computed.setDevice(device)
+	if s.deviceAnnouncer != nil {
+		s.deviceAnnouncer.DeviceBirth(device)
+	}
 
 	s.logger.Info().
 		Str("device_id", device.ID).
@@ -288,12 +366,7 @@ func (s *PollingService) UnregisterDevice(deviceID string) error {
 	}
 
 	// If device was using subscriptions, unsubscribe
-	if dp.subscribed && s.subscriptionHandler != nil {
-		if err := s.subscriptionHandler.Unsubscribe(deviceID); err != nil {
-			s.logger.Warn().Err(err).Str("device_id", deviceID).Msg("Failed to unsubscribe device")
-		}
-		dp.subscribed = false
-	}
+	s.unsubscribe(dp)
 
 	// Stop the poller
 	if dp.running.Load() {
@@ -303,14 +376,19 @@ func (s *PollingService) UnregisterDevice(deviceID string) error {
 	}
 
 	delete(s.devices, deviceID)
+	s.computed.removeDevice(deviceID)
+	if s.deviceAnnouncer != nil {
+		s.deviceAnnouncer.DeviceDeath(deviceID)
+	}
 
 	s.logger.Info().Str("device_id", deviceID).Msg("Unregistered device")
 	return nil
 }
 
 // ReplaceDevice atomically updates a device's configuration while preserving
-// polling runtime state (stats, last poll time, error history). If the poll
-// interval changed, the poller goroutine is restarted with the new interval.
+// polling runtime state (stats, last poll time, error history). If the set of
+// scan classes (effective tag poll intervals and priorities) changed, the
+// poller goroutine is restarted with the new schedules.
 // If only tags or non-connection fields changed, the device pointer is swapped
 // in-place and the next poll cycle picks up the new config automatically.
 func (s *PollingService) ReplaceDevice(ctx context.Context, device *domain.Device) error {
@@ -328,23 +406,28 @@ func (s *PollingService) ReplaceDevice(ctx context.Context, device *domain.Devic
 
 	if !device.Enabled {
 		// Device disabled — unregister it.
-		if dp.subscribed && s.subscriptionHandler != nil {
-			_ = s.subscriptionHandler.Unsubscribe(device.ID)
-			dp.subscribed = false
-		}
+		s.unsubscribe(dp)
 		if dp.running.Load() {
 			dp.stopOnce.Do(func() {
 				close(dp.stopChan)
 			})
 		}
 		delete(s.devices, device.ID)
+		s.computed.removeDevice(device.ID)
+		if s.deviceAnnouncer != nil {
+			s.deviceAnnouncer.DeviceDeath(device.ID)
+		}
 		s.logger.Info().Str("device_id", device.ID).Msg("Device disabled, unregistered")
 		return nil
 	}
 
-	oldInterval := dp.device.PollInterval
+	oldClasses := scanClasses(dp.device)
 	wasSubscribed := dp.subscribed
-	wantsSubscription := device.Connection.OPCUseSubscriptions && device.Protocol == domain.ProtocolOPCUA && s.subscriptionHandler != nil
+	wantsSubscription := s.subscriptionHandlerFor(device) != nil
+	if wasSubscribed {
+		// Unsubscribe under the old configuration
+		s.unsubscribe(dp)
+	}
 
 	// Swap the device pointer. The next pollDevice() call reads from dp.device,
 	// so it will automatically use the new tags, connection config, etc.
@@ -352,6 +435,13 @@ func (s *PollingService) ReplaceDevice(ctx context.Context, device *domain.Devic
 	dp.device = device
 	dp.mu.Unlock()
 
+	// Deadband settings may have changed; report every tag fresh on its next poll.
+	dp.filter.reset()
+	s.computed.setDevice(device)
+	if s.deviceAnnouncer != nil {
+		s.deviceAnnouncer.DeviceBirth(device)
+	}
+
 	s.logger.Info().
 		Str("device_id", device.ID).
 		Int("tags", len(device.Tags)).
@@ -361,10 +451,6 @@ func (s *PollingService) ReplaceDevice(ctx context.Context, device *domain.Devic
 	// Handle mode change: subscription ↔ polling
 	if wasSubscribed && !wantsSubscription {
 		// Switching from subscription to polling
-		if s.subscriptionHandler != nil {
-			_ = s.subscriptionHandler.Unsubscribe(device.ID)
-		}
-		dp.subscribed = false
 		s.stopAndResetPoller(dp)
 		s.startDevicePoller(dp)
 		return nil
@@ -374,28 +460,25 @@ func (s *PollingService) ReplaceDevice(ctx context.Context, device *domain.Devic
 		// to fully exit before starting subscription, otherwise the old goroutine's
 		// deferred dp.running.Store(false) races with the new subscription's state.
 		s.stopAndResetPoller(dp)
-		s.startDevicePoller(dp) // Will detect OPCUseSubscriptions and delegate
+		s.startDevicePoller(dp) // Will detect the subscription and delegate
 		return nil
 	}
 	if wasSubscribed && wantsSubscription {
 		// Still subscription mode — re-subscribe with new tags
-		if s.subscriptionHandler != nil {
-			_ = s.subscriptionHandler.Unsubscribe(device.ID)
-		}
-		dp.subscribed = false
 		s.stopAndResetPoller(dp)
 		s.startDevicePoller(dp) // Will delegate to startDeviceSubscription
 		return nil
 	}
 
-	// If the poll interval changed, we need to restart the poller goroutine
-	// because the ticker was created with the old interval.
-	if oldInterval != device.PollInterval && s.started.Load() {
+	// If the scan classes changed, we need to restart the poller goroutine
+	// because the tickers were created with the old intervals. Tag changes
+	// within existing scan classes are picked up on the next poll cycle.
+	if newClasses := scanClasses(device); !slices.Equal(oldClasses, newClasses) && s.started.Load() {
 		s.logger.Info().
 			Str("device_id", device.ID).
-			Dur("old_interval", oldInterval).
-			Dur("new_interval", device.PollInterval).
-			Msg("Poll interval changed, restarting poller")
+			Str("old_scan_classes", fmt.Sprint(oldClasses)).
+			Str("new_scan_classes", fmt.Sprint(newClasses)).
+			Msg("Scan classes changed, restarting poller")
 
 		s.stopAndResetPoller(dp)
 		s.startDevicePoller(dp)
@@ -433,19 +516,31 @@ func (s *PollingService) stopAndResetPoller(dp *devicePoller) {
 }
 
 // startDevicePoller starts the polling loop for a device.
-// For OPC UA devices with subscriptions enabled, it delegates to the
-// subscription handler for push-based data delivery instead of polling.
-// Adds jitter to poll intervals to prevent synchronized bursts across devices.
+// For OPC UA devices with subscriptions enabled and devices of push-only
+// protocols, it delegates to the subscription handler for push-based data
+// delivery instead of polling.
+// Tags are polled in scan classes: each distinct effective poll interval and
+// priority gets its own schedule and batched read, so slow tags don't ride
+// along with fast ones and telemetry never shares a read with alarms.
 func (s *PollingService) startDevicePoller(dp *devicePoller) {
 	if dp.running.Load() {
 		return
 	}
 
 	// Check if this device should use subscriptions instead of polling
-	if dp.device.Connection.OPCUseSubscriptions && dp.device.Protocol == domain.ProtocolOPCUA && s.subscriptionHandler != nil {
-		s.startDeviceSubscription(dp)
+	if handler := s.subscriptionHandlerFor(dp.device); handler != nil {
+		s.startDeviceSubscription(dp, handler)
 		return
 	}
+	s.startPolling(dp)
+}
+
+// startPolling starts the scan class pollers of a device.
+func (s *PollingService) startPolling(dp *devicePoller) {
+	classes := scanClasses(dp.device)
+	if len(classes) == 0 {
+		classes = []scanClass{{interval: dp.device.PollInterval}}
+	}
 
 	dp.running.Store(true)
 	s.wg.Add(1)
@@ -454,55 +549,101 @@ func (s *PollingService) startDevicePoller(dp *devicePoller) {
 		defer s.wg.Done()
 		defer dp.running.Store(false)
 
-		// Add jitter (0-10% of interval) to spread device polls over time
-		// This prevents all devices from polling simultaneously
-		jitterMax := dp.device.PollInterval / 10
-		if jitterMax > 0 {
-			jitter := time.Duration(rand.Int63n(int64(jitterMax)))
-			time.Sleep(jitter)
-		}
-
 		s.logger.Debug().
 			Str("device_id", dp.device.ID).
 			Dur("interval", dp.device.PollInterval).
+			Int("scan_classes", len(classes)).
 			Msg("Starting device poller")
 
-		ticker := time.NewTicker(dp.device.PollInterval)
-		defer ticker.Stop()
+		var running sync.WaitGroup
+		for _, class := range classes {
+			running.Add(1)
+			go func(class scanClass) {
+				defer running.Done()
+				s.runScanClass(dp, class)
+			}(class)
+		}
+		running.Wait()
+	}()
+}
+
+// runScanClass polls the tags of one scan class until the device poller is
+// stopped. Adds jitter to the first poll to prevent synchronized bursts
+// across devices.
+func (s *PollingService) runScanClass(dp *devicePoller, class scanClass) {
+	// Add jitter (0-10% of interval) to spread device polls over time
+	// This prevents all devices from polling simultaneously
+	if jitterMax := class.interval / 10; jitterMax > 0 {
+		jitter := time.Duration(rand.Int63n(int64(jitterMax)))
+		select {
+		case <-time.After(jitter):
+		case <-s.ctx.Done():
+			return
+		case <-dp.stopChan:
+			return
+		}
+	}
 
-		// Initial poll
-		s.pollDevice(dp)
+	ticker := time.NewTicker(class.interval)
+	defer ticker.Stop()
 
-		for {
-			select {
-			case <-s.ctx.Done():
-				return
-			case <-dp.stopChan:
-				return
-			case <-ticker.C:
-				s.pollDevice(dp)
-			}
+	// Initial poll
+	s.pollDevice(dp, class)
+
+	for {
+		select {
+		case <-s.ctx.Done():
+			return
+		case <-dp.stopChan:
+			return
+		case <-ticker.C:
+			s.pollDevice(dp, class)
 		}
-	}()
+	}
 }
 
-// startDeviceSubscription sets up push-based subscriptions for an OPC UA device.
-// The subscription handler receives data changes from the server and publishes
-// them to MQTT via the onData callback — no polling loop is needed.
-func (s *PollingService) startDeviceSubscription(dp *devicePoller) {
+// startDeviceSubscription sets up push-based subscriptions for a device.
+// The subscription handler receives data changes from the server (or source
+// broker) and publishes them to MQTT via the onData callback — no polling
+// loop is needed.
+func (s *PollingService) startDeviceSubscription(dp *devicePoller, handler SubscriptionHandler) {
 	tags := s.getEnabledTags(dp.device)
 	if len(tags) == 0 {
 		s.logger.Warn().Str("device_id", dp.device.ID).Msg("No enabled tags for subscription")
 		return
 	}
 
+	// Push-only protocols deliver every received message, so they get the
+	// deadband filter of the polling path; OPC UA filters on the server.
+	_, pushOnly := s.pushHandlers[dp.device.Protocol]
+	tagByID := make(map[string]*domain.Tag, len(tags))
+	for _, tag := range tags {
+		tagByID[tag.ID] = tag
+		for _, sub := range tag.BitFieldTags() {
+			tagByID[sub.ID] = sub
+		}
+	}
+
 	// The onData callback publishes each data point to MQTT.
-	// Topic is already set by the subscription manager.
+	// Topic is set by the subscription manager, or here if it left it empty.
 	onData := func(dataPoint *domain.DataPoint) {
 		s.stats.PointsRead.Add(1)
 		dp.stats.pointsRead.Add(1)
 
+		tag := tagByID[dataPoint.TagID]
+		if dataPoint.Topic == "" && tag != nil {
+			dataPoint.Topic = topicForTag(dp.device.UNSPrefix, tag)
+		}
+		// Backfilled points are history; computed tags follow current values only
+		if !dataPoint.Backfilled {
+			s.publishComputed(s.computed.update(dp.device.ID, []*domain.DataPoint{dataPoint}, time.Now()))
+		}
+
 		if dataPoint.Quality == domain.QualityGood {
+			if pushOnly && !dp.filter.allow(tag, dataPoint, time.Now()) {
+				s.stats.PointsFiltered.Add(1)
+				return
+			}
 			if err := s.publisher.Publish(s.ctx, dataPoint); err != nil {
 				s.logger.Warn().
 					Err(err).
@@ -517,10 +658,24 @@ func (s *PollingService) startDeviceSubscription(dp *devicePoller) {
 			}
 			// Heartbeat status (deduplicated to every 60s inside publishDeviceStatus)
 			s.publishDeviceStatus(dp, "online", "")
+		} else if pushOnly {
+			dp.filter.forget(dataPoint.TagID)
 		}
 	}
 
-	err := s.subscriptionHandler.Subscribe(s.ctx, dp.device, tags, onData)
+	err := handler.Subscribe(s.ctx, dp.device, tags, onData)
+	if err != nil && pushOnly {
+		s.logger.Error().
+			Err(err).
+			Str("device_id", dp.device.ID).
+			Str("protocol", string(dp.device.Protocol)).
+			Msg("Failed to subscribe device")
+		dp.mu.Lock()
+		dp.lastError = err
+		dp.mu.Unlock()
+		s.publishDeviceStatus(dp, "error", err.Error())
+		return
+	}
 	if err != nil {
 		s.logger.Error().
 			Err(err).
@@ -531,11 +686,12 @@ func (s *PollingService) startDeviceSubscription(dp *devicePoller) {
 		// Fallback to polling on subscription failure
 		dp.subscribed = false
 		dp.running.Store(false)
-		s.startDevicePoller(dp)
+		s.startPolling(dp)
 		return
 	}
 
 	dp.subscribed = true
+	dp.handler = handler
 	dp.running.Store(true)
 
 	// Publish online status now that subscription is active
@@ -543,36 +699,38 @@ func (s *PollingService) startDeviceSubscription(dp *devicePoller) {
 
 	s.logger.Info().
 		Str("device_id", dp.device.ID).
+		Str("protocol", string(dp.device.Protocol)).
 		Int("tags", len(tags)).
-		Msg("Device using OPC UA subscriptions (push mode)")
+		Msg("Device using subscriptions (push mode)")
 }
 
-// pollDevice performs a single poll cycle for a device.
-// Implements back-pressure: skips poll if all workers are busy instead of blocking.
-func (s *PollingService) pollDevice(dp *devicePoller) {
-	// Try to acquire worker from pool (non-blocking with back-pressure)
-	select {
-	case s.workerPool <- struct{}{}:
-		defer func() { <-s.workerPool }()
-	case <-s.ctx.Done():
-		return
-	default:
+// pollDevice performs a single poll cycle for one scan class of a device.
+// Implements back-pressure: telemetry and control polls are skipped if all
+// workers available to their tier are busy; safety polls wait for a worker.
+func (s *PollingService) pollDevice(dp *devicePoller, class scanClass) {
+	release, ok := s.acquireWorker(class.priority, dp.stopChan)
+	if !ok {
+		if s.ctx.Err() != nil || class.priority >= domain.PrioritySafety {
+			return // Shutting down
+		}
 		// All workers busy - skip this poll cycle (back-pressure)
 		s.stats.SkippedPolls.Add(1)
 		dp.stats.skippedCount.Add(1)
 		s.logger.Debug().
 			Str("device_id", dp.device.ID).
+			Uint8("priority", class.priority).
 			Msg("Poll skipped: worker pool full (back-pressure)")
 		return
 	}
+	defer release()
 
 	s.stats.TotalPolls.Add(1)
 	dp.stats.pollCount.Add(1)
 
 	startTime := time.Now()
 
-	// Get enabled tags
-	tags := s.getEnabledTags(dp.device)
+	// Get the enabled tags of this scan class
+	tags := s.getScanClassTags(dp.device, class)
 	if len(tags) == 0 {
 		return
 	}
@@ -583,6 +741,10 @@ func (s *PollingService) pollDevice(dp *devicePoller) {
 	for _, tag := range tags {
 		if tag != nil && tag.ID != "" {
 			tagByID[tag.ID] = tag
+			// Bit-field sub-points are returned alongside their parent
+			for _, sub := range tag.BitFieldTags() {
+				tagByID[sub.ID] = sub
+			}
 		}
 	}
 
@@ -610,6 +772,7 @@ func (s *PollingService) pollDevice(dp *devicePoller) {
 				Err(err).
 				Str("device_id", dp.device.ID).
 				Msg("Poll skipped: circuit breaker open")
+			s.publishComputed(s.computed.markBad(dp.device.ID, tags, time.Now()))
 			return
 		}
 
@@ -630,6 +793,7 @@ func (s *PollingService) pollDevice(dp *devicePoller) {
 			Msg("Failed to read tags")
 
 		s.publishDeviceStatus(dp, "error", err.Error())
+		s.publishComputed(s.computed.markBad(dp.device.ID, tags, time.Now()))
 		return
 	}
 
@@ -660,31 +824,50 @@ func (s *PollingService) pollDevice(dp *devicePoller) {
 
 	// Set topics and filter good data points.
 	// Do NOT assume datapoints are aligned with tags by index.
+	filtered := 0
+	now := time.Now()
 	for _, point := range dataPoints {
 		if point == nil {
 			continue
 		}
 
-		if tag := tagByID[point.TagID]; tag != nil {
+		tag := tagByID[point.TagID]
+		if tag != nil {
 			point.Topic = topicForTag(dp.device.UNSPrefix, tag)
+			point.Priority = tag.Priority
 		} else if suffix := sanitizeTopicSegment(point.TagID); suffix != "" {
 			point.Topic = dp.device.UNSPrefix + "/" + suffix
 		} else {
 			point.Topic = dp.device.UNSPrefix
 		}
 
-		if point.Quality == domain.QualityGood {
-			goodPoints = append(goodPoints, point)
+		if point.Quality != domain.QualityGood {
+			// Report the first good value after a bad read regardless of deadband
+			dp.filter.forget(point.TagID)
+			continue
 		}
+		if !dp.filter.allow(tag, point, now) {
+			filtered++
+			continue
+		}
+		goodPoints = append(goodPoints, point)
 	}
 
+	// Evaluate computed tags from all points, so bad reads propagate
+	computed := s.computed.update(dp.device.ID, dataPoints, now)
+
 	s.stats.PointsRead.Add(uint64(len(dataPoints)))
 	dp.stats.pointsRead.Add(uint64(len(dataPoints)))
+	if filtered > 0 {
+		s.stats.PointsFiltered.Add(uint64(filtered))
+		if s.metrics != nil {
+			s.metrics.PointsFiltered.Add(float64(filtered))
+		}
+	}
 
-	// Calculate staleness for good data points relative to expected poll interval.
-	pollInterval := dp.device.PollInterval
+	// Calculate staleness for good data points relative to the scan class interval.
 	for _, point := range goodPoints {
-		point.CalculateStaleness(pollInterval)
+		point.CalculateStaleness(class.interval)
 	}
 
 	// Publish good data points.
@@ -705,6 +888,7 @@ func (s *PollingService) pollDevice(dp *devicePoller) {
 			}
 		}
 	}
+	s.publishComputed(computed)
 
 	// Record poll duration for metrics
 	duration := time.Since(startTime)
@@ -717,8 +901,11 @@ func (s *PollingService) pollDevice(dp *devicePoller) {
 	// Log poll completion
 	s.logger.Debug().
 		Str("device_id", dp.device.ID).
+		Dur("scan_class", class.interval).
+		Uint8("priority", class.priority).
 		Int("tags_read", len(dataPoints)).
 		Int("good_points", len(goodPoints)).
+		Int("filtered_points", filtered).
 		Dur("duration", duration).
 		Msg("Poll cycle completed")
 
@@ -726,6 +913,25 @@ func (s *PollingService) pollDevice(dp *devicePoller) {
 	s.publishDeviceStatus(dp, "online", "")
 }
 
+// publishComputed publishes the data points of computed tags.
+func (s *PollingService) publishComputed(points []*domain.DataPoint) {
+	if len(points) == 0 {
+		return
+	}
+	if err := s.publisher.PublishBatch(s.ctx, points); err != nil {
+		s.logger.Warn().
+			Err(err).
+			Int("points", len(points)).
+			Msg("Failed to publish computed tags")
+		return
+	}
+	s.stats.PointsPublished.Add(uint64(len(points)))
+	s.stats.PointsComputed.Add(uint64(len(points)))
+	if s.metrics != nil {
+		s.metrics.PointsPublished.Add(float64(len(points)))
+	}
+}
+
 // publishDeviceStatus publishes a device status update to MQTT if the status
 // changed or hasn't been reported in the last 60 seconds.
 func (s *PollingService) publishDeviceStatus(dp *devicePoller, status string, lastError string) {
@@ -760,17 +966,112 @@ func (s *PollingService) publishDeviceStatus(dp *devicePoller, status string, la
 	dp.mu.Unlock()
 }
 
-// getEnabledTags returns only the enabled tags for a device.
+// getEnabledTags returns the enabled tags read from a device; computed tags
+// are evaluated instead.
 func (s *PollingService) getEnabledTags(device *domain.Device) []*domain.Tag {
 	tags := make([]*domain.Tag, 0, len(device.Tags))
 	for i := range device.Tags {
-		if device.Tags[i].Enabled {
+		if device.Tags[i].Enabled && !device.Tags[i].IsComputed() {
 			tags = append(tags, &device.Tags[i])
 		}
 	}
 	return tags
 }
 
+// acquireWorker takes a worker slot for a poll of the given priority tier and
+// returns the function that gives it back. Each tier has its own pool; a poll
+// may borrow an idle slot from a lower tier but never from a higher one, so a
+// telemetry flood cannot consume capacity reserved for control and safety.
+// Telemetry and control polls don't wait (ok=false means skip the cycle);
+// safety polls wait for the first free slot until the poller is stopped.
+func (s *PollingService) acquireWorker(priority uint8, stop <-chan struct{}) (release func(), ok bool) {
+	tier := min(priority, domain.PrioritySafety)
+	for i := int(tier); i >= 0; i-- {
+		pool := s.workerPools[i]
+		select {
+		case pool <- struct{}{}:
+			return func() { <-pool }, true
+		default:
+		}
+	}
+	if tier < domain.PrioritySafety {
+		return nil, false
+	}
+
+	telemetry := s.workerPools[domain.PriorityTelemetry]
+	control := s.workerPools[domain.PriorityControl]
+	safety := s.workerPools[domain.PrioritySafety]
+	select {
+	case safety <- struct{}{}:
+		return func() { <-safety }, true
+	case control <- struct{}{}:
+		return func() { <-control }, true
+	case telemetry <- struct{}{}:
+		return func() { <-telemetry }, true
+	case <-s.ctx.Done():
+		return nil, false
+	case <-stop:
+		return nil, false
+	}
+}
+
+// scanClass is a group of a device's tags polled together in one batched read.
+type scanClass struct {
+	interval time.Duration
+	priority uint8
+}
+
+func (c scanClass) String() string {
+	return fmt.Sprintf("%s/p%d", c.interval, c.priority)
+}
+
+// getScanClassTags returns the enabled tags read from a device that belong
+// to class.
+func (s *PollingService) getScanClassTags(device *domain.Device, class scanClass) []*domain.Tag {
+	tags := make([]*domain.Tag, 0, len(device.Tags))
+	for i := range device.Tags {
+		tag := &device.Tags[i]
+		if tag.Enabled && !tag.IsComputed() && tagScanClass(device, tag) == class {
+			tags = append(tags, tag)
+		}
+	}
+	return tags
+}
+
+// tagScanClass returns the scan class of a tag: its effective poll interval
+// and priority tier.
+func tagScanClass(device *domain.Device, tag *domain.Tag) scanClass {
+	return scanClass{
+		interval: tag.GetEffectivePollInterval(device.PollInterval),
+		priority: min(tag.Priority, domain.PrioritySafety),
+	}
+}
+
+// scanClasses returns the distinct scan classes of a device's enabled tags
+// read from the device, fastest first and, within an interval, highest
+// priority first.
+func scanClasses(device *domain.Device) []scanClass {
+	seen := make(map[scanClass]struct{})
+	classes := make([]scanClass, 0, 1)
+	for i := range device.Tags {
+		if !device.Tags[i].Enabled || device.Tags[i].IsComputed() {
+			continue
+		}
+		class := tagScanClass(device, &device.Tags[i])
+		if _, ok := seen[class]; !ok {
+			seen[class] = struct{}{}
+			classes = append(classes, class)
+		}
+	}
+	slices.SortFunc(classes, func(a, b scanClass) int {
+		if a.interval != b.interval {
+			return cmp.Compare(a.interval, b.interval)
+		}
+		return cmp.Compare(b.priority, a.priority)
+	})
+	return classes
+}
+
 // GetDeviceStatus returns the status of a device.
 func (s *PollingService) GetDeviceStatus(deviceID string) (*DeviceStatus, error) {
 	s.mu.RLock()
@@ -827,6 +1128,8 @@ type StatsSnapshot struct {
 	SkippedPolls    uint64
 	PointsRead      uint64
 	PointsPublished uint64
+	PointsFiltered  uint64
+	PointsComputed  uint64
 }
 
 // Stats returns a snapshot of the polling service statistics.
@@ -838,5 +1141,7 @@ func (s *PollingService) Stats() StatsSnapshot {
 		SkippedPolls:    s.stats.SkippedPolls.Load(),
 		PointsRead:      s.stats.PointsRead.Load(),
 		PointsPublished: s.stats.PointsPublished.Load(),
+		PointsFiltered:  s.stats.PointsFiltered.Load(),
+		PointsComputed:  s.stats.PointsComputed.Load(),
 	}
 }
@@ -0,0 +1,208 @@
+package service
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/nexus-edge/protocol-gateway/internal/domain"
+	"github.com/rs/zerolog"
+)
+
+// recordingPool is a ProtocolPool that records each ReadTags batch and each
+// multi-tag write.
+type recordingPool struct {
+	mu      sync.Mutex
+	batches [][]string
+	writes  [][]domain.TagWrite
+}
+
+func (p *recordingPool) ReadTags(ctx context.Context, device *domain.Device, tags []*domain.Tag) ([]*domain.DataPoint, error) {
+	ids := make([]string, 0, len(tags))
+	points := make([]*domain.DataPoint, 0, len(tags))
+	for _, tag := range tags {
+		ids = append(ids, tag.ID)
+		points = append(points, domain.NewDataPoint(device.ID, tag.ID, "", 1, "", domain.QualityGood))
+	}
+	p.mu.Lock()
+	p.batches = append(p.batches, ids)
+	p.mu.Unlock()
+	return points, nil
+}
+
+func (p *recordingPool) ReadTag(ctx context.Context, device *domain.Device, tag *domain.Tag) (*domain.DataPoint, error) {
+	points, err := p.ReadTags(ctx, device, []*domain.Tag{tag})
+	if err != nil {
+		return nil, err
+	}
+	return points[0], nil
+}
+
+func (p *recordingPool) WriteTag(ctx context.Context, device *domain.Device, tag *domain.Tag, value interface{}) error {
+	return nil
+}
+
+func (p *recordingPool) WriteTags(ctx context.Context, device *domain.Device, writes []domain.TagWrite) []error {
+	p.mu.Lock()
+	p.writes = append(p.writes, writes)
+	p.mu.Unlock()
+	return make([]error, len(writes))
+}
+
+func (p *recordingPool) Close() error                          { return nil }
+func (p *recordingPool) HealthCheck(ctx context.Context) error { return nil }
+
+// readCounts returns how often each tag was read and fails if a batch mixed tags
+// that don't belong to the same scan class.
+func (p *recordingPool) readCounts(t *testing.T, classOf map[string]scanClass) map[string]int {
+	t.Helper()
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	counts := make(map[string]int)
+	for _, batch := range p.batches {
+		for _, id := range batch {
+			if classOf[id] != classOf[batch[0]] {
+				t.Errorf("batch %v mixes scan classes", batch)
+			}
+			counts[id]++
+		}
+	}
+	return counts
+}
+
+type nopPublisher struct{}
+
+func (nopPublisher) Publish(ctx context.Context, dataPoint *domain.DataPoint) error { return nil }
+func (nopPublisher) PublishBatch(ctx context.Context, dataPoints []*domain.DataPoint) error {
+	return nil
+}
+
+func durationPtr(d time.Duration) *time.Duration { return &d }
+
+func scanClassDevice() *domain.Device {
+	return &domain.Device{
+		ID:           "plc-1",
+		Protocol:     domain.ProtocolS7,
+		Enabled:      true,
+		PollInterval: time.Second,
+		UNSPrefix:    "site/plc-1",
+		Connection:   domain.ConnectionConfig{Timeout: time.Second},
+		Tags: []domain.Tag{
+			{ID: "alarm_word", Name: "alarm_word", Enabled: true, PollInterval: durationPtr(100 * time.Millisecond)},
+			{ID: "temperature", Name: "temperature", Enabled: true},
+			{ID: "pressure", Name: "pressure", Enabled: true},
+			{ID: "serial_number", Name: "serial_number", Enabled: true, PollInterval: durationPtr(time.Minute)},
+			{ID: "spare", Name: "spare", Enabled: false, PollInterval: durationPtr(50 * time.Millisecond)},
+		},
+	}
+}
+
+func TestScanClasses_GroupsByIntervalAndPriority(t *testing.T) {
+	device := scanClassDevice()
+	device.Tags = append(device.Tags, domain.Tag{ID: "estop", Name: "estop", Enabled: true, Priority: domain.PrioritySafety})
+	classes := scanClasses(device)
+
+	want := []scanClass{
+		{interval: 100 * time.Millisecond},
+		{interval: time.Second, priority: domain.PrioritySafety},
+		{interval: time.Second},
+		{interval: time.Minute},
+	}
+	if len(classes) != len(want) {
+		t.Fatalf("expected scan classes %v, got %v", want, classes)
+	}
+	for i := range want {
+		if classes[i] != want[i] {
+			t.Errorf("scan class %d: expected %v, got %v", i, want[i], classes[i])
+		}
+	}
+}
+
+func TestPollingService_ScanClassesPollIndependently(t *testing.T) {
+	pool := &recordingPool{}
+	manager := domain.NewProtocolManager()
+	manager.RegisterPool(domain.ProtocolS7, pool)
+
+	svc := NewPollingService(PollingConfig{}, manager, nopPublisher{}, zerolog.Nop(), nil)
+	device := scanClassDevice()
+	if err := svc.RegisterDevice(context.Background(), device); err != nil {
+		t.Fatalf("RegisterDevice: %v", err)
+	}
+	if err := svc.Start(context.Background()); err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	time.Sleep(550 * time.Millisecond)
+	svc.Stop(context.Background())
+
+	classOf := make(map[string]scanClass)
+	for i := range device.Tags {
+		classOf[device.Tags[i].ID] = tagScanClass(device, &device.Tags[i])
+	}
+	counts := pool.readCounts(t, classOf)
+
+	if counts["alarm_word"] < 4 {
+		t.Errorf("alarm_word: expected at least 4 reads at 100ms, got %d", counts["alarm_word"])
+	}
+	for _, id := range []string{"temperature", "pressure"} {
+		if counts[id] != 1 {
+			t.Errorf("%s: expected only the initial read, got %d", id, counts[id])
+		}
+	}
+	// The 1-minute class may still be in its start-up jitter (up to 6s)
+	if counts["serial_number"] > 1 {
+		t.Errorf("serial_number: expected at most the initial read, got %d", counts["serial_number"])
+	}
+	if counts["spare"] != 0 {
+		t.Errorf("spare: disabled tag was read %d times", counts["spare"])
+	}
+}
+
+func TestAcquireWorker_ReservedCapacityPerTier(t *testing.T) {
+	svc := NewPollingService(PollingConfig{WorkerCount: 1, ControlWorkers: 1, SafetyWorkers: 1}, domain.NewProtocolManager(), nopPublisher{}, zerolog.Nop(), nil)
+	svc.ctx, svc.cancel = context.WithCancel(context.Background())
+	defer svc.cancel()
+	stop := make(chan struct{})
+
+	releaseTelemetry, ok := svc.acquireWorker(domain.PriorityTelemetry, stop)
+	if !ok {
+		t.Fatal("expected a telemetry worker")
+	}
+	if _, ok := svc.acquireWorker(domain.PriorityTelemetry, stop); ok {
+		t.Fatal("telemetry must not take control or safety capacity")
+	}
+
+	releaseControl, ok := svc.acquireWorker(domain.PriorityControl, stop)
+	if !ok {
+		t.Fatal("control must have reserved capacity while telemetry is saturated")
+	}
+	releaseSafety, ok := svc.acquireWorker(domain.PrioritySafety, stop)
+	if !ok {
+		t.Fatal("safety must have reserved capacity")
+	}
+
+	// Every pool is busy: a safety poll waits instead of being skipped
+	acquired := make(chan func(), 1)
+	go func() {
+		release, ok := svc.acquireWorker(domain.PrioritySafety, stop)
+		if ok {
+			acquired <- release
+		}
+	}()
+	select {
+	case <-acquired:
+		t.Fatal("safety poll acquired a worker while all pools were busy")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	releaseTelemetry()
+	select {
+	case release := <-acquired:
+		release() // Borrowed the freed telemetry slot
+	case <-time.After(time.Second):
+		t.Fatal("safety poll was not granted the freed worker")
+	}
+	releaseControl()
+	releaseSafety()
+}